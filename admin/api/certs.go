@@ -0,0 +1,18 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/eBay/fabio/cert"
+)
+
+// HandleCerts returns the certificates which have been loaded from the
+// certificate sources with their names, SANs, expiry and the time the
+// source last provided new certificates.
+func HandleCerts(w http.ResponseWriter, r *http.Request) {
+	certs := cert.Loaded()
+	if certs == nil {
+		certs = []cert.Info{}
+	}
+	writeJSON(w, r, certs)
+}
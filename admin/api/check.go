@@ -0,0 +1,114 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	fabioroute "github.com/eBay/fabio/route"
+)
+
+type checkResult struct {
+	Service  string `json:"service"`
+	Route    string `json:"route"`
+	Target   string `json:"target"`
+	Healthy  bool   `json:"healthy"`
+	Status   int    `json:"status,omitempty"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HandleCheck runs a health check against the targets which match the
+// 'route' and 'target' parameters and returns the results. 'route' is
+// the host and path of the route and 'target' is the URL of the target.
+// At least one of them is required. Targets with the 'check=<path>'
+// option are checked with an HTTP GET request for the path which must
+// not return a 5xx status code. All other targets are checked by
+// opening a TCP connection. The 'timeout' parameter overrides the
+// default timeout of 5s.
+func HandleCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	route, target := r.FormValue("route"), r.FormValue("target")
+	if route == "" && target == "" {
+		http.Error(w, "route or target required", http.StatusBadRequest)
+		return
+	}
+
+	timeout := 5 * time.Second
+	if s := r.FormValue("timeout"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid timeout: "+s, http.StatusBadRequest)
+			return
+		}
+		timeout = d
+	}
+
+	var targets []*fabioroute.Target
+	for _, routes := range fabioroute.GetTable() {
+		for _, rt := range routes {
+			for _, t := range rt.Targets {
+				if route != "" && t.Route != route {
+					continue
+				}
+				if target != "" && t.URL.String() != target {
+					continue
+				}
+				targets = append(targets, t)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		http.Error(w, "no matching targets", http.StatusNotFound)
+		return
+	}
+
+	results := make([]checkResult, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t *fabioroute.Target) {
+			defer wg.Done()
+			results[i] = check(t, timeout)
+		}(i, t)
+	}
+	wg.Wait()
+	writeJSON(w, r, results)
+}
+
+// check runs the health check for the target.
+func check(t *fabioroute.Target, timeout time.Duration) (res checkResult) {
+	res = checkResult{Service: t.Service, Route: t.Route, Target: t.URL.String()}
+	start := time.Now()
+	defer func() { res.Duration = time.Since(start).String() }()
+
+	path, ok := t.Opts["check"]
+	if !ok {
+		conn, err := net.DialTimeout("tcp", t.URL.Host, timeout)
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		conn.Close()
+		res.Healthy = true
+		return res
+	}
+
+	u := *t.URL
+	u.Path, u.RawQuery = path, ""
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	resp.Body.Close()
+	res.Status = resp.StatusCode
+	res.Healthy = resp.StatusCode < 500
+	return res
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	fabioroute "github.com/eBay/fabio/route"
+)
+
+func TestHandleCheck(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer up.Close()
+
+	// closed server for a target which is down
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	tbl, err := fabioroute.ParseString(
+		"route add a /a " + up.URL + "/ opts \"check=/health\"\n" +
+			"route add a /a " + down.URL + "/\n" +
+			"route add b /b " + up.URL + "/ opts \"check=/fail\"\n",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fabioroute.SetTable(fabioroute.GetTable())
+	fabioroute.SetTable(tbl)
+
+	check := func(v url.Values) (int, []checkResult) {
+		req := httptest.NewRequest("POST", "/api/check", strings.NewReader(v.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		HandleCheck(rec, req)
+		var res []checkResult
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, res
+	}
+
+	code, res := check(url.Values{"route": {"/a"}})
+	if code != 200 || len(res) != 2 {
+		t.Fatalf("got %d %v want 200 and 2 results", code, res)
+	}
+	for _, r := range res {
+		switch r.Target {
+		case up.URL + "/":
+			if !r.Healthy || r.Status != 200 {
+				t.Errorf("%s: got %v want healthy with status 200", r.Target, r)
+			}
+		case down.URL + "/":
+			if r.Healthy || r.Error == "" {
+				t.Errorf("%s: got %v want unhealthy with error", r.Target, r)
+			}
+		default:
+			t.Errorf("unexpected target %s", r.Target)
+		}
+	}
+
+	code, res = check(url.Values{"route": {"/b"}, "target": {up.URL + "/"}})
+	if code != 200 || len(res) != 1 || res[0].Healthy || res[0].Status != 503 {
+		t.Fatalf("got %d %v want one unhealthy result with status 503", code, res)
+	}
+
+	if code, _ := check(url.Values{"route": {"/c"}}); code != http.StatusNotFound {
+		t.Fatalf("got %d want 404", code)
+	}
+	if code, _ := check(url.Values{}); code != http.StatusBadRequest {
+		t.Fatalf("got %d want 400", code)
+	}
+}
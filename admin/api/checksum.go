@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	fabioroute "github.com/eBay/fabio/route"
+)
+
+type checksum struct {
+	Checksum string    `json:"checksum"`
+	Targets  int       `json:"targets"`
+	Updated  time.Time `json:"updated,omitempty"`
+}
+
+// HandleChecksum returns the checksum of the routing table, the number
+// of targets and the time of the last change. Instances of a fleet with
+// the same routes have the same checksum.
+func HandleChecksum(w http.ResponseWriter, r *http.Request) {
+	t := fabioroute.GetTable()
+	c := checksum{Checksum: t.Checksum(), Targets: len(t.Config(false))}
+	if h := fabioroute.History(); len(h) > 0 {
+		c.Updated = h[0].Time
+	}
+	writeJSON(w, r, c)
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	fabioroute "github.com/eBay/fabio/route"
+)
+
+func TestHandleChecksum(t *testing.T) {
+	tbl, err := fabioroute.ParseString("route add a / http://1.1.1.1/\nroute add b /b http://2.2.2.2/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fabioroute.SetTable(tbl)
+	defer fabioroute.SetTable(make(fabioroute.Table))
+
+	rec := httptest.NewRecorder()
+	HandleChecksum(rec, httptest.NewRequest("GET", "/api/routes/checksum", nil))
+
+	var c checksum
+	if err := json.NewDecoder(rec.Body).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.Checksum, tbl.Checksum(); got != want {
+		t.Fatalf("got checksum %q want %q", got, want)
+	}
+	if got, want := c.Targets, 2; got != want {
+		t.Fatalf("got %d targets want %d", got, want)
+	}
+	if c.Updated.IsZero() {
+		t.Fatal("got no update time")
+	}
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eBay/fabio/config"
+)
+
+func TestHandleConfigSecrets(t *testing.T) {
+	defer func(c *config.Config) { Cfg = c }(Cfg)
+	Cfg = &config.Config{
+		OIDCValue: []map[string]string{{"name": "corp", "clientsecret": "client-s3cret", "cookiesecret": "cookie-s3cret"}},
+		OIDC: map[string]config.OIDCProvider{
+			"corp": {Name: "corp", ClientID: "fabio", ClientSecret: "client-s3cret", CookieSecret: "cookie-s3cret"},
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	HandleConfig(rec, httptest.NewRequest("GET", "/api/config", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `"ClientID":"fabio"`) {
+		t.Fatalf("got %s want OIDC provider", body)
+	}
+	for _, s := range []string{"client-s3cret", "cookie-s3cret"} {
+		if strings.Contains(body, s) {
+			t.Errorf("got %s in %s", s, body)
+		}
+	}
+}
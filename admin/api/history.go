@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	fabioroute "github.com/eBay/fabio/route"
+)
+
+// HandleHistory returns the recent changes of the routing table with
+// the most recent change first. The 'since' and 'until' parameters
+// limit the changes to a time range in RFC 3339 format, e.g.
+//
+//	/api/routes/history?since=2017-07-14T14:30:00Z&until=2017-07-14T14:35:00Z
+func HandleHistory(w http.ResponseWriter, r *http.Request) {
+	var since, until time.Time
+	for name, t := range map[string]*time.Time{"since": &since, "until": &until} {
+		s := r.URL.Query().Get(name)
+		if s == "" {
+			continue
+		}
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "invalid value for "+name+": "+s, http.StatusBadRequest)
+			return
+		}
+		*t = v
+	}
+
+	changes := []fabioroute.Change{}
+	for _, c := range fabioroute.History() {
+		if (!since.IsZero() && c.Time.Before(since)) || (!until.IsZero() && c.Time.After(until)) {
+			continue
+		}
+		changes = append(changes, c)
+	}
+	writeJSON(w, r, changes)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	fabioroute "github.com/eBay/fabio/route"
+)
+
+func TestHandleHistory(t *testing.T) {
+	defer fabioroute.SetTable(make(fabioroute.Table))
+	start := time.Now()
+	tbl, err := fabioroute.ParseString("route add a /a http://1.1.1.1:80/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fabioroute.SetTableFrom(tbl, "manual")
+
+	tests := []struct {
+		query string
+		code  int
+		found bool
+	}{
+		{"", 200, true},
+		{"?since=" + start.Add(-time.Second).Format(time.RFC3339), 200, true},
+		{"?since=" + start.Add(time.Hour).Format(time.RFC3339), 200, false},
+		{"?until=" + start.Add(-time.Hour).Format(time.RFC3339), 200, false},
+		{"?since=yesterday", 400, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			HandleHistory(rec, httptest.NewRequest("GET", "/api/routes/history"+tt.query, nil))
+			if got, want := rec.Code, tt.code; got != want {
+				t.Fatalf("got code %d want %d", got, want)
+			}
+			if tt.code != 200 {
+				return
+			}
+			var changes []fabioroute.Change
+			if err := json.NewDecoder(rec.Body).Decode(&changes); err != nil {
+				t.Fatal(err)
+			}
+			found := false
+			for _, c := range changes {
+				if c.Source == "manual" && len(c.Added) == 1 && c.Added[0] == "route add a /a http://1.1.1.1:80/ weight 1.00" {
+					found = true
+				}
+			}
+			if found != tt.found {
+				t.Fatalf("got change %v want %v in %+v", found, tt.found, changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/eBay/fabio/diag"
+)
+
+// HandleLeaks returns the last leak detector report. If the leak
+// detector is disabled the report contains only the current snapshot.
+func HandleLeaks(w http.ResponseWriter, r *http.Request) {
+	if diag.Default == nil {
+		writeJSON(w, r, &diag.Report{Current: diag.Take()})
+		return
+	}
+	writeJSON(w, r, diag.Default.Report())
+}
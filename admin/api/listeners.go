@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/eBay/fabio/config"
+)
+
+// ListenerManager opens, replaces and closes the
+// listeners of the running proxy.
+type ListenerManager interface {
+	Listeners() []config.Listen
+	AddListener(l config.Listen) error
+	UpdateListener(l config.Listen) error
+	RemoveListener(addr string) error
+}
+
+// Listeners manages the listeners for the listeners api.
+var Listeners ListenerManager
+
+type listenerInfo struct {
+	Addr         string `json:"addr"`
+	Proto        string `json:"proto"`
+	CertSource   string `json:"cs,omitempty"`
+	ReadTimeout  string `json:"rt,omitempty"`
+	WriteTimeout string `json:"wt,omitempty"`
+	StrictMatch  bool   `json:"strictmatch,omitempty"`
+	Redirect     string `json:"redirect,omitempty"`
+}
+
+type listenerValue struct {
+	Value string `json:"value"`
+}
+
+// HandleListeners lists the open listeners on GET, opens a new
+// listener on POST, replaces the listener on the same address on PUT
+// and closes the listener with the address from the 'addr' parameter
+// on DELETE. New listeners are described by a JSON object with a
+// 'value' in the format of proxy.addr, e.g.
+//
+//	{"value": ":9443;proto=https;cs=tenant"}
+func HandleListeners(w http.ResponseWriter, r *http.Request) {
+	if Listeners == nil {
+		http.Error(w, "listeners cannot be managed", http.StatusNotImplemented)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		infos := []listenerInfo{}
+		for _, l := range Listeners.Listeners() {
+			info := listenerInfo{
+				Addr:        l.Addr,
+				Proto:       l.Proto,
+				CertSource:  l.CertSource.Name,
+				StrictMatch: l.StrictMatch,
+				Redirect:    l.Redirect,
+			}
+			if l.RedirectPort != "" {
+				info.Redirect += ":" + l.RedirectPort
+			}
+			if l.ReadTimeout > 0 {
+				info.ReadTimeout = l.ReadTimeout.String()
+			}
+			if l.WriteTimeout > 0 {
+				info.WriteTimeout = l.WriteTimeout.String()
+			}
+			infos = append(infos, info)
+		}
+		writeJSON(w, r, infos)
+
+	case "POST", "PUT":
+		var v listenerValue
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		l, err := config.ParseListen(Cfg, v.Value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if l.Addr == "" {
+			http.Error(w, "missing listener address", http.StatusBadRequest)
+			return
+		}
+
+		if r.Method == "POST" {
+			err = Listeners.AddListener(l)
+		} else {
+			err = Listeners.UpdateListener(l)
+		}
+		if err != nil {
+			log.Print("[ERROR] ", err)
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+	case "DELETE":
+		addr := r.URL.Query().Get("addr")
+		if addr == "" {
+			http.Error(w, "missing addr", http.StatusBadRequest)
+			return
+		}
+		if err := Listeners.RemoveListener(addr); err != nil {
+			log.Print("[ERROR] ", err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+	default:
+		http.Error(w, "not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eBay/fabio/config"
+)
+
+type fakeListeners struct {
+	ls []config.Listen
+}
+
+func (f *fakeListeners) Listeners() []config.Listen { return f.ls }
+
+func (f *fakeListeners) AddListener(l config.Listen) error {
+	for _, x := range f.ls {
+		if x.Addr == l.Addr {
+			return errors.New("exists")
+		}
+	}
+	f.ls = append(f.ls, l)
+	return nil
+}
+
+func (f *fakeListeners) UpdateListener(l config.Listen) error {
+	for i, x := range f.ls {
+		if x.Addr == l.Addr {
+			f.ls[i] = l
+			return nil
+		}
+	}
+	return errors.New("missing")
+}
+
+func (f *fakeListeners) RemoveListener(addr string) error {
+	for i, x := range f.ls {
+		if x.Addr == addr {
+			f.ls = append(f.ls[:i], f.ls[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("missing")
+}
+
+func TestHandleListeners(t *testing.T) {
+	defer func(c *config.Config, l ListenerManager) { Cfg, Listeners = c, l }(Cfg, Listeners)
+	Cfg = &config.Config{CertSources: map[string]config.CertSource{"tenant": {Name: "tenant", Type: "path"}}}
+	f := &fakeListeners{}
+	Listeners = f
+
+	do := func(method, url, body string) (int, string) {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		HandleListeners(rec, req)
+		return rec.Code, strings.TrimSpace(rec.Body.String())
+	}
+
+	tests := []struct {
+		method, url, body string
+		code              int
+		resp              string
+	}{
+		{"POST", "/api/listeners", `{"value": ":9000"}`, 200, ""},
+		{"POST", "/api/listeners", `{"value": ":9000"}`, 409, "exists"},
+		{"POST", "/api/listeners", `{"value": ":9443;cs=tenant"}`, 200, ""},
+		{"POST", "/api/listeners", `{"value": ":9444;cs=foo"}`, 400, `unknown certificate source "foo"`},
+		{"POST", "/api/listeners", `{"value": ""}`, 400, "missing listener address"},
+		{"PUT", "/api/listeners", `{"value": ":9000;strictmatch=true"}`, 200, ""},
+		{"PUT", "/api/listeners", `{"value": ":9001"}`, 409, "missing"},
+		{"GET", "/api/listeners", "", 200, `[{"addr":":9000","proto":"http","strictmatch":true},{"addr":":9443","proto":"https","cs":"tenant"}]`},
+		{"DELETE", "/api/listeners?addr=:9000", "", 200, ""},
+		{"DELETE", "/api/listeners?addr=:9000", "", 404, "missing"},
+		{"DELETE", "/api/listeners", "", 400, "missing addr"},
+		{"GET", "/api/listeners", "", 200, `[{"addr":":9443","proto":"https","cs":"tenant"}]`},
+	}
+
+	for i, tt := range tests {
+		code, resp := do(tt.method, tt.url, tt.body)
+		if code != tt.code || resp != tt.resp {
+			t.Errorf("%d: %s %s: got %d %q want %d %q", i, tt.method, tt.url, code, resp, tt.code, tt.resp)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	fabioroute "github.com/eBay/fabio/route"
+)
+
+type lookupInfo struct {
+	*fabioroute.Explanation
+	Strategy string `json:"strategy,omitempty"`
+}
+
+// HandleLookup explains which route and targets the routing table
+// selects for the request described by the 'url' parameter without
+// sending a request. The optional 'method', 'header' and 'remote'
+// parameters set the method, the headers and the client address of
+// the request for the predicates of the targets, e.g.
+//
+//	/api/routes/lookup?url=https://example.com/app&method=POST&header=X-Beta:1
+func HandleLookup(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	u, err := url.Parse(q.Get("url"))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		http.Error(w, "invalid url: "+q.Get("url"), http.StatusBadRequest)
+		return
+	}
+
+	req := &http.Request{
+		Method:     "GET",
+		URL:        u,
+		Host:       u.Host,
+		RequestURI: u.RequestURI(),
+		Header:     http.Header{},
+		RemoteAddr: "127.0.0.1:0",
+	}
+	if m := q.Get("method"); m != "" {
+		req.Method = strings.ToUpper(m)
+	}
+	if u.Scheme == "https" {
+		req.TLS = &tls.ConnectionState{ServerName: u.Hostname()}
+	}
+	for _, h := range q["header"] {
+		p := strings.SplitN(h, ":", 2)
+		if len(p) != 2 || strings.TrimSpace(p[0]) == "" {
+			http.Error(w, "invalid header: "+h, http.StatusBadRequest)
+			return
+		}
+		req.Header.Add(strings.TrimSpace(p[0]), strings.TrimSpace(p[1]))
+	}
+	if addr := q.Get("remote"); addr != "" {
+		if net.ParseIP(addr) == nil {
+			http.Error(w, "invalid remote: "+addr, http.StatusBadRequest)
+			return
+		}
+		req.RemoteAddr = net.JoinHostPort(addr, "0")
+	}
+
+	info := lookupInfo{Explanation: fabioroute.GetTable().Explain(req)}
+	if Cfg != nil && len(info.Candidates) > 1 {
+		info.Strategy = Cfg.Proxy.Strategy
+	}
+	writeJSON(w, r, info)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	fabioroute "github.com/eBay/fabio/route"
+)
+
+func TestHandleLookup(t *testing.T) {
+	tbl, err := fabioroute.ParseString(`
+route add a example.com/ http://1.1.1.1:80/
+route add b example.com/beta http://2.2.2.2:80/ opts "match-header=X-Beta:1"
+route add c example.com/beta http://3.3.3.3:80/ opts "src=10.0.0.0/8"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fabioroute.SetTable(tbl)
+	defer fabioroute.SetTable(make(fabioroute.Table))
+
+	tests := []struct {
+		query string
+		code  int
+		url   string
+	}{
+		{"url=" + url.QueryEscape("https://example.com/x"), 200, "http://1.1.1.1:80/"},
+		{"url=" + url.QueryEscape("http://example.com/beta") + "&header=X-Beta:1", 200, "http://2.2.2.2:80/"},
+		{"url=" + url.QueryEscape("http://example.com/beta") + "&remote=10.1.1.1", 200, "http://3.3.3.3:80/"},
+		{"url=" + url.QueryEscape("http://example.com/beta"), 200, "http://1.1.1.1:80/"},
+		{"url=" + url.QueryEscape("http://other.com/"), 200, ""},
+		{"url=/x", 400, ""},
+		{"url=" + url.QueryEscape("http://example.com/") + "&header=foo", 400, ""},
+		{"url=" + url.QueryEscape("http://example.com/") + "&remote=foo", 400, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			HandleLookup(rec, httptest.NewRequest("GET", "/api/routes/lookup?"+tt.query, nil))
+			if got, want := rec.Code, tt.code; got != want {
+				t.Fatalf("got code %d want %d", got, want)
+			}
+			if tt.code != 200 {
+				return
+			}
+			var e fabioroute.Explanation
+			if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			if len(e.Candidates) > 0 {
+				got = e.Candidates[0].URL
+			}
+			if got != tt.url {
+				t.Fatalf("got target %q want %q in %+v", got, tt.url, e)
+			}
+		})
+	}
+}
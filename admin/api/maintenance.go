@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	fabioroute "github.com/eBay/fabio/route"
+)
+
+type maintenanceInfo struct {
+	Route      string `json:"route"`
+	Status     int    `json:"status,omitempty"`
+	Body       string `json:"body,omitempty"`
+	RetryAfter string `json:"retryafter,omitempty"`
+}
+
+// HandleMaintenance lists the routes in maintenance mode on GET, puts
+// a route into maintenance mode on PUT and ends the maintenance mode
+// of the route from the 'route' parameter on DELETE. The route is the
+// host and path of the route and the status code defaults to 503, e.g.
+//
+//	{"route": "example.com/app", "status": 503, "retryafter": "10m"}
+func HandleMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		infos := []maintenanceInfo{}
+		for _, m := range fabioroute.MaintenanceRoutes() {
+			info := maintenanceInfo{Route: m.Route, Status: m.Status, Body: m.Body}
+			if m.RetryAfter > 0 {
+				info.RetryAfter = m.RetryAfter.String()
+			}
+			infos = append(infos, info)
+		}
+		writeJSON(w, r, infos)
+
+	case "PUT":
+		var info maintenanceInfo
+		if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		if info.Route == "" {
+			http.Error(w, "missing route", http.StatusBadRequest)
+			return
+		}
+		m := fabioroute.Maintenance{Route: info.Route, Status: info.Status, Body: info.Body}
+		if m.Status == 0 {
+			m.Status = http.StatusServiceUnavailable
+		}
+		if m.Status < 200 || m.Status > 599 {
+			http.Error(w, "invalid status", http.StatusBadRequest)
+			return
+		}
+		if info.RetryAfter != "" {
+			d, err := time.ParseDuration(info.RetryAfter)
+			if err != nil || d < 0 {
+				http.Error(w, "invalid retryafter", http.StatusBadRequest)
+				return
+			}
+			m.RetryAfter = d
+		}
+		fabioroute.SetMaintenance(m)
+		log.Printf("[INFO] Route %s is in maintenance mode", m.Route)
+
+	case "DELETE":
+		name := r.URL.Query().Get("route")
+		if name == "" {
+			http.Error(w, "missing route", http.StatusBadRequest)
+			return
+		}
+		if !fabioroute.ClearMaintenance(name) {
+			http.Error(w, "route not in maintenance mode", http.StatusNotFound)
+			return
+		}
+		log.Printf("[INFO] Route %s is no longer in maintenance mode", name)
+
+	default:
+		http.Error(w, "not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleMaintenance(t *testing.T) {
+	do := func(method, url, body string) (int, string) {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		HandleMaintenance(rec, req)
+		return rec.Code, strings.TrimSpace(rec.Body.String())
+	}
+
+	tests := []struct {
+		method, url, body string
+		code              int
+		resp              string
+	}{
+		{"PUT", "/api/maintenance", `{"route": "example.com/app"}`, 200, ""},
+		{"PUT", "/api/maintenance", `{"route": "/b", "status": 410, "body": "gone", "retryafter": "10m"}`, 200, ""},
+		{"PUT", "/api/maintenance", `{"status": 503}`, 400, "missing route"},
+		{"PUT", "/api/maintenance", `{"route": "/c", "status": 99}`, 400, "invalid status"},
+		{"PUT", "/api/maintenance", `{"route": "/c", "retryafter": "soon"}`, 400, "invalid retryafter"},
+		{"GET", "/api/maintenance", "", 200, `[{"route":"/b","status":410,"body":"gone","retryafter":"10m0s"},{"route":"example.com/app","status":503}]`},
+		{"DELETE", "/api/maintenance?route=/b", "", 200, ""},
+		{"DELETE", "/api/maintenance?route=/b", "", 404, "route not in maintenance mode"},
+		{"DELETE", "/api/maintenance", "", 400, "missing route"},
+		{"DELETE", "/api/maintenance?route=example.com/app", "", 200, ""},
+		{"GET", "/api/maintenance", "", 200, `[]`},
+		{"POST", "/api/maintenance", "", 405, "not allowed"},
+	}
+
+	for i, tt := range tests {
+		code, resp := do(tt.method, tt.url, tt.body)
+		if code != tt.code || resp != tt.resp {
+			t.Errorf("%d: %s %s: got %d %q want %d %q", i, tt.method, tt.url, code, resp, tt.code, tt.resp)
+		}
+	}
+}
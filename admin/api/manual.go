@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/eBay/fabio/registry"
+	fabioroute "github.com/eBay/fabio/route"
 )
 
 type manual struct {
@@ -51,3 +52,31 @@ func HandleManual(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "not allowed", http.StatusMethodNotAllowed)
 	}
 }
+
+type validation struct {
+	Valid  bool                    `json:"valid"`
+	Errors []*fabioroute.LineError `json:"errors"`
+}
+
+// HandleManualValidate checks the route commands of the posted manual
+// overrides without saving them and returns the errors with their line
+// numbers.
+func HandleManualValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var m manual
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	errs := fabioroute.Validate(m.Value)
+	if errs == nil {
+		errs = []*fabioroute.LineError{}
+	}
+	writeJSON(w, r, validation{Valid: len(errs) == 0, Errors: errs})
+}
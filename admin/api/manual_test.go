@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleManualValidate(t *testing.T) {
+	tests := []struct {
+		method string
+		body   string
+		code   int
+		valid  bool
+		lines  []int
+	}{
+		{"POST", `{"value":"route add a / http://a/\nroute del a"}`, 200, true, nil},
+		{"POST", `{"value":"route add a / http://a/ weight 2\nroute foo\nroute add a / http://a/ opts \"foo=1\""}`, 200, false, []int{1, 2, 3}},
+		{"POST", `{`, 400, false, nil},
+		{"GET", ``, 405, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			HandleManualValidate(rec, httptest.NewRequest(tt.method, "/api/manual/validate", strings.NewReader(tt.body)))
+			if got, want := rec.Code, tt.code; got != want {
+				t.Fatalf("got code %d want %d", got, want)
+			}
+			if tt.code != 200 {
+				return
+			}
+			var v validation
+			if err := json.NewDecoder(rec.Body).Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := v.Valid, tt.valid; got != want {
+				t.Fatalf("got valid %v want %v", got, want)
+			}
+			var lines []int
+			for _, e := range v.Errors {
+				lines = append(lines, e.Line)
+			}
+			if got, want := len(lines), len(tt.lines); got != want {
+				t.Fatalf("got errors %v want lines %v", lines, tt.lines)
+			}
+			for i := range lines {
+				if lines[i] != tt.lines[i] {
+					t.Fatalf("got errors %v want lines %v", lines, tt.lines)
+				}
+			}
+		})
+	}
+}
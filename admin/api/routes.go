@@ -3,24 +3,36 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"sort"
+	"strconv"
+	"strings"
 
 	fabioroute "github.com/eBay/fabio/route"
 )
 
 type route struct {
-	Service string   `json:"service"`
-	Host    string   `json:"host"`
-	Path    string   `json:"path"`
-	Dst     string   `json:"dst"`
-	Weight  float64  `json:"weight"`
-	Tags    []string `json:"tags,omitempty"`
-	Cmd     string   `json:"cmd"`
-	Rate1   float64  `json:"rate1"`
-	Pct99   float64  `json:"pct99"`
+	Service string            `json:"service"`
+	Host    string            `json:"host"`
+	Path    string            `json:"path"`
+	Dst     string            `json:"dst"`
+	Weight  float64           `json:"weight"`
+	Tags    []string          `json:"tags,omitempty"`
+	Meta    map[string]string `json:"meta,omitempty"`
+	Cmd     string            `json:"cmd"`
+	Rate1   float64           `json:"rate1"`
+	Pct99   float64           `json:"pct99"`
 }
 
 // HandleRoutes provides a fetch handler for the current routing table.
+//
+// The routes can be filtered with the 'host', 'path', 'service' and
+// 'target' parameters which must be contained in the respective field
+// and the 'tag' parameter which must be one of the tags. All words of
+// the 'q' parameter must be contained in the route command. The
+// 'page' and 'size' parameters return only the given page of the
+// filtered routes. The number of filtered routes is returned in the
+// X-Total-Count header.
 func HandleRoutes(w http.ResponseWriter, r *http.Request) {
 	t := fabioroute.GetTable()
 
@@ -30,6 +42,13 @@ func HandleRoutes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	f := newRouteFilter(r.URL.Query())
+	page, size, err := pagination(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	var hosts []string
 	for host := range t {
 		hosts = append(hosts, host)
@@ -47,13 +66,92 @@ func HandleRoutes(w http.ResponseWriter, r *http.Request) {
 					Dst:     tg.URL.String(),
 					Weight:  tg.Weight,
 					Tags:    tg.Tags,
+					Meta:    tg.Meta,
 					Cmd:     tr.TargetConfig(tg, true),
 					Rate1:   tg.Timer.Rate1(),
 					Pct99:   tg.Timer.Percentile(0.99),
 				}
+				if !f.match(ar) {
+					continue
+				}
 				routes = append(routes, ar)
 			}
 		}
 	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(routes)))
+	if size > 0 {
+		start, end := (page-1)*size, page*size
+		if start > len(routes) {
+			start = len(routes)
+		}
+		if end > len(routes) {
+			end = len(routes)
+		}
+		routes = routes[start:end]
+	}
 	writeJSON(w, r, routes)
 }
+
+// routeFilter selects the routes which match all of its
+// non-empty fields.
+type routeFilter struct {
+	host, path, service, tag, target string
+	words                            []string
+}
+
+func newRouteFilter(v url.Values) routeFilter {
+	return routeFilter{
+		host:    v.Get("host"),
+		path:    v.Get("path"),
+		service: v.Get("service"),
+		tag:     v.Get("tag"),
+		target:  v.Get("target"),
+		words:   strings.Fields(v.Get("q")),
+	}
+}
+
+func (f routeFilter) match(r route) bool {
+	if !strings.Contains(r.Host, f.host) ||
+		!strings.Contains(r.Path, f.path) ||
+		!strings.Contains(r.Service, f.service) ||
+		!strings.Contains(r.Dst, f.target) {
+		return false
+	}
+	if f.tag != "" && !hasTag(r.Tags, f.tag) {
+		return false
+	}
+	for _, w := range f.words {
+		if !strings.Contains(r.Cmd, w) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// pagination returns the values of the 'page' and 'size'
+// parameters. page defaults to 1 and a size of zero returns
+// all entries.
+func pagination(v url.Values) (page, size int, err error) {
+	page = 1
+	if s := v.Get("page"); s != "" {
+		if page, err = strconv.Atoi(s); err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("invalid page: %s", s)
+		}
+	}
+	if s := v.Get("size"); s != "" {
+		if size, err = strconv.Atoi(s); err != nil || size < 0 {
+			return 0, 0, fmt.Errorf("invalid size: %s", s)
+		}
+	}
+	return page, size, nil
+}
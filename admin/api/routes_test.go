@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	fabioroute "github.com/eBay/fabio/route"
+)
+
+func TestHandleRoutesFilter(t *testing.T) {
+	tbl, err := fabioroute.ParseString(`
+route add a abc.com/foo http://10.1.1.1:80/ tags "blue,canary"
+route add a abc.com/foo http://10.1.1.2:80/ tags "blue"
+route add b def.com/bar http://10.2.1.1:80/
+route add c /baz http://10.2.1.2:80/ opts "strip=/baz"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fabioroute.SetTable(fabioroute.GetTable())
+	fabioroute.SetTable(tbl)
+
+	tests := []struct {
+		desc  string
+		query string
+		code  int
+		total string
+		dst   []string
+	}{
+		{"all", "", 200, "4", []string{"http://10.2.1.2:80/", "http://10.1.1.1:80/", "http://10.1.1.2:80/", "http://10.2.1.1:80/"}},
+		{"host", "host=abc", 200, "2", []string{"http://10.1.1.1:80/", "http://10.1.1.2:80/"}},
+		{"path", "path=/ba", 200, "2", []string{"http://10.2.1.2:80/", "http://10.2.1.1:80/"}},
+		{"service", "service=b", 200, "1", []string{"http://10.2.1.1:80/"}},
+		{"tag", "tag=canary", 200, "1", []string{"http://10.1.1.1:80/"}},
+		{"partial tag", "tag=can", 200, "0", nil},
+		{"target", "target=10.2.", 200, "2", []string{"http://10.2.1.2:80/", "http://10.2.1.1:80/"}},
+		{"words", "q=strip+baz", 200, "1", []string{"http://10.2.1.2:80/"}},
+		{"combined", "service=a&target=.2:", 200, "1", []string{"http://10.1.1.2:80/"}},
+		{"first page", "size=3", 200, "4", []string{"http://10.2.1.2:80/", "http://10.1.1.1:80/", "http://10.1.1.2:80/"}},
+		{"second page", "size=3&page=2", 200, "4", []string{"http://10.2.1.1:80/"}},
+		{"page out of range", "size=3&page=3", 200, "4", []string{}},
+		{"invalid page", "page=0", 400, "", nil},
+		{"invalid size", "size=x", 400, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			HandleRoutes(rec, httptest.NewRequest("GET", "/api/routes?"+tt.query, nil))
+			if got, want := rec.Code, tt.code; got != want {
+				t.Fatalf("got code %d want %d", got, want)
+			}
+			if tt.code != 200 {
+				return
+			}
+			if got, want := rec.Header().Get("X-Total-Count"), tt.total; got != want {
+				t.Fatalf("got total %s want %s", got, want)
+			}
+			var routes []route
+			if err := json.Unmarshal(rec.Body.Bytes(), &routes); err != nil {
+				t.Fatal(err)
+			}
+			var dst []string
+			for _, r := range routes {
+				dst = append(dst, r.Dst)
+			}
+			if len(dst) == 0 && len(tt.dst) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(dst, tt.dst) {
+				t.Fatalf("got %v want %v", dst, tt.dst)
+			}
+		})
+	}
+}
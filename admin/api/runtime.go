@@ -0,0 +1,12 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/eBay/fabio/diag"
+)
+
+// HandleRuntime returns the current statistics of the Go runtime.
+func HandleRuntime(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, diag.ReadRuntime())
+}
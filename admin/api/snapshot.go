@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eBay/fabio/registry"
+	fabioroute "github.com/eBay/fabio/route"
+)
+
+// snapshot is an export of the complete routing table.
+type snapshot struct {
+	Time   time.Time       `json:"time"`
+	Routes []snapshotRoute `json:"routes"`
+}
+
+type snapshotRoute struct {
+	Service string            `json:"service"`
+	Host    string            `json:"host"`
+	Path    string            `json:"path"`
+	Dst     string            `json:"dst"`
+	Weight  float64           `json:"weight,omitempty"`
+	Tags    []string          `json:"tags,omitempty"`
+	Opts    map[string]string `json:"opts,omitempty"`
+	Cmd     string            `json:"cmd"`
+}
+
+// HandleSnapshot exports the current routing table on GET and
+// restores an exported table on PUT. A snapshot is restored by
+// writing manual overrides which delete the routes of all services
+// in the current table and the snapshot and then add the routes
+// from the snapshot. The overrides replace the existing ones in a
+// single update. Services which are registered later are added
+// to the routing table as usual.
+func HandleSnapshot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		writeJSON(w, r, newSnapshot(fabioroute.GetTable(), time.Now()))
+
+	case "PUT":
+		var s snapshot
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			log.Print("[ERROR] ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		value := restoreConfig(s, fabioroute.GetTable())
+		if _, err := fabioroute.ParseString(value); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		_, version, err := registry.Default.ReadManual()
+		if err != nil {
+			log.Print("[ERROR] ", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ok, err := registry.Default.WriteManual(value, version)
+		if err != nil {
+			log.Print("[ERROR] ", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "version mismatch", http.StatusConflict)
+			return
+		}
+		log.Printf("[INFO] Restored %d routes from snapshot of %s", len(s.Routes), s.Time.Format(time.RFC3339))
+
+	default:
+		http.Error(w, "not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func newSnapshot(t fabioroute.Table, now time.Time) snapshot {
+	var hosts []string
+	for host := range t {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	s := snapshot{Time: now, Routes: []snapshotRoute{}}
+	for _, host := range hosts {
+		for _, tr := range t[host] {
+			for _, tg := range tr.Targets {
+				s.Routes = append(s.Routes, snapshotRoute{
+					Service: tg.Service,
+					Host:    tr.Host,
+					Path:    tr.Path,
+					Dst:     tg.URL.String(),
+					Weight:  tg.FixedWeight,
+					Tags:    tg.Tags,
+					Opts:    tg.Opts,
+					Cmd:     tr.TargetConfig(tg, false),
+				})
+			}
+		}
+	}
+	return s
+}
+
+// restoreConfig returns the manual overrides which replace the
+// routes of the current table with the routes of the snapshot.
+// The deployments of the snapshot routes are activated since
+// their routes would be dropped otherwise.
+func restoreConfig(s snapshot, t fabioroute.Table) string {
+	services := map[string]bool{}
+	for _, routes := range t {
+		for _, r := range routes {
+			for _, tg := range r.Targets {
+				services[tg.Service] = true
+			}
+		}
+	}
+	deploys := map[string]bool{}
+	for _, r := range s.Routes {
+		services[r.Service] = true
+		if id, ok := r.Opts["deploy"]; ok {
+			deploys[id] = true
+		}
+	}
+
+	var del, deploy []string
+	for svc := range services {
+		del = append(del, "route del "+svc)
+	}
+	for id := range deploys {
+		deploy = append(deploy, "route deploy "+id)
+	}
+	sort.Strings(del)
+	sort.Strings(deploy)
+
+	cmds := append(del, deploy...)
+	for _, r := range s.Routes {
+		cmds = append(cmds, r.Cmd)
+	}
+	return strings.Join(cmds, "\n")
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	fabioroute "github.com/eBay/fabio/route"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	snap, err := fabioroute.ParseString(`
+route add a /a http://1:111/ weight 0.5 tags "x,y"
+route add b example.com/b http://2:222/ opts "deploy=v1 strip=/b"
+route deploy v1
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := fabioroute.ParseString(`
+route add a /a http://3:333/
+route add c /c http://4:444/
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	s := newSnapshot(snap, now)
+	if got, want := len(s.Routes), 2; got != want {
+		t.Fatalf("got %d routes want %d", got, want)
+	}
+
+	// restoring on top of the current table yields the snapshot
+	value := restoreConfig(s, cur)
+	want := "route del a\nroute del b\nroute del c\nroute deploy v1\n" +
+		"route add a /a http://1:111/ weight 0.50 tags \"x,y\"\n" +
+		"route add b example.com/b http://2:222/ opts \"deploy=v1 strip=/b\""
+	if value != want {
+		t.Fatalf("got %q want %q", value, want)
+	}
+
+	restored, err := fabioroute.ParseString(cur.String() + "\n" + value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := restored.String(), snap.String(); got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eBay/fabio/stats"
+)
+
+type topStats struct {
+	Window  string        `json:"window"`
+	Order   string        `json:"order"`
+	Routes  []stats.Entry `json:"routes"`
+	Targets []stats.Entry `json:"targets"`
+}
+
+// HandleStatsTop returns the busiest routes and targets over the last
+// few minutes. The 'n' parameter limits the number of entries (default
+// 10) and the 'order' parameter sorts them by 'rps', 'errors' or 'bytes'.
+func HandleStatsTop(w http.ResponseWriter, r *http.Request) {
+	n := 10
+	if s := r.URL.Query().Get("n"); s != "" {
+		var err error
+		if n, err = strconv.Atoi(s); err != nil || n < 0 {
+			http.Error(w, "invalid value for n: "+s, http.StatusBadRequest)
+			return
+		}
+	}
+
+	order := r.URL.Query().Get("order")
+	switch order {
+	case "":
+		order = "rps"
+	case "rps", "errors", "bytes":
+	default:
+		http.Error(w, "invalid order: "+order, http.StatusBadRequest)
+		return
+	}
+
+	routes, targets := stats.Default.Top(n, order)
+	writeJSON(w, r, &topStats{
+		Window:  stats.Window.String(),
+		Order:   order,
+		Routes:  routes,
+		Targets: targets,
+	})
+}
+
+type liveStats struct {
+	Time     time.Time     `json:"time"`
+	Interval string        `json:"interval"`
+	Routes   []stats.Entry `json:"routes"`
+}
+
+// HandleStatsLive streams the request rate, error rate and average
+// latency of the routes over the last few seconds as server-sent
+// events until the client disconnects.
+func HandleStatsLive(w http.ResponseWriter, r *http.Request) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	send := func() bool {
+		b, err := json.Marshal(&liveStats{
+			Time:     time.Now(),
+			Interval: stats.Resolution.String(),
+			Routes:   stats.Default.Live(),
+		})
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+			return false
+		}
+		f.Flush()
+		return true
+	}
+
+	t := time.NewTicker(stats.Resolution)
+	defer t.Stop()
+	for send() {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleStatsLive(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(HandleStatsLive))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got, want := resp.Header.Get("Content-Type"), "text/event-stream"; got != want {
+		t.Fatalf("got content type %q want %q", got, want)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(line, "data: ") {
+		t.Fatalf("got %q want server-sent event", line)
+	}
+	var s liveStats
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Interval != "10s" || s.Routes == nil {
+		t.Fatalf("got %+v", s)
+	}
+}
@@ -2,11 +2,14 @@ package admin
 
 import (
 	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/eBay/fabio/admin/api"
 	"github.com/eBay/fabio/admin/ui"
 	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/registry"
 )
 
 // ListenAndServe starts the admin api and ui server.
@@ -14,19 +17,57 @@ func ListenAndServe(cfg *config.Config, version string) error {
 	ui.Version = version
 	ui.Color = cfg.UI.Color
 	ui.Title = cfg.UI.Title
+	ui.Lang = cfg.UI.Lang
+	if !ui.IsSupportedLang(ui.Lang) {
+		log.Printf("[WARN] Unsupported UI language %q. Using 'en'", ui.Lang)
+		ui.Lang = "en"
+	}
+	loc, err := time.LoadLocation(cfg.UI.Timezone)
+	if err != nil {
+		return err
+	}
+	ui.Location = loc
 	api.Cfg = cfg
 	api.Version = version
+	http.HandleFunc("/api/certs", api.HandleCerts)
+	http.HandleFunc("/api/check", api.HandleCheck)
 	http.HandleFunc("/api/config", api.HandleConfig)
+	http.HandleFunc("/api/debug/leaks", api.HandleLeaks)
+	http.HandleFunc("/api/listeners", api.HandleListeners)
+	http.HandleFunc("/api/maintenance", api.HandleMaintenance)
 	http.HandleFunc("/api/manual", api.HandleManual)
+	http.HandleFunc("/api/manual/validate", api.HandleManualValidate)
 	http.HandleFunc("/api/routes", api.HandleRoutes)
+	http.HandleFunc("/api/routes/history", api.HandleHistory)
+	http.HandleFunc("/api/routes/lookup", api.HandleLookup)
+	http.HandleFunc("/api/routes/checksum", api.HandleChecksum)
+	http.HandleFunc("/api/routes/snapshot", api.HandleSnapshot)
+	http.HandleFunc("/api/runtime", api.HandleRuntime)
+	http.HandleFunc("/api/stats/live", api.HandleStatsLive)
+	http.HandleFunc("/api/stats/top", api.HandleStatsTop)
 	http.HandleFunc("/api/version", api.HandleVersion)
+	http.HandleFunc("/certs", ui.HandleCerts)
+	http.HandleFunc("/history", ui.HandleHistory)
 	http.HandleFunc("/manual", ui.HandleManual)
 	http.HandleFunc("/routes", ui.HandleRoutes)
+	http.HandleFunc("/runtime", ui.HandleRuntime)
+	http.HandleFunc("/traffic", ui.HandleTraffic)
 	http.HandleFunc("/health", handleHealth)
 	http.Handle("/", http.RedirectHandler("/routes", http.StatusSeeOther))
 	return http.ListenAndServe(cfg.UI.Addr, nil)
 }
 
+// handleHealth responds with 503 Service Unavailable and the errors
+// if a registry backend does not deliver updates and the routes may
+// be stale.
 func handleHealth(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintln(w, "OK")
+	h := registry.BackendHealth()
+	if h.Healthy {
+		fmt.Fprintln(w, "OK")
+		return
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+	for _, e := range h.Errors {
+		fmt.Fprintf(w, "%s failed %d times since %s. %s\n", e.Name, e.Failures, e.Since.Format(time.RFC3339), e.Error)
+	}
 }
@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// HandleCerts provides the UI for the loaded certificates.
+func HandleCerts(w http.ResponseWriter, r *http.Request) {
+	tmplCerts.ExecuteTemplate(w, "certs", newPage(r))
+}
+
+var tmplCerts = template.Must(template.New("certs").Parse(`
+<!doctype html>
+<html lang="{{.Lang}}">
+<head>
+	<meta charset="utf-8">
+	<title>./fabio{{if .Title}} - {{.Title}}{{end}}</title>
+	<script type="text/javascript" src="https://code.jquery.com/jquery-2.1.1.min.js"></script>
+	<link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/materialize/0.97.3/css/materialize.min.css">
+	<script src="https://cdnjs.cloudflare.com/ajax/libs/materialize/0.97.3/js/materialize.min.js"></script>
+	<meta name="viewport" content="width=device-width, initial-scale=1.0"/>
+</head>
+<body>
+
+<nav class="top-nav {{.Color}}">
+
+	<div class="container">
+		<div class="nav-wrapper">
+			<a href="/" class="brand-logo">./fabio{{if .Title}} - {{.Title}}{{end}}</a>
+			<ul id="nav-mobile" class="right hide-on-med-and-down">
+				<li><a href="/routes">{{.T "nav.routes"}}</a></li>
+				<li><a href="/manual">{{.T "nav.overrides"}}</a></li>
+				<li><a href="/runtime">{{.T "nav.runtime"}}</a></li>
+				<li><a href="/traffic">{{.T "nav.traffic"}}</a></li>
+				<li><a href="/history">{{.T "nav.history"}}</a></li>
+				<li><a href="https://github.com/eBay/fabio/blob/master/CHANGELOG.md">{{.Version}}</a></li>
+				<li><a href="https://github.com/eBay/fabio">{{.T "nav.github"}}</a></li>
+			</ul>
+		</div>
+	</div>
+
+</nav>
+
+<div class="container">
+
+	<div class="section">
+		<h5>{{.T "certs.title"}}</h5>
+		<p class="grey-text">{{.T "routes.updated"}}: <span class="updated">-</span></p>
+		<table class="certs highlight">
+			<thead>
+				<tr>
+					<th>{{.T "certs.source"}}</th>
+					<th>{{.T "certs.subject"}}</th>
+					<th>{{.T "certs.sans"}}</th>
+					<th>{{.T "certs.issuer"}}</th>
+					<th>{{.T "certs.expires"}}</th>
+					<th>{{.T "certs.refreshed"}}</th>
+				</tr>
+			</thead>
+			<tbody></tbody>
+		</table>
+	</div>
+
+</div>
+
+<script>
+$(function(){
+	function esc(s) { return $('<div/>').text(s).html(); }
+
+	function renderCerts(certs) {
+		var tbl = '', updated = null;
+		for (var i=0; i < certs.length; i++) {
+			var c = certs[i];
+			var refreshed = new Date(c.refreshed);
+			if (updated == null || refreshed > updated) {
+				updated = refreshed;
+			}
+			var days = Math.floor((new Date(c.not_after) - new Date()) / 86400000);
+			var expires = new Date(c.not_after).toLocaleString() + ' (' + days + 'd)';
+			tbl += '<tr' + (days < 14 ? ' class="red-text"' : '') + '>';
+			tbl += '<td>' + esc(c.source) + '</td>';
+			tbl += '<td>' + esc(c.subject) + '</td>';
+			tbl += '<td>' + esc((c.sans || []).join(', ')) + '</td>';
+			tbl += '<td>' + esc(c.issuer) + '</td>';
+			tbl += '<td>' + esc(expires) + '</td>';
+			tbl += '<td>' + esc(refreshed.toLocaleString()) + '</td>';
+			tbl += '</tr>';
+		}
+		$("table.certs tbody").html(tbl);
+		// the time of the most recent certificate refresh
+		$("span.updated").text(updated == null ? '-' : updated.toLocaleString());
+	}
+
+	function update() {
+		$.get("/api/certs", renderCerts);
+	}
+	update();
+	setInterval(update, 30000);
+})
+</script>
+
+</body>
+</html>
+`))
@@ -0,0 +1,104 @@
+package ui
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// HandleHistory provides the UI for the changes of the routing table.
+func HandleHistory(w http.ResponseWriter, r *http.Request) {
+	tmplHistory.ExecuteTemplate(w, "history", newPage(r))
+}
+
+var tmplHistory = template.Must(template.New("history").Parse(`
+<!doctype html>
+<html lang="{{.Lang}}">
+<head>
+	<meta charset="utf-8">
+	<title>./fabio{{if .Title}} - {{.Title}}{{end}}</title>
+	<script type="text/javascript" src="https://code.jquery.com/jquery-2.1.1.min.js"></script>
+	<link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/materialize/0.97.3/css/materialize.min.css">
+	<script src="https://cdnjs.cloudflare.com/ajax/libs/materialize/0.97.3/js/materialize.min.js"></script>
+	<meta name="viewport" content="width=device-width, initial-scale=1.0"/>
+	<style>
+		td.changes { font-family: monospace; white-space: pre; }
+	</style>
+</head>
+<body>
+
+<nav class="top-nav {{.Color}}">
+
+	<div class="container">
+		<div class="nav-wrapper">
+			<a href="/" class="brand-logo">./fabio{{if .Title}} - {{.Title}}{{end}}</a>
+			<ul id="nav-mobile" class="right hide-on-med-and-down">
+				<li><a href="/routes">{{.T "nav.routes"}}</a></li>
+				<li><a href="/manual">{{.T "nav.overrides"}}</a></li>
+				<li><a href="/runtime">{{.T "nav.runtime"}}</a></li>
+				<li><a href="/certs">{{.T "nav.certs"}}</a></li>
+				<li><a href="/traffic">{{.T "nav.traffic"}}</a></li>
+				<li><a href="https://github.com/eBay/fabio/blob/master/CHANGELOG.md">{{.Version}}</a></li>
+				<li><a href="https://github.com/eBay/fabio">{{.T "nav.github"}}</a></li>
+			</ul>
+		</div>
+	</div>
+
+</nav>
+
+<div class="container">
+
+	<div class="section">
+		<h5>{{.T "history.title"}}</h5>
+		<table class="history highlight">
+			<thead>
+				<tr>
+					<th>{{.T "history.time"}}</th>
+					<th>{{.T "history.source"}}</th>
+					<th>{{.T "history.changes"}}</th>
+				</tr>
+			</thead>
+			<tbody></tbody>
+		</table>
+	</div>
+
+</div>
+
+<script>
+$(function(){
+	function esc(s) { return $('<div/>').text(s).html(); }
+
+	function lines(prefix, cls, l) {
+		var s = '';
+		for (var i=0; l && i < l.length; i++) {
+			s += '<span class="' + cls + '">' + prefix + esc(l[i]) + '</span>\n';
+		}
+		return s;
+	}
+
+	function renderHistory(changes) {
+		var tbl = '';
+		for (var i=0; i < changes.length; i++) {
+			var c = changes[i];
+			tbl += '<tr>';
+			tbl += '<td>' + new Date(c.time).toLocaleString() + '</td>';
+			tbl += '<td>' + esc(c.source || '') + '</td>';
+			tbl += '<td class="changes">' + lines('- ', 'red-text', c.removed) + lines('+ ', 'green-text', c.added) + '</td>';
+			tbl += '</tr>';
+		}
+		if (changes.length == 0) {
+			tbl = '<tr><td colspan="3" class="grey-text">' + {{.T "history.empty"}} + '</td></tr>';
+		}
+		$("table.history tbody").html(tbl);
+	}
+
+	function update() {
+		$.get("/api/routes/history", renderHistory);
+	}
+	update();
+	setInterval(update, 10000);
+})
+</script>
+
+</body>
+</html>
+`))
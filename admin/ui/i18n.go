@@ -0,0 +1,163 @@
+package ui
+
+import (
+	"net/http"
+	"strings"
+)
+
+// messages contains the message catalogs by language.
+// All catalogs must contain the same keys as the "en" catalog.
+var messages = map[string]map[string]string{
+	"en": {
+		"certs.expires":        "Expires",
+		"certs.issuer":         "Issuer",
+		"certs.refreshed":      "Refreshed",
+		"certs.sans":           "SANs",
+		"certs.source":         "Source",
+		"certs.subject":        "Subject",
+		"certs.title":          "Certificates",
+		"nav.certs":            "Certificates",
+		"nav.github":           "Github",
+		"nav.history":          "History",
+		"nav.overrides":        "Overrides",
+		"nav.routes":           "Routes",
+		"nav.runtime":          "Runtime",
+		"nav.traffic":          "Traffic",
+		"history.changes":      "Changes",
+		"history.empty":        "No changes recorded",
+		"history.source":       "Source",
+		"history.time":         "Time",
+		"history.title":        "Routing Table Changes",
+		"manual.help":          "Help",
+		"manual.invalid":       "Invalid route commands",
+		"manual.line":          "Line",
+		"manual.save":          "Save",
+		"manual.title":         "Manual Overrides",
+		"manual.version":       "Version",
+		"routes.check":         "Check",
+		"routes.dest":          "Dest",
+		"routes.filter":        "type to filter routes, e.g. service:foo tag:canary target:10.1.",
+		"routes.next":          "Next",
+		"routes.prev":          "Previous",
+		"routes.saveview":      "Save view",
+		"routes.viewname":      "Name of the view",
+		"routes.host":          "Host",
+		"routes.meta":          "Meta",
+		"routes.path":          "Path",
+		"routes.service":       "Service",
+		"routes.title":         "Routing Table",
+		"routes.updated":       "Last updated",
+		"routes.weight":        "Weight",
+		"runtime.fds":          "Open file descriptors",
+		"runtime.gccount":      "GC cycles",
+		"runtime.gclastpause":  "Last GC pause",
+		"runtime.gctotalpause": "Total GC pause",
+		"runtime.goroutines":   "Goroutines",
+		"runtime.heapalloc":    "Heap allocated",
+		"runtime.heapobjects":  "Heap objects",
+		"runtime.heapsys":      "Heap from OS",
+		"runtime.title":        "Runtime",
+		"traffic.bytes":        "Throughput",
+		"traffic.empty":        "No requests in the last interval",
+		"traffic.errors":       "Error rate",
+		"traffic.latency":      "Latency",
+		"traffic.route":        "Route",
+		"traffic.rps":          "Requests/s",
+		"traffic.title":        "Live Traffic",
+	},
+	"zh": {
+		"certs.expires":        "过期时间",
+		"certs.issuer":         "签发者",
+		"certs.refreshed":      "刷新时间",
+		"certs.sans":           "备用名称",
+		"certs.source":         "来源",
+		"certs.subject":        "主题",
+		"certs.title":          "证书",
+		"nav.certs":            "证书",
+		"nav.github":           "Github",
+		"nav.history":          "变更历史",
+		"nav.overrides":        "手动覆盖",
+		"nav.routes":           "路由",
+		"nav.runtime":          "运行时",
+		"nav.traffic":          "流量",
+		"history.changes":      "变更",
+		"history.empty":        "没有记录的变更",
+		"history.source":       "来源",
+		"history.time":         "时间",
+		"history.title":        "路由表变更历史",
+		"manual.help":          "帮助",
+		"manual.invalid":       "无效的路由命令",
+		"manual.line":          "行",
+		"manual.save":          "保存",
+		"manual.title":         "手动覆盖路由",
+		"manual.version":       "版本",
+		"routes.check":         "检查",
+		"routes.dest":          "目标地址",
+		"routes.filter":        "输入关键字过滤路由，例如 service:foo tag:canary target:10.1.",
+		"routes.next":          "下一页",
+		"routes.prev":          "上一页",
+		"routes.saveview":      "保存视图",
+		"routes.viewname":      "视图名称",
+		"routes.host":          "主机",
+		"routes.meta":          "元数据",
+		"routes.path":          "路径",
+		"routes.service":       "服务",
+		"routes.title":         "路由表",
+		"routes.updated":       "更新时间",
+		"routes.weight":        "权重",
+		"runtime.fds":          "打开的文件描述符",
+		"runtime.gccount":      "GC 次数",
+		"runtime.gclastpause":  "最近一次 GC 暂停",
+		"runtime.gctotalpause": "GC 暂停总时长",
+		"runtime.goroutines":   "Goroutine 数量",
+		"runtime.heapalloc":    "已分配堆内存",
+		"runtime.heapobjects":  "堆对象数量",
+		"runtime.heapsys":      "从系统获取的堆内存",
+		"runtime.title":        "运行时",
+		"traffic.bytes":        "吞吐量",
+		"traffic.empty":        "最近一个周期内没有请求",
+		"traffic.errors":       "错误率",
+		"traffic.latency":      "延迟",
+		"traffic.route":        "路由",
+		"traffic.rps":          "请求/秒",
+		"traffic.title":        "实时流量",
+	},
+}
+
+// IsSupportedLang returns true if there is a message catalog for lang.
+func IsSupportedLang(lang string) bool {
+	_, ok := messages[lang]
+	return ok
+}
+
+// translate returns the message for the key in the given language.
+// It falls back to the english message and then to the key itself.
+func translate(lang, key string) string {
+	if s, ok := messages[lang][key]; ok {
+		return s
+	}
+	if s, ok := messages["en"][key]; ok {
+		return s
+	}
+	return key
+}
+
+// requestLang determines the language for the request from the
+// 'lang' query parameter, the Accept-Language header and the
+// default language in that order.
+func requestLang(r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); IsSupportedLang(lang) {
+		return lang
+	}
+	for _, s := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag := strings.TrimSpace(strings.SplitN(s, ";", 2)[0])
+		lang := strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+		if IsSupportedLang(lang) {
+			return lang
+		}
+	}
+	if IsSupportedLang(Lang) {
+		return Lang
+	}
+	return "en"
+}
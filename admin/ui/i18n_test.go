@@ -0,0 +1,57 @@
+package ui
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestMessageCatalogs(t *testing.T) {
+	for lang, m := range messages {
+		for key := range messages["en"] {
+			if _, ok := m[key]; !ok {
+				t.Errorf("%s: missing message %q", lang, key)
+			}
+		}
+		for key := range m {
+			if _, ok := messages["en"][key]; !ok {
+				t.Errorf("%s: unknown message %q", lang, key)
+			}
+		}
+	}
+}
+
+func TestRequestLang(t *testing.T) {
+	defer func(lang string) { Lang = lang }(Lang)
+	Lang = "en"
+
+	tests := []struct {
+		query, accept, lang string
+	}{
+		{"", "", "en"},
+		{"lang=zh", "", "zh"},
+		{"lang=xx", "zh-CN,zh;q=0.9", "zh"},
+		{"", "fr-FR, zh;q=0.5", "zh"},
+		{"lang=en", "zh-CN", "en"},
+		{"", "fr", "en"},
+	}
+
+	for i, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}, Header: http.Header{"Accept-Language": {tt.accept}}}
+		if got, want := requestLang(r), tt.lang; got != want {
+			t.Errorf("%d: got %q want %q", i, got, want)
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	if got, want := translate("zh", "routes.title"), "路由表"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	if got, want := translate("xx", "routes.title"), "Routing Table"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	if got, want := translate("en", "foo"), "foo"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
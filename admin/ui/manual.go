@@ -7,17 +7,12 @@ import (
 
 // HandleManual provides the UI for the manual overrides.
 func HandleManual(w http.ResponseWriter, r *http.Request) {
-	data := struct {
-		Color   string
-		Title   string
-		Version string
-	}{Color, Title, Version}
-	tmplManual.ExecuteTemplate(w, "manual", data)
+	tmplManual.ExecuteTemplate(w, "manual", newPage(r))
 }
 
 var tmplManual = template.Must(template.New("manual").Parse(`
 <!doctype html>
-<html lang="en">
+<html lang="{{.Lang}}">
 <head>
 	<meta charset="utf-8">
 	<title>./fabio{{if .Title}} - {{.Title}}{{end}}</title>
@@ -37,9 +32,13 @@ var tmplManual = template.Must(template.New("manual").Parse(`
 		<div class="nav-wrapper">
 			<a href="/" class="brand-logo">./fabio{{if .Title}} - {{.Title}}{{end}}</a>
 			<ul id="nav-mobile" class="right hide-on-med-and-down">
-				<li><a href="/routes">Routes</a></li>
+				<li><a href="/routes">{{.T "nav.routes"}}</a></li>
+				<li><a href="/runtime">{{.T "nav.runtime"}}</a></li>
+				<li><a href="/certs">{{.T "nav.certs"}}</a></li>
+				<li><a href="/traffic">{{.T "nav.traffic"}}</a></li>
+				<li><a href="/history">{{.T "nav.history"}}</a></li>
 				<li><a href="https://github.com/eBay/fabio/blob/master/CHANGELOG.md">{{.Version}}</a></li>
-				<li><a href="https://github.com/eBay/fabio">Github</a></li>
+				<li><a href="https://github.com/eBay/fabio">{{.T "nav.github"}}</a></li>
 			</ul>
 		</div>
 	</div>
@@ -49,7 +48,7 @@ var tmplManual = template.Must(template.New("manual").Parse(`
 <div class="container">
 
 	<div class="section">
-		<h5>Manual Overrides</h5>
+		<h5>{{.T "manual.title"}}</h5>
 
 		<div class="row">
 			<form class="col s12">
@@ -61,8 +60,15 @@ var tmplManual = template.Must(template.New("manual").Parse(`
 					</div>
 				</div>
 			</form>
-			<button class="btn waves-effect waves-light" name="save">Save</button>
-			<button class="btn waves-effect waves-light" name="help">Help</button>
+			<button class="btn waves-effect waves-light" name="save">{{.T "manual.save"}}</button>
+			<button class="btn waves-effect waves-light" name="help">{{.T "manual.help"}}</button>
+		</div>
+
+		<div class="row errors hide">
+			<div class="col s12">
+				<h6 class="red-text">{{.T "manual.invalid"}}</h6>
+				<ul class="collection"></ul>
+			</div>
 		</div>
 
 		<div class="row">
@@ -106,6 +112,11 @@ route weight service host/path weight w tags "tag1,tag2"
     sum(w) &gt;= 1: only matching services will receive traffic
 
     Note that the total sum of traffic sent to all matching routes is w%.
+
+route weight &lt;svc&gt; &lt;src&gt; weight &lt;w&gt; ramp &lt;to&gt;:&lt;d&gt; tags "&lt;t1&gt;,&lt;t2&gt;,..."
+  - Change the weight linearly from w to 'to' over the duration d,
+    e.g. weight 0.05 ramp 1.0:30m. The ramp can follow all route weight
+    commands and starts over when the command is changed.
 			</pre>
 		</div>
 	</div>
@@ -118,7 +129,7 @@ $(function(){
 
 	$.get("/api/manual", function(data) {
 		$("input[name=version]").val(data.version);
-		$("textarea>label").val("Version " + data.version);
+		$("textarea>label").val({{.T "manual.version"}} + " " + data.version);
 		$("#textarea1").val(data.value);
 		$("#textarea1").trigger('autoresize');
 	});
@@ -127,11 +138,33 @@ $(function(){
 		$("pre.help").toggleClass("hide");
 	});
 
+	function showErrors(errors) {
+		var $ul = $("div.errors ul").empty();
+		$.each(errors, function(i, e) {
+			$("<li class='collection-item'>").text({{.T "manual.line"}} + " " + e.line + ": " + e.message).appendTo($ul);
+		});
+		$("div.errors").toggleClass("hide", errors.length == 0);
+	}
+
 	$("button[name=save]").click(function() {
 		var data = {
 			value   : $("#textarea1").val(),
 			version : $("input[name=version]").val()
 		}
+		$.ajax('/api/manual/validate', {
+			type: 'POST',
+			data: JSON.stringify(data),
+			contentType: 'application/json',
+			success: function(v) {
+				showErrors(v.errors);
+				if (v.valid) {
+					save(data);
+				}
+			}
+		});
+	});
+
+	function save(data) {
 		$.ajax('/api/manual', {
 			type: 'PUT',
 			data: JSON.stringify(data),
@@ -145,7 +178,7 @@ $(function(){
 				window.location.reload();
 			}
 		});
-	});
+	}
 })
 </script>
 
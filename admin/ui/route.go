@@ -3,21 +3,35 @@ package ui
 import (
 	"html/template"
 	"net/http"
+
+	"github.com/eBay/fabio/route"
 )
 
 // HandleRoutes provides the UI for managing the routing table.
 func HandleRoutes(w http.ResponseWriter, r *http.Request) {
-	data := struct {
-		Color   string
-		Title   string
-		Version string
-	}{Color, Title, Version}
-	tmplRoutes.ExecuteTemplate(w, "routes", data)
+	tmplRoutes.ExecuteTemplate(w, "routes", routesPage{page: newPage(r), Updated: lastChange()})
+}
+
+// routesPage contains the data of the routes page. Updated is
+// the time of the last change of the routing table.
+type routesPage struct {
+	page
+	Updated string
+}
+
+// lastChange returns the time of the most recent routing
+// table change or "-" if the table has not changed.
+func lastChange() string {
+	h := route.History()
+	if len(h) == 0 {
+		return "-"
+	}
+	return h[0].Time.In(Location).Format(timeFormat)
 }
 
 var tmplRoutes = template.Must(template.New("routes").Parse(`
 <!doctype html>
-<html lang="en">
+<html lang="{{.Lang}}">
 <head>
 	<meta charset="utf-8">
 	<title>./fabio{{if .Title}} - {{.Title}}{{end}}</title>
@@ -27,7 +41,7 @@ var tmplRoutes = template.Must(template.New("routes").Parse(`
 	<meta name="viewport" content="width=device-width, initial-scale=1.0"/>
 
 	<style type="text/css">
-		td.tags { display: none; }
+		th.tags, td.tags { display: none; }
 
 		/*
 		 * -- DESKTOP (AND UP) MEDIA QUERIES --
@@ -35,7 +49,7 @@ var tmplRoutes = template.Must(template.New("routes").Parse(`
 		 * of the mobile and tablet styles.
 		 */
 		@media (min-width: 78em) {
-			td.tags{ display: table-cell; }
+			th.tags, td.tags { display: table-cell; }
 		}
 	</style>
 </head>
@@ -47,9 +61,13 @@ var tmplRoutes = template.Must(template.New("routes").Parse(`
 		<div class="nav-wrapper">
 			<a href="/" class="brand-logo">./fabio{{if .Title}} - {{.Title}}{{end}}</a>
 			<ul id="nav-mobile" class="right hide-on-med-and-down">
-				<li><a href="/manual">Overrides</a></li>
+				<li><a href="/manual">{{.T "nav.overrides"}}</a></li>
+				<li><a href="/runtime">{{.T "nav.runtime"}}</a></li>
+				<li><a href="/certs">{{.T "nav.certs"}}</a></li>
+				<li><a href="/traffic">{{.T "nav.traffic"}}</a></li>
+				<li><a href="/history">{{.T "nav.history"}}</a></li>
 				<li><a href="https://github.com/eBay/fabio/blob/master/CHANGELOG.md">{{.Version}}</a></li>
-				<li><a href="https://github.com/eBay/fabio">Github</a></li>
+				<li><a href="https://github.com/eBay/fabio">{{.T "nav.github"}}</a></li>
 			</ul>
 		</div>
 	</div>
@@ -59,9 +77,18 @@ var tmplRoutes = template.Must(template.New("routes").Parse(`
 <div class="container">
 
 	<div class="section">
-		<h5>Routing Table</h5>
-		<p><input type="text" id="filter" placeholder="type to filter routes"></p>
+		<h5>{{.T "routes.title"}}</h5>
+		<p class="grey-text">{{.T "routes.updated"}}: {{.Updated}}</p>
+		<p><input type="text" id="filter" placeholder="{{.T "routes.filter"}}"></p>
+		<p id="views">
+			<a href="#" id="save-view">{{.T "routes.saveview"}}</a>
+		</p>
 		<table class="routes highlight"></table>
+		<p>
+			<a href="#" id="prev">{{.T "routes.prev"}}</a>
+			<span id="pageinfo" class="grey-text"></span>
+			<a href="#" id="next">{{.T "routes.next"}}</a>
+		</p>
 	</div>
 
 </div>
@@ -70,61 +97,146 @@ var tmplRoutes = template.Must(template.New("routes").Parse(`
 $(function(){
 	var params={};window.location.search.replace(/[?&]+([^=&]+)=([^&]*)/gi,function(str,key,value){params[key] = value;});
 
+	var pageSize = 100;
+	var page = parseInt(params.page) || 1;
+
 	function renderRoutes(routes) {
 		var $table = $("table.routes");
 
 		var tbl = '<thead><tr>';
 		tbl += '<th>#</th>';
-		tbl += '<th>Service</th>';
-		tbl += '<th>Host</th>';
-		tbl += '<th>Path</th>';
-		tbl += '<th>Dest</th>';
-		tbl += '<th>Weight</th>';
+		tbl += '<th>' + {{.T "routes.service"}} + '</th>';
+		tbl += '<th>' + {{.T "routes.host"}} + '</th>';
+		tbl += '<th>' + {{.T "routes.path"}} + '</th>';
+		tbl += '<th>' + {{.T "routes.dest"}} + '</th>';
+		tbl += '<th>' + {{.T "routes.weight"}} + '</th>';
+		tbl += '<th class="tags">' + {{.T "routes.meta"}} + '</th>';
+		tbl += '<th></th>';
 		tbl += '</tr></thead><tbody>'
 		tbl += '<tbody>'
 		for (var i=0; i < routes.length; i++) {
 			var r = routes[i];
 			tbl += '<tr>';
-			tbl += '<td>' + (i+1) + '</td>';
+			tbl += '<td>' + ((page-1)*pageSize+i+1) + '</td>';
 			tbl += '<td>' + r.service + '</td>';
 			tbl += '<td>' + r.host + '</td>';
 			tbl += '<td>' + r.path + '</td>';
 			tbl += '<td>' + r.dst + '</td>';
 			tbl += '<td>' + r.weight * 100 + '%</td>';
+			var meta = [];
+			for (var k in r.meta || {}) meta.push(k + '=' + r.meta[k]);
+			tbl += '<td class="tags">' + meta.sort().join(', ') + '</td>';
+			tbl += '<td><a href="#" class="check" data-dst="' + r.dst + '">' + {{.T "routes.check"}} + '</a></td>';
 			tbl += '</tr>';
 		}
 		tbl += '</tbody>';
 		$table.html(tbl);
 	}
 
-	var $filter = $('#filter');
-	function doFilter(v) {
-		$("tr").show();
-		if (!v) return;
-		var words = v.split(' ');
-		console.log('words: ', words);
-		for (var i=0; i < words.length; i++) {
-			var w = words[i].trim();
+	// query converts the filter into the parameters for /api/routes.
+	// Words of the form 'key:value' filter on the host, path, service,
+	// tag or target field and all other words on the route command.
+	function query(v) {
+		var q = {page: page, size: pageSize}, words = [];
+		var keys = ["host", "path", "service", "tag", "target"];
+		var parts = (v || "").split(' ');
+		for (var i=0; i < parts.length; i++) {
+			var w = parts[i].trim();
 			if (w == "") continue;
-			$("tbody tr:not(:contains('"+w+"'))").hide();
+			var n = w.indexOf(':');
+			if (n > 0 && keys.indexOf(w.substr(0, n)) >= 0) {
+				q[w.substr(0, n)] = w.substr(n+1);
+				continue;
+			}
+			words.push(w);
 		}
+		if (words.length > 0) q.q = words.join(' ');
+		return q;
+	}
+
+	var $filter = $('#filter');
+	function doFilter(v) {
+		window.history.replaceState(null, null, "?filter=" + encodeURIComponent(v) + "&page=" + page);
+		$.get("/api/routes", query(v), function(data, status, xhr) {
+			var total = parseInt(xhr.getResponseHeader("X-Total-Count")) || 0;
+			var last = Math.max(1, Math.ceil(total / pageSize));
+			renderRoutes(data || []);
+			$("#pageinfo").text(page + " / " + last + " (" + total + ")");
+			$("#prev").toggle(page > 1);
+			$("#next").toggle(page < last);
+		});
 	}
 
+	var timer;
 	$filter.focus();
 	$filter.keyup(function() {
-		var v = $filter.val();
-		window.history.pushState(null, null, "?filter=" +v);
-		doFilter(v);
+		clearTimeout(timer);
+		timer = setTimeout(function() {
+			page = 1;
+			doFilter($filter.val());
+		}, 200);
 	});
 
-	$.get("/api/routes", function(data) {
-		renderRoutes(data);
-		if (!params.filter) return;
-		var v = decodeURIComponent(params.filter);
-		$filter.val(v);
-		doFilter(v);
+	$("#prev").click(function(e) {
+		e.preventDefault();
+		page--;
+		doFilter($filter.val());
 	});
 
+	$("#next").click(function(e) {
+		e.preventDefault();
+		page++;
+		doFilter($filter.val());
+	});
+
+	// saved views are stored in the browser as name -> filter
+	function loadViews() {
+		try { return JSON.parse(localStorage.getItem("fabio.views")) || {}; } catch (e) { return {}; }
+	}
+
+	function renderViews() {
+		var views = loadViews();
+		$("#views a.view").remove();
+		$.each(Object.keys(views).sort().reverse(), function(i, name) {
+			var $a = $('<a href="#" class="view chip"></a>').text(name).data("filter", views[name]);
+			$("#views").prepend($a);
+		});
+	}
+
+	$("#views").on("click", "a.view", function(e) {
+		e.preventDefault();
+		page = 1;
+		$filter.val($(this).data("filter"));
+		doFilter($filter.val());
+	});
+
+	$("#save-view").click(function(e) {
+		e.preventDefault();
+		var name = window.prompt({{.T "routes.viewname"}});
+		if (!name) return;
+		var views = loadViews();
+		views[name] = $filter.val();
+		localStorage.setItem("fabio.views", JSON.stringify(views));
+		renderViews();
+	});
+
+	$("table.routes").on("click", "a.check", function(e) {
+		e.preventDefault();
+		var $a = $(this);
+		$.post("/api/check", {target: $a.data("dst")}, function(data) {
+			var r = data[0];
+			var msg = r.healthy ? "healthy" : "unhealthy";
+			if (r.status) msg += " (" + r.status + ")";
+			if (r.error) msg += ": " + r.error;
+			Materialize.toast(r.target + " " + msg + " in " + r.duration, 4000, r.healthy ? "green" : "red");
+		});
+	});
+
+	renderViews();
+	var v = params.filter ? decodeURIComponent(params.filter.replace(/\+/g, ' ')) : "";
+	$filter.val(v);
+	doFilter(v);
+
 })
 </script>
 
@@ -0,0 +1,25 @@
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/route"
+)
+
+func TestLastChange(t *testing.T) {
+	defer func(loc *time.Location) { Location = loc }(Location)
+	Location = time.UTC
+
+	tbl, err := route.ParseString("route add svc / http://1.2.3.4:5000/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.SetTable(tbl)
+	defer route.SetTable(make(route.Table))
+
+	want := route.History()[0].Time.UTC().Format(timeFormat)
+	if got := lastChange(); got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
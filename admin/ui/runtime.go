@@ -0,0 +1,90 @@
+package ui
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// HandleRuntime provides the UI for the Go runtime statistics.
+func HandleRuntime(w http.ResponseWriter, r *http.Request) {
+	tmplRuntime.ExecuteTemplate(w, "runtime", newPage(r))
+}
+
+var tmplRuntime = template.Must(template.New("runtime").Parse(`
+<!doctype html>
+<html lang="{{.Lang}}">
+<head>
+	<meta charset="utf-8">
+	<title>./fabio{{if .Title}} - {{.Title}}{{end}}</title>
+	<script type="text/javascript" src="https://code.jquery.com/jquery-2.1.1.min.js"></script>
+	<link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/materialize/0.97.3/css/materialize.min.css">
+	<script src="https://cdnjs.cloudflare.com/ajax/libs/materialize/0.97.3/js/materialize.min.js"></script>
+	<meta name="viewport" content="width=device-width, initial-scale=1.0"/>
+</head>
+<body>
+
+<nav class="top-nav {{.Color}}">
+
+	<div class="container">
+		<div class="nav-wrapper">
+			<a href="/" class="brand-logo">./fabio{{if .Title}} - {{.Title}}{{end}}</a>
+			<ul id="nav-mobile" class="right hide-on-med-and-down">
+				<li><a href="/routes">{{.T "nav.routes"}}</a></li>
+				<li><a href="/manual">{{.T "nav.overrides"}}</a></li>
+				<li><a href="/certs">{{.T "nav.certs"}}</a></li>
+				<li><a href="/traffic">{{.T "nav.traffic"}}</a></li>
+				<li><a href="/history">{{.T "nav.history"}}</a></li>
+				<li><a href="https://github.com/eBay/fabio/blob/master/CHANGELOG.md">{{.Version}}</a></li>
+				<li><a href="https://github.com/eBay/fabio">{{.T "nav.github"}}</a></li>
+			</ul>
+		</div>
+	</div>
+
+</nav>
+
+<div class="container">
+
+	<div class="section">
+		<h5>{{.T "runtime.title"}}</h5>
+		<p class="grey-text">{{.T "routes.updated"}}: <span class="updated">{{.Time}}</span></p>
+		<table class="runtime highlight"></table>
+	</div>
+
+</div>
+
+<script>
+$(function(){
+	function mb(n) { return (n / 1024 / 1024).toFixed(1) + ' MB'; }
+	function ms(n) { return (n / 1000000).toFixed(3) + ' ms'; }
+
+	function renderRuntime(s) {
+		var rows = [
+			[{{.T "runtime.goroutines"}}, s.goroutines],
+			[{{.T "runtime.fds"}}, s.fds < 0 ? '-' : s.fds],
+			[{{.T "runtime.heapalloc"}}, mb(s.heap_alloc)],
+			[{{.T "runtime.heapsys"}}, mb(s.heap_sys)],
+			[{{.T "runtime.heapobjects"}}, s.heap_objects],
+			[{{.T "runtime.gccount"}}, s.num_gc],
+			[{{.T "runtime.gclastpause"}}, ms(s.last_gc_pause)],
+			[{{.T "runtime.gctotalpause"}}, ms(s.total_gc_pause)],
+		];
+		var tbl = '<tbody>';
+		for (var i=0; i < rows.length; i++) {
+			tbl += '<tr><td>' + rows[i][0] + '</td><td>' + rows[i][1] + '</td></tr>';
+		}
+		tbl += '</tbody>';
+		$("table.runtime").html(tbl);
+		$("span.updated").text(new Date(s.time).toLocaleString());
+	}
+
+	function update() {
+		$.get("/api/runtime", renderRuntime);
+	}
+	update();
+	setInterval(update, 5000);
+})
+</script>
+
+</body>
+</html>
+`))
@@ -0,0 +1,98 @@
+package ui
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// HandleTraffic provides the UI for the live request statistics.
+func HandleTraffic(w http.ResponseWriter, r *http.Request) {
+	tmplTraffic.ExecuteTemplate(w, "traffic", newPage(r))
+}
+
+var tmplTraffic = template.Must(template.New("traffic").Parse(`
+<!doctype html>
+<html lang="{{.Lang}}">
+<head>
+	<meta charset="utf-8">
+	<title>./fabio{{if .Title}} - {{.Title}}{{end}}</title>
+	<script type="text/javascript" src="https://code.jquery.com/jquery-2.1.1.min.js"></script>
+	<link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/materialize/0.97.3/css/materialize.min.css">
+	<script src="https://cdnjs.cloudflare.com/ajax/libs/materialize/0.97.3/js/materialize.min.js"></script>
+	<meta name="viewport" content="width=device-width, initial-scale=1.0"/>
+</head>
+<body>
+
+<nav class="top-nav {{.Color}}">
+
+	<div class="container">
+		<div class="nav-wrapper">
+			<a href="/" class="brand-logo">./fabio{{if .Title}} - {{.Title}}{{end}}</a>
+			<ul id="nav-mobile" class="right hide-on-med-and-down">
+				<li><a href="/routes">{{.T "nav.routes"}}</a></li>
+				<li><a href="/manual">{{.T "nav.overrides"}}</a></li>
+				<li><a href="/runtime">{{.T "nav.runtime"}}</a></li>
+				<li><a href="/certs">{{.T "nav.certs"}}</a></li>
+				<li><a href="/history">{{.T "nav.history"}}</a></li>
+				<li><a href="https://github.com/eBay/fabio/blob/master/CHANGELOG.md">{{.Version}}</a></li>
+				<li><a href="https://github.com/eBay/fabio">{{.T "nav.github"}}</a></li>
+			</ul>
+		</div>
+	</div>
+
+</nav>
+
+<div class="container">
+
+	<div class="section">
+		<h5>{{.T "traffic.title"}}</h5>
+		<p class="grey-text">{{.T "routes.updated"}}: <span class="updated">{{.Time}}</span></p>
+		<table class="traffic highlight">
+			<thead>
+				<tr>
+					<th>{{.T "traffic.route"}}</th>
+					<th>{{.T "traffic.rps"}}</th>
+					<th>{{.T "traffic.errors"}}</th>
+					<th>{{.T "traffic.latency"}}</th>
+					<th>{{.T "traffic.bytes"}}</th>
+				</tr>
+			</thead>
+			<tbody></tbody>
+		</table>
+	</div>
+
+</div>
+
+<script>
+$(function(){
+	function esc(s) { return $('<div/>').text(s).html(); }
+	function kb(n) { return (n / 1024).toFixed(1) + ' KB/s'; }
+
+	function renderTraffic(s) {
+		var tbl = '';
+		for (var i=0; i < s.routes.length; i++) {
+			var r = s.routes[i];
+			var err = (r.error_rate * 100).toFixed(1) + ' %';
+			tbl += '<tr>';
+			tbl += '<td>' + esc(r.route) + '</td>';
+			tbl += '<td>' + r.rps.toFixed(1) + '</td>';
+			tbl += '<td' + (r.errors > 0 ? ' class="red-text"' : '') + '>' + err + '</td>';
+			tbl += '<td>' + r.latency_ms.toFixed(1) + ' ms</td>';
+			tbl += '<td>' + kb(r.bytes / r.requests * r.rps) + '</td>';
+			tbl += '</tr>';
+		}
+		if (s.routes.length == 0) {
+			tbl = '<tr><td colspan="5" class="grey-text">' + {{.T "traffic.empty"}} + '</td></tr>';
+		}
+		$("table.traffic tbody").html(tbl);
+		$("span.updated").text(new Date(s.time).toLocaleString());
+	}
+
+	var events = new EventSource("/api/stats/live");
+	events.onmessage = function(e) { renderTraffic(JSON.parse(e.data)); };
+})
+</script>
+
+</body>
+</html>
+`))
@@ -1,6 +1,11 @@
 // Package ui provides the HTML admin console.
 package ui
 
+import (
+	"net/http"
+	"time"
+)
+
 // Color contains the color of the nav bar.
 var Color string
 
@@ -9,3 +14,36 @@ var Title string
 
 // Version contains the current fabio version.
 var Version string
+
+// Lang contains the default language of the UI.
+var Lang = "en"
+
+// Location contains the timezone for the timestamps in the UI.
+var Location = time.Local
+
+// timeFormat is the format of the timestamps in the UI.
+const timeFormat = "2006-01-02 15:04:05 MST"
+
+// page contains the data which is common to all pages.
+type page struct {
+	Color   string
+	Title   string
+	Version string
+	Lang    string
+	Time    string
+}
+
+func newPage(r *http.Request) page {
+	return page{
+		Color:   Color,
+		Title:   Title,
+		Version: Version,
+		Lang:    requestLang(r),
+		Time:    time.Now().In(Location).Format(timeFormat),
+	}
+}
+
+// T returns the message for the key in the language of the page.
+func (p page) T(key string) string {
+	return translate(p.Lang, key)
+}
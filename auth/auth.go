@@ -0,0 +1,123 @@
+// Package auth implements basic authentication for routes
+// with the 'auth' option.
+//
+// The credentials of a realm are loaded from an htpasswd file
+// or a key in the consul KV store and are refreshed periodically.
+package auth
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/eBay/fabio/config"
+	"github.com/hashicorp/consul/api"
+)
+
+// Realms contains the configured realms by name.
+var Realms = map[string]*Realm{}
+
+// Realm is a named set of credentials.
+type Realm struct {
+	// Name is the realm which is sent to the client.
+	Name string
+
+	load  func() ([]byte, error)
+	users atomic.Value // map[string]string
+}
+
+// New creates a realm for the auth source and loads the credentials.
+// If the refresh interval of the source is positive the credentials
+// are reloaded in the background.
+func New(src config.AuthSource) (*Realm, error) {
+	r := &Realm{Name: src.Realm}
+	switch src.Type {
+	case "file":
+		r.load = func() ([]byte, error) { return ioutil.ReadFile(src.Path) }
+	case "consul":
+		load, err := consulLoader(src.Path)
+		if err != nil {
+			return nil, err
+		}
+		r.load = load
+	default:
+		return nil, errors.New("auth: unknown source type " + src.Type)
+	}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	if src.Refresh > 0 {
+		go r.refresh(src.Refresh)
+	}
+	return r, nil
+}
+
+func (r *Realm) reload() error {
+	b, err := r.load()
+	if err != nil {
+		return err
+	}
+	r.users.Store(parseHtpasswd(b))
+	return nil
+}
+
+// refresh reloads the credentials periodically and keeps
+// the current credentials if the source cannot be loaded.
+func (r *Realm) refresh(d time.Duration) {
+	for range time.Tick(d) {
+		if err := r.reload(); err != nil {
+			log.Printf("[WARN] auth: Cannot refresh credentials for realm %s. %s", r.Name, err)
+		}
+	}
+}
+
+// Authenticate returns true if the request contains valid
+// basic auth credentials for the realm.
+func (r *Realm) Authenticate(req *http.Request) bool {
+	user, pass, ok := req.BasicAuth()
+	if !ok {
+		return false
+	}
+	users, _ := r.users.Load().(map[string]string)
+	hash, ok := users[user]
+	return ok && verify(hash, pass)
+}
+
+// consulLoader returns a function which loads the value of a key
+// from the consul KV store. The url has the same format as for
+// the consul certificate source.
+func consulLoader(rawurl string) (func() ([]byte, error), error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("auth: invalid consul url " + rawurl)
+	}
+	const prefix = "/v1/kv/"
+	if !strings.HasPrefix(u.Path, prefix) {
+		return nil, errors.New("auth: missing prefix " + prefix + " in " + rawurl)
+	}
+	key := u.Path[len(prefix):]
+
+	client, err := api.NewClient(&api.Config{Address: u.Host, Scheme: u.Scheme, Token: u.Query().Get("token")})
+	if err != nil {
+		return nil, err
+	}
+	return func() ([]byte, error) {
+		kv, _, err := client.KV().Get(key, nil)
+		if err != nil {
+			return nil, err
+		}
+		if kv == nil {
+			return nil, errors.New("auth: key " + key + " not found")
+		}
+		return kv.Value, nil
+	}, nil
+}
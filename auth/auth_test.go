@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/eBay/fabio/config"
+)
+
+func TestRealmAuthenticate(t *testing.T) {
+	f, err := ioutil.TempFile("", "htpasswd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("alice:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n")
+	f.Close()
+
+	r, err := New(config.AuthSource{Name: "a", Type: "file", Path: f.Name(), Realm: "Staging"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		user, pass string
+		ok         bool
+	}{
+		{"alice", "secret", true},
+		{"alice", "wrong", false},
+		{"bob", "secret", false},
+		{"", "", false},
+	}
+
+	for i, tt := range tests {
+		req := &http.Request{Header: http.Header{}}
+		if tt.user != "" {
+			req.SetBasicAuth(tt.user, tt.pass)
+		}
+		if got, want := r.Authenticate(req), tt.ok; got != want {
+			t.Errorf("%d: got %v want %v", i, got, want)
+		}
+	}
+}
+
+func TestNewMissingFile(t *testing.T) {
+	if _, err := New(config.AuthSource{Name: "a", Type: "file", Path: "/does/not/exist"}); err == nil {
+		t.Fatal("got nil want error")
+	}
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"log"
+	"strings"
+)
+
+// parseHtpasswd parses the content of an htpasswd file and returns
+// the password hashes by user name. Lines with unsupported hash
+// formats are logged and ignored.
+func parseHtpasswd(b []byte) map[string]string {
+	users := map[string]string{}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p := strings.SplitN(line, ":", 2)
+		if len(p) != 2 || p[0] == "" {
+			log.Printf("[WARN] auth: Skipping invalid htpasswd line %q", line)
+			continue
+		}
+		user, hash := p[0], p[1]
+		if !strings.HasPrefix(hash, "{SHA}") && !strings.HasPrefix(hash, "$apr1$") {
+			log.Printf("[WARN] auth: Skipping user %s with unsupported hash format. Use 'htpasswd -m' or 'htpasswd -s'", user)
+			continue
+		}
+		users[user] = hash
+	}
+	return users
+}
+
+// verify returns true if the password matches the hash.
+func verify(hash, password string) bool {
+	var h string
+	switch {
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		h = "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+	case strings.HasPrefix(hash, "$apr1$"):
+		salt := strings.SplitN(hash[len("$apr1$"):], "$", 2)[0]
+		h = apr1(password, salt)
+	default:
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1
+}
+
+// apr1 returns the Apache MD5 hash of the password
+// which is the format of 'htpasswd -m'.
+func apr1(password, salt string) string {
+	const magic = "$apr1$"
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	pw := []byte(password)
+
+	alt := md5.New()
+	alt.Write(pw)
+	alt.Write([]byte(salt))
+	alt.Write(pw)
+	altSum := alt.Sum(nil)
+
+	h := md5.New()
+	h.Write(pw)
+	h.Write([]byte(magic))
+	h.Write([]byte(salt))
+	for i := len(pw); i > 0; i -= 16 {
+		if i > 16 {
+			h.Write(altSum)
+		} else {
+			h.Write(altSum[:i])
+		}
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write([]byte{0})
+		} else {
+			h.Write(pw[:1])
+		}
+	}
+	sum := h.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		h := md5.New()
+		if i&1 != 0 {
+			h.Write(pw)
+		} else {
+			h.Write(sum)
+		}
+		if i%3 != 0 {
+			h.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			h.Write(pw)
+		}
+		if i&1 != 0 {
+			h.Write(sum)
+		} else {
+			h.Write(pw)
+		}
+		sum = h.Sum(nil)
+	}
+
+	const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	var out []byte
+	to64 := func(v uint, n int) {
+		for ; n > 0; n-- {
+			out = append(out, itoa64[v&0x3f])
+			v >>= 6
+		}
+	}
+	to64(uint(sum[0])<<16|uint(sum[6])<<8|uint(sum[12]), 4)
+	to64(uint(sum[1])<<16|uint(sum[7])<<8|uint(sum[13]), 4)
+	to64(uint(sum[2])<<16|uint(sum[8])<<8|uint(sum[14]), 4)
+	to64(uint(sum[3])<<16|uint(sum[9])<<8|uint(sum[15]), 4)
+	to64(uint(sum[4])<<16|uint(sum[10])<<8|uint(sum[5]), 4)
+	to64(uint(sum[11]), 2)
+
+	return magic + salt + "$" + string(out)
+}
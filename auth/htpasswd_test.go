@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHtpasswd(t *testing.T) {
+	in := `
+# comment
+alice:$apr1$r31....K$vQyPE55xffCTu96EN2QYb0
+bob:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=
+carol:$2y$05$abcdefghijklmnopqrstuu
+invalid
+`
+	got := parseHtpasswd([]byte(in))
+	want := map[string]string{
+		"alice": "$apr1$r31....K$vQyPE55xffCTu96EN2QYb0",
+		"bob":   "{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		hash, password string
+		ok             bool
+	}{
+		{"$apr1$r31....K$vQyPE55xffCTu96EN2QYb0", "secret", true},
+		{"$apr1$r31....K$vQyPE55xffCTu96EN2QYb0", "Secret", false},
+		{"{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=", "secret", true},
+		{"{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=", "", false},
+		{"secret", "secret", false},
+	}
+
+	for i, tt := range tests {
+		if got, want := verify(tt.hash, tt.password), tt.ok; got != want {
+			t.Errorf("%d: got %v want %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package cert
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// awsCredentials are the credentials for signing AWS requests.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Token           string
+	Expiration      time.Time
+}
+
+// expired returns true if the credentials expire within
+// the next five minutes.
+func (c *awsCredentials) expired(t time.Time) bool {
+	return !c.Expiration.IsZero() && t.Add(5*time.Minute).After(c.Expiration)
+}
+
+// awsMetadataURL is the URL of the EC2 instance metadata service.
+var awsMetadataURL = "http://169.254.169.254"
+
+// awsContainerURL is the URL of the ECS container credentials endpoint.
+var awsContainerURL = "http://169.254.170.2"
+
+// awsCredentialProvider caches the AWS credentials from the
+// environment, the ECS container credentials endpoint or the
+// role of the EC2 instance in that order.
+type awsCredentialProvider struct {
+	client *http.Client
+
+	mu    sync.Mutex
+	creds *awsCredentials
+}
+
+var awsCreds = &awsCredentialProvider{client: &http.Client{Timeout: 5 * time.Second}}
+
+func (p *awsCredentialProvider) get() (*awsCredentials, error) {
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return &awsCredentials{AccessKeyID: id, SecretAccessKey: secret, Token: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.creds != nil && !p.creds.expired(time.Now()) {
+		return p.creds, nil
+	}
+
+	var creds *awsCredentials
+	var err error
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		creds, err = p.fetch(awsContainerURL+uri, nil)
+	} else {
+		creds, err = p.instanceRole()
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.creds = creds
+	return creds, nil
+}
+
+// instanceRole returns the credentials of the role of the EC2
+// instance with a session token for the metadata service (IMDSv2).
+func (p *awsCredentialProvider) instanceRole() (*awsCredentials, error) {
+	req, err := http.NewRequest("PUT", awsMetadataURL+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, err := p.read(req)
+	if err != nil {
+		return nil, fmt.Errorf("cert: cannot get metadata token: %s", err)
+	}
+	hdr := http.Header{"X-Aws-Ec2-Metadata-Token": {string(token)}}
+
+	base := awsMetadataURL + "/latest/meta-data/iam/security-credentials/"
+	req, err = http.NewRequest("GET", base, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = hdr
+	roles, err := p.read(req)
+	if err != nil {
+		return nil, fmt.Errorf("cert: cannot get instance role: %s", err)
+	}
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if role == "" {
+		return nil, errors.New("cert: no instance role")
+	}
+	return p.fetch(base+role, hdr)
+}
+
+// fetch loads the credentials in JSON format from the URL.
+func (p *awsCredentialProvider) fetch(url string, hdr http.Header) (*awsCredentials, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if hdr != nil {
+		req.Header = hdr
+	}
+	buf, err := p.read(req)
+	if err != nil {
+		return nil, fmt.Errorf("cert: cannot get AWS credentials: %s", err)
+	}
+	var creds awsCredentials
+	if err := json.Unmarshal(buf, &creds); err != nil {
+		return nil, fmt.Errorf("cert: invalid AWS credentials: %s", err)
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, errors.New("cert: invalid AWS credentials")
+	}
+	return &creds, nil
+}
+
+func (p *awsCredentialProvider) read(req *http.Request) ([]byte, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", req.URL, resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// emptySHA256 is the hex encoded SHA-256 hash of an empty body.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signV4 signs the request without a body for the AWS service in the
+// region with the AWS Signature Version 4. All headers of the request
+// and the host are signed.
+func signV4(req *http.Request, creds *awsCredentials, region, service string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	if creds.Token != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.Token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	var names []string
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsURIEncode(req.URL.Path, false),
+		awsCanonicalQuery(req),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptySHA256,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	h := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(h[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsCanonicalQuery returns the sorted and encoded query string.
+func awsCanonicalQuery(req *http.Request) string {
+	q := req.URL.Query()
+	var keys []string
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var p []string
+	for _, k := range keys {
+		vals := q[k]
+		sort.Strings(vals)
+		for _, v := range vals {
+			p = append(p, awsURIEncode(k, true)+"="+awsURIEncode(v, true))
+		}
+	}
+	return strings.Join(p, "&")
+}
+
+// awsURIEncode encodes all characters except the unreserved ones.
+// The slash is only encoded if encodeSlash is true.
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
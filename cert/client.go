@@ -0,0 +1,37 @@
+package cert
+
+import (
+	"crypto/tls"
+)
+
+// ClientStores contains the stores with the client certificates
+// for the connections to upstream servers by the name of the
+// certificate source.
+var ClientStores = map[string]*Store{}
+
+// NewClientStore creates a store which is updated with the
+// certificates from the source with the given name. Its
+// GetClientCertificate method can be used for the tls.Config
+// of a client.
+func NewClientStore(name string, src Source) *Store {
+	store := NewStore()
+	go update(name, store, src, nil)
+	return store
+}
+
+// GetClientCertificate returns the first certificate of the store
+// which is supported by the server. If none of them is supported
+// the first certificate is returned and the server decides whether
+// to accept it. No certificate is sent if the store is empty.
+func (s *Store) GetClientCertificate(cri *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cs := s.certstore()
+	if len(cs.Certificates) == 0 {
+		return &tls.Certificate{}, nil
+	}
+	for i := range cs.Certificates {
+		if cri.SupportsCertificate(&cs.Certificates[i]) == nil {
+			return &cs.Certificates[i], nil
+		}
+	}
+	return &cs.Certificates[0], nil
+}
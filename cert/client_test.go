@@ -0,0 +1,43 @@
+package cert
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+)
+
+func TestGetClientCertificate(t *testing.T) {
+	store := NewStore()
+	cri := &tls.CertificateRequestInfo{Version: tls.VersionTLS12}
+
+	got, err := store.GetClientCertificate(cri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Certificate) != 0 {
+		t.Fatal("got certificate from empty store")
+	}
+
+	cert := makeCert("client", time.Minute)
+	store.SetCertificates([]tls.Certificate{cert})
+	got, err = store.GetClientCertificate(cri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Certificate) == 0 || string(got.Certificate[0]) != string(cert.Certificate[0]) {
+		t.Fatal("got wrong certificate")
+	}
+}
+
+func TestNewClientStore(t *testing.T) {
+	cert := makeCert("client", time.Minute)
+	store := NewClientStore("client", StaticSource{cert})
+	ok := waitFor(time.Second, func() bool {
+		eventsMu.Lock()
+		defer eventsMu.Unlock()
+		return len(store.certstore().Certificates) == 1 && len(loaded["client"].Certs) == 1
+	})
+	if !ok {
+		t.Fatal("store not updated")
+	}
+}
@@ -0,0 +1,104 @@
+package cert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Event is sent when a certificate source has provided
+// new certificates.
+type Event struct {
+	Source string
+	Time   time.Time
+	Certs  []Info
+}
+
+// Info describes a loaded certificate.
+type Info struct {
+	Source    string    `json:"source"`
+	Subject   string    `json:"subject"`
+	SANs      []string  `json:"sans"`
+	Issuer    string    `json:"issuer"`
+	Serial    string    `json:"serial"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	Refreshed time.Time `json:"refreshed"`
+}
+
+var (
+	eventsMu    sync.Mutex
+	loaded      = map[string]Event{}
+	subscribers []chan<- Event
+)
+
+// Notify causes the events for new certificates to be sent to ch.
+// Like signal.Notify the events are not sent if ch is not ready to
+// receive them and the caller should use a buffered channel.
+func Notify(ch chan<- Event) {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	subscribers = append(subscribers, ch)
+}
+
+// Loaded returns the certificates of all sources sorted by
+// source and subject.
+func Loaded() []Info {
+	eventsMu.Lock()
+	var infos []Info
+	for _, ev := range loaded {
+		infos = append(infos, ev.Certs...)
+	}
+	eventsMu.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Source != infos[j].Source {
+			return infos[i].Source < infos[j].Source
+		}
+		return infos[i].Subject < infos[j].Subject
+	})
+	return infos
+}
+
+// record stores the certificates of the source and
+// notifies the subscribers.
+func record(source string, certs []tls.Certificate, t time.Time) {
+	ev := Event{Source: source, Time: t}
+	for _, c := range certs {
+		if len(c.Certificate) == 0 {
+			continue
+		}
+		x, err := x509.ParseCertificate(c.Certificate[0])
+		if err != nil {
+			continue
+		}
+		var sans []string
+		sans = append(sans, x.DNSNames...)
+		for _, ip := range x.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		ev.Certs = append(ev.Certs, Info{
+			Source:    source,
+			Subject:   certName(x),
+			SANs:      sans,
+			Issuer:    x.Issuer.CommonName,
+			Serial:    fmt.Sprintf("%x", x.SerialNumber),
+			NotBefore: x.NotBefore,
+			NotAfter:  x.NotAfter,
+			Refreshed: t,
+		})
+	}
+
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	loaded[source] = ev
+	for _, ch := range subscribers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package cert
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	eventsMu.Lock()
+	loaded, subscribers = map[string]Event{}, nil
+	eventsMu.Unlock()
+	defer func() { loaded, subscribers = map[string]Event{}, nil }()
+
+	ch := make(chan Event, 1)
+	Notify(ch)
+
+	t1 := time.Unix(1500000000, 0)
+	record("b", []tls.Certificate{makeCert("foo.com", time.Hour)}, t1)
+	record("a", []tls.Certificate{makeCert("bar.com", time.Hour), makeCert("baz.com", time.Hour)}, t1)
+
+	ev := <-ch
+	if got, want := ev.Source, "b"; got != want {
+		t.Fatalf("got event for %q want %q", got, want)
+	}
+	if got, want := ev.Certs[0].SANs, []string{"foo.com"}; len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("got SANs %v want %v", got, want)
+	}
+
+	var got []string
+	for _, info := range Loaded() {
+		got = append(got, info.Source+":"+info.Subject)
+	}
+	want := []string{"a:bar.com", "a:baz.com", "b:foo.com"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("got %v want %v", got, want)
+	}
+
+	// an update replaces the certificates of the source
+	t2 := t1.Add(time.Minute)
+	record("a", []tls.Certificate{makeCert("new.com", time.Hour)}, t2)
+	infos := Loaded()
+	if len(infos) != 2 || infos[0].Subject != "new.com" || !infos[0].Refreshed.Equal(t2) {
+		t.Fatalf("got %+v", infos)
+	}
+}
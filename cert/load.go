@@ -19,15 +19,6 @@ import (
 const MaxSize = 1 << 20 // 1MB
 
 func loadURL(listURL string) (pemBlocks map[string][]byte, err error) {
-	if listURL == "" {
-		return nil, nil
-	}
-
-	baseURL, err := base(listURL)
-	if err != nil {
-		return nil, fmt.Errorf("cert: %s", err)
-	}
-
 	fetch := func(url string) (buf []byte, err error) {
 		resp, err := http.Get(url)
 		if err != nil {
@@ -36,6 +27,20 @@ func loadURL(listURL string) (pemBlocks map[string][]byte, err error) {
 		defer resp.Body.Close()
 		return ioutil.ReadAll(resp.Body)
 	}
+	return loadList(listURL, fetch)
+}
+
+// loadList loads the files which are listed in the file at listURL
+// from the same directory with the fetch function.
+func loadList(listURL string, fetch func(url string) ([]byte, error)) (pemBlocks map[string][]byte, err error) {
+	if listURL == "" {
+		return nil, nil
+	}
+
+	baseURL, err := base(listURL)
+	if err != nil {
+		return nil, fmt.Errorf("cert: %s", err)
+	}
 
 	// fetch the file with the list of filenames
 	list, err := fetch(listURL)
@@ -50,7 +55,12 @@ func loadURL(listURL string) (pemBlocks map[string][]byte, err error) {
 			continue
 		}
 
-		path := baseURL + p
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("cert: %s", err)
+		}
+		u.Path = path.Join(u.Path, p)
+		path := u.String()
 
 		buf, err := fetch(path)
 		if err != nil {
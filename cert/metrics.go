@@ -0,0 +1,40 @@
+package cert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"time"
+
+	"github.com/eBay/fabio/metrics"
+)
+
+// expiryInterval is the interval in which the certificate
+// expiry gauges are updated.
+var expiryInterval = time.Hour
+
+// updateExpiryGauges sets the 'cert.<name>.expiry_days' gauges
+// to the number of days until the certificates expire. The name
+// is the common name of the leaf certificate or its first DNS name.
+func updateExpiryGauges(certs []tls.Certificate, now time.Time) {
+	for _, c := range certs {
+		if len(c.Certificate) == 0 {
+			continue
+		}
+		x, err := x509.ParseCertificate(c.Certificate[0])
+		if err != nil {
+			continue
+		}
+		days := int64(x.NotAfter.Sub(now) / (24 * time.Hour))
+		metrics.DefaultRegistry.GetGauge("cert." + metrics.Clean(certName(x)) + ".expiry_days").Update(days)
+	}
+}
+
+func certName(x *x509.Certificate) string {
+	if x.Subject.CommonName != "" {
+		return x.Subject.CommonName
+	}
+	if len(x.DNSNames) > 0 {
+		return x.DNSNames[0]
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package cert
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/metrics"
+)
+
+type gaugeRegistry struct {
+	metrics.NoopRegistry
+	gauges map[string]*gauge
+}
+
+func (r *gaugeRegistry) GetGauge(name string) metrics.Gauge {
+	g := r.gauges[name]
+	if g == nil {
+		g = &gauge{}
+		r.gauges[name] = g
+	}
+	return g
+}
+
+type gauge struct{ n int64 }
+
+func (g *gauge) Update(n int64) { g.n = n }
+
+func TestUpdateExpiryGauges(t *testing.T) {
+	defer func(r metrics.Registry) { metrics.DefaultRegistry = r }(metrics.DefaultRegistry)
+	r := &gaugeRegistry{gauges: map[string]*gauge{}}
+	metrics.DefaultRegistry = r
+
+	certs := []tls.Certificate{
+		makeCert("a.com", 10*24*time.Hour),
+		makeCert("b.com", time.Hour),
+	}
+	updateExpiryGauges(certs, time.Now())
+
+	want := map[string]int64{
+		"cert.a_com.expiry_days": 9,
+		"cert.b_com.expiry_days": 0,
+	}
+	if got, want := len(r.gauges), len(want); got != want {
+		t.Fatalf("got %d gauges want %d", got, want)
+	}
+	for name, days := range want {
+		g := r.gauges[name]
+		if g == nil {
+			t.Fatalf("gauge %s missing", name)
+		}
+		if got := g.n; got != days {
+			t.Errorf("%s: got %d want %d", name, got, days)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ocspInterval is the interval in which the OCSP responses
+// are checked for a refresh.
+var ocspInterval = time.Minute
+
+// ocspRetry is the time after which a failed OCSP request is retried.
+var ocspRetry = 5 * time.Minute
+
+// stubbed out for testing
+var now = time.Now
+
+// The ASN.1 structures of the OCSP request and response from RFC 6960.
+// Only the fields which are needed for stapling are decoded.
+
+var (
+	oidSHA1          = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidOCSPBasic     = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	ocspSigAlgorithm = map[string]x509.SignatureAlgorithm{
+		"1.2.840.113549.1.1.5":  x509.SHA1WithRSA,
+		"1.2.840.113549.1.1.11": x509.SHA256WithRSA,
+		"1.2.840.113549.1.1.12": x509.SHA384WithRSA,
+		"1.2.840.113549.1.1.13": x509.SHA512WithRSA,
+		"1.2.840.10045.4.1":     x509.ECDSAWithSHA1,
+		"1.2.840.10045.4.3.2":   x509.ECDSAWithSHA256,
+		"1.2.840.10045.4.3.3":   x509.ECDSAWithSHA384,
+		"1.2.840.10045.4.3.4":   x509.ECDSAWithSHA512,
+		"1.3.101.112":           x509.PureEd25519,
+	}
+)
+
+type ocspCertID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type ocspRequest struct {
+	TBSRequest ocspTBSRequest
+}
+
+type ocspTBSRequest struct {
+	Version     int `asn1:"explicit,tag:0,default:0,optional"`
+	RequestList []ocspSingleRequest
+}
+
+type ocspSingleRequest struct {
+	Cert ocspCertID
+}
+
+type ocspResponseASN1 struct {
+	Status   asn1.Enumerated
+	Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData    ocspResponseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	Raw                asn1.RawContent
+	Version            int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID     asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []ocspSingleResponse
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspSingleResponse struct {
+	CertID           ocspCertID
+	Good             asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked          ocspRevokedInfo  `asn1:"tag:1,optional"`
+	Unknown          asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate       time.Time        `asn1:"generalized"`
+	NextUpdate       time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	SingleExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// ocspCertIDFor returns the OCSP certificate id of the certificate.
+func ocspCertIDFor(cert, issuer *x509.Certificate) (ocspCertID, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return ocspCertID{}, err
+	}
+	nameHash := sha1.Sum(issuer.RawSubject)
+	keyHash := sha1.Sum(spki.PublicKey.RightAlign())
+	return ocspCertID{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.RawValue{Tag: asn1.TagNull}},
+		NameHash:      nameHash[:],
+		IssuerKeyHash: keyHash[:],
+		SerialNumber:  cert.SerialNumber,
+	}, nil
+}
+
+// ocspResponse is the status of a certificate from an OCSP response.
+type ocspResponse struct {
+	raw        []byte
+	good       bool
+	thisUpdate time.Time
+	nextUpdate time.Time
+}
+
+// parseOCSPResponse parses the OCSP response for the certificate and
+// verifies that it has been signed by the issuer or by a responder
+// which has been delegated by the issuer.
+func parseOCSPResponse(der []byte, cert, issuer *x509.Certificate) (*ocspResponse, error) {
+	var resp ocspResponseASN1
+	if rest, err := asn1.Unmarshal(der, &resp); err != nil {
+		return nil, err
+	} else if len(rest) > 0 {
+		return nil, errors.New("ocsp: trailing data in response")
+	}
+	if resp.Status != 0 {
+		return nil, fmt.Errorf("ocsp: responder returned status %d", resp.Status)
+	}
+	if !resp.Response.ResponseType.Equal(oidOCSPBasic) {
+		return nil, errors.New("ocsp: unsupported response type")
+	}
+
+	var basic ocspBasicResponse
+	if _, err := asn1.Unmarshal(resp.Response.Response, &basic); err != nil {
+		return nil, err
+	}
+
+	signer := issuer
+	if len(basic.Certificates) > 0 {
+		c, err := x509.ParseCertificate(basic.Certificates[0].FullBytes)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(c.Raw, issuer.Raw) {
+			if err := c.CheckSignatureFrom(issuer); err != nil {
+				return nil, fmt.Errorf("ocsp: responder certificate not signed by issuer: %s", err)
+			}
+			if !hasExtKeyUsage(c, x509.ExtKeyUsageOCSPSigning) {
+				return nil, errors.New("ocsp: responder certificate not authorized for OCSP signing")
+			}
+		}
+		signer = c
+	}
+	algo, ok := ocspSigAlgorithm[basic.SignatureAlgorithm.Algorithm.String()]
+	if !ok {
+		return nil, fmt.Errorf("ocsp: unsupported signature algorithm %s", basic.SignatureAlgorithm.Algorithm)
+	}
+	if err := signer.CheckSignature(algo, basic.TBSResponseData.Raw, basic.Signature.RightAlign()); err != nil {
+		return nil, fmt.Errorf("ocsp: invalid signature: %s", err)
+	}
+
+	for _, r := range basic.TBSResponseData.Responses {
+		if r.CertID.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+			continue
+		}
+		return &ocspResponse{
+			raw:        der,
+			good:       bool(r.Good),
+			thisUpdate: r.ThisUpdate,
+			nextUpdate: r.NextUpdate,
+		}, nil
+	}
+	return nil, errors.New("ocsp: no response for certificate")
+}
+
+func hasExtKeyUsage(c *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	for _, u := range c.ExtKeyUsage {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchOCSP requests the status of the certificate from the
+// first OCSP server of the certificate.
+func fetchOCSP(client *http.Client, cert, issuer *x509.Certificate) (*ocspResponse, error) {
+	if len(cert.OCSPServer) == 0 {
+		return nil, errors.New("ocsp: certificate has no OCSP server")
+	}
+	id, err := ocspCertIDFor(cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+	req, err := asn1.Marshal(ocspRequest{TBSRequest: ocspTBSRequest{RequestList: []ocspSingleRequest{{Cert: id}}}})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Post(cert.OCSPServer[0], "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ocsp: %s returned status %d", cert.OCSPServer[0], resp.StatusCode)
+	}
+	der, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	return parseOCSPResponse(der, cert, issuer)
+}
+
+// staple is a cached OCSP response and the time for the next refresh.
+type staple struct {
+	resp    *ocspResponse
+	refresh time.Time
+}
+
+// ocspStapler fetches and caches the OCSP responses for the served
+// certificates and refreshes them halfway through their validity.
+type ocspStapler struct {
+	client *http.Client
+
+	mu      sync.Mutex
+	staples map[string]*staple
+}
+
+func newOCSPStapler() *ocspStapler {
+	return &ocspStapler{
+		client:  &http.Client{Timeout: 10 * time.Second},
+		staples: map[string]*staple{},
+	}
+}
+
+// staple returns a copy of the certificate with the cached OCSP
+// response if there is a valid one. Otherwise, the certificate is
+// returned unchanged.
+func (s *ocspStapler) staple(cert *tls.Certificate) *tls.Certificate {
+	if cert == nil || len(cert.Certificate) == 0 {
+		return cert
+	}
+	s.mu.Lock()
+	st := s.staples[string(cert.Certificate[0])]
+	s.mu.Unlock()
+	if st == nil || st.resp == nil || !st.resp.good {
+		return cert
+	}
+	if !st.resp.nextUpdate.IsZero() && now().After(st.resp.nextUpdate) {
+		return cert
+	}
+	c := *cert
+	c.OCSPStaple = st.resp.raw
+	return &c
+}
+
+// update fetches the OCSP responses for the certificates which are
+// due for a refresh and removes the responses of the certificates
+// which are no longer served. The previous response is kept until
+// it expires if the refresh fails.
+func (s *ocspStapler) update(certs []tls.Certificate) {
+	t := now()
+	keep := map[string]bool{}
+	for _, c := range certs {
+		if len(c.Certificate) < 2 {
+			continue
+		}
+		key := string(c.Certificate[0])
+		keep[key] = true
+
+		s.mu.Lock()
+		st := s.staples[key]
+		s.mu.Unlock()
+		if st != nil && t.Before(st.refresh) {
+			continue
+		}
+
+		leaf, err := x509.ParseCertificate(c.Certificate[0])
+		if err != nil || len(leaf.OCSPServer) == 0 {
+			continue
+		}
+		issuer, err := x509.ParseCertificate(c.Certificate[1])
+		if err != nil {
+			continue
+		}
+
+		next := &staple{refresh: t.Add(ocspRetry)}
+		if st != nil {
+			next.resp = st.resp
+		}
+		resp, err := fetchOCSP(s.client, leaf, issuer)
+		switch {
+		case err != nil:
+			log.Printf("[WARN] cert: Cannot fetch OCSP response for %s. %s", certName(leaf), err)
+		case !resp.good:
+			log.Printf("[ERROR] cert: OCSP status of %s is not good", certName(leaf))
+			next.resp = nil
+		default:
+			next.resp = resp
+			next.refresh = t.Add(time.Hour)
+			if !resp.nextUpdate.IsZero() {
+				next.refresh = resp.thisUpdate.Add(resp.nextUpdate.Sub(resp.thisUpdate) / 2)
+			}
+		}
+
+		s.mu.Lock()
+		s.staples[key] = next
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	for key := range s.staples {
+		if !keep[key] {
+			delete(s.staples, key)
+		}
+	}
+	s.mu.Unlock()
+}
@@ -0,0 +1,206 @@
+package cert
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// ocspTestCA is a CA with a leaf certificate whose
+// OCSP responder is served by a test server.
+type ocspTestCA struct {
+	key     *ecdsa.PrivateKey
+	cert    *x509.Certificate
+	leaf    *x509.Certificate
+	tlsCert tls.Certificate
+	server  *httptest.Server
+	good    bool
+	next    time.Duration
+	reqs    int
+}
+
+func newOCSPTestCA(t *testing.T) *ocspTestCA {
+	ca := &ocspTestCA{good: true, next: 4 * time.Hour}
+	ca.server = httptest.NewServer(http.HandlerFunc(ca.respond))
+
+	var err error
+	ca.key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             now().Add(-time.Hour),
+		NotAfter:              now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &ca.key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.cert, _ = x509.ParseCertificate(caDER)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "leaf"},
+		NotBefore:    now().Add(-time.Hour),
+		NotAfter:     now().Add(24 * time.Hour),
+		OCSPServer:   []string{ca.server.URL},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTmpl, ca.cert, &leafKey.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.leaf, _ = x509.ParseCertificate(leafDER)
+	ca.tlsCert = tls.Certificate{Certificate: [][]byte{leafDER, caDER}, PrivateKey: leafKey}
+	return ca
+}
+
+// respond answers the OCSP request with a response
+// which is signed by the CA.
+func (ca *ocspTestCA) respond(w http.ResponseWriter, r *http.Request) {
+	ca.reqs++
+	body, _ := ioutil.ReadAll(r.Body)
+	var req ocspRequest
+	if _, err := asn1.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id := req.TBSRequest.RequestList[0].Cert
+
+	single := ocspSingleResponse{CertID: id, ThisUpdate: now().Add(-time.Hour).UTC().Truncate(time.Second)}
+	single.NextUpdate = single.ThisUpdate.Add(ca.next)
+	if ca.good {
+		single.Good = true
+	} else {
+		single.Unknown = true
+	}
+	keyHash := sha256.Sum256(ca.cert.RawSubjectPublicKeyInfo)
+	responderID, _ := asn1.Marshal(keyHash[:20])
+	tbs, err := asn1.Marshal(ocspResponseData{
+		RawResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: responderID},
+		ProducedAt:     now().UTC().Truncate(time.Second),
+		Responses:      []ocspSingleResponse{single},
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h := sha256.Sum256(tbs)
+	sig, err := ca.key.Sign(rand.Reader, h[:], crypto.SHA256)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	basic, err := asn1.Marshal(struct {
+		TBSResponseData    asn1.RawValue
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		Signature          asn1.BitString
+	}{
+		asn1.RawValue{FullBytes: tbs},
+		pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)},
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp, err := asn1.Marshal(ocspResponseASN1{Response: ocspResponseBytes{ResponseType: oidOCSPBasic, Response: basic}})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resp)
+}
+
+func TestFetchOCSP(t *testing.T) {
+	ca := newOCSPTestCA(t)
+	defer ca.server.Close()
+
+	resp, err := fetchOCSP(http.DefaultClient, ca.leaf, ca.cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.good {
+		t.Fatal("got status not good")
+	}
+	if got, want := resp.nextUpdate.Sub(resp.thisUpdate), ca.next; got != want {
+		t.Fatalf("got validity %s want %s", got, want)
+	}
+
+	// response must be signed by the issuer
+	other := newOCSPTestCA(t)
+	defer other.server.Close()
+	if _, err := fetchOCSP(http.DefaultClient, ca.leaf, other.cert); err == nil {
+		t.Fatal("got no error for response of another issuer")
+	}
+}
+
+func TestOCSPStapler(t *testing.T) {
+	defer func(fn func() time.Time) { now = fn }(now)
+	start := time.Now()
+	now = func() time.Time { return start }
+
+	ca := newOCSPTestCA(t)
+	defer ca.server.Close()
+
+	s := newOCSPStapler()
+	if got := s.staple(&ca.tlsCert); got.OCSPStaple != nil {
+		t.Fatal("got staple before update")
+	}
+
+	s.update([]tls.Certificate{ca.tlsCert})
+	got := s.staple(&ca.tlsCert)
+	if got.OCSPStaple == nil {
+		t.Fatal("got no staple")
+	}
+	if ca.tlsCert.OCSPStaple != nil {
+		t.Fatal("original certificate modified")
+	}
+
+	// no refresh before half of the validity
+	s.update([]tls.Certificate{ca.tlsCert})
+	if ca.reqs != 1 {
+		t.Fatalf("got %d requests want 1", ca.reqs)
+	}
+
+	// refresh after half of the validity
+	now = func() time.Time { return start.Add(2 * time.Hour) }
+	s.update([]tls.Certificate{ca.tlsCert})
+	if ca.reqs != 2 {
+		t.Fatalf("got %d requests want 2", ca.reqs)
+	}
+
+	// responses which are not good are not stapled
+	ca.good = false
+	now = func() time.Time { return start.Add(4 * time.Hour) }
+	s.update([]tls.Certificate{ca.tlsCert})
+	if got := s.staple(&ca.tlsCert); got.OCSPStaple != nil {
+		t.Fatal("got staple for unknown status")
+	}
+
+	// responses of certificates which are no longer served are removed
+	s.update(nil)
+	if len(s.staples) != 0 {
+		t.Fatalf("got %d staples want 0", len(s.staples))
+	}
+}
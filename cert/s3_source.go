@@ -0,0 +1,113 @@
+package cert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// S3Source implements a certificate source which loads TLS and
+// client authentication certificates from an AWS S3 bucket with
+// requests signed with AWS Signature Version 4.
+// The CertURL/ClientCAURL must point to a text file in the directory
+// of the certificates like for the HTTPSource and can either be an
+// s3://bucket/path URL or an https URL of an S3 compatible endpoint.
+// The credentials are read from the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables,
+// the ECS container credentials or the role of the EC2 instance.
+// The TLS certificates are updated automatically when Refresh
+// is not zero. Refresh cannot be less than one second to prevent
+// busy loops.
+type S3Source struct {
+	CertURL     string
+	ClientCAURL string
+	CAUpgradeCN string
+	Region      string
+	Refresh     time.Duration
+}
+
+func (s S3Source) LoadClientCAs() (*x509.CertPool, error) {
+	return newCertPool(s.ClientCAURL, s.CAUpgradeCN, s.load)
+}
+
+func (s S3Source) Certificates() chan []tls.Certificate {
+	ch := make(chan []tls.Certificate, 1)
+	go watch(ch, s.Refresh, s.CertURL, s.load)
+	return ch
+}
+
+func (s S3Source) load(listURL string) (map[string][]byte, error) {
+	if listURL == "" {
+		return nil, nil
+	}
+	region := s.region()
+	u, err := s3URL(listURL, region)
+	if err != nil {
+		return nil, fmt.Errorf("cert: %s", err)
+	}
+	fetch := func(url string) ([]byte, error) {
+		return s3Get(url, region)
+	}
+	return loadList(u, fetch)
+}
+
+// region returns the configured region or the region
+// from the environment. The default is us-east-1.
+func (s S3Source) region() string {
+	for _, r := range []string{s.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION")} {
+		if r != "" {
+			return r
+		}
+	}
+	return "us-east-1"
+}
+
+// s3URL converts an s3://bucket/path URL into the https URL
+// of the object in the region. Other URLs are returned unchanged.
+func s3URL(rawurl, region string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "s3":
+		if u.Host == "" {
+			return "", fmt.Errorf("missing bucket in %s", rawurl)
+		}
+		u.Scheme = "https"
+		u.Host = u.Host + ".s3." + region + ".amazonaws.com"
+		return u.String(), nil
+	case "http", "https":
+		return rawurl, nil
+	default:
+		return "", fmt.Errorf("invalid S3 URL %s", rawurl)
+	}
+}
+
+// s3Get fetches the object with a signed request.
+func s3Get(url, region string) ([]byte, error) {
+	creds, err := awsCreds.get()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	signV4(req, creds, region, "s3", time.Now())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, MaxSize))
+}
@@ -0,0 +1,78 @@
+package cert
+
+import (
+	"crypto/tls"
+	"errors"
+	"strings"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/metrics"
+)
+
+// errServerNameDenied is returned for handshakes with a server
+// name from the deny list of the listener.
+var errServerNameDenied = errors.New("cert: server name denied")
+
+// sniMatcher selects the certificate for a client hello according
+// to the strict match, default certificate and denied server names
+// of a listener.
+type sniMatcher struct {
+	strict      bool
+	defaultCert string
+	deny        []string
+
+	// rejected counts the handshakes without a matching certificate
+	// in strict mode and denied the handshakes for denied names.
+	rejected metrics.Counter
+	denied   metrics.Counter
+}
+
+func newSNIMatcher(l config.Listen) *sniMatcher {
+	prefix := "tls." + metrics.Clean(l.Addr)
+	return &sniMatcher{
+		strict:      l.StrictMatch,
+		defaultCert: l.DefaultCert,
+		deny:        l.DenySNI,
+		rejected:    metrics.DefaultRegistry.GetCounter(prefix + ".strictmatch_rejected"),
+		denied:      metrics.DefaultRegistry.GetCounter(prefix + ".sni_denied"),
+	}
+}
+
+// certificate returns the certificate for the server name of the
+// client hello. If no certificate matches the certificate for the
+// default name is used. Otherwise, the handshake is rejected in
+// strict mode or the first certificate is used.
+func (m *sniMatcher) certificate(cs certstore, clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if m.denies(clientHello.ServerName) {
+		m.denied.Inc(1)
+		return nil, errServerNameDenied
+	}
+
+	cert, err := getCertificate(cs, clientHello, m.strict || m.defaultCert != "")
+	if cert != nil || err != nil {
+		return cert, err
+	}
+	if m.defaultCert != "" {
+		cert, err = getCertificate(cs, &tls.ClientHelloInfo{ServerName: m.defaultCert}, true)
+		if cert != nil || err != nil {
+			return cert, err
+		}
+	}
+	if !m.strict {
+		return &cs.Certificates[0], nil
+	}
+	m.rejected.Inc(1)
+	return nil, nil
+}
+
+// denies returns true if the server name matches one of the denied
+// names. Names starting with '*.' match all subdomains.
+func (m *sniMatcher) denies(name string) bool {
+	name = strings.TrimRight(strings.ToLower(name), ".")
+	for _, d := range m.deny {
+		if d == name || (strings.HasPrefix(d, "*.") && strings.HasSuffix(name, d[1:])) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package cert
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSNIMatcher(t *testing.T) {
+	fooCert := makeCert("foo.com", time.Minute)
+	barCert := makeCert("bar.com", time.Minute)
+	cs := certstore{Certificates: []tls.Certificate{fooCert, barCert}}
+	cs.BuildNameToCertificate()
+
+	tests := []struct {
+		desc        string
+		strict      bool
+		defaultCert string
+		name        string
+		cert        *tls.Certificate
+		err         error
+		rejected    int64
+		denied      int64
+	}{
+		{desc: "match", name: "bar.com", cert: &barCert},
+		{desc: "fallback to first cert", name: "whiz.com", cert: &fooCert},
+		{desc: "fallback to default cert", defaultCert: "bar.com", name: "whiz.com", cert: &barCert},
+		{desc: "strict match", strict: true, name: "whiz.com", rejected: 1},
+		{desc: "strict match with default cert", strict: true, defaultCert: "bar.com", name: "whiz.com", cert: &barCert},
+		{desc: "strict match without server name", strict: true, defaultCert: "bar.com", name: "", cert: &barCert},
+		{desc: "strict match with unknown default cert", strict: true, defaultCert: "quux.com", name: "whiz.com", rejected: 1},
+		{desc: "denied name", name: "Evil.com.", err: errServerNameDenied, denied: 1},
+		{desc: "denied subdomain", name: "www.bad.com", err: errServerNameDenied, denied: 1},
+		{desc: "parent of denied subdomain", name: "bad.com", cert: &fooCert},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			rejected, denied := &countingCounter{}, &countingCounter{}
+			m := &sniMatcher{
+				strict:      tt.strict,
+				defaultCert: tt.defaultCert,
+				deny:        []string{"evil.com", "*.bad.com"},
+				rejected:    rejected,
+				denied:      denied,
+			}
+
+			cert, err := m.certificate(cs, &tls.ClientHelloInfo{ServerName: tt.name})
+			if got, want := err, tt.err; got != want {
+				t.Fatalf("got error %v want %v", got, want)
+			}
+			if got, want := cert, tt.cert; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %+v want %+v", got, want)
+			}
+			if got, want := rejected.n, tt.rejected; got != want {
+				t.Fatalf("got %d rejected want %d", got, want)
+			}
+			if got, want := denied.n, tt.denied; got != want {
+				t.Fatalf("got %d denied want %d", got, want)
+			}
+		})
+	}
+}
+
+type countingCounter struct {
+	n int64
+}
+
+func (c *countingCounter) Inc(n int64) { c.n += n }
@@ -5,6 +5,7 @@ import (
 	"crypto/x509"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/eBay/fabio/config"
 )
@@ -49,6 +50,15 @@ func NewSource(cfg config.CertSource) (Source, error) {
 			Refresh:     cfg.Refresh,
 		}, nil
 
+	case "s3":
+		return S3Source{
+			CertURL:     cfg.CertPath,
+			ClientCAURL: cfg.ClientCAPath,
+			CAUpgradeCN: cfg.CAUpgradeCN,
+			Region:      cfg.Region,
+			Refresh:     cfg.Refresh,
+		}, nil
+
 	case "consul":
 		return ConsulSource{
 			CertURL:     cfg.CertPath,
@@ -79,17 +89,38 @@ func NewSource(cfg config.CertSource) (Source, error) {
 // It also sets the ClientCAs field if
 // src.LoadClientCAs returns a non-nil value
 // and sets ClientAuth to RequireAndVerifyClientCert.
-func TLSConfig(src Source, strictMatch bool) (*tls.Config, error) {
+//
+// The TLS versions, cipher suites, curves and
+// the certificate selection for the server names
+// are taken from the listener config. If OCSP
+// stapling is enabled the OCSP responses for
+// the certificates are fetched and stapled.
+func TLSConfig(src Source, l config.Listen) (*tls.Config, error) {
 	clientCAs, err := src.LoadClientCAs()
 	if err != nil {
 		return nil, err
 	}
 
+	var stapler *ocspStapler
+	if l.OCSPStapling {
+		stapler = newOCSPStapler()
+	}
+
 	store := NewStore()
+	sni := newSNIMatcher(l)
 	x := &tls.Config{
 		GetCertificate: func(clientHello *tls.ClientHelloInfo) (cert *tls.Certificate, err error) {
-			return getCertificate(store.certstore(), clientHello, strictMatch)
+			cert, err = sni.certificate(store.certstore(), clientHello)
+			if stapler != nil {
+				cert = stapler.staple(cert)
+			}
+			return cert, err
 		},
+		MinVersion:               l.TLSMinVersion,
+		MaxVersion:               l.TLSMaxVersion,
+		CipherSuites:             l.TLSCiphers,
+		CurvePreferences:         l.TLSCurves,
+		PreferServerCipherSuites: l.TLSPreferServerCiphers,
 	}
 
 	if clientCAs != nil {
@@ -97,11 +128,51 @@ func TLSConfig(src Source, strictMatch bool) (*tls.Config, error) {
 		x.ClientAuth = tls.RequireAndVerifyClientCert
 	}
 
-	go func() {
-		for certs := range src.Certificates() {
-			store.SetCertificates(certs)
-		}
-	}()
+	go update(l.CertSource.Name, store, src, stapler)
 
 	return x, nil
 }
+
+// update stores the certificates of the source in the store
+// whenever they change, records them for the admin API and
+// updates the expiry gauges. If stapler
+// is not nil the OCSP responses for the certificates are fetched
+// and refreshed.
+func update(name string, store *Store, src Source, stapler *ocspStapler) {
+	var certs []tls.Certificate
+	ch := src.Certificates()
+	t := time.NewTicker(expiryInterval)
+	defer t.Stop()
+
+	var ocspc <-chan time.Time
+	if stapler != nil {
+		ot := time.NewTicker(ocspInterval)
+		defer ot.Stop()
+		ocspc = ot.C
+	}
+
+	for {
+		select {
+		case c, ok := <-ch:
+			if !ok {
+				if stapler == nil {
+					return
+				}
+				// keep refreshing the OCSP responses
+				ch = nil
+				continue
+			}
+			certs = c
+			store.SetCertificates(certs)
+			record(name, certs, time.Now())
+			if stapler != nil {
+				stapler.update(certs)
+			}
+		case <-ocspc:
+			stapler.update(certs)
+			continue
+		case <-t.C:
+		}
+		updateExpiryGauges(certs, time.Now())
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -136,6 +137,26 @@ func TestStaticSource(t *testing.T) {
 	testSource(t, StaticSource{cert}, makeCertPool(certPEM), 0)
 }
 
+func TestTLSConfigListenerSettings(t *testing.T) {
+	l := config.Listen{
+		TLSMinVersion:          tls.VersionTLS12,
+		TLSMaxVersion:          tls.VersionTLS13,
+		TLSCiphers:             []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		TLSCurves:              []tls.CurveID{tls.X25519},
+		TLSPreferServerCiphers: true,
+	}
+	x, err := TLSConfig(StaticSource{makeCert("localhost", time.Minute)}, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.MinVersion != l.TLSMinVersion || x.MaxVersion != l.TLSMaxVersion || !x.PreferServerCipherSuites {
+		t.Fatalf("got MinVersion=%x MaxVersion=%x PreferServerCipherSuites=%v", x.MinVersion, x.MaxVersion, x.PreferServerCipherSuites)
+	}
+	if !reflect.DeepEqual(x.CipherSuites, l.TLSCiphers) || !reflect.DeepEqual(x.CurvePreferences, l.TLSCurves) {
+		t.Fatalf("got CipherSuites=%v CurvePreferences=%v", x.CipherSuites, x.CurvePreferences)
+	}
+}
+
 func TestFileSource(t *testing.T) {
 	dir := tempDir()
 	defer os.RemoveAll(dir)
@@ -365,8 +386,7 @@ func TestVaultSource(t *testing.T) {
 // the HTTPS client can validate the certificate presented by the
 // server.
 func testSource(t *testing.T, source Source, rootCAs *x509.CertPool, sleep time.Duration) {
-	const NoStrictMatch = false
-	srvConfig, err := TLSConfig(source, NoStrictMatch)
+	srvConfig, err := TLSConfig(source, config.Listen{})
 	if err != nil {
 		t.Fatalf("TLSConfig: got %q want nil", err)
 	}
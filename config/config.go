@@ -1,6 +1,7 @@
 package config
 
 import (
+	"crypto/tls"
 	"net/http"
 	"regexp"
 	"time"
@@ -11,12 +12,23 @@ type Config struct {
 	Registry    Registry
 	Listen      []Listen
 	CertSources map[string]CertSource
+	AuthSources map[string]AuthSource
+	JWTIssuers  map[string]JWTIssuer
+	OIDC        map[string]OIDCProvider
+	ExtAuthz    map[string]ExtAuthz
 	Metrics     Metrics
+	Tracing     Tracing
+	Log         Log
+	Leaks       Leaks
 	UI          UI
 	Runtime     Runtime
 
 	ListenerValue    []string
 	CertSourcesValue []map[string]string
+	AuthSourcesValue []map[string]string
+	JWTIssuersValue  []map[string]string
+	OIDCValue        []map[string]string `json:"-"`
+	ExtAuthzValue    []map[string]string
 }
 
 type CertSource struct {
@@ -26,10 +38,69 @@ type CertSource struct {
 	KeyPath      string
 	ClientCAPath string
 	CAUpgradeCN  string
+	Region       string
 	Refresh      time.Duration
 	Header       http.Header
 }
 
+type AuthSource struct {
+	Name    string
+	Type    string
+	Path    string
+	Realm   string
+	Refresh time.Duration
+}
+
+// JWTIssuer configures the validation of the JSON web tokens for
+// the routes with the 'jwt' option. Claims contains the claims which
+// the token must have by name and the value or an empty value if the
+// claim only has to be present. Headers contains the names of the
+// request headers to which the claims are forwarded by claim name.
+type JWTIssuer struct {
+	Name     string
+	JWKS     string
+	Issuer   string
+	Audience string
+	Claims   map[string]string
+	Headers  map[string]string
+	Refresh  time.Duration
+}
+
+// OIDCProvider configures the OpenID Connect login for the routes
+// with the 'oidc' option. The endpoints of the provider are discovered
+// from the issuer URL. Claims and Headers have the same meaning as for
+// the JWTIssuer and apply to the ID token. CookieSecret signs the
+// session cookies and is generated on startup if it is empty. The
+// secrets are not included in the JSON of the configuration which is
+// logged and served by the admin API.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string `json:"-"`
+	CallbackPath string
+	Scopes       []string
+	CookieName   string
+	CookieSecret string `json:"-"`
+	Session      time.Duration
+	Claims       map[string]string
+	Headers      map[string]string
+}
+
+// ExtAuthz configures the external authorization service for the
+// routes with the 'extauthz' option. Headers contains the request
+// headers which are sent to the service and ResponseHeaders the
+// headers of an allowing response which are added to the request.
+// FailOpen allows the requests if the service cannot be reached.
+type ExtAuthz struct {
+	Name            string
+	URL             string
+	Timeout         time.Duration
+	FailOpen        bool
+	Headers         []string
+	ResponseHeaders []string
+}
+
 type Listen struct {
 	Addr         string
 	Proto        string
@@ -37,12 +108,66 @@ type Listen struct {
 	WriteTimeout time.Duration
 	CertSource   CertSource
 	StrictMatch  bool
+
+	// DefaultCert is the server name of the certificate which is
+	// used when no certificate matches the server name of the client
+	// hello. DenySNI contains the server names in lower case for which
+	// handshakes are rejected. Names starting with '*.' match all
+	// subdomains.
+	DefaultCert string
+	DenySNI     []string
+
+	// MaxIdleConns, IdleConnTimeout and DisableKeepAlives override
+	// the upstream transport settings of the proxy for the requests
+	// on this listener if they are set.
+	MaxIdleConns      int
+	IdleConnTimeout   time.Duration
+	DisableKeepAlives bool
+
+	// TLSMinVersion, TLSMaxVersion, TLSCiphers, TLSCurves and
+	// TLSPreferServerCiphers configure the TLS settings of an https
+	// listener. Zero values use the defaults of the Go TLS server.
+	TLSMinVersion          uint16
+	TLSMaxVersion          uint16
+	TLSCiphers             []uint16
+	TLSCurves              []tls.CurveID
+	TLSPreferServerCiphers bool
+
+	// OCSPStapling enables the stapling of OCSP responses
+	// for the certificates of an https listener.
+	OCSPStapling bool
+
+	// MaxConns limits the number of open connections and AcceptRate
+	// the number of new connections per second of the listener if
+	// they are not zero.
+	MaxConns   int
+	AcceptRate int
+
+	// MaxHeaderBytes limits the size of the request headers and
+	// ReadHeaderTimeout the time to read them. IdleTimeout closes
+	// keep-alive connections without requests. For TCP+SNI listeners
+	// the client hello must be read within ReadHeaderTimeout and
+	// connections without traffic are closed after IdleTimeout.
+	// Zero values use the defaults of the Go HTTP server or no
+	// timeout for TCP+SNI listeners.
+	MaxHeaderBytes    int
+	ReadHeaderTimeout time.Duration
+	IdleTimeout       time.Duration
+
+	// Redirect is the scheme to which all requests on an http
+	// listener are redirected except ACME challenges if it is not
+	// empty. RedirectPort is the port of the redirect URL or empty
+	// for the default port.
+	Redirect     string
+	RedirectPort string
 }
 
 type UI struct {
-	Addr  string
-	Color string
-	Title string
+	Addr     string
+	Color    string
+	Title    string
+	Lang     string
+	Timezone string
 }
 
 type Proxy struct {
@@ -54,6 +179,14 @@ type Proxy struct {
 	DialTimeout           time.Duration
 	ResponseHeaderTimeout time.Duration
 	KeepAliveTimeout      time.Duration
+	MaxIdleConns          int
+	IdleConnTimeout       time.Duration
+	DisableKeepAlives     bool
+	TLSCAPath             string
+	TLSCertPath           string
+	TLSKeyPath            string
+	TLSSkipVerify         bool
+	TLSCertSource         string
 	ReadTimeout           time.Duration
 	WriteTimeout          time.Duration
 	FlushInterval         time.Duration
@@ -61,6 +194,40 @@ type Proxy struct {
 	ClientIPHeader        string
 	TLSHeader             string
 	TLSHeaderValue        string
+	ClientCertCNHeader    string
+	ClientCertSANHeader   string
+	STSMaxAge             int
+	STSSubdomains         bool
+	STSPreload            bool
+	FrameOptions          string
+	ContentTypeOptions    string
+	CSP                   string
+	RequestIDHeader       string
+	ResponseHeadersValue  string
+	ResponseHeaders       map[string]string
+	ErrorPagesValue       string
+	ErrorPages            map[int]string
+	ExcludePaths          []string
+	IdempotencySize       int
+	IdempotencyTTL        time.Duration
+	CacheSize             int
+	RequestTimeoutHeader  string
+	RequestTimeoutTrusted []string
+	RequestTimeoutMax     time.Duration
+	ObserveOnly           bool
+	ObserveOnlyForward    string
+	Middleware            []string
+	NormalizeSlashes      bool
+	NormalizeHost         bool
+	TrailingSlash         string
+	SlowStart             time.Duration
+	OutlierMultiple       float64
+	OutlierPercentile     float64
+	OutlierMinRequests    int
+	OutlierWindow         time.Duration
+	OutlierInterval       time.Duration
+	OutlierCooldown       time.Duration
+	OutlierMaxEjection    float64
 	GZIPContentTypesValue string
 	GZIPContentTypes      *regexp.Regexp
 }
@@ -68,15 +235,23 @@ type Proxy struct {
 type Runtime struct {
 	GOGC       int
 	GOMAXPROCS int
+
+	// User and Group are the user and group to which fabio
+	// switches after the listeners have been opened.
+	User  string
+	Group string
 }
 
 type Metrics struct {
 	Target           string
 	Prefix           string
 	Names            string
+	RouteNames       string
 	Interval         time.Duration
+	Runtime          bool
 	GraphiteAddr     string
 	StatsDAddr       string
+	DogStatsDAddr    string
 	CirconusAPIKey   string
 	CirconusAPIApp   string
 	CirconusAPIURL   string
@@ -84,11 +259,42 @@ type Metrics struct {
 	CirconusBrokerID string
 }
 
+type Tracing struct {
+	Enabled     bool
+	Format      string
+	Collector   string
+	ServiceName string
+	SampleRate  float64
+	Interval    time.Duration
+}
+
+type Log struct {
+	AccessTarget string
+	AccessFormat string
+	AccessFile   string
+	ErrorFile    string
+}
+
+type Leaks struct {
+	Interval           time.Duration
+	GoroutineThreshold int
+	FDThreshold        int
+}
+
 type Registry struct {
 	Backend string
 	Static  Static
 	File    File
+	Remote  Remote
+	DNS     DNS
 	Consul  Consul
+
+	// Timeout is the time fabio waits for the backend on startup.
+	// The backend is retried every Retry and the proxy responds
+	// with 503 until the first routing table has been loaded.
+	// Zero disables the retries and the readiness gate.
+	Timeout time.Duration
+	Retry   time.Duration
 }
 
 type Static struct {
@@ -96,15 +302,56 @@ type Static struct {
 }
 
 type File struct {
-	Path string
+	Path    string
+	Refresh time.Duration
+}
+
+type Remote struct {
+	URL     string
+	Refresh time.Duration
+}
+
+type DNS struct {
+	SRVValue []map[string]string
+	SRV      []SRV
+	Refresh  time.Duration
+}
+
+// SRV describes the routes for the targets of a DNS SRV record.
+type SRV struct {
+	Name    string
+	Service string
+	Prefix  string
+	Scheme  string
+	Opts    string
+}
+
+// TagPrefix describes a prefix for consul tags which define routes and
+// the default route options for these routes.
+type TagPrefix struct {
+	Prefix string
+	Opts   string
 }
 
 type Consul struct {
 	Addr          string
 	Scheme        string
 	Token         string
+	Datacenter    string
+	Datacenters   []string
+	TagDatacenter bool
+	TLS           ConsulTLS
 	KVPath        string
+	ConfigKVPath  string
 	TagPrefix     string
+	MetaPrefix    string
+
+	// TagPrefixes contains the prefixes of tags which define routes
+	// ordered from the longest to the shortest prefix. It contains
+	// TagPrefix and the prefixes from registry.consul.tagprefixes.
+	TagPrefixesValue []map[string]string
+	TagPrefixes      []TagPrefix
+
 	Register      bool
 	ServiceAddr   string
 	ServiceName   string
@@ -112,4 +359,18 @@ type Consul struct {
 	ServiceStatus []string
 	CheckInterval time.Duration
 	CheckTimeout  time.Duration
+	CheckType     string
+	CheckTTL      time.Duration
+
+	// CheckDeregisterCriticalServiceAfter is the time after which
+	// consul removes the registration of fabio when its health check
+	// is critical. Zero keeps the registration.
+	CheckDeregisterCriticalServiceAfter time.Duration
+}
+
+type ConsulTLS struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
 }
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+// NewConsulClient creates a consul client with the ACL token,
+// datacenter and TLS settings from the config.
+func NewConsulClient(cfg *Consul) (*api.Client, error) {
+	c := &api.Config{
+		Address:    cfg.Addr,
+		Scheme:     cfg.Scheme,
+		Token:      cfg.Token,
+		Datacenter: cfg.Datacenter,
+	}
+
+	if cfg.TLS != (ConsulTLS{}) {
+		tlscfg, err := consulTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		tr := cleanhttp.DefaultPooledTransport()
+		tr.TLSClientConfig = tlscfg
+		c.HttpClient = &http.Client{Transport: tr}
+	}
+
+	return api.NewClient(c)
+}
+
+// consulTLSConfig creates the TLS client config for the consul API.
+func consulTLSConfig(cfg ConsulTLS) (*tls.Config, error) {
+	tlscfg := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	if cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("consul: no certificates found in " + cfg.CAFile)
+		}
+		tlscfg.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, errors.New("consul: client certificate requires both certfile and keyfile")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlscfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlscfg, nil
+}
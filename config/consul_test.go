@@ -0,0 +1,27 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestConsulTLSConfig(t *testing.T) {
+	tests := []struct {
+		cfg ConsulTLS
+		err bool
+	}{
+		{ConsulTLS{InsecureSkipVerify: true}, false},
+		{ConsulTLS{CAFile: "/does/not/exist"}, true},
+		{ConsulTLS{CertFile: "cert.pem"}, true},
+		{ConsulTLS{KeyFile: "key.pem"}, true},
+	}
+
+	for i, tt := range tests {
+		tlscfg, err := consulTLSConfig(tt.cfg)
+		if got, want := err != nil, tt.err; got != want {
+			t.Errorf("%d: got error %v want %v", i, err, want)
+		}
+		if err == nil && tlscfg.InsecureSkipVerify != tt.cfg.InsecureSkipVerify {
+			t.Errorf("%d: got InsecureSkipVerify %v want %v", i, tlscfg.InsecureSkipVerify, tt.cfg.InsecureSkipVerify)
+		}
+	}
+}
@@ -15,20 +15,42 @@ var Default = &Config{
 		DialTimeout:   30 * time.Second,
 		FlushInterval: time.Second,
 		LocalIP:       LocalIPString(),
+
+		IdempotencySize: 10000,
+		IdempotencyTTL:  time.Hour,
+		CacheSize:       1000,
+
+		OutlierPercentile:  0.99,
+		OutlierMinRequests: 20,
+		OutlierWindow:      time.Minute,
+		OutlierInterval:    10 * time.Second,
+		OutlierCooldown:    30 * time.Second,
+		OutlierMaxEjection: 0.5,
 	},
 	Registry: Registry{
 		Backend: "consul",
+		Retry:   500 * time.Millisecond,
+		Remote: Remote{
+			Refresh: 30 * time.Second,
+		},
+		DNS: DNS{
+			Refresh: 30 * time.Second,
+		},
 		Consul: Consul{
 			Addr:          "localhost:8500",
 			Scheme:        "http",
 			KVPath:        "/fabio/config",
 			TagPrefix:     "urlprefix-",
+			MetaPrefix:    "fabio-",
+			TagPrefixes:   []TagPrefix{{Prefix: "urlprefix-"}},
 			Register:      true,
 			ServiceAddr:   ":9998",
 			ServiceName:   "fabio",
 			ServiceStatus: []string{"passing"},
 			CheckInterval: time.Second,
 			CheckTimeout:  3 * time.Second,
+			CheckType:     "http",
+			CheckTTL:      15 * time.Second,
 		},
 	},
 	Runtime: Runtime{
@@ -36,14 +58,36 @@ var Default = &Config{
 		GOMAXPROCS: runtime.NumCPU(),
 	},
 	UI: UI{
-		Addr:  ":9998",
-		Color: "light-green",
+		Addr:     ":9998",
+		Color:    "light-green",
+		Lang:     "en",
+		Timezone: "Local",
 	},
 	Metrics: Metrics{
 		Prefix:         "{{clean .Hostname}}.{{clean .Exec}}",
 		Names:          "{{clean .Service}}.{{clean .Host}}.{{clean .Path}}.{{clean .TargetURL.Host}}",
 		Interval:       30 * time.Second,
+		Runtime:        true,
 		CirconusAPIApp: "fabio",
 	},
+	Tracing: Tracing{
+		Format:      "b3",
+		Collector:   "http://localhost:9411/api/v2/spans",
+		ServiceName: "fabio",
+		SampleRate:  1,
+		Interval:    time.Second,
+	},
+	Log: Log{
+		AccessFormat: "common",
+	},
+	Leaks: Leaks{
+		Interval:           time.Minute,
+		GoroutineThreshold: 100,
+		FDThreshold:        100,
+	},
 	CertSources: map[string]CertSource{},
+	AuthSources: map[string]AuthSource{},
+	JWTIssuers:  map[string]JWTIssuer{},
+	OIDC:        map[string]OIDCProvider{},
+	ExtAuthz:    map[string]ExtAuthz{},
 }
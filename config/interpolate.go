@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/magiconair/properties"
+)
+
+// varRE matches the ${env:VAR} and ${consul:key} expressions.
+var varRE = regexp.MustCompile(`\$\{(env|consul):([^}]+)\}`)
+
+// interpolate replaces ${env:VAR} in all property values with the
+// value of the environment variable VAR and ${consul:key} with the
+// value of the key in the consul KV store. Environment variables are
+// replaced first so that they can be used for the registry.consul.*
+// properties which configure the connection to consul. Unset
+// environment variables are replaced with an empty string and missing
+// consul keys are an error. The consul client uses the address, token,
+// datacenter and TLS settings of the registry.consul.* properties.
+func interpolate(p *properties.Properties) error {
+	// work on the raw values since the properties
+	// library expands ${key} on read
+	defer func(v bool) { p.DisableExpansion = v }(p.DisableExpansion)
+	p.DisableExpansion = true
+
+	replace := func(kind string, fn func(name string) (string, error)) error {
+		for _, k := range p.Keys() {
+			v, _ := p.Get(k)
+			if !strings.Contains(v, "${"+kind+":") {
+				continue
+			}
+			var err error
+			v = varRE.ReplaceAllStringFunc(v, func(s string) string {
+				m := varRE.FindStringSubmatch(s)
+				if m[1] != kind || err != nil {
+					return s
+				}
+				var val string
+				val, err = fn(m[2])
+				return val
+			})
+			if err != nil {
+				return fmt.Errorf("config: cannot expand %s. %s", k, err)
+			}
+			p.Set(k, v)
+		}
+		return nil
+	}
+
+	if err := replace("env", func(name string) (string, error) { return os.Getenv(name), nil }); err != nil {
+		return err
+	}
+
+	var kv *api.KV
+	return replace("consul", func(key string) (string, error) {
+		if kv == nil {
+			cfg, err := consulConfig(p)
+			if err != nil {
+				return "", err
+			}
+			c, err := NewConsulClient(cfg)
+			if err != nil {
+				return "", err
+			}
+			kv = c.KV()
+		}
+		pair, _, err := kv.Get(strings.TrimPrefix(key, "/"), nil)
+		if err != nil {
+			return "", err
+		}
+		if pair == nil {
+			return "", fmt.Errorf("consul key %q not found", key)
+		}
+		return string(pair.Value), nil
+	})
+}
+
+// consulConfig returns the settings of the consul client
+// from the registry.consul.* properties.
+func consulConfig(p *properties.Properties) (*Consul, error) {
+	get := func(key, def string) string {
+		if v, ok := p.Get(key); ok {
+			return v
+		}
+		return def
+	}
+
+	cfg := Default.Registry.Consul
+	cfg.Scheme, cfg.Addr = parseScheme(get("registry.consul.addr", cfg.Addr))
+	cfg.Token = get("registry.consul.token", cfg.Token)
+	cfg.Datacenter = get("registry.consul.dc", cfg.Datacenter)
+	cfg.TLS.CAFile = get("registry.consul.tls.cafile", cfg.TLS.CAFile)
+	cfg.TLS.CertFile = get("registry.consul.tls.certfile", cfg.TLS.CertFile)
+	cfg.TLS.KeyFile = get("registry.consul.tls.keyfile", cfg.TLS.KeyFile)
+	if v, ok := p.Get("registry.consul.tls.insecureskipverify"); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid registry.consul.tls.insecureskipverify %q", v)
+		}
+		cfg.TLS.InsecureSkipVerify = b
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/magiconair/properties"
+)
+
+func TestInterpolate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Query().Get("token"), "secret"; got != want {
+			t.Errorf("got token %q want %q", got, want)
+		}
+		if got, want := r.URL.Query().Get("dc"), "dc2"; got != want {
+			t.Errorf("got dc %q want %q", got, want)
+		}
+		switch r.URL.Path {
+		case "/v1/kv/fabio/collector":
+			w.Write([]byte(`[{"Key":"fabio/collector","Value":"aHR0cDovL3ppcGtpbg=="}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	os.Setenv("FABIO_TEST_TOKEN", "secret")
+	defer os.Unsetenv("FABIO_TEST_TOKEN")
+
+	p, err := properties.LoadString(strings.Join([]string{
+		"registry.consul.addr = " + srv.URL[len("http://"):],
+		"registry.consul.token = ${env:FABIO_TEST_TOKEN}",
+		"registry.consul.dc = dc2",
+		"tracing.collector = ${consul:/fabio/collector}/api",
+		"proxy.localip = ${env:FABIO_TEST_UNSET}",
+		"ui.title = ${ui.color}",
+		"ui.color = red",
+	}, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := interpolate(p); err != nil {
+		t.Fatalf("got %v want nil", err)
+	}
+
+	want := map[string]string{
+		"registry.consul.token": "secret",
+		"tracing.collector":     "http://zipkin/api",
+		"proxy.localip":         "",
+		"ui.title":              "red",
+	}
+	for k, v := range want {
+		if got := p.MustGet(k); got != v {
+			t.Errorf("%s: got %q want %q", k, got, v)
+		}
+	}
+
+	p.Set("tracing.collector", "${consul:missing}")
+	if err := interpolate(p); err == nil {
+		t.Fatal("got nil want error for missing key")
+	}
+
+	// the expansion setting of the caller is kept
+	p.Set("tracing.collector", "x")
+	p.DisableExpansion = true
+	if err := interpolate(p); err != nil || !p.DisableExpansion {
+		t.Fatalf("got %v, DisableExpansion %v want nil, true", err, p.DisableExpansion)
+	}
+}
+
+func TestConsulConfig(t *testing.T) {
+	p := properties.MustLoadString(strings.Join([]string{
+		"registry.consul.addr = https://consul:8501",
+		"registry.consul.dc = dc2",
+		"registry.consul.tls.cafile = ca.pem",
+		"registry.consul.tls.insecureskipverify = true",
+	}, "\n"))
+	cfg, err := consulConfig(p)
+	if err != nil {
+		t.Fatalf("got %v want nil", err)
+	}
+	if cfg.Scheme != "https" || cfg.Addr != "consul:8501" || cfg.Datacenter != "dc2" {
+		t.Errorf("got %s://%s in %q want https://consul:8501 in \"dc2\"", cfg.Scheme, cfg.Addr, cfg.Datacenter)
+	}
+	if want := (ConsulTLS{CAFile: "ca.pem", InsecureSkipVerify: true}); cfg.TLS != want {
+		t.Errorf("got TLS %+v want %+v", cfg.TLS, want)
+	}
+
+	p.Set("registry.consul.tls.insecureskipverify", "maybe")
+	if _, err := consulConfig(p); err == nil {
+		t.Fatal("got nil want error")
+	}
+}
@@ -1,21 +1,35 @@
 package config
 
 import (
+	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/magiconair/properties"
 )
 
 func Load() (cfg *Config, err error) {
+	return LoadWithOverrides(nil)
+}
+
+// LoadWithOverrides loads the configuration like Load but the values
+// in kv take precedence over the values from the config file. Values
+// from the environment and the command line still take precedence
+// over kv.
+func LoadWithOverrides(kv map[string]string) (cfg *Config, err error) {
 	var path string
 	for i, arg := range os.Args {
 
@@ -39,6 +53,14 @@ func Load() (cfg *Config, err error) {
 	if err != nil {
 		return nil, err
 	}
+	for k, v := range kv {
+		if _, _, err := p.Set(k, v); err != nil {
+			return nil, err
+		}
+	}
+	if err := interpolate(p); err != nil {
+		return nil, err
+	}
 
 	//
 	return load(p)
@@ -117,34 +139,111 @@ func load(p *properties.Properties) (cfg *Config, err error) {
 	f.DurationVar(&cfg.Proxy.DialTimeout, "proxy.dialtimeout", Default.Proxy.DialTimeout, "connection timeout for backend connections")
 	f.DurationVar(&cfg.Proxy.ResponseHeaderTimeout, "proxy.responseheadertimeout", Default.Proxy.ResponseHeaderTimeout, "response header timeout")
 	f.DurationVar(&cfg.Proxy.KeepAliveTimeout, "proxy.keepalivetimeout", Default.Proxy.KeepAliveTimeout, "keep-alive timeout")
+	f.IntVar(&cfg.Proxy.MaxIdleConns, "proxy.maxidleconns", Default.Proxy.MaxIdleConns, "maximum number of idle upstream connections")
+	f.DurationVar(&cfg.Proxy.IdleConnTimeout, "proxy.idleconntimeout", Default.Proxy.IdleConnTimeout, "idle timeout of upstream connections")
+	f.BoolVar(&cfg.Proxy.DisableKeepAlives, "proxy.disablekeepalives", Default.Proxy.DisableKeepAlives, "disable keep-alive for upstream connections")
+	f.StringVar(&cfg.Proxy.TLSCAPath, "proxy.tls.ca", Default.Proxy.TLSCAPath, "path to the CA bundle for https upstream servers")
+	f.StringVar(&cfg.Proxy.TLSCertPath, "proxy.tls.cert", Default.Proxy.TLSCertPath, "path to the client certificate for https upstream servers")
+	f.StringVar(&cfg.Proxy.TLSKeyPath, "proxy.tls.key", Default.Proxy.TLSKeyPath, "path to the client key for https upstream servers")
+	f.StringVar(&cfg.Proxy.TLSCertSource, "proxy.tls.cs", Default.Proxy.TLSCertSource, "name of the certificate source with the client certificate for https upstream servers")
+	f.BoolVar(&cfg.Proxy.TLSSkipVerify, "proxy.tls.skipverify", Default.Proxy.TLSSkipVerify, "skip the verification of upstream certificates")
 	f.StringVar(&cfg.Proxy.LocalIP, "proxy.localip", Default.Proxy.LocalIP, "fabio address in Forward headers")
 	f.StringVar(&cfg.Proxy.ClientIPHeader, "proxy.header.clientip", Default.Proxy.ClientIPHeader, "header for the request ip")
 	f.StringVar(&cfg.Proxy.TLSHeader, "proxy.header.tls", Default.Proxy.TLSHeader, "header for TLS connections")
 	f.StringVar(&cfg.Proxy.TLSHeaderValue, "proxy.header.tls.value", Default.Proxy.TLSHeaderValue, "value for TLS connection header")
+	f.StringVar(&cfg.Proxy.ClientCertCNHeader, "proxy.header.clientcert.cn", Default.Proxy.ClientCertCNHeader, "header for the common name of the verified client certificate")
+	f.StringVar(&cfg.Proxy.ClientCertSANHeader, "proxy.header.clientcert.san", Default.Proxy.ClientCertSANHeader, "header for the subject alternative names of the verified client certificate")
+	f.IntVar(&cfg.Proxy.STSMaxAge, "proxy.header.sts.maxage", Default.Proxy.STSMaxAge, "max-age of the Strict-Transport-Security header in seconds")
+	f.BoolVar(&cfg.Proxy.STSSubdomains, "proxy.header.sts.subdomains", Default.Proxy.STSSubdomains, "add includeSubDomains to the Strict-Transport-Security header")
+	f.BoolVar(&cfg.Proxy.STSPreload, "proxy.header.sts.preload", Default.Proxy.STSPreload, "add preload to the Strict-Transport-Security header")
+	f.StringVar(&cfg.Proxy.FrameOptions, "proxy.header.frameoptions", Default.Proxy.FrameOptions, "value of the X-Frame-Options header")
+	f.StringVar(&cfg.Proxy.ContentTypeOptions, "proxy.header.contenttypeoptions", Default.Proxy.ContentTypeOptions, "value of the X-Content-Type-Options header")
+	f.StringVar(&cfg.Proxy.CSP, "proxy.header.csp", Default.Proxy.CSP, "value of the Content-Security-Policy header")
+	f.StringVar(&cfg.Proxy.RequestIDHeader, "proxy.requestid.header", Default.Proxy.RequestIDHeader, "header for the request id")
+	f.StringVar(&cfg.Proxy.ResponseHeadersValue, "proxy.header.response", Default.Proxy.ResponseHeadersValue, "response header templates")
+	f.StringVar(&cfg.Proxy.ErrorPagesValue, "proxy.errorpages", Default.Proxy.ErrorPagesValue, "files with custom pages for error responses by status code")
+	f.StringSliceVar(&cfg.Proxy.ExcludePaths, "proxy.exclude.paths", Default.Proxy.ExcludePaths, "paths excluded from access log and request metrics")
+	f.IntVar(&cfg.Proxy.IdempotencySize, "proxy.idempotency.size", Default.Proxy.IdempotencySize, "maximum number of stored responses for idempotency keys")
+	f.DurationVar(&cfg.Proxy.IdempotencyTTL, "proxy.idempotency.ttl", Default.Proxy.IdempotencyTTL, "time responses for idempotency keys are stored")
+	f.IntVar(&cfg.Proxy.CacheSize, "proxy.cache.size", Default.Proxy.CacheSize, "maximum number of cached responses for routes with the cache option")
+	f.StringVar(&cfg.Proxy.RequestTimeoutHeader, "proxy.requesttimeout.header", Default.Proxy.RequestTimeoutHeader, "header with the request timeout of trusted clients")
+	f.StringSliceVar(&cfg.Proxy.RequestTimeoutTrusted, "proxy.requesttimeout.trusted", Default.Proxy.RequestTimeoutTrusted, "networks of the clients whose request timeout header is honored")
+	f.DurationVar(&cfg.Proxy.RequestTimeoutMax, "proxy.requesttimeout.max", Default.Proxy.RequestTimeoutMax, "maximum request timeout from the request timeout header")
+	f.BoolVar(&cfg.Proxy.ObserveOnly, "proxy.observeonly", Default.Proxy.ObserveOnly, "build the routing table but do not proxy requests to the targets")
+	f.StringVar(&cfg.Proxy.ObserveOnlyForward, "proxy.observeonly.forward", Default.Proxy.ObserveOnlyForward, "URL of a fabio instance which handles the requests in observe-only mode")
+	f.StringSliceVar(&cfg.Proxy.Middleware, "proxy.middleware", Default.Proxy.Middleware, "order of the middleware which handles the requests before they are proxied")
+	f.BoolVar(&cfg.Proxy.NormalizeSlashes, "proxy.normalize.slashes", Default.Proxy.NormalizeSlashes, "collapse duplicate slashes in the request path before matching")
+	f.BoolVar(&cfg.Proxy.NormalizeHost, "proxy.normalize.host", Default.Proxy.NormalizeHost, "send the host header in lower case upstream")
+	f.StringVar(&cfg.Proxy.TrailingSlash, "proxy.normalize.trailingslash", Default.Proxy.TrailingSlash, "redirect to add or remove the trailing slash of the request path")
+	f.DurationVar(&cfg.Proxy.SlowStart, "proxy.slowstart", Default.Proxy.SlowStart, "time in which the weight of new targets increases to their full weight")
+	f.Float64Var(&cfg.Proxy.OutlierMultiple, "proxy.outlier.multiple", Default.Proxy.OutlierMultiple, "factor by which the latency of a target must exceed the route median to be ejected")
+	f.Float64Var(&cfg.Proxy.OutlierPercentile, "proxy.outlier.percentile", Default.Proxy.OutlierPercentile, "latency percentile for the outlier detection")
+	f.IntVar(&cfg.Proxy.OutlierMinRequests, "proxy.outlier.minrequests", Default.Proxy.OutlierMinRequests, "minimum number of requests of a target for the outlier detection")
+	f.DurationVar(&cfg.Proxy.OutlierWindow, "proxy.outlier.window", Default.Proxy.OutlierWindow, "time span of the latencies for the outlier detection")
+	f.DurationVar(&cfg.Proxy.OutlierInterval, "proxy.outlier.interval", Default.Proxy.OutlierInterval, "interval of the outlier detection")
+	f.DurationVar(&cfg.Proxy.OutlierCooldown, "proxy.outlier.cooldown", Default.Proxy.OutlierCooldown, "time for which outliers are ejected")
+	f.Float64Var(&cfg.Proxy.OutlierMaxEjection, "proxy.outlier.maxejection", Default.Proxy.OutlierMaxEjection, "maximum fraction of the targets of a route which can be ejected")
 	f.StringVar(&cfg.Proxy.GZIPContentTypesValue, "proxy.gzip.contenttype", Default.Proxy.GZIPContentTypesValue, "regexp of content types to compress")
 	f.StringSliceVar(&cfg.ListenerValue, "proxy.addr", Default.ListenerValue, "listener config")
 	f.KVSliceVar(&cfg.CertSourcesValue, "proxy.cs", Default.CertSourcesValue, "certificate sources")
+	f.KVSliceVar(&cfg.AuthSourcesValue, "proxy.auth", Default.AuthSourcesValue, "basic auth credential sources")
+	f.KVSliceVar(&cfg.JWTIssuersValue, "proxy.jwt", Default.JWTIssuersValue, "JSON web token issuers")
+	f.KVSliceVar(&cfg.OIDCValue, "proxy.oidc", Default.OIDCValue, "OpenID Connect providers")
+	f.KVSliceVar(&cfg.ExtAuthzValue, "proxy.extauthz", Default.ExtAuthzValue, "external authorization services")
 	f.DurationVar(&cfg.Proxy.ReadTimeout, "proxy.readtimeout", Default.Proxy.ReadTimeout, "read timeout for incoming requests")
 	f.DurationVar(&cfg.Proxy.WriteTimeout, "proxy.writetimeout", Default.Proxy.WriteTimeout, "write timeout for outgoing responses")
 	f.DurationVar(&cfg.Proxy.FlushInterval, "proxy.flushinterval", Default.Proxy.FlushInterval, "flush interval for streaming responses")
 	f.StringVar(&cfg.Metrics.Target, "metrics.target", Default.Metrics.Target, "metrics backend")
 	f.StringVar(&cfg.Metrics.Prefix, "metrics.prefix", Default.Metrics.Prefix, "prefix for reported metrics")
 	f.StringVar(&cfg.Metrics.Names, "metrics.names", Default.Metrics.Names, "route metric name template")
+	f.StringVar(&cfg.Metrics.RouteNames, "metrics.routenames", Default.Metrics.RouteNames, "template for the names of the per route metrics")
 	f.DurationVar(&cfg.Metrics.Interval, "metrics.interval", Default.Metrics.Interval, "metrics reporting interval")
+	f.BoolVar(&cfg.Metrics.Runtime, "metrics.runtime", Default.Metrics.Runtime, "report go runtime metrics")
 	f.StringVar(&cfg.Metrics.GraphiteAddr, "metrics.graphite.addr", Default.Metrics.GraphiteAddr, "graphite server address")
 	f.StringVar(&cfg.Metrics.StatsDAddr, "metrics.statsd.addr", Default.Metrics.StatsDAddr, "statsd server address")
+	f.StringVar(&cfg.Metrics.DogStatsDAddr, "metrics.dogstatsd.addr", Default.Metrics.DogStatsDAddr, "dogstatsd server address")
 	f.StringVar(&cfg.Metrics.CirconusAPIKey, "metrics.circonus.apikey", Default.Metrics.CirconusAPIKey, "Circonus API token key")
 	f.StringVar(&cfg.Metrics.CirconusAPIApp, "metrics.circonus.apiapp", Default.Metrics.CirconusAPIApp, "Circonus API token app")
 	f.StringVar(&cfg.Metrics.CirconusAPIURL, "metrics.circonus.apiurl", Default.Metrics.CirconusAPIURL, "Circonus API URL")
 	f.StringVar(&cfg.Metrics.CirconusBrokerID, "metrics.circonus.brokerid", Default.Metrics.CirconusBrokerID, "Circonus Broker ID")
 	f.StringVar(&cfg.Metrics.CirconusCheckID, "metrics.circonus.checkid", Default.Metrics.CirconusCheckID, "Circonus Check ID")
+	f.BoolVar(&cfg.Tracing.Enabled, "tracing.enabled", Default.Tracing.Enabled, "enable request tracing")
+	f.StringVar(&cfg.Tracing.Format, "tracing.format", Default.Tracing.Format, "trace header format: b3 or w3c")
+	f.StringVar(&cfg.Tracing.Collector, "tracing.collector", Default.Tracing.Collector, "URL of the zipkin compatible span collector")
+	f.StringVar(&cfg.Tracing.ServiceName, "tracing.servicename", Default.Tracing.ServiceName, "service name for the reported spans")
+	f.Float64Var(&cfg.Tracing.SampleRate, "tracing.samplerate", Default.Tracing.SampleRate, "sample rate for new traces between 0 and 1")
+	f.DurationVar(&cfg.Tracing.Interval, "tracing.interval", Default.Tracing.Interval, "span reporting interval")
+	f.StringVar(&cfg.Log.AccessTarget, "log.access.target", Default.Log.AccessTarget, "access log target")
+	f.StringVar(&cfg.Log.AccessFormat, "log.access.format", Default.Log.AccessFormat, "access log format")
+	f.StringVar(&cfg.Log.AccessFile, "log.access.file", Default.Log.AccessFile, "path to the access log file for log.access.target = file")
+	f.StringVar(&cfg.Log.ErrorFile, "log.error.file", Default.Log.ErrorFile, "path to the log file for the fabio log messages")
+	f.DurationVar(&cfg.Leaks.Interval, "leaks.interval", Default.Leaks.Interval, "leak detection interval")
+	f.IntVar(&cfg.Leaks.GoroutineThreshold, "leaks.threshold.goroutines", Default.Leaks.GoroutineThreshold, "goroutine increase per interval which is reported as leak")
+	f.IntVar(&cfg.Leaks.FDThreshold, "leaks.threshold.fds", Default.Leaks.FDThreshold, "open file descriptor increase per interval which is reported as leak")
 	f.StringVar(&cfg.Registry.Backend, "registry.backend", Default.Registry.Backend, "registry backend")
+	f.DurationVar(&cfg.Registry.Timeout, "registry.timeout", Default.Registry.Timeout, "time to wait for the registry backend and the first routing table on startup")
+	f.DurationVar(&cfg.Registry.Retry, "registry.retry", Default.Registry.Retry, "interval for connecting to the registry backend on startup")
 	f.StringVar(&cfg.Registry.File.Path, "registry.file.path", Default.Registry.File.Path, "path to file based routing table")
+	f.DurationVar(&cfg.Registry.File.Refresh, "registry.file.refresh", Default.Registry.File.Refresh, "interval for reloading the file based routing table")
+	f.StringVar(&cfg.Registry.Remote.URL, "registry.remote.url", Default.Registry.Remote.URL, "url of the remote routing table")
+	f.DurationVar(&cfg.Registry.Remote.Refresh, "registry.remote.refresh", Default.Registry.Remote.Refresh, "interval for fetching the remote routing table")
+	f.KVSliceVar(&cfg.Registry.DNS.SRVValue, "registry.dns.srv", Default.Registry.DNS.SRVValue, "DNS SRV records for routes")
+	f.DurationVar(&cfg.Registry.DNS.Refresh, "registry.dns.refresh", Default.Registry.DNS.Refresh, "interval for resolving the DNS SRV records")
 	f.StringVar(&cfg.Registry.Static.Routes, "registry.static.routes", Default.Registry.Static.Routes, "static routes")
 	f.StringVar(&cfg.Registry.Consul.Addr, "registry.consul.addr", Default.Registry.Consul.Addr, "address of the consul agent")
 	f.StringVar(&cfg.Registry.Consul.Token, "registry.consul.token", Default.Registry.Consul.Token, "token for consul agent")
+	f.StringVar(&cfg.Registry.Consul.Datacenter, "registry.consul.dc", Default.Registry.Consul.Datacenter, "consul datacenter to query")
+	f.StringSliceVar(&cfg.Registry.Consul.Datacenters, "registry.consul.dcs", Default.Registry.Consul.Datacenters, "consul datacenters to aggregate services from")
+	f.BoolVar(&cfg.Registry.Consul.TagDatacenter, "registry.consul.dcs.tag", Default.Registry.Consul.TagDatacenter, "tag targets with their datacenter")
+	f.StringVar(&cfg.Registry.Consul.TLS.CAFile, "registry.consul.tls.cafile", Default.Registry.Consul.TLS.CAFile, "path to the CA certificate for the consul agent")
+	f.StringVar(&cfg.Registry.Consul.TLS.CertFile, "registry.consul.tls.certfile", Default.Registry.Consul.TLS.CertFile, "path to the client certificate for the consul agent")
+	f.StringVar(&cfg.Registry.Consul.TLS.KeyFile, "registry.consul.tls.keyfile", Default.Registry.Consul.TLS.KeyFile, "path to the client key for the consul agent")
+	f.BoolVar(&cfg.Registry.Consul.TLS.InsecureSkipVerify, "registry.consul.tls.insecureskipverify", Default.Registry.Consul.TLS.InsecureSkipVerify, "disable verification of the consul agent certificate")
 	f.StringVar(&cfg.Registry.Consul.KVPath, "registry.consul.kvpath", Default.Registry.Consul.KVPath, "consul KV path for manual overrides")
+	f.StringVar(&cfg.Registry.Consul.ConfigKVPath, "registry.consul.config.kvpath", Default.Registry.Consul.ConfigKVPath, "consul KV path for config overrides")
 	f.StringVar(&cfg.Registry.Consul.TagPrefix, "registry.consul.tagprefix", Default.Registry.Consul.TagPrefix, "prefix for consul tags")
+	f.KVSliceVar(&cfg.Registry.Consul.TagPrefixesValue, "registry.consul.tagprefixes", Default.Registry.Consul.TagPrefixesValue, "additional prefixes for consul tags with default route options")
+	f.StringVar(&cfg.Registry.Consul.MetaPrefix, "registry.consul.metaprefix", Default.Registry.Consul.MetaPrefix, "prefix for consul metadata keys with route options")
 	f.BoolVar(&cfg.Registry.Consul.Register, "registry.consul.register.enabled", Default.Registry.Consul.Register, "register fabio in consul")
 	f.StringVar(&cfg.Registry.Consul.ServiceAddr, "registry.consul.register.addr", Default.Registry.Consul.ServiceAddr, "service registration address")
 	f.StringVar(&cfg.Registry.Consul.ServiceName, "registry.consul.register.name", Default.Registry.Consul.ServiceName, "service registration name")
@@ -152,11 +251,18 @@ func load(p *properties.Properties) (cfg *Config, err error) {
 	f.StringSliceVar(&cfg.Registry.Consul.ServiceStatus, "registry.consul.service.status", Default.Registry.Consul.ServiceStatus, "valid service status values")
 	f.DurationVar(&cfg.Registry.Consul.CheckInterval, "registry.consul.register.checkInterval", Default.Registry.Consul.CheckInterval, "service check interval")
 	f.DurationVar(&cfg.Registry.Consul.CheckTimeout, "registry.consul.register.checkTimeout", Default.Registry.Consul.CheckTimeout, "service check timeout")
+	f.StringVar(&cfg.Registry.Consul.CheckType, "registry.consul.register.checkType", Default.Registry.Consul.CheckType, "service check type: http or ttl")
+	f.DurationVar(&cfg.Registry.Consul.CheckTTL, "registry.consul.register.checkTTL", Default.Registry.Consul.CheckTTL, "TTL of the service check")
+	f.DurationVar(&cfg.Registry.Consul.CheckDeregisterCriticalServiceAfter, "registry.consul.register.checkDeregisterCriticalServiceAfter", Default.Registry.Consul.CheckDeregisterCriticalServiceAfter, "time after which a critical service is deregistered")
 	f.IntVar(&cfg.Runtime.GOGC, "runtime.gogc", Default.Runtime.GOGC, "sets runtime.GOGC")
 	f.IntVar(&cfg.Runtime.GOMAXPROCS, "runtime.gomaxprocs", Default.Runtime.GOMAXPROCS, "sets runtime.GOMAXPROCS")
+	f.StringVar(&cfg.Runtime.User, "runtime.user", Default.Runtime.User, "user to switch to after the listeners have been opened")
+	f.StringVar(&cfg.Runtime.Group, "runtime.group", Default.Runtime.Group, "group to switch to after the listeners have been opened")
 	f.StringVar(&cfg.UI.Addr, "ui.addr", Default.UI.Addr, "address the UI/API is listening on")
 	f.StringVar(&cfg.UI.Color, "ui.color", Default.UI.Color, "background color of the UI")
 	f.StringVar(&cfg.UI.Title, "ui.title", Default.UI.Title, "optional title for the UI")
+	f.StringVar(&cfg.UI.Lang, "ui.lang", Default.UI.Lang, "default language of the UI")
+	f.StringVar(&cfg.UI.Timezone, "ui.timezone", Default.UI.Timezone, "timezone for timestamps in the UI")
 
 	var awsApiGWCertCN string
 	f.StringVar(&awsApiGWCertCN, "aws.apigw.cert.cn", "", "deprecated. use caupgcn=<CN> for cert source")
@@ -188,11 +294,122 @@ func load(p *properties.Properties) (cfg *Config, err error) {
 		return nil, err
 	}
 
+	cfg.AuthSources, err = parseAuthSources(cfg.AuthSourcesValue)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.JWTIssuers, err = parseJWTIssuers(cfg.JWTIssuersValue)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.OIDC, err = parseOIDCProviders(cfg.OIDCValue)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ExtAuthz, err = parseExtAuthzs(cfg.ExtAuthzValue)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Registry.DNS.SRV, err = parseSRVs(cfg.Registry.DNS.SRVValue)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Registry.Consul.TagPrefixes, err = parseTagPrefixes(cfg.Registry.Consul.TagPrefix, cfg.Registry.Consul.TagPrefixesValue)
+	if err != nil {
+		return nil, err
+	}
+
 	cfg.Listen, err = parseListeners(cfg.ListenerValue, cfg.CertSources, cfg.Proxy.ReadTimeout, cfg.Proxy.WriteTimeout)
 	if err != nil {
 		return nil, err
 	}
 
+	if cfg.Tracing.Format != "b3" && cfg.Tracing.Format != "w3c" {
+		return nil, fmt.Errorf("invalid trace header format %q", cfg.Tracing.Format)
+	}
+
+	if cfg.Tracing.SampleRate < 0 || cfg.Tracing.SampleRate > 1 {
+		return nil, fmt.Errorf("tracing.samplerate must be between 0 and 1")
+	}
+
+	for _, s := range cfg.Proxy.RequestTimeoutTrusted {
+		if _, _, err := net.ParseCIDR(s); err != nil && net.ParseIP(s) == nil {
+			return nil, fmt.Errorf("invalid network %q in proxy.requesttimeout.trusted", s)
+		}
+	}
+
+	if (cfg.Proxy.TLSCertPath == "") != (cfg.Proxy.TLSKeyPath == "") {
+		return nil, errors.New("proxy.tls.cert and proxy.tls.key must be set together")
+	}
+
+	if name := cfg.Proxy.TLSCertSource; name != "" {
+		if _, ok := cfg.CertSources[name]; !ok {
+			return nil, fmt.Errorf("unknown certificate source %q in proxy.tls.cs", name)
+		}
+	}
+
+	if s := cfg.Proxy.ObserveOnlyForward; s != "" {
+		u, err := url.Parse(s)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid proxy.observeonly.forward %q", s)
+		}
+	}
+
+	if cfg.Proxy.OutlierMultiple > 0 {
+		switch {
+		case cfg.Proxy.OutlierPercentile <= 0 || cfg.Proxy.OutlierPercentile > 1:
+			return nil, errors.New("proxy.outlier.percentile must be between 0 and 1")
+		case cfg.Proxy.OutlierMaxEjection < 0 || cfg.Proxy.OutlierMaxEjection > 1:
+			return nil, errors.New("proxy.outlier.maxejection must be between 0 and 1")
+		case cfg.Proxy.OutlierInterval <= 0:
+			return nil, errors.New("proxy.outlier.interval must be positive")
+		}
+	}
+
+	switch cfg.Registry.Consul.CheckType {
+	case "http", "ttl":
+	default:
+		return nil, fmt.Errorf("invalid registry.consul.register.checkType %q", cfg.Registry.Consul.CheckType)
+	}
+	if cfg.Registry.Consul.CheckType == "ttl" && cfg.Registry.Consul.CheckTTL <= 0 {
+		return nil, errors.New("registry.consul.register.checkTTL must be positive")
+	}
+
+	switch cfg.Proxy.TrailingSlash {
+	case "", "add", "remove":
+	default:
+		return nil, fmt.Errorf("invalid proxy.normalize.trailingslash %q", cfg.Proxy.TrailingSlash)
+	}
+
+	if _, err := time.LoadLocation(cfg.UI.Timezone); err != nil {
+		return nil, fmt.Errorf("invalid ui.timezone: %s", err)
+	}
+
+	switch cfg.Log.AccessTarget {
+	case "", "stdout":
+	case "file":
+		if cfg.Log.AccessFile == "" {
+			return nil, fmt.Errorf("log.access.file required for access log target %q", cfg.Log.AccessTarget)
+		}
+	default:
+		return nil, fmt.Errorf("invalid access log target %q", cfg.Log.AccessTarget)
+	}
+
+	cfg.Proxy.ResponseHeaders, err = parseResponseHeaders(cfg.Proxy.ResponseHeadersValue)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Proxy.ErrorPages, err = parseErrorPages(cfg.Proxy.ErrorPagesValue)
+	if err != nil {
+		return nil, err
+	}
+
 	if cfg.Proxy.GZIPContentTypesValue != "" {
 		cfg.Proxy.GZIPContentTypes, err = regexp.Compile(cfg.Proxy.GZIPContentTypesValue)
 		if err != nil {
@@ -234,6 +451,13 @@ func parseListeners(cfgs []string, cs map[string]CertSource, readTimeout, writeT
 	return
 }
 
+// ParseListen parses a listener configuration in the format of
+// proxy.addr with the certificate sources and default timeouts
+// of the config.
+func ParseListen(cfg *Config, s string) (Listen, error) {
+	return parseListen(s, cfg.CertSources, cfg.Proxy.ReadTimeout, cfg.Proxy.WriteTimeout)
+}
+
 func parseListen(cfg string, cs map[string]CertSource, readTimeout, writeTimeout time.Duration) (l Listen, err error) {
 	if cfg == "" {
 		return Listen{}, nil
@@ -255,7 +479,7 @@ func parseListen(cfg string, cs map[string]CertSource, readTimeout, writeTimeout
 		switch k {
 		case "proto":
 			l.Proto = v
-			if l.Proto != "http" && l.Proto != "https" && l.Proto != "tcp+sni" {
+			if l.Proto != "http" && l.Proto != "https" && l.Proto != "tcp+sni" && l.Proto != "unix" {
 				return Listen{}, fmt.Errorf("unknown protocol %q", v)
 			}
 		case "rt": // read timeout
@@ -282,6 +506,94 @@ func parseListen(cfg string, cs map[string]CertSource, readTimeout, writeTimeout
 			}
 		case "strictmatch":
 			l.StrictMatch = (v == "true")
+		case "defaultcert":
+			l.DefaultCert = strings.ToLower(v)
+		case "denysni":
+			for _, name := range strings.Split(v, ":") {
+				if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+					l.DenySNI = append(l.DenySNI, name)
+				}
+			}
+		case "maxidleconns":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return Listen{}, fmt.Errorf("invalid maxidleconns %q", v)
+			}
+			l.MaxIdleConns = n
+		case "idleconntimeout":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return Listen{}, err
+			}
+			l.IdleConnTimeout = d
+		case "disablekeepalives":
+			l.DisableKeepAlives = (v == "true")
+		case "tlsmin":
+			l.TLSMinVersion, err = parseTLSVersion(v)
+			if err != nil {
+				return Listen{}, err
+			}
+		case "tlsmax":
+			l.TLSMaxVersion, err = parseTLSVersion(v)
+			if err != nil {
+				return Listen{}, err
+			}
+		case "ciphers":
+			l.TLSCiphers, err = parseTLSCiphers(v)
+			if err != nil {
+				return Listen{}, err
+			}
+		case "curves":
+			l.TLSCurves, err = parseTLSCurves(v)
+			if err != nil {
+				return Listen{}, err
+			}
+		case "preferserverciphers":
+			l.TLSPreferServerCiphers = (v == "true")
+		case "ocsp":
+			l.OCSPStapling = (v == "true")
+		case "maxconns":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return Listen{}, fmt.Errorf("invalid maxconns %q", v)
+			}
+			l.MaxConns = n
+		case "acceptrate":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return Listen{}, fmt.Errorf("invalid acceptrate %q", v)
+			}
+			l.AcceptRate = n
+		case "maxheaderbytes":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return Listen{}, fmt.Errorf("invalid maxheaderbytes %q", v)
+			}
+			l.MaxHeaderBytes = n
+		case "readheadertimeout":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return Listen{}, err
+			}
+			l.ReadHeaderTimeout = d
+		case "idletimeout":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return Listen{}, err
+			}
+			l.IdleTimeout = d
+		case "redirect":
+			p := strings.SplitN(v, ":", 2)
+			if p[0] != "https" {
+				return Listen{}, fmt.Errorf("invalid redirect %q", v)
+			}
+			l.Redirect = p[0]
+			if len(p) == 2 {
+				if n, err := strconv.Atoi(p[1]); err != nil || n <= 0 || n > 65535 {
+					return Listen{}, fmt.Errorf("invalid redirect port %q", p[1])
+				}
+				l.RedirectPort = p[1]
+			}
 		}
 	}
 
@@ -294,10 +606,82 @@ func parseListen(cfg string, cs map[string]CertSource, readTimeout, writeTimeout
 	if csName == "" && l.Proto == "https" {
 		return Listen{}, fmt.Errorf("proto 'https' requires cert source")
 	}
+	if (l.DefaultCert != "" || len(l.DenySNI) > 0) && l.Proto != "https" {
+		return Listen{}, fmt.Errorf("defaultcert and denysni require proto 'https'")
+	}
+	if l.Redirect != "" && l.Proto != "http" {
+		return Listen{}, fmt.Errorf("redirect requires proto 'http'")
+	}
+	if l.MaxHeaderBytes > 0 && l.Proto == "tcp+sni" {
+		return Listen{}, fmt.Errorf("maxheaderbytes not supported for proto 'tcp+sni'")
+	}
+	if l.TLSMinVersion > 0 && l.TLSMaxVersion > 0 && l.TLSMinVersion > l.TLSMaxVersion {
+		return Listen{}, fmt.Errorf("tlsmin must not be greater than tlsmax")
+	}
 
 	return
 }
 
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseTLSVersion parses a TLS version like '1.2'.
+func parseTLSVersion(s string) (uint16, error) {
+	v, ok := tlsVersions[s]
+	if !ok {
+		return 0, fmt.Errorf("invalid TLS version %q", s)
+	}
+	return v, nil
+}
+
+// parseTLSCiphers parses a colon separated list of cipher suite names
+// like TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 or hex values like 0xc02f.
+func parseTLSCiphers(s string) ([]uint16, error) {
+	ids := map[string]uint16{}
+	for _, c := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		ids[c.Name] = c.ID
+	}
+
+	var ciphers []uint16
+	for _, name := range strings.Split(s, ":") {
+		if id, ok := ids[name]; ok {
+			ciphers = append(ciphers, id)
+			continue
+		}
+		id, err := strconv.ParseUint(name, 0, 16)
+		if err != nil || !strings.HasPrefix(name, "0x") {
+			return nil, fmt.Errorf("invalid cipher suite %q", name)
+		}
+		ciphers = append(ciphers, uint16(id))
+	}
+	return ciphers, nil
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"p256":   tls.CurveP256,
+	"p384":   tls.CurveP384,
+	"p521":   tls.CurveP521,
+	"x25519": tls.X25519,
+}
+
+// parseTLSCurves parses a colon separated list of elliptic curves
+// which are P256, P384, P521 and X25519.
+func parseTLSCurves(s string) ([]tls.CurveID, error) {
+	var curves []tls.CurveID
+	for _, name := range strings.Split(s, ":") {
+		c, ok := tlsCurves[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("invalid curve %q", name)
+		}
+		curves = append(curves, c)
+	}
+	return curves, nil
+}
+
 func parseLegacyListen(cfg string, readTimeout, writeTimeout time.Duration) (l Listen, err error) {
 	opts := strings.Split(cfg, ";")
 
@@ -360,6 +744,8 @@ func parseCertSource(cfg map[string]string) (c CertSource, err error) {
 			c.ClientCAPath = v
 		case "caupgcn":
 			c.CAUpgradeCN = v
+		case "region":
+			c.Region = v
 		case "refresh":
 			d, err := time.ParseDuration(v)
 			if err != nil {
@@ -386,7 +772,7 @@ func parseCertSource(cfg map[string]string) (c CertSource, err error) {
 	if c.CertPath == "" {
 		return CertSource{}, fmt.Errorf("missing 'cert' in %s", cfg)
 	}
-	if c.Type != "file" && c.Type != "path" && c.Type != "http" && c.Type != "consul" && c.Type != "vault" {
+	if c.Type != "file" && c.Type != "path" && c.Type != "http" && c.Type != "s3" && c.Type != "consul" && c.Type != "vault" {
 		return CertSource{}, fmt.Errorf("unknown cert source type %s", c.Type)
 	}
 	if c.Type == "file" {
@@ -394,3 +780,404 @@ func parseCertSource(cfg map[string]string) (c CertSource, err error) {
 	}
 	return
 }
+
+// parseResponseHeaders parses a list of response header templates
+// in the form 'name: template;name: template;...'. The templates
+// are only checked for syntax errors.
+func parseResponseHeaders(s string) (map[string]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	hdr := map[string]string{}
+	for _, h := range strings.Split(s, ";") {
+		if strings.TrimSpace(h) == "" {
+			continue
+		}
+		p := strings.SplitN(h, ":", 2)
+		if len(p) != 2 || strings.TrimSpace(p[0]) == "" {
+			return nil, fmt.Errorf("invalid response header %q", h)
+		}
+		name, value := http.CanonicalHeaderKey(strings.TrimSpace(p[0])), strings.TrimSpace(p[1])
+		if _, err := template.New(name).Parse(value); err != nil {
+			return nil, fmt.Errorf("invalid template for response header %s: %s", name, err)
+		}
+		hdr[name] = value
+	}
+	return hdr, nil
+}
+
+// parseErrorPages parses a list of custom error pages in the
+// form 'code=file;code=file;...'. The status codes must be
+// between 400 and 599.
+func parseErrorPages(s string) (map[int]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	pages := map[int]string{}
+	for _, p := range strings.Split(s, ";") {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[1]) == "" {
+			return nil, fmt.Errorf("invalid error page %q", p)
+		}
+		code, err := strconv.Atoi(strings.TrimSpace(kv[0]))
+		if err != nil || code < 400 || code > 599 {
+			return nil, fmt.Errorf("invalid status code for error page %q", p)
+		}
+		pages[code] = strings.TrimSpace(kv[1])
+	}
+	return pages, nil
+}
+
+func parseAuthSources(cfgs []map[string]string) (as map[string]AuthSource, err error) {
+	as = map[string]AuthSource{}
+	for _, cfg := range cfgs {
+		src, err := parseAuthSource(cfg)
+		if err != nil {
+			return nil, err
+		}
+		as[src.Name] = src
+	}
+	return
+}
+
+func parseAuthSource(cfg map[string]string) (a AuthSource, err error) {
+	a.Refresh = 3 * time.Second
+
+	for k, v := range cfg {
+		switch k {
+		case "auth":
+			a.Name = v
+		case "type":
+			a.Type = v
+		case "path":
+			a.Path = v
+		case "realm":
+			a.Realm = v
+		case "refresh":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return AuthSource{}, err
+			}
+			a.Refresh = d
+		}
+	}
+	if a.Name == "" {
+		return AuthSource{}, fmt.Errorf("missing 'auth' in %s", cfg)
+	}
+	if a.Type == "" {
+		return AuthSource{}, fmt.Errorf("missing 'type' in %s", cfg)
+	}
+	if a.Path == "" {
+		return AuthSource{}, fmt.Errorf("missing 'path' in %s", cfg)
+	}
+	if a.Type != "file" && a.Type != "consul" {
+		return AuthSource{}, fmt.Errorf("unknown auth source type %s", a.Type)
+	}
+	if a.Realm == "" {
+		a.Realm = a.Name
+	}
+	if a.Refresh > 0 && a.Refresh < time.Second {
+		a.Refresh = time.Second
+	}
+	return
+}
+
+func parseJWTIssuers(cfgs []map[string]string) (issuers map[string]JWTIssuer, err error) {
+	issuers = map[string]JWTIssuer{}
+	for _, cfg := range cfgs {
+		iss, err := parseJWTIssuer(cfg)
+		if err != nil {
+			return nil, err
+		}
+		issuers[iss.Name] = iss
+	}
+	return
+}
+
+func parseJWTIssuer(cfg map[string]string) (j JWTIssuer, err error) {
+	j.Refresh = time.Hour
+
+	for k, v := range cfg {
+		switch k {
+		case "jwt":
+			j.Name = v
+		case "jwks":
+			j.JWKS = v
+		case "iss":
+			j.Issuer = v
+		case "aud":
+			j.Audience = v
+		case "claims":
+			if j.Claims, err = parseJWTPairs(v, false); err != nil {
+				return JWTIssuer{}, err
+			}
+		case "headers":
+			if j.Headers, err = parseJWTPairs(v, true); err != nil {
+				return JWTIssuer{}, err
+			}
+		case "refresh":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return JWTIssuer{}, err
+			}
+			j.Refresh = d
+		}
+	}
+	if j.Name == "" {
+		return JWTIssuer{}, fmt.Errorf("missing 'jwt' in %s", cfg)
+	}
+	if j.JWKS == "" {
+		return JWTIssuer{}, fmt.Errorf("missing 'jwks' in %s", cfg)
+	}
+	if j.Refresh > 0 && j.Refresh < time.Second {
+		j.Refresh = time.Second
+	}
+	return
+}
+
+func parseOIDCProviders(cfgs []map[string]string) (providers map[string]OIDCProvider, err error) {
+	providers = map[string]OIDCProvider{}
+	for _, cfg := range cfgs {
+		p, err := parseOIDCProvider(cfg)
+		if err != nil {
+			return nil, err
+		}
+		providers[p.Name] = p
+	}
+	return
+}
+
+func parseOIDCProvider(cfg map[string]string) (p OIDCProvider, err error) {
+	p.CallbackPath = "/oauth2/callback"
+	p.Scopes = []string{"openid"}
+	p.CookieName = "_fabio_oidc"
+	p.Session = 12 * time.Hour
+
+	for k, v := range cfg {
+		switch k {
+		case "oidc":
+			p.Name = v
+		case "issuer":
+			p.Issuer = v
+		case "clientid":
+			p.ClientID = v
+		case "clientsecret":
+			p.ClientSecret = v
+		case "callback":
+			p.CallbackPath = v
+		case "scopes":
+			for _, s := range strings.Split(v, "|") {
+				if s = strings.TrimSpace(s); s != "" && s != "openid" {
+					p.Scopes = append(p.Scopes, s)
+				}
+			}
+		case "cookie":
+			p.CookieName = v
+		case "secret":
+			p.CookieSecret = v
+		case "session":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return OIDCProvider{}, err
+			}
+			p.Session = d
+		case "claims":
+			if p.Claims, err = parseJWTPairs(v, false); err != nil {
+				return OIDCProvider{}, err
+			}
+		case "headers":
+			if p.Headers, err = parseJWTPairs(v, true); err != nil {
+				return OIDCProvider{}, err
+			}
+		}
+	}
+	for _, key := range []string{"oidc", "issuer", "clientid", "clientsecret"} {
+		if cfg[key] == "" {
+			return OIDCProvider{}, fmt.Errorf("missing '%s' in %s", key, cfg)
+		}
+	}
+	if !strings.HasPrefix(p.CallbackPath, "/") {
+		return OIDCProvider{}, fmt.Errorf("invalid callback %q", p.CallbackPath)
+	}
+	if p.CookieName == "" {
+		return OIDCProvider{}, fmt.Errorf("invalid cookie %q", p.CookieName)
+	}
+	if p.Session <= 0 {
+		return OIDCProvider{}, fmt.Errorf("invalid session %s", p.Session)
+	}
+	return
+}
+
+func parseExtAuthzs(cfgs []map[string]string) (services map[string]ExtAuthz, err error) {
+	services = map[string]ExtAuthz{}
+	for _, cfg := range cfgs {
+		a, err := parseExtAuthz(cfg)
+		if err != nil {
+			return nil, err
+		}
+		services[a.Name] = a
+	}
+	return
+}
+
+func parseExtAuthz(cfg map[string]string) (a ExtAuthz, err error) {
+	a.Timeout = time.Second
+
+	for k, v := range cfg {
+		switch k {
+		case "extauthz":
+			a.Name = v
+		case "url":
+			a.URL = v
+		case "timeout":
+			if a.Timeout, err = time.ParseDuration(v); err != nil {
+				return ExtAuthz{}, err
+			}
+		case "failopen":
+			if a.FailOpen, err = strconv.ParseBool(v); err != nil {
+				return ExtAuthz{}, fmt.Errorf("invalid failopen %q", v)
+			}
+		case "headers":
+			a.Headers = parseHeaderList(v)
+		case "responseheaders":
+			a.ResponseHeaders = parseHeaderList(v)
+		}
+	}
+	if a.Name == "" {
+		return ExtAuthz{}, fmt.Errorf("missing 'extauthz' in %s", cfg)
+	}
+	u, err := url.Parse(a.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return ExtAuthz{}, fmt.Errorf("invalid url %q", a.URL)
+	}
+	if a.Timeout <= 0 {
+		return ExtAuthz{}, fmt.Errorf("invalid timeout %s", a.Timeout)
+	}
+	return
+}
+
+// parseHeaderList parses a '|' separated list of header names.
+func parseHeaderList(s string) []string {
+	var hdrs []string
+	for _, h := range strings.Split(s, "|") {
+		if h = strings.TrimSpace(h); h != "" {
+			hdrs = append(hdrs, http.CanonicalHeaderKey(h))
+		}
+	}
+	return hdrs
+}
+
+// parseJWTPairs parses a '|' separated list of 'name:value' pairs.
+// If required is true the values must not be empty.
+func parseJWTPairs(s string, required bool) (map[string]string, error) {
+	m := map[string]string{}
+	for _, p := range strings.Split(s, "|") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		kv := strings.SplitN(p, ":", 2)
+		name, value := strings.TrimSpace(kv[0]), ""
+		if len(kv) == 2 {
+			value = strings.TrimSpace(kv[1])
+		}
+		if name == "" || (required && value == "") {
+			return nil, fmt.Errorf("invalid claim %q", p)
+		}
+		m[name] = value
+	}
+	return m, nil
+}
+
+func parseSRVs(cfgs []map[string]string) (srvs []SRV, err error) {
+	for _, cfg := range cfgs {
+		srv, err := parseSRV(cfg)
+		if err != nil {
+			return nil, err
+		}
+		srvs = append(srvs, srv)
+	}
+	return
+}
+
+func parseSRV(cfg map[string]string) (s SRV, err error) {
+	s.Scheme = "http"
+
+	for k, v := range cfg {
+		switch k {
+		case "srv":
+			s.Name = v
+		case "svc":
+			s.Service = v
+		case "prefix":
+			s.Prefix = v
+		case "scheme":
+			s.Scheme = v
+		case "opts":
+			s.Opts = v
+		}
+	}
+	if s.Name == "" {
+		return SRV{}, fmt.Errorf("missing 'srv' in %s", cfg)
+	}
+	if s.Prefix == "" {
+		return SRV{}, fmt.Errorf("missing 'prefix' in %s", cfg)
+	}
+	if s.Service == "" {
+		s.Service = s.Name
+	}
+	if s.Scheme != "http" && s.Scheme != "https" {
+		return SRV{}, fmt.Errorf("invalid scheme %s in %s", s.Scheme, cfg)
+	}
+	return
+}
+
+// parseTagPrefixes returns the prefix from registry.consul.tagprefix and
+// the prefixes with their default options from registry.consul.tagprefixes
+// ordered from the longest to the shortest prefix so that the most specific
+// prefix matches a tag first.
+func parseTagPrefixes(prefix string, cfgs []map[string]string) (prefixes []TagPrefix, err error) {
+	seen := map[string]bool{}
+	add := func(p TagPrefix) error {
+		if seen[p.Prefix] {
+			return fmt.Errorf("duplicate tag prefix %q", p.Prefix)
+		}
+		seen[p.Prefix] = true
+		prefixes = append(prefixes, p)
+		return nil
+	}
+
+	if prefix != "" {
+		if err := add(TagPrefix{Prefix: prefix}); err != nil {
+			return nil, err
+		}
+	}
+	for _, cfg := range cfgs {
+		p, err := parseTagPrefix(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(p); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(prefixes, func(i, j int) bool { return len(prefixes[i].Prefix) > len(prefixes[j].Prefix) })
+	return prefixes, nil
+}
+
+func parseTagPrefix(cfg map[string]string) (p TagPrefix, err error) {
+	for k, v := range cfg {
+		switch k {
+		case "prefix":
+			p.Prefix = v
+		case "opts":
+			p.Opts = strings.Join(strings.Fields(v), " ")
+		}
+	}
+	if p.Prefix == "" {
+		return TagPrefix{}, fmt.Errorf("missing 'prefix' in %s", cfg)
+	}
+	return p, nil
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"crypto/tls"
 	"net/http"
 	"reflect"
 	"regexp"
@@ -15,6 +16,10 @@ func TestFromProperties(t *testing.T) {
 	in := `
 proxy.cs = cs=name;type=path;cert=foo;clientca=bar;refresh=99s;hdr=a: b;caupgcn=furb
 proxy.addr = :1234;proto=tcp+sni
+proxy.auth = auth=staging;type=file;path=/etc/htpasswd;realm=Staging;refresh=5s
+proxy.jwt = jwt=sso;jwks=https://sso.example.com/keys;iss=https://sso.example.com;aud=api;claims=scope:read|email;headers=sub:X-User|email:X-Email;refresh=10m
+proxy.oidc = oidc=corp;issuer=https://login.example.com/;clientid=fabio;clientsecret=s3cr3t;scopes=email|profile;secret=k3y;session=1h;headers=email:X-Email
+proxy.extauthz = extauthz=opa;url=http://opa:8181/authz;timeout=200ms;failopen=true;headers=authorization|Cookie;responseheaders=X-User
 proxy.localip = 4.4.4.4
 proxy.strategy = rr
 proxy.matcher = prefix
@@ -22,6 +27,14 @@ proxy.noroutestatus = 929
 proxy.shutdownwait = 500ms
 proxy.responseheadertimeout = 3s
 proxy.keepalivetimeout = 4s
+proxy.maxidleconns = 50
+proxy.idleconntimeout = 90s
+proxy.disablekeepalives = true
+proxy.tls.ca = ca.pem
+proxy.tls.cert = cert.pem
+proxy.tls.key = key.pem
+proxy.tls.skipverify = true
+proxy.tls.cs = name
 proxy.dialtimeout = 60s
 proxy.readtimeout = 5s
 proxy.writetimeout = 10s
@@ -30,37 +43,109 @@ proxy.maxconn = 666
 proxy.header.clientip = clientip
 proxy.header.tls = tls
 proxy.header.tls.value = tls-true
+proxy.header.clientcert.cn = X-Client-CN
+proxy.header.clientcert.san = X-Client-SAN
+proxy.header.sts.maxage = 31536000
+proxy.header.sts.subdomains = true
+proxy.header.sts.preload = true
+proxy.header.frameoptions = DENY
+proxy.header.contenttypeoptions = nosniff
+proxy.header.csp = default-src 'self'
+proxy.requestid.header = X-Request-Id
+proxy.header.response = X-Served-By: {{.Hostname}}; x-route: {{.RouteSrc}}
+proxy.errorpages = 502=/etc/fabio/502.html; 503=/etc/fabio/503.html
+proxy.exclude.paths = /health, /ping
+proxy.idempotency.size = 500
+proxy.idempotency.ttl = 10m
+proxy.cache.size = 200
+proxy.requesttimeout.header = X-Request-Timeout
+proxy.requesttimeout.trusted = 10.0.0.0/8, 1.2.3.4
+proxy.requesttimeout.max = 1m
+proxy.observeonly = true
+proxy.observeonly.forward = http://fabio-live:9999
+proxy.middleware = access,auth,custom
+proxy.normalize.slashes = true
+proxy.normalize.host = true
+proxy.normalize.trailingslash = remove
+proxy.slowstart = 30s
+proxy.outlier.multiple = 3
+proxy.outlier.percentile = 0.95
+proxy.outlier.minrequests = 50
+proxy.outlier.window = 2m
+proxy.outlier.interval = 5s
+proxy.outlier.cooldown = 1m
+proxy.outlier.maxejection = 0.3
 proxy.gzip.contenttype = ^(text/.*|application/(javascript|json|font-woff|xml)|.*\\+(json|xml))$
 registry.backend = something
+registry.timeout = 10s
+registry.retry = 1s
 registry.file.path = /foo/bar
+registry.file.refresh = 5s
+registry.remote.url = https://config/routes
+registry.remote.refresh = 1m
+registry.dns.srv = srv=_http._tcp.web.example.com;svc=web;prefix=/web
+registry.dns.refresh = 10s
 registry.static.routes = route add svc / http://127.0.0.1:6666/
 registry.consul.addr = https://1.2.3.4:5678
 registry.consul.token = consul-token
+registry.consul.dc = dc2
+registry.consul.dcs = dc1,dc2
+registry.consul.dcs.tag = true
+registry.consul.tls.cafile = /etc/consul/ca.pem
+registry.consul.tls.certfile = /etc/consul/cert.pem
+registry.consul.tls.keyfile = /etc/consul/key.pem
+registry.consul.tls.insecureskipverify = true
 registry.consul.kvpath = /some/path
+registry.consul.config.kvpath = /some/settings
 registry.consul.tagprefix = p-
+registry.consul.tagprefixes = prefix=internal-;opts=maxconn=10 dialtimeout=2s,prefix=q-
+registry.consul.metaprefix = m-
 registry.consul.register.enabled = false
 registry.consul.register.addr = 6.6.6.6:7777
 registry.consul.register.name = fab
 registry.consul.register.tags = a, b, c ,
 registry.consul.register.checkInterval = 5s
 registry.consul.register.checkTimeout = 10s
+registry.consul.register.checkType = ttl
+registry.consul.register.checkTTL = 20s
+registry.consul.register.checkDeregisterCriticalServiceAfter = 90m
 registry.consul.service.status = a,b
 metrics.target = graphite
 metrics.prefix = someprefix
 metrics.names = {{clean .Service}}.{{clean .Host}}.{{clean .Path}}.{{clean .TargetURL.Host}}
+metrics.routenames = {{clean .Host}}.{{clean .Path}}.{{clean .TargetService}}
 metrics.interval = 5s
+metrics.runtime = false
 metrics.graphite.addr = 5.6.7.8:9999
 metrics.statsd.addr = 6.7.8.9:9999
+metrics.dogstatsd.addr = 7.8.9.10:8125
 metrics.circonus.apikey = circonus-apikey
 metrics.circonus.apiapp = circonus-apiapp
 metrics.circonus.apiurl = circonus-apiurl
 metrics.circonus.brokerid = circonus-brokerid
 metrics.circonus.checkid = circonus-checkid
+tracing.enabled = true
+tracing.format = w3c
+tracing.collector = http://1.2.3.4:9411/api/v2/spans
+tracing.servicename = fab
+tracing.samplerate = 0.25
+tracing.interval = 5s
+log.access.target = stdout
+log.access.format = combined
+log.access.file = /var/log/fabio/access.log
+log.error.file = /var/log/fabio/fabio.log
+leaks.interval = 5m
+leaks.threshold.goroutines = 50
+leaks.threshold.fds = 20
 runtime.gogc = 666
 runtime.gomaxprocs = 12
+runtime.user = fabio
+runtime.group = nogroup
 ui.addr = 7.8.9.0:1234
 ui.color = fonzy
 ui.title = fabfab
+ui.lang = zh
+ui.timezone = Asia/Shanghai
 aws.apigw.cert.cn = furb
 `
 	out := &Config{
@@ -77,6 +162,54 @@ aws.apigw.cert.cn = furb
 				Header:       http.Header{"A": []string{"b"}},
 			},
 		},
+		AuthSourcesValue: []map[string]string{{"auth": "staging", "type": "file", "path": "/etc/htpasswd", "realm": "Staging", "refresh": "5s"}},
+		AuthSources: map[string]AuthSource{
+			"staging": AuthSource{
+				Name:    "staging",
+				Type:    "file",
+				Path:    "/etc/htpasswd",
+				Realm:   "Staging",
+				Refresh: 5 * time.Second,
+			},
+		},
+		JWTIssuersValue: []map[string]string{{"jwt": "sso", "jwks": "https://sso.example.com/keys", "iss": "https://sso.example.com", "aud": "api", "claims": "scope:read|email", "headers": "sub:X-User|email:X-Email", "refresh": "10m"}},
+		JWTIssuers: map[string]JWTIssuer{
+			"sso": JWTIssuer{
+				Name:     "sso",
+				JWKS:     "https://sso.example.com/keys",
+				Issuer:   "https://sso.example.com",
+				Audience: "api",
+				Claims:   map[string]string{"scope": "read", "email": ""},
+				Headers:  map[string]string{"sub": "X-User", "email": "X-Email"},
+				Refresh:  10 * time.Minute,
+			},
+		},
+		OIDCValue: []map[string]string{{"oidc": "corp", "issuer": "https://login.example.com/", "clientid": "fabio", "clientsecret": "s3cr3t", "scopes": "email|profile", "secret": "k3y", "session": "1h", "headers": "email:X-Email"}},
+		OIDC: map[string]OIDCProvider{
+			"corp": OIDCProvider{
+				Name:         "corp",
+				Issuer:       "https://login.example.com/",
+				ClientID:     "fabio",
+				ClientSecret: "s3cr3t",
+				CallbackPath: "/oauth2/callback",
+				Scopes:       []string{"openid", "email", "profile"},
+				CookieName:   "_fabio_oidc",
+				CookieSecret: "k3y",
+				Session:      time.Hour,
+				Headers:      map[string]string{"email": "X-Email"},
+			},
+		},
+		ExtAuthzValue: []map[string]string{{"extauthz": "opa", "url": "http://opa:8181/authz", "timeout": "200ms", "failopen": "true", "headers": "authorization|Cookie", "responseheaders": "X-User"}},
+		ExtAuthz: map[string]ExtAuthz{
+			"opa": ExtAuthz{
+				Name:            "opa",
+				URL:             "http://opa:8181/authz",
+				Timeout:         200 * time.Millisecond,
+				FailOpen:        true,
+				Headers:         []string{"Authorization", "Cookie"},
+				ResponseHeaders: []string{"X-User"},
+			},
+		},
 		Proxy: Proxy{
 			MaxConn:               666,
 			LocalIP:               "4.4.4.4",
@@ -87,19 +220,71 @@ aws.apigw.cert.cn = furb
 			DialTimeout:           60 * time.Second,
 			ResponseHeaderTimeout: 3 * time.Second,
 			KeepAliveTimeout:      4 * time.Second,
+			MaxIdleConns:          50,
+			IdleConnTimeout:       90 * time.Second,
+			DisableKeepAlives:     true,
+			TLSCAPath:             "ca.pem",
+			TLSCertPath:           "cert.pem",
+			TLSKeyPath:            "key.pem",
+			TLSSkipVerify:         true,
+			TLSCertSource:         "name",
 			ReadTimeout:           5 * time.Second,
 			WriteTimeout:          10 * time.Second,
 			FlushInterval:         15 * time.Second,
 			ClientIPHeader:        "clientip",
 			TLSHeader:             "tls",
 			TLSHeaderValue:        "tls-true",
+			ClientCertCNHeader:    "X-Client-CN",
+			ClientCertSANHeader:   "X-Client-SAN",
+			STSMaxAge:             31536000,
+			STSSubdomains:         true,
+			STSPreload:            true,
+			FrameOptions:          "DENY",
+			ContentTypeOptions:    "nosniff",
+			CSP:                   "default-src 'self'",
+			RequestIDHeader:       "X-Request-Id",
+			ResponseHeadersValue:  "X-Served-By: {{.Hostname}}; x-route: {{.RouteSrc}}",
+			ResponseHeaders:       map[string]string{"X-Served-By": "{{.Hostname}}", "X-Route": "{{.RouteSrc}}"},
+			ErrorPagesValue:       "502=/etc/fabio/502.html; 503=/etc/fabio/503.html",
+			ErrorPages:            map[int]string{502: "/etc/fabio/502.html", 503: "/etc/fabio/503.html"},
+			ExcludePaths:          []string{"/health", "/ping"},
+			IdempotencySize:       500,
+			IdempotencyTTL:        10 * time.Minute,
+			CacheSize:             200,
+			RequestTimeoutHeader:  "X-Request-Timeout",
+			RequestTimeoutTrusted: []string{"10.0.0.0/8", "1.2.3.4"},
+			RequestTimeoutMax:     time.Minute,
+			ObserveOnly:           true,
+			ObserveOnlyForward:    "http://fabio-live:9999",
+			Middleware:            []string{"access", "auth", "custom"},
+			NormalizeSlashes:      true,
+			NormalizeHost:         true,
+			TrailingSlash:         "remove",
+			SlowStart:             30 * time.Second,
+			OutlierMultiple:       3,
+			OutlierPercentile:     0.95,
+			OutlierMinRequests:    50,
+			OutlierWindow:         2 * time.Minute,
+			OutlierInterval:       5 * time.Second,
+			OutlierCooldown:       time.Minute,
+			OutlierMaxEjection:    0.3,
 			GZIPContentTypesValue: `^(text/.*|application/(javascript|json|font-woff|xml)|.*\+(json|xml))$`,
 			GZIPContentTypes:      regexp.MustCompile(`^(text/.*|application/(javascript|json|font-woff|xml)|.*\+(json|xml))$`),
 		},
 		Registry: Registry{
 			Backend: "something",
 			File: File{
-				Path: "/foo/bar",
+				Path:    "/foo/bar",
+				Refresh: 5 * time.Second,
+			},
+			Remote: Remote{
+				URL:     "https://config/routes",
+				Refresh: time.Minute,
+			},
+			DNS: DNS{
+				SRVValue: []map[string]string{{"srv": "_http._tcp.web.example.com", "svc": "web", "prefix": "/web"}},
+				SRV:      []SRV{{Name: "_http._tcp.web.example.com", Service: "web", Prefix: "/web", Scheme: "http"}},
+				Refresh:  10 * time.Second,
 			},
 			Static: Static{
 				Routes: "route add svc / http://127.0.0.1:6666/",
@@ -108,8 +293,19 @@ aws.apigw.cert.cn = furb
 				Addr:          "1.2.3.4:5678",
 				Scheme:        "https",
 				Token:         "consul-token",
+				Datacenter:    "dc2",
+				Datacenters:   []string{"dc1", "dc2"},
+				TagDatacenter: true,
+				TLS: ConsulTLS{
+					CAFile:             "/etc/consul/ca.pem",
+					CertFile:           "/etc/consul/cert.pem",
+					KeyFile:            "/etc/consul/key.pem",
+					InsecureSkipVerify: true,
+				},
 				KVPath:        "/some/path",
+				ConfigKVPath:  "/some/settings",
 				TagPrefix:     "p-",
+				MetaPrefix:    "m-",
 				Register:      false,
 				ServiceAddr:   "6.6.6.6:7777",
 				ServiceName:   "fab",
@@ -117,7 +313,23 @@ aws.apigw.cert.cn = furb
 				ServiceStatus: []string{"a", "b"},
 				CheckInterval: 5 * time.Second,
 				CheckTimeout:  10 * time.Second,
+				CheckType:     "ttl",
+				CheckTTL:      20 * time.Second,
+
+				CheckDeregisterCriticalServiceAfter: 90 * time.Minute,
+
+				TagPrefixesValue: []map[string]string{
+					{"prefix": "internal-", "opts": "maxconn=10 dialtimeout=2s"},
+					{"prefix": "q-"},
+				},
+				TagPrefixes: []TagPrefix{
+					{Prefix: "internal-", Opts: "maxconn=10 dialtimeout=2s"},
+					{Prefix: "p-"},
+					{Prefix: "q-"},
+				},
 			},
+			Timeout: 10 * time.Second,
+			Retry:   time.Second,
 		},
 		Listen: []Listen{
 			{
@@ -131,23 +343,48 @@ aws.apigw.cert.cn = furb
 			Target:           "graphite",
 			Prefix:           "someprefix",
 			Names:            "{{clean .Service}}.{{clean .Host}}.{{clean .Path}}.{{clean .TargetURL.Host}}",
+			RouteNames:       "{{clean .Host}}.{{clean .Path}}.{{clean .TargetService}}",
 			Interval:         5 * time.Second,
 			GraphiteAddr:     "5.6.7.8:9999",
 			StatsDAddr:       "6.7.8.9:9999",
+			DogStatsDAddr:    "7.8.9.10:8125",
 			CirconusAPIKey:   "circonus-apikey",
 			CirconusAPIApp:   "circonus-apiapp",
 			CirconusAPIURL:   "circonus-apiurl",
 			CirconusBrokerID: "circonus-brokerid",
 			CirconusCheckID:  "circonus-checkid",
 		},
+		Tracing: Tracing{
+			Enabled:     true,
+			Format:      "w3c",
+			Collector:   "http://1.2.3.4:9411/api/v2/spans",
+			ServiceName: "fab",
+			SampleRate:  0.25,
+			Interval:    5 * time.Second,
+		},
+		Log: Log{
+			AccessTarget: "stdout",
+			AccessFormat: "combined",
+			AccessFile:   "/var/log/fabio/access.log",
+			ErrorFile:    "/var/log/fabio/fabio.log",
+		},
+		Leaks: Leaks{
+			Interval:           5 * time.Minute,
+			GoroutineThreshold: 50,
+			FDThreshold:        20,
+		},
 		Runtime: Runtime{
 			GOGC:       666,
 			GOMAXPROCS: 12,
+			User:       "fabio",
+			Group:      "nogroup",
 		},
 		UI: UI{
-			Addr:  "7.8.9.0:1234",
-			Color: "fonzy",
-			Title: "fabfab",
+			Addr:     "7.8.9.0:1234",
+			Color:    "fonzy",
+			Title:    "fabfab",
+			Lang:     "zh",
+			Timezone: "Asia/Shanghai",
 		},
 	}
 
@@ -187,6 +424,379 @@ func TestParseScheme(t *testing.T) {
 	}
 }
 
+func TestParseResponseHeaders(t *testing.T) {
+	tests := []struct {
+		in  string
+		out map[string]string
+		err string
+	}{
+		{"", nil, ""},
+		{"a: b", map[string]string{"A": "b"}, ""},
+		{"a: b:c; ;x-y:{{.Host}} ", map[string]string{"A": "b:c", "X-Y": "{{.Host}}"}, ""},
+		{"a", nil, `invalid response header "a"`},
+		{"a: {{.Host", nil, "invalid template for response header A: template: A:1: unclosed action"},
+	}
+
+	for i, tt := range tests {
+		out, err := parseResponseHeaders(tt.in)
+		if got, want := out, tt.out; !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: got %v want %v", i, got, want)
+		}
+		var errs string
+		if err != nil {
+			errs = err.Error()
+		}
+		if got, want := errs, tt.err; got != want {
+			t.Errorf("%d: got error %q want %q", i, got, want)
+		}
+	}
+}
+
+func TestParseErrorPages(t *testing.T) {
+	tests := []struct {
+		in  string
+		out map[int]string
+		err string
+	}{
+		{"", nil, ""},
+		{"502=/a.html", map[int]string{502: "/a.html"}, ""},
+		{" 503 = /b.html ; ;504=/c.json", map[int]string{503: "/b.html", 504: "/c.json"}, ""},
+		{"502", nil, `invalid error page "502"`},
+		{"502=", nil, `invalid error page "502="`},
+		{"200=/a.html", nil, `invalid status code for error page "200=/a.html"`},
+		{"foo=/a.html", nil, `invalid status code for error page "foo=/a.html"`},
+	}
+
+	for i, tt := range tests {
+		out, err := parseErrorPages(tt.in)
+		if got, want := out, tt.out; !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: got %v want %v", i, got, want)
+		}
+		var errs string
+		if err != nil {
+			errs = err.Error()
+		}
+		if got, want := errs, tt.err; got != want {
+			t.Errorf("%d: got error %q want %q", i, got, want)
+		}
+	}
+}
+
+func TestParseAuthSource(t *testing.T) {
+	tests := []struct {
+		in  map[string]string
+		out AuthSource
+		err string
+	}{
+		{
+			in:  map[string]string{"auth": "a", "type": "consul", "path": "http://localhost:8500/v1/kv/fabio/htpasswd"},
+			out: AuthSource{Name: "a", Type: "consul", Path: "http://localhost:8500/v1/kv/fabio/htpasswd", Realm: "a", Refresh: 3 * time.Second},
+		},
+		{
+			in:  map[string]string{"auth": "a", "type": "file", "path": "p", "refresh": "0"},
+			out: AuthSource{Name: "a", Type: "file", Path: "p", Realm: "a"},
+		},
+		{
+			in:  map[string]string{"type": "file", "path": "p"},
+			err: "missing 'auth' in map[path:p type:file]",
+		},
+		{
+			in:  map[string]string{"auth": "a", "path": "p"},
+			err: "missing 'type' in map[auth:a path:p]",
+		},
+		{
+			in:  map[string]string{"auth": "a", "type": "file"},
+			err: "missing 'path' in map[auth:a type:file]",
+		},
+		{
+			in:  map[string]string{"auth": "a", "type": "foo", "path": "p"},
+			err: "unknown auth source type foo",
+		},
+	}
+
+	for i, tt := range tests {
+		a, err := parseAuthSource(tt.in)
+		if got, want := a, tt.out; got != want {
+			t.Errorf("%d: got %+v want %+v", i, got, want)
+		}
+		var errs string
+		if err != nil {
+			errs = err.Error()
+		}
+		if got, want := errs, tt.err; got != want {
+			t.Errorf("%d: got error %q want %q", i, got, want)
+		}
+	}
+}
+
+func TestParseJWTIssuer(t *testing.T) {
+	tests := []struct {
+		in  map[string]string
+		out JWTIssuer
+		err string
+	}{
+		{
+			in:  map[string]string{"jwt": "a", "jwks": "https://example.com/keys"},
+			out: JWTIssuer{Name: "a", JWKS: "https://example.com/keys", Refresh: time.Hour},
+		},
+		{
+			in:  map[string]string{"jwt": "a", "jwks": "/etc/jwks.json", "refresh": "0", "claims": "role:admin|sub", "headers": "sub:X-User"},
+			out: JWTIssuer{Name: "a", JWKS: "/etc/jwks.json", Claims: map[string]string{"role": "admin", "sub": ""}, Headers: map[string]string{"sub": "X-User"}},
+		},
+		{
+			in:  map[string]string{"jwt": "a", "jwks": "p", "refresh": "1ms"},
+			out: JWTIssuer{Name: "a", JWKS: "p", Refresh: time.Second},
+		},
+		{
+			in:  map[string]string{"jwks": "p"},
+			err: "missing 'jwt' in map[jwks:p]",
+		},
+		{
+			in:  map[string]string{"jwt": "a"},
+			err: "missing 'jwks' in map[jwt:a]",
+		},
+		{
+			in:  map[string]string{"jwt": "a", "jwks": "p", "headers": "sub"},
+			err: `invalid claim "sub"`,
+		},
+	}
+
+	for i, tt := range tests {
+		j, err := parseJWTIssuer(tt.in)
+		if got, want := j, tt.out; !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: got %+v want %+v", i, got, want)
+		}
+		var errs string
+		if err != nil {
+			errs = err.Error()
+		}
+		if got, want := errs, tt.err; got != want {
+			t.Errorf("%d: got error %q want %q", i, got, want)
+		}
+	}
+}
+
+func TestParseExtAuthz(t *testing.T) {
+	tests := []struct {
+		desc string
+		in   map[string]string
+		out  ExtAuthz
+		err  string
+	}{
+		{"defaults", map[string]string{"extauthz": "a", "url": "https://authz/check"}, ExtAuthz{Name: "a", URL: "https://authz/check", Timeout: time.Second}, ""},
+		{"missing name", map[string]string{"url": "https://authz/check"}, ExtAuthz{}, "missing 'extauthz' in map[url:https://authz/check]"},
+		{"invalid url", map[string]string{"extauthz": "a", "url": "authz/check"}, ExtAuthz{}, `invalid url "authz/check"`},
+		{"invalid timeout", map[string]string{"extauthz": "a", "url": "https://authz/check", "timeout": "0s"}, ExtAuthz{}, "invalid timeout 0s"},
+		{"invalid failopen", map[string]string{"extauthz": "a", "url": "https://authz/check", "failopen": "maybe"}, ExtAuthz{}, `invalid failopen "maybe"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			a, err := parseExtAuthz(tt.in)
+			if got, want := a, tt.out; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v want %+v", got, want)
+			}
+			var gotErr string
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if got, want := gotErr, tt.err; got != want {
+				t.Errorf("got error %q want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestParseOIDCProvider(t *testing.T) {
+	base := map[string]string{"oidc": "a", "issuer": "https://idp", "clientid": "c", "clientsecret": "s"}
+	with := func(kv ...string) map[string]string {
+		m := map[string]string{}
+		for k, v := range base {
+			m[k] = v
+		}
+		for i := 0; i < len(kv); i += 2 {
+			m[kv[i]] = kv[i+1]
+		}
+		return m
+	}
+	defaults := OIDCProvider{
+		Name:         "a",
+		Issuer:       "https://idp",
+		ClientID:     "c",
+		ClientSecret: "s",
+		CallbackPath: "/oauth2/callback",
+		Scopes:       []string{"openid"},
+		CookieName:   "_fabio_oidc",
+		Session:      12 * time.Hour,
+	}
+
+	tests := []struct {
+		desc string
+		in   map[string]string
+		out  func(p *OIDCProvider)
+		err  string
+	}{
+		{"defaults", base, func(p *OIDCProvider) {}, ""},
+		{"options", with("callback", "/login", "cookie", "sess", "scopes", "openid|groups", "claims", "groups:admin"), func(p *OIDCProvider) {
+			p.CallbackPath = "/login"
+			p.CookieName = "sess"
+			p.Scopes = []string{"openid", "groups"}
+			p.Claims = map[string]string{"groups": "admin"}
+		}, ""},
+		{"missing clientsecret", with("clientsecret", ""), nil, "missing 'clientsecret' in map[clientid:c clientsecret: issuer:https://idp oidc:a]"},
+		{"invalid callback", with("callback", "login"), nil, `invalid callback "login"`},
+		{"invalid session", with("session", "0"), nil, "invalid session 0s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			p, err := parseOIDCProvider(tt.in)
+			var want OIDCProvider
+			if tt.out != nil {
+				want = defaults
+				tt.out(&want)
+			}
+			if got := p; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v want %+v", got, want)
+			}
+			var errs string
+			if err != nil {
+				errs = err.Error()
+			}
+			if got, want := errs, tt.err; got != want {
+				t.Errorf("got error %q want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestParseCertSource(t *testing.T) {
+	tests := []struct {
+		in  map[string]string
+		out CertSource
+		err string
+	}{
+		{
+			in:  map[string]string{"cs": "a", "type": "s3", "cert": "s3://bucket/certs/list", "region": "eu-west-1"},
+			out: CertSource{Name: "a", Type: "s3", CertPath: "s3://bucket/certs/list", Region: "eu-west-1", Refresh: 3 * time.Second},
+		},
+		{
+			in:  map[string]string{"cs": "a", "type": "foo", "cert": "c"},
+			err: "unknown cert source type foo",
+		},
+	}
+
+	for i, tt := range tests {
+		c, err := parseCertSource(tt.in)
+		if got, want := c, tt.out; !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: got %+v want %+v", i, got, want)
+		}
+		var errs string
+		if err != nil {
+			errs = err.Error()
+		}
+		if got, want := errs, tt.err; got != want {
+			t.Errorf("%d: got error %q want %q", i, got, want)
+		}
+	}
+}
+
+func TestParseSRV(t *testing.T) {
+	tests := []struct {
+		in  map[string]string
+		out SRV
+		err string
+	}{
+		{
+			in:  map[string]string{"srv": "_http._tcp.web", "prefix": "/web"},
+			out: SRV{Name: "_http._tcp.web", Service: "_http._tcp.web", Prefix: "/web", Scheme: "http"},
+		},
+		{
+			in:  map[string]string{"srv": "_https._tcp.web", "svc": "web", "prefix": "a.com/", "scheme": "https", "opts": "strip=/web"},
+			out: SRV{Name: "_https._tcp.web", Service: "web", Prefix: "a.com/", Scheme: "https", Opts: "strip=/web"},
+		},
+		{
+			in:  map[string]string{"prefix": "/web"},
+			err: "missing 'srv' in map[prefix:/web]",
+		},
+		{
+			in:  map[string]string{"srv": "web"},
+			err: "missing 'prefix' in map[srv:web]",
+		},
+		{
+			in:  map[string]string{"srv": "web", "prefix": "/", "scheme": "tcp"},
+			err: "invalid scheme tcp in map[prefix:/ scheme:tcp srv:web]",
+		},
+	}
+
+	for i, tt := range tests {
+		s, err := parseSRV(tt.in)
+		if got, want := s, tt.out; got != want {
+			t.Errorf("%d: got %+v want %+v", i, got, want)
+		}
+		var errs string
+		if err != nil {
+			errs = err.Error()
+		}
+		if got, want := errs, tt.err; got != want {
+			t.Errorf("%d: got error %q want %q", i, got, want)
+		}
+	}
+}
+
+func TestParseTagPrefixes(t *testing.T) {
+	tests := []struct {
+		prefix string
+		in     []map[string]string
+		out    []TagPrefix
+		err    string
+	}{
+		{
+			prefix: "urlprefix-",
+			out:    []TagPrefix{{Prefix: "urlprefix-"}},
+		},
+		{
+			prefix: "urlprefix-",
+			in: []map[string]string{
+				{"prefix": "public-"},
+				{"prefix": "internal-", "opts": " allow=ip:10.0.0.0/8  maxconn=10 "},
+			},
+			out: []TagPrefix{
+				{Prefix: "urlprefix-"},
+				{Prefix: "internal-", Opts: "allow=ip:10.0.0.0/8 maxconn=10"},
+				{Prefix: "public-"},
+			},
+		},
+		{
+			in:  []map[string]string{{"prefix": "public-"}},
+			out: []TagPrefix{{Prefix: "public-"}},
+		},
+		{
+			in:  []map[string]string{{"opts": "maxconn=10"}},
+			err: "missing 'prefix' in map[opts:maxconn=10]",
+		},
+		{
+			prefix: "urlprefix-",
+			in:     []map[string]string{{"prefix": "urlprefix-"}},
+			err:    `duplicate tag prefix "urlprefix-"`,
+		},
+	}
+
+	for i, tt := range tests {
+		p, err := parseTagPrefixes(tt.prefix, tt.in)
+		if got, want := p, tt.out; !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: got %+v want %+v", i, got, want)
+		}
+		var errs string
+		if err != nil {
+			errs = err.Error()
+		}
+		if got, want := errs, tt.err; got != want {
+			t.Errorf("%d: got error %q want %q", i, got, want)
+		}
+	}
+}
+
 func TestParseListen(t *testing.T) {
 	cs := map[string]CertSource{
 		"name": CertSource{Name: "name", Type: "foo"},
@@ -217,11 +827,129 @@ func TestParseListen(t *testing.T) {
 			Listen{Addr: ":123", Proto: "tcp+sni"},
 			"",
 		},
+		{
+			"/var/run/fabio.sock;proto=unix",
+			Listen{Addr: "/var/run/fabio.sock", Proto: "unix"},
+			"",
+		},
 		{
 			":123;rt=5s;wt=5s",
 			Listen{Addr: ":123", Proto: "http", ReadTimeout: 5 * time.Second, WriteTimeout: 5 * time.Second},
 			"",
 		},
+		{
+			":123;maxidleconns=10;idleconntimeout=30s;disablekeepalives=true",
+			Listen{Addr: ":123", Proto: "http", MaxIdleConns: 10, IdleConnTimeout: 30 * time.Second, DisableKeepAlives: true},
+			"",
+		},
+		{
+			":123;maxconns=100;acceptrate=50",
+			Listen{Addr: ":123", Proto: "http", MaxConns: 100, AcceptRate: 50},
+			"",
+		},
+		{
+			":123;maxheaderbytes=65536;readheadertimeout=5s;idletimeout=2m",
+			Listen{Addr: ":123", Proto: "http", MaxHeaderBytes: 65536, ReadHeaderTimeout: 5 * time.Second, IdleTimeout: 2 * time.Minute},
+			"",
+		},
+		{
+			":123;proto=tcp+sni;readheadertimeout=5s;idletimeout=2m",
+			Listen{Addr: ":123", Proto: "tcp+sni", ReadHeaderTimeout: 5 * time.Second, IdleTimeout: 2 * time.Minute},
+			"",
+		},
+		{
+			":123;maxheaderbytes=-1",
+			Listen{},
+			"invalid maxheaderbytes \"-1\"",
+		},
+		{
+			":123;proto=tcp+sni;maxheaderbytes=1024",
+			Listen{},
+			"maxheaderbytes not supported for proto 'tcp+sni'",
+		},
+		{
+			":443;cs=name;strictmatch=true;defaultcert=Example.com;denysni=evil.com:*.Bad.com",
+			Listen{Addr: ":443", Proto: "https", CertSource: cs["name"], StrictMatch: true, DefaultCert: "example.com", DenySNI: []string{"evil.com", "*.bad.com"}},
+			"",
+		},
+		{
+			":80;denysni=evil.com",
+			Listen{},
+			"defaultcert and denysni require proto 'https'",
+		},
+		{
+			":80;proto=http;redirect=https",
+			Listen{Addr: ":80", Proto: "http", Redirect: "https"},
+			"",
+		},
+		{
+			":8080;redirect=https:8443",
+			Listen{Addr: ":8080", Proto: "http", Redirect: "https", RedirectPort: "8443"},
+			"",
+		},
+		{
+			":80;redirect=ftp",
+			Listen{},
+			"invalid redirect \"ftp\"",
+		},
+		{
+			":80;redirect=https:0",
+			Listen{},
+			"invalid redirect port \"0\"",
+		},
+		{
+			":443;cs=name;redirect=https",
+			Listen{},
+			"redirect requires proto 'http'",
+		},
+		{
+			":123;acceptrate=-1",
+			Listen{},
+			"invalid acceptrate \"-1\"",
+		},
+		{
+			":123;maxidleconns=foo",
+			Listen{},
+			"invalid maxidleconns \"foo\"",
+		},
+		{
+			":123;cs=name;tlsmin=1.2;tlsmax=1.3;ciphers=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:0xc030;curves=X25519:p256;preferserverciphers=true;ocsp=true",
+			Listen{
+				Addr:  ":123",
+				Proto: "https",
+				CertSource: CertSource{
+					Name: "name",
+					Type: "foo",
+				},
+				TLSMinVersion:          tls.VersionTLS12,
+				TLSMaxVersion:          tls.VersionTLS13,
+				TLSCiphers:             []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+				TLSCurves:              []tls.CurveID{tls.X25519, tls.CurveP256},
+				TLSPreferServerCiphers: true,
+				OCSPStapling:           true,
+			},
+			"",
+		},
+		{
+			":123;cs=name;tlsmin=1.4",
+			Listen{},
+			"invalid TLS version \"1.4\"",
+		},
+		{
+			":123;cs=name;tlsmin=1.3;tlsmax=1.2",
+			Listen{},
+			"tlsmin must not be greater than tlsmax",
+		},
+		{
+			":123;cs=name;ciphers=foo",
+			Listen{},
+			"invalid cipher suite \"foo\"",
+		},
+		{
+			":123;cs=name;curves=P224",
+			Listen{},
+			"invalid curve \"P224\"",
+		},
 		{
 			":123;pathA;pathB;pathC",
 			Listen{
@@ -349,3 +1077,16 @@ func TestParseCfg(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadWithOverrides(t *testing.T) {
+	cfg, err := LoadWithOverrides(map[string]string{"proxy.strategy": "rr", "proxy.maxconn": "5"})
+	if err != nil {
+		t.Fatalf("got %v want nil", err)
+	}
+	if got, want := cfg.Proxy.Strategy, "rr"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	if got, want := cfg.Proxy.MaxConn, 5; got != want {
+		t.Errorf("got %d want %d", got, want)
+	}
+}
@@ -0,0 +1,180 @@
+// Package diag provides runtime diagnostics for detecting
+// goroutine and file descriptor leaks.
+package diag
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"log"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eBay/fabio/config"
+)
+
+// Default stores the leak detector. It is nil if
+// the periodic leak detection is disabled.
+var Default *Detector
+
+// Snapshot contains the goroutine and file descriptor counts
+// at a point in time.
+type Snapshot struct {
+	Time       time.Time      `json:"time"`
+	Goroutines int            `json:"goroutines"`
+	Creators   map[string]int `json:"creators"`
+
+	// FDs is the number of open file descriptors
+	// or -1 if it cannot be determined.
+	FDs int `json:"fds"`
+}
+
+// Take returns a snapshot of the current goroutines grouped
+// by the function which created them and the number of open
+// file descriptors.
+func Take() *Snapshot {
+	var buf bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&buf, 2)
+	creators := countCreators(buf.Bytes())
+
+	n := 0
+	for _, c := range creators {
+		n += c
+	}
+	return &Snapshot{Time: time.Now(), Goroutines: n, Creators: creators, FDs: countFDs()}
+}
+
+// countCreators parses a goroutine dump and counts the goroutines
+// by the function which created them. Goroutines without a creator
+// are counted as "main".
+func countCreators(dump []byte) map[string]int {
+	creators := map[string]int{}
+	s := bufio.NewScanner(bytes.NewReader(dump))
+	s.Buffer(nil, 1<<20)
+	inStack, creator := false, ""
+	flush := func() {
+		if !inStack {
+			return
+		}
+		if creator == "" {
+			creator = "main"
+		}
+		creators[creator]++
+		inStack, creator = false, ""
+	}
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case strings.HasPrefix(line, "goroutine "):
+			flush()
+			inStack = true
+		case strings.HasPrefix(line, "created by "):
+			creator = strings.TrimPrefix(line, "created by ")
+			// go1.21+: "created by main.f in goroutine 1"
+			if n := strings.Index(creator, " in goroutine "); n > 0 {
+				creator = creator[:n]
+			}
+		}
+	}
+	flush()
+	return creators
+}
+
+// countFDs returns the number of open file descriptors
+// or -1 on platforms without /proc.
+func countFDs() int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(fds)
+}
+
+// Delta describes the change of a value between two snapshots.
+type Delta struct {
+	Name  string `json:"name"`
+	Prev  int    `json:"prev"`
+	Cur   int    `json:"cur"`
+	Delta int    `json:"delta"`
+}
+
+// Report contains the current and the previous snapshot and
+// the values which increased by more than the threshold.
+type Report struct {
+	Baseline *Snapshot `json:"baseline"`
+	Previous *Snapshot `json:"previous"`
+	Current  *Snapshot `json:"current"`
+	Leaks    []Delta   `json:"leaks"`
+}
+
+// Detector takes snapshots periodically and logs
+// when the counts grow beyond the thresholds.
+type Detector struct {
+	cfg config.Leaks
+
+	mu     sync.Mutex
+	report *Report
+}
+
+// NewDetector creates a detector and takes the baseline snapshot.
+func NewDetector(cfg config.Leaks) *Detector {
+	s := Take()
+	return &Detector{cfg: cfg, report: &Report{Baseline: s, Current: s}}
+}
+
+// Run takes a snapshot every interval until the process exits.
+func (d *Detector) Run() {
+	for range time.Tick(d.cfg.Interval) {
+		d.Check(Take())
+	}
+}
+
+// Check compares the snapshot to the previous one and
+// logs all values which increased by more than the threshold.
+func (d *Detector) Check(s *Snapshot) *Report {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	prev := d.report.Current
+	r := &Report{Baseline: d.report.Baseline, Previous: prev, Current: s}
+	r.Leaks = leaks(prev, s, d.cfg.GoroutineThreshold, d.cfg.FDThreshold)
+	for _, l := range r.Leaks {
+		log.Printf("[WARN] leaks: %s increased by %d from %d to %d", l.Name, l.Delta, l.Prev, l.Cur)
+	}
+	d.report = r
+	return r
+}
+
+// Report returns the last report.
+func (d *Detector) Report() *Report {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.report
+}
+
+// leaks returns the goroutine creators and file descriptor counts
+// which increased by at least the threshold. A threshold of zero
+// disables the check.
+func leaks(prev, cur *Snapshot, goroutines, fds int) []Delta {
+	var d []Delta
+	if goroutines > 0 {
+		var names []string
+		for name := range cur.Creators {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p, c := prev.Creators[name], cur.Creators[name]
+			if c-p >= goroutines {
+				d = append(d, Delta{Name: "goroutines created by " + name, Prev: p, Cur: c, Delta: c - p})
+			}
+		}
+	}
+	if fds > 0 && prev.FDs >= 0 && cur.FDs-prev.FDs >= fds {
+		d = append(d, Delta{Name: "open fds", Prev: prev.FDs, Cur: cur.FDs, Delta: cur.FDs - prev.FDs})
+	}
+	return d
+}
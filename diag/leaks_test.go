@@ -0,0 +1,72 @@
+package diag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCountCreators(t *testing.T) {
+	dump := `goroutine 1 [running]:
+main.main()
+	/src/main.go:10 +0x1
+
+goroutine 7 [IO wait]:
+net.(*conn).Read(0x1)
+	/go/src/net/net.go:1 +0x1
+created by github.com/eBay/fabio/proxy.(*tcpSNIProxy).Serve
+	/src/proxy/tcp_sni_proxy.go:42 +0x1
+
+goroutine 8 [IO wait]:
+net.(*conn).Read(0x1)
+	/go/src/net/net.go:1 +0x1
+created by github.com/eBay/fabio/proxy.(*tcpSNIProxy).Serve in goroutine 1
+	/src/proxy/tcp_sni_proxy.go:42 +0x1
+`
+	got := countCreators([]byte(dump))
+	want := map[string]int{
+		"main": 1,
+		"github.com/eBay/fabio/proxy.(*tcpSNIProxy).Serve": 2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
+func TestTake(t *testing.T) {
+	s := Take()
+	if s.Goroutines < 1 {
+		t.Fatalf("got %d goroutines want > 0", s.Goroutines)
+	}
+}
+
+func TestLeaks(t *testing.T) {
+	prev := &Snapshot{Creators: map[string]int{"a": 1, "b": 5}, FDs: 10}
+	cur := &Snapshot{Creators: map[string]int{"a": 11, "b": 6, "c": 20}, FDs: 30}
+
+	tests := []struct {
+		goroutines, fds int
+		want            []Delta
+	}{
+		{0, 0, nil},
+		{
+			10, 20,
+			[]Delta{
+				{Name: "goroutines created by a", Prev: 1, Cur: 11, Delta: 10},
+				{Name: "goroutines created by c", Prev: 0, Cur: 20, Delta: 20},
+				{Name: "open fds", Prev: 10, Cur: 30, Delta: 20},
+			},
+		},
+		{
+			15, 21,
+			[]Delta{
+				{Name: "goroutines created by c", Prev: 0, Cur: 20, Delta: 20},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		if got, want := leaks(prev, cur, tt.goroutines, tt.fds), tt.want; !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: got %v want %v", i, got, want)
+		}
+	}
+}
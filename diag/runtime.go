@@ -0,0 +1,81 @@
+package diag
+
+import (
+	"log"
+	"runtime"
+	"time"
+
+	"github.com/eBay/fabio/metrics"
+)
+
+// Runtime contains statistics of the Go runtime.
+type Runtime struct {
+	Time       time.Time `json:"time"`
+	Goroutines int       `json:"goroutines"`
+
+	// FDs is the number of open file descriptors
+	// or -1 if it cannot be determined.
+	FDs int `json:"fds"`
+
+	HeapAlloc   uint64 `json:"heap_alloc"`
+	HeapSys     uint64 `json:"heap_sys"`
+	HeapObjects uint64 `json:"heap_objects"`
+
+	NumGC        uint32        `json:"num_gc"`
+	LastGCPause  time.Duration `json:"last_gc_pause"`
+	TotalGCPause time.Duration `json:"total_gc_pause"`
+}
+
+// ReadRuntime returns the current runtime statistics.
+func ReadRuntime() *Runtime {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	var last time.Duration
+	if m.NumGC > 0 {
+		last = time.Duration(m.PauseNs[(m.NumGC+255)%256])
+	}
+	return &Runtime{
+		Time:         time.Now(),
+		Goroutines:   runtime.NumGoroutine(),
+		FDs:          countFDs(),
+		HeapAlloc:    m.HeapAlloc,
+		HeapSys:      m.HeapSys,
+		HeapObjects:  m.HeapObjects,
+		NumGC:        m.NumGC,
+		LastGCPause:  last,
+		TotalGCPause: time.Duration(m.PauseTotalNs),
+	}
+}
+
+// updateRuntimeGauges sets the 'runtime.*' gauges in the
+// registry to the values of the runtime statistics.
+func updateRuntimeGauges(r metrics.Registry, s *Runtime) {
+	gauges := map[string]int64{
+		"runtime.goroutines":        int64(s.Goroutines),
+		"runtime.heap.alloc":        int64(s.HeapAlloc),
+		"runtime.heap.sys":          int64(s.HeapSys),
+		"runtime.heap.objects":      int64(s.HeapObjects),
+		"runtime.gc.count":          int64(s.NumGC),
+		"runtime.gc.pause_last_ns":  int64(s.LastGCPause),
+		"runtime.gc.pause_total_ns": int64(s.TotalGCPause),
+	}
+	if s.FDs >= 0 {
+		gauges["runtime.fds"] = int64(s.FDs)
+	}
+	for name, v := range gauges {
+		r.GetGauge(name).Update(v)
+	}
+}
+
+// ReportRuntime updates the runtime gauges in the
+// registry in the given interval.
+func ReportRuntime(r metrics.Registry, interval time.Duration) {
+	log.Printf("[INFO] Reporting runtime metrics every %s", interval)
+	go func() {
+		for {
+			updateRuntimeGauges(r, ReadRuntime())
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,47 @@
+package diag
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/metrics"
+)
+
+type gaugeRegistry struct {
+	metrics.NoopRegistry
+	values map[string]int64
+}
+
+func (r *gaugeRegistry) GetGauge(name string) metrics.Gauge {
+	return gauge(func(n int64) { r.values[name] = n })
+}
+
+type gauge func(n int64)
+
+func (g gauge) Update(n int64) { g(n) }
+
+func TestUpdateRuntimeGauges(t *testing.T) {
+	r := &gaugeRegistry{values: map[string]int64{}}
+	s := &Runtime{Goroutines: 5, FDs: -1, HeapAlloc: 100, NumGC: 2, LastGCPause: time.Millisecond}
+	updateRuntimeGauges(r, s)
+
+	if got, want := r.values["runtime.goroutines"], int64(5); got != want {
+		t.Errorf("got %d goroutines want %d", got, want)
+	}
+	if got, want := r.values["runtime.heap.alloc"], int64(100); got != want {
+		t.Errorf("got heap alloc %d want %d", got, want)
+	}
+	if got, want := r.values["runtime.gc.pause_last_ns"], int64(time.Millisecond); got != want {
+		t.Errorf("got last gc pause %d want %d", got, want)
+	}
+	if _, ok := r.values["runtime.fds"]; ok {
+		t.Error("got fds gauge for unknown number of fds")
+	}
+}
+
+func TestReadRuntime(t *testing.T) {
+	s := ReadRuntime()
+	if s.Goroutines < 1 || s.HeapSys == 0 {
+		t.Fatalf("got %+v want goroutines and heap", s)
+	}
+}
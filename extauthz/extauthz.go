@@ -0,0 +1,145 @@
+// Package extauthz implements the external authorization for routes
+// with the 'extauthz' option.
+//
+// For every request fabio asks the authorization service with a GET
+// request which has the method, host, URI and client address of the
+// original request in X-Forwarded-* headers and the configured request
+// headers. A 2xx response allows the request and the configured response
+// headers are added to it. Other responses are sent to the client. If the
+// service cannot be reached or responds with a 5xx status the request is
+// rejected with 503 or allowed if the service is configured to fail open.
+package extauthz
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/eBay/fabio/config"
+)
+
+// Services contains the configured authorization services by name.
+var Services = map[string]*Service{}
+
+// maxBodySize limits the size of a denying response which
+// is sent to the client.
+const maxBodySize = 64 * 1024
+
+// deniedHeaders are the headers of a denying response
+// which are sent to the client.
+var deniedHeaders = []string{"Content-Type", "Location", "Set-Cookie", "Www-Authenticate"}
+
+// Service authorizes requests with an external service.
+type Service struct {
+	cfg    config.ExtAuthz
+	client *http.Client
+}
+
+// New creates an authorization service for the config.
+func New(cfg config.ExtAuthz) *Service {
+	return &Service{
+		cfg: cfg,
+		client: &http.Client{
+			// redirects of the service are sent to the client
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// Authorize asks the authorization service whether the request is
+// allowed and writes the response of the service if it is not. It
+// returns the status code of the response or zero if the request is
+// allowed. The configured response headers are removed from all
+// requests and set from the response of the service for allowed
+// requests so that clients cannot forge them.
+func (s *Service) Authorize(w http.ResponseWriter, r *http.Request) int {
+	for _, h := range s.cfg.ResponseHeaders {
+		r.Header.Del(h)
+	}
+
+	resp, err := s.check(r)
+	if err == nil && resp.StatusCode >= 500 {
+		resp.Body.Close()
+		err = fmt.Errorf("service responded with %s", resp.Status)
+	}
+	if err != nil {
+		if s.cfg.FailOpen {
+			log.Printf("[WARN] extauthz: %s failed for %s%s. Allowing request. %s", s.cfg.Name, r.Host, r.URL, err)
+			return 0
+		}
+		log.Printf("[WARN] extauthz: %s failed for %s%s. %s", s.cfg.Name, r.Host, r.URL, err)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return http.StatusServiceUnavailable
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		for _, h := range s.cfg.ResponseHeaders {
+			if v, ok := resp.Header[h]; ok {
+				r.Header[h] = v
+			}
+		}
+		return 0
+	}
+
+	for _, h := range deniedHeaders {
+		if v, ok := resp.Header[h]; ok {
+			w.Header()[h] = v
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, io.LimitReader(resp.Body, maxBodySize))
+	return resp.StatusCode
+}
+
+// check sends the authorization request for r to the service.
+func (s *Service) check(r *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.Timeout)
+	req, err := http.NewRequest("GET", s.cfg.URL, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	for _, h := range s.cfg.Headers {
+		if v, ok := r.Header[h]; ok {
+			req.Header[h] = v
+		}
+	}
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+	req.Header.Set("X-Forwarded-Method", r.Method)
+	req.Header.Set("X-Forwarded-Proto", proto)
+	req.Header.Set("X-Forwarded-Host", r.Host)
+	req.Header.Set("X-Forwarded-Uri", r.URL.RequestURI())
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		req.Header.Set("X-Forwarded-For", ip)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody cancels the context of the request when
+// the body of the response is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
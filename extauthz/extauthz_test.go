@@ -0,0 +1,100 @@
+package extauthz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/config"
+)
+
+func TestServiceAuthorize(t *testing.T) {
+	var got http.Header
+	authz := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		switch r.Header.Get("Authorization") {
+		case "ok":
+			w.Header().Set("X-User", "alice")
+		case "login":
+			http.Redirect(w, r, "https://login/", http.StatusFound)
+		case "slow":
+			time.Sleep(200 * time.Millisecond)
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.Header().Set("X-Secret", "internal")
+			http.Error(w, "denied", http.StatusUnauthorized)
+		}
+	}))
+	defer authz.Close()
+
+	cfg := config.ExtAuthz{
+		Name:            "a",
+		URL:             authz.URL + "/check",
+		Timeout:         50 * time.Millisecond,
+		Headers:         []string{"Authorization"},
+		ResponseHeaders: []string{"X-User"},
+	}
+
+	tests := []struct {
+		desc     string
+		auth     string
+		failOpen bool
+		code     int
+		user     string
+		location string
+	}{
+		{"allowed", "ok", false, 0, "alice", ""},
+		{"denied", "bad", false, 401, "", ""},
+		{"redirect", "login", false, 302, "", "https://login/"},
+		{"timeout", "slow", false, 503, "", ""},
+		{"timeout fail open", "slow", true, 0, "", ""},
+		{"service error", "broken", false, 503, "", ""},
+		{"service error fail open", "broken", true, 0, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			c := cfg
+			c.FailOpen = tt.failOpen
+			s := New(c)
+
+			r := httptest.NewRequest("POST", "http://app.com/x?y=1", nil)
+			r.Header.Set("Authorization", tt.auth)
+			r.Header.Set("Cookie", "a=b")
+			r.Header.Set("X-User", "mallory")
+			w := httptest.NewRecorder()
+
+			if got, want := s.Authorize(w, r), tt.code; got != want {
+				t.Fatalf("got code %d want %d", got, want)
+			}
+			if got, want := r.Header.Get("X-User"), tt.user; got != want {
+				t.Fatalf("got user %q want %q", got, want)
+			}
+			if got, want := w.Header().Get("Location"), tt.location; got != want {
+				t.Fatalf("got location %q want %q", got, want)
+			}
+			if w.Header().Get("X-Secret") != "" {
+				t.Fatal("got internal header of the service")
+			}
+			if tt.code == 401 && w.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Fatal("missing WWW-Authenticate header")
+			}
+		})
+	}
+
+	want := map[string]string{
+		"X-Forwarded-Method": "POST",
+		"X-Forwarded-Host":   "app.com",
+		"X-Forwarded-Uri":    "/x?y=1",
+		"X-Forwarded-Proto":  "http",
+		"Cookie":             "",
+	}
+	for h, v := range want {
+		if got.Get(h) != v {
+			t.Errorf("got %s %q want %q", h, got.Get(h), v)
+		}
+	}
+}
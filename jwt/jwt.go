@@ -0,0 +1,359 @@
+// Package jwt implements the validation of JSON web tokens for
+// routes with the 'jwt' option.
+//
+// The tokens are verified with the public keys from the JSON web
+// key set of the issuer which is loaded from a URL or a file and
+// refreshed periodically. Only the RS, PS and ES algorithms are
+// supported.
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eBay/fabio/config"
+)
+
+// Issuers contains the configured issuers by name.
+var Issuers = map[string]*Issuer{}
+
+var (
+	// ErrInvalidToken is returned for tokens which are malformed,
+	// expired or not signed by the issuer.
+	ErrInvalidToken = errors.New("jwt: invalid token")
+
+	// ErrInsufficientClaims is returned for valid tokens which
+	// do not have the required claims.
+	ErrInsufficientClaims = errors.New("jwt: insufficient claims")
+)
+
+// leeway is the allowed clock skew for the 'exp'
+// and 'nbf' claims.
+const leeway = 30 * time.Second
+
+// minReload is the minimum time between two loads of the key set
+// when a token is signed with an unknown key.
+var minReload = 10 * time.Second
+
+// now returns the current time and can be replaced in tests.
+var now = time.Now
+
+// Issuer validates the tokens of a configured issuer.
+type Issuer struct {
+	cfg  config.JWTIssuer
+	load func() ([]byte, error)
+	keys atomic.Value // map[string]crypto.PublicKey
+
+	mu     sync.Mutex
+	loaded time.Time
+}
+
+// New creates an issuer for the config and loads the key set.
+// If the refresh interval is positive the key set is reloaded
+// in the background.
+func New(cfg config.JWTIssuer) (*Issuer, error) {
+	i := &Issuer{cfg: cfg, load: loader(cfg.JWKS)}
+	if err := i.reload(); err != nil {
+		return nil, err
+	}
+	if cfg.Refresh > 0 {
+		go i.refresh(cfg.Refresh)
+	}
+	return i, nil
+}
+
+// loader returns a function which loads the key set
+// from an http(s) URL or a file.
+func loader(src string) func() ([]byte, error) {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		return func() ([]byte, error) { return ioutil.ReadFile(src) }
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	return func() ([]byte, error) {
+		resp, err := client.Get(src)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.New("jwt: cannot load key set. " + resp.Status)
+		}
+		return ioutil.ReadAll(resp.Body)
+	}
+}
+
+func (i *Issuer) reload() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.loaded = now()
+	b, err := i.load()
+	if err != nil {
+		return err
+	}
+	keys, err := parseKeySet(b)
+	if err != nil {
+		return err
+	}
+	i.keys.Store(keys)
+	return nil
+}
+
+// refresh reloads the key set periodically and keeps
+// the current keys if the key set cannot be loaded.
+func (i *Issuer) refresh(d time.Duration) {
+	for range time.Tick(d) {
+		if err := i.reload(); err != nil {
+			log.Printf("[WARN] jwt: Cannot refresh key set for issuer %s. %s", i.cfg.Name, err)
+		}
+	}
+}
+
+// key returns the key with the given id. If the key is unknown
+// the key set is reloaded unless it was loaded recently.
+func (i *Issuer) key(kid string) crypto.PublicKey {
+	if k, ok := i.keys.Load().(map[string]crypto.PublicKey)[kid]; ok {
+		return k
+	}
+	i.mu.Lock()
+	stale := now().Sub(i.loaded) >= minReload
+	i.mu.Unlock()
+	if !stale {
+		return nil
+	}
+	if err := i.reload(); err != nil {
+		log.Printf("[WARN] jwt: Cannot reload key set for issuer %s. %s", i.cfg.Name, err)
+		return nil
+	}
+	return i.keys.Load().(map[string]crypto.PublicKey)[kid]
+}
+
+// Verify validates the token and returns its claims. It returns
+// ErrInvalidToken if the token is not valid and ErrInsufficientClaims
+// if the token does not have the required claims.
+func (i *Issuer) Verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var hdr struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJSON(parts[0], &hdr); err != nil {
+		return nil, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	key := i.key(hdr.Kid)
+	if key == nil || !verify(hdr.Alg, key, parts[0]+"."+parts[1], sig) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJSON(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !i.valid(claims) {
+		return nil, ErrInvalidToken
+	}
+	for name, want := range i.cfg.Claims {
+		if !hasClaim(claims[name], want) {
+			return nil, ErrInsufficientClaims
+		}
+	}
+	return claims, nil
+}
+
+// valid checks the time, issuer and audience claims.
+func (i *Issuer) valid(claims map[string]interface{}) bool {
+	t := now()
+	if exp, ok := claims["exp"]; ok {
+		n, ok := exp.(json.Number)
+		if !ok || !t.Add(-leeway).Before(unixTime(n)) {
+			return false
+		}
+	}
+	if nbf, ok := claims["nbf"]; ok {
+		n, ok := nbf.(json.Number)
+		if !ok || t.Add(leeway).Before(unixTime(n)) {
+			return false
+		}
+	}
+	if i.cfg.Issuer != "" && claims["iss"] != i.cfg.Issuer {
+		return false
+	}
+	if i.cfg.Audience != "" {
+		switch aud := claims["aud"].(type) {
+		case string:
+			return aud == i.cfg.Audience
+		case []interface{}:
+			for _, a := range aud {
+				if a == i.cfg.Audience {
+					return true
+				}
+			}
+			return false
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Headers returns the values of the configured request headers
+// from the claims by header name. Claims which are not set or
+// are objects are omitted.
+func (i *Issuer) Headers(claims map[string]interface{}) map[string]string {
+	hdr := map[string]string{}
+	for name, header := range i.cfg.Headers {
+		if v, ok := claimString(claims[name]); ok {
+			hdr[header] = v
+		}
+	}
+	return hdr
+}
+
+// HeaderNames returns the names of the configured request headers.
+func (i *Issuer) HeaderNames() []string {
+	var names []string
+	for _, header := range i.cfg.Headers {
+		names = append(names, header)
+	}
+	return names
+}
+
+// hasClaim returns true if the claim has the wanted value. An empty
+// value only requires the claim to be present. String claims match
+// the value or one of their space separated values like 'scope' and
+// array claims match if they contain the value.
+func hasClaim(v interface{}, want string) bool {
+	if v == nil {
+		return false
+	}
+	if want == "" {
+		return true
+	}
+	switch x := v.(type) {
+	case string:
+		for _, f := range strings.Fields(x) {
+			if f == want {
+				return true
+			}
+		}
+		return x == want
+	case []interface{}:
+		for _, e := range x {
+			if s, ok := claimString(e); ok && s == want {
+				return true
+			}
+		}
+		return false
+	default:
+		s, ok := claimString(x)
+		return ok && s == want
+	}
+}
+
+// claimString returns the string value of a claim. Arrays
+// are returned as comma separated list.
+func claimString(v interface{}) (string, bool) {
+	switch x := v.(type) {
+	case string:
+		return x, true
+	case json.Number:
+		return x.String(), true
+	case bool:
+		return strconv.FormatBool(x), true
+	case []interface{}:
+		var list []string
+		for _, e := range x {
+			if s, ok := claimString(e); ok {
+				list = append(list, s)
+			}
+		}
+		return strings.Join(list, ","), true
+	default:
+		return "", false
+	}
+}
+
+// verify checks the signature of the signed part of the token
+// with the key for the algorithm.
+func verify(alg string, key crypto.PublicKey, signed string, sig []byte) bool {
+	if len(alg) != 5 {
+		return false
+	}
+	var hash crypto.Hash
+	switch alg[len(alg)-3:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return false
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch alg[:len(alg)-3] {
+	case "RS":
+		k, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(k, hash, digest, sig) == nil
+	case "PS":
+		k, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPSS(k, hash, digest, sig, nil) == nil
+	case "ES":
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok || k.Curve != curves[alg] {
+			return false
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	default:
+		return false
+	}
+}
+
+func decodeJSON(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	d := json.NewDecoder(strings.NewReader(string(b)))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+func unixTime(n json.Number) time.Time {
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(int64(f), 0)
+}
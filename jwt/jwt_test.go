@@ -0,0 +1,168 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/config"
+)
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+// sign creates a token with the given header and claims.
+func sign(t *testing.T, key crypto.Signer, alg, kid string, claims map[string]interface{}) string {
+	hdr, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	body, _ := json.Marshal(claims)
+	signed := b64(hdr) + "." + b64(body)
+
+	h := crypto.SHA256.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var sig []byte
+	var err error
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest)
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest)
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + b64(sig)
+}
+
+func TestIssuerVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"r1","use":"sig","n":%q,"e":%q},
+		{"kty":"EC","kid":"e1","crv":"P-256","x":%q,"y":%q},
+		{"kty":"oct","kid":"s1","k":"c2VjcmV0"}
+	]}`,
+		b64(rsaKey.N.Bytes()), b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		b64(ecKey.X.Bytes()), b64(ecKey.Y.Bytes()),
+	)
+
+	iss := &Issuer{
+		cfg: config.JWTIssuer{
+			Name:     "sso",
+			Issuer:   "https://sso",
+			Audience: "api",
+			Claims:   map[string]string{"scope": "read", "sub": ""},
+			Headers:  map[string]string{"sub": "X-User", "groups": "X-Groups"},
+		},
+		load: func() ([]byte, error) { return []byte(jwks), nil },
+	}
+	if err := iss.reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+	claims := func(kv ...interface{}) map[string]interface{} {
+		m := map[string]interface{}{"iss": "https://sso", "aud": "api", "sub": "alice", "scope": "read write", "exp": exp}
+		for i := 0; i < len(kv); i += 2 {
+			if kv[i+1] == nil {
+				delete(m, kv[i].(string))
+				continue
+			}
+			m[kv[i].(string)] = kv[i+1]
+		}
+		return m
+	}
+
+	tests := []struct {
+		desc  string
+		token string
+		err   error
+	}{
+		{"RS256", sign(t, rsaKey, "RS256", "r1", claims()), nil},
+		{"ES256", sign(t, ecKey, "ES256", "e1", claims()), nil},
+		{"aud array", sign(t, rsaKey, "RS256", "r1", claims("aud", []string{"web", "api"})), nil},
+		{"malformed", "abc.def", ErrInvalidToken},
+		{"wrong key", sign(t, otherKey, "RS256", "r1", claims()), ErrInvalidToken},
+		{"unknown kid", sign(t, rsaKey, "RS256", "r2", claims()), ErrInvalidToken},
+		{"alg mismatch", sign(t, rsaKey, "ES256", "r1", claims()), ErrInvalidToken},
+		{"alg none", sign(t, rsaKey, "none", "r1", claims()), ErrInvalidToken},
+		{"alg HS256", sign(t, rsaKey, "HS256", "s1", claims()), ErrInvalidToken},
+		{"expired", sign(t, rsaKey, "RS256", "r1", claims("exp", time.Now().Add(-time.Hour).Unix())), ErrInvalidToken},
+		{"not before", sign(t, rsaKey, "RS256", "r1", claims("nbf", time.Now().Add(time.Hour).Unix())), ErrInvalidToken},
+		{"wrong issuer", sign(t, rsaKey, "RS256", "r1", claims("iss", "https://other")), ErrInvalidToken},
+		{"wrong audience", sign(t, rsaKey, "RS256", "r1", claims("aud", "web")), ErrInvalidToken},
+		{"missing claim", sign(t, rsaKey, "RS256", "r1", claims("sub", nil)), ErrInsufficientClaims},
+		{"wrong claim", sign(t, rsaKey, "RS256", "r1", claims("scope", "write")), ErrInsufficientClaims},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := iss.Verify(tt.token)
+			if got, want := err, tt.err; got != want {
+				t.Fatalf("got %v want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestIssuerHeaders(t *testing.T) {
+	iss := &Issuer{cfg: config.JWTIssuer{Headers: map[string]string{"sub": "X-User", "groups": "X-Groups", "admin": "X-Admin", "n": "X-N", "obj": "X-Obj"}}}
+	claims := map[string]interface{}{
+		"sub":    "alice",
+		"groups": []interface{}{"dev", "ops"},
+		"admin":  true,
+		"n":      json.Number("42"),
+		"obj":    map[string]interface{}{"a": "b"},
+	}
+	want := map[string]string{"X-User": "alice", "X-Groups": "dev,ops", "X-Admin": "true", "X-N": "42"}
+	if got := iss.Headers(claims); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
+func TestHasClaim(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want string
+		ok   bool
+	}{
+		{nil, "", false},
+		{"x", "", true},
+		{"read write", "write", true},
+		{"read write", "read write", true},
+		{"read", "write", false},
+		{[]interface{}{"a", "b"}, "b", true},
+		{[]interface{}{"a", "b"}, "c", false},
+		{json.Number("1"), "1", true},
+		{true, "true", true},
+	}
+	for i, tt := range tests {
+		if got, want := hasClaim(tt.v, tt.want), tt.ok; got != want {
+			t.Errorf("%d: got %v want %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"os"
+	"sync"
+)
+
+// File is a log file which can be reopened, e.g. after it has been
+// moved away by logrotate. Writes are not buffered so that no log
+// entries are lost when the file is reopened.
+type File struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenFile opens the log file at path for appending and creates it if
+// it does not exist.
+func OpenFile(path string) (*File, error) {
+	f, err := openLogFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &File{path: path, f: f}, nil
+}
+
+func openLogFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+}
+
+// Path returns the path of the log file.
+func (f *File) Path() string {
+	return f.path
+}
+
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.f.Write(p)
+}
+
+// Reopen opens the log file again and closes the previous file handle.
+// If the file cannot be opened the previous file handle is kept.
+func (f *File) Reopen() error {
+	nf, err := openLogFile(f.path)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	old := f.f
+	f.f = nf
+	f.mu.Unlock()
+	return old.Close()
+}
+
+// Close closes the log file.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.f.Close()
+}
@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabio-logger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "access.log")
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// rotate the file like logrotate does
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("b\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("c\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(path string) string {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	if got, want := read(path+".1"), "a\nb\n"; got != want {
+		t.Fatalf("got rotated file %q want %q", got, want)
+	}
+	if got, want := read(path), "c\n"; got != want {
+		t.Fatalf("got log file %q want %q", got, want)
+	}
+}
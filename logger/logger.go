@@ -0,0 +1,204 @@
+// Package logger implements the access log for the HTTP proxy.
+//
+// The format of a log line is described by a template of $field
+// variables which are replaced with the values from the request,
+// the response and the upstream target. All other text is copied
+// verbatim. The predefined formats 'common' and 'combined' are
+// the Common Log Format and the Combined Log Format.
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default stores the access logger. Access logging is disabled if it is nil.
+var Default *Logger
+
+// Formats contains the predefined log formats.
+var Formats = map[string]string{
+	"common":   `$remote_host - - [$time_common] "$request" $response_status $response_body_size`,
+	"combined": `$remote_host - - [$time_common] "$request" $response_status $response_body_size "$header.Referer" "$header.User-Agent"`,
+}
+
+// Event contains the values of a single proxied request.
+type Event struct {
+	Start, End time.Time
+
+	Request          *http.Request
+	RequestID        string
+	ResponseStatus   int
+	ResponseBodySize int64
+
+	UpstreamService string
+	UpstreamURL     *url.URL
+}
+
+// field writes a value of the event to the buffer.
+type field func(b *bytes.Buffer, e *Event)
+
+var fields = map[string]field{
+	"remote_host": func(b *bytes.Buffer, e *Event) {
+		host, _, err := net.SplitHostPort(e.Request.RemoteAddr)
+		if err != nil {
+			host = e.Request.RemoteAddr
+		}
+		b.WriteString(host)
+	},
+	"time_common": func(b *bytes.Buffer, e *Event) {
+		b.WriteString(e.End.Format("02/Jan/2006:15:04:05 -0700"))
+	},
+	"time_rfc3339": func(b *bytes.Buffer, e *Event) {
+		b.WriteString(e.End.Format(time.RFC3339))
+	},
+	"request": func(b *bytes.Buffer, e *Event) {
+		b.WriteString(e.Request.Method)
+		b.WriteByte(' ')
+		b.WriteString(e.Request.RequestURI)
+		b.WriteByte(' ')
+		b.WriteString(e.Request.Proto)
+	},
+	"request_method": func(b *bytes.Buffer, e *Event) {
+		b.WriteString(e.Request.Method)
+	},
+	"request_uri": func(b *bytes.Buffer, e *Event) {
+		b.WriteString(e.Request.RequestURI)
+	},
+	"request_host": func(b *bytes.Buffer, e *Event) {
+		b.WriteString(e.Request.Host)
+	},
+	"request_proto": func(b *bytes.Buffer, e *Event) {
+		b.WriteString(e.Request.Proto)
+	},
+	"request_id": func(b *bytes.Buffer, e *Event) {
+		writeOrDash(b, e.RequestID)
+	},
+	"response_status": func(b *bytes.Buffer, e *Event) {
+		b.WriteString(strconv.Itoa(e.ResponseStatus))
+	},
+	"response_body_size": func(b *bytes.Buffer, e *Event) {
+		b.WriteString(strconv.FormatInt(e.ResponseBodySize, 10))
+	},
+	"response_time_ms": func(b *bytes.Buffer, e *Event) {
+		b.WriteString(strconv.FormatFloat(e.End.Sub(e.Start).Seconds()*1000, 'f', 3, 64))
+	},
+	"upstream_service": func(b *bytes.Buffer, e *Event) {
+		writeOrDash(b, e.UpstreamService)
+	},
+	"upstream_addr": func(b *bytes.Buffer, e *Event) {
+		if e.UpstreamURL == nil {
+			b.WriteByte('-')
+			return
+		}
+		b.WriteString(e.UpstreamURL.Host)
+	},
+}
+
+func writeOrDash(b *bytes.Buffer, s string) {
+	if s == "" {
+		b.WriteByte('-')
+		return
+	}
+	b.WriteString(s)
+}
+
+// Logger writes access log lines for events in a given format.
+type Logger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	fields []field
+}
+
+// New creates an access logger which writes to w. format is either
+// the name of a predefined format or a template of $field variables.
+func New(w io.Writer, format string) (*Logger, error) {
+	if f, ok := Formats[format]; ok {
+		format = f
+	}
+	fields, err := parse(format)
+	if err != nil {
+		return nil, err
+	}
+	return &Logger{w: w, fields: fields}, nil
+}
+
+// Log writes the log line for the event. It is a no-op on a nil logger.
+func (l *Logger) Log(e *Event) {
+	if l == nil {
+		return
+	}
+	var b bytes.Buffer
+	for _, f := range l.fields {
+		f(&b, e)
+	}
+	b.WriteByte('\n')
+
+	l.mu.Lock()
+	l.w.Write(b.Bytes())
+	l.mu.Unlock()
+}
+
+// parse converts the format into a list of fields. Variables
+// start with '$' and consist of letters, digits, '_', '-' and '.'.
+// A '$' which is not followed by a name is copied verbatim.
+// The '$header.<name>' variable is replaced with the value of the
+// request header <name>.
+func parse(format string) ([]field, error) {
+	var fs []field
+	text := func(s string) {
+		if s != "" {
+			fs = append(fs, func(b *bytes.Buffer, _ *Event) { b.WriteString(s) })
+		}
+	}
+
+	for {
+		n := strings.IndexByte(format, '$')
+		if n < 0 {
+			text(format)
+			return fs, nil
+		}
+		text(format[:n])
+		format = format[n+1:]
+
+		m := strings.IndexFunc(format, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' || r == '-')
+		})
+		if m < 0 {
+			m = len(format)
+		}
+		name := format[:m]
+		format = format[m:]
+
+		switch {
+		case name == "":
+			text("$")
+		case strings.HasPrefix(name, "header."):
+			hdr := name[len("header."):]
+			fs = append(fs, func(b *bytes.Buffer, e *Event) { writeOrDash(b, e.Request.Header.Get(hdr)) })
+		case fields[name] != nil:
+			fs = append(fs, fields[name])
+		default:
+			return nil, fmt.Errorf("logger: invalid field %q. Valid fields are %s", name, strings.Join(Fields(), ", "))
+		}
+	}
+}
+
+// Fields returns the sorted list of field names.
+func Fields() []string {
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	names = append(names, "header.<name>")
+	sort.Strings(names)
+	return names
+}
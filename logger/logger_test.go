@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestLog(t *testing.T) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := &Event{
+		Start: start,
+		End:   start.Add(1500 * time.Microsecond),
+		Request: &http.Request{
+			Method:     "GET",
+			RequestURI: "/foo?x=y",
+			Proto:      "HTTP/1.1",
+			Host:       "example.com",
+			RemoteAddr: "2.2.2.2:666",
+			Header:     http.Header{"User-Agent": {"curl"}},
+		},
+		RequestID:        "abc",
+		ResponseStatus:   200,
+		ResponseBodySize: 1234,
+		UpstreamService:  "svc",
+		UpstreamURL:      &url.URL{Scheme: "http", Host: "1.2.3.4:5000"},
+	}
+
+	tests := []struct {
+		format, line string
+	}{
+		{"common", `2.2.2.2 - - [01/Jan/2016:00:00:00 +0000] "GET /foo?x=y HTTP/1.1" 200 1234`},
+		{"combined", `2.2.2.2 - - [01/Jan/2016:00:00:00 +0000] "GET /foo?x=y HTTP/1.1" 200 1234 "-" "curl"`},
+		{"$request_id $upstream_service $upstream_addr $response_time_ms", `abc svc 1.2.3.4:5000 1.500`},
+		{"$request_method:$request_host$request_uri [$time_rfc3339]", `GET:example.com/foo?x=y [2016-01-01T00:00:00Z]`},
+		{"$$header.X-Foo$", `$-$`},
+	}
+
+	for i, tt := range tests {
+		var b bytes.Buffer
+		l, err := New(&b, tt.format)
+		if err != nil {
+			t.Fatalf("%d: got %v want nil", i, err)
+		}
+		l.Log(e)
+		if got, want := b.String(), tt.line+"\n"; got != want {
+			t.Errorf("%d: got %q want %q", i, got, want)
+		}
+	}
+}
+
+func TestNewInvalidField(t *testing.T) {
+	if _, err := New(nil, "$foo"); err == nil {
+		t.Fatal("got nil want error")
+	}
+}
@@ -1,25 +1,39 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
-	"net"
-	"net/http"
 	"os"
+	"os/signal"
+	"reflect"
 	"runtime"
 	"runtime/debug"
+	"syscall"
 
 	"github.com/eBay/fabio/admin"
+	"github.com/eBay/fabio/admin/api"
+	"github.com/eBay/fabio/auth"
+	"github.com/eBay/fabio/cert"
 	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/diag"
 	"github.com/eBay/fabio/exit"
+	"github.com/eBay/fabio/extauthz"
+	"github.com/eBay/fabio/jwt"
+	"github.com/eBay/fabio/logger"
 	"github.com/eBay/fabio/metrics"
+	"github.com/eBay/fabio/oidc"
 	"github.com/eBay/fabio/proxy"
 	"github.com/eBay/fabio/registry"
 	"github.com/eBay/fabio/registry/consul"
-	"github.com/eBay/fabio/registry/file"
-	"github.com/eBay/fabio/registry/static"
+	_ "github.com/eBay/fabio/registry/dns"
+	_ "github.com/eBay/fabio/registry/file"
+	_ "github.com/eBay/fabio/registry/remote"
+	_ "github.com/eBay/fabio/registry/static"
 	"github.com/eBay/fabio/route"
+	"github.com/eBay/fabio/server"
+	"github.com/eBay/fabio/tracing"
 )
 
 // version contains the version number
@@ -43,201 +57,233 @@ func main() {
 		return
 	}
 
+	var cfgIndex uint64
+	if cfg.Registry.Consul.ConfigKVPath != "" {
+		cfg, cfgIndex = loadConsulConfig(cfg)
+	}
+
 	// 打印启动信息
 	log.Printf("[INFO] Runtime config\n" + toJSON(cfg))
 	log.Printf("[INFO] Version %s starting", version)
 	log.Printf("[INFO] Go runtime is %s", runtime.Version())
 
-	// 加上程序退出监听goroutine
-	exit.Listen(func(s os.Signal) {
-		if registry.Default == nil {
-			return
-		}
-		// 从fabio移除服务注册信息
-		registry.Default.Deregister()
-	})
-
 	// Proxy
 	/*
-	"Proxy": {
-		"Strategy": "rnd",
-		"Matcher": "prefix",
-		"NoRouteStatus": 404,
-		"MaxConn": 10000,
-		"ShutdownWait": 0,
-		"DialTimeout": 30000000000,
-		"ResponseHeaderTimeout": 0,
-		"KeepAliveTimeout": 0,
-		"ReadTimeout": 0,
-		"WriteTimeout": 0,
-		"FlushInterval": 1000000000,
-		"LocalIP": "192.168.3.101",
-		"ClientIPHeader": "",
-		"TLSHeader": "",
-		"TLSHeaderValue": "",
-		"GZIPContentTypesValue": "",
-		"GZIPContentTypes": null
-	    },
-
-	 */
-
-	// 创建HTTP代理的句柄
-	httpProxy := newHTTPProxy(cfg)
-	// @todo 了解业务流程
-	// SNI 即 Server Name Indication 是用来改善
-	// SSL(Secure Socket Layer)和TLS(Transport Layer Security)的一项特性。
-	// 它允许客户端在服务器端向其发送证书之前请求服务器的域名。这对于在虚拟主机模式使用TLS是必要的。
-	//
-	// 即提供 HTTPS 服务, 返回 tcpSNIProxy 结构体
-	tcpProxy := proxy.NewTCPSNIProxy(cfg.Proxy)
+		"Proxy": {
+			"Strategy": "rnd",
+			"Matcher": "prefix",
+			"NoRouteStatus": 404,
+			"MaxConn": 10000,
+			"ShutdownWait": 0,
+			"DialTimeout": 30000000000,
+			"ResponseHeaderTimeout": 0,
+			"KeepAliveTimeout": 0,
+			"ReadTimeout": 0,
+			"WriteTimeout": 0,
+			"FlushInterval": 1000000000,
+			"LocalIP": "192.168.3.101",
+			"ClientIPHeader": "",
+			"TLSHeader": "",
+			"TLSHeaderValue": "",
+			"GZIPContentTypesValue": "",
+			"GZIPContentTypes": null
+		    },
+
+	*/
+
+	// 创建服务器，HTTP 代理和 TCP+SNI 代理在 Start 中创建
+	srv := server.New(cfg)
 
 	// 初始化运行时
 	/*
-	"Runtime": {
-		"GOGC": 800,
-		"GOMAXPROCS": 4
-	    },
+		"Runtime": {
+			"GOGC": 800,
+			"GOMAXPROCS": 4
+		    },
 
-	 */
+	*/
 	initRuntime(cfg)
 	// 设置Metrics监控系统的配置信息，以及路由的服务注册信息
 	/*
-	"Metrics": {
-		"Target": "",
-		"Prefix": "{{clean .Hostname}}.{{clean .Exec}}",
-		"Names": "{{clean .Service}}.{{clean .Host}}.{{clean .Path}}.{{clean .TargetURL.Host}}",
-		"Interval": 30000000000,
-		"GraphiteAddr": "",
-		"StatsDAddr": "",
-		"CirconusAPIKey": "",
-		"CirconusAPIApp": "fabio",
-		"CirconusAPIURL": "",
-		"CirconusCheckID": "",
-		"CirconusBrokerID": ""
-	    },
-	 */
+		"Metrics": {
+			"Target": "",
+			"Prefix": "{{clean .Hostname}}.{{clean .Exec}}",
+			"Names": "{{clean .Service}}.{{clean .Host}}.{{clean .Path}}.{{clean .TargetURL.Host}}",
+			"Interval": 30000000000,
+			"GraphiteAddr": "",
+			"StatsDAddr": "",
+			"CirconusAPIKey": "",
+			"CirconusAPIApp": "fabio",
+			"CirconusAPIURL": "",
+			"CirconusCheckID": "",
+			"CirconusBrokerID": ""
+		    },
+	*/
 	initMetrics(cfg)
+	initTracing(cfg)
+	initLogFiles(cfg)
+	initAuth(cfg)
+	initJWT(cfg)
+	initOIDC(cfg)
+	initExtAuthz(cfg)
+	initErrorPages(cfg)
+	initClientCerts(cfg)
+	initLeakDetector(cfg)
 	/*
-	 "Registry": {
-		"Backend": "consul",
-		"Static": {
-		    "Routes": ""
-		},
-		"File": {
-		    "Path": ""
-		},
-		"Consul": {
-		    "Addr": "localhost:8500",
-		    "Scheme": "http",
-		    "Token": "",
-		    "KVPath": "/fabio/config",
-		    "TagPrefix": "urlprefix-",
-		    "Register": true,
-		    "ServiceAddr": ":9998",
-		    "ServiceName": "fabio",
-		    "ServiceTags": null,
-		    "ServiceStatus": [
-			"passing"
-		    ],
-		    "CheckInterval": 1000000000,
-		    "CheckTimeout": 3000000000
-		}
-	    },
-
-	 */
+		 "Registry": {
+			"Backend": "consul",
+			"Static": {
+			    "Routes": ""
+			},
+			"File": {
+			    "Path": ""
+			},
+			"Consul": {
+			    "Addr": "localhost:8500",
+			    "Scheme": "http",
+			    "Token": "",
+			    "KVPath": "/fabio/config",
+			    "TagPrefix": "urlprefix-",
+			    "Register": true,
+			    "ServiceAddr": ":9998",
+			    "ServiceName": "fabio",
+			    "ServiceTags": null,
+			    "ServiceStatus": [
+				"passing"
+			    ],
+			    "CheckInterval": 1000000000,
+			    "CheckTimeout": 3000000000
+			}
+		    },
+
+	*/
 	// 初始化注册服务的后端配置信息
-	initBackend(cfg)
-	// 启动后端监听服务器
-	go watchBackend()
+	initBackend(cfg, srv)
 
 	/*
-	"UI": {
-		"Addr": ":9998",
-		"Color": "light-green",
-		"Title": ""
-	    },
-	 */
-	// 启动管理界面
+		"UI": {
+			"Addr": ":9998",
+			"Color": "light-green",
+			"Title": ""
+		    },
+	*/
+	// 启动管理界面，并通过管理 API 在运行时管理监听器
+	api.Listeners = srv
 	startAdmin(cfg)
 
 	/*
-	"Listen": [
-        {
-            "Addr": ":9999",
-            "Proto": "http",
-            "ReadTimeout": 0,
-            "WriteTimeout": 0,
-            "CertSource": {
-                "Name": "",
-                "Type": "",
-                "CertPath": "",
-                "KeyPath": "",
-                "ClientCAPath": "",
-                "CAUpgradeCN": "",
-                "Refresh": 0,
-                "Header": null
-            },
-            "StrictMatch": false
-        }
-    ],
-
-	 */
-	// 启动监听，开启服务器 @todo 了解业务流程
-	startListeners(cfg.Listen, cfg.Proxy.ShutdownWait, httpProxy, tcpProxy)
+			"Listen": [
+		        {
+		            "Addr": ":9999",
+		            "Proto": "http",
+		            "ReadTimeout": 0,
+		            "WriteTimeout": 0,
+		            "CertSource": {
+		                "Name": "",
+		                "Type": "",
+		                "CertPath": "",
+		                "KeyPath": "",
+		                "ClientCAPath": "",
+		                "CAUpgradeCN": "",
+		                "Refresh": 0,
+		                "Header": null
+		            },
+		            "StrictMatch": false
+		        }
+		    ],
+
+	*/
+	if cfg.Registry.Consul.ConfigKVPath != "" {
+		go watchConsulConfig(cfg, cfgIndex)
+	}
+
+	// 启动监听，开启服务器，收到退出信号后优雅关闭
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	exit.Listen(func(os.Signal) {
+		cancel()
+		<-done
+	})
+	if err := srv.Start(ctx); err != nil {
+		close(done)
+		exit.Fatal("[FATAL] ", err)
+	}
+	close(done)
 
 	//等待退出
 	exit.Wait()
 }
 
-/**
-  使用配置信息创建并返回HTTP代理服务器的句柄
- */
-func newHTTPProxy(cfg *config.Config) http.Handler {
-	// 设置路由拣选策略
-	if err := route.SetPickerStrategy(cfg.Proxy.Strategy); err != nil {
-		exit.Fatal("[FATAL] ", err)
+// loadConsulConfig reloads the config with the values from
+// the consul KV store and returns it with the KV index.
+func loadConsulConfig(cfg *config.Config) (*config.Config, uint64) {
+	kv, index, err := consul.ReadConfig(&cfg.Registry.Consul)
+	if err != nil {
+		exit.Fatalf("[FATAL] Cannot read config from consul. %s", err)
 	}
-	log.Printf("[INFO] Using routing strategy %q", cfg.Proxy.Strategy)
-
-	// 设置路由匹配器
-	if err := route.SetMatcher(cfg.Proxy.Matcher); err != nil {
-		exit.Fatal("[FATAL] ", err)
+	next, err := config.LoadWithOverrides(kv)
+	if err != nil {
+		exit.Fatalf("[FATAL] Invalid config in consul. %s", err)
 	}
-	log.Printf("[INFO] Using routing matching %q", cfg.Proxy.Matcher)
+	log.Printf("[INFO] Loaded %d config values from consul path %s", len(kv), cfg.Registry.Consul.ConfigKVPath)
+	return next, index
+}
 
-	// 配置转换器
-	tr := &http.Transport{
-		ResponseHeaderTimeout: cfg.Proxy.ResponseHeaderTimeout,
-		MaxIdleConnsPerHost:   cfg.Proxy.MaxConn,
-		Dial: (&net.Dialer{
-			Timeout:   cfg.Proxy.DialTimeout,
-			KeepAlive: cfg.Proxy.KeepAliveTimeout,
-		}).Dial,
+// watchConsulConfig watches the config values in the consul KV store.
+// The routing strategy and the matcher are updated immediately.
+// All other changes are logged and require a restart.
+func watchConsulConfig(cfg *config.Config, index uint64) {
+	kvc, err := consul.WatchConfig(&cfg.Registry.Consul, index)
+	if err != nil {
+		log.Printf("[WARN] Cannot watch config in consul. %s", err)
+		return
 	}
-	/**
-	@todo 上面代码中有疑问，如下代码：
 
-	Dial: (&net.Dialer{
-		Timeout:   cfg.Proxy.DialTimeout,
-		KeepAlive: cfg.Proxy.KeepAliveTimeout,
-	}).Dial
+	cur := *cfg
+	for kv := range kvc {
+		next, err := config.LoadWithOverrides(kv)
+		if err != nil {
+			log.Printf("[WARN] Ignoring invalid config from consul. %s", err)
+			continue
+		}
 
-	第一行为何用 &net.Dialer ? 即为何使用引用？
-	原因是 net包的Dialer结构体(struct)的方法Dial是指针类型，所以只有使用引用定义的时候才能访问到该函数
-	 */
+		if next.Proxy.Strategy != cur.Proxy.Strategy {
+			if err := route.SetPickerStrategy(next.Proxy.Strategy); err != nil {
+				log.Printf("[WARN] %s", err)
+			} else {
+				log.Printf("[INFO] Using routing strategy %q", next.Proxy.Strategy)
+				cur.Proxy.Strategy = next.Proxy.Strategy
+			}
+		}
+		if next.Proxy.Matcher != cur.Proxy.Matcher {
+			if err := route.SetMatcher(next.Proxy.Matcher); err != nil {
+				log.Printf("[WARN] %s", err)
+			} else {
+				log.Printf("[INFO] Using routing matching %q", next.Proxy.Matcher)
+				cur.Proxy.Matcher = next.Proxy.Matcher
+			}
+		}
 
-	// 生成并返回HTTP代理句柄
-	return proxy.NewHTTPProxy(tr, cfg.Proxy)
+		next.Proxy.Strategy, next.Proxy.Matcher = cur.Proxy.Strategy, cur.Proxy.Matcher
+		if next.Proxy.GZIPContentTypesValue == cur.Proxy.GZIPContentTypesValue {
+			next.Proxy.GZIPContentTypes = cur.Proxy.GZIPContentTypes
+		}
+		if !reflect.DeepEqual(next, &cur) {
+			log.Printf("[WARN] Config in consul changed. Restart fabio to apply all changes")
+		}
+	}
 }
 
-/**
- 启动管理UI服务,使用配置文件中的 UI配置信息
- "UI": {
-        "Addr": ":9998",
-        "Color": "light-green",
-        "Title": ""
-    },
- */
+/*
+*
+
+	启动管理UI服务,使用配置文件中的 UI配置信息
+	"UI": {
+	       "Addr": ":9998",
+	       "Color": "light-green",
+	       "Title": ""
+	   },
+*/
 func startAdmin(cfg *config.Config) {
 	log.Printf("[INFO] Admin server listening on %q", cfg.UI.Addr)
 	go func() {
@@ -247,11 +293,13 @@ func startAdmin(cfg *config.Config) {
 	}()
 }
 
-/**
- @todo Metrics 用来做什么？
- 系统监控
- 使用 配置文件中的 Metrics 信息来设置，Metrics的默认注册表和路由器的服务注册表
- */
+/*
+*
+
+	@todo Metrics 用来做什么？
+	系统监控
+	使用 配置文件中的 Metrics 信息来设置，Metrics的默认注册表和路由器的服务注册表
+*/
 func initMetrics(cfg *config.Config) {
 	// 如果度量服务器的Target 为空，那么表示Metrics功能被禁用
 	if cfg.Metrics.Target == "" {
@@ -266,11 +314,175 @@ func initMetrics(cfg *config.Config) {
 	if route.ServiceRegistry, err = metrics.NewRegistry(cfg.Metrics); err != nil {
 		exit.Fatal("[FATAL] ", err)
 	}
+	if cfg.Metrics.Runtime {
+		diag.ReportRuntime(metrics.DefaultRegistry, cfg.Metrics.Interval)
+	}
 }
 
-/**
-  配置运行时信息
- */
+// initTracing creates the tracer for the proxied requests
+// if tracing is enabled.
+func initTracing(cfg *config.Config) {
+	if !cfg.Tracing.Enabled {
+		log.Printf("[INFO] Tracing disabled")
+		return
+	}
+
+	var err error
+	if tracing.Default, err = tracing.New(cfg.Tracing, cfg.Proxy.LocalIP); err != nil {
+		exit.Fatal("[FATAL] ", err)
+	}
+	log.Printf("[INFO] Sending %s traces to %s", cfg.Tracing.Format, cfg.Tracing.Collector)
+}
+
+// initLogFiles opens the log files for the fabio log messages and the
+// access log if configured. The log files are reopened on SIGUSR1 to
+// support logrotate.
+func initLogFiles(cfg *config.Config) {
+	var files []*logger.File
+	if cfg.Log.ErrorFile != "" {
+		f, err := logger.OpenFile(cfg.Log.ErrorFile)
+		if err != nil {
+			exit.Fatal("[FATAL] ", err)
+		}
+		log.Printf("[INFO] Writing log to %s", cfg.Log.ErrorFile)
+		log.SetOutput(f)
+		files = append(files, f)
+	}
+
+	if f := initAccessLog(cfg); f != nil {
+		files = append(files, f)
+	}
+
+	if len(files) > 0 {
+		go reopenLogFiles(files)
+	}
+}
+
+// initAccessLog creates the access logger for the proxied requests
+// if an access log target is configured. It returns the log file
+// if the access log is written to a file.
+func initAccessLog(cfg *config.Config) *logger.File {
+	var f *logger.File
+	var err error
+	switch cfg.Log.AccessTarget {
+	case "":
+		log.Printf("[INFO] Access logging disabled")
+		return nil
+	case "file":
+		if f, err = logger.OpenFile(cfg.Log.AccessFile); err != nil {
+			exit.Fatal("[FATAL] ", err)
+		}
+		logger.Default, err = logger.New(f, cfg.Log.AccessFormat)
+		log.Printf("[INFO] Writing access log to %s", cfg.Log.AccessFile)
+	default:
+		logger.Default, err = logger.New(os.Stdout, cfg.Log.AccessFormat)
+		log.Printf("[INFO] Writing access log to %s", cfg.Log.AccessTarget)
+	}
+	if err != nil {
+		exit.Fatal("[FATAL] ", err)
+	}
+	return f
+}
+
+// reopenLogFiles reopens the log files whenever fabio receives
+// a SIGUSR1 signal.
+func reopenLogFiles(files []*logger.File) {
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGUSR1)
+	for range sigchan {
+		for _, f := range files {
+			if err := f.Reopen(); err != nil {
+				log.Printf("[ERROR] Cannot reopen log file %s. %s", f.Path(), err)
+				continue
+			}
+			log.Printf("[INFO] Reopened log file %s", f.Path())
+		}
+	}
+}
+
+// initAuth loads the credentials for the basic auth realms.
+func initAuth(cfg *config.Config) {
+	for name, src := range cfg.AuthSources {
+		r, err := auth.New(src)
+		if err != nil {
+			exit.Fatalf("[FATAL] Cannot load auth source %s. %s", name, err)
+		}
+		auth.Realms[name] = r
+		log.Printf("[INFO] Loaded auth realm %s from %s source", name, src.Type)
+	}
+}
+
+// initJWT loads the key sets of the JWT issuers.
+func initJWT(cfg *config.Config) {
+	for name, c := range cfg.JWTIssuers {
+		iss, err := jwt.New(c)
+		if err != nil {
+			exit.Fatalf("[FATAL] Cannot load JWT issuer %s. %s", name, err)
+		}
+		jwt.Issuers[name] = iss
+		log.Printf("[INFO] Loaded JWT issuer %s from %s", name, c.JWKS)
+	}
+}
+
+// initOIDC discovers the OpenID Connect providers.
+func initOIDC(cfg *config.Config) {
+	for name, c := range cfg.OIDC {
+		p, err := oidc.New(c)
+		if err != nil {
+			exit.Fatalf("[FATAL] Cannot load OIDC provider %s. %s", name, err)
+		}
+		oidc.Providers[name] = p
+		if c.CookieSecret == "" {
+			log.Printf("[WARN] No cookie secret for OIDC provider %s. Sessions are lost on restart", name)
+		}
+		log.Printf("[INFO] Loaded OIDC provider %s from %s", name, c.Issuer)
+	}
+}
+
+// initExtAuthz creates the external authorization services.
+func initExtAuthz(cfg *config.Config) {
+	for name, c := range cfg.ExtAuthz {
+		extauthz.Services[name] = extauthz.New(c)
+		log.Printf("[INFO] Using external authorization service %s at %s", name, c.URL)
+	}
+}
+
+// initErrorPages loads the custom pages for the error responses.
+func initErrorPages(cfg *config.Config) {
+	if err := proxy.LoadErrorPages(cfg.Proxy.ErrorPages); err != nil {
+		exit.Fatalf("[FATAL] Cannot load error pages. %s", err)
+	}
+}
+
+// initClientCerts creates the stores for the client certificates
+// which are presented to https upstream servers.
+func initClientCerts(cfg *config.Config) {
+	for name, src := range cfg.CertSources {
+		s, err := cert.NewSource(src)
+		if err != nil {
+			exit.Fatalf("[FATAL] Cannot load certificate source %s. %s", name, err)
+		}
+		cert.ClientStores[name] = cert.NewClientStore(name, s)
+	}
+}
+
+// initLeakDetector starts the periodic goroutine and
+// file descriptor leak detection.
+func initLeakDetector(cfg *config.Config) {
+	if cfg.Leaks.Interval <= 0 {
+		log.Printf("[INFO] Leak detection disabled")
+		return
+	}
+	diag.Default = diag.NewDetector(cfg.Leaks)
+	go diag.Default.Run()
+	log.Printf("[INFO] Checking for goroutine and fd leaks every %s", cfg.Leaks.Interval)
+}
+
+/*
+*
+
+	配置运行时信息
+*/
 func initRuntime(cfg *config.Config) {
 
 	// GC 百分比，当内存占用达到总内存的百分比后触发GC
@@ -291,65 +503,20 @@ func initRuntime(cfg *config.Config) {
 }
 
 // 初始化后端服务器的配置信息
-// 初始后端注册服务的默认 registry.Default 注册服务及配置信息
-func initBackend(cfg *config.Config) {
+// 创建服务器使用的 registry 后端，注册和监听在 srv.Start 中进行
+func initBackend(cfg *config.Config, srv *server.Server) {
 	var err error
 
 	// 根据配置中的　Registry -> Backend 的数据(file | static | consul)来判断后端服务的类型，并生成相应的配置信息
-	switch cfg.Registry.Backend {
-	case "file":
-		registry.Default, err = file.NewBackend(cfg.Registry.File.Path)
-	case "static":
-		registry.Default, err = static.NewBackend(cfg.Registry.Static.Routes)
-	case "consul":
-		registry.Default, err = consul.NewBackend(&cfg.Registry.Consul)
-	default:
-		exit.Fatal("[FATAL] Unknown registry backend ", cfg.Registry.Backend)
-	}
-
+	// Additional backends register themselves via registry.Register.
+	err = registry.Retry(cfg.Registry.Timeout, cfg.Registry.Retry, func() (err error) {
+		srv.Backend, err = registry.New(cfg.Registry.Backend, &cfg.Registry)
+		return err
+	})
 	if err != nil {
 		exit.Fatal("[FATAL] Error initializing backend. ", err)
 	}
-	if err := registry.Default.Register(); err != nil {
-		exit.Fatal("[FATAL] Error registering backend. ", err)
-	}
-}
-
-/**
-  启动监测服务器的后端服务
- */
-func watchBackend() {
-	var (
-		last   string
-		svccfg string
-		mancfg string
-	)
-
-	svc := registry.Default.WatchServices()
-	man := registry.Default.WatchManual()
-
-	for {
-		select {
-		case svccfg = <-svc:
-		case mancfg = <-man:
-		}
-
-		// manual config overrides service config
-		// order matters
-		next := svccfg + "\n" + mancfg
-		if next == last {
-			continue
-		}
-
-		t, err := route.ParseString(next)
-		if err != nil {
-			log.Printf("[WARN] %s", err)
-			continue
-		}
-		route.SetTable(t)
-
-		last = next
-	}
+	registry.Default = srv.Backend
 }
 
 func toJSON(v interface{}) string {
@@ -101,6 +101,18 @@ func (m *cgmRegistry) GetTimer(name string) Timer {
 	return &cgmTimer{m.metrics, metricName}
 }
 
+// GetGauge returns a gauge for the given metric name.
+func (m *cgmRegistry) GetGauge(name string) Gauge {
+	metricName := fmt.Sprintf("%s`%s", m.prefix, name)
+	return &cgmGauge{m.metrics, metricName}
+}
+
+// GetFloatGauge returns a float gauge for the given metric name.
+func (m *cgmRegistry) GetFloatGauge(name string) FloatGauge {
+	metricName := fmt.Sprintf("%s`%s", m.prefix, name)
+	return &cgmFloatGauge{m.metrics, metricName}
+}
+
 type cgmCounter struct {
 	metrics *cgm.CirconusMetrics
 	name    string
@@ -111,6 +123,26 @@ func (c *cgmCounter) Inc(n int64) {
 	c.metrics.IncrementByValue(c.name, uint64(n))
 }
 
+type cgmGauge struct {
+	metrics *cgm.CirconusMetrics
+	name    string
+}
+
+// Update sets the gauge to n.
+func (g *cgmGauge) Update(n int64) {
+	g.metrics.SetGauge(g.name, n)
+}
+
+type cgmFloatGauge struct {
+	metrics *cgm.CirconusMetrics
+	name    string
+}
+
+// Update sets the gauge to v.
+func (g *cgmFloatGauge) Update(v float64) {
+	g.metrics.SetGauge(g.name, v)
+}
+
 type cgmTimer struct {
 	metrics *cgm.CirconusMetrics
 	name    string
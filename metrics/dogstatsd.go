@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	gm "github.com/rcrowley/go-metrics"
+)
+
+// tagSep separates the name of a tagged metric from its tags.
+// It is the same separator which is used by DogStatsD.
+const tagSep = "|#"
+
+// tagged is true if the metrics target supports tags
+// and the route metrics are reported with tags instead
+// of names generated from the templates.
+var tagged bool
+
+// taggedName returns the name of a metric with the given tags
+// which are provided as key/value pairs.
+func taggedName(name string, kv ...string) string {
+	var tags []string
+	for i := 0; i+1 < len(kv); i += 2 {
+		tags = append(tags, cleanTag(kv[i])+":"+cleanTag(kv[i+1]))
+	}
+	return name + tagSep + strings.Join(tags, ",")
+}
+
+// WithTag adds a tag to the name of a tagged metric.
+func WithTag(name, key, value string) string {
+	return name + "," + cleanTag(key) + ":" + cleanTag(value)
+}
+
+// WithSuffix appends the suffix to the name of a metric. The
+// suffix of a tagged metric is added to the name before the tags.
+func WithSuffix(name, suffix string) string {
+	if i := strings.Index(name, tagSep); i >= 0 {
+		return name[:i] + suffix + name[i:]
+	}
+	return name + suffix
+}
+
+// StatusName returns the name of the counter for the responses of
+// the service on the route. The status code is added as 'code' tag
+// with WithTag. StatusName returns an empty string if the metrics
+// target does not support tags.
+func StatusName(service, host, path string) string {
+	if !tagged {
+		return ""
+	}
+	return taggedName("status", "service", service, "host", host, "route", path)
+}
+
+// cleanTag replaces the characters which are not allowed
+// in DogStatsD tags with underscores.
+func cleanTag(s string) string {
+	if s == "" {
+		return "_"
+	}
+	return strings.NewReplacer(",", "_", "|", "_", "#", "_", " ", "_").Replace(s)
+}
+
+// gmDogStatsDRegistry returns a go-metrics registry that reports
+// tagged metrics to a DogStatsD server.
+func gmDogStatsDRegistry(prefix, addr string, interval time.Duration) (Registry, error) {
+	if addr == "" {
+		return nil, errors.New(" dogstatsd addr missing")
+	}
+
+	a, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf(" cannot connect to DogStatsD: %s", err)
+	}
+
+	r := gm.NewRegistry()
+	go dogstatsd(r, interval, prefix, a)
+	return &gmRegistry{r}, nil
+}
+
+// dogstatsd reports the metrics in r to the DogStatsD server
+// at addr every interval.
+func dogstatsd(r gm.Registry, interval time.Duration, prefix string, addr *net.UDPAddr) {
+	last := map[string]int64{}
+	for range time.Tick(interval) {
+		conn, err := net.DialUDP("udp", nil, addr)
+		if err != nil {
+			log.Print("[WARN] metrics: Cannot connect to DogStatsD. ", err)
+			continue
+		}
+		w := bufio.NewWriter(conn)
+		writeDogStatsD(w, r, prefix, last)
+		w.Flush()
+		conn.Close()
+	}
+}
+
+// writeDogStatsD writes the metrics in r in the DogStatsD format.
+// Counters are reported as the difference to the values in last
+// which is updated. Durations are reported in milliseconds.
+func writeDogStatsD(w io.Writer, r gm.Registry, prefix string, last map[string]int64) {
+	if prefix != "" {
+		prefix += "."
+	}
+
+	var names []string
+	metrics := map[string]interface{}{}
+	r.Each(func(name string, m interface{}) {
+		names = append(names, name)
+		metrics[name] = m
+	})
+	sort.Strings(names)
+
+	delta := func(name string, n int64) int64 {
+		d := n - last[name]
+		if d < 0 {
+			d = n
+		}
+		last[name] = n
+		return d
+	}
+
+	ms := float64(time.Millisecond)
+	for _, name := range names {
+		base, tags := name, ""
+		if i := strings.Index(name, tagSep); i >= 0 {
+			base, tags = name[:i], name[i:]
+		}
+
+		switch m := metrics[name].(type) {
+		case gm.Counter:
+			fmt.Fprintf(w, "%s%s:%d|c%s\n", prefix, base, delta(name, m.Count()), tags)
+		case gm.Gauge:
+			fmt.Fprintf(w, "%s%s:%d|g%s\n", prefix, base, m.Value(), tags)
+		case gm.GaugeFloat64:
+			fmt.Fprintf(w, "%s%s:%g|g%s\n", prefix, base, m.Value(), tags)
+		case gm.Timer:
+			t := m.Snapshot()
+			ps := t.Percentiles([]float64{0.5, 0.95, 0.99})
+			fmt.Fprintf(w, "%s%s.count:%d|c%s\n", prefix, base, delta(name, t.Count()), tags)
+			fmt.Fprintf(w, "%s%s.mean:%.2f|g%s\n", prefix, base, t.Mean()/ms, tags)
+			fmt.Fprintf(w, "%s%s.max:%.2f|g%s\n", prefix, base, float64(t.Max())/ms, tags)
+			fmt.Fprintf(w, "%s%s.p50:%.2f|g%s\n", prefix, base, ps[0]/ms, tags)
+			fmt.Fprintf(w, "%s%s.p95:%.2f|g%s\n", prefix, base, ps[1]/ms, tags)
+			fmt.Fprintf(w, "%s%s.p99:%.2f|g%s\n", prefix, base, ps[2]/ms, tags)
+			fmt.Fprintf(w, "%s%s.rate1:%.2f|g%s\n", prefix, base, t.Rate1(), tags)
+		}
+	}
+
+	// forget the unregistered metrics
+	for name := range last {
+		if metrics[name] == nil {
+			delete(last, name)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+
+	gm "github.com/rcrowley/go-metrics"
+)
+
+func TestTaggedNames(t *testing.T) {
+	defer func() { tagged = false }()
+	tagged = true
+
+	u, _ := url.Parse("http://1.2.3.4:5000/")
+	name, err := TargetName("svc", "example.com", "/foo", u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := name, "target|#service:svc,host:example.com,route:/foo,target:1.2.3.4:5000"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+
+	status := WithTag(StatusName("svc", "", "/foo"), "code", "200")
+	if got, want := status, "status|#service:svc,host:_,route:/foo,code:200"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func TestWriteDogStatsD(t *testing.T) {
+	r := gm.NewRegistry()
+	gm.GetOrRegisterCounter("notfound", r).Inc(3)
+	gm.GetOrRegisterCounter("status|#service:svc,code:200", r).Inc(5)
+	gm.GetOrRegisterGauge("cert.a_com.expiry_days", r).Update(7)
+
+	last := map[string]int64{}
+	var b bytes.Buffer
+	writeDogStatsD(&b, r, "fabio", last)
+	want := "fabio.cert.a_com.expiry_days:7|g\n" +
+		"fabio.notfound:3|c\n" +
+		"fabio.status:5|c|#service:svc,code:200\n"
+	if got := b.String(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// counters are reported as the difference to the last report
+	gm.GetOrRegisterCounter("notfound", r).Inc(2)
+	r.Unregister("status|#service:svc,code:200")
+	b.Reset()
+	writeDogStatsD(&b, r, "", last)
+	want = "cert.a_com.expiry_days:7|g\n" +
+		"notfound:2|c\n"
+	if got := b.String(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+	if _, ok := last["status|#service:svc,code:200"]; ok {
+		t.Fatal("unregistered counter not removed")
+	}
+}
@@ -83,3 +83,11 @@ func (p *gmRegistry) GetCounter(name string) Counter {
 func (p *gmRegistry) GetTimer(name string) Timer {
 	return gm.GetOrRegisterTimer(name, p.r)
 }
+
+func (p *gmRegistry) GetGauge(name string) Gauge {
+	return gm.GetOrRegisterGauge(name, p.r)
+}
+
+func (p *gmRegistry) GetFloatGauge(name string) FloatGauge {
+	return gm.GetOrRegisterGaugeFloat64(name, p.r)
+}
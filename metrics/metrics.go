@@ -31,6 +31,10 @@ const DefaultPrefix = "{{clean .Hostname}}.{{clean .Exec}}"
 // names stores the template for the route metric names.
 var names *template.Template
 
+// routeNames stores the template for the names of the metrics which
+// are aggregated per route and service. It is nil if they are disabled.
+var routeNames *template.Template
+
 // prefix stores the final prefix string to use it with metric collectors where applicable, i.e. Graphite/StatsD
 var prefix string
 
@@ -53,6 +57,12 @@ func NewRegistry(cfg config.Metrics) (r Registry, err error) {
 		return nil, fmt.Errorf("metrics: invalid names template. %s", err)
 	}
 
+	if routeNames, err = parseRouteNames(cfg.RouteNames); err != nil {
+		return nil, fmt.Errorf("metrics: invalid route names template. %s", err)
+	}
+
+	tagged = cfg.Target == "dogstatsd"
+
 	switch cfg.Target {
 	case "stdout":
 		log.Printf("[INFO] Sending metrics to stdout")
@@ -66,6 +76,10 @@ func NewRegistry(cfg config.Metrics) (r Registry, err error) {
 		log.Printf("[INFO] Sending metrics to StatsD on %s as %q", cfg.StatsDAddr, prefix)
 		return gmStatsDRegistry(prefix, cfg.StatsDAddr, cfg.Interval)
 
+	case "dogstatsd":
+		log.Printf("[INFO] Sending tagged metrics to DogStatsD on %s as %q", cfg.DogStatsDAddr, prefix)
+		return gmDogStatsDRegistry(prefix, cfg.DogStatsDAddr, cfg.Interval)
+
 	case "circonus":
 		return circonusRegistry(prefix,
 			cfg.CirconusAPIKey,
@@ -128,7 +142,12 @@ func parseNames(tmpl string) (*template.Template, error) {
 }
 
 // TargetName returns the metrics name from the given parameters.
+// If the metrics target supports tags the name is 'target' with
+// the service, host, route and target tags.
 func TargetName(service, host, path string, targetURL *url.URL) (string, error) {
+	if tagged {
+		return taggedName("target", "service", service, "host", host, "route", path, "target", targetURL.Host), nil
+	}
 	if names == nil {
 		return "", nil
 	}
@@ -147,6 +166,61 @@ func TargetName(service, host, path string, targetURL *url.URL) (string, error)
 	return name.String(), nil
 }
 
+// parseRouteNames parses the template for the per route metric
+// names. It returns nil if the template is empty.
+func parseRouteNames(tmpl string) (*template.Template, error) {
+	if tmpl == "" {
+		return nil, nil
+	}
+	funcMap := template.FuncMap{
+		"clean": clean,
+	}
+	t, err := template.New("routenames").Funcs(funcMap).Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := routeName(t, "testservice", "test.example.com", "/test"); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// RouteName returns the name of the metric for all targets of the
+// service on the route with the given host and path. It returns an
+// empty string if per route metrics are disabled. If the metrics
+// target supports tags the name is 'route' with the service, host
+// and route tags.
+func RouteName(service, host, path string) (string, error) {
+	if tagged && routeNames != nil {
+		return taggedName("route", "service", service, "host", host, "route", path), nil
+	}
+	return routeName(routeNames, service, host, path)
+}
+
+func routeName(t *template.Template, service, host, path string) (string, error) {
+	if t == nil {
+		return "", nil
+	}
+
+	var name bytes.Buffer
+
+	data := struct {
+		Host, Path, TargetService string
+	}{host, path, service}
+
+	if err := t.Execute(&name, data); err != nil {
+		return "", err
+	}
+
+	return name.String(), nil
+}
+
+// Clean returns a version of s which is safe to use
+// as part of a metric name.
+func Clean(s string) string {
+	return clean(s)
+}
+
 // clean creates safe names for graphite reporting by replacing
 // some characters with underscores.
 // TODO(fs): This may need updating for other metrics backends.
@@ -4,6 +4,7 @@ import (
 	"net/url"
 	"os"
 	"testing"
+	"text/template"
 )
 
 func TestParsePrefix(t *testing.T) {
@@ -56,3 +57,40 @@ func TestTargetName(t *testing.T) {
 		}
 	}
 }
+
+func TestRouteName(t *testing.T) {
+	defer func(t *template.Template) { routeNames = t }(routeNames)
+
+	routeNames = nil
+	if got, err := RouteName("s", "h", "p"); err != nil || got != "" {
+		t.Fatalf("got %q, %v want empty name for disabled route metrics", got, err)
+	}
+
+	var err error
+	routeNames, err = parseRouteNames("{{clean .Host}}.{{clean .Path}}.{{clean .TargetService}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		service, host, path string
+		name                string
+	}{
+		{"s", "www.example.com", "/foo", "www_example_com./foo.s"},
+		{"s", "", "/", "_./.s"},
+	}
+
+	for i, tt := range tests {
+		got, err := RouteName(tt.service, tt.host, tt.path)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if want := tt.name; got != want {
+			t.Errorf("%d: got %q want %q", i, got, want)
+		}
+	}
+
+	if _, err := parseRouteNames("{{.Foo}}"); err == nil {
+		t.Fatal("got nil want error for invalid variable")
+	}
+}
@@ -15,6 +15,10 @@ func (p NoopRegistry) GetCounter(name string) Counter { return noopCounter }
 
 func (p NoopRegistry) GetTimer(name string) Timer { return noopTimer }
 
+func (p NoopRegistry) GetGauge(name string) Gauge { return noopGauge }
+
+func (p NoopRegistry) GetFloatGauge(name string) FloatGauge { return noopFloatGauge }
+
 var noopCounter = NoopCounter{}
 
 // NoopCounter is a stub implementation of the Counter interface.
@@ -32,3 +36,17 @@ func (t NoopTimer) UpdateSince(start time.Time) {}
 func (t NoopTimer) Rate1() float64 { return 0 }
 
 func (t NoopTimer) Percentile(nth float64) float64 { return 0 }
+
+var noopGauge = NoopGauge{}
+
+// NoopGauge is a stub implementation of the Gauge interface.
+type NoopGauge struct{}
+
+func (g NoopGauge) Update(n int64) {}
+
+var noopFloatGauge = NoopFloatGauge{}
+
+// NoopFloatGauge is a stub implementation of the FloatGauge interface.
+type NoopFloatGauge struct{}
+
+func (g NoopFloatGauge) Update(v float64) {}
@@ -29,6 +29,17 @@ type Registry interface {
 	// If the metric does not exist yet it should be created
 	// otherwise the existing metric should be returned.
 	GetTimer(name string) Timer
+
+	// GetGauge returns a gauge metric for the given name.
+	// If the metric does not exist yet it should be created
+	// otherwise the existing metric should be returned.
+	GetGauge(name string) Gauge
+
+	// GetFloatGauge returns a gauge metric with a float value
+	// for the given name. If the metric does not exist yet it
+	// should be created otherwise the existing metric should
+	// be returned.
+	GetFloatGauge(name string) FloatGauge
 }
 
 // Counter defines a metric for counting events.
@@ -37,6 +48,18 @@ type Counter interface {
 	Inc(n int64)
 }
 
+// Gauge defines a metric for an instantaneous value.
+type Gauge interface {
+	// Update sets the gauge value to 'n'.
+	Update(n int64)
+}
+
+// FloatGauge defines a metric for an instantaneous float value.
+type FloatGauge interface {
+	// Update sets the gauge value to 'v'.
+	Update(v float64)
+}
+
 // Timer defines a metric for counting and timing durations for events.
 type Timer interface {
 	// Percentile returns the nth percentile of the duration.
@@ -0,0 +1,362 @@
+// Package oidc implements the OpenID Connect login for routes
+// with the 'oidc' option.
+//
+// Unauthenticated browser requests are redirected to the provider
+// and the identity from the ID token is kept in a signed session
+// cookie. The configured claims of the ID token are forwarded to
+// the upstream server as request headers.
+package oidc
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/jwt"
+)
+
+// Providers contains the configured providers by name.
+var Providers = map[string]*Provider{}
+
+// stateTTL is the time in which the login must be completed.
+const stateTTL = 10 * time.Minute
+
+// now returns the current time and can be replaced in tests.
+var now = time.Now
+
+// Provider authenticates requests with an OpenID Connect provider.
+type Provider struct {
+	cfg      config.OIDCProvider
+	authURL  *url.URL
+	tokenURL string
+	verifier *jwt.Issuer
+	secret   []byte
+	client   *http.Client
+}
+
+// discovery contains the fields of the provider metadata
+// which are used.
+type discovery struct {
+	Issuer   string `json:"issuer"`
+	AuthURL  string `json:"authorization_endpoint"`
+	TokenURL string `json:"token_endpoint"`
+	JWKSURL  string `json:"jwks_uri"`
+}
+
+// New creates a provider for the config and discovers its endpoints
+// and keys from the issuer URL.
+func New(cfg config.OIDCProvider) (*Provider, error) {
+	p := &Provider{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+
+	d, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	if p.authURL, err = url.Parse(d.AuthURL); err != nil {
+		return nil, err
+	}
+	p.tokenURL = d.TokenURL
+
+	p.verifier, err = jwt.New(config.JWTIssuer{
+		Name:     cfg.Name,
+		JWKS:     d.JWKSURL,
+		Issuer:   d.Issuer,
+		Audience: cfg.ClientID,
+		Claims:   cfg.Claims,
+		Headers:  cfg.Headers,
+		Refresh:  time.Hour,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p.secret = []byte(cfg.CookieSecret)
+	if len(p.secret) == 0 {
+		p.secret = make([]byte, 32)
+		if _, err := rand.Read(p.secret); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (p *Provider) discover() (*discovery, error) {
+	u := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	resp, err := p.client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("oidc: cannot load provider metadata. " + resp.Status)
+	}
+	var d discovery
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return nil, err
+	}
+	if d.Issuer != p.cfg.Issuer {
+		return nil, errors.New("oidc: issuer " + d.Issuer + " does not match " + p.cfg.Issuer)
+	}
+	if d.AuthURL == "" || d.TokenURL == "" || d.JWKSURL == "" {
+		return nil, errors.New("oidc: incomplete provider metadata")
+	}
+	return &d, nil
+}
+
+// Authenticate checks the session of the request and writes the
+// response for the login if there is none. It returns the status
+// code of the response or zero if the request is authenticated.
+// Requests for the callback path complete the login. The configured
+// headers are removed from all requests and set from the session of
+// authenticated requests. The cookies of the provider are not sent
+// to the upstream server.
+func (p *Provider) Authenticate(w http.ResponseWriter, r *http.Request) int {
+	for _, h := range p.verifier.HeaderNames() {
+		r.Header.Del(h)
+	}
+
+	if r.URL.Path == p.cfg.CallbackPath {
+		return p.callback(w, r)
+	}
+
+	if hdr, ok := p.session(r); ok {
+		removeCookies(r, p.cfg.CookieName, p.stateCookie())
+		for h, v := range hdr {
+			r.Header.Set(h, v)
+		}
+		return 0
+	}
+
+	// only browsers can follow the login
+	if (r.Method != "GET" && r.Method != "HEAD") || !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return http.StatusUnauthorized
+	}
+	return p.login(w, r)
+}
+
+// login redirects the client to the provider. The state, nonce and
+// the requested URI are kept in a cookie for the callback.
+func (p *Provider) login(w http.ResponseWriter, r *http.Request) int {
+	state, nonce := randomString(), randomString()
+	p.setCookie(w, r, p.stateCookie(), state+"|"+nonce+"|"+r.URL.RequestURI(), stateTTL)
+
+	u := *p.authURL
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.redirectURI(r))
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	u.RawQuery = q.Encode()
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+	return http.StatusFound
+}
+
+// callback exchanges the authorization code for the ID token, starts
+// the session and redirects the client to the originally requested URI.
+func (p *Provider) callback(w http.ResponseWriter, r *http.Request) int {
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		log.Printf("[WARN] oidc: Login with provider %s failed. %s %s", p.cfg.Name, e, q.Get("error_description"))
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return http.StatusUnauthorized
+	}
+
+	c, err := r.Cookie(p.stateCookie())
+	if err != nil {
+		http.Error(w, "missing login state", http.StatusBadRequest)
+		return http.StatusBadRequest
+	}
+	v, ok := p.verify(p.stateCookie(), c.Value)
+	parts := strings.SplitN(v, "|", 3)
+	if !ok || len(parts) != 3 || parts[0] != q.Get("state") {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return http.StatusBadRequest
+	}
+	nonce, uri := parts[1], parts[2]
+
+	token, err := p.exchange(r, q.Get("code"))
+	if err != nil {
+		log.Printf("[WARN] oidc: Cannot get ID token from provider %s. %s", p.cfg.Name, err)
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return http.StatusBadGateway
+	}
+
+	claims, err := p.verifier.Verify(token)
+	switch {
+	case err == jwt.ErrInsufficientClaims:
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return http.StatusForbidden
+	case err != nil || claims["nonce"] != nonce:
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return http.StatusUnauthorized
+	}
+
+	sess, err := json.Marshal(session{
+		Expires: now().Add(p.cfg.Session).Unix(),
+		Headers: p.verifier.Headers(claims),
+	})
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+	p.setCookie(w, r, p.cfg.CookieName, string(sess), p.cfg.Session)
+	p.setCookie(w, r, p.stateCookie(), "", -1)
+
+	if !strings.HasPrefix(uri, "/") || strings.HasPrefix(uri, "//") {
+		uri = "/"
+	}
+	http.Redirect(w, r, uri, http.StatusFound)
+	return http.StatusFound
+}
+
+// exchange returns the ID token for the authorization code.
+func (p *Provider) exchange(r *http.Request, code string) (string, error) {
+	if code == "" {
+		return "", errors.New("missing code")
+	}
+	resp, err := p.client.PostForm(p.tokenURL, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURI(r)},
+		"client_id":     {p.cfg.ClientID},
+		"client_secret": {p.cfg.ClientSecret},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("token endpoint returned " + resp.Status)
+	}
+	var tr struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", err
+	}
+	if tr.IDToken == "" {
+		return "", errors.New("missing id_token")
+	}
+	return tr.IDToken, nil
+}
+
+// session contains the identity of an authenticated client.
+type session struct {
+	Expires int64             `json:"exp"`
+	Headers map[string]string `json:"hdr"`
+}
+
+// session returns the request headers from the session cookie
+// if the cookie is valid and has not expired.
+func (p *Provider) session(r *http.Request) (map[string]string, bool) {
+	c, err := r.Cookie(p.cfg.CookieName)
+	if err != nil {
+		return nil, false
+	}
+	v, ok := p.verify(p.cfg.CookieName, c.Value)
+	if !ok {
+		return nil, false
+	}
+	var s session
+	if err := json.Unmarshal([]byte(v), &s); err != nil || now().Unix() >= s.Expires {
+		return nil, false
+	}
+	return s.Headers, true
+}
+
+func (p *Provider) stateCookie() string {
+	return p.cfg.CookieName + "_state"
+}
+
+func (p *Provider) redirectURI(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + p.cfg.CallbackPath
+}
+
+// setCookie sets a cookie with the signed value. A negative
+// lifetime deletes the cookie.
+func (p *Provider) setCookie(w http.ResponseWriter, r *http.Request, name, value string, d time.Duration) {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    p.sign(name, value),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if d < 0 {
+		c.Value, c.MaxAge = "", -1
+	} else {
+		c.MaxAge = int(d / time.Second)
+	}
+	http.SetCookie(w, c)
+}
+
+// sign returns the value with the signature for the cookie name.
+func (p *Provider) sign(name, value string) string {
+	v := base64.RawURLEncoding.EncodeToString([]byte(value))
+	return v + "." + base64.RawURLEncoding.EncodeToString(p.mac(name, v))
+}
+
+// verify returns the value of a signed cookie and whether
+// the signature is valid.
+func (p *Provider) verify(name, s string) (string, bool) {
+	i := strings.LastIndexByte(s, '.')
+	if i < 0 {
+		return "", false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(s[i+1:])
+	if err != nil || !hmac.Equal(sig, p.mac(name, s[:i])) {
+		return "", false
+	}
+	v, err := base64.RawURLEncoding.DecodeString(s[:i])
+	if err != nil {
+		return "", false
+	}
+	return string(v), true
+}
+
+func (p *Provider) mac(name, value string) []byte {
+	h := hmac.New(sha256.New, p.secret)
+	h.Write([]byte(name + "=" + value))
+	return h.Sum(nil)
+}
+
+// removeCookies removes the named cookies from the request.
+func removeCookies(r *http.Request, names ...string) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+next:
+	for _, c := range cookies {
+		for _, name := range names {
+			if c.Name == name {
+				continue next
+			}
+		}
+		r.AddCookie(c)
+	}
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("oidc: cannot read random bytes. " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
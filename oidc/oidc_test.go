@@ -0,0 +1,195 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/config"
+)
+
+// idp is a minimal OpenID Connect provider which issues ID tokens
+// with the given claims for the code 'abc'.
+type idp struct {
+	*httptest.Server
+	key    *ecdsa.PrivateKey
+	claims map[string]interface{}
+}
+
+func newIDP(t *testing.T) *idp {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	p := &idp{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"issuer":%q,"authorization_endpoint":%q,"token_endpoint":%q,"jwks_uri":%q}`,
+			p.URL, p.URL+"/authorize", p.URL+"/token", p.URL+"/keys")
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"keys":[{"kty":"EC","kid":"k1","crv":"P-256","x":%q,"y":%q}]}`, b64(key.X.Bytes()), b64(key.Y.Bytes()))
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "abc" || r.FormValue("client_secret") != "secret" {
+			http.Error(w, "invalid_grant", http.StatusBadRequest)
+			return
+		}
+		body, _ := json.Marshal(p.claims)
+		signed := b64([]byte(`{"alg":"ES256","kid":"k1"}`)) + "." + b64(body)
+		digest := sha256.Sum256([]byte(signed))
+		rr, ss, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := make([]byte, 64)
+		rr.FillBytes(sig[:32])
+		ss.FillBytes(sig[32:])
+		fmt.Fprintf(w, `{"access_token":"x","id_token":%q}`, signed+"."+b64(sig))
+	})
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+func TestProviderLogin(t *testing.T) {
+	server := newIDP(t)
+	defer server.Close()
+
+	p, err := New(config.OIDCProvider{
+		Name:         "corp",
+		Issuer:       server.URL,
+		ClientID:     "fabio",
+		ClientSecret: "secret",
+		CallbackPath: "/oauth2/callback",
+		Scopes:       []string{"openid", "email"},
+		CookieName:   "sess",
+		Session:      time.Hour,
+		Claims:       map[string]string{"groups": "dev"},
+		Headers:      map[string]string{"email": "X-Email"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, uri string, cookies []*http.Cookie, hdr http.Header) (*http.Request, *httptest.ResponseRecorder, int) {
+		r := httptest.NewRequest(method, "http://app.com"+uri, nil)
+		for k, v := range hdr {
+			r.Header[k] = v
+		}
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		code := p.Authenticate(w, r)
+		return r, w, code
+	}
+	browser := http.Header{"Accept": {"text/html"}}
+
+	// api requests are not redirected
+	if _, _, code := do("GET", "/", nil, nil); code != 401 {
+		t.Fatalf("got code %d want 401", code)
+	}
+
+	// browsers are redirected to the provider
+	_, w, code := do("GET", "/app?x=1", nil, browser)
+	if code != 302 {
+		t.Fatalf("got code %d want 302", code)
+	}
+	loc, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := loc.Query()
+	if got, want := loc.Path, "/authorize"; got != want {
+		t.Fatalf("got path %q want %q", got, want)
+	}
+	if got, want := q.Get("redirect_uri"), "http://app.com/oauth2/callback"; got != want {
+		t.Fatalf("got redirect_uri %q want %q", got, want)
+	}
+	if got, want := q.Get("scope"), "openid email"; got != want {
+		t.Fatalf("got scope %q want %q", got, want)
+	}
+	state := w.Result().Cookies()
+
+	// the callback checks the state and the nonce
+	server.claims = map[string]interface{}{"iss": server.URL, "aud": "fabio", "exp": time.Now().Add(time.Hour).Unix(), "email": "alice@example.com", "groups": []string{"dev"}, "nonce": q.Get("nonce")}
+	if _, _, code := do("GET", "/oauth2/callback?code=abc&state=wrong", state, nil); code != 400 {
+		t.Fatalf("got code %d want 400", code)
+	}
+	if _, _, code := do("GET", "/oauth2/callback?code=abc&state="+q.Get("state"), nil, nil); code != 400 {
+		t.Fatalf("got code %d want 400", code)
+	}
+	if _, _, code := do("GET", "/oauth2/callback?code=bad&state="+q.Get("state"), state, nil); code != 502 {
+		t.Fatalf("got code %d want 502", code)
+	}
+	server.claims["groups"] = []string{"ops"}
+	if _, _, code := do("GET", "/oauth2/callback?code=abc&state="+q.Get("state"), state, nil); code != 403 {
+		t.Fatalf("got code %d want 403", code)
+	}
+	server.claims["groups"] = []string{"dev"}
+	server.claims["nonce"] = "other"
+	if _, _, code := do("GET", "/oauth2/callback?code=abc&state="+q.Get("state"), state, nil); code != 401 {
+		t.Fatalf("got code %d want 401", code)
+	}
+	server.claims["nonce"] = q.Get("nonce")
+	_, w, code = do("GET", "/oauth2/callback?code=abc&state="+q.Get("state"), state, nil)
+	if code != 302 {
+		t.Fatalf("got code %d want 302", code)
+	}
+	if got, want := w.Header().Get("Location"), "/app?x=1"; got != want {
+		t.Fatalf("got location %q want %q", got, want)
+	}
+	var sess *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "sess" {
+			sess = c
+		}
+	}
+	if sess == nil || sess.MaxAge != 3600 || !sess.HttpOnly {
+		t.Fatalf("got session cookie %v", sess)
+	}
+
+	// the session authenticates the request and sets the headers
+	r, _, code := do("GET", "/app", []*http.Cookie{sess, {Name: "other", Value: "1"}}, http.Header{"X-Email": {"mallory@example.com"}})
+	if code != 0 {
+		t.Fatalf("got code %d want 0", code)
+	}
+	if got, want := r.Header.Get("X-Email"), "alice@example.com"; got != want {
+		t.Fatalf("got header %q want %q", got, want)
+	}
+	if got, want := r.Header.Get("Cookie"), "other=1"; got != want {
+		t.Fatalf("got cookies %q want %q", got, want)
+	}
+
+	// tampered and expired sessions are rejected
+	forged := &http.Cookie{Name: "sess", Value: sess.Value[:len(sess.Value)-4] + "AAAA"}
+	if _, _, code := do("GET", "/app", []*http.Cookie{forged}, nil); code != 401 {
+		t.Fatalf("got code %d want 401", code)
+	}
+	now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	defer func() { now = time.Now }()
+	if _, _, code := do("GET", "/app", []*http.Cookie{sess}, nil); code != 401 {
+		t.Fatalf("got code %d want 401", code)
+	}
+}
+
+func TestProviderIssuerMismatch(t *testing.T) {
+	server := newIDP(t)
+	defer server.Close()
+
+	_, err := New(config.OIDCProvider{Name: "corp", Issuer: server.URL + "/"})
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("got %v want issuer mismatch", err)
+	}
+}
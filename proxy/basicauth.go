@@ -0,0 +1,30 @@
+package proxy
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/eBay/fabio/auth"
+)
+
+// basicAuth checks the credentials of the request against the realm
+// with the given name and writes the error response if the request
+// is not authorized. It returns the status code of the error response
+// or zero if the request is authorized. The credentials of authorized
+// requests are removed so that they are not sent to the upstream server.
+// Requests for unknown realms are rejected.
+func basicAuth(w http.ResponseWriter, r *http.Request, name string) int {
+	realm := auth.Realms[name]
+	if realm == nil {
+		log.Printf("[WARN] Unknown auth realm %s for %s%s", name, r.Host, r.URL)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+	if !realm.Authenticate(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="`+realm.Name+`"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return http.StatusUnauthorized
+	}
+	r.Header.Del("Authorization")
+	return 0
+}
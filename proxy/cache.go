@@ -0,0 +1,498 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eBay/fabio/metrics"
+	"github.com/eBay/fabio/route"
+)
+
+// maxCachedBody is the maximum size of a response body which is
+// cached. Larger responses are passed through but not cached.
+const maxCachedBody = 1 << 20
+
+// cacheRefreshTimeout limits the background requests which
+// refresh stale responses.
+const cacheRefreshTimeout = time.Minute
+
+// CachedResponse is a response in the cache which is fresh
+// until Expires and is revalidated with the upstream server
+// afterwards if it has an ETag or a Last-Modified header.
+//
+// After Expires the response is served for StaleWhileRevalidate
+// while it is refreshed in the background and for StaleIfError
+// when the upstream server fails.
+type CachedResponse struct {
+	Code    int
+	Header  http.Header
+	Body    []byte
+	Stored  time.Time
+	Expires time.Time
+
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+}
+
+// Cache stores the responses for routes with the 'cache' option.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	Get(key string) *CachedResponse
+	Set(key string, resp *CachedResponse)
+}
+
+// DefaultCache replaces the in-memory cache of the proxy
+// with a different implementation if it is not nil.
+var DefaultCache Cache
+
+// memoryCache stores a bounded number of responses in memory.
+// The least recently used entries are evicted first.
+type memoryCache struct {
+	size int
+
+	mu sync.Mutex
+	m  map[string]*list.Element
+	l  *list.List
+}
+
+type memoryCacheEntry struct {
+	key  string
+	resp *CachedResponse
+}
+
+// NewMemoryCache returns a Cache which stores up to size responses in memory.
+func NewMemoryCache(size int) Cache {
+	return &memoryCache{size: size, m: map[string]*list.Element{}, l: list.New()}
+}
+
+func (c *memoryCache) Get(key string) *CachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.m[key]
+	if e == nil {
+		return nil
+	}
+	c.l.MoveToFront(e)
+	return e.Value.(*memoryCacheEntry).resp
+}
+
+func (c *memoryCache) Set(key string, resp *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e := c.m[key]; e != nil {
+		e.Value.(*memoryCacheEntry).resp = resp
+		c.l.MoveToFront(e)
+		return
+	}
+	c.m[key] = c.l.PushFront(&memoryCacheEntry{key: key, resp: resp})
+	for c.l.Len() > c.size {
+		e := c.l.Back()
+		c.l.Remove(e)
+		delete(c.m, e.Value.(*memoryCacheEntry).key)
+	}
+}
+
+// cachePolicy contains the cache options of a route.
+type cachePolicy struct {
+	// ttl is the lifetime of responses without
+	// a max-age or s-maxage directive.
+	ttl time.Duration
+
+	// staleWhileRevalidate and staleIfError are used for responses
+	// without the Cache-Control directives of the same name.
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+}
+
+// cacheRefreshes contains the keys of the stale responses which
+// are refreshed in the background so that there is only one
+// request per key.
+var cacheRefreshes = struct {
+	sync.Mutex
+	m map[string]bool
+}{m: map[string]bool{}}
+
+// newCacheHandler returns a handler which serves GET requests from the
+// cache while the stored response is fresh. The upstream server controls
+// the lifetime with the max-age and s-maxage directives of the
+// Cache-Control header. The ttl of the policy is used if the response
+// has neither. Stale responses with an ETag or Last-Modified header are
+// revalidated with a conditional request. Responses with no-store,
+// no-cache or private, a Set-Cookie header or a Vary header other than
+// Accept-Encoding and requests with an Authorization header are not
+// cached.
+//
+// Within the stale-while-revalidate window of a stale response it is
+// served immediately and refreshed in the background. Within the
+// stale-if-error window it is served instead of a 5xx response or a
+// failed request.
+func newCacheHandler(h http.Handler, c Cache, route string, p cachePolicy) http.Handler {
+	hit := metrics.DefaultRegistry.GetCounter("cache.hit")
+	stale := metrics.DefaultRegistry.GetCounter("cache.stale")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cacheableRequest(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		key := route + " " + r.Host + r.URL.RequestURI() + " " + r.Header.Get("Accept-Encoding")
+		reqCC := parseCacheControl(r.Header.Get("Cache-Control"))
+
+		now := time.Now()
+		resp := c.Get(key)
+		if resp != nil && !reqCC["no-cache"] {
+			switch {
+			case now.Before(resp.Expires):
+				hit.Inc(1)
+				serveCached(w, r, resp, now)
+				return
+			case now.Before(resp.Expires.Add(resp.StaleWhileRevalidate)):
+				stale.Inc(1)
+				refreshCached(h, c, key, r, resp, p)
+				serveCached(w, r, resp, now)
+				return
+			}
+		}
+		fetchCached(h, c, key, w, r, resp, p, now)
+	})
+}
+
+// credentialHeaders are removed from the background requests which
+// refresh a stale response since it is shared by all clients. The
+// identity headers of the auth middleware are not set since routes
+// with authentication are not cached.
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// refreshCached fetches the stale response for the key in the
+// background unless it is already being refreshed.
+func refreshCached(h http.Handler, c Cache, key string, r *http.Request, resp *CachedResponse, p cachePolicy) {
+	cacheRefreshes.Lock()
+	if cacheRefreshes.m[key] {
+		cacheRefreshes.Unlock()
+		return
+	}
+	cacheRefreshes.m[key] = true
+	cacheRefreshes.Unlock()
+
+	// the request must not be canceled with the client request
+	ctx, cancel := context.WithTimeout(context.Background(), cacheRefreshTimeout)
+	br := r.Clone(ctx)
+	br.Body = http.NoBody
+	br.Header.Del("If-None-Match")
+	br.Header.Del("If-Modified-Since")
+	for _, name := range credentialHeaders {
+		br.Header.Del(name)
+	}
+	go func() {
+		defer func() {
+			cancel()
+			cacheRefreshes.Lock()
+			delete(cacheRefreshes.m, key)
+			cacheRefreshes.Unlock()
+		}()
+		fetchCached(h, c, key, &discardWriter{hdr: http.Header{}}, br, resp, p, time.Now())
+	}()
+}
+
+// fetchCached sends the request upstream, revalidates the stale
+// response resp if it has validators and stores the new response.
+// The stale response is served instead of an upstream error within
+// its stale-if-error window.
+func fetchCached(h http.Handler, c Cache, key string, w http.ResponseWriter, r *http.Request, resp *CachedResponse, p cachePolicy, now time.Time) {
+	miss := metrics.DefaultRegistry.GetCounter("cache.miss")
+	revalidated := metrics.DefaultRegistry.GetCounter("cache.revalidated")
+	staleIfError := metrics.DefaultRegistry.GetCounter("cache.staleiferror")
+	reqCC := parseCacheControl(r.Header.Get("Cache-Control"))
+
+	// revalidate the stale response unless the
+	// client sends its own conditional request.
+	rec := &cacheRecorder{w: w}
+	if resp != nil && now.Before(resp.Expires.Add(resp.StaleIfError)) {
+		rec.stale = resp
+	}
+	if resp != nil && r.Header.Get("If-None-Match") == "" && r.Header.Get("If-Modified-Since") == "" {
+		if etag := resp.Header.Get("Etag"); etag != "" {
+			r.Header.Set("If-None-Match", etag)
+			rec.cached = resp
+		}
+		if lm := resp.Header.Get("Last-Modified"); lm != "" {
+			r.Header.Set("If-Modified-Since", lm)
+			rec.cached = resp
+		}
+	}
+	if rec.cached == nil {
+		miss.Inc(1)
+	}
+	h.ServeHTTP(rec, r)
+	if rec.cached != nil {
+		r.Header.Del("If-None-Match")
+		r.Header.Del("If-Modified-Since")
+	}
+
+	if rec.failed {
+		staleIfError.Inc(1)
+		serveCached(w, r, rec.stale, now)
+		return
+	}
+
+	if rec.notModified {
+		revalidated.Inc(1)
+		fresh := *rec.cached
+		fresh.Header = rec.cached.Header.Clone()
+		for k, v := range rec.header {
+			fresh.Header[k] = v
+		}
+		fresh.Stored, fresh.Expires = now, now.Add(cacheLifetime(fresh.Header, p.ttl))
+		fresh.StaleWhileRevalidate, fresh.StaleIfError = staleWindows(fresh.Header, p)
+		if fresh.Expires.After(now) && cacheableResponse(fresh.Header) {
+			c.Set(key, &fresh)
+		}
+		serveCached(w, r, &fresh, now)
+		return
+	}
+
+	if reqCC["no-store"] || rec.code != http.StatusOK || rec.skip || !cacheableResponse(rec.header) {
+		return
+	}
+	d := cacheLifetime(rec.header, p.ttl)
+	if d <= 0 {
+		return
+	}
+	swr, sie := staleWindows(rec.header, p)
+	c.Set(key, &CachedResponse{
+		Code:                 rec.code,
+		Header:               rec.header,
+		Body:                 rec.body.Bytes(),
+		Stored:               now,
+		Expires:              now.Add(d),
+		StaleWhileRevalidate: swr,
+		StaleIfError:         sie,
+	})
+}
+
+// authOpts are the route options which authenticate the users.
+// The responses of these routes can be personalized and the
+// credentials are not part of the cache key.
+var authOpts = []string{"auth", "jwt", "oidc", "extauthz"}
+
+// cacheableTarget returns true if the responses of the target
+// can be shared between users.
+func cacheableTarget(t *route.Target) bool {
+	for _, opt := range authOpts {
+		if _, ok := t.Opts[opt]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheableRequest returns true for GET requests which
+// can be served from and stored in a shared cache.
+// Requests with credentials or cookies are not cached.
+func cacheableRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		r.Header.Get("Authorization") == "" &&
+		r.Header.Get("Cookie") == "" &&
+		r.Header.Get("Upgrade") == "" &&
+		r.Header.Get("Range") == ""
+}
+
+// cacheableResponse returns true if a shared cache
+// can store the response with the header.
+func cacheableResponse(hdr http.Header) bool {
+	cc := parseCacheControl(hdr.Get("Cache-Control"))
+	if cc["no-store"] || cc["no-cache"] || cc["private"] {
+		return false
+	}
+	if hdr.Get("Set-Cookie") != "" {
+		return false
+	}
+	for _, v := range hdr["Vary"] {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" && !strings.EqualFold(f, "Accept-Encoding") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// cacheLifetime returns the freshness lifetime of the response from
+// the s-maxage or max-age directives or ttl if there is neither.
+func cacheLifetime(hdr http.Header, ttl time.Duration) time.Duration {
+	cc := hdr.Get("Cache-Control")
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if v, ok := cacheControlValue(cc, name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return 0
+			}
+			return time.Duration(n) * time.Second
+		}
+	}
+	return ttl
+}
+
+// staleWindows returns the stale-while-revalidate and stale-if-error
+// windows of the response from its Cache-Control header or from the
+// policy of the route. must-revalidate and proxy-revalidate forbid
+// serving stale responses.
+func staleWindows(hdr http.Header, p cachePolicy) (swr, sie time.Duration) {
+	cc := hdr.Get("Cache-Control")
+	if d := parseCacheControl(cc); d["must-revalidate"] || d["proxy-revalidate"] {
+		return 0, 0
+	}
+	window := func(name string, def time.Duration) time.Duration {
+		v, ok := cacheControlValue(cc, name)
+		if !ok {
+			return def
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0
+		}
+		return time.Duration(n) * time.Second
+	}
+	return window("stale-while-revalidate", p.staleWhileRevalidate), window("stale-if-error", p.staleIfError)
+}
+
+// parseCacheControl returns the directives of a Cache-Control
+// header without a value.
+func parseCacheControl(v string) map[string]bool {
+	m := map[string]bool{}
+	for _, d := range strings.Split(v, ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d != "" && !strings.Contains(d, "=") {
+			m[d] = true
+		}
+	}
+	return m
+}
+
+// cacheControlValue returns the value of the directive
+// of a Cache-Control header.
+func cacheControlValue(v, name string) (string, bool) {
+	for _, d := range strings.Split(v, ",") {
+		p := strings.SplitN(strings.TrimSpace(d), "=", 2)
+		if len(p) == 2 && strings.EqualFold(p[0], name) {
+			return strings.Trim(p[1], `"`), true
+		}
+	}
+	return "", false
+}
+
+// serveCached writes the cached response or '304 Not Modified'
+// if the ETag matches the If-None-Match header of the request.
+func serveCached(w http.ResponseWriter, r *http.Request, resp *CachedResponse, now time.Time) {
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Age", strconv.Itoa(int(now.Sub(resp.Stored).Seconds())))
+	if etag := resp.Header.Get("Etag"); etag != "" && r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(resp.Code)
+	w.Write(resp.Body)
+}
+
+// cacheRecorder writes the response to the underlying writer and
+// records a copy. skip is set if the response cannot be cached. If
+// cached is set the request is a revalidation of the cached response
+// and a '304 Not Modified' response is not passed through. If stale
+// is set a 5xx response is not passed through and failed is set so
+// that the stale response can be served instead.
+type cacheRecorder struct {
+	w           http.ResponseWriter
+	cached      *CachedResponse
+	stale       *CachedResponse
+	notModified bool
+	failed      bool
+	code        int
+	header      http.Header
+	body        bytes.Buffer
+	skip        bool
+}
+
+func (rec *cacheRecorder) Header() http.Header {
+	return rec.w.Header()
+}
+
+func (rec *cacheRecorder) WriteHeader(code int) {
+	if isInformational(code) {
+		rec.w.WriteHeader(code)
+		return
+	}
+	if rec.code != 0 {
+		return
+	}
+	rec.code = code
+	rec.header = rec.w.Header().Clone()
+	if code == http.StatusNotModified && rec.cached != nil {
+		rec.notModified = true
+	}
+	if code >= 500 && rec.stale != nil {
+		rec.failed = true
+	}
+	if rec.notModified || rec.failed {
+		for k := range rec.w.Header() {
+			delete(rec.w.Header(), k)
+		}
+		return
+	}
+	rec.w.WriteHeader(code)
+}
+
+func (rec *cacheRecorder) Write(b []byte) (int, error) {
+	if rec.code == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	if rec.notModified || rec.failed {
+		return len(b), nil
+	}
+	if !rec.skip {
+		if rec.body.Len()+len(b) > maxCachedBody {
+			rec.skip = true
+			rec.body = bytes.Buffer{}
+		} else {
+			rec.body.Write(b)
+		}
+	}
+	return rec.w.Write(b)
+}
+
+func (rec *cacheRecorder) Flush() {
+	if f, ok := rec.w.(http.Flusher); ok && !rec.notModified && !rec.failed {
+		f.Flush()
+	}
+}
+
+func (rec *cacheRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := rec.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("not a hijacker")
+	}
+	rec.skip = true
+	return hj.Hijack()
+}
+
+// discardWriter is the response writer for the background
+// requests which only update the cache.
+type discardWriter struct {
+	hdr http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.hdr }
+func (w *discardWriter) WriteHeader(int)             {}
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
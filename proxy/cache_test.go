@@ -0,0 +1,318 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/route"
+)
+
+func TestMemoryCache(t *testing.T) {
+	c := NewMemoryCache(2)
+	a, b := &CachedResponse{Code: 200}, &CachedResponse{Code: 201}
+
+	c.Set("a", a)
+	c.Set("b", b)
+	if got := c.Get("a"); got != a {
+		t.Fatalf("got %v want %v", got, a)
+	}
+
+	// b is the least recently used entry
+	c.Set("c", &CachedResponse{})
+	if got := c.Get("b"); got != nil {
+		t.Fatalf("got %v want b evicted", got)
+	}
+	if got := c.Get("a"); got != a {
+		t.Fatalf("got %v want %v", got, a)
+	}
+}
+
+func TestCacheLifetime(t *testing.T) {
+	tests := []struct {
+		cc   string
+		want time.Duration
+	}{
+		{"", time.Minute},
+		{"public", time.Minute},
+		{"max-age=10", 10 * time.Second},
+		{"public, max-age=10, s-maxage=20", 20 * time.Second},
+		{"max-age=foo", 0},
+	}
+	for i, tt := range tests {
+		hdr := http.Header{"Cache-Control": {tt.cc}}
+		if got := cacheLifetime(hdr, time.Minute); got != tt.want {
+			t.Errorf("%d: %q: got %s want %s", i, tt.cc, got, tt.want)
+		}
+	}
+}
+
+func TestCacheHandler(t *testing.T) {
+	var calls int
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte("call " + strconv.Itoa(calls)))
+	})
+	c := NewMemoryCache(10)
+
+	req := func(method string, reqHdr http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/data", nil)
+		for k, v := range reqHdr {
+			r.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		newCacheHandler(h, c, "/data", cachePolicy{ttl: time.Minute}).ServeHTTP(rec, r)
+		return rec
+	}
+
+	tests := []struct {
+		desc   string
+		method string
+		reqHdr http.Header
+		code   int
+		body   string
+		calls  int
+	}{
+		{"first request is sent upstream", "GET", nil, 200, "call 1", 1},
+		{"second request is cached", "GET", nil, 200, "call 1", 1},
+		{"POST is not cached", "POST", nil, 200, "call 2", 2},
+		{"authorized request is not cached", "GET", http.Header{"Authorization": {"x"}}, 200, "call 3", 3},
+		{"no-cache request bypasses the cache", "GET", http.Header{"Cache-Control": {"no-cache"}}, 200, "call 4", 4},
+		{"refreshed response is cached", "GET", nil, 200, "call 4", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			rec := req(tt.method, tt.reqHdr)
+			if got, want := rec.Code, tt.code; got != want {
+				t.Errorf("got code %d want %d", got, want)
+			}
+			if got, want := rec.Body.String(), tt.body; got != want {
+				t.Errorf("got body %q want %q", got, want)
+			}
+			if got, want := calls, tt.calls; got != want {
+				t.Errorf("got %d calls want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestCacheHandlerNotCacheable(t *testing.T) {
+	for _, hdr := range []http.Header{
+		{"Cache-Control": {"no-store"}},
+		{"Cache-Control": {"private, max-age=60"}},
+		{"Set-Cookie": {"a=b"}},
+		{"Vary": {"Accept-Encoding, Cookie"}},
+		{"Cache-Control": {"max-age=0"}},
+	} {
+		var calls int
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			for k, v := range hdr {
+				w.Header()[k] = v
+			}
+		})
+		c := NewMemoryCache(10)
+		for i := 0; i < 2; i++ {
+			newCacheHandler(h, c, "/", cachePolicy{ttl: time.Minute}).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}
+		if calls != 2 {
+			t.Errorf("%v: got %d calls want 2", hdr, calls)
+		}
+	}
+}
+
+func TestCacheableRequest(t *testing.T) {
+	for _, hdr := range []http.Header{
+		{"Authorization": {"Basic YTpi"}},
+		{"Cookie": {"session=abc"}},
+		{"Range": {"bytes=0-1"}},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header = hdr
+		if cacheableRequest(r) {
+			t.Errorf("%v: got cacheable want not cacheable", hdr)
+		}
+	}
+	if !cacheableRequest(httptest.NewRequest("GET", "/", nil)) {
+		t.Error("got not cacheable want cacheable")
+	}
+}
+
+func TestCacheableTarget(t *testing.T) {
+	for _, opts := range []string{"auth=a", "jwt=a", "oidc=a", "extauthz=a"} {
+		if cacheableTarget(&route.Target{Opts: route.ParseOpts(opts)}) {
+			t.Errorf("%s: got cacheable want not cacheable", opts)
+		}
+	}
+	if !cacheableTarget(&route.Target{Opts: route.ParseOpts("cache=1m")}) {
+		t.Error("got not cacheable want cacheable")
+	}
+}
+
+func TestCacheHandlerRevalidate(t *testing.T) {
+	var calls int
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Etag", `"v1"`)
+		w.Write([]byte("data"))
+	})
+	c := NewMemoryCache(10)
+	c.Set("/ example.com/ ", &CachedResponse{
+		Code:    200,
+		Header:  http.Header{"Etag": {`"v1"`}},
+		Body:    []byte("data"),
+		Expires: time.Now().Add(-time.Second),
+	})
+
+	// stale response is revalidated and served from the cache
+	rec := httptest.NewRecorder()
+	newCacheHandler(h, c, "/", cachePolicy{ttl: time.Minute}).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != 200 || rec.Body.String() != "data" || calls != 1 {
+		t.Fatalf("got %d %q after %d calls want 200 \"data\" after 1 call", rec.Code, rec.Body.String(), calls)
+	}
+	if resp := c.Get("/ example.com/ "); resp == nil || !resp.Expires.After(time.Now()) {
+		t.Fatal("revalidated response not refreshed")
+	}
+
+	// fresh response is served without a call
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("If-None-Match", `"v1"`)
+	rec = httptest.NewRecorder()
+	newCacheHandler(h, c, "/", cachePolicy{ttl: time.Minute}).ServeHTTP(rec, r)
+	if rec.Code != http.StatusNotModified || calls != 1 {
+		t.Fatalf("got %d after %d calls want 304 after 1 call", rec.Code, calls)
+	}
+}
+
+func TestStaleWindows(t *testing.T) {
+	p := cachePolicy{staleWhileRevalidate: time.Minute, staleIfError: time.Hour}
+	tests := []struct {
+		cc       string
+		swr, sie time.Duration
+	}{
+		{"", time.Minute, time.Hour},
+		{"max-age=10, stale-while-revalidate=5", 5 * time.Second, time.Hour},
+		{"stale-if-error=30, stale-while-revalidate=0", 0, 30 * time.Second},
+		{"stale-if-error=foo", time.Minute, 0},
+		{"max-age=10, must-revalidate", 0, 0},
+		{"proxy-revalidate", 0, 0},
+	}
+	for i, tt := range tests {
+		swr, sie := staleWindows(http.Header{"Cache-Control": {tt.cc}}, p)
+		if swr != tt.swr || sie != tt.sie {
+			t.Errorf("%d: %q: got %s %s want %s %s", i, tt.cc, swr, sie, tt.swr, tt.sie)
+		}
+	}
+}
+
+func TestCacheHandlerStaleWhileRevalidate(t *testing.T) {
+	calls := make(chan struct{}, 1)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("new"))
+		calls <- struct{}{}
+	})
+	c := NewMemoryCache(10)
+	c.Set("/ example.com/ ", &CachedResponse{
+		Code:                 200,
+		Header:               http.Header{},
+		Body:                 []byte("old"),
+		Expires:              time.Now().Add(-time.Second),
+		StaleWhileRevalidate: time.Minute,
+	})
+
+	// stale response is served and refreshed in the background
+	rec := httptest.NewRecorder()
+	newCacheHandler(h, c, "/", cachePolicy{ttl: time.Minute}).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != 200 || rec.Body.String() != "old" {
+		t.Fatalf("got %d %q want 200 \"old\"", rec.Code, rec.Body.String())
+	}
+	select {
+	case <-calls:
+	case <-time.After(time.Second):
+		t.Fatal("stale response not refreshed")
+	}
+
+	// wait for the refresh to store the response
+	deadline := time.Now().Add(time.Second)
+	for {
+		resp := c.Get("/ example.com/ ")
+		if resp != nil && string(resp.Body) == "new" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("refreshed response not stored")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRefreshCachedWithoutCredentials(t *testing.T) {
+	hdr := make(chan http.Header, 1)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hdr <- r.Header
+	})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Proxy-Authorization", "Basic YTpi")
+	r.Header.Set("Cookie", "session=abc")
+	r.Header.Set("Accept", "text/plain")
+	refreshCached(h, NewMemoryCache(10), "refresh", r, &CachedResponse{Header: http.Header{}}, cachePolicy{ttl: time.Minute})
+
+	select {
+	case got := <-hdr:
+		for _, name := range credentialHeaders {
+			if v := got.Get(name); v != "" {
+				t.Errorf("got %s %q want none", name, v)
+			}
+		}
+		if got.Get("Accept") != "text/plain" {
+			t.Error("got no Accept header")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+	if r.Header.Get("Cookie") == "" {
+		t.Error("client request modified")
+	}
+}
+
+func TestCacheHandlerStaleIfError(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("error"))
+	})
+	stale := func(sie time.Duration) Cache {
+		c := NewMemoryCache(10)
+		c.Set("/ example.com/ ", &CachedResponse{
+			Code:         200,
+			Header:       http.Header{"Content-Type": {"text/plain"}},
+			Body:         []byte("old"),
+			Expires:      time.Now().Add(-time.Second),
+			StaleIfError: sie,
+		})
+		return c
+	}
+
+	// stale response is served instead of the error
+	rec := httptest.NewRecorder()
+	newCacheHandler(h, stale(time.Minute), "/", cachePolicy{ttl: time.Minute}).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != 200 || rec.Body.String() != "old" {
+		t.Fatalf("got %d %q want 200 \"old\"", rec.Code, rec.Body.String())
+	}
+
+	// error is passed through outside of the window
+	rec = httptest.NewRecorder()
+	newCacheHandler(h, stale(0), "/", cachePolicy{ttl: time.Minute}).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusBadGateway || rec.Body.String() != "error" {
+		t.Fatalf("got %d %q want 502 \"error\"", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,197 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/eBay/fabio/metrics"
+)
+
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// checksumAlgos maps the value of the 'checksum' route option
+// to the hash function and the name of the algorithm in the
+// RFC 3230 Digest header.
+var checksumAlgos = map[string]struct {
+	digest string
+	hash   func() hash.Hash
+}{
+	"md5":    {"MD5", md5.New},
+	"sha1":   {"SHA", sha1.New},
+	"sha256": {"SHA-256", sha256.New},
+	"sha512": {"SHA-512", sha512.New},
+}
+
+// newChecksumRoundTripper returns a round tripper which verifies the
+// response body against the checksum in the given response header.
+// If header is empty then 'Content-MD5' is used for md5 and the
+// 'Digest' header for all other algorithms. For unknown algorithms
+// a warning is logged and the responses are not verified.
+func newChecksumRoundTripper(tr http.RoundTripper, algo, header string) http.RoundTripper {
+	if _, ok := checksumAlgos[algo]; !ok {
+		log.Printf("[WARN] Unknown checksum algorithm %q", algo)
+		return tr
+	}
+	if header == "" {
+		header = "Digest"
+		if algo == "md5" {
+			header = "Content-MD5"
+		}
+	}
+	return &checksumRoundTripper{tr: tr, algo: algo, header: header}
+}
+
+// checksumRoundTripper verifies the body of a successful response
+// against the checksum provided by the upstream server.
+type checksumRoundTripper struct {
+	tr     http.RoundTripper
+	algo   string
+	header string
+}
+
+func (c *checksumRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := c.tr.RoundTrip(r)
+	if err != nil || r.Method == "HEAD" || resp.StatusCode != http.StatusOK || resp.Uncompressed {
+		return resp, err
+	}
+
+	want, ok := c.checksum(resp.Header)
+	if !ok {
+		return resp, nil
+	}
+
+	resp.Body = &checksumReader{
+		rc:   resp.Body,
+		h:    checksumAlgos[c.algo].hash(),
+		want: want,
+		url:  r.URL,
+	}
+	return resp, nil
+}
+
+// checksum returns the decoded checksum from the response header.
+// Values of the 'Digest' header are base64 encoded. All other values
+// can be either hex or base64 encoded.
+func (c *checksumRoundTripper) checksum(h http.Header) ([]byte, bool) {
+	v := strings.TrimSpace(h.Get(c.header))
+	if v == "" {
+		return nil, false
+	}
+
+	if http.CanonicalHeaderKey(c.header) == "Digest" {
+		prefix := strings.ToLower(checksumAlgos[c.algo].digest) + "="
+		found := false
+		for _, d := range strings.Split(v, ",") {
+			d = strings.TrimSpace(d)
+			if strings.HasPrefix(strings.ToLower(d), prefix) {
+				v, found = d[len(prefix):], true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+		b, err := base64.StdEncoding.DecodeString(v)
+		return b, err == nil
+	}
+
+	size := checksumAlgos[c.algo].hash().Size()
+	if len(v) == 2*size {
+		if b, err := hex.DecodeString(v); err == nil {
+			return b, true
+		}
+	}
+	b, err := base64.StdEncoding.DecodeString(v)
+	return b, err == nil
+}
+
+// checksumReader computes the checksum of the body while it is
+// read and returns an error instead of io.EOF when the checksum
+// does not match. Since the response is streamed to the client
+// the reverse proxy aborts the response in that case.
+//
+// The reader holds back the last byte it has read until the end
+// of the body has been reached so that a response with a known
+// content length is incomplete when it is aborted.
+type checksumReader struct {
+	rc   io.ReadCloser
+	h    hash.Hash
+	want []byte
+	url  *url.URL
+
+	last    byte
+	hasLast bool
+	eof     bool
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if c.eof {
+		return c.flush(p, 0)
+	}
+
+	n, err := c.rc.Read(p)
+	c.h.Write(p[:n])
+
+	// emit the previously held byte and hold the last one
+	out := 0
+	if n > 0 {
+		last := p[n-1]
+		if c.hasLast {
+			copy(p[1:n], p[:n-1])
+			p[0] = c.last
+			out = n
+		} else {
+			out = n - 1
+		}
+		c.last, c.hasLast = last, true
+	}
+
+	if err != io.EOF {
+		return out, err
+	}
+
+	c.eof = true
+	if !bytes.Equal(c.h.Sum(nil), c.want) {
+		log.Printf("[WARN] Checksum mismatch for %s", c.url)
+		metrics.DefaultRegistry.GetCounter("checksum.mismatch").Inc(1)
+		c.hasLast = false
+		return out, errChecksumMismatch
+	}
+	return c.flush(p, out)
+}
+
+// flush appends the held byte to p[:n] if there is room
+// and returns io.EOF once there is nothing left to read.
+func (c *checksumReader) flush(p []byte, n int) (int, error) {
+	if !c.hasLast {
+		if n == 0 && !bytes.Equal(c.h.Sum(nil), c.want) {
+			return 0, errChecksumMismatch
+		}
+		return n, io.EOF
+	}
+	if n == len(p) {
+		return n, nil
+	}
+	p[n] = c.last
+	c.hasLast = false
+	return n + 1, io.EOF
+}
+
+func (c *checksumReader) Close() error {
+	return c.rc.Close()
+}
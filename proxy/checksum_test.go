@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"testing/iotest"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/route"
+	"github.com/eBay/fabio/stats"
+)
+
+func TestChecksum(t *testing.T) {
+	// checksums for "Hello World"
+	const (
+		md5b64    = "sQqNsWTgdUEFt6mb5y4/5Q=="
+		sha256b64 = "pZGm1Av0IEBKARczz7exkNYsZb8LzaMrV7J32a2fFG4="
+		sha256hex = "a591a6d40bf420404a011733cfb7b190d62c65bf0bcda32b57b277d9ad9f146e"
+	)
+
+	tests := []struct {
+		desc   string
+		opts   string
+		header http.Header
+		body   string
+		err    error
+	}{
+		{"no header", "checksum=sha256", nil, "Hello World", nil},
+		{"md5 ok", "checksum=md5", http.Header{"Content-Md5": {md5b64}}, "Hello World", nil},
+		{"md5 mismatch", "checksum=md5", http.Header{"Content-Md5": {md5b64}}, "Hello", errChecksumMismatch},
+		{"digest ok", "checksum=sha256", http.Header{"Digest": {"MD5=" + md5b64 + ", SHA-256=" + sha256b64}}, "Hello World", nil},
+		{"digest mismatch", "checksum=sha256", http.Header{"Digest": {"SHA-256=" + sha256b64}}, "Hello Worl", errChecksumMismatch},
+		{"digest other algo", "checksum=sha256", http.Header{"Digest": {"MD5=" + md5b64}}, "Hello", nil},
+		{"custom header hex ok", "checksum=sha256 checksumheader=X-Checksum-Sha256", http.Header{"X-Checksum-Sha256": {sha256hex}}, "Hello World", nil},
+		{"custom header hex mismatch", "checksum=sha256 checksumheader=X-Checksum-Sha256", http.Header{"X-Checksum-Sha256": {sha256hex}}, "", errChecksumMismatch},
+		{"unknown algo", "checksum=crc32", http.Header{"Digest": {"SHA-256=" + sha256b64}}, "Hello", nil},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture loop var
+		t.Run(tt.desc, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.header {
+					w.Header()[k] = v
+				}
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			opts := route.ParseOpts(tt.opts)
+			tr := newChecksumRoundTripper(&http.Transport{Dial: (&net.Dialer{}).Dial}, opts["checksum"], opts["checksumheader"])
+			resp, err := tr.RoundTrip(httptest.NewRequest("GET", server.URL, nil))
+			if err != nil {
+				t.Fatalf("got %v want nil", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if got, want := err, tt.err; got != want {
+				t.Fatalf("got %v want %v", got, want)
+			}
+			if err == nil && string(body) != tt.body {
+				t.Fatalf("got body %q want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestChecksumReader(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	sum := sha256.Sum256(data)
+
+	tests := []struct {
+		desc string
+		r    io.Reader
+		want []byte
+		err  error
+	}{
+		{"match", bytes.NewReader(data), data, nil},
+		{"match one byte reads", iotest.OneByteReader(bytes.NewReader(data)), data, nil},
+		{"match data with eof", iotest.DataErrReader(bytes.NewReader(data)), data, nil},
+		{"mismatch", bytes.NewReader(data[1:]), data[1 : len(data)-1], errChecksumMismatch},
+		{"mismatch one byte reads", iotest.OneByteReader(bytes.NewReader(data[1:])), data[1 : len(data)-1], errChecksumMismatch},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture loop var
+		t.Run(tt.desc, func(t *testing.T) {
+			r := &checksumReader{rc: ioutil.NopCloser(tt.r), h: sha256.New(), want: sum[:], url: &url.URL{}}
+			var buf bytes.Buffer
+			_, err := io.Copy(&buf, r)
+			if got, want := err, tt.err; got != want {
+				t.Fatalf("got %v want %v", got, want)
+			}
+			if got, want := buf.Bytes(), tt.want; !bytes.Equal(got, want) {
+				t.Fatalf("got %d bytes want %d bytes", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestProxyChecksumMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-MD5", "sQqNsWTgdUEFt6mb5y4/5Q==")
+		w.Write([]byte("Hello"))
+	}))
+	defer server.Close()
+
+	table := make(route.Table)
+	table.AddRouteOpts("mock", "/", server.URL, 1, nil, map[string]string{"checksum": "md5"})
+	route.SetTable(table)
+
+	tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+	proxy := httptest.NewServer(NewHTTPProxy(tr, config.Proxy{}))
+	defer proxy.Close()
+
+	// the response is aborted before the last byte has been sent
+	resp, err := http.Get(proxy.URL)
+	if err == nil {
+		defer resp.Body.Close()
+		_, err = ioutil.ReadAll(resp.Body)
+	}
+	if err == nil {
+		t.Fatal("got nil want error for aborted response")
+	}
+
+	// the aborted response is counted as an error
+	u, _ := url.Parse(server.URL)
+	_, targets := stats.Default.Top(0, "rps")
+	var n, errs int64
+	for _, e := range targets {
+		if e.Target == u.Host {
+			n, errs = e.Requests, e.Errors
+		}
+	}
+	if n != 1 || errs != 1 {
+		t.Fatalf("got %d requests with %d errors want 1 request with 1 error", n, errs)
+	}
+}
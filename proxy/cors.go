@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eBay/fabio/route"
+)
+
+// isPreflight returns true for a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// preflight answers the CORS preflight request with '204 No Content'
+// and the CORS headers if the origin, the method and the headers of
+// the actual request are allowed and with '403 Forbidden' otherwise.
+// It returns the status code of the response.
+func preflight(w http.ResponseWriter, r *http.Request, c *route.CORS) int {
+	origin := r.Header.Get("Origin")
+	method := r.Header.Get("Access-Control-Request-Method")
+	if !c.AllowOrigin(origin) || !c.AllowMethod(method) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return http.StatusForbidden
+	}
+	var headers []string
+	for _, v := range r.Header["Access-Control-Request-Headers"] {
+		for _, h := range strings.Split(v, ",") {
+			if h = strings.TrimSpace(h); h == "" {
+				continue
+			}
+			if !c.AllowHeader(h) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return http.StatusForbidden
+			}
+			headers = append(headers, h)
+		}
+	}
+
+	hdr := w.Header()
+	setCORSOrigin(hdr, origin, c)
+	hdr.Add("Vary", "Origin")
+	hdr.Set("Access-Control-Allow-Methods", strings.Join(c.Methods, ", "))
+	if len(headers) > 0 {
+		hdr.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+	if c.MaxAge > 0 {
+		hdr.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge.Seconds())))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return http.StatusNoContent
+}
+
+// corsHeaders adds the CORS headers for the response to a request from
+// an allowed origin to the response headers and returns them.
+func corsHeaders(hdr http.Header, r *http.Request, c *route.CORS) http.Header {
+	origin := r.Header.Get("Origin")
+	if c == nil || origin == "" || !c.AllowOrigin(origin) {
+		return hdr
+	}
+	if hdr == nil {
+		hdr = http.Header{}
+	}
+	setCORSOrigin(hdr, origin, c)
+	return hdr
+}
+
+// setCORSOrigin sets the allowed origin to '*' if all origins are allowed
+// without credentials and to the origin of the request otherwise.
+func setCORSOrigin(hdr http.Header, origin string, c *route.CORS) {
+	if c.AllowOrigin("*") && !c.Credentials {
+		hdr.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	hdr.Set("Access-Control-Allow-Origin", origin)
+	if c.Credentials {
+		hdr.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/route"
+)
+
+func TestProxyCORS(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	tbl, err := route.ParseString(`
+route add a /a ` + server.URL + ` opts "cors-origins=https://app.com cors-methods=GET,PUT cors-headers=X-Token cors-maxage=10m cors-credentials"
+route add b /b ` + server.URL + ` opts "cors-origins=*"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.SetTable(tbl)
+
+	tests := []struct {
+		desc   string
+		method string
+		path   string
+		hdr    map[string]string
+		code   int
+		resp   map[string]string
+		calls  int
+	}{
+		{
+			"preflight",
+			"OPTIONS", "/a",
+			map[string]string{"Origin": "https://app.com", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "x-token"},
+			204,
+			map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "GET, PUT",
+				"Access-Control-Allow-Headers":     "x-token",
+				"Access-Control-Max-Age":           "600",
+			},
+			0,
+		},
+		{
+			"preflight with invalid origin",
+			"OPTIONS", "/a",
+			map[string]string{"Origin": "https://evil.com", "Access-Control-Request-Method": "PUT"},
+			403, map[string]string{"Access-Control-Allow-Origin": ""}, 0,
+		},
+		{
+			"preflight with invalid method",
+			"OPTIONS", "/a",
+			map[string]string{"Origin": "https://app.com", "Access-Control-Request-Method": "DELETE"},
+			403, nil, 0,
+		},
+		{
+			"preflight with invalid header",
+			"OPTIONS", "/a",
+			map[string]string{"Origin": "https://app.com", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-Other"},
+			403, nil, 0,
+		},
+		{
+			"request",
+			"GET", "/a",
+			map[string]string{"Origin": "https://app.com"},
+			200, map[string]string{"Access-Control-Allow-Origin": "https://app.com", "Access-Control-Allow-Credentials": "true"}, 1,
+		},
+		{
+			"request from invalid origin",
+			"GET", "/a",
+			map[string]string{"Origin": "https://evil.com"},
+			200, map[string]string{"Access-Control-Allow-Origin": ""}, 2,
+		},
+		{
+			"request for all origins",
+			"GET", "/b",
+			map[string]string{"Origin": "https://other.com"},
+			200, map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Credentials": ""}, 3,
+		},
+		{
+			"options without preflight is proxied",
+			"OPTIONS", "/a",
+			map[string]string{"Origin": "https://app.com"},
+			200, nil, 4,
+		},
+	}
+
+	proxy := NewHTTPProxy(&http.Transport{}, config.Proxy{})
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for k, v := range tt.hdr {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			proxy.ServeHTTP(rec, req)
+			if got, want := rec.Code, tt.code; got != want {
+				t.Errorf("got code %d want %d", got, want)
+			}
+			for k, v := range tt.resp {
+				if got := rec.Header().Get(k); got != v {
+					t.Errorf("got %s %q want %q", k, got, v)
+				}
+			}
+			if got, want := calls, tt.calls; got != want {
+				t.Errorf("got %d calls want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestProxyCORSVary(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Accept-Encoding")
+	}))
+	defer server.Close()
+
+	tbl, err := route.ParseString(`route add a /a ` + server.URL + ` opts "cors-origins=https://app.com"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.SetTable(tbl)
+
+	req := httptest.NewRequest("GET", "/a", nil)
+	req.Header.Set("Origin", "https://app.com")
+	rec := httptest.NewRecorder()
+	NewHTTPProxy(&http.Transport{}, config.Proxy{}).ServeHTTP(rec, req)
+	if got, want := rec.Header()["Vary"], []string{"Accept-Encoding", "Origin"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got Vary %v want %v", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eBay/fabio/route"
+)
+
+// parseTrustedNets parses the IP addresses and networks in CIDR
+// notation of the clients which may set the request timeout.
+// Invalid entries are logged and skipped since they have already
+// been validated by the config loader.
+func parseTrustedNets(s []string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, v := range s {
+		v = strings.TrimSpace(v)
+		if !strings.Contains(v, "/") {
+			if ip := net.ParseIP(v); ip != nil && ip.To4() != nil {
+				v += "/32"
+			} else {
+				v += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			log.Printf("[WARN] Invalid trusted network %q. %s", v, err)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// trusted returns true if the remote address of the
+// request is in one of the trusted networks.
+func trusted(r *http.Request, nets []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRequestTimeout parses the value of the request timeout header
+// which is either a duration or a number of milliseconds.
+func parseRequestTimeout(s string) (time.Duration, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(n) * time.Millisecond, n > 0
+	}
+	d, err := time.ParseDuration(s)
+	return d, err == nil && d > 0
+}
+
+// requestTimeout returns the deadline for the upstream request which
+// a trusted client has set with the request timeout header. The value
+// is capped by the 'maxrequesttimeout' option of the target and the
+// global maximum. requestTimeout returns zero if there is no deadline.
+func (p *httpProxy) requestTimeout(r *http.Request, t *route.Target) time.Duration {
+	if p.cfg.RequestTimeoutHeader == "" {
+		return 0
+	}
+	v := r.Header.Get(p.cfg.RequestTimeoutHeader)
+	if v == "" || !trusted(r, p.trusted) {
+		return 0
+	}
+	d, ok := parseRequestTimeout(v)
+	if !ok {
+		log.Printf("[WARN] Invalid request timeout %q from %s", v, r.RemoteAddr)
+		return 0
+	}
+	if max := t.MaxRequestTimeout; max > 0 && d > max {
+		d = max
+	}
+	if max := p.cfg.RequestTimeoutMax; max > 0 && d > max {
+		d = max
+	}
+	return d
+}
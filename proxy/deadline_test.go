@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/route"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	cfg := config.Proxy{
+		RequestTimeoutHeader:  "X-Request-Timeout",
+		RequestTimeoutTrusted: []string{"10.0.0.0/8", "1.2.3.4"},
+		RequestTimeoutMax:     time.Minute,
+	}
+	p := NewHTTPProxy(nil, cfg).(*httpProxy)
+
+	tests := []struct {
+		desc   string
+		remote string
+		value  string
+		max    time.Duration
+		want   time.Duration
+	}{
+		{"no header", "10.1.1.1:1234", "", 0, 0},
+		{"duration", "10.1.1.1:1234", "1.5s", 0, 1500 * time.Millisecond},
+		{"milliseconds", "1.2.3.4:1234", "300", 0, 300 * time.Millisecond},
+		{"untrusted", "1.2.3.5:1234", "1s", 0, 0},
+		{"invalid", "10.1.1.1:1234", "soon", 0, 0},
+		{"negative", "10.1.1.1:1234", "-1s", 0, 0},
+		{"zero", "10.1.1.1:1234", "0", 0, 0},
+		{"route max", "10.1.1.1:1234", "10s", 2 * time.Second, 2 * time.Second},
+		{"global max", "10.1.1.1:1234", "1h", 2 * time.Hour, time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			r := &http.Request{RemoteAddr: tt.remote, Header: http.Header{}}
+			if tt.value != "" {
+				r.Header.Set("X-Request-Timeout", tt.value)
+			}
+			if got, want := p.requestTimeout(r, &route.Target{MaxRequestTimeout: tt.max}), tt.want; got != want {
+				t.Fatalf("got %v want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRequestTimeoutDisabled(t *testing.T) {
+	p := NewHTTPProxy(nil, config.Proxy{RequestTimeoutTrusted: []string{"0.0.0.0/0"}}).(*httpProxy)
+	r := &http.Request{RemoteAddr: "1.2.3.4:1234", Header: http.Header{"X-Request-Timeout": {"1s"}}}
+	if got := p.requestTimeout(r, &route.Target{}); got != 0 {
+		t.Fatalf("got %v want 0", got)
+	}
+}
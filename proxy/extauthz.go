@@ -0,0 +1,22 @@
+package proxy
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/eBay/fabio/extauthz"
+)
+
+// extAuthz asks the external authorization service with the given
+// name whether the request is allowed. It returns the status code of
+// the response or zero if the request is allowed. Requests for unknown
+// services are rejected.
+func extAuthz(w http.ResponseWriter, r *http.Request, name string) int {
+	s := extauthz.Services[name]
+	if s == nil {
+		log.Printf("[WARN] Unknown extauthz service %s for %s%s", name, r.Host, r.URL)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+	return s.Authorize(w, r)
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eBay/fabio/metrics"
+	"github.com/eBay/fabio/route"
+)
+
+// newFallbackRoundTripper returns a round tripper which retries a
+// request once against a target of the fallback service if the
+// primary target responds with one of the given status codes or
+// fails without a response, e.g. since it cannot be reached.
+// statuses is a comma separated list of status codes and defaults
+// to all 5xx status codes if it is empty.
+func newFallbackRoundTripper(tr http.RoundTripper, service, statuses string) http.RoundTripper {
+	f := &fallbackRoundTripper{tr: tr, service: service}
+	for _, s := range strings.Split(statuses, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		code, err := strconv.Atoi(s)
+		if err != nil {
+			log.Printf("[WARN] Ignoring invalid fallback status %q", s)
+			continue
+		}
+		f.statuses = append(f.statuses, code)
+	}
+	return f
+}
+
+type fallbackRoundTripper struct {
+	tr       http.RoundTripper
+	service  string
+	statuses []int
+}
+
+func (f *fallbackRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := f.tr.RoundTrip(r)
+	switch {
+	case err != nil:
+		// the client has gone away
+		if r.Context().Err() != nil {
+			return resp, err
+		}
+	case !f.retry(resp.StatusCode):
+		return resp, err
+	}
+
+	// requests with a body cannot be replayed
+	if r.Body != nil && r.Body != http.NoBody {
+		return resp, err
+	}
+
+	t := route.GetTable().LookupService(f.service)
+	if t == nil {
+		log.Printf("[WARN] No target for fallback service %s", f.service)
+		return resp, err
+	}
+
+	out := new(http.Request)
+	*out = *r
+	u := *r.URL
+	u.Scheme, u.Host = t.URL.Scheme, t.URL.Host
+	out.URL = &u
+
+	fresp, ferr := f.tr.RoundTrip(out)
+	if ferr != nil {
+		log.Printf("[WARN] Fallback to %s for %s failed. %s", f.service, r.URL, ferr)
+		return resp, err
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	metrics.DefaultRegistry.GetCounter("fallback").Inc(1)
+	return fresp, nil
+}
+
+func (f *fallbackRoundTripper) retry(code int) bool {
+	if len(f.statuses) == 0 {
+		return code >= 500 && code <= 599
+	}
+	for _, s := range f.statuses {
+		if s == code {
+			return true
+		}
+	}
+	return false
+}
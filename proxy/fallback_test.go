@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/route"
+)
+
+func TestProxyFallback(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(503)
+		w.Write([]byte("primary"))
+	}))
+	defer primary.Close()
+
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("backup"))
+	}))
+	defer backup.Close()
+
+	tests := []struct {
+		desc   string
+		opts   string
+		method string
+		body   string
+		code   int
+		resp   string
+	}{
+		{"no fallback", "", "GET", "", 503, "primary"},
+		{"fallback on 5xx", "fallback=backup", "GET", "", 200, "backup"},
+		{"fallback on status", "fallback=backup fallbackstatus=502,503", "GET", "", 200, "backup"},
+		{"no fallback on other status", "fallback=backup fallbackstatus=502", "GET", "", 503, "primary"},
+		{"no fallback with body", "fallback=backup", "POST", "data", 503, "primary"},
+		{"unknown fallback service", "fallback=foo", "GET", "", 503, "primary"},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture loop var
+		t.Run(tt.desc, func(t *testing.T) {
+			table := make(route.Table)
+			table.AddRouteOpts("primary", "/", primary.URL, 0, nil, route.ParseOpts(tt.opts))
+			table.AddRoute("backup", "/backup", backup.URL, 0, nil)
+			route.SetTable(table)
+
+			tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+			proxy := NewHTTPProxy(tr, config.Proxy{})
+			req := httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body))
+			if tt.body == "" {
+				req.Body = http.NoBody
+			}
+			req.URL = &url.URL{}
+			rec := httptest.NewRecorder()
+			proxy.ServeHTTP(rec, req)
+
+			if got, want := rec.Code, tt.code; got != want {
+				t.Errorf("got code %d want %d", got, want)
+			}
+			if got, want := rec.Body.String(), tt.resp; got != want {
+				t.Errorf("got body %q want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestProxyFallbackTransportError(t *testing.T) {
+	// a closed listener refuses the connections to the primary target
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	primaryURL := "http://" + l.Addr().String()
+	l.Close()
+
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("backup"))
+	}))
+	defer backup.Close()
+
+	tests := []struct {
+		desc string
+		opts string
+		code int
+		resp string
+	}{
+		{"no fallback", "", 502, ""},
+		{"fallback", "fallback=backup", 200, "backup"},
+		{"fallback with status", "fallback=backup fallbackstatus=503", 200, "backup"},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture loop var
+		t.Run(tt.desc, func(t *testing.T) {
+			table := make(route.Table)
+			table.AddRouteOpts("primary", "/", primaryURL, 0, nil, route.ParseOpts(tt.opts))
+			table.AddRoute("backup", "/backup", backup.URL, 0, nil)
+			route.SetTable(table)
+
+			tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+			proxy := NewHTTPProxy(tr, config.Proxy{})
+			req := httptest.NewRequest("GET", "/", nil)
+			req.URL = &url.URL{}
+			rec := httptest.NewRecorder()
+			proxy.ServeHTTP(rec, req)
+
+			if got, want := rec.Code, tt.code; got != want {
+				t.Errorf("got code %d want %d", got, want)
+			}
+			if tt.resp != "" {
+				if got, want := rec.Body.String(), tt.resp; got != want {
+					t.Errorf("got body %q want %q", got, want)
+				}
+			}
+		})
+	}
+}
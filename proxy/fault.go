@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/eBay/fabio/metrics"
+	"github.com/eBay/fabio/route"
+)
+
+// faultRand returns a random number in [0, 1) which decides whether a
+// fault is injected. It is a variable so that tests can replace it.
+var faultRand = rand.Float64
+
+// faultMiddleware injects the latency and errors of the 'delay' and
+// 'abort' options of the target. The delay ends early when the client
+// goes away. Injected faults are counted in the 'faults.delay' and
+// 'faults.abort' metrics.
+func faultMiddleware(w http.ResponseWriter, r *http.Request, t *route.Target) int {
+	f := t.Fault
+	if f == nil {
+		return 0
+	}
+
+	if f.Delay > 0 && faultRand() < f.DelayRatio {
+		metrics.DefaultRegistry.GetCounter("faults.delay").Inc(1)
+		timer := time.NewTimer(f.Delay)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+		}
+	}
+
+	if f.AbortStatus > 0 && faultRand() < f.AbortRatio {
+		metrics.DefaultRegistry.GetCounter("faults.abort").Inc(1)
+		if !writeErrorPage(w, f.AbortStatus) {
+			http.Error(w, http.StatusText(f.AbortStatus), f.AbortStatus)
+		}
+		return f.AbortStatus
+	}
+	return 0
+}
@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/route"
+)
+
+func TestFaultMiddleware(t *testing.T) {
+	defer func(fn func() float64) { faultRand = fn }(faultRand)
+
+	tests := []struct {
+		desc  string
+		fault *route.Fault
+		rand  float64
+		code  int
+		delay bool
+	}{
+		{"no fault", nil, 0, 0, false},
+		{"abort", &route.Fault{AbortStatus: 503, AbortRatio: 0.01}, 0.005, 503, false},
+		{"no abort", &route.Fault{AbortStatus: 503, AbortRatio: 0.01}, 0.5, 0, false},
+		{"delay", &route.Fault{Delay: 50 * time.Millisecond, DelayRatio: 0.5}, 0.1, 0, true},
+		{"no delay", &route.Fault{Delay: 50 * time.Millisecond, DelayRatio: 0.5}, 0.9, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			faultRand = func() float64 { return tt.rand }
+			w := httptest.NewRecorder()
+			start := time.Now()
+			code := faultMiddleware(w, httptest.NewRequest("GET", "/", nil), &route.Target{Fault: tt.fault})
+			if got, want := code, tt.code; got != want {
+				t.Fatalf("got code %d want %d", got, want)
+			}
+			if tt.code != 0 && w.Code != tt.code {
+				t.Fatalf("got response code %d want %d", w.Code, tt.code)
+			}
+			if got, want := time.Since(start) >= 50*time.Millisecond, tt.delay; got != want {
+				t.Fatalf("got delay %v want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFaultMiddlewareCancel(t *testing.T) {
+	defer func(fn func() float64) { faultRand = fn }(faultRand)
+	faultRand = func() float64 { return 0 }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+
+	start := time.Now()
+	faultMiddleware(httptest.NewRecorder(), r, &route.Target{Fault: &route.Fault{Delay: time.Minute, DelayRatio: 1}})
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("delay not canceled after %s", d)
+	}
+}
+
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"os"
+	"text/template"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/route"
+)
+
+// headerVars contains the variables for the response header templates.
+type headerVars struct {
+	Hostname   string // host name of the fabio server
+	Service    string // service name of the target
+	RouteSrc   string // host and path of the matching route
+	Target     string // URL of the target
+	Method     string // request method
+	Host       string // request host
+	Path       string // request path
+	RemoteAddr string // client address
+	RequestID  string // request id, see proxy.requestid.header
+}
+
+var hostname, _ = os.Hostname()
+
+// parseHeaderTemplates compiles the response header templates
+// from the config. Invalid templates are logged and ignored.
+func parseHeaderTemplates(m map[string]string) map[string]*template.Template {
+	if len(m) == 0 {
+		return nil
+	}
+	tmpls := map[string]*template.Template{}
+	for name, s := range m {
+		t, err := template.New(name).Parse(s)
+		if err != nil {
+			log.Printf("[WARN] Ignoring invalid template for response header %s. %s", name, err)
+			continue
+		}
+		tmpls[http.CanonicalHeaderKey(name)] = t
+	}
+	return tmpls
+}
+
+// securityHeaders returns the global security headers
+// for the responses to requests via HTTPS listeners.
+func securityHeaders(cfg config.Proxy) http.Header {
+	return route.SecurityHeaders{
+		STSMaxAge:          cfg.STSMaxAge,
+		STSSubdomains:      cfg.STSSubdomains,
+		STSPreload:         cfg.STSPreload,
+		FrameOptions:       cfg.FrameOptions,
+		ContentTypeOptions: cfg.ContentTypeOptions,
+		CSP:                cfg.CSP,
+	}.Header()
+}
+
+// responseHeaders returns the headers which replace the response headers
+// of the upstream server. These are the global and the route specific
+// security headers for requests via HTTPS listeners, the response header
+// templates and the request id header. Route specific headers take
+// precedence over the global ones.
+func (p *httpProxy) responseHeaders(r *http.Request, t *route.Target, id string) http.Header {
+	secure := r.TLS != nil && (len(p.security) > 0 || len(t.SecurityHeaders) > 0)
+	if len(p.headers) == 0 && len(t.ResponseHeaders) == 0 && id == "" && !secure {
+		return nil
+	}
+
+	hdr := http.Header{}
+	if secure {
+		for k, v := range p.security {
+			hdr[k] = v
+		}
+		for k, v := range t.SecurityHeaders {
+			hdr[k] = v
+		}
+	}
+	if len(p.headers) > 0 || len(t.ResponseHeaders) > 0 {
+		v := &headerVars{
+			Hostname:   hostname,
+			Service:    t.Service,
+			RouteSrc:   t.Route,
+			Target:     t.URL.String(),
+			Method:     r.Method,
+			Host:       r.Host,
+			Path:       r.URL.Path,
+			RemoteAddr: r.RemoteAddr,
+			RequestID:  id,
+		}
+		execHeaderTemplates(hdr, p.headers, v)
+		execHeaderTemplates(hdr, t.ResponseHeaders, v)
+	}
+	if id != "" {
+		hdr.Set(p.cfg.RequestIDHeader, id)
+	}
+	return hdr
+}
+
+func execHeaderTemplates(hdr http.Header, tmpls map[string]*template.Template, v *headerVars) {
+	var b bytes.Buffer
+	for name, t := range tmpls {
+		b.Reset()
+		if err := t.Execute(&b, v); err != nil {
+			log.Printf("[WARN] Cannot execute template for response header %s. %s", name, err)
+			continue
+		}
+		hdr.Set(name, b.String())
+	}
+}
@@ -1,6 +1,8 @@
 package proxy
 
 import (
+	"context"
+	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -11,13 +13,39 @@ import (
 )
 
 func newHTTPProxy(t *url.URL, tr http.RoundTripper, flush time.Duration) http.Handler {
-	rp := httputil.NewSingleHostReverseProxy(t)
+	rp := httputil.NewSingleHostReverseProxy(upstreamURL(t))
 	rp.Transport = tr
 	rp.FlushInterval = flush
 	rp.Transport = &meteredRoundTripper{tr}
+	rp.ErrorHandler = proxyError
 	return rp
 }
 
+// upstreamURL returns the URL for the requests to the target. The
+// path of a unix:// target is the path of the socket and the request
+// path is forwarded unchanged to the socket by the transport.
+func upstreamURL(t *url.URL) *url.URL {
+	if t.Scheme != "unix" {
+		return t
+	}
+	return &url.URL{Scheme: "http", Host: "unix", RawQuery: t.RawQuery}
+}
+
+// proxyError responds with '504 Gateway Timeout' if the deadline
+// of the request has been exceeded and with '502 Bad Gateway'
+// otherwise. The body is the custom error page for the status
+// code if there is one.
+func proxyError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("[ERROR] http: proxy error: %v", err)
+	code := http.StatusBadGateway
+	if r.Context().Err() == context.DeadlineExceeded {
+		code = http.StatusGatewayTimeout
+	}
+	if !writeErrorPage(w, code) {
+		w.WriteHeader(code)
+	}
+}
+
 type meteredRoundTripper struct {
 	tr http.RoundTripper
 }
@@ -25,6 +53,7 @@ type meteredRoundTripper struct {
 func (m *meteredRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
 	start := time.Now()
 	resp, err := m.tr.RoundTrip(r)
+	metrics.DefaultRegistry.GetTimer("requests.upstream").UpdateSince(start)
 	if resp != nil {
 		metrics.DefaultRegistry.GetTimer(name(resp.StatusCode)).UpdateSince(start)
 	}
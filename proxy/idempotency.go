@@ -0,0 +1,271 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/eBay/fabio/metrics"
+)
+
+// idempotencyHeader is the request header with the key
+// which identifies retries of the same request.
+const idempotencyHeader = "Idempotency-Key"
+
+// maxIdempotentBody is the maximum size of a request or response body
+// which is stored for replay. Larger responses are passed through but
+// not stored and requests with larger bodies are not deduplicated.
+const maxIdempotentBody = 1 << 20
+
+// idempotencyScopeHeaders are the request headers which identify the
+// client. Requests with the same key from different clients do not
+// share the stored response.
+var idempotencyScopeHeaders = []string{"Authorization", "Cookie"}
+
+var (
+	errIdempotencyInProgress = errors.New("request with the same idempotency key in progress")
+	errIdempotencyMismatch   = errors.New("idempotency key reused for a different request")
+)
+
+// idempotentResponse is a stored response or a reservation for
+// a request which is still in progress if done is false. fingerprint
+// identifies the method, URI and body of the request.
+type idempotentResponse struct {
+	key         string
+	fingerprint string
+	expires     time.Time
+	done        bool
+	code        int
+	header      http.Header
+	body        []byte
+}
+
+// idempotencyStore stores a bounded number of responses for
+// idempotency keys. The least recently added entries are
+// evicted first and entries expire after the ttl.
+type idempotencyStore struct {
+	size int
+	ttl  time.Duration
+
+	mu sync.Mutex
+	m  map[string]*list.Element
+	l  *list.List
+}
+
+func newIdempotencyStore(size int, ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{size: size, ttl: ttl, m: map[string]*list.Element{}, l: list.New()}
+}
+
+// reserve returns the stored response for the key. If there is none a
+// reservation for the key is created and nil is returned. It returns
+// errIdempotencyMismatch if the key has been used for a request with a
+// different fingerprint and errIdempotencyInProgress if another request
+// with the same key is still in progress.
+func (s *idempotencyStore) reserve(key, fingerprint string, now time.Time) (*idempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e := s.m[key]; e != nil {
+		resp := e.Value.(*idempotentResponse)
+		if now.Before(resp.expires) {
+			switch {
+			case resp.fingerprint != fingerprint:
+				return nil, errIdempotencyMismatch
+			case !resp.done:
+				return nil, errIdempotencyInProgress
+			}
+			return resp, nil
+		}
+		s.remove(e)
+	}
+
+	s.m[key] = s.l.PushFront(&idempotentResponse{key: key, fingerprint: fingerprint, expires: now.Add(s.ttl)})
+	for s.l.Len() > s.size {
+		s.remove(s.l.Back())
+	}
+	return nil, nil
+}
+
+// store completes the reservation for the key with the response.
+func (s *idempotencyStore) store(key string, code int, header http.Header, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e := s.m[key]; e != nil {
+		resp := e.Value.(*idempotentResponse)
+		resp.done, resp.code, resp.header, resp.body = true, code, header, body
+	}
+}
+
+// release removes the reservation for the key so that
+// the request can be retried.
+func (s *idempotencyStore) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e := s.m[key]; e != nil && !e.Value.(*idempotentResponse).done {
+		s.remove(e)
+	}
+}
+
+func (s *idempotencyStore) remove(e *list.Element) {
+	s.l.Remove(e)
+	delete(s.m, e.Value.(*idempotentResponse).key)
+}
+
+// newIdempotencyHandler returns a handler which serves the stored
+// response for requests with an Idempotency-Key header which has
+// already been seen for the route and the client. The clients are
+// distinguished by the idempotencyScopeHeaders. A key which is reused
+// for a request with a different method, URI or body is rejected with
+// 422 Unprocessable Entity and concurrent requests with the same key
+// with 409 Conflict. Responses with a 5xx status are not stored so that
+// the request can be retried. Set-Cookie headers are never replayed.
+func newIdempotencyHandler(h http.Handler, s *idempotencyStore, route string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		fingerprint, ok := requestFingerprint(r)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		key = route + " " + clientScope(r) + " " + key
+
+		resp, err := s.reserve(key, fingerprint, time.Now())
+		switch err {
+		case errIdempotencyMismatch:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		case errIdempotencyInProgress:
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if resp != nil {
+			for k, v := range resp.header {
+				w.Header()[k] = v
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(resp.code)
+			w.Write(resp.body)
+			metrics.DefaultRegistry.GetCounter("idempotency.replayed").Inc(1)
+			return
+		}
+
+		rec := &idempotencyRecorder{w: w}
+		defer s.release(key)
+		h.ServeHTTP(rec, r)
+		if rec.code == 0 || rec.code >= 500 || rec.skip {
+			return
+		}
+		rec.header.Del("Set-Cookie")
+		s.store(key, rec.code, rec.header, rec.body.Bytes())
+	})
+}
+
+// clientScope returns a hash of the idempotencyScopeHeaders of the
+// request which identifies the client without storing its credentials.
+func clientScope(r *http.Request) string {
+	h := sha256.New()
+	for _, name := range idempotencyScopeHeaders {
+		for _, v := range r.Header[name] {
+			io.WriteString(h, name+": "+v+"\n")
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// requestFingerprint returns a hash of the method, URI and body of the
+// request. The body is read into memory and replaced so that it can
+// still be sent upstream. ok is false if the body is larger than
+// maxIdempotentBody or cannot be read.
+func requestFingerprint(r *http.Request) (fingerprint string, ok bool) {
+	var body []byte
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+		if err != nil || len(b) > maxIdempotentBody {
+			// send the part which has been read and the rest upstream
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+			return "", false
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(b))
+		body = b
+	}
+
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// idempotencyRecorder writes the response to the underlying writer
+// and records a copy. skip is set if the response cannot be replayed.
+type idempotencyRecorder struct {
+	w      http.ResponseWriter
+	code   int
+	header http.Header
+	body   bytes.Buffer
+	skip   bool
+}
+
+func (rec *idempotencyRecorder) Header() http.Header {
+	return rec.w.Header()
+}
+
+func (rec *idempotencyRecorder) WriteHeader(code int) {
+	if isInformational(code) {
+		rec.w.WriteHeader(code)
+		return
+	}
+	if rec.code == 0 {
+		rec.code = code
+		rec.header = rec.w.Header().Clone()
+	}
+	rec.w.WriteHeader(code)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.code == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	if !rec.skip {
+		if rec.body.Len()+len(b) > maxIdempotentBody {
+			rec.skip = true
+			rec.body = bytes.Buffer{}
+		} else {
+			rec.body.Write(b)
+		}
+	}
+	return rec.w.Write(b)
+}
+
+func (rec *idempotencyRecorder) Flush() {
+	if f, ok := rec.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rec *idempotencyRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := rec.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("not a hijacker")
+	}
+	rec.skip = true
+	return hj.Hijack()
+}
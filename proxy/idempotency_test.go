@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	now := time.Now()
+	s := newIdempotencyStore(2, time.Minute)
+
+	// new key is reserved
+	if resp, err := s.reserve("a", "fp", now); resp != nil || err != nil {
+		t.Fatalf("got %v, %v want nil, nil", resp, err)
+	}
+
+	// reserved key is in progress
+	if resp, err := s.reserve("a", "fp", now); resp != nil || err != errIdempotencyInProgress {
+		t.Fatalf("got %v, %v want nil, %v", resp, err, errIdempotencyInProgress)
+	}
+
+	// stored key returns the response
+	s.store("a", 201, http.Header{}, []byte("foo"))
+	if resp, err := s.reserve("a", "fp", now); resp == nil || err != nil || resp.code != 201 || string(resp.body) != "foo" {
+		t.Fatalf("got %v, %v want stored response", resp, err)
+	}
+
+	// stored key with a different fingerprint is rejected
+	if resp, err := s.reserve("a", "other", now); resp != nil || err != errIdempotencyMismatch {
+		t.Fatalf("got %v, %v want nil, %v", resp, err, errIdempotencyMismatch)
+	}
+
+	// stored key is not released
+	s.release("a")
+	if resp, _ := s.reserve("a", "fp", now); resp == nil {
+		t.Fatal("got nil want stored response after release")
+	}
+
+	// released reservation can be reserved again
+	s.reserve("b", "fp", now)
+	s.release("b")
+	if resp, err := s.reserve("b", "fp", now); resp != nil || err != nil {
+		t.Fatalf("got %v, %v want nil, nil", resp, err)
+	}
+
+	// oldest key is evicted
+	s.reserve("c", "fp", now)
+	if _, ok := s.m["a"]; ok {
+		t.Fatal("got a want evicted")
+	}
+
+	// expired key is reserved again
+	s.store("c", 200, http.Header{}, nil)
+	if resp, err := s.reserve("c", "fp", now.Add(time.Minute)); resp != nil || err != nil {
+		t.Fatalf("got %v, %v want nil, nil", resp, err)
+	}
+}
+
+func TestIdempotencyHandler(t *testing.T) {
+	var calls int
+	code := 200
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-Call", strconv.Itoa(calls))
+		w.Header().Set("Set-Cookie", "session="+strconv.Itoa(calls))
+		w.WriteHeader(code)
+		w.Write([]byte("call " + strconv.Itoa(calls)))
+	})
+	s := newIdempotencyStore(10, time.Minute)
+
+	req := func(route, key, auth, body string) *httptest.ResponseRecorder {
+		if body == "" {
+			body = "x"
+		}
+		r := httptest.NewRequest("POST", "/pay", strings.NewReader(body))
+		if key != "" {
+			r.Header.Set(idempotencyHeader, key)
+		}
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		newIdempotencyHandler(h, s, route).ServeHTTP(rec, r)
+		return rec
+	}
+
+	tests := []struct {
+		desc        string
+		route, key  string
+		auth, req   string
+		code        int
+		body        string
+		replayed    bool
+		upstreamErr bool
+	}{
+		{desc: "no key", route: "/pay", body: "call 1"},
+		{desc: "no key again", route: "/pay", body: "call 2"},
+		{desc: "first request", route: "/pay", key: "k1", body: "call 3"},
+		{desc: "retry", route: "/pay", key: "k1", body: "call 3", replayed: true},
+		{desc: "other route", route: "/other", key: "k1", body: "call 4"},
+		{desc: "upstream error", route: "/pay", key: "k2", body: "call 5", upstreamErr: true},
+		{desc: "retry after error", route: "/pay", key: "k2", body: "call 6"},
+		{desc: "other client", route: "/pay", key: "k1", auth: "Bearer other", body: "call 7"},
+		{desc: "retry other client", route: "/pay", key: "k1", auth: "Bearer other", body: "call 7", replayed: true},
+		{desc: "different body", route: "/pay", key: "k1", req: "y", code: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		code = 200
+		if tt.upstreamErr {
+			code = 502
+		}
+		rec := req(tt.route, tt.key, tt.auth, tt.req)
+		if tt.code != 0 {
+			if got, want := rec.Code, tt.code; got != want {
+				t.Errorf("%s: got code %d want %d", tt.desc, got, want)
+			}
+			continue
+		}
+		if got, want := rec.Body.String(), tt.body; got != want {
+			t.Errorf("%s: got body %q want %q", tt.desc, got, want)
+		}
+		if got, want := rec.Header().Get("Idempotent-Replayed") == "true", tt.replayed; got != want {
+			t.Errorf("%s: got replayed %v want %v", tt.desc, got, want)
+		}
+		if got, want := rec.Header().Get("X-Call"), strings.TrimPrefix(tt.body, "call "); got != want {
+			t.Errorf("%s: got header %q want %q", tt.desc, got, want)
+		}
+		if got, want := rec.Header().Get("Set-Cookie") != "", !tt.replayed; got != want {
+			t.Errorf("%s: got Set-Cookie %v want %v", tt.desc, got, want)
+		}
+	}
+
+	// concurrent request with the same key
+	r := httptest.NewRequest("POST", "/pay", strings.NewReader("x"))
+	fp, _ := requestFingerprint(r)
+	s.reserve("/pay "+clientScope(r)+" k3", fp, time.Now())
+	if got, want := req("/pay", "k3", "", "").Code, http.StatusConflict; got != want {
+		t.Errorf("got code %d want %d", got, want)
+	}
+}
+
+func TestRequestFingerprintLargeBody(t *testing.T) {
+	body := strings.Repeat("x", maxIdempotentBody+10)
+	r := httptest.NewRequest("POST", "/upload", strings.NewReader(body))
+	if _, ok := requestFingerprint(r); ok {
+		t.Fatal("got fingerprint want none for large body")
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != len(body) {
+		t.Fatalf("got body of %d bytes want %d", len(b), len(body))
+	}
+}
@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"net"
+	"time"
+)
+
+// handshakeConn is implemented by the client connections of TCP+SNI
+// listeners with handshake or idle timeouts. The handshake deadline
+// stays in place until HandshakeDone is called.
+type handshakeConn interface {
+	HandshakeDone()
+	IdleTimeout() time.Duration
+}
+
+// idleConn applies the idle timeout of the client connection to the
+// upstream connection. The read deadline is moved after every read
+// and write and writes must complete within the idle timeout.
+type idleConn struct {
+	net.Conn
+	idle time.Duration
+}
+
+// newIdleConn wraps c with an idleConn if the idle timeout is set.
+func newIdleConn(c net.Conn, idle time.Duration) net.Conn {
+	if idle <= 0 {
+		return c
+	}
+	c.SetReadDeadline(time.Now().Add(idle))
+	return &idleConn{Conn: c, idle: idle}
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.idle))
+	}
+	return n, err
+}
+
+func (c *idleConn) Write(b []byte) (int, error) {
+	c.Conn.SetWriteDeadline(time.Now().Add(c.idle))
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.idle))
+	}
+	return n, err
+}
@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestIdleConn(t *testing.T) {
+	if c := newIdleConn(nil, 0); c != nil {
+		t.Fatalf("got %T want unwrapped conn", c)
+	}
+
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	c := newIdleConn(a, 50*time.Millisecond)
+	go func() {
+		b.Write([]byte("x"))
+		time.Sleep(30 * time.Millisecond)
+		b.Write([]byte("x"))
+	}()
+
+	buf := make([]byte, 1)
+	for i := 0; i < 2; i++ {
+		if _, err := c.Read(buf); err != nil {
+			t.Fatalf("%d: got %v want nil", i, err)
+		}
+	}
+	if _, err := c.Read(buf); err == nil {
+		t.Fatal("got nil want timeout")
+	} else if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Fatalf("got %v want timeout", err)
+	}
+	if _, err := c.Write([]byte("x")); err == nil {
+		t.Fatal("got nil want write timeout since nobody reads")
+	}
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/eBay/fabio/jwt"
+)
+
+// jwtAuth validates the bearer token of the request with the issuer
+// with the given name and writes the error response if the token is
+// not valid. It returns the status code of the error response or zero
+// if the request is authorized. The configured claim headers are
+// removed from all requests and set from the claims of valid tokens
+// so that clients cannot forge them. Requests for unknown issuers are
+// rejected.
+func jwtAuth(w http.ResponseWriter, r *http.Request, name string) int {
+	iss := jwt.Issuers[name]
+	if iss == nil {
+		log.Printf("[WARN] Unknown JWT issuer %s for %s%s", name, r.Host, r.URL)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+	for _, h := range iss.HeaderNames() {
+		r.Header.Del(h)
+	}
+
+	token := r.Header.Get("Authorization")
+	if len(token) < 7 || !strings.EqualFold(token[:7], "Bearer ") {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return http.StatusUnauthorized
+	}
+
+	claims, err := iss.Verify(strings.TrimSpace(token[7:]))
+	switch err {
+	case nil:
+	case jwt.ErrInsufficientClaims:
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return http.StatusForbidden
+	default:
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return http.StatusUnauthorized
+	}
+
+	for h, v := range iss.Headers(claims) {
+		r.Header.Set(h, v)
+	}
+	return 0
+}
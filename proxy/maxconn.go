@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/eBay/fabio/metrics"
+	"github.com/eBay/fabio/route"
+)
+
+// connLimits contains the slots for the concurrent requests of the
+// targets with the 'maxconn' option by target URL. They are kept
+// across routing table updates since the targets are recreated.
+var connLimits = struct {
+	sync.Mutex
+	m map[string]chan struct{}
+}{m: map[string]chan struct{}{}}
+
+// connSlots returns the slots for the target. They are replaced
+// when the limit changes. Requests in flight release the slot of
+// the old limit.
+func connSlots(t *route.Target) chan struct{} {
+	key := t.URL.String()
+	connLimits.Lock()
+	defer connLimits.Unlock()
+	slots := connLimits.m[key]
+	if cap(slots) != t.MaxConn {
+		slots = make(chan struct{}, t.MaxConn)
+		connLimits.m[key] = slots
+	}
+	return slots
+}
+
+// acquireConn reserves a slot for a request to a target with the
+// 'maxconn' option. If all slots are taken it waits up to the
+// 'maxconn-wait' duration or until the request is canceled. ok is
+// false if no slot is available and release must be called when the
+// request has completed.
+func acquireConn(r *http.Request, t *route.Target) (release func(), ok bool) {
+	if t.MaxConn <= 0 {
+		return func() {}, true
+	}
+
+	slots := connSlots(t)
+	release = func() { <-slots }
+	select {
+	case slots <- struct{}{}:
+		return release, true
+	default:
+	}
+
+	if t.MaxConnWait > 0 {
+		timer := time.NewTimer(t.MaxConnWait)
+		defer timer.Stop()
+		select {
+		case slots <- struct{}{}:
+			return release, true
+		case <-timer.C:
+		case <-r.Context().Done():
+		}
+	}
+	metrics.DefaultRegistry.GetCounter("maxconn.rejected").Inc(1)
+	return nil, false
+}
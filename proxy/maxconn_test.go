@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/route"
+)
+
+func TestAcquireConn(t *testing.T) {
+	u, _ := url.Parse("http://maxconn-test:1234/")
+	tg := &route.Target{URL: u, MaxConn: 2}
+	r := httptest.NewRequest("GET", "/", nil)
+
+	release1, ok := acquireConn(r, tg)
+	if !ok {
+		t.Fatal("first request rejected")
+	}
+	release2, ok := acquireConn(r, tg)
+	if !ok {
+		t.Fatal("second request rejected")
+	}
+	if _, ok := acquireConn(r, tg); ok {
+		t.Fatal("third request accepted")
+	}
+
+	// waiting requests get the slot of a completed request
+	tg.MaxConnWait = time.Second
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release1()
+	}()
+	release3, ok := acquireConn(r, tg)
+	if !ok {
+		t.Fatal("waiting request rejected")
+	}
+
+	tg.MaxConnWait = 10 * time.Millisecond
+	if _, ok := acquireConn(r, tg); ok {
+		t.Fatal("request accepted after wait")
+	}
+
+	release2()
+	release3()
+	if _, ok := acquireConn(r, &route.Target{URL: u}); !ok {
+		t.Fatal("request without limit rejected")
+	}
+}
+
+func TestProxyMaxConn(t *testing.T) {
+	started, block := make(chan bool), make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		<-block
+	}))
+	defer server.Close()
+
+	tbl, err := route.ParseString("route add svc / " + server.URL + ` opts "maxconn=1"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.SetTable(tbl)
+	defer route.SetTable(make(route.Table))
+
+	proxy := NewHTTPProxy(&http.Transport{}, config.Proxy{})
+	done := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		done <- rec.Code
+	}()
+
+	<-started
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if got, want := rec.Code, http.StatusServiceUnavailable; got != want {
+		t.Fatalf("got %d want %d", got, want)
+	}
+
+	close(block)
+	if got, want := <-done, http.StatusOK; got != want {
+		t.Fatalf("got %d want %d", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/eBay/fabio/route"
+)
+
+const headerMethodOverride = "X-HTTP-Method-Override"
+
+// overrideMethods contains the methods which can be requested
+// through the X-HTTP-Method-Override header.
+var overrideMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"PUT":     true,
+	"PATCH":   true,
+	"DELETE":  true,
+	"OPTIONS": true,
+}
+
+// overrideMethod returns the method from the X-HTTP-Method-Override
+// header for POST requests to targets with the 'methodoverride' option.
+// For other requests or invalid values it returns the request method.
+//
+// With 'methodoverride=rewrite' the method of the upstream request is
+// changed to the override method and the header is removed.
+func overrideMethod(r *http.Request, t *route.Target) string {
+	if !honorsOverride(t) || r.Method != "POST" {
+		return r.Method
+	}
+
+	m := strings.ToUpper(strings.TrimSpace(r.Header.Get(headerMethodOverride)))
+	if !overrideMethods[m] {
+		return r.Method
+	}
+
+	if t.Opts["methodoverride"] == "rewrite" {
+		r.Method = m
+		r.Header.Del(headerMethodOverride)
+	}
+	return m
+}
+
+// lookupTarget returns the target for the request and the effective
+// method. POST requests with a valid X-HTTP-Method-Override header are
+// matched with the override method, e.g. for the 'methods' option, if
+// a target of the matching route has the 'methodoverride' option.
+// Otherwise the request is matched with its own method. The target is
+// picked only once so that the override does not skew the picker.
+func lookupTarget(r *http.Request) (*route.Target, string) {
+	if m := strings.ToUpper(strings.TrimSpace(r.Header.Get(headerMethodOverride))); r.Method == "POST" && overrideMethods[m] {
+		or := *r
+		or.Method = m
+		if rt := route.GetTable().LookupRoute(&or); rt != nil && routeHonorsOverride(rt) {
+			if t := rt.Lookup(&or); t != nil {
+				return t, overrideMethod(r, t)
+			}
+		}
+	}
+
+	t := target(r)
+	if t == nil {
+		return nil, r.Method
+	}
+	return t, overrideMethod(r, t)
+}
+
+// routeHonorsOverride returns true if a target of the route has the
+// 'methodoverride' option.
+func routeHonorsOverride(rt *route.Route) bool {
+	for _, t := range rt.Targets {
+		if honorsOverride(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// honorsOverride returns true if the target has the 'methodoverride' option.
+func honorsOverride(t *route.Target) bool {
+	v, ok := t.Opts["methodoverride"]
+	return ok && v != "false"
+}
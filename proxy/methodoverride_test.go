@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eBay/fabio/route"
+)
+
+func TestOverrideMethod(t *testing.T) {
+	tests := []struct {
+		desc         string
+		opts         string
+		method       string
+		header       string
+		want         string
+		upstream     string
+		headerRemain bool
+	}{
+		{"no option", "", "POST", "PUT", "POST", "POST", true},
+		{"option disabled", "methodoverride=false", "POST", "PUT", "POST", "POST", true},
+		{"no header", "methodoverride", "POST", "", "POST", "POST", false},
+		{"honor", "methodoverride", "POST", "put", "PUT", "POST", true},
+		{"honor only post", "methodoverride", "GET", "DELETE", "GET", "GET", true},
+		{"invalid method", "methodoverride", "POST", "CONNECT", "POST", "POST", true},
+		{"rewrite", "methodoverride=rewrite", "POST", "DELETE", "DELETE", "DELETE", false},
+	}
+
+	for _, tt := range tests {
+		r := &http.Request{Method: tt.method, Header: http.Header{}}
+		if tt.header != "" {
+			r.Header.Set(headerMethodOverride, tt.header)
+		}
+		tg := &route.Target{Opts: route.ParseOpts(tt.opts)}
+
+		if got, want := overrideMethod(r, tg), tt.want; got != want {
+			t.Errorf("%s: got method %q want %q", tt.desc, got, want)
+		}
+		if got, want := r.Method, tt.upstream; got != want {
+			t.Errorf("%s: got upstream method %q want %q", tt.desc, got, want)
+		}
+		if got, want := r.Header.Get(headerMethodOverride) != "", tt.headerRemain; got != want {
+			t.Errorf("%s: got header %v want %v", tt.desc, got, want)
+		}
+	}
+}
+
+func TestLookupTargetMethodOverride(t *testing.T) {
+	tbl, err := route.ParseString(`
+route add del /x http://1.1.1.1:1/ opts "methods=DELETE methodoverride"
+route add any /x http://2.2.2.2:2/
+route add del /y http://3.3.3.3:3/ opts "methods=DELETE"
+route add any /y http://4.4.4.4:4/
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.SetTable(tbl)
+	defer route.SetTable(make(route.Table))
+
+	tests := []struct {
+		desc           string
+		method, header string
+		path           string
+		host, want     string
+	}{
+		{"override matches methods", "POST", "DELETE", "/x", "1.1.1.1:1", "DELETE"},
+		{"no override", "POST", "", "/x", "2.2.2.2:2", "POST"},
+		{"override only for POST", "PUT", "DELETE", "/x", "2.2.2.2:2", "PUT"},
+		{"target without methodoverride", "POST", "DELETE", "/y", "4.4.4.4:4", "POST"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.header != "" {
+			r.Header.Set(headerMethodOverride, tt.header)
+		}
+		tg, method := lookupTarget(r)
+		if tg == nil {
+			t.Fatalf("%s: got no target", tt.desc)
+		}
+		if got, want := tg.URL.Host, tt.host; got != want {
+			t.Errorf("%s: got target %q want %q", tt.desc, got, want)
+		}
+		if got, want := method, tt.want; got != want {
+			t.Errorf("%s: got method %q want %q", tt.desc, got, want)
+		}
+	}
+}
+
+func TestLookupTargetMethodOverridePicksOnce(t *testing.T) {
+	if err := route.SetPickerStrategy("rr"); err != nil {
+		t.Fatal(err)
+	}
+	defer route.SetPickerStrategy("rnd")
+
+	tbl, err := route.ParseString(`
+route add a /x http://1.1.1.1:1/ opts "methodoverride"
+route add b /x http://2.2.2.2:2/ opts "methodoverride"
+route add c /y http://3.3.3.3:3/
+route add d /y http://4.4.4.4:4/
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.SetTable(tbl)
+	defer route.SetTable(make(route.Table))
+
+	for _, path := range []string{"/x", "/y"} {
+		var hosts []string
+		for i := 0; i < 4; i++ {
+			r := httptest.NewRequest("POST", path, nil)
+			r.Header.Set(headerMethodOverride, "DELETE")
+			tg, _ := lookupTarget(r)
+			hosts = append(hosts, tg.URL.Host)
+		}
+		if hosts[0] == hosts[1] || hosts[0] != hosts[2] || hosts[1] != hosts[3] {
+			t.Errorf("%s: got targets %v want alternating targets", path, hosts)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/eBay/fabio/route"
+)
+
+// Middleware checks or modifies a request for the target before it
+// is sent to the upstream server. It returns zero to continue with the
+// next middleware or the status code of the response it has written
+// to stop the request.
+type Middleware func(w http.ResponseWriter, r *http.Request, t *route.Target) int
+
+// DefaultMiddleware is the order of the built-in middleware which is
+// used if proxy.middleware is empty.
+var DefaultMiddleware = []string{"access", "clientcert", "cors", "auth", "jwt", "oidc", "extauthz", "fault"}
+
+// securityMiddleware is the built-in middleware which enforces the access
+// control route options. It must be part of every chain since leaving it
+// out would silently disable the options for the routes which set them.
+var securityMiddleware = []string{"access", "clientcert", "auth", "jwt", "oidc", "extauthz"}
+
+var (
+	middlewareMu sync.RWMutex
+	middlewares  = map[string]Middleware{}
+)
+
+func init() {
+	RegisterMiddleware("access", accessMiddleware)
+	RegisterMiddleware("clientcert", clientCertMiddleware)
+	RegisterMiddleware("cors", corsMiddleware)
+	RegisterMiddleware("auth", optMiddleware("auth", basicAuth))
+	RegisterMiddleware("jwt", optMiddleware("jwt", jwtAuth))
+	RegisterMiddleware("oidc", optMiddleware("oidc", oidcAuth))
+	RegisterMiddleware("extauthz", optMiddleware("extauthz", extAuthz))
+	RegisterMiddleware("fault", faultMiddleware)
+}
+
+// RegisterMiddleware makes a middleware available under the given name
+// for the proxy.middleware option so that custom builds can add their
+// own request handling without changing the proxy. Middleware usually
+// registers itself in the init function of its package. RegisterMiddleware
+// panics if the name is empty, the middleware is nil or a middleware
+// with the same name has already been registered.
+func RegisterMiddleware(name string, m Middleware) {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+
+	if name == "" {
+		panic("proxy: middleware name is empty")
+	}
+	if m == nil {
+		panic("proxy: middleware " + name + " is nil")
+	}
+	if _, dup := middlewares[name]; dup {
+		panic("proxy: middleware " + name + " registered twice")
+	}
+	middlewares[name] = m
+}
+
+// MiddlewareChain returns the registered middleware with the given
+// names in order. An empty list returns the DefaultMiddleware. It
+// returns an error if a name is unknown or the list does not contain
+// all of the built-in security middleware.
+func MiddlewareChain(names []string) ([]Middleware, error) {
+	if len(names) == 0 {
+		names = DefaultMiddleware
+	}
+
+	listed := map[string]bool{}
+	for _, name := range names {
+		listed[name] = true
+	}
+	var missing []string
+	for _, name := range securityMiddleware {
+		if !listed[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("proxy: middleware %s is missing. It enforces route options and cannot be disabled", strings.Join(missing, ", "))
+	}
+
+	middlewareMu.RLock()
+	defer middlewareMu.RUnlock()
+
+	var chain []Middleware
+	for _, name := range names {
+		m := middlewares[name]
+		if m == nil {
+			var known []string
+			for k := range middlewares {
+				known = append(known, k)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("proxy: unknown middleware %q. Registered middleware: %s", name, strings.Join(known, ", "))
+		}
+		chain = append(chain, m)
+	}
+	return chain, nil
+}
+
+// mustMiddlewareChain returns the middleware chain for the names and
+// panics if the names are invalid. Callers should check the names with
+// MiddlewareChain first.
+func mustMiddlewareChain(names []string) []Middleware {
+	chain, err := MiddlewareChain(names)
+	if err != nil {
+		panic(err)
+	}
+	return chain
+}
+
+// accessMiddleware rejects the clients which are not allowed by
+// the 'allow' and 'deny' options of the target.
+func accessMiddleware(w http.ResponseWriter, r *http.Request, t *route.Target) int {
+	if !t.AccessDenied(r.RemoteAddr) {
+		return 0
+	}
+	log.Printf("[INFO] Access denied for %s to %s%s", r.RemoteAddr, r.Host, r.URL)
+	http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	return http.StatusForbidden
+}
+
+// clientCertMiddleware rejects the clients whose certificate does not
+// match the 'clientcert-*' options of the target.
+func clientCertMiddleware(w http.ResponseWriter, r *http.Request, t *route.Target) int {
+	if !t.ClientCertDenied(r.TLS) {
+		return 0
+	}
+	log.Printf("[INFO] Client certificate denied for %s to %s%s", r.RemoteAddr, r.Host, r.URL)
+	http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	return http.StatusForbidden
+}
+
+// corsMiddleware answers the CORS preflight requests for targets
+// with the 'cors-*' options.
+func corsMiddleware(w http.ResponseWriter, r *http.Request, t *route.Target) int {
+	if t.CORS == nil || !isPreflight(r) {
+		return 0
+	}
+	return preflight(w, r, t.CORS)
+}
+
+// optMiddleware returns a middleware which calls fn with the value
+// of the route option for targets which have the option. An option
+// with an empty value is not skipped but rejected by fn like any
+// other unknown name.
+func optMiddleware(opt string, fn func(w http.ResponseWriter, r *http.Request, name string) int) Middleware {
+	return func(w http.ResponseWriter, r *http.Request, t *route.Target) int {
+		name, ok := t.Opts[opt]
+		if !ok {
+			return 0
+		}
+		return fn(w, r, name)
+	}
+}
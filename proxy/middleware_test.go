@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/route"
+)
+
+func TestMiddlewareChain(t *testing.T) {
+	var calls []string
+	RegisterMiddleware("test-header", func(w http.ResponseWriter, r *http.Request, t *route.Target) int {
+		calls = append(calls, "header")
+		r.Header.Set("X-Middleware", t.Service)
+		return 0
+	})
+	RegisterMiddleware("test-reject", func(w http.ResponseWriter, r *http.Request, t *route.Target) int {
+		calls = append(calls, "reject")
+		if r.URL.Path != "/reject" {
+			return 0
+		}
+		http.Error(w, "rejected", http.StatusTeapot)
+		return http.StatusTeapot
+	})
+	defer func() {
+		delete(middlewares, "test-header")
+		delete(middlewares, "test-reject")
+	}()
+
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Middleware")
+	}))
+	defer server.Close()
+
+	table, err := route.ParseString("route add svc / " + server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.SetTable(table)
+	defer route.SetTable(make(route.Table))
+
+	tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+	proxy := NewHTTPProxy(tr, config.Proxy{Middleware: []string{"test-reject", "access", "clientcert", "auth", "jwt", "oidc", "extauthz", "test-header"}})
+
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest("GET", "/reject", nil))
+	if got, want := rec.Code, http.StatusTeapot; got != want {
+		t.Fatalf("got code %d want %d", got, want)
+	}
+	if got, want := strings.Join(calls, ","), "reject"; got != want {
+		t.Fatalf("got calls %q want %q", got, want)
+	}
+
+	calls = nil
+	rec = httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if got, want := rec.Code, 200; got != want {
+		t.Fatalf("got code %d want %d", got, want)
+	}
+	if got, want := strings.Join(calls, ","), "reject,header"; got != want {
+		t.Fatalf("got calls %q want %q", got, want)
+	}
+	if want := "svc"; got != want {
+		t.Fatalf("got header %q want %q", got, want)
+	}
+
+	if _, err := MiddlewareChain(append([]string{"foo"}, securityMiddleware...)); err == nil || !strings.Contains(err.Error(), `"foo"`) {
+		t.Fatalf("got %v want unknown middleware error", err)
+	}
+	if _, err := MiddlewareChain([]string{"access", "jwt"}); err == nil || !strings.Contains(err.Error(), "clientcert, auth, oidc, extauthz") {
+		t.Fatalf("got %v want missing middleware error", err)
+	}
+	if chain, err := MiddlewareChain(nil); err != nil || len(chain) != len(DefaultMiddleware) {
+		t.Fatalf("got %d, %v want default chain", len(chain), err)
+	}
+}
+
+// durationTimer records the duration of the last event.
+type durationTimer struct{ d time.Duration }
+
+func (t *durationTimer) Percentile(float64) float64  { return 0 }
+func (t *durationTimer) Rate1() float64              { return 0 }
+func (t *durationTimer) UpdateSince(start time.Time) { t.d = time.Since(start) }
+
+func TestMiddlewareNotInRoutingTime(t *testing.T) {
+	RegisterMiddleware("test-slow", func(w http.ResponseWriter, r *http.Request, t *route.Target) int {
+		time.Sleep(100 * time.Millisecond)
+		return 0
+	})
+	defer delete(middlewares, "test-slow")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	table, err := route.ParseString("route add svc / " + server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.SetTable(table)
+	defer route.SetTable(make(route.Table))
+
+	tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+	proxy := NewHTTPProxy(tr, config.Proxy{Middleware: append([]string{"test-slow"}, securityMiddleware...)}).(*httpProxy)
+	routing := &durationTimer{}
+	proxy.routing = routing
+
+	proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if routing.d >= 100*time.Millisecond {
+		t.Fatalf("got routing time %s want less than the middleware time", routing.d)
+	}
+}
+
+func TestRegisterMiddlewarePanics(t *testing.T) {
+	m := func(http.ResponseWriter, *http.Request, *route.Target) int { return 0 }
+	tests := []struct {
+		desc string
+		name string
+		m    Middleware
+	}{
+		{"empty name", "", m},
+		{"nil middleware", "test-nil", nil},
+		{"duplicate", "access", m},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("got no panic")
+				}
+			}()
+			RegisterMiddleware(tt.name, tt.m)
+		})
+	}
+}
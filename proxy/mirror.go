@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/eBay/fabio/metrics"
+)
+
+const (
+	// maxMirrorBody is the maximum size of a request body which is
+	// mirrored. Requests with larger bodies are not mirrored.
+	maxMirrorBody = 1 << 20 // 1MB
+
+	// mirrorTimeout is the timeout for a mirrored request.
+	mirrorTimeout = 30 * time.Second
+)
+
+// mirrorSem limits the number of concurrent mirrored requests so that
+// a slow mirror cannot exhaust the resources of the proxy. Requests
+// are not mirrored when the limit is reached.
+var mirrorSem = make(chan struct{}, 100)
+
+// newMirrorRoundTripper returns a round tripper which sends a copy
+// of every request to the mirror in the background via mtr. The
+// response of the mirror is discarded.
+func newMirrorRoundTripper(tr, mtr http.RoundTripper, mirror *url.URL) http.RoundTripper {
+	return &mirrorRoundTripper{tr: tr, mtr: mtr, mirror: mirror}
+}
+
+type mirrorRoundTripper struct {
+	tr, mtr http.RoundTripper
+	mirror  *url.URL
+}
+
+func (m *mirrorRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, ok, err := m.readBody(r)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		metrics.DefaultRegistry.GetCounter("mirror.skipped").Inc(1)
+		return m.tr.RoundTrip(r)
+	}
+
+	select {
+	case mirrorSem <- struct{}{}:
+		go m.send(m.mirrorRequest(r, body))
+	default:
+		metrics.DefaultRegistry.GetCounter("mirror.dropped").Inc(1)
+	}
+	return m.tr.RoundTrip(r)
+}
+
+// readBody reads the request body if it is not larger than maxMirrorBody
+// and replaces it with a copy. ok is false if the body is too large. In
+// that case the request body is restored without being copied.
+func (m *mirrorRoundTripper) readBody(r *http.Request) (body []byte, ok bool, err error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true, nil
+	}
+	if r.ContentLength > maxMirrorBody {
+		return nil, false, nil
+	}
+	body, err = ioutil.ReadAll(io.LimitReader(r.Body, maxMirrorBody+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(body) > maxMirrorBody {
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil, false, nil
+	}
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, true, nil
+}
+
+// mirrorRequest returns the copy of the request for the mirror. It
+// is not bound to the context of the original request so that the
+// mirrored request is not cancelled when the original completes.
+func (m *mirrorRoundTripper) mirrorRequest(r *http.Request, body []byte) (*http.Request, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+	out := r.Clone(ctx)
+	out.URL.Scheme, out.URL.Host = m.mirror.Scheme, m.mirror.Host
+	out.Host = m.mirror.Host
+	out.Body, out.ContentLength = http.NoBody, 0
+	if body != nil {
+		out.Body, out.ContentLength = ioutil.NopCloser(bytes.NewReader(body)), int64(len(body))
+	}
+	return out, cancel
+}
+
+// send sends the copy of the request to the mirror.
+func (m *mirrorRoundTripper) send(out *http.Request, cancel context.CancelFunc) {
+	defer func() { <-mirrorSem }()
+	defer cancel()
+
+	resp, err := m.mtr.RoundTrip(out)
+	if err != nil {
+		log.Printf("[WARN] Mirroring %s to %s failed. %s", out.URL.Path, m.mirror, err)
+		metrics.DefaultRegistry.GetCounter("mirror.failed").Inc(1)
+		return
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	metrics.DefaultRegistry.GetCounter("mirror.sent").Inc(1)
+}
+
+// readCloser combines a reader with the closer of the original body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/route"
+)
+
+func TestProxyMirror(t *testing.T) {
+	type mirrored struct{ method, path, body string }
+	ch := make(chan mirrored, 1)
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		ch <- mirrored{r.Method, r.URL.Path, string(b)}
+		w.WriteHeader(500)
+	}))
+	defer mirror.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte("primary:" + string(b)))
+	}))
+	defer server.Close()
+
+	table := make(route.Table)
+	table.AddRouteOpts("mock", "/", server.URL, 1, nil, route.ParseOpts("mirror="+mirror.URL))
+	route.SetTable(table)
+
+	tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+	proxy := NewHTTPProxy(tr, config.Proxy{})
+
+	tests := []struct {
+		method, body string
+	}{
+		{"GET", ""},
+		{"POST", "data"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/foo", strings.NewReader(tt.body))
+		if tt.body == "" {
+			req.Body = http.NoBody
+		}
+		req.URL = &url.URL{Path: "/foo"}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+
+		if got, want := rec.Code, 200; got != want {
+			t.Fatalf("%s: got status %d want %d", tt.method, got, want)
+		}
+		if got, want := rec.Body.String(), "primary:"+tt.body; got != want {
+			t.Fatalf("%s: got body %q want %q", tt.method, got, want)
+		}
+
+		select {
+		case m := <-ch:
+			if want := (mirrored{tt.method, "/foo", tt.body}); m != want {
+				t.Fatalf("%s: got mirrored request %v want %v", tt.method, m, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: request not mirrored", tt.method)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/eBay/fabio/config"
+)
+
+// normalizeRequest changes the host and path of the request before
+// the route lookup according to the proxy.normalize.slashes and
+// proxy.normalize.host options.
+func normalizeRequest(r *http.Request, cfg config.Proxy) {
+	if cfg.NormalizeHost {
+		r.Host = strings.ToLower(r.Host)
+	}
+	if cfg.NormalizeSlashes && strings.Contains(r.URL.Path, "//") {
+		u := *r.URL
+		u.Path, u.RawPath = collapseSlashes(u.Path), collapseSlashes(u.RawPath)
+		r.URL = &u
+		r.RequestURI = u.RequestURI()
+	}
+}
+
+// collapseSlashes replaces sequences of slashes in the path with a
+// single slash.
+func collapseSlashes(p string) string {
+	if !strings.Contains(p, "//") {
+		return p
+	}
+	b := make([]byte, 0, len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] == '/' && i > 0 && p[i-1] == '/' {
+			continue
+		}
+		b = append(b, p[i])
+	}
+	return string(b)
+}
+
+// trailingSlashRedirect returns the location to which the request is
+// redirected to add or remove the trailing slash of the path or an
+// empty string if the path is already in the canonical form. With
+// 'add' paths whose last segment looks like a file name, e.g.
+// '/app.js', are not changed.
+func trailingSlashRedirect(r *http.Request, mode string) string {
+	p := r.URL.EscapedPath()
+	if p == "" || p == "/" {
+		return ""
+	}
+	switch {
+	case mode == "add" && !strings.HasSuffix(p, "/") && !strings.Contains(path.Base(p), "."):
+		p += "/"
+	case mode == "remove" && strings.HasSuffix(p, "/"):
+		if p = strings.TrimRight(p, "/"); p == "" {
+			return ""
+		}
+	default:
+		return ""
+	}
+	if r.URL.RawQuery != "" {
+		p += "?" + r.URL.RawQuery
+	}
+	return p
+}
+
+// redirectStatus returns the status code for a permanent redirect
+// which keeps the method and body of the request.
+func redirectStatus(method string) int {
+	if method == "GET" || method == "HEAD" {
+		return http.StatusMovedPermanently
+	}
+	return http.StatusPermanentRedirect
+}
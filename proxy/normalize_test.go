@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eBay/fabio/config"
+)
+
+func TestNormalizeRequest(t *testing.T) {
+	tests := []struct {
+		desc, uri, host, wantURI string
+		cfg                      config.Proxy
+	}{
+		{"disabled", "/a//b?x=1", "Example.COM", "/a//b?x=1", config.Proxy{}},
+		{"slashes", "/a//b///c/?x=1", "Example.COM", "/a/b/c/?x=1", config.Proxy{NormalizeSlashes: true}},
+		{"escaped slashes", "/a//b%2Fc", "Example.COM", "/a/b%2Fc", config.Proxy{NormalizeSlashes: true}},
+		{"host", "/a//b", "example.com", "/a//b", config.Proxy{NormalizeHost: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.uri, nil)
+			r.Host = "Example.COM"
+			normalizeRequest(r, tt.cfg)
+			if got, want := r.RequestURI, tt.wantURI; got != want {
+				t.Errorf("got request uri %q want %q", got, want)
+			}
+			if got, want := r.URL.RequestURI(), tt.wantURI; got != want {
+				t.Errorf("got url %q want %q", got, want)
+			}
+			if got, want := r.Host, tt.host; got != want {
+				t.Errorf("got host %q want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestTrailingSlashRedirect(t *testing.T) {
+	tests := []struct {
+		mode, uri, want string
+	}{
+		{"", "/a/", ""},
+		{"add", "/", ""},
+		{"add", "/a", "/a/"},
+		{"add", "/a?x=1", "/a/?x=1"},
+		{"add", "/a/", ""},
+		{"add", "/a/app.js", ""},
+		{"remove", "/", ""},
+		{"remove", "/a", ""},
+		{"remove", "/a/", "/a"},
+		{"remove", "/a//?x=1", "/a?x=1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.uri, nil)
+		if got := trailingSlashRedirect(r, tt.mode); got != tt.want {
+			t.Errorf("%s %s: got %q want %q", tt.mode, tt.uri, got, tt.want)
+		}
+	}
+}
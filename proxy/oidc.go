@@ -0,0 +1,23 @@
+package proxy
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/eBay/fabio/oidc"
+)
+
+// oidcAuth authenticates the request with the OpenID Connect provider
+// with the given name and writes the response for the login if the
+// client has no session. It returns the status code of the response
+// or zero if the request is authenticated. Requests for unknown
+// providers are rejected.
+func oidcAuth(w http.ResponseWriter, r *http.Request, name string) int {
+	p := oidc.Providers[name]
+	if p == nil {
+		log.Printf("[WARN] Unknown OIDC provider %s for %s%s", name, r.Host, r.URL)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+	return p.Authenticate(w, r)
+}
@@ -1,12 +1,22 @@
 package proxy
 
 import (
+	"context"
+	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"text/template"
 	"time"
 
 	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/logger"
 	"github.com/eBay/fabio/metrics"
 	"github.com/eBay/fabio/proxy/gzip"
+	"github.com/eBay/fabio/route"
+	"github.com/eBay/fabio/stats"
+	"github.com/eBay/fabio/tracing"
 )
 
 // httpProxy is a dynamic reverse proxy for HTTP and HTTPS protocols.
@@ -15,27 +25,190 @@ type httpProxy struct {
 	cfg      config.Proxy
 	requests metrics.Timer
 	noroute  metrics.Counter
+
+	// routing measures the time spent in the proxy before
+	// the request is sent to the upstream server without the
+	// time spent in the middleware.
+	routing metrics.Timer
+
+	// exclude contains the paths which are not logged and not
+	// included in the request metrics. They are counted in excluded.
+	exclude  map[string]bool
+	excluded metrics.Counter
+
+	// overrides contains the transports for targets
+	// with route specific timeouts.
+	overrides *transports
+
+	// headers contains the templates for the response headers.
+	headers map[string]*template.Template
+
+	// security contains the security headers for the
+	// responses to requests via HTTPS listeners.
+	security http.Header
+
+	// idempotency stores the responses for routes with the
+	// 'idempotency' option. It is nil if the feature is disabled.
+	idempotency *idempotencyStore
+
+	// cache stores the responses for routes with the 'cache'
+	// option. It is nil if the feature is disabled.
+	cache Cache
+
+	// observe is the handler for the requests in observe-only mode.
+	// It is nil if the mode is disabled.
+	observe  http.Handler
+	observed metrics.Counter
+
+	// trusted contains the networks of the clients which
+	// can set the deadline with the request timeout header.
+	trusted []*net.IPNet
+
+	// middleware handles the requests for a target in order
+	// before they are sent upstream.
+	middleware []Middleware
 }
 
 func NewHTTPProxy(tr http.RoundTripper, cfg config.Proxy) http.Handler {
+	var idempotency *idempotencyStore
+	if cfg.IdempotencySize > 0 {
+		idempotency = newIdempotencyStore(cfg.IdempotencySize, cfg.IdempotencyTTL)
+	}
+	cache := DefaultCache
+	if cache == nil && cfg.CacheSize > 0 {
+		cache = NewMemoryCache(cfg.CacheSize)
+	}
 	return &httpProxy{
-		tr:       tr,
-		cfg:      cfg,
-		requests: metrics.DefaultRegistry.GetTimer("requests"),
-		noroute:  metrics.DefaultRegistry.GetCounter("notfound"),
+		tr:          tr,
+		idempotency: idempotency,
+		cache:       cache,
+		cfg:         cfg,
+		overrides:   newTransports(cfg),
+		headers:     parseHeaderTemplates(cfg.ResponseHeaders),
+		security:    securityHeaders(cfg),
+		requests:    metrics.DefaultRegistry.GetTimer("requests"),
+		noroute:     metrics.DefaultRegistry.GetCounter("notfound"),
+		routing:     metrics.DefaultRegistry.GetTimer("requests.routing"),
+		exclude:     excludePaths(cfg.ExcludePaths),
+		excluded:    metrics.DefaultRegistry.GetCounter("requests.excluded"),
+		trusted:     parseTrustedNets(cfg.RequestTimeoutTrusted),
+		observe:     observeHandler(tr, cfg),
+		observed:    metrics.DefaultRegistry.GetCounter("requests.observed"),
+		middleware:  mustMiddlewareChain(cfg.Middleware),
+	}
+}
+
+// observeHandler returns the handler for the requests in observe-only
+// mode which forwards them to proxy.observeonly.forward or responds with
+// '503 Service Unavailable'. It returns nil if the mode is disabled.
+func observeHandler(tr http.RoundTripper, cfg config.Proxy) http.Handler {
+	if !cfg.ObserveOnly {
+		return nil
+	}
+	if cfg.ObserveOnlyForward == "" {
+		log.Print("[INFO] Observe-only mode. Responding with 503 to all requests")
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !writeErrorPage(w, http.StatusServiceUnavailable) {
+				http.Error(w, "observe-only mode", http.StatusServiceUnavailable)
+			}
+		})
+	}
+	u, err := url.Parse(cfg.ObserveOnlyForward)
+	if err != nil {
+		log.Printf("[ERROR] Invalid proxy.observeonly.forward %q. %s", cfg.ObserveOnlyForward, err)
+		return nil
+	}
+	log.Printf("[INFO] Observe-only mode. Forwarding all requests to %s", u)
+	return newHTTPProxy(u, tr, cfg.FlushInterval)
+}
+
+func excludePaths(paths []string) map[string]bool {
+	if len(paths) == 0 {
+		return nil
 	}
+	m := map[string]bool{}
+	for _, p := range paths {
+		m[p] = true
+	}
+	return m
 }
 
 func (p *httpProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if ShuttingDown() {
-		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		w.Header().Set("Connection", "close")
+		if !writeErrorPage(w, http.StatusServiceUnavailable) {
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		}
+		return
+	}
+
+	if !Ready() {
+		if !writeErrorPage(w, http.StatusServiceUnavailable) {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+		}
+		return
+	}
+
+	start := time.Now()
+	normalizeRequest(r, p.cfg)
+
+	// the client URL is logged since the path and the query
+	// can be rewritten for the upstream request below.
+	reqURL := r.URL
+	excluded := p.exclude[reqURL.Path]
+	if excluded {
+		p.excluded.Inc(1)
+	}
+
+	id := requestID(r, p.cfg.RequestIDHeader)
+	if id != "" {
+		w.Header().Set(p.cfg.RequestIDHeader, id)
+	}
+
+	if loc := trailingSlashRedirect(r, p.cfg.TrailingSlash); loc != "" {
+		code := redirectStatus(r.Method)
+		http.Redirect(w, r, loc, code)
+		p.logAccess(r, reqURL, id, nil, code, 0, start)
 		return
 	}
 
-	t := target(r)
+	// the override method is used for the lookup and the middleware
+	t, method := lookupTarget(r)
 	if t == nil {
 		p.noroute.Inc(1)
 		w.WriteHeader(p.cfg.NoRouteStatus)
+		p.logAccess(r, reqURL, id, nil, p.cfg.NoRouteStatus, 0, start)
+		return
+	}
+
+	if m := t.Maintenance(); m != nil {
+		writeMaintenance(w, m)
+		p.logAccess(r, reqURL, id, t, m.Status, 0, start)
+		return
+	}
+
+	// the middleware can call other services, e.g. for
+	// authorization, which is not part of the routing time.
+	mwStart := time.Now()
+	for _, m := range p.middleware {
+		if code := m(w, r, t); code != 0 {
+			p.logAccess(r, reqURL, id, t, code, 0, start)
+			return
+		}
+	}
+	routingStart := start.Add(time.Since(mwStart))
+
+	if p.observe != nil {
+		rw := &responseWriter{w: w}
+		if !excluded {
+			p.routing.UpdateSince(routingStart)
+		}
+		p.observe.ServeHTTP(rw, r)
+		if !excluded {
+			p.observed.Inc(1)
+			p.requests.UpdateSince(start)
+		}
+		p.logAccess(r, reqURL, id, t, rw.code, rw.size, start)
 		return
 	}
 
@@ -44,12 +217,65 @@ func (p *httpProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	release, ok := acquireConn(r, t)
+	if !ok {
+		if !writeErrorPage(w, http.StatusServiceUnavailable) {
+			http.Error(w, "too many concurrent requests", http.StatusServiceUnavailable)
+		}
+		p.logAccess(r, reqURL, id, t, http.StatusServiceUnavailable, 0, start)
+		return
+	}
+	defer release()
+
+	span := tracing.Default.StartSpan(r, t.Service)
+	span.SetTag("http.method", method)
+	span.SetTag("http.host", r.Host)
+	span.SetTag("http.path", r.URL.Path)
+	span.SetTag("fabio.target", t.URL.String())
+
+	// the path is replaced with the one from the target URL
+	// which contains the capture groups of the route.
+	targetURL := t.URL
+	if path, ok := t.RewritePath(r.URL.Path); ok {
+		u, ru := *t.URL, *r.URL
+		u.Path, u.RawPath = "", ""
+		ru.Path, ru.RawPath = path, ""
+		targetURL, r.URL = &u, &ru
+	}
+	if t.Query != nil {
+		ru := *r.URL
+		ru.RawQuery = t.Query.Apply(ru.RawQuery)
+		r.URL = &ru
+	}
+
+	tr := p.overrides.get(t)
+	if tr == nil {
+		tr = p.tr
+	}
+	base := tr
+	if n, ok := t.Opts["followredirects"]; ok {
+		tr = newRedirectRoundTripper(tr, n)
+	}
+	if svc := t.Opts["fallback"]; svc != "" {
+		tr = newFallbackRoundTripper(tr, svc, t.Opts["fallbackstatus"])
+	}
+	if algo := t.Opts["checksum"]; algo != "" {
+		tr = newChecksumRoundTripper(tr, algo, t.Opts["checksumheader"])
+	}
+	if t.Mirror != nil {
+		tr = newMirrorRoundTripper(tr, base, t.Mirror)
+	}
+
 	upgrade, accept := r.Header.Get("Upgrade"), r.Header.Get("Accept")
 
 	var h http.Handler
+	th := targetHandler(t.URL.Scheme)
 	switch {
+	case th != nil:
+		h = th(t)
+
 	case upgrade == "websocket" || upgrade == "Websocket":
-		h = newRawProxy(t.URL)
+		h = newRawProxy(targetURL, dialTimeout(t, p.cfg), t.MaxBpsIn, t.MaxBpsOut)
 
 		// To use the filtered proxy use
 		// h = newWSProxy(t.URL)
@@ -57,18 +283,114 @@ func (p *httpProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case accept == "text/event-stream":
 		// use the flush interval for SSE (server-sent events)
 		// must be > 0s to be effective
-		h = newHTTPProxy(t.URL, p.tr, p.cfg.FlushInterval)
+		h = newHTTPProxy(targetURL, tr, p.cfg.FlushInterval)
 
 	default:
-		h = newHTTPProxy(t.URL, p.tr, time.Duration(0))
+		h = newHTTPProxy(targetURL, tr, time.Duration(0))
+	}
+
+	if _, ok := t.Opts["idempotency"]; ok && p.idempotency != nil {
+		h = newIdempotencyHandler(h, p.idempotency, t.Route)
+	}
+
+	if t.CacheTTL > 0 && p.cache != nil && cacheableTarget(t) {
+		h = newCacheHandler(h, p.cache, t.Route, cachePolicy{
+			ttl:                  t.CacheTTL,
+			staleWhileRevalidate: t.CacheStaleWhileRevalidate,
+			staleIfError:         t.CacheStaleIfError,
+		})
 	}
 
 	if p.cfg.GZIPContentTypes != nil {
 		h = gzip.NewGzipHandler(h, p.cfg.GZIPContentTypes)
 	}
 
-	start := time.Now()
+	_, informational := t.Opts["informational"]
+	rw := &responseWriter{
+		w:             w,
+		hdr:           corsHeaders(p.responseHeaders(r, t, id), r, t.CORS),
+		cacheControl:  t.CacheControl,
+		informational: informational,
+		throttle:      newThrottle(t.MaxBpsOut),
+		varyOrigin:    t.CORS != nil,
+	}
+	throttleBody(r, t.MaxBpsIn)
+	if d := p.requestTimeout(r, t); d > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
+	if !excluded {
+		p.routing.UpdateSince(routingStart)
+	}
+	aborted := serveAbortable(h, rw, r)
+	if aborted {
+		rw.code = http.StatusBadGateway
+	}
+	if !excluded {
+		p.requests.UpdateSince(start)
+		t.Timer.UpdateSince(start)
+		t.RouteTimer.UpdateSince(start)
+		t.CountStatus(rw.code)
+		t.StatusCounter(rw.code).Inc(1)
+		t.ErrorRate.Update(stats.Default.Record(t.Route, t.Service, t.URL.Host, rw.code, rw.size, time.Since(start)))
+	}
+
+	span.SetTag("http.status_code", strconv.Itoa(rw.code))
+	if rw.code >= 500 {
+		span.SetTag("error", "true")
+	}
+	span.Finish()
+
+	p.logAccess(r, reqURL, id, t, rw.code, rw.size, start)
+
+	// abort the response to the client after the request
+	// has been counted and logged.
+	if aborted {
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// serveAbortable calls the handler and returns true if it has aborted
+// the response with http.ErrAbortHandler, e.g. since the body of the
+// upstream response failed its checksum after the header was sent.
+// Other panics are passed on.
+func serveAbortable(h http.Handler, w http.ResponseWriter, r *http.Request) (aborted bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			if err != http.ErrAbortHandler {
+				panic(err)
+			}
+			aborted = true
+		}
+	}()
 	h.ServeHTTP(w, r)
-	p.requests.UpdateSince(start)
-	t.Timer.UpdateSince(start)
+	return false
+}
+
+// logAccess writes the access log entry for the request with
+// the URL u of the client unless its path is excluded.
+func (p *httpProxy) logAccess(r *http.Request, u *url.URL, id string, t *route.Target, code int, size int64, start time.Time) {
+	if logger.Default == nil || p.exclude[u.Path] {
+		return
+	}
+	if r.URL != u {
+		r2 := *r
+		r2.URL = u
+		r = &r2
+	}
+	e := &logger.Event{
+		Start:            start,
+		End:              time.Now(),
+		Request:          r,
+		RequestID:        id,
+		ResponseStatus:   code,
+		ResponseBodySize: size,
+	}
+	if t != nil {
+		e.UpstreamService = t.Service
+		e.UpstreamURL = t.URL
+	}
+	logger.Default.Log(e)
 }
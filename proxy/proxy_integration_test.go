@@ -3,15 +3,35 @@ package proxy
 import (
 	"bytes"
 	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
 	"net/url"
+	"os"
 	"regexp"
 	"testing"
+	"time"
 
+	"github.com/eBay/fabio/auth"
+	"github.com/eBay/fabio/cert"
 	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/jwt"
+	"github.com/eBay/fabio/logger"
 	"github.com/eBay/fabio/route"
+	"github.com/eBay/fabio/tracing"
 )
 
 func TestProxyProducesCorrectXffHeader(t *testing.T) {
@@ -59,6 +79,109 @@ func TestProxyNoRouteStaus(t *testing.T) {
 	}
 }
 
+func TestProxyNotReady(t *testing.T) {
+	route.SetTable(make(route.Table))
+	tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+	proxy := NewHTTPProxy(tr, config.Proxy{NoRouteStatus: 404})
+	req := &http.Request{
+		RequestURI: "/",
+		URL:        &url.URL{},
+	}
+
+	SetReady(false)
+	defer SetReady(true)
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusServiceUnavailable; got != want {
+		t.Fatalf("got %d want %d", got, want)
+	}
+
+	SetReady(true)
+	rec = httptest.NewRecorder()
+	proxy.ServeHTTP(rec, req)
+	if got, want := rec.Code, 404; got != want {
+		t.Fatalf("got %d want %d", got, want)
+	}
+}
+
+func TestProxyRouteResponseTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		opts string
+		code int
+	}{
+		{"", 502},
+		{"responsetimeout=10ms", 502},
+		{"responsetimeout=1s", 200},
+	}
+
+	for i, tt := range tests {
+		table := make(route.Table)
+		table.AddRouteOpts("mock", "/", server.URL, 1, nil, route.ParseOpts(tt.opts))
+		route.SetTable(table)
+
+		cfg := config.Proxy{ResponseHeaderTimeout: 50 * time.Millisecond}
+		tr, err := NewTransport(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proxy := NewHTTPProxy(tr, cfg)
+		req := &http.Request{RequestURI: "/", RemoteAddr: "2.2.2.2:2222", Header: http.Header{}, URL: &url.URL{}}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+
+		if got, want := rec.Code, tt.code; got != want {
+			t.Errorf("%d: got code %d want %d", i, got, want)
+		}
+	}
+}
+
+func TestProxyTracingHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer server.Close()
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer collector.Close()
+
+	var err error
+	tracing.Default, err = tracing.New(config.Tracing{Format: "b3", Collector: collector.URL, SampleRate: 1}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { tracing.Default = nil }()
+
+	table := make(route.Table)
+	table.AddRoute("mock", "/", server.URL, 1, nil)
+	route.SetTable(table)
+
+	tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+	proxy := NewHTTPProxy(tr, config.Proxy{})
+	req := &http.Request{
+		RequestURI: "/",
+		Header:     http.Header{"X-B3-Traceid": {"463ac35c9f6413ad"}, "X-B3-Spanid": {"a2fb4a1d1a96d312"}},
+		RemoteAddr: "2.2.2.2:666",
+		URL:        &url.URL{},
+	}
+	proxy.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got, want := got.Get("X-B3-Traceid"), "463ac35c9f6413ad"; got != want {
+		t.Errorf("got trace id %q want %q", got, want)
+	}
+	if got, want := got.Get("X-B3-Parentspanid"), "a2fb4a1d1a96d312"; got != want {
+		t.Errorf("got parent span id %q want %q", got, want)
+	}
+	if got := got.Get("X-B3-Spanid"); got == "" || got == "a2fb4a1d1a96d312" {
+		t.Errorf("got span id %q want new span id", got)
+	}
+}
+
 func TestProxyGzipHandler(t *testing.T) {
 	tests := []struct {
 		desc            string
@@ -170,3 +293,845 @@ func compress(b []byte) []byte {
 	}
 	return buf.Bytes()
 }
+
+func TestProxyRequestID(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Request-Id")
+		w.Header().Set("X-Request-Id", "upstream")
+	}))
+	defer server.Close()
+
+	table := make(route.Table)
+	table.AddRoute("mock", "/", server.URL, 1, nil)
+	route.SetTable(table)
+
+	var log bytes.Buffer
+	var err error
+	logger.Default, err = logger.New(&log, "$request_id $upstream_service $response_status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { logger.Default = nil }()
+
+	tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+	proxy := NewHTTPProxy(tr, config.Proxy{RequestIDHeader: "x-request-id"})
+
+	// generate a request id
+	req := &http.Request{RequestURI: "/", Header: http.Header{}, RemoteAddr: "2.2.2.2:666", URL: &url.URL{}}
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, req)
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(got) {
+		t.Fatalf("got invalid request id %q", got)
+	}
+	if h := rec.HeaderMap["X-Request-Id"]; len(h) != 1 || h[0] != got {
+		t.Errorf("got response header %q want %q", h, got)
+	}
+	if got, want := log.String(), got+" mock 200\n"; got != want {
+		t.Errorf("got log %q want %q", got, want)
+	}
+
+	// keep an existing request id
+	req = &http.Request{RequestURI: "/", Header: http.Header{"X-Request-Id": {"abc"}}, RemoteAddr: "2.2.2.2:666", URL: &url.URL{}}
+	rec = httptest.NewRecorder()
+	proxy.ServeHTTP(rec, req)
+	if got != "abc" {
+		t.Errorf("got request id %q want %q", got, "abc")
+	}
+	if got, want := rec.HeaderMap.Get("X-Request-Id"), "abc"; got != want {
+		t.Errorf("got response header %q want %q", got, want)
+	}
+}
+
+func TestProxyBasicAuth(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer server.Close()
+
+	f, err := ioutil.TempFile("", "htpasswd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("alice:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n")
+	f.Close()
+
+	realm, err := auth.New(config.AuthSource{Name: "staging", Type: "file", Path: f.Name(), Realm: "Staging"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.Realms["staging"] = realm
+	defer delete(auth.Realms, "staging")
+
+	tests := []struct {
+		opts, user, pass string
+		code             int
+	}{
+		{"auth=staging", "", "", 401},
+		{"auth=staging", "alice", "wrong", 401},
+		{"auth=staging", "alice", "secret", 200},
+		{"auth=unknown", "alice", "secret", 500},
+		{"auth=", "alice", "secret", 500},
+	}
+
+	for i, tt := range tests {
+		got = nil
+		table := make(route.Table)
+		table.AddRouteOpts("mock", "/", server.URL, 1, nil, route.ParseOpts(tt.opts))
+		route.SetTable(table)
+
+		tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+		proxy := NewHTTPProxy(tr, config.Proxy{})
+		req := &http.Request{RequestURI: "/", Header: http.Header{}, RemoteAddr: "2.2.2.2:666", URL: &url.URL{}}
+		if tt.user != "" {
+			req.SetBasicAuth(tt.user, tt.pass)
+		}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+
+		if got, want := rec.Code, tt.code; got != want {
+			t.Errorf("%d: got code %d want %d", i, got, want)
+		}
+		if tt.code == 401 {
+			if got, want := rec.HeaderMap.Get("WWW-Authenticate"), `Basic realm="Staging"`; got != want {
+				t.Errorf("%d: got %q want %q", i, got, want)
+			}
+		}
+		if tt.code == 200 && got.Get("Authorization") != "" {
+			t.Errorf("%d: got credentials upstream", i)
+		}
+		if tt.code != 200 && got != nil {
+			t.Errorf("%d: got unauthorized request upstream", i)
+		}
+	}
+}
+
+func TestProxyCacheBasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := r.Header.Get("X-User")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("hello " + user))
+	}))
+	defer server.Close()
+
+	f, err := ioutil.TempFile("", "htpasswd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("alice:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\nbob:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n")
+	f.Close()
+
+	realm, err := auth.New(config.AuthSource{Name: "staging", Type: "file", Path: f.Name(), Realm: "Staging"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.Realms["staging"] = realm
+	defer delete(auth.Realms, "staging")
+
+	table := make(route.Table)
+	table.AddRouteOpts("mock", "/", server.URL, 1, nil, route.ParseOpts("cache=1m auth=staging"))
+	route.SetTable(table)
+
+	tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+	proxy := NewHTTPProxy(tr, config.Proxy{CacheSize: 10})
+
+	// the upstream response depends on the user which
+	// the client sends in a header after the login
+	for _, user := range []string{"alice", "bob"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.SetBasicAuth(user, "secret")
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+
+		if got, want := rec.Body.String(), "hello "+user; got != want {
+			t.Fatalf("got %q want %q", got, want)
+		}
+	}
+}
+
+func TestProxyJWT(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer server.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	f, err := ioutil.TempFile("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"keys":[{"kty":"EC","kid":"k1","crv":"P-256","x":"` + b64(key.X.Bytes()) + `","y":"` + b64(key.Y.Bytes()) + `"}]}`)
+	f.Close()
+
+	iss, err := jwt.New(config.JWTIssuer{Name: "sso", JWKS: f.Name(), Claims: map[string]string{"role": "admin"}, Headers: map[string]string{"sub": "X-User"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwt.Issuers["sso"] = iss
+	defer delete(jwt.Issuers, "sso")
+
+	sign := func(claims string) string {
+		signed := b64([]byte(`{"alg":"ES256","kid":"k1"}`)) + "." + b64([]byte(claims))
+		digest := sha256.Sum256([]byte(signed))
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return "Bearer " + signed + "." + b64(sig)
+	}
+
+	tests := []struct {
+		opts, auth string
+		code       int
+		challenge  string
+	}{
+		{"jwt=sso", "", 401, "Bearer"},
+		{"jwt=sso", "Bearer abc", 401, `Bearer error="invalid_token"`},
+		{"jwt=sso", sign(`{"sub":"alice","role":"user"}`), 403, `Bearer error="insufficient_scope"`},
+		{"jwt=sso", sign(`{"sub":"alice","role":"admin"}`), 200, ""},
+		{"jwt=unknown", sign(`{"sub":"alice","role":"admin"}`), 500, ""},
+		{"jwt=", sign(`{"sub":"alice","role":"admin"}`), 500, ""},
+	}
+
+	for i, tt := range tests {
+		got = nil
+		table := make(route.Table)
+		table.AddRouteOpts("mock", "/", server.URL, 1, nil, route.ParseOpts(tt.opts))
+		route.SetTable(table)
+
+		tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+		proxy := NewHTTPProxy(tr, config.Proxy{})
+		req := &http.Request{RequestURI: "/", Header: http.Header{"X-User": {"mallory"}}, RemoteAddr: "2.2.2.2:666", URL: &url.URL{}}
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+
+		if got, want := rec.Code, tt.code; got != want {
+			t.Errorf("%d: got code %d want %d", i, got, want)
+		}
+		if got, want := rec.HeaderMap.Get("WWW-Authenticate"), tt.challenge; got != want {
+			t.Errorf("%d: got %q want %q", i, got, want)
+		}
+		if tt.code == 200 && got.Get("X-User") != "alice" {
+			t.Errorf("%d: got user %q upstream want %q", i, got.Get("X-User"), "alice")
+		}
+		if tt.code != 200 && got != nil {
+			t.Errorf("%d: got unauthorized request upstream", i)
+		}
+	}
+}
+
+func TestProxyAccessRules(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	table := make(route.Table)
+	table.AddRouteOpts("mock", "/", server.URL, 1, nil, route.ParseOpts("allow=ip:10.0.0.0/8 deny=ip:10.1.0.0/16"))
+	route.SetTable(table)
+
+	tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+	proxy := NewHTTPProxy(tr, config.Proxy{})
+
+	tests := []struct {
+		addr string
+		code int
+	}{
+		{"10.2.3.4:666", 200},
+		{"10.1.3.4:666", 403},
+		{"2.2.2.2:666", 403},
+	}
+
+	for i, tt := range tests {
+		req := &http.Request{RequestURI: "/", Header: http.Header{}, RemoteAddr: tt.addr, URL: &url.URL{}}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		if got, want := rec.Code, tt.code; got != want {
+			t.Errorf("%d: got code %d want %d", i, got, want)
+		}
+	}
+}
+
+func TestProxyTLSPins(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	h := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	pin := "sha256:" + base64.StdEncoding.EncodeToString(h[:])
+	other := "sha256:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+
+	table := make(route.Table)
+	table.AddRouteOpts("mock", "/pinonly", server.URL, 1, nil, route.ParseOpts("tlspinonly tlspin="+other+","+pin))
+	table.AddRouteOpts("mock", "/wrongpin", server.URL, 1, nil, route.ParseOpts("tlspinonly tlspin="+other))
+	table.AddRouteOpts("mock", "/untrusted", server.URL, 1, nil, route.ParseOpts("tlspin="+pin))
+	route.SetTable(table)
+
+	proxy := NewHTTPProxy(http.DefaultTransport, config.Proxy{})
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/pinonly", 200},
+		{"/wrongpin", 502},
+		{"/untrusted", 502},
+	}
+
+	for _, tt := range tests {
+		req := &http.Request{RequestURI: tt.path, Header: http.Header{}, RemoteAddr: "1.2.3.4:5555", URL: &url.URL{Path: tt.path}}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		if got, want := rec.Code, tt.code; got != want {
+			t.Errorf("%s: got code %d want %d", tt.path, got, want)
+		}
+	}
+}
+
+func TestProxyHTTPSUpstream(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	f, err := ioutil.TempFile("", "fabio-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	f.Close()
+
+	table := make(route.Table)
+	table.AddRouteOpts("mock", "/verify", server.URL, 1, nil, nil)
+	table.AddRouteOpts("mock", "/skipverify", server.URL, 1, nil, route.ParseOpts("tlsskipverify=true"))
+	route.SetTable(table)
+
+	tests := []struct {
+		desc string
+		cfg  config.Proxy
+		path string
+		code int
+	}{
+		{"unknown CA", config.Proxy{}, "/verify", 502},
+		{"skip verify route", config.Proxy{}, "/skipverify", 200},
+		{"skip verify global", config.Proxy{TLSSkipVerify: true}, "/verify", 200},
+		{"CA bundle", config.Proxy{TLSCAPath: f.Name()}, "/verify", 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tr, err := NewTransport(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			proxy := NewHTTPProxy(tr, tt.cfg)
+			req := &http.Request{RequestURI: tt.path, Header: http.Header{}, RemoteAddr: "1.2.3.4:5555", URL: &url.URL{Path: tt.path}}
+			rec := httptest.NewRecorder()
+			proxy.ServeHTTP(rec, req)
+			if got, want := rec.Code, tt.code; got != want {
+				t.Errorf("got code %d want %d", got, want)
+			}
+		})
+	}
+
+	if _, err := NewTransport(config.Proxy{TLSCAPath: "/does/not/exist"}); err == nil {
+		t.Fatal("got no error for missing CA bundle")
+	}
+}
+
+func TestProxyClientCertificate(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	store := cert.NewStore()
+	store.SetCertificates([]tls.Certificate{makeClientCert(t, "fabio")})
+	cert.ClientStores["client"] = store
+	defer delete(cert.ClientStores, "client")
+
+	table := make(route.Table)
+	table.AddRouteOpts("mock", "/route", server.URL, 1, nil, route.ParseOpts("tlsskipverify=true tlscs=client"))
+	table.AddRouteOpts("mock", "/global", server.URL, 1, nil, nil)
+	table.AddRouteOpts("mock", "/nocert", server.URL, 1, nil, route.ParseOpts("tlsskipverify=true"))
+	route.SetTable(table)
+
+	tests := []struct {
+		desc string
+		cfg  config.Proxy
+		path string
+		code int
+		body string
+	}{
+		{"route", config.Proxy{}, "/route", 200, "fabio"},
+		{"global", config.Proxy{TLSSkipVerify: true, TLSCertSource: "client"}, "/global", 200, "fabio"},
+		{"no cert", config.Proxy{}, "/nocert", 502, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tr, err := NewTransport(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			proxy := NewHTTPProxy(tr, tt.cfg)
+			req := &http.Request{RequestURI: tt.path, Header: http.Header{}, RemoteAddr: "1.2.3.4:5555", URL: &url.URL{Path: tt.path}}
+			rec := httptest.NewRecorder()
+			proxy.ServeHTTP(rec, req)
+			if got, want := rec.Code, tt.code; got != want {
+				t.Fatalf("got code %d want %d", got, want)
+			}
+			if tt.body != "" {
+				if got, want := rec.Body.String(), tt.body; got != want {
+					t.Fatalf("got body %q want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+// makeClientCert returns a self-signed certificate with the common name.
+func makeClientCert(t *testing.T, cn string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestProxyResponseHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Route", "upstream")
+		w.Header().Set("X-Upstream", "yes")
+	}))
+	defer server.Close()
+
+	table := make(route.Table)
+	table.AddRouteOpts("mock", "example.com/foo", server.URL, 1, nil, route.ParseOpts("respheader.x-target={{.Service}}@{{.Target}}"))
+	route.SetTable(table)
+
+	tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+	cfg := config.Proxy{ResponseHeaders: map[string]string{"X-Route": "{{.RouteSrc}}", "X-Method": "{{.Method}} {{.Path}}"}}
+	proxy := NewHTTPProxy(tr, cfg)
+	req := &http.Request{Method: "GET", Host: "example.com", RequestURI: "/foo/bar", Header: http.Header{}, RemoteAddr: "2.2.2.2:666", URL: &url.URL{Path: "/foo/bar"}}
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, req)
+
+	want := map[string]string{
+		"X-Route":    "example.com/foo",
+		"X-Method":   "GET /foo/bar",
+		"X-Target":   "mock@" + server.URL,
+		"X-Upstream": "yes",
+	}
+	for name, v := range want {
+		if got := rec.HeaderMap[name]; len(got) != 1 || got[0] != v {
+			t.Errorf("%s: got %q want %q", name, got, v)
+		}
+	}
+}
+
+func TestProxySecurityHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "ALLOW")
+	}))
+	defer server.Close()
+
+	table := make(route.Table)
+	table.AddRoute("mock", "/global", server.URL, 1, nil)
+	table.AddRouteOpts("mock", "/route", server.URL, 1, nil, route.ParseOpts("sts.maxage=60 sts.preload frameoptions=SAMEORIGIN csp=default-src%20'none'"))
+	route.SetTable(table)
+
+	tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+	cfg := config.Proxy{STSMaxAge: 31536000, STSSubdomains: true, FrameOptions: "DENY", ContentTypeOptions: "nosniff"}
+	proxy := NewHTTPProxy(tr, cfg)
+
+	tests := []struct {
+		desc string
+		path string
+		tls  bool
+		want map[string]string
+	}{
+		{
+			"http", "/global", false,
+			map[string]string{"Strict-Transport-Security": "", "X-Frame-Options": "ALLOW", "X-Content-Type-Options": ""},
+		},
+		{
+			"https global", "/global", true,
+			map[string]string{"Strict-Transport-Security": "max-age=31536000; includeSubDomains", "X-Frame-Options": "DENY", "X-Content-Type-Options": "nosniff", "Content-Security-Policy": ""},
+		},
+		{
+			"https route", "/route", true,
+			map[string]string{"Strict-Transport-Security": "max-age=60; preload", "X-Frame-Options": "SAMEORIGIN", "X-Content-Type-Options": "nosniff", "Content-Security-Policy": "default-src 'none'"},
+		},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.tls {
+			req.TLS = &tls.ConnectionState{}
+		}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		for name, v := range tt.want {
+			if got := rec.Header().Get(name); got != v {
+				t.Errorf("%s: %s: got %q want %q", tt.desc, name, got, v)
+			}
+		}
+	}
+}
+
+func TestProxySetCacheControl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.URL.Path == "/static/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	table := make(route.Table)
+	table.AddRouteOpts("mock", "/static", server.URL, 1, nil, route.ParseOpts("setcachecontrol=public,max-age=3600"))
+	table.AddRoute("mock", "/api", server.URL, 1, nil)
+	route.SetTable(table)
+
+	tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+	proxy := NewHTTPProxy(tr, config.Proxy{})
+
+	tests := []struct {
+		path, cacheControl string
+	}{
+		{"/static/app.js", "public,max-age=3600"},
+		{"/static/missing", "no-cache"},
+		{"/api/foo", "no-cache"},
+	}
+	for _, tt := range tests {
+		req := &http.Request{RequestURI: tt.path, Header: http.Header{}, RemoteAddr: "1.2.3.4:5555", URL: &url.URL{Path: tt.path}}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		if got, want := rec.Header().Get("Cache-Control"), tt.cacheControl; got != want {
+			t.Errorf("%s: got %q want %q", tt.path, got, want)
+		}
+	}
+}
+
+func TestProxyInformationalResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</app.css>; rel=preload")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Del("Link")
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	table := make(route.Table)
+	table.AddRouteOpts("mock", "/hints", server.URL, 1, nil, route.ParseOpts("informational"))
+	table.AddRoute("mock", "/", server.URL, 1, nil)
+	route.SetTable(table)
+
+	proxy := httptest.NewServer(NewHTTPProxy(http.DefaultTransport, config.Proxy{}))
+	defer proxy.Close()
+
+	get := func(path string) (hints []string, code int) {
+		trace := &httptrace.ClientTrace{
+			Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+				hints = append(hints, header.Get("Link"))
+				return nil
+			},
+		}
+		req, _ := http.NewRequest("GET", proxy.URL+path, nil)
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if got := resp.Header.Get("Link"); got != "" {
+			t.Errorf("%s: got Link header %q on final response", path, got)
+		}
+		return hints, resp.StatusCode
+	}
+
+	hints, code := get("/hints")
+	if code != 200 || len(hints) != 1 || hints[0] != "</app.css>; rel=preload" {
+		t.Errorf("got %d %q want 200 and one early hint", code, hints)
+	}
+	hints, code = get("/other")
+	if code != 200 || len(hints) != 0 {
+		t.Errorf("got %d %q want 200 and no early hints", code, hints)
+	}
+}
+
+func TestProxyExcludePaths(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	table := make(route.Table)
+	table.AddRoute("mock", "/", server.URL, 1, nil)
+	route.SetTable(table)
+
+	var log bytes.Buffer
+	var err error
+	logger.Default, err = logger.New(&log, "$request_uri")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { logger.Default = nil }()
+
+	tr := &http.Transport{Dial: (&net.Dialer{}).Dial}
+	proxy := NewHTTPProxy(tr, config.Proxy{ExcludePaths: []string{"/health"}})
+	for _, path := range []string{"/health", "/foo", "/health/x"} {
+		req := &http.Request{RequestURI: path, Header: http.Header{}, RemoteAddr: "2.2.2.2:666", URL: &url.URL{Path: path}}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		if got, want := rec.Code, 200; got != want {
+			t.Fatalf("%s: got code %d want %d", path, got, want)
+		}
+	}
+
+	if got, want := log.String(), "/foo\n/health/x\n"; got != want {
+		t.Errorf("got log %q want %q", got, want)
+	}
+}
+
+func TestProxyRequestTimeoutHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		desc   string
+		remote string
+		value  string
+		opts   string
+		code   int
+	}{
+		{"no header", "10.1.1.1:1234", "", "", 200},
+		{"enough time", "10.1.1.1:1234", "1s", "", 200},
+		{"deadline exceeded", "10.1.1.1:1234", "10ms", "", 504},
+		{"untrusted client", "2.2.2.2:1234", "10ms", "", 200},
+		{"route max", "10.1.1.1:1234", "1s", "maxrequesttimeout=10ms", 504},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			table := make(route.Table)
+			table.AddRouteOpts("mock", "/", server.URL, 1, nil, route.ParseOpts(tt.opts))
+			route.SetTable(table)
+
+			cfg := config.Proxy{RequestTimeoutHeader: "X-Request-Timeout", RequestTimeoutTrusted: []string{"10.0.0.0/8"}}
+			tr, err := NewTransport(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			proxy := NewHTTPProxy(tr, cfg)
+			req := &http.Request{RequestURI: "/", RemoteAddr: tt.remote, Header: http.Header{}, URL: &url.URL{}}
+			if tt.value != "" {
+				req.Header.Set("X-Request-Timeout", tt.value)
+			}
+			rec := httptest.NewRecorder()
+			proxy.ServeHTTP(rec, req)
+
+			if got, want := rec.Code, tt.code; got != want {
+				t.Fatalf("got code %d want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestProxyObserveOnly(t *testing.T) {
+	var targetCalled bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targetCalled = true
+	}))
+	defer server.Close()
+
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("live " + r.Host + r.URL.Path))
+	}))
+	defer live.Close()
+
+	table := make(route.Table)
+	table.AddRoute("mock", "/", server.URL, 1, nil)
+	route.SetTable(table)
+
+	tests := []struct {
+		desc string
+		cfg  config.Proxy
+		code int
+		body string
+	}{
+		{"503", config.Proxy{ObserveOnly: true}, 503, "observe-only mode\n"},
+		{"forward", config.Proxy{ObserveOnly: true, ObserveOnlyForward: live.URL}, 200, "live example.com/foo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			targetCalled = false
+			proxy := NewHTTPProxy(&http.Transport{}, tt.cfg)
+			req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+			req.RequestURI = "/foo"
+			rec := httptest.NewRecorder()
+			proxy.ServeHTTP(rec, req)
+
+			if got, want := rec.Code, tt.code; got != want {
+				t.Fatalf("got code %d want %d", got, want)
+			}
+			if got, want := rec.Body.String(), tt.body; got != want {
+				t.Fatalf("got body %q want %q", got, want)
+			}
+			if targetCalled {
+				t.Fatal("target was called")
+			}
+		})
+	}
+}
+
+func TestProxyRegexpRewrite(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.RequestURI()
+	}))
+	defer server.Close()
+
+	if err := route.SetMatcher("regexp"); err != nil {
+		t.Fatal(err)
+	}
+	defer route.SetMatcher("prefix")
+
+	tbl, err := route.ParseString("route add svc /user/([0-9]+) " + server.URL + "/v2/users/$1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.SetTable(tbl)
+
+	proxy := NewHTTPProxy(&http.Transport{}, config.Proxy{})
+	req := httptest.NewRequest("GET", "/user/42?x=y", nil)
+	proxy.ServeHTTP(httptest.NewRecorder(), req)
+
+	if want := "/v2/users/42?x=y"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestProxyRegexpRewriteExcludePaths(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if err := route.SetMatcher("regexp"); err != nil {
+		t.Fatal(err)
+	}
+	defer route.SetMatcher("prefix")
+
+	tbl, err := route.ParseString("route add svc /user/([0-9]+) " + server.URL + "/v2/users/$1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.SetTable(tbl)
+
+	var log bytes.Buffer
+	logger.Default, err = logger.New(&log, "$request_uri")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { logger.Default = nil }()
+
+	proxy := NewHTTPProxy(&http.Transport{}, config.Proxy{ExcludePaths: []string{"/user/42"}})
+	for _, path := range []string{"/user/42", "/user/7?x=y"} {
+		proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if got, want := log.String(), "/user/7?x=y\n"; got != want {
+		t.Errorf("got log %q want %q", got, want)
+	}
+}
+
+func TestProxyQueryRules(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.RawQuery
+	}))
+	defer server.Close()
+
+	tbl, err := route.ParseString(`route add svc /api ` + server.URL + ` opts "add-query=version:2 strip-query=debug"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.SetTable(tbl)
+
+	proxy := NewHTTPProxy(&http.Transport{}, config.Proxy{})
+	req := httptest.NewRequest("GET", "/api/x?debug=1&q=a", nil)
+	proxy.ServeHTTP(httptest.NewRecorder(), req)
+
+	if want := "q=a&version=2"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestProxyUnixUpstream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := dir + "/app.sock"
+
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, host string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, host = r.URL.RequestURI(), r.Host
+	}))
+	server.Listener = ln
+	server.Start()
+	defer server.Close()
+
+	tbl, err := route.ParseString("route add svc /app unix://" + sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.SetTable(tbl)
+
+	proxy := NewHTTPProxy(&http.Transport{}, config.Proxy{})
+	req := httptest.NewRequest("GET", "/app/x?y=z", nil)
+	req.Host = "example.com"
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, req)
+
+	if rec.Code != 200 {
+		t.Fatalf("got status %d want 200", rec.Code)
+	}
+	if want := "/app/x?y=z"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	if want := "example.com"; host != want {
+		t.Fatalf("got host %q want %q", host, want)
+	}
+}
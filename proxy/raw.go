@@ -6,6 +6,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/eBay/fabio/metrics"
 )
@@ -16,7 +17,9 @@ var conn = metrics.DefaultRegistry.GetCounter("ws.conn")
 // newRawProxy returns an HTTP handler which forwards data between
 // an incoming and outgoing TCP connection including the original request.
 // This handler establishes a new outgoing connection per request.
-func newRawProxy(t *url.URL) http.Handler {
+// The bandwidth in both directions is limited to bpsIn and bpsOut
+// bits per second if they are not zero.
+func newRawProxy(t *url.URL, dialTimeout time.Duration, bpsIn, bpsOut int64) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		conn.Inc(1)
 		defer func() { conn.Inc(-1) }()
@@ -35,7 +38,11 @@ func newRawProxy(t *url.URL) http.Handler {
 		}
 		defer in.Close()
 
-		out, err := net.Dial("tcp", t.Host)
+		network, addr := "tcp", t.Host
+		if t.Scheme == "unix" {
+			network, addr = "unix", t.Path
+		}
+		out, err := net.DialTimeout(network, addr, dialTimeout)
 		if err != nil {
 			log.Printf("[ERROR] WS error for %s. %s", r.URL, err)
 			http.Error(w, "error contacting backend server", http.StatusInternalServerError)
@@ -56,8 +63,8 @@ func newRawProxy(t *url.URL) http.Handler {
 			errc <- err
 		}
 
-		go cp(out, in)
-		go cp(in, out)
+		go cp(throttleWriter(out, bpsIn), in)
+		go cp(throttleWriter(in, bpsOut), out)
 		err = <-errc
 		if err != nil && err != io.EOF {
 			log.Printf("[INFO] WS error for %s. %s", r.URL, err)
@@ -0,0 +1,22 @@
+package proxy
+
+import "sync/atomic"
+
+var notReady int32
+
+// SetReady sets whether the proxy routes requests. Until the proxy
+// is ready the HTTP proxy responds with 503 Service Unavailable and
+// the TCP+SNI proxy closes the connections. The proxy is ready by
+// default.
+func SetReady(ok bool) {
+	var v int32
+	if !ok {
+		v = 1
+	}
+	atomic.StoreInt32(&notReady, v)
+}
+
+// Ready returns whether the proxy routes requests.
+func Ready() bool {
+	return atomic.LoadInt32(&notReady) == 0
+}
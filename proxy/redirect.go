@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/eBay/fabio/metrics"
+)
+
+// newRedirectRoundTripper returns a round tripper which follows up to
+// max redirects of the upstream server instead of returning them to
+// the client. max is the value of the 'followredirects' route option.
+// It returns tr if max is not a positive number.
+func newRedirectRoundTripper(tr http.RoundTripper, max string) http.RoundTripper {
+	n, err := strconv.Atoi(max)
+	if err != nil || n <= 0 {
+		log.Printf("[WARN] Ignoring invalid value %q for route option followredirects", max)
+		return tr
+	}
+	return &redirectRoundTripper{tr: tr, max: n}
+}
+
+type redirectRoundTripper struct {
+	tr  http.RoundTripper
+	max int
+}
+
+func (rt *redirectRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := rt.tr.RoundTrip(r)
+
+	// requests with a body cannot be replayed
+	if r.Body != nil && r.Body != http.NoBody {
+		return resp, err
+	}
+
+	for i := 0; i < rt.max && err == nil; i++ {
+		next := redirectRequest(r, resp)
+		if next == nil {
+			return resp, nil
+		}
+		resp.Body.Close()
+		metrics.DefaultRegistry.GetCounter("redirect.followed").Inc(1)
+		r = next
+		resp, err = rt.tr.RoundTrip(r)
+	}
+	return resp, err
+}
+
+// redirectRequest returns the request for the location of the
+// redirect response or nil if the response is not a redirect.
+// Like the http.Client it changes the method to GET for 301, 302
+// and 303 unless the method is HEAD.
+func redirectRequest(r *http.Request, resp *http.Response) *http.Request {
+	method := r.Method
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther:
+		if method != "HEAD" {
+			method = "GET"
+		}
+	case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return nil
+	}
+
+	loc := resp.Header.Get("Location")
+	if loc == "" {
+		return nil
+	}
+	u, err := r.URL.Parse(loc)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		log.Printf("[WARN] Not following redirect to %q for %s", loc, r.URL)
+		return nil
+	}
+
+	out := r.Clone(r.Context())
+	out.Method = method
+	out.URL = u
+	if u.Host != r.URL.Host {
+		out.Host = ""
+	}
+	return out
+}
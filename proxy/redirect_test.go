@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/route"
+)
+
+func TestProxyFollowRedirects(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method + " internal " + r.URL.Path))
+	}))
+	defer internal.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, internal.URL+"/c", http.StatusTemporaryRedirect)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			w.Write([]byte(r.Method + " server " + r.URL.Path))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		desc   string
+		opts   string
+		method string
+		body   string
+		path   string
+		code   int
+		resp   string
+	}{
+		{"disabled", "", "GET", "", "/a", 302, ""},
+		{"relative and absolute", "followredirects=2", "GET", "", "/a", 200, "GET internal /c"},
+		{"limit reached", "followredirects=1", "GET", "", "/a", 307, ""},
+		{"loop", "followredirects=3", "GET", "", "/loop", 302, ""},
+		{"no redirect", "followredirects=3", "GET", "", "/d", 200, "GET server /d"},
+		{"not with body", "followredirects=3", "POST", "data", "/a", 302, ""},
+		{"invalid value", "followredirects=x", "GET", "", "/a", 302, ""},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture loop var
+		t.Run(tt.desc, func(t *testing.T) {
+			table := make(route.Table)
+			table.AddRouteOpts("svc", "/", server.URL, 0, nil, route.ParseOpts(tt.opts))
+			route.SetTable(table)
+
+			proxy := httptest.NewServer(NewHTTPProxy(&http.Transport{}, config.Proxy{}))
+			defer proxy.Close()
+
+			req, err := http.NewRequest(tt.method, proxy.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.body == "" {
+				req.Body = nil
+			}
+			client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			if got, want := resp.StatusCode, tt.code; got != want {
+				t.Fatalf("got code %d want %d", got, want)
+			}
+			if tt.code == 200 {
+				if got, want := string(body), tt.resp; got != want {
+					t.Fatalf("got body %q want %q", got, want)
+				}
+			}
+			if tt.code != 200 && resp.Header.Get("Location") == "" {
+				t.Fatal("got no Location header")
+			}
+		})
+	}
+}
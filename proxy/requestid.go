@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// requestID returns the value of the request id header and
+// generates a new id if the request does not have one. It returns
+// an empty string if no request id header is configured.
+func requestID(r *http.Request, header string) string {
+	if header == "" {
+		return ""
+	}
+	if id := r.Header.Get(header); id != "" {
+		return id
+	}
+	id, err := newRequestID()
+	if err != nil {
+		log.Printf("[WARN] Cannot generate request id. %s", err)
+		return ""
+	}
+	r.Header.Set(header, id)
+	return id
+}
+
+// newRequestID returns a random (version 4) UUID.
+func newRequestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// responseWriter wraps an http.ResponseWriter and records the status
+// code and the number of bytes written. It passes through the Flusher
+// and Hijacker interfaces of the underlying writer since the SSE and
+// websocket handlers depend on them. The headers in hdr replace the
+// headers of the same name in the response when the header is written.
+// If cacheControl is not empty it replaces the Cache-Control header of
+// responses with a status code below 400. Informational 1xx responses
+// are only forwarded if informational is true and are not recorded.
+// If throttle is not nil the body is written at its rate. If varyOrigin
+// is true 'Origin' is added to the Vary header for the CORS headers.
+type responseWriter struct {
+	w             http.ResponseWriter
+	hdr           http.Header
+	cacheControl  string
+	informational bool
+	throttle      *throttle
+	varyOrigin    bool
+	code          int
+	size          int64
+}
+
+func (rw *responseWriter) Header() http.Header {
+	return rw.w.Header()
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if rw.code == 0 {
+		rw.code = http.StatusOK
+		rw.setHeaders()
+	}
+	n, err := rw.throttle.write(rw.w, b)
+	rw.size += int64(n)
+	return n, err
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if isInformational(code) {
+		if rw.informational {
+			rw.w.WriteHeader(code)
+		}
+		return
+	}
+	if rw.code == 0 {
+		rw.code = code
+		rw.setHeaders()
+	}
+	rw.w.WriteHeader(code)
+}
+
+// isInformational returns true for the 1xx status codes which are
+// followed by the final response. 101 Switching Protocols is final.
+func isInformational(code int) bool {
+	return code >= 100 && code < 200 && code != http.StatusSwitchingProtocols
+}
+
+func (rw *responseWriter) setHeaders() {
+	for k, v := range rw.hdr {
+		rw.w.Header()[k] = v
+	}
+	if rw.cacheControl != "" && rw.code < 400 {
+		rw.w.Header().Set("Cache-Control", rw.cacheControl)
+	}
+	if rw.varyOrigin {
+		rw.w.Header().Add("Vary", "Origin")
+	}
+}
+
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := rw.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("not a hijacker")
+	}
+	if rw.code == 0 {
+		rw.code = http.StatusSwitchingProtocols
+	}
+	return hj.Hijack()
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.w
+}
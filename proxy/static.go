@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/eBay/fabio/route"
+)
+
+// newFileHandler returns a handler which serves the static files of a
+// file:// target. If the path of the target is a directory the request
+// path without the path of the route is served from it. If it is a file
+// the file is served for all requests, e.g. for a maintenance page.
+func newFileHandler(t *route.Target) http.Handler {
+	root := t.URL.Path
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fi, err := os.Stat(root)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if !fi.IsDir() {
+			f, err := os.Open(root)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			defer f.Close()
+			http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+			return
+		}
+
+		path := strings.TrimPrefix(r.URL.Path, t.RoutePath())
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		r2 := new(http.Request)
+		*r2 = *r
+		u := *r.URL
+		u.Path, u.RawPath = path, ""
+		r2.URL = &u
+		http.FileServer(http.Dir(root)).ServeHTTP(w, r2)
+	})
+}
+
+// errorPage is a custom page for an error status code.
+type errorPage struct {
+	contentType string
+	body        []byte
+}
+
+// errorPages contains the custom pages for the error
+// responses which are generated by the proxy.
+var errorPages map[int]*errorPage
+
+// LoadErrorPages reads the custom error pages from the files
+// by status code. The content type is derived from the file
+// extension.
+func LoadErrorPages(files map[int]string) error {
+	pages := map[int]*errorPage{}
+	for code, path := range files {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		ct := mime.TypeByExtension(filepath.Ext(path))
+		if ct == "" {
+			ct = http.DetectContentType(b)
+		}
+		pages[code] = &errorPage{contentType: ct, body: b}
+	}
+	errorPages = pages
+	return nil
+}
+
+// writeErrorPage writes the custom error page for the status code.
+// It returns false if there is none.
+func writeErrorPage(w http.ResponseWriter, code int) bool {
+	p := errorPages[code]
+	if p == nil {
+		return false
+	}
+	w.Header().Set("Content-Type", p.contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(code)
+	w.Write(p.body)
+	return true
+}
+
+// writeMaintenance writes the response for a route in maintenance
+// mode. Without a body the error page or the status text is used.
+func writeMaintenance(w http.ResponseWriter, m *route.Maintenance) {
+	if m.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(m.RetryAfter.Seconds())))
+	}
+	switch {
+	case m.Body != "":
+		w.Header().Set("Content-Type", http.DetectContentType([]byte(m.Body)))
+		w.WriteHeader(m.Status)
+		w.Write([]byte(m.Body))
+	case writeErrorPage(w, m.Status):
+	default:
+		http.Error(w, http.StatusText(m.Status), m.Status)
+	}
+}
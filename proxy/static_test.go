@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/route"
+)
+
+func TestFileTarget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "www", "css"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "www", "css", "a.css"), []byte("body{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "maintenance.html"), []byte("down"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tbl, err := route.ParseString(`
+route add static /static file://` + filepath.Join(dir, "www") + `
+route add maint /maint file://` + filepath.Join(dir, "maintenance.html") + `
+route add missing /missing file://` + filepath.Join(dir, "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.SetTable(tbl)
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/static/css/a.css", 200, "body{}"},
+		{"/static/css/b.css", 404, "404 page not found\n"},
+		{"/maint", 200, "down"},
+		{"/maint/any/path", 200, "down"},
+		{"/missing/x", 404, "404 page not found\n"},
+	}
+
+	proxy := NewHTTPProxy(&http.Transport{}, config.Proxy{})
+	for i, tt := range tests {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if got, want := rec.Code, tt.code; got != want {
+			t.Errorf("%d: %s: got code %d want %d", i, tt.path, got, want)
+		}
+		if got, want := rec.Body.String(), tt.body; got != want {
+			t.Errorf("%d: %s: got body %q want %q", i, tt.path, got, want)
+		}
+	}
+}
+
+func TestErrorPages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	page := filepath.Join(dir, "502.html")
+	if err := ioutil.WriteFile(page, []byte("<h1>oops</h1>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadErrorPages(map[int]string{502: filepath.Join(dir, "missing.html")}); err == nil {
+		t.Fatal("got nil want error for missing file")
+	}
+	if err := LoadErrorPages(map[int]string{502: page}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { errorPages = nil }()
+
+	rec := httptest.NewRecorder()
+	proxyError(rec, httptest.NewRequest("GET", "/", nil), errors.New("connection refused"))
+	if got, want := rec.Code, http.StatusBadGateway; got != want {
+		t.Fatalf("got code %d want %d", got, want)
+	}
+	if got, want := rec.Body.String(), "<h1>oops</h1>"; got != want {
+		t.Fatalf("got body %q want %q", got, want)
+	}
+	if got, want := rec.Header().Get("Content-Type"), "text/html; charset=utf-8"; got != want {
+		t.Fatalf("got content type %q want %q", got, want)
+	}
+
+	// status codes without a page are left to the caller
+	rec = httptest.NewRecorder()
+	if writeErrorPage(rec, http.StatusGatewayTimeout) {
+		t.Fatal("got true want false for 504")
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	tbl, err := route.ParseString(`
+route add a /a ` + server.URL + ` opts "maintenance maintenance-retryafter=2m"
+route add b /b ` + server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.SetTable(tbl)
+	route.SetMaintenance(route.Maintenance{Route: "/b", Status: 410, Body: "gone"})
+	defer route.ClearMaintenance("/b")
+
+	tests := []struct {
+		path       string
+		code       int
+		body       string
+		retryAfter string
+	}{
+		{"/a", 503, "Service Unavailable\n", "120"},
+		{"/b", 410, "gone", ""},
+	}
+
+	proxy := NewHTTPProxy(&http.Transport{}, config.Proxy{})
+	for i, tt := range tests {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if rec.Code != tt.code || rec.Body.String() != tt.body || rec.Header().Get("Retry-After") != tt.retryAfter {
+			t.Errorf("%d: %s: got %d %q %q want %d %q %q", i, tt.path, rec.Code, rec.Body.String(), rec.Header().Get("Retry-After"), tt.code, tt.body, tt.retryAfter)
+		}
+	}
+	if calls != 0 {
+		t.Fatalf("got %d upstream calls want 0", calls)
+	}
+}
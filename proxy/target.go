@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/eBay/fabio/route"
+)
+
+// TargetHandler returns the handler which serves the requests for a
+// target with a custom URL scheme instead of proxying them to an HTTP
+// server, e.g. to invoke a function or to publish the request to a
+// message queue. It is called for every request and should cache
+// expensive clients by target URL.
+type TargetHandler func(t *route.Target) http.Handler
+
+var (
+	targetHandlerMu sync.RWMutex
+	targetHandlers  = map[string]TargetHandler{}
+)
+
+func init() {
+	RegisterTargetHandler("file", newFileHandler)
+}
+
+// RegisterTargetHandler makes a handler available for the targets whose
+// URL has the given scheme, e.g. 'lambda' for 'lambda://fn-name', so that
+// custom builds can add their own target types without changing the
+// proxy. Handlers usually register themselves in the init function of
+// their package. RegisterTargetHandler panics if the scheme is empty, one
+// of the schemes which the proxy handles itself, the handler is nil or a
+// handler for the scheme has already been registered.
+func RegisterTargetHandler(scheme string, h TargetHandler) {
+	targetHandlerMu.Lock()
+	defer targetHandlerMu.Unlock()
+
+	switch scheme {
+	case "":
+		panic("proxy: target handler scheme is empty")
+	case "http", "https", "unix":
+		panic("proxy: target handler for built-in scheme " + scheme)
+	}
+	if h == nil {
+		panic("proxy: target handler for " + scheme + " is nil")
+	}
+	if _, dup := targetHandlers[scheme]; dup {
+		panic("proxy: target handler for " + scheme + " registered twice")
+	}
+	targetHandlers[scheme] = h
+}
+
+// targetHandler returns the registered handler for the
+// scheme or nil if there is none.
+func targetHandler(scheme string) TargetHandler {
+	targetHandlerMu.RLock()
+	defer targetHandlerMu.RUnlock()
+	return targetHandlers[scheme]
+}
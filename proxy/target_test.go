@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/route"
+)
+
+func TestTargetHandler(t *testing.T) {
+	RegisterTargetHandler("test", func(t *route.Target) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, t.URL.Host+" "+r.URL.Path)
+		})
+	})
+	defer func() {
+		delete(targetHandlers, "test")
+	}()
+
+	table, err := route.ParseString("route add svc /fn test://my-function")
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.SetTable(table)
+	defer route.SetTable(make(route.Table))
+
+	proxy := NewHTTPProxy(&http.Transport{}, config.Proxy{})
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest("GET", "/fn/x", nil))
+	if got, want := rec.Body.String(), "my-function /fn/x"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestRegisterTargetHandlerPanics(t *testing.T) {
+	h := func(t *route.Target) http.Handler { return nil }
+	tests := []struct {
+		desc   string
+		scheme string
+		h      TargetHandler
+	}{
+		{"empty scheme", "", h},
+		{"built-in scheme", "https", h},
+		{"nil handler", "test", nil},
+		{"duplicate", "file", h},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected panic")
+				}
+			}()
+			RegisterTargetHandler(tt.scheme, tt.h)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"log"
 	"net"
+	"time"
 
 	"github.com/eBay/fabio/config"
 	"github.com/eBay/fabio/route"
@@ -17,11 +18,11 @@ import (
 // without decrypting it.
 //
 // This implementation is EXPERIMENTAL in the sense that it has been tested
-// to work but is considered incomplete for production use. It needs support
-// for read and write timeouts which require replacing the io.Copy() with
-// something that can set the deadlines on the underlying connections. One
-// possible way could be to use TeeReader/TeeWriter streams which discard
-// the data and only set the deadlines. The implementation also needs a
+// to work but is considered incomplete for production use. The handshake
+// and idle timeouts of the client connection are enforced by the listener
+// and the idle timeout is also applied to the upstream connection.
+// Implementations must call HandshakeDone on connections which have it
+// after they have read the server name. The implementation also needs a
 // full integration test.
 //
 // This implementation exists to gather more real-world data to finalize
@@ -44,8 +45,8 @@ type tcpSNIProxy struct {
 func (p *tcpSNIProxy) Serve(in net.Conn) {
 	defer in.Close()
 
-	// if is shutting down, then exit
-	if ShuttingDown() {
+	// if is shutting down or has no routes yet, then exit
+	if ShuttingDown() || !Ready() {
 		return
 	}
 
@@ -71,6 +72,13 @@ func (p *tcpSNIProxy) Serve(in net.Conn) {
 		return
 	}
 
+	// switch from the handshake to the idle timeout of the listener
+	var idle time.Duration
+	if c, ok := in.(handshakeConn); ok {
+		c.HandshakeDone()
+		idle = c.IdleTimeout()
+	}
+
 	// 根据Server Name 从路由表中查找路由信息
 	t := route.GetTable().LookupHost(serverName)
 	if t == nil {
@@ -78,13 +86,24 @@ func (p *tcpSNIProxy) Serve(in net.Conn) {
 		return
 	}
 
+	if t.AccessDenied(in.RemoteAddr().String()) {
+		log.Print("[INFO] tcp+sni: Access denied for ", in.RemoteAddr(), " to ", serverName)
+		return
+	}
+
 	// 连接路由对应的真实服务器
-	out, err := net.DialTimeout("tcp", t.URL.Host, p.cfg.DialTimeout)
+	// unix:// targets are connected to the socket at the path
+	network, addr := "tcp", t.URL.Host
+	if t.URL.Scheme == "unix" {
+		network, addr = "unix", t.URL.Path
+	}
+	out, err := net.DialTimeout(network, addr, dialTimeout(t, p.cfg))
 	if err != nil {
-		log.Print("[WARN] tcp+sni: cannot connect to upstream ", t.URL.Host)
+		log.Print("[WARN] tcp+sni: cannot connect to upstream ", addr)
 		return
 	}
 	defer out.Close()
+	out = newIdleConn(out, idle)
 
 	// copy client hello
 	_, err = out.Write(data)
@@ -95,16 +114,13 @@ func (p *tcpSNIProxy) Serve(in net.Conn) {
 
 	errc := make(chan error, 2)
 	cp := func(dst io.Writer, src io.Reader) {
-		// TODO(fs): this implementation does not enforce any timeouts.
-		// for this the io.Copy will have to be replaced with something
-		// more sophisticated. Idea: use TeeReader/TeeWriter to discard
-		// the second data stream and set the deadlines.
+		// the idle timeout is enforced on both connections
 		_, err := io.Copy(dst, src)
 		errc <- err
 	}
 
-	go cp(out, in)
-	go cp(in, out)
+	go cp(throttleWriter(out, t.MaxBpsIn), in)
+	go cp(throttleWriter(in, t.MaxBpsOut), out)
 	err = <-errc
 	if err != nil && err != io.EOF {
 		log.Print("[WARN]: tcp+sni:  ", err)
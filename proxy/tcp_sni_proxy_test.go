@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/route"
+)
+
+func TestTCPSNIProxyUnixTarget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sock := filepath.Join(dir, "app.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	tbl, err := route.ParseString("route add svc example.com/ unix://" + sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.SetTable(tbl)
+	defer route.SetTable(make(route.Table))
+
+	in, client := net.Pipe()
+	defer client.Close()
+	go NewTCPSNIProxy(config.Proxy{}).Serve(in)
+
+	// the client hello must fit into the buffer of the proxy
+	go tls.Client(client, &tls.Config{ServerName: "example.com", CurvePreferences: []tls.CurveID{tls.X25519}}).Handshake()
+
+	l.(*net.UnixListener).SetDeadline(time.Now().Add(time.Second))
+	out, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	// the replayed client hello starts with a TLS handshake record
+	b := make([]byte, 1)
+	if _, err := out.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b[0], byte(0x16); got != want {
+		t.Fatalf("got record type %#x want %#x", got, want)
+	}
+}
@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// throttle limits the bandwidth of a data stream. Every chunk of
+// data moves the time at which the next chunk may pass by the time
+// the chunk needs at the configured rate. Bandwidth which is not
+// used is not saved for later so that bursts stay short.
+type throttle struct {
+	// rate is the bandwidth in bytes per second.
+	rate int64
+	next time.Time
+}
+
+// newThrottle returns a throttle for the bandwidth in bits per
+// second or nil if the bandwidth is not limited.
+func newThrottle(bps int64) *throttle {
+	if bps <= 0 {
+		return nil
+	}
+	rate := bps / 8
+	if rate < 1 {
+		rate = 1
+	}
+	return &throttle{rate: rate}
+}
+
+// chunk returns the maximum size of a chunk of data which
+// passes the throttle at once. It is the amount of data for
+// 100ms but at least one byte.
+func (t *throttle) chunk() int {
+	n := t.rate / 10
+	if n < 1 {
+		return 1
+	}
+	return int(n)
+}
+
+// wait blocks until n bytes may pass the throttle.
+func (t *throttle) wait(n int) {
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	t.next = t.next.Add(time.Duration(int64(n) * int64(time.Second) / t.rate))
+	time.Sleep(t.next.Sub(now))
+}
+
+// write writes b to w in chunks at the rate of the throttle.
+// A nil throttle writes b at once.
+func (t *throttle) write(w io.Writer, b []byte) (int, error) {
+	if t == nil {
+		return w.Write(b)
+	}
+	var written int
+	for len(b) > 0 {
+		p := b
+		if len(p) > t.chunk() {
+			p = p[:t.chunk()]
+		}
+		t.wait(len(p))
+		n, err := w.Write(p)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// throttledReader reads from the underlying reader
+// at the rate of the throttle.
+type throttledReader struct {
+	io.ReadCloser
+	t *throttle
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > r.t.chunk() {
+		p = p[:r.t.chunk()]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.t.wait(n)
+	}
+	return n, err
+}
+
+// throttledWriter writes to the underlying writer
+// at the rate of the throttle.
+type throttledWriter struct {
+	w io.Writer
+	t *throttle
+}
+
+func (w *throttledWriter) Write(b []byte) (int, error) {
+	return w.t.write(w.w, b)
+}
+
+// throttleBody limits the bandwidth of the request body
+// to bps bits per second if bps is not zero.
+func throttleBody(r *http.Request, bps int64) {
+	t := newThrottle(bps)
+	if t == nil || r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+	r.Body = &throttledReader{ReadCloser: r.Body, t: t}
+}
+
+// throttleWriter returns a writer which limits the bandwidth to
+// bps bits per second or w if bps is zero.
+func throttleWriter(w io.Writer, bps int64) io.Writer {
+	t := newThrottle(bps)
+	if t == nil {
+		return w
+	}
+	return &throttledWriter{w: w, t: t}
+}
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestThrottleWriter(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1000)
+
+	var buf bytes.Buffer
+	if w := throttleWriter(&buf, 0); w != &buf {
+		t.Fatalf("got %T want unthrottled writer", w)
+	}
+
+	// 40kbit/s is 5000 bytes per second and 1000 bytes take 200ms
+	start := time.Now()
+	n, err := throttleWriter(&buf, 40000).Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, len(data); got != want {
+		t.Fatalf("got %d bytes want %d", got, want)
+	}
+	if got, want := time.Since(start), 150*time.Millisecond; got < want {
+		t.Fatalf("wrote %d bytes in %s want at least %s", n, got, want)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("data corrupted")
+	}
+}
+
+func TestThrottledReader(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1000)
+	r := &throttledReader{ReadCloser: ioutil.NopCloser(bytes.NewReader(data)), t: newThrottle(40000)}
+
+	start := time.Now()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := time.Since(start), 150*time.Millisecond; got < want {
+		t.Fatalf("read %d bytes in %s want at least %s", len(b), got, want)
+	}
+	if !bytes.Equal(b, data) {
+		t.Fatal("data corrupted")
+	}
+}
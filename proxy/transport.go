@@ -0,0 +1,277 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eBay/fabio/cert"
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/route"
+)
+
+// NewTransport returns a transport for the upstream connections
+// which uses the timeouts and connection limits from the config.
+// HTTP and HTTPS upstream servers use separate connection pools.
+// The connections to HTTPS upstream servers use the CA bundle and
+// the client certificate from the proxy.tls options.
+func NewTransport(cfg config.Proxy) (http.RoundTripper, error) {
+	tlscfg, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	k := defaultKey(cfg)
+	https := newTransport(cfg, k)
+	https.TLSClientConfig = tlscfg
+	return &schemeTransport{http: newTransport(cfg, k), https: https}, nil
+}
+
+// newTLSConfig returns the TLS config for the connections to the
+// HTTPS upstream servers.
+func newTLSConfig(cfg config.Proxy) (*tls.Config, error) {
+	c := &tls.Config{InsecureSkipVerify: cfg.TLSSkipVerify}
+	if cfg.TLSCAPath != "" {
+		pem, err := ioutil.ReadFile(cfg.TLSCAPath)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: cannot read CA bundle: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("proxy: no certificates in CA bundle %s", cfg.TLSCAPath)
+		}
+		c.RootCAs = pool
+	}
+	if cfg.TLSCertPath != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertPath, cfg.TLSKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: cannot load client certificate: %s", err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.TLSCertSource != "" {
+		c.GetClientCertificate = clientCertificate(cfg.TLSCertSource)
+	}
+	return c, nil
+}
+
+// clientCertificate returns a function which provides the client
+// certificate from the certificate source with the given name.
+// The source is looked up on every handshake since the stores are
+// registered after the transports have been created.
+func clientCertificate(name string) func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return func(cri *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		store := cert.ClientStores[name]
+		if store == nil {
+			return nil, fmt.Errorf("proxy: unknown certificate source %q", name)
+		}
+		return store.GetClientCertificate(cri)
+	}
+}
+
+func newTransport(cfg config.Proxy, k transportKey) *http.Transport {
+	d := &net.Dialer{
+		Timeout:   k.dial,
+		KeepAlive: cfg.KeepAliveTimeout,
+	}
+	dial := d.Dial
+	if k.unix != "" {
+		dial = func(string, string) (net.Conn, error) {
+			return d.Dial("unix", k.unix)
+		}
+	}
+	return &http.Transport{
+		ResponseHeaderTimeout: k.responseHeader,
+		MaxIdleConnsPerHost:   cfg.MaxConn,
+		MaxIdleConns:          k.maxIdleConns,
+		IdleConnTimeout:       k.idleConnTimeout,
+		DisableKeepAlives:     k.disableKeepAlives,
+		Dial:                  dial,
+	}
+}
+
+// schemeTransport sends the requests for https upstream servers
+// through a different transport than the requests for http
+// upstream servers.
+type schemeTransport struct {
+	http, https http.RoundTripper
+}
+
+func (t *schemeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Scheme == "https" {
+		return t.https.RoundTrip(r)
+	}
+	return t.http.RoundTrip(r)
+}
+
+// transportKey is the key for transports with overridden
+// timeouts, connection pooling or pinned upstream certificates
+// and for the transports of unix socket targets.
+type transportKey struct {
+	dial, responseHeader time.Duration
+	maxIdleConns         int
+	idleConnTimeout      time.Duration
+	disableKeepAlives    bool
+	skipVerify           bool
+	certSource           string
+	pins                 string
+	pinOnly              bool
+	unix                 string
+}
+
+// defaultKey returns the key for the transport settings of the config.
+func defaultKey(cfg config.Proxy) transportKey {
+	return transportKey{
+		dial:              cfg.DialTimeout,
+		responseHeader:    cfg.ResponseHeaderTimeout,
+		maxIdleConns:      cfg.MaxIdleConns,
+		idleConnTimeout:   cfg.IdleConnTimeout,
+		disableKeepAlives: cfg.DisableKeepAlives,
+		skipVerify:        cfg.TLSSkipVerify,
+		certSource:        cfg.TLSCertSource,
+	}
+}
+
+// transports caches the transports for targets which override
+// the timeouts or the connection pooling of the proxy or which
+// pin the certificate of the upstream server.
+type transports struct {
+	cfg config.Proxy
+	tls *tls.Config
+
+	mu sync.Mutex
+	m  map[transportKey]http.RoundTripper
+}
+
+// newTransports creates the cache for the transports of the targets
+// which override the settings of the config. An invalid TLS config is
+// logged since it has already been reported by NewTransport.
+func newTransports(cfg config.Proxy) *transports {
+	tlscfg, err := newTLSConfig(cfg)
+	if err != nil {
+		log.Print("[ERROR] ", err)
+		tlscfg = &tls.Config{InsecureSkipVerify: cfg.TLSSkipVerify}
+	}
+	return &transports{cfg: cfg, tls: tlscfg}
+}
+
+// get returns the transport for the target or nil if the target
+// does not override any transport settings, pin the upstream
+// certificate or connect to a unix socket and the default transport
+// should be used.
+func (tr *transports) get(t *route.Target) http.RoundTripper {
+	unix := t.URL != nil && t.URL.Scheme == "unix"
+	if t.DialTimeout == 0 && t.ResponseTimeout == 0 && t.TLSPins == nil &&
+		t.MaxIdleConns == 0 && t.IdleConnTimeout == 0 && !t.DisableKeepAlives &&
+		!t.TLSSkipVerify && t.TLSCertSource == "" && !unix {
+		return nil
+	}
+
+	k := defaultKey(tr.cfg)
+	if t.DialTimeout > 0 {
+		k.dial = t.DialTimeout
+	}
+	if t.ResponseTimeout > 0 {
+		k.responseHeader = t.ResponseTimeout
+	}
+	if t.MaxIdleConns > 0 {
+		k.maxIdleConns = t.MaxIdleConns
+	}
+	if t.IdleConnTimeout > 0 {
+		k.idleConnTimeout = t.IdleConnTimeout
+	}
+	if t.DisableKeepAlives {
+		k.disableKeepAlives = true
+	}
+	if t.TLSSkipVerify {
+		k.skipVerify = true
+	}
+	if t.TLSCertSource != "" {
+		k.certSource = t.TLSCertSource
+	}
+	if t.TLSPins != nil {
+		// the separator keeps an empty list distinct from no list
+		k.pins = "," + strings.Join(t.TLSPins, ",")
+		k.pinOnly = t.TLSPinOnly
+	}
+	if unix {
+		k.unix = t.URL.Path
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.m == nil {
+		tr.m = map[transportKey]http.RoundTripper{}
+	}
+	if rt := tr.m[k]; rt != nil {
+		return rt
+	}
+	https := newTransport(tr.cfg, k)
+	https.TLSClientConfig = tr.tls.Clone()
+	https.TLSClientConfig.InsecureSkipVerify = k.skipVerify
+	if k.certSource != "" {
+		https.TLSClientConfig.GetClientCertificate = clientCertificate(k.certSource)
+	}
+	if t.TLSPins != nil {
+		// without the CA validation only the leaf certificate can be pinned
+		pinOnly := k.skipVerify || t.TLSPinOnly
+		https.TLSClientConfig.InsecureSkipVerify = pinOnly
+		https.TLSClientConfig.VerifyPeerCertificate = verifyPins(t.TLSPins, pinOnly)
+	}
+	rt := &schemeTransport{http: newTransport(tr.cfg, k), https: https}
+	tr.m[k] = rt
+	return rt
+}
+
+// verifyPins returns a function which verifies that the public key of
+// one of the certificates of the upstream server matches one of the
+// pins. Only the leaf certificate is checked if the chain has not been
+// verified since the other certificates are not bound to the connection.
+func verifyPins(pins []string, pinOnly bool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		var certs []*x509.Certificate
+		if pinOnly {
+			if len(rawCerts) == 0 {
+				return errors.New("proxy: no upstream certificate")
+			}
+			c, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			certs = append(certs, c)
+		} else {
+			for _, chain := range verifiedChains {
+				certs = append(certs, chain...)
+			}
+		}
+
+		for _, c := range certs {
+			h := sha256.Sum256(c.RawSubjectPublicKeyInfo)
+			spki := base64.StdEncoding.EncodeToString(h[:])
+			for _, pin := range pins {
+				if spki == pin {
+					return nil
+				}
+			}
+		}
+		return errors.New("proxy: upstream certificate does not match any pin")
+	}
+}
+
+// dialTimeout returns the dial timeout for the target which
+// can be overridden with the 'dialtimeout' route option.
+func dialTimeout(t *route.Target, cfg config.Proxy) time.Duration {
+	if t.DialTimeout > 0 {
+		return t.DialTimeout
+	}
+	return cfg.DialTimeout
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/route"
+)
+
+func TestSchemeTransport(t *testing.T) {
+	var got string
+	rt := func(name string) http.RoundTripper {
+		return roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			got = name
+			return &http.Response{StatusCode: 200}, nil
+		})
+	}
+	tr := &schemeTransport{http: rt("http"), https: rt("https")}
+
+	for _, scheme := range []string{"http", "https"} {
+		req := &http.Request{URL: &url.URL{Scheme: scheme, Host: "foo"}}
+		if _, err := tr.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+		if got != scheme {
+			t.Fatalf("%s: got transport %q", scheme, got)
+		}
+	}
+}
+
+func TestTransportsGet(t *testing.T) {
+	cfg := config.Proxy{MaxIdleConns: 100, IdleConnTimeout: time.Minute}
+	tr := newTransports(cfg)
+
+	if rt := tr.get(&route.Target{}); rt != nil {
+		t.Fatalf("got transport %v for target without overrides", rt)
+	}
+
+	target := &route.Target{MaxIdleConns: 5, DisableKeepAlives: true}
+	rt := tr.get(target)
+	st, ok := rt.(*schemeTransport)
+	if !ok {
+		t.Fatalf("got %T want *schemeTransport", rt)
+	}
+	for _, h := range []http.RoundTripper{st.http, st.https} {
+		ht := h.(*http.Transport)
+		if ht.MaxIdleConns != 5 || ht.IdleConnTimeout != time.Minute || !ht.DisableKeepAlives {
+			t.Fatalf("got MaxIdleConns=%d IdleConnTimeout=%s DisableKeepAlives=%v", ht.MaxIdleConns, ht.IdleConnTimeout, ht.DisableKeepAlives)
+		}
+	}
+	if st.http == st.https {
+		t.Fatal("http and https share a transport")
+	}
+	if tr.get(&route.Target{MaxIdleConns: 5, DisableKeepAlives: true}) != rt {
+		t.Fatal("transport not cached")
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
@@ -18,6 +18,9 @@ import (
 // * add X-Real-Ip, if not present
 // * ClientIPHeader != "": Set header with that name to <remote ip>
 // * TLS connection: Set header with name from `cfg.TLSHeader` to `cfg.TLSHeaderValue`
+// * ClientCertCNHeader, ClientCertSANHeader != "": Remove the header from the
+//   client and set it to the common name or the subject alternative names of
+//   the verified client certificate
 //
 func addHeaders(r *http.Request, cfg config.Proxy) error {
 	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
@@ -86,9 +89,37 @@ func addHeaders(r *http.Request, cfg config.Proxy) error {
 		r.Header.Set(cfg.TLSHeader, cfg.TLSHeaderValue)
 	}
 
+	addClientCertHeaders(r, cfg)
+
 	return nil
 }
 
+// addClientCertHeaders sets the client certificate headers to the
+// attributes of the verified client certificate. Values sent by the
+// client are always removed so that the upstream server can trust
+// the headers.
+func addClientCertHeaders(r *http.Request, cfg config.Proxy) {
+	if cfg.ClientCertCNHeader == "" && cfg.ClientCertSANHeader == "" {
+		return
+	}
+	if cfg.ClientCertCNHeader != "" {
+		r.Header.Del(cfg.ClientCertCNHeader)
+	}
+	if cfg.ClientCertSANHeader != "" {
+		r.Header.Del(cfg.ClientCertSANHeader)
+	}
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return
+	}
+	c := r.TLS.VerifiedChains[0][0]
+	if cfg.ClientCertCNHeader != "" && c.Subject.CommonName != "" {
+		r.Header.Set(cfg.ClientCertCNHeader, c.Subject.CommonName)
+	}
+	if sans := route.SubjectAltNames(c); cfg.ClientCertSANHeader != "" && len(sans) > 0 {
+		r.Header.Set(cfg.ClientCertSANHeader, strings.Join(sans, ","))
+	}
+}
+
 // target looks up a target URL for the request from the current routing table.
 func target(r *http.Request) *route.Target {
 	t := route.GetTable().Lookup(r, r.Header.Get("trace"))
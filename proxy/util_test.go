@@ -2,6 +2,8 @@ package proxy
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"net/http"
 	"testing"
 
@@ -9,6 +11,12 @@ import (
 )
 
 func TestAddHeaders(t *testing.T) {
+	clientCert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "admin-1"},
+		DNSNames:       []string{"a.example.com"},
+		EmailAddresses: []string{"ops@example.com"},
+	}
+
 	tests := []struct {
 		desc string
 		r    *http.Request
@@ -100,6 +108,20 @@ func TestAddHeaders(t *testing.T) {
 			"",
 		},
 
+		{"set client cert headers",
+			&http.Request{RemoteAddr: "1.2.3.4:5555", TLS: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{clientCert}}}},
+			config.Proxy{ClientCertCNHeader: "X-Client-CN", ClientCertSANHeader: "X-Client-SAN"},
+			http.Header{"X-Client-Cn": {"admin-1"}, "X-Client-San": {"a.example.com,ops@example.com"}},
+			"",
+		},
+
+		{"strip client cert headers from client",
+			&http.Request{RemoteAddr: "1.2.3.4:5555", Header: http.Header{"X-Client-Cn": {"admin-1"}, "X-Client-San": {"a.example.com"}}, TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{clientCert}}},
+			config.Proxy{ClientCertCNHeader: "X-Client-CN", ClientCertSANHeader: "X-Client-SAN"},
+			http.Header{"X-Client-Cn": {}, "X-Client-San": {}},
+			"",
+		},
+
 		{"set X-Forwarded-For for wss",
 			&http.Request{RemoteAddr: "1.2.3.4:5555", Header: http.Header{"Upgrade": {"websocket"}}, TLS: &tls.ConnectionState{}},
 			config.Proxy{},
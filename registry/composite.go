@@ -0,0 +1,213 @@
+package registry
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultGateTimeout is the time the composite backend waits for
+// the routes of all backends before it pushes the first merged
+// routes if registry.timeout is zero.
+const defaultGateTimeout = 10 * time.Second
+
+// composite combines multiple backends into one. The routes of all
+// backends are merged and the backends are ordered by precedence.
+// A route for a prefix from an earlier backend hides all routes for
+// the same prefix from the later ones. The manual overrides are
+// stored in the first backend.
+type composite struct {
+	backends []Backend
+
+	// gate is the time to wait for the routes of all backends
+	// before the first merged routes are pushed.
+	gate time.Duration
+}
+
+// newComposite creates a backend for each of the comma separated
+// names and combines them. The first merged routes are pushed when
+// all backends have reported their routes or after timeout.
+func newComposite(names []string, timeout time.Duration, create func(name string) (Backend, error)) (Backend, error) {
+	c := &composite{gate: timeout}
+	if c.gate <= 0 {
+		c.gate = defaultGateTimeout
+	}
+	for _, name := range names {
+		b, err := create(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		c.backends = append(c.backends, b)
+	}
+	return c, nil
+}
+
+func (c *composite) Register() error {
+	for _, b := range c.backends {
+		if err := b.Register(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *composite) Deregister() error {
+	var err error
+	for _, b := range c.backends {
+		if e := b.Deregister(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (c *composite) ReadManual() (value string, version uint64, err error) {
+	return c.backends[0].ReadManual()
+}
+
+func (c *composite) WriteManual(value string, version uint64) (ok bool, err error) {
+	return c.backends[0].WriteManual(value, version)
+}
+
+// WatchServices pushes the merged routes of all backends whenever
+// one of them changes. The first merged routes are held back until
+// every backend has reported its routes or the gate timeout expires
+// so that the routes of a slow backend with a higher precedence are
+// not replaced by the routes of a faster one on startup.
+func (c *composite) WatchServices() chan string {
+	type update struct {
+		i   int
+		cfg string
+	}
+
+	updates := make(chan update)
+	for i, b := range c.backends {
+		go func(i int, svc chan string) {
+			for cfg := range svc {
+				updates <- update{i, cfg}
+			}
+		}(i, b.WatchServices())
+	}
+
+	svc := make(chan string)
+	go func() {
+		cfgs := make([]string, len(c.backends))
+		reported := make([]bool, len(c.backends))
+		pending := len(c.backends)
+		gate := time.After(c.gate)
+		for {
+			select {
+			case u := <-updates:
+				cfgs[u.i] = u.cfg
+				if !reported[u.i] {
+					reported[u.i] = true
+					pending--
+				}
+				if gate != nil && pending > 0 {
+					continue
+				}
+				gate = nil
+				svc <- mergeServices(cfgs)
+
+			case <-gate:
+				gate = nil
+				log.Printf("[WARN] registry: Not all backends reported their routes within %s", c.gate)
+				svc <- mergeServices(cfgs)
+			}
+		}
+	}()
+	return svc
+}
+
+func (c *composite) WatchManual() chan string {
+	return c.backends[0].WatchManual()
+}
+
+// mergeServices merges the route commands of the backends in order of
+// precedence. 'route add' commands for a prefix which has already been
+// added by a previous backend are dropped. 'route del' and 'route weight'
+// commands for such a prefix are dropped as well so that a backend
+// cannot change the routes of a backend with a higher precedence. A
+// 'route del <svc>' command without a prefix is limited to the prefixes
+// which the backend has added itself. All other commands are passed
+// through unchanged.
+func mergeServices(cfgs []string) string {
+	var lines []string
+	seen := map[string]bool{}
+	for _, cfg := range cfgs {
+		var added []string
+		for _, line := range strings.Split(cfg, "\n") {
+			cmd, svc, src := routeCmd(line)
+			switch {
+			case cmd == "add":
+				if seen[src] {
+					continue
+				}
+				added = append(added, src)
+
+			case (cmd == "del" || cmd == "weight") && src != "":
+				if seen[src] {
+					continue
+				}
+
+			case cmd == "del" && len(seen) > 0:
+				for _, src := range uniq(added) {
+					lines = append(lines, "route del "+svc+" "+src)
+				}
+				continue
+			}
+			lines = append(lines, line)
+		}
+		for _, src := range added {
+			seen[src] = true
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// routeCmd returns the command, the service and the source of a 'route
+// add', 'route del' or 'route weight' command. The source is empty for
+// 'route del <svc>'. cmd is empty for all other lines.
+func routeCmd(line string) (cmd, svc, src string) {
+	f := strings.Fields(line)
+	if len(f) < 3 || f[0] != "route" {
+		return "", "", ""
+	}
+	switch f[1] {
+	case "add":
+		if len(f) < 4 {
+			return "", "", ""
+		}
+		return "add", f[2], f[3]
+	case "del":
+		if len(f) < 4 {
+			return "del", f[2], ""
+		}
+		return "del", f[2], f[3]
+	case "weight":
+		// route weight <src> weight <w> tags "..."
+		if len(f) > 3 && f[3] == "weight" {
+			return "weight", "", f[2]
+		}
+		if len(f) < 4 {
+			return "", "", ""
+		}
+		return "weight", f[2], f[3]
+	}
+	return "", "", ""
+}
+
+// uniq returns the sorted unique values of s.
+func uniq(s []string) []string {
+	m := map[string]bool{}
+	var u []string
+	for _, v := range s {
+		if !m[v] {
+			m[v] = true
+			u = append(u, v)
+		}
+	}
+	sort.Strings(u)
+	return u
+}
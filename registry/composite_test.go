@@ -0,0 +1,144 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/config"
+)
+
+type fakeBackend struct {
+	name string
+	svc  chan string
+	man  chan string
+}
+
+func (b *fakeBackend) Register() error                          { return nil }
+func (b *fakeBackend) Deregister() error                        { return nil }
+func (b *fakeBackend) ReadManual() (string, uint64, error)      { return b.name, 1, nil }
+func (b *fakeBackend) WriteManual(string, uint64) (bool, error) { return true, nil }
+func (b *fakeBackend) WatchServices() chan string               { return b.svc }
+func (b *fakeBackend) WatchManual() chan string                 { return b.man }
+
+func TestMergeServices(t *testing.T) {
+	tests := []struct {
+		desc string
+		cfgs []string
+		out  string
+	}{
+		{"empty", []string{"", ""}, "\n"},
+		{
+			"disjoint routes",
+			[]string{"route add a /a http://1/", "route add b /b http://2/"},
+			"route add a /a http://1/\nroute add b /b http://2/",
+		},
+		{
+			"first backend wins",
+			[]string{
+				"route add a /a http://1/\nroute add a /a http://2/",
+				"route add b /a http://3/\nroute add b /b http://4/",
+			},
+			"route add a /a http://1/\nroute add a /a http://2/\nroute add b /b http://4/",
+		},
+		{
+			"other commands pass through",
+			[]string{"route add a /a http://1/\nroute weight a /a weight 0.5\nroute del a", "route add b /b http://2/\nroute weight /b weight 0.1 tags \"x\"\nroute del b /b http://2/"},
+			"route add a /a http://1/\nroute weight a /a weight 0.5\nroute del a\nroute add b /b http://2/\nroute weight /b weight 0.1 tags \"x\"\nroute del b /b http://2/",
+		},
+		{
+			"lower backend cannot change hidden routes",
+			[]string{
+				"route add a /a http://1/",
+				"route add b /b http://2/\nroute weight a /a weight 0.5\nroute weight /a weight 0.5 tags \"x\"\nroute del a /a\nroute del a /a http://1/",
+			},
+			"route add a /a http://1/\nroute add b /b http://2/",
+		},
+		{
+			"service delete limited to own prefixes",
+			[]string{
+				"route add a /a http://1/",
+				"route add a /c http://3/\nroute add a /b http://2/\nroute del a\nroute add a /d http://4/",
+			},
+			"route add a /a http://1/\nroute add a /c http://3/\nroute add a /b http://2/\nroute del a /b\nroute del a /c\nroute add a /d http://4/",
+		},
+	}
+
+	for _, tt := range tests {
+		if got, want := mergeServices(tt.cfgs), tt.out; got != want {
+			t.Errorf("%s: got %q want %q", tt.desc, got, want)
+		}
+	}
+}
+
+func TestComposite(t *testing.T) {
+	defer func(f map[string]Factory) { factories = f }(factories)
+	factories = map[string]Factory{}
+
+	a := &fakeBackend{name: "a", svc: make(chan string), man: make(chan string)}
+	b := &fakeBackend{name: "b", svc: make(chan string), man: make(chan string)}
+	Register("a", func(*config.Registry) (Backend, error) { return a, nil })
+	Register("b", func(*config.Registry) (Backend, error) { return b, nil })
+
+	be, err := New("a, b", &config.Registry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _, _ := be.ReadManual(); v != "a" {
+		t.Fatalf("got manual from %q want a", v)
+	}
+
+	svc := be.WatchServices()
+	recv := func() string {
+		select {
+		case cfg := <-svc:
+			return cfg
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+			return ""
+		}
+	}
+
+	// the first routes are pushed when all backends have reported
+	b.svc <- "route add b /b http://2/"
+	select {
+	case cfg := <-svc:
+		t.Fatalf("got %q before all backends reported", cfg)
+	case <-time.After(50 * time.Millisecond):
+	}
+	a.svc <- "route add a /b http://1/"
+	if got, want := recv(), "route add a /b http://1/"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	b.svc <- "route add b /c http://2/"
+	if got, want := recv(), "route add a /b http://1/\nroute add b /c http://2/"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+
+	if _, err := New("a,c", &config.Registry{}); err == nil {
+		t.Fatal("got nil want error for unknown backend")
+	}
+}
+
+func TestCompositeGateTimeout(t *testing.T) {
+	a := &fakeBackend{name: "a", svc: make(chan string), man: make(chan string)}
+	b := &fakeBackend{name: "b", svc: make(chan string), man: make(chan string)}
+	backends := map[string]Backend{"a": a, "b": b}
+	be, err := newComposite([]string{"a", "b"}, 50*time.Millisecond, func(name string) (Backend, error) {
+		return backends[name], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the routes are pushed after the timeout if a backend does not report
+	svc := be.WatchServices()
+	b.svc <- "route add b /b http://2/"
+	select {
+	case cfg := <-svc:
+		if got, want := cfg, "\nroute add b /b http://2/"; got != want {
+			t.Fatalf("got %q want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+}
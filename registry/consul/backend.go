@@ -6,10 +6,17 @@ import (
 
 	"github.com/eBay/fabio/config"
 	"github.com/eBay/fabio/registry"
+	"github.com/eBay/fabio/route"
 
 	"github.com/hashicorp/consul/api"
 )
 
+func init() {
+	registry.Register("consul", func(cfg *config.Registry) (registry.Backend, error) {
+		return NewBackend(&cfg.Consul)
+	})
+}
+
 // be is an implementation of a registry backend for consul.
 type be struct {
 	c     *api.Client
@@ -20,7 +27,7 @@ type be struct {
 
 func NewBackend(cfg *config.Consul) (registry.Backend, error) {
 	// create a reusable client
-	c, err := api.NewClient(&api.Config{Address: cfg.Addr, Scheme: cfg.Scheme, Token: cfg.Token})
+	c, err := config.NewConsulClient(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -31,6 +38,14 @@ func NewBackend(cfg *config.Consul) (registry.Backend, error) {
 		return nil, err
 	}
 
+	// query the configured datacenter instead of the one of the agent
+	if cfg.Datacenter != "" {
+		dc = cfg.Datacenter
+	}
+
+	// the 'local' strategy prefers the targets of this datacenter
+	route.SetLocalDatacenter(dc)
+
 	// we're good
 	log.Printf("[INFO] consul: Connecting to %q in datacenter %q", cfg.Addr, dc)
 	return &be{c: c, dc: dc, cfg: cfg}, nil
@@ -42,7 +57,7 @@ func (b *be) Register() error {
 		return nil
 	}
 
-	service, err := serviceRegistration(b.cfg.ServiceAddr, b.cfg.ServiceName, b.cfg.ServiceTags, b.cfg.CheckInterval, b.cfg.CheckTimeout)
+	service, err := serviceRegistration(b.cfg)
 	if err != nil {
 		return err
 	}
@@ -80,10 +95,24 @@ func (b *be) WriteManual(value string, version uint64) (ok bool, err error) {
  */
 func (b *be) WatchServices() chan string {
 	log.Printf("[INFO] consul: Using dynamic routes")
-	log.Printf("[INFO] consul: Using tag prefix %q", b.cfg.TagPrefix)
+	for _, p := range b.cfg.TagPrefixes {
+		if p.Opts != "" {
+			log.Printf("[INFO] consul: Using tag prefix %q with options %q", p.Prefix, p.Opts)
+			continue
+		}
+		log.Printf("[INFO] consul: Using tag prefix %q", p.Prefix)
+	}
+	if b.cfg.MetaPrefix != "" {
+		log.Printf("[INFO] consul: Using meta prefix %q", b.cfg.MetaPrefix)
+	}
 
 	svc := make(chan string)
-	go watchServices(b.c, b.cfg.TagPrefix, b.cfg.ServiceStatus, svc)
+	if len(b.cfg.Datacenters) > 0 {
+		log.Printf("[INFO] consul: Watching services in datacenters %v", b.cfg.Datacenters)
+		go watchDatacenters(b.c, b.cfg, svc)
+		return svc
+	}
+	go watchServices(b.c, b.cfg, b.dc, svc)
 	return svc
 }
 
@@ -0,0 +1,72 @@
+package consul
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/eBay/fabio/config"
+	"github.com/hashicorp/consul/api"
+)
+
+// ReadConfig returns the fabio configuration values which are stored
+// as one key per property below the config KV path, e.g.
+// /fabio/settings/proxy.strategy, and the index of the KV data.
+func ReadConfig(cfg *config.Consul) (map[string]string, uint64, error) {
+	client, err := config.NewConsulClient(cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	return listConfig(client, cfg.ConfigKVPath, 0)
+}
+
+// WatchConfig monitors the config KV path for changes after the
+// given index and sends the new configuration values.
+func WatchConfig(cfg *config.Consul, index uint64) (chan map[string]string, error) {
+	client, err := config.NewConsulClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	kv := make(chan map[string]string)
+	go func() {
+		lastIndex := index
+		for {
+			values, index, err := listConfig(client, cfg.ConfigKVPath, lastIndex)
+			if err != nil {
+				log.Printf("[WARN] consul: Error fetching config from %s. %v", cfg.ConfigKVPath, err)
+				time.Sleep(time.Second)
+				continue
+			}
+			if index != lastIndex {
+				log.Printf("[INFO] consul: Config in %s changed to #%d", cfg.ConfigKVPath, index)
+				kv <- values
+				lastIndex = index
+			}
+		}
+	}()
+	return kv, nil
+}
+
+func listConfig(client *api.Client, path string, waitIndex uint64) (map[string]string, uint64, error) {
+	prefix := strings.TrimPrefix(path, "/")
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	q := &api.QueryOptions{RequireConsistent: true, WaitIndex: waitIndex}
+	pairs, meta, err := client.KV().List(prefix, q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	values := map[string]string{}
+	for _, p := range pairs {
+		key := strings.TrimPrefix(p.Key, prefix)
+		if key == "" || strings.Contains(key, "/") {
+			continue
+		}
+		values[key] = strings.TrimSpace(string(p.Value))
+	}
+	return values, meta.LastIndex, nil
+}
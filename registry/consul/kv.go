@@ -1,15 +1,18 @@
 package consul
 
 import (
+	"fmt"
 	"log"
 	"strings"
 	"time"
 
+	"github.com/eBay/fabio/registry"
 	"github.com/hashicorp/consul/api"
 )
 
 // watchKV monitors a key in the KV store for changes.
 // The intended use case is to add additional route commands to the routing table.
+// Failed requests are retried with an increasing delay.
 func watchKV(client *api.Client, path string, config chan string) {
 	var lastIndex uint64
 	var lastValue string
@@ -17,10 +20,10 @@ func watchKV(client *api.Client, path string, config chan string) {
 	for {
 		value, index, err := getKV(client, path, lastIndex)
 		if err != nil {
-			log.Printf("[WARN] consul: Error fetching config from %s. %v", path, err)
-			time.Sleep(time.Second)
+			time.Sleep(registry.ReportError("consul.kv", fmt.Errorf("cannot fetch config from %s. %v", path, err)))
 			continue
 		}
+		registry.ReportOK("consul.kv")
 
 		if value != lastValue || index != lastIndex {
 			log.Printf("[INFO] consul: Manual config changed to #%d", index)
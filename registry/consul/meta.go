@@ -0,0 +1,81 @@
+package consul
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// metaOptions returns the weight and the route options for a service
+// instance from its node and service metadata.
+//
+// Keys with the prefix set the route option of the same name without
+// the prefix, e.g. 'fabio-dialtimeout=2s' sets 'dialtimeout=2s'. The
+// 'weight' key sets the fixed weight of the target. The service metadata
+// overrides the node metadata. The other keys of the service metadata
+// are added as 'meta.<key>' options to show them in the UI. Values which
+// cannot be used in a route command are ignored.
+func metaOptions(nodeMeta, serviceMeta map[string]string, prefix string) (weight string, opts []string) {
+	m := map[string]string{}
+	if prefix != "" {
+		for _, meta := range []map[string]string{nodeMeta, serviceMeta} {
+			for k, v := range meta {
+				if strings.HasPrefix(k, prefix) && len(k) > len(prefix) {
+					m[k[len(prefix):]] = v
+				}
+			}
+		}
+	}
+	for k, v := range serviceMeta {
+		if prefix == "" || !strings.HasPrefix(k, prefix) {
+			m["meta."+k] = v
+		}
+	}
+
+	if w, ok := m["weight"]; ok {
+		delete(m, "weight")
+		if n, err := strconv.ParseFloat(w, 64); err == nil && n > 0 && n <= 1 {
+			weight = w
+		} else {
+			log.Printf("[WARN] consul: Ignoring invalid weight %q in %sweight", w, prefix)
+		}
+	}
+
+	for k, v := range m {
+		if strings.ContainsAny(k+v, " \t\r\n\"") {
+			log.Printf("[WARN] consul: Ignoring metadata %q with spaces or quotes", k)
+			continue
+		}
+		if v == "" {
+			opts = append(opts, k)
+			continue
+		}
+		opts = append(opts, k+"="+v)
+	}
+	sort.Strings(opts)
+	return weight, opts
+}
+
+// mergeOpts adds the options from the metadata to the options from
+// the tag unless the tag already sets them.
+func mergeOpts(tagOpts string, metaOpts []string) string {
+	set := map[string]bool{}
+	for _, o := range strings.Fields(tagOpts) {
+		set[optKey(o)] = true
+	}
+	opts := strings.Fields(tagOpts)
+	for _, o := range metaOpts {
+		if !set[optKey(o)] {
+			opts = append(opts, o)
+		}
+	}
+	return strings.Join(opts, " ")
+}
+
+func optKey(opt string) string {
+	if i := strings.Index(opt, "="); i >= 0 {
+		return opt[:i]
+	}
+	return opt
+}
@@ -0,0 +1,76 @@
+package consul
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMetaOptions(t *testing.T) {
+	tests := []struct {
+		desc          string
+		node, service map[string]string
+		prefix        string
+		weight        string
+		opts          []string
+	}{
+		{
+			desc: "no meta",
+		},
+		{
+			desc:    "service meta",
+			service: map[string]string{"version": "1.2", "team": "web"},
+			prefix:  "fabio-",
+			opts:    []string{"meta.team=web", "meta.version=1.2"},
+		},
+		{
+			desc:    "prefixed options",
+			node:    map[string]string{"fabio-dialtimeout": "5s", "fabio-maxconn": "10", "rack": "a"},
+			service: map[string]string{"fabio-dialtimeout": "2s", "fabio-disablekeepalives": "", "fabio-weight": "0.25"},
+			prefix:  "fabio-",
+			weight:  "0.25",
+			opts:    []string{"dialtimeout=2s", "disablekeepalives", "maxconn=10"},
+		},
+		{
+			desc:    "invalid values",
+			service: map[string]string{"fabio-weight": "2", "owner": "John Doe", "fabio-": "x", "fabio-maxconn": "a\"b"},
+			prefix:  "fabio-",
+		},
+		{
+			desc:    "no prefix",
+			node:    map[string]string{"fabio-maxconn": "10"},
+			service: map[string]string{"fabio-weight": "0.5"},
+			opts:    []string{"meta.fabio-weight=0.5"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			weight, opts := metaOptions(tt.node, tt.service, tt.prefix)
+			if got, want := weight, tt.weight; got != want {
+				t.Errorf("got weight %q want %q", got, want)
+			}
+			if got, want := opts, tt.opts; !reflect.DeepEqual(got, want) {
+				t.Errorf("got opts %q want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestMergeOpts(t *testing.T) {
+	tests := []struct {
+		tag  string
+		meta []string
+		opts string
+	}{
+		{"", nil, ""},
+		{"proto=https", nil, "proto=https"},
+		{"", []string{"maxconn=10"}, "maxconn=10"},
+		{"strip=/foo maxconn=5", []string{"maxconn=10", "meta.team=web"}, "strip=/foo maxconn=5 meta.team=web"},
+		{"disablekeepalives", []string{"disablekeepalives=x"}, "disablekeepalives"},
+	}
+	for _, tt := range tests {
+		if got, want := mergeOpts(tt.tag, tt.meta), tt.opts; got != want {
+			t.Errorf("%q %q: got %q want %q", tt.tag, tt.meta, got, want)
+		}
+	}
+}
@@ -4,22 +4,42 @@ import (
 	"log"
 	"os"
 	"strings"
+
+	"github.com/eBay/fabio/config"
 )
 
-// parseURLPrefixTag expects an input in the form of 'tag-host/path'
+// parseTag parses the tag with the first matching prefix and returns
+// the default route options of the prefix in defaults.
+func parseTag(s string, prefixes []config.TagPrefix, env map[string]string) (host, path, opts, defaults string, ok bool) {
+	for _, p := range prefixes {
+		if host, path, opts, ok = parseURLPrefixTag(s, p.Prefix, env); ok {
+			return host, path, opts, p.Opts, true
+		}
+	}
+	return "", "", "", "", false
+}
+
+// parseURLPrefixTag expects an input in the form of 'tag-host/path opts'
 // and returns the lower cased host plus the path unaltered if the
-// prefix matches the tag.
-func parseURLPrefixTag(s, prefix string, env map[string]string) (host, path string, ok bool) {
+// prefix matches the tag. The optional route options follow the path
+// separated by whitespace and are returned as a space separated list.
+func parseURLPrefixTag(s, prefix string, env map[string]string) (host, path, opts string, ok bool) {
 	s = strings.TrimSpace(s)
 	if !strings.HasPrefix(s, prefix) {
-		return "", "", false
+		return "", "", "", false
 	}
 
 	// split host/path
 	p := strings.SplitN(s[len(prefix):], "/", 2)
 	if len(p) != 2 {
 		log.Printf("[WARN] consul: Invalid %s tag %q - You need to have a trailing slash!", prefix, s)
-		return "", "", false
+		return "", "", "", false
+	}
+
+	// split path and options
+	var pathopts []string
+	if f := strings.Fields(p[1]); len(f) > 0 {
+		p[1], pathopts = f[0], f[1:]
 	}
 
 	// expand $x or ${x} to env[x] or ""
@@ -34,6 +54,7 @@ func parseURLPrefixTag(s, prefix string, env map[string]string) (host, path stri
 
 	host = strings.ToLower(expand(strings.TrimSpace(p[0])))
 	path = "/" + expand(strings.TrimSpace(p[1]))
+	opts = strings.Join(pathopts, " ")
 
-	return host, path, true
+	return host, path, opts, true
 }
@@ -1,6 +1,10 @@
 package consul
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/eBay/fabio/config"
+)
 
 func TestParseTag(t *testing.T) {
 	prefix := "p-"
@@ -9,6 +13,7 @@ func TestParseTag(t *testing.T) {
 		env  map[string]string
 		host string
 		path string
+		opts string
 		ok   bool
 	}{
 		{tag: "p", host: "", path: "", ok: false},
@@ -24,6 +29,8 @@ func TestParseTag(t *testing.T) {
 		{tag: "p-bar/foo/foo", host: "bar", path: "/foo/foo", ok: true},
 		{tag: "p-www.bar.com/foo/foo", host: "www.bar.com", path: "/foo/foo", ok: true},
 		{tag: "p-WWW.BAR.COM/foo/foo", host: "www.bar.com", path: "/foo/foo", ok: true},
+		{tag: "p-/foo dialtimeout=2s", host: "", path: "/foo", opts: "dialtimeout=2s", ok: true},
+		{tag: "p-bar/foo  dialtimeout=2s   responsetimeout=30s ", host: "bar", path: "/foo", opts: "dialtimeout=2s responsetimeout=30s", ok: true},
 		{
 			tag:  "p-$x/$y",
 			host: "", path: "/",
@@ -49,7 +56,7 @@ func TestParseTag(t *testing.T) {
 	}
 
 	for i, tt := range tests {
-		host, path, ok := parseURLPrefixTag(tt.tag, prefix, tt.env)
+		host, path, opts, ok := parseURLPrefixTag(tt.tag, prefix, tt.env)
 		if got, want := ok, tt.ok; got != want {
 			t.Errorf("%d: got %v want %v", i, got, want)
 		}
@@ -62,5 +69,42 @@ func TestParseTag(t *testing.T) {
 		if got, want := path, tt.path; got != want {
 			t.Errorf("%d: got path %q want %q", i, got, want)
 		}
+		if got, want := opts, tt.opts; got != want {
+			t.Errorf("%d: got opts %q want %q", i, got, want)
+		}
+	}
+}
+
+func TestParseTagPrefixes(t *testing.T) {
+	prefixes := []config.TagPrefix{
+		{Prefix: "public-internal-", Opts: "maxconn=5"},
+		{Prefix: "internal-", Opts: "allow=ip:10.0.0.0/8 maxconn=10"},
+		{Prefix: "urlprefix-"},
+	}
+	tests := []struct {
+		tag                  string
+		path, opts, defaults string
+		ok                   bool
+	}{
+		{tag: "foo-/a", ok: false},
+		{tag: "urlprefix-/a", path: "/a", ok: true},
+		{tag: "internal-/a maxconn=20", path: "/a", opts: "maxconn=20", defaults: "allow=ip:10.0.0.0/8 maxconn=10", ok: true},
+		{tag: "public-internal-/a", path: "/a", defaults: "maxconn=5", ok: true},
+	}
+
+	for _, tt := range tests {
+		_, path, opts, defaults, ok := parseTag(tt.tag, prefixes, nil)
+		if got, want := ok, tt.ok; got != want {
+			t.Fatalf("%s: got %v want %v", tt.tag, got, want)
+		}
+		if got, want := path, tt.path; got != want {
+			t.Errorf("%s: got path %q want %q", tt.tag, got, want)
+		}
+		if got, want := opts, tt.opts; got != want {
+			t.Errorf("%s: got opts %q want %q", tt.tag, got, want)
+		}
+		if got, want := defaults, tt.defaults; got != want {
+			t.Errorf("%s: got defaults %q want %q", tt.tag, got, want)
+		}
 	}
 }
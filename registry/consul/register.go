@@ -11,9 +11,24 @@ import (
 	"time"
 
 	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/registry"
 	"github.com/hashicorp/consul/api"
 )
 
+// registration is the service registration of fabio. It extends the
+// registration of the consul client with the check options which the
+// client does not support.
+type registration struct {
+	api.AgentServiceRegistration
+	Check *check
+}
+
+// check is the health check of the service registration.
+type check struct {
+	api.AgentServiceCheck
+	DeregisterCriticalServiceAfter string `json:",omitempty"`
+}
+
 // register keeps a service registered in consul.
 //
 // When a value is sent in the dereg channel the service is deregistered from
@@ -23,8 +38,12 @@ import (
 //    dereg <- true // trigger deregistration
 //    <-dereg       // wait for completion
 //
-func register(c *api.Client, service *api.AgentServiceRegistration) (dereg chan bool) {
+// For a TTL check the status of the check is updated in an interval of
+// a third of the TTL. It is passing while the registry backend is
+// healthy.
+func register(c *api.Client, service *registration) (dereg chan bool) {
 	var serviceID string
+	var updateTTL func()
 
 	registered := func() bool {
 		if serviceID == "" {
@@ -39,7 +58,7 @@ func register(c *api.Client, service *api.AgentServiceRegistration) (dereg chan
 	}
 
 	register := func() {
-		if err := c.Agent().ServiceRegister(service); err != nil {
+		if _, err := c.Raw().Write("/v1/agent/service/register", service, nil, nil); err != nil {
 			log.Printf("[ERROR] consul: Cannot register fabio in consul. %s", err)
 			return
 		}
@@ -47,9 +66,31 @@ func register(c *api.Client, service *api.AgentServiceRegistration) (dereg chan
 		log.Printf("[INFO] consul: Registered fabio with id %q", service.ID)
 		log.Printf("[INFO] consul: Registered fabio with address %q", service.Address)
 		log.Printf("[INFO] consul: Registered fabio with tags %q", strings.Join(service.Tags, ","))
-		log.Printf("[INFO] consul: Registered fabio with health check to %q", service.Check.HTTP)
+		if service.Check.TTL != "" {
+			log.Printf("[INFO] consul: Registered fabio with TTL health check of %s", service.Check.TTL)
+		} else {
+			log.Printf("[INFO] consul: Registered fabio with health check to %q", service.Check.HTTP)
+		}
 
 		serviceID = service.ID
+		updateTTL()
+	}
+
+	updateTTL = func() {
+		if service.Check.TTL == "" || serviceID == "" {
+			return
+		}
+		status, output := "passing", "OK"
+		if h := registry.BackendHealth(); !h.Healthy {
+			var errs []string
+			for _, e := range h.Errors {
+				errs = append(errs, fmt.Sprintf("%s failed %d times. %s", e.Name, e.Failures, e.Error))
+			}
+			status, output = "critical", strings.Join(errs, "\n")
+		}
+		if err := c.Agent().UpdateTTL("service:"+serviceID, output, status); err != nil {
+			log.Printf("[ERROR] consul: Cannot update TTL check. %s", err)
+		}
 	}
 
 	deregister := func() {
@@ -59,6 +100,14 @@ func register(c *api.Client, service *api.AgentServiceRegistration) (dereg chan
 
 	dereg = make(chan bool)
 	go func() {
+		// the ticker only fires for TTL checks
+		var ttl <-chan time.Time
+		if d, err := time.ParseDuration(service.Check.TTL); err == nil {
+			t := time.NewTicker(ttlInterval(d))
+			defer t.Stop()
+			ttl = t.C
+		}
+
 		register()
 		for {
 			select {
@@ -66,6 +115,8 @@ func register(c *api.Client, service *api.AgentServiceRegistration) (dereg chan
 				deregister()
 				dereg <- true
 				return
+			case <-ttl:
+				updateTTL()
 			case <-time.After(time.Second):
 				if !registered() {
 					register()
@@ -76,12 +127,20 @@ func register(c *api.Client, service *api.AgentServiceRegistration) (dereg chan
 	return dereg
 }
 
-func serviceRegistration(addr, name string, tags []string, interval, timeout time.Duration) (*api.AgentServiceRegistration, error) {
+// ttlInterval returns the interval in which a TTL check is updated.
+func ttlInterval(ttl time.Duration) time.Duration {
+	if d := ttl / 3; d > time.Second {
+		return d
+	}
+	return time.Second
+}
+
+func serviceRegistration(cfg *config.Consul) (*registration, error) {
 	hostname, err := os.Hostname()
 	if err != nil {
 		return nil, err
 	}
-	ipstr, portstr, err := net.SplitHostPort(addr)
+	ipstr, portstr, err := net.SplitHostPort(cfg.ServiceAddr)
 	if err != nil {
 		return nil, err
 	}
@@ -101,24 +160,30 @@ func serviceRegistration(addr, name string, tags []string, interval, timeout tim
 		}
 	}
 
-	serviceID := fmt.Sprintf("%s-%s-%d", name, hostname, port)
+	serviceID := fmt.Sprintf("%s-%s-%d", cfg.ServiceName, hostname, port)
 
-	checkURL := fmt.Sprintf("http://%s:%d/health", ip, port)
-	if ip.To16() != nil {
-		checkURL = fmt.Sprintf("http://[%s]:%d/health", ip, port)
+	c := &check{}
+	switch cfg.CheckType {
+	case "ttl":
+		c.TTL = cfg.CheckTTL.String()
+	default:
+		c.HTTP = "http://" + net.JoinHostPort(ip.String(), portstr) + "/health"
+		c.Interval = cfg.CheckInterval.String()
+		c.Timeout = cfg.CheckTimeout.String()
+	}
+	if cfg.CheckDeregisterCriticalServiceAfter > 0 {
+		c.DeregisterCriticalServiceAfter = cfg.CheckDeregisterCriticalServiceAfter.String()
 	}
 
-	service := &api.AgentServiceRegistration{
-		ID:      serviceID,
-		Name:    name,
-		Address: ip.String(),
-		Port:    port,
-		Tags:    tags,
-		Check: &api.AgentServiceCheck{
-			HTTP:     checkURL,
-			Interval: interval.String(),
-			Timeout:  timeout.String(),
+	service := &registration{
+		AgentServiceRegistration: api.AgentServiceRegistration{
+			ID:      serviceID,
+			Name:    cfg.ServiceName,
+			Address: ip.String(),
+			Port:    port,
+			Tags:    cfg.ServiceTags,
 		},
+		Check: c,
 	}
 
 	return service, nil
@@ -0,0 +1,92 @@
+package consul
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/config"
+)
+
+func TestServiceRegistrationCheck(t *testing.T) {
+	tests := []struct {
+		desc  string
+		cfg   config.Consul
+		check map[string]string
+	}{
+		{
+			desc: "http",
+			cfg:  config.Consul{ServiceAddr: "1.2.3.4:9998", CheckType: "http", CheckInterval: time.Second, CheckTimeout: 3 * time.Second},
+			check: map[string]string{
+				"HTTP":     "http://1.2.3.4:9998/health",
+				"Interval": "1s",
+				"Timeout":  "3s",
+			},
+		},
+		{
+			desc: "http ipv6",
+			cfg:  config.Consul{ServiceAddr: "[::1]:9998", CheckType: "http", CheckInterval: time.Second, CheckTimeout: 3 * time.Second},
+			check: map[string]string{
+				"HTTP":     "http://[::1]:9998/health",
+				"Interval": "1s",
+				"Timeout":  "3s",
+			},
+		},
+		{
+			desc: "ttl with deregister",
+			cfg:  config.Consul{ServiceAddr: "1.2.3.4:9998", CheckType: "ttl", CheckTTL: 15 * time.Second, CheckDeregisterCriticalServiceAfter: time.Minute},
+			check: map[string]string{
+				"TTL":                            "15s",
+				"DeregisterCriticalServiceAfter": "1m0s",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tt.cfg.ServiceName = "fabio"
+			reg, err := serviceRegistration(&tt.cfg)
+			if err != nil {
+				t.Fatalf("got %v want nil", err)
+			}
+			b, err := json.Marshal(reg)
+			if err != nil {
+				t.Fatalf("got %v want nil", err)
+			}
+			var v struct {
+				Name  string
+				Check map[string]interface{}
+			}
+			if err := json.Unmarshal(b, &v); err != nil {
+				t.Fatalf("got %v want nil", err)
+			}
+			if got, want := v.Name, "fabio"; got != want {
+				t.Errorf("got name %q want %q", got, want)
+			}
+			for k, want := range tt.check {
+				if got := v.Check[k]; got != want {
+					t.Errorf("got %s %v want %q", k, got, want)
+				}
+			}
+			for k, val := range v.Check {
+				if _, ok := tt.check[k]; !ok && val != "" {
+					t.Errorf("got unexpected %s %v", k, val)
+				}
+			}
+		})
+	}
+}
+
+func TestTTLInterval(t *testing.T) {
+	tests := []struct {
+		ttl, d time.Duration
+	}{
+		{15 * time.Second, 5 * time.Second},
+		{2 * time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		if got, want := ttlInterval(tt.ttl), tt.d; got != want {
+			t.Errorf("%s: got %s want %s", tt.ttl, got, want)
+		}
+	}
+}
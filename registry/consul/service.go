@@ -10,32 +10,49 @@ import (
 	"strings"
 	"time"
 
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/registry"
 	"github.com/hashicorp/consul/api"
 )
 
 // watchServices monitors the consul health checks and creates a new configuration
-// on every change.
-func watchServices(client *api.Client, tagPrefix string, status []string, config chan string) {
+// on every change. If registry.consul.dcs.tag is set the targets are tagged
+// with 'dc=<dc>'. Failed requests are retried with an increasing delay and
+// the last configuration is kept until consul is available again.
+func watchServices(client *api.Client, cc *config.Consul, dc string, config chan string) {
 	var lastIndex uint64
 
+	watch := "consul.health"
+	if dc != "" {
+		watch += "." + dc
+	}
+
 	for {
-		q := &api.QueryOptions{RequireConsistent: true, WaitIndex: lastIndex}
+		q := &api.QueryOptions{RequireConsistent: true, WaitIndex: lastIndex, Datacenter: dc}
 		checks, meta, err := client.Health().State("any", q)
 		if err != nil {
-			log.Printf("[WARN] consul: Error fetching health state. %v", err)
-			time.Sleep(time.Second)
+			time.Sleep(registry.ReportError(watch, fmt.Errorf("cannot fetch health state. %v", err)))
+			continue
+		}
+
+		cfg, err := servicesConfig(client, cc, dc, passingServices(checks, cc.ServiceStatus))
+		if err != nil {
+			time.Sleep(registry.ReportError(watch, err))
 			continue
 		}
+		registry.ReportOK(watch)
 
-		log.Printf("[INFO] consul: Health changed to #%d", meta.LastIndex)
-		config <- servicesConfig(client, passingServices(checks, status), tagPrefix)
+		log.Printf("[INFO] consul: Health in %s changed to #%d", dc, meta.LastIndex)
+		config <- cfg
 		lastIndex = meta.LastIndex
 	}
 }
 
 // servicesConfig determines which service instances have passing health checks
 // and then finds the ones which have tags with the right prefix to build the config from.
-func servicesConfig(client *api.Client, checks []*api.HealthCheck, tagPrefix string) string {
+// It returns an error if the catalog of a service cannot be fetched since the
+// config would not contain the routes of the service.
+func servicesConfig(client *api.Client, cc *config.Consul, dc string, checks []*api.HealthCheck) (string, error) {
 	// map service name to list of service passing for which the health check is ok
 	m := map[string]map[string]bool{}
 	for _, check := range checks {
@@ -49,33 +66,37 @@ func servicesConfig(client *api.Client, checks []*api.HealthCheck, tagPrefix str
 
 	var config []string
 	for name, passing := range m {
-		cfg := serviceConfig(client, name, passing, tagPrefix)
+		cfg, err := serviceConfig(client, cc, dc, name, passing)
+		if err != nil {
+			return "", err
+		}
 		config = append(config, cfg...)
 	}
 
 	// sort config in reverse order to sort most specific config to the top
 	sort.Sort(sort.Reverse(sort.StringSlice(config)))
 
-	return strings.Join(config, "\n")
+	return strings.Join(config, "\n"), nil
+}
+
+// catalogService is a service instance from the catalog with the
+// node and service metadata which the consul client does not support.
+type catalogService struct {
+	api.CatalogService
+	NodeMeta    map[string]string
+	ServiceMeta map[string]string
 }
 
 // serviceConfig constructs the config for all good instances of a single service.
-func serviceConfig(client *api.Client, name string, passing map[string]bool, tagPrefix string) (config []string) {
+func serviceConfig(client *api.Client, cc *config.Consul, dc, name string, passing map[string]bool) (config []string, err error) {
 	if name == "" || len(passing) == 0 {
-		return nil
-	}
-
-	dc, err := datacenter(client)
-	if err != nil {
-		log.Printf("[WARN] consul: Error getting datacenter. %s", err)
-		return nil
+		return nil, nil
 	}
 
-	q := &api.QueryOptions{RequireConsistent: true}
-	svcs, _, err := client.Catalog().Service(name, "", q)
-	if err != nil {
-		log.Printf("[WARN] consul: Error getting catalog service %s. %v", name, err)
-		return nil
+	q := &api.QueryOptions{RequireConsistent: true, Datacenter: dc}
+	var svcs []*catalogService
+	if _, err := client.Raw().Query("/v1/catalog/service/"+name, &svcs, q); err != nil {
+		return nil, fmt.Errorf("cannot fetch catalog service %s. %v", name, err)
 	}
 
 	env := map[string]string{
@@ -89,8 +110,10 @@ func serviceConfig(client *api.Client, name string, passing map[string]bool, tag
 			continue
 		}
 
+		weight, metaOpts := metaOptions(svc.NodeMeta, svc.ServiceMeta, cc.MetaPrefix)
+
 		for _, tag := range svc.ServiceTags {
-			if host, path, ok := parseURLPrefixTag(tag, tagPrefix, env); ok {
+			if host, path, opts, defaults, ok := parseTag(tag, cc.TagPrefixes, env); ok {
 				name, addr, port := svc.ServiceName, svc.ServiceAddress, svc.ServicePort
 
 				// use consul node address if service address is not set
@@ -105,9 +128,76 @@ func serviceConfig(client *api.Client, name string, passing map[string]bool, tag
 
 				addrport := net.JoinHostPort(addr, strconv.Itoa(port))
 
-				config = append(config, fmt.Sprintf("route add %s %s%s http://%s/ tags %q", name, host, path, addrport, strings.Join(svc.ServiceTags, ",")))
+				tags := svc.ServiceTags
+				if cc.TagDatacenter {
+					tags = append(tags[:len(tags):len(tags)], "dc="+dc)
+				}
+
+				// the options of the tag override the ones from the
+				// metadata which override the defaults of the prefix
+				opts = mergeOpts(mergeOpts(opts, metaOpts), strings.Fields(defaults))
+
+				cfg := fmt.Sprintf("route add %s %s%s %s://%s/", name, host, path, targetScheme(opts), addrport)
+				if weight != "" {
+					cfg += " weight " + weight
+				}
+				cfg += fmt.Sprintf(" tags %q", strings.Join(tags, ","))
+				if opts != "" {
+					cfg += fmt.Sprintf(" opts %q", opts)
+				}
+				config = append(config, cfg)
 			}
 		}
 	}
-	return config
+	return config, nil
+}
+
+// targetScheme returns 'https' if the route options contain
+// 'proto=https' and 'http' otherwise.
+func targetScheme(opts string) string {
+	for _, o := range strings.Fields(opts) {
+		if o == "proto=https" {
+			return "https"
+		}
+	}
+	return "http"
+}
+
+// watchDatacenters watches the services in all datacenters and
+// merges them into a single configuration on every change.
+func watchDatacenters(client *api.Client, cc *config.Consul, config chan string) {
+	type dcConfig struct {
+		dc, config string
+	}
+
+	updates := make(chan dcConfig)
+	for _, dc := range cc.Datacenters {
+		go func(dc string) {
+			c := make(chan string)
+			go watchServices(client, cc, dc, c)
+			for cfg := range c {
+				updates <- dcConfig{dc, cfg}
+			}
+		}(dc)
+	}
+
+	m := map[string]string{}
+	for u := range updates {
+		m[u.dc] = u.config
+		config <- mergeConfigs(m)
+	}
+}
+
+// mergeConfigs merges the configurations of multiple datacenters
+// and sorts the most specific routes to the top.
+func mergeConfigs(m map[string]string) string {
+	var config []string
+	for _, cfg := range m {
+		if cfg == "" {
+			continue
+		}
+		config = append(config, strings.Split(cfg, "\n")...)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(config)))
+	return strings.Join(config, "\n")
 }
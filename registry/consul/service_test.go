@@ -0,0 +1,32 @@
+package consul
+
+import "testing"
+
+func TestMergeConfigs(t *testing.T) {
+	m := map[string]string{
+		"dc1": "route add a /a http://1.1.1.1:1/\nroute add b /b http://1.1.1.1:2/",
+		"dc2": "route add a /a http://2.2.2.2:1/",
+		"dc3": "",
+	}
+	got := mergeConfigs(m)
+	want := "route add b /b http://1.1.1.1:2/\nroute add a /a http://2.2.2.2:1/\nroute add a /a http://1.1.1.1:1/"
+	if got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestTargetScheme(t *testing.T) {
+	tests := []struct {
+		opts, scheme string
+	}{
+		{"", "http"},
+		{"strip=/foo", "http"},
+		{"proto=http", "http"},
+		{"strip=/foo proto=https", "https"},
+	}
+	for _, tt := range tests {
+		if got, want := targetScheme(tt.opts), tt.scheme; got != want {
+			t.Errorf("%q: got %q want %q", tt.opts, got, want)
+		}
+	}
+}
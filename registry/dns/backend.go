@@ -0,0 +1,134 @@
+// Package dns implements a registry backend which creates the
+// routes from the targets of DNS SRV records, e.g. from Mesos-DNS,
+// SkyDNS or Kubernetes headless services.
+//
+// The records are resolved periodically since the resolver of
+// the standard library does not provide the TTL of the records.
+package dns
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/registry"
+)
+
+func init() {
+	registry.Register("dns", func(cfg *config.Registry) (registry.Backend, error) {
+		return NewBackend(&cfg.DNS)
+	})
+}
+
+// lookupSRV resolves the SRV record with the given name.
+// It is a variable so that it can be replaced in tests.
+var lookupSRV = func(name string) ([]*net.SRV, error) {
+	_, addrs, err := net.LookupSRV("", "", name)
+	return addrs, err
+}
+
+type be struct {
+	cfg *config.DNS
+}
+
+func NewBackend(cfg *config.DNS) (registry.Backend, error) {
+	if len(cfg.SRV) == 0 {
+		return nil, fmt.Errorf("dns: no SRV records configured in registry.dns.srv")
+	}
+	return &be{cfg: cfg}, nil
+}
+
+func (b *be) Register() error {
+	return nil
+}
+
+func (b *be) Deregister() error {
+	return nil
+}
+
+func (b *be) ReadManual() (value string, version uint64, err error) {
+	return "", 0, nil
+}
+
+func (b *be) WriteManual(value string, version uint64) (ok bool, err error) {
+	return false, nil
+}
+
+func (b *be) WatchServices() chan string {
+	log.Printf("[INFO] dns: Resolving %d SRV records every %s", len(b.cfg.SRV), b.cfg.Refresh)
+
+	svc := make(chan string, 1)
+	go watch(svc, b.cfg.SRV, b.cfg.Refresh)
+	return svc
+}
+
+func (b *be) WatchManual() chan string {
+	return make(chan string)
+}
+
+// watch resolves the SRV records every refresh interval
+// and pushes the routes if they have changed.
+func watch(svc chan string, srvs []config.SRV, refresh time.Duration) {
+	// do not refresh more often than once a second to prevent busy loops
+	if refresh < time.Second {
+		refresh = time.Second
+	}
+
+	var last string
+	for {
+		next := routes(srvs)
+		if next != last {
+			svc <- next
+			last = next
+		}
+		time.Sleep(refresh)
+	}
+}
+
+// routes returns the route commands for the targets of all records.
+// Only the targets with the lowest priority of a record are used.
+// Records which cannot be resolved do not have any routes.
+func routes(srvs []config.SRV) string {
+	var cmds []string
+	for _, srv := range srvs {
+		addrs, err := lookupSRV(srv.Name)
+		if err != nil {
+			registry.ReportError("dns."+srv.Name, fmt.Errorf("cannot resolve %s. %s", srv.Name, err))
+			continue
+		}
+		registry.ReportOK("dns." + srv.Name)
+		if len(addrs) == 0 {
+			continue
+		}
+
+		prio := addrs[0].Priority
+		for _, a := range addrs {
+			if a.Priority < prio {
+				prio = a.Priority
+			}
+		}
+
+		var targets []string
+		for _, a := range addrs {
+			if a.Priority != prio {
+				continue
+			}
+			host := strings.TrimSuffix(a.Target, ".")
+			targets = append(targets, fmt.Sprintf("%s://%s/", srv.Scheme, net.JoinHostPort(host, fmt.Sprint(a.Port))))
+		}
+		sort.Strings(targets)
+
+		for _, t := range targets {
+			cmd := fmt.Sprintf("route add %s %s %s", srv.Service, srv.Prefix, t)
+			if srv.Opts != "" {
+				cmd += fmt.Sprintf(" opts %q", srv.Opts)
+			}
+			cmds = append(cmds, cmd)
+		}
+	}
+	return strings.Join(cmds, "\n")
+}
@@ -0,0 +1,46 @@
+package dns
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/eBay/fabio/config"
+)
+
+func TestRoutes(t *testing.T) {
+	defer func(fn func(string) ([]*net.SRV, error)) { lookupSRV = fn }(lookupSRV)
+	lookupSRV = func(name string) ([]*net.SRV, error) {
+		switch name {
+		case "_http._tcp.web":
+			return []*net.SRV{
+				{Target: "b.example.com.", Port: 8080, Priority: 10},
+				{Target: "a.example.com.", Port: 8080, Priority: 10},
+				{Target: "backup.example.com.", Port: 8080, Priority: 20},
+			}, nil
+		case "_https._tcp.api":
+			return []*net.SRV{{Target: "10.1.2.3", Port: 443}}, nil
+		default:
+			return nil, errors.New("no such host")
+		}
+	}
+
+	srvs := []config.SRV{
+		{Name: "_http._tcp.web", Service: "web", Prefix: "/web", Scheme: "http"},
+		{Name: "_https._tcp.api", Service: "api", Prefix: "api.com/", Scheme: "https", Opts: "dialtimeout=2s"},
+		{Name: "_http._tcp.missing", Service: "missing", Prefix: "/missing", Scheme: "http"},
+	}
+
+	want := "route add web /web http://a.example.com:8080/\n" +
+		"route add web /web http://b.example.com:8080/\n" +
+		`route add api api.com/ https://10.1.2.3:443/ opts "dialtimeout=2s"`
+	if got := routes(srvs); got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestNewBackend(t *testing.T) {
+	if _, err := NewBackend(&config.DNS{}); err == nil {
+		t.Fatal("got nil want error")
+	}
+}
@@ -0,0 +1,72 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/eBay/fabio/config"
+)
+
+// Factory creates a registry backend from the registry configuration.
+type Factory func(cfg *config.Registry) (Backend, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Register makes a registry backend available under the given name
+// for the registry.backend option. Backends usually register
+// themselves in the init function of their package. Register
+// panics if the name is empty, the factory is nil or a backend
+// with the same name has already been registered.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if name == "" {
+		panic("registry: backend name is empty")
+	}
+	if f == nil {
+		panic("registry: factory for backend " + name + " is nil")
+	}
+	if _, dup := factories[name]; dup {
+		panic("registry: backend " + name + " registered twice")
+	}
+	factories[name] = f
+}
+
+// New creates the registry backend with the given name. A comma
+// separated list of names creates a composite backend which merges
+// the routes of all backends with decreasing precedence.
+func New(name string, cfg *config.Registry) (Backend, error) {
+	if strings.Contains(name, ",") {
+		return newComposite(strings.Split(name, ","), cfg.Timeout, func(name string) (Backend, error) {
+			return New(name, cfg)
+		})
+	}
+
+	mu.RLock()
+	f := factories[name]
+	mu.RUnlock()
+
+	if f == nil {
+		return nil, fmt.Errorf("registry: unknown backend %q. Valid backends are %s", name, strings.Join(Backends(), ", "))
+	}
+	return f(cfg)
+}
+
+// Backends returns the sorted names of the registered backends.
+func Backends() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var names []string
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,65 @@
+package registry
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/eBay/fabio/config"
+)
+
+func TestRegister(t *testing.T) {
+	defer func(f map[string]Factory) { factories = f }(factories)
+	factories = map[string]Factory{}
+
+	var got *config.Registry
+	Register("b", func(cfg *config.Registry) (Backend, error) { got = cfg; return nil, nil })
+	Register("a", func(*config.Registry) (Backend, error) { return nil, errors.New("fail") })
+
+	if got, want := Backends(), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+
+	cfg := &config.Registry{Backend: "b"}
+	if _, err := New("b", cfg); err != nil {
+		t.Fatalf("got %v want nil", err)
+	}
+	if got != cfg {
+		t.Fatalf("got config %p want %p", got, cfg)
+	}
+	if _, err := New("a", cfg); err == nil || err.Error() != "fail" {
+		t.Fatalf("got %v want fail", err)
+	}
+	if _, err := New("c", cfg); err == nil {
+		t.Fatal("got nil want error for unknown backend")
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	defer func(f map[string]Factory) { factories = f }(factories)
+	factories = map[string]Factory{}
+
+	f := func(*config.Registry) (Backend, error) { return nil, nil }
+	Register("a", f)
+
+	tests := []struct {
+		desc string
+		name string
+		f    Factory
+	}{
+		{"empty name", "", f},
+		{"nil factory", "b", nil},
+		{"duplicate", "a", f},
+	}
+
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: got no panic", tt.desc)
+				}
+			}()
+			Register(tt.name, tt.f)
+		}()
+	}
+}
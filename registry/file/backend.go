@@ -1,20 +1,116 @@
-// Package file implements a simple file based registry
-// backend which reads the routes from a file once.
+// Package file implements a file based registry backend which
+// reads the routes from a file or from all *.routes files in a
+// directory. The routes are reloaded when they change if a
+// refresh interval is configured.
 package file
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/eBay/fabio/config"
 	"github.com/eBay/fabio/registry"
-	"github.com/eBay/fabio/registry/static"
 )
 
-func NewBackend(filename string) (registry.Backend, error) {
-	data, err := ioutil.ReadFile(filename)
-	if err != nil {
-		log.Println("[ERROR] Cannot read routes from ", filename)
+func init() {
+	registry.Register("file", func(cfg *config.Registry) (registry.Backend, error) {
+		return NewBackend(&cfg.File)
+	})
+}
+
+type be struct {
+	path    string
+	refresh time.Duration
+}
+
+func NewBackend(cfg *config.File) (registry.Backend, error) {
+	if _, err := readRoutes(cfg.Path); err != nil {
+		log.Println("[ERROR] Cannot read routes from ", cfg.Path)
 		return nil, err
 	}
-	return static.NewBackend(string(data))
+	return &be{path: cfg.Path, refresh: cfg.Refresh}, nil
+}
+
+func (b *be) Register() error {
+	return nil
+}
+
+func (b *be) Deregister() error {
+	return nil
+}
+
+func (b *be) ReadManual() (value string, version uint64, err error) {
+	return "", 0, nil
+}
+
+func (b *be) WriteManual(value string, version uint64) (ok bool, err error) {
+	return false, nil
+}
+
+func (b *be) WatchServices() chan string {
+	ch := make(chan string, 1)
+	go watch(ch, b.refresh, b.path)
+	return ch
+}
+
+func (b *be) WatchManual() chan string {
+	return make(chan string)
+}
+
+// watch pushes the routes from path and checks them for
+// changes every refresh interval. The routes are read only
+// once if refresh is not positive.
+func watch(ch chan string, refresh time.Duration, path string) {
+	var last string
+	for {
+		next, err := readRoutes(path)
+		if err != nil {
+			registry.ReportError("file", fmt.Errorf("cannot read routes from %s. %s", path, err))
+		} else {
+			registry.ReportOK("file")
+			if next != last {
+				ch <- next
+				last = next
+			}
+		}
+
+		if refresh <= 0 {
+			return
+		}
+		time.Sleep(refresh)
+	}
+}
+
+// readRoutes returns the content of the file or the concatenated
+// content of all *.routes files in lexical order if path is a
+// directory.
+func readRoutes(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		data, err := ioutil.ReadFile(path)
+		return string(data), err
+	}
+
+	files, err := filepath.Glob(filepath.Join(path, "*.routes"))
+	if err != nil {
+		return "", err
+	}
+
+	var routes []string
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+		routes = append(routes, string(data))
+	}
+	return strings.Join(routes, "\n"), nil
 }
@@ -0,0 +1,88 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/config"
+)
+
+func TestReadRoutes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, data string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("b.routes", "route add b /b http://2/")
+	write("a.routes", "route add a /a http://1/")
+	write("c.txt", "route add c /c http://3/")
+
+	got, err := readRoutes(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "route add a /a http://1/\nroute add b /b http://2/"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+
+	got, err = readRoutes(filepath.Join(dir, "c.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "route add c /c http://3/"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+
+	if _, err := readRoutes(filepath.Join(dir, "x")); err == nil {
+		t.Fatal("got nil want error")
+	}
+}
+
+func TestWatchServices(t *testing.T) {
+	f, err := ioutil.TempFile("", "fabio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	write := func(data string) {
+		if err := ioutil.WriteFile(f.Name(), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("route add a /a http://1/")
+
+	b, err := NewBackend(&config.File{Path: f.Name(), Refresh: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := b.WatchServices()
+
+	recv := func() string {
+		select {
+		case routes := <-svc:
+			return routes
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+			return ""
+		}
+	}
+
+	if got, want := recv(), "route add a /a http://1/"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	write("route add b /b http://2/")
+	if got, want := recv(), "route add b /b http://2/"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
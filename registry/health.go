@@ -0,0 +1,123 @@
+package registry
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eBay/fabio/metrics"
+)
+
+// minBackoff and maxBackoff limit the time a watch waits
+// before it retries a failed request to the backend.
+var (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// WatchError describes a watch of a backend which fails.
+type WatchError struct {
+	Name     string    `json:"name"`
+	Error    string    `json:"error"`
+	Since    time.Time `json:"since"`
+	Failures int       `json:"failures"`
+}
+
+// Health describes whether the backends deliver updates.
+type Health struct {
+	Healthy bool         `json:"healthy"`
+	Errors  []WatchError `json:"errors,omitempty"`
+}
+
+var (
+	healthMu sync.Mutex
+	failing  = map[string]*WatchError{}
+)
+
+// ReportError records that the named watch of a backend has failed
+// and returns the time the watch should wait before it retries. The
+// wait time doubles with every consecutive failure up to maxBackoff.
+// The routing table is not changed while the backend fails so that
+// fabio routes with the last known routes.
+func ReportError(name string, err error) time.Duration {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	e := failing[name]
+	if e == nil {
+		e = &WatchError{Name: name, Since: time.Now()}
+		failing[name] = e
+	}
+	e.Error = err.Error()
+	e.Failures++
+
+	metrics.DefaultRegistry.GetCounter("registry." + metrics.Clean(name) + ".errors").Inc(1)
+	updateStale()
+
+	wait := backoff(e.Failures)
+	if e.Failures == 1 {
+		log.Printf("[WARN] registry: %s failed. Keeping the last known routes. Retrying in %s. %s", name, wait, err)
+	} else {
+		log.Printf("[WARN] registry: %s failed %d times since %s. Routes may be stale. Retrying in %s. %s",
+			name, e.Failures, e.Since.Format(time.RFC3339), wait, err)
+	}
+	return wait
+}
+
+// ReportOK records that the named watch of a backend has succeeded.
+func ReportOK(name string) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	e := failing[name]
+	if e == nil {
+		return
+	}
+	delete(failing, name)
+	updateStale()
+	log.Printf("[INFO] registry: %s recovered after %d failures in %s", name, e.Failures, time.Since(e.Since).Round(time.Second))
+}
+
+// BackendHealth returns whether all backends deliver updates and the
+// errors of the failing watches.
+func BackendHealth() Health {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	h := Health{Healthy: len(failing) == 0}
+	for _, e := range failing {
+		h.Errors = append(h.Errors, *e)
+	}
+	sort.Slice(h.Errors, func(i, j int) bool { return h.Errors[i].Name < h.Errors[j].Name })
+	return h
+}
+
+// updateStale sets the registry.stale gauge to the number of seconds
+// since the oldest watch started failing. It must be called with
+// healthMu held.
+func updateStale() {
+	var since time.Time
+	for _, e := range failing {
+		if since.IsZero() || e.Since.Before(since) {
+			since = e.Since
+		}
+	}
+	var stale int64
+	if !since.IsZero() {
+		stale = int64(time.Since(since) / time.Second)
+	}
+	metrics.DefaultRegistry.GetGauge("registry.stale").Update(stale)
+}
+
+// backoff returns the wait time after n consecutive failures.
+func backoff(n int) time.Duration {
+	d := minBackoff
+	for i := 1; i < n && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
@@ -0,0 +1,62 @@
+package registry
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		n    int
+		want time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{5, 16 * time.Second},
+		{6, 30 * time.Second},
+		{100, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.n); got != tt.want {
+			t.Errorf("backoff(%d): got %s want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestBackendHealth(t *testing.T) {
+	defer func() { failing = map[string]*WatchError{} }()
+
+	if h := BackendHealth(); !h.Healthy || len(h.Errors) != 0 {
+		t.Fatalf("got %+v want healthy", h)
+	}
+
+	if got, want := ReportError("b", errors.New("down")), time.Second; got != want {
+		t.Fatalf("got wait %s want %s", got, want)
+	}
+	if got, want := ReportError("b", errors.New("still down")), 2*time.Second; got != want {
+		t.Fatalf("got wait %s want %s", got, want)
+	}
+	ReportError("a", errors.New("down"))
+
+	h := BackendHealth()
+	if h.Healthy || len(h.Errors) != 2 {
+		t.Fatalf("got %+v want two errors", h)
+	}
+	if e := h.Errors[1]; e.Name != "b" || e.Failures != 2 || e.Error != "still down" {
+		t.Fatalf("got %+v", e)
+	}
+
+	ReportOK("a")
+	ReportOK("b")
+	ReportOK("c")
+	if h := BackendHealth(); !h.Healthy {
+		t.Fatalf("got %+v want healthy", h)
+	}
+
+	// the backoff starts over after a success
+	if got, want := ReportError("b", errors.New("down")), time.Second; got != want {
+		t.Fatalf("got wait %s want %s", got, want)
+	}
+}
@@ -0,0 +1,139 @@
+// Package remote implements a registry backend which periodically
+// fetches the routing table from an HTTP or HTTPS server.
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/registry"
+)
+
+func init() {
+	registry.Register("remote", func(cfg *config.Registry) (registry.Backend, error) {
+		return NewBackend(&cfg.Remote)
+	})
+}
+
+type be struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+}
+
+func NewBackend(cfg *config.Remote) (registry.Backend, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("remote: url must start with http:// or https://")
+	}
+
+	// do not refresh more often than once a second to prevent busy loops
+	refresh := cfg.Refresh
+	if refresh < time.Second {
+		refresh = time.Second
+	}
+
+	log.Printf("[INFO] remote: Fetching routes from %s every %s", u.Redacted(), refresh)
+	return &be{url: cfg.URL, refresh: refresh, client: &http.Client{Timeout: refresh}}, nil
+}
+
+func (b *be) Register() error {
+	return nil
+}
+
+func (b *be) Deregister() error {
+	return nil
+}
+
+func (b *be) ReadManual() (value string, version uint64, err error) {
+	return "", 0, nil
+}
+
+func (b *be) WriteManual(value string, version uint64) (ok bool, err error) {
+	return false, nil
+}
+
+func (b *be) WatchServices() chan string {
+	ch := make(chan string, 1)
+	go b.watch(ch)
+	return ch
+}
+
+func (b *be) WatchManual() chan string {
+	return make(chan string)
+}
+
+// watch fetches the routes every refresh interval
+// and pushes them if they have changed.
+func (b *be) watch(ch chan string) {
+	var f fetcher
+	var last string
+	for {
+		next, changed, err := f.fetch(b.client, b.url)
+		if err != nil {
+			// retry with backoff but at least every refresh interval
+			wait := registry.ReportError("remote", fmt.Errorf("cannot fetch routes. %s", err))
+			if wait > b.refresh {
+				wait = b.refresh
+			}
+			time.Sleep(wait)
+			continue
+		}
+		registry.ReportOK("remote")
+		if changed && next != last {
+			ch <- next
+			last = next
+		}
+		time.Sleep(b.refresh)
+	}
+}
+
+// fetcher fetches a document and uses the ETag and Last-Modified
+// headers of the previous response for conditional requests.
+type fetcher struct {
+	etag, lastModified string
+}
+
+// fetch returns the document and true or false if the document
+// has not been modified since the last request.
+func (f *fetcher) fetch(client *http.Client, url string) (string, bool, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", false, err
+	}
+	if f.etag != "" {
+		req.Header.Set("If-None-Match", f.etag)
+	}
+	if f.lastModified != "" {
+		req.Header.Set("If-Modified-Since", f.lastModified)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return "", false, err
+		}
+		f.etag, f.lastModified = resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+		return string(data), true, nil
+	case http.StatusNotModified:
+		return "", false, nil
+	default:
+		return "", false, fmt.Errorf("%s returned %s", req.URL.Redacted(), resp.Status)
+	}
+}
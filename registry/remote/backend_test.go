@@ -0,0 +1,75 @@
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/config"
+)
+
+func TestFetch(t *testing.T) {
+	routes := "route add a /a http://1/"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(routes))
+	}))
+	defer srv.Close()
+
+	var f fetcher
+	data, changed, err := f.fetch(http.DefaultClient, srv.URL)
+	if err != nil || !changed || data != routes {
+		t.Fatalf("got %q, %v, %v want %q, true, nil", data, changed, err, routes)
+	}
+
+	data, changed, err = f.fetch(http.DefaultClient, srv.URL)
+	if err != nil || changed || data != "" {
+		t.Fatalf("got %q, %v, %v want not modified", data, changed, err)
+	}
+
+	var g fetcher
+	if _, _, err := g.fetch(http.DefaultClient, srv.URL+"/missing"); err == nil {
+		t.Fatal("got nil want error")
+	}
+}
+
+func TestWatchServices(t *testing.T) {
+	routes := make(chan string, 1)
+	routes <- "route add a /a http://1/"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(<-routes))
+	}))
+	defer srv.Close()
+
+	b, err := NewBackend(&config.Remote{URL: srv.URL, Refresh: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := b.WatchServices()
+
+	select {
+	case got := <-svc:
+		if want := "route add a /a http://1/"; got != want {
+			t.Fatalf("got %q want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+func TestNewBackend(t *testing.T) {
+	for _, u := range []string{"", "foo", "ftp://host/routes", "://"} {
+		if _, err := NewBackend(&config.Remote{URL: u}); err == nil {
+			t.Errorf("%q: got nil want error", u)
+		}
+	}
+}
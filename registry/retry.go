@@ -0,0 +1,21 @@
+package registry
+
+import (
+	"log"
+	"time"
+)
+
+// Retry calls fn every interval until it succeeds or the timeout has
+// expired and returns the last error. fn is called once if the timeout
+// is zero.
+func Retry(timeout, interval time.Duration, fn func() error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := fn()
+		if err == nil || !time.Now().Add(interval).Before(deadline) {
+			return err
+		}
+		log.Printf("[WARN] Registry backend not available. Retrying in %s. %s", interval, err)
+		time.Sleep(interval)
+	}
+}
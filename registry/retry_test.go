@@ -0,0 +1,46 @@
+package registry
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	errFail := errors.New("fail")
+
+	tests := []struct {
+		desc    string
+		timeout time.Duration
+		ok      int
+		calls   int
+		err     error
+	}{
+		{"no timeout", 0, 2, 1, errFail},
+		{"success", time.Second, 1, 1, nil},
+		{"success after retry", time.Second, 3, 3, nil},
+		{"timeout", 50 * time.Millisecond, 100, 0, errFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			calls := 0
+			err := Retry(tt.timeout, 10*time.Millisecond, func() error {
+				if calls++; calls < tt.ok {
+					return errFail
+				}
+				return nil
+			})
+			if got, want := err, tt.err; got != want {
+				t.Fatalf("got %v want %v", got, want)
+			}
+			// the number of calls until the timeout depends on the scheduler
+			if tt.calls == 0 && (calls < 2 || calls > 5) {
+				t.Fatalf("got %d calls want 2-5", calls)
+			}
+			if got, want := calls, tt.calls; tt.calls > 0 && got != want {
+				t.Fatalf("got %d calls want %d", got, want)
+			}
+		})
+	}
+}
@@ -2,15 +2,23 @@
 // backend which uses statically configured routes.
 package static
 
-import "github.com/eBay/fabio/registry"
-
-type be struct{}
+import (
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/registry"
+)
+
+func init() {
+	registry.Register("static", func(cfg *config.Registry) (registry.Backend, error) {
+		return NewBackend(cfg.Static.Routes)
+	})
+}
 
-var staticRoutes string
+type be struct {
+	routes string
+}
 
 func NewBackend(routes string) (registry.Backend, error) {
-	staticRoutes = routes
-	return &be{}, nil
+	return &be{routes: routes}, nil
 }
 
 func (b *be) Register() error {
@@ -31,7 +39,7 @@ func (b *be) WriteManual(value string, version uint64) (ok bool, err error) {
 
 func (b *be) WatchServices() chan string {
 	ch := make(chan string, 1)
-	ch <- staticRoutes
+	ch <- b.routes
 	return ch
 }
 
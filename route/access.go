@@ -0,0 +1,87 @@
+package route
+
+import (
+	"log"
+	"net"
+	"strings"
+)
+
+// optAccessRules returns the list of networks of the 'allow' or
+// 'deny' route option. Rules have the form 'ip:<cidr>' or 'ip:<addr>'
+// and are separated by commas. The result is nil if the option is
+// not set and non-nil if it is set so that an 'allow' option with
+// only invalid rules rejects all clients.
+func optAccessRules(opts map[string]string, name string) []*net.IPNet {
+	v, ok := opts[name]
+	if !ok {
+		return nil
+	}
+	nets := []*net.IPNet{}
+	for _, rule := range strings.Split(v, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		n := parseAccessRule(rule)
+		if n == nil {
+			log.Printf("[WARN] Ignoring invalid %s rule %q", name, rule)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+func parseAccessRule(rule string) *net.IPNet {
+	if !strings.HasPrefix(rule, "ip:") {
+		return nil
+	}
+	s := rule[len("ip:"):]
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil
+		}
+		if ip.To4() != nil {
+			return &net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)}
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+	}
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil
+	}
+	return n
+}
+
+// AccessDenied returns true if the client with the given address
+// is rejected by the 'deny' rules or not listed in the 'allow' rules
+// of the target. Deny rules take precedence over allow rules.
+// addr is either an ip address or a host:port pair. Clients with
+// an invalid address are rejected if the target has access rules.
+func (t *Target) AccessDenied(addr string) bool {
+	if t.Allow == nil && t.Deny == nil {
+		return false
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return true
+	}
+	if containsIP(t.Deny, ip) {
+		return true
+	}
+	return t.Allow != nil && !containsIP(t.Allow, ip)
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
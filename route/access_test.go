@@ -0,0 +1,34 @@
+package route
+
+import "testing"
+
+func TestTargetAccessDenied(t *testing.T) {
+	tests := []struct {
+		opts   string
+		addr   string
+		denied bool
+	}{
+		{"", "1.2.3.4:5", false},
+		{"allow=ip:10.0.0.0/8,ip:192.168.0.1", "10.1.2.3:5", false},
+		{"allow=ip:10.0.0.0/8,ip:192.168.0.1", "192.168.0.1:5", false},
+		{"allow=ip:10.0.0.0/8,ip:192.168.0.1", "192.168.0.2:5", true},
+		{"allow=ip:10.0.0.0/8", "10.1.2.3", false},
+		{"allow=ip:10.0.0.0/8", "[::1]:5", true},
+		{"allow=ip:::1", "[::1]:5", false},
+		{"deny=ip:10.0.0.0/8", "10.1.2.3:5", true},
+		{"deny=ip:10.0.0.0/8", "11.1.2.3:5", false},
+		{"allow=ip:10.0.0.0/8 deny=ip:10.1.0.0/16", "10.1.2.3:5", true},
+		{"allow=ip:10.0.0.0/8 deny=ip:10.1.0.0/16", "10.2.2.3:5", false},
+		{"allow=foo", "10.1.2.3:5", true},
+		{"deny=foo", "10.1.2.3:5", false},
+		{"deny=ip:10.0.0.0/8", "invalid", true},
+	}
+
+	for i, tt := range tests {
+		r := &Route{}
+		r.addTarget("svc", mustParse("http://foo.com/"), 0, nil, ParseOpts(tt.opts))
+		if got, want := r.Targets[0].AccessDenied(tt.addr), tt.denied; got != want {
+			t.Errorf("%d: got %v want %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package route
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/eBay/fabio/metrics"
+)
+
+// Checksum returns the SHA-256 hash of the routes and weights of the
+// table in hex. Tables with the same routes have the same checksum
+// independent of the order in which the routes were added so that
+// fabio instances which have drifted from the rest of the fleet, e.g.
+// due to a stale registry watch, can be detected. The checksums can
+// differ for a short time while the weight of a target is ramped.
+func (t Table) Checksum() string {
+	sum := t.checksum()
+	return hex.EncodeToString(sum[:])
+}
+
+func (t Table) checksum() [sha256.Size]byte {
+	cfg := t.Config(true)
+	sort.Strings(cfg)
+	return sha256.Sum256([]byte(strings.Join(cfg, "\n")))
+}
+
+// reportChecksum updates the routes.checksum gauge with the first
+// four bytes of the checksum of the table since gauges are numbers.
+func reportChecksum(t Table) {
+	sum := t.checksum()
+	metrics.DefaultRegistry.GetGauge("routes.checksum").Update(int64(binary.BigEndian.Uint32(sum[:4])))
+}
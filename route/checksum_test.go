@@ -0,0 +1,31 @@
+package route
+
+import "testing"
+
+func TestTableChecksum(t *testing.T) {
+	mustParse := func(s string) Table {
+		tbl, err := ParseString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tbl
+	}
+
+	a := mustParse("route add svc /a http://1.1.1.1/\nroute add svc /b http://2.2.2.2/\nroute add svc /b http://3.3.3.3/")
+	b := mustParse("route add svc /b http://3.3.3.3/\nroute add svc /a http://1.1.1.1/\nroute add svc /b http://2.2.2.2/")
+	c := mustParse("route add svc /a http://1.1.1.1/\nroute add svc /b http://2.2.2.2/")
+	d := mustParse("route add svc /a http://1.1.1.1/\nroute add svc /b http://2.2.2.2/\nroute add svc /b http://3.3.3.3/ weight 0.2")
+
+	if got, want := a.Checksum(), b.Checksum(); got != want {
+		t.Fatalf("got %s want %s for the same routes in a different order", got, want)
+	}
+	if a.Checksum() == c.Checksum() {
+		t.Fatal("got the same checksum for different routes")
+	}
+	if a.Checksum() == d.Checksum() {
+		t.Fatal("got the same checksum for different weights")
+	}
+	if got, want := len(a.Checksum()), 64; got != want {
+		t.Fatalf("got length %d want %d", got, want)
+	}
+}
@@ -0,0 +1,110 @@
+package route
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"log"
+	"regexp"
+)
+
+// ClientCertRules restricts a target to the clients which present a
+// verified client certificate with matching attributes. The patterns
+// are regular expressions which must match the whole value. Only the
+// patterns which are set are checked.
+type ClientCertRules struct {
+	// CN must match the common name of the subject.
+	CN *regexp.Regexp
+
+	// OU must match one of the organizational units of the subject.
+	OU *regexp.Regexp
+
+	// SAN must match one of the DNS names, email addresses,
+	// IP addresses or URIs of the subject alternative names.
+	SAN *regexp.Regexp
+
+	// invalid is true if one of the patterns could not be parsed.
+	// All clients are rejected in this case.
+	invalid bool
+}
+
+// optClientCertRules returns the rules of the 'clientcert-cn',
+// 'clientcert-ou' and 'clientcert-san' route options or nil if none
+// of them is set.
+func optClientCertRules(opts map[string]string) *ClientCertRules {
+	var rules *ClientCertRules
+	for _, o := range []struct {
+		name string
+		re   func(*ClientCertRules) **regexp.Regexp
+	}{
+		{"clientcert-cn", func(r *ClientCertRules) **regexp.Regexp { return &r.CN }},
+		{"clientcert-ou", func(r *ClientCertRules) **regexp.Regexp { return &r.OU }},
+		{"clientcert-san", func(r *ClientCertRules) **regexp.Regexp { return &r.SAN }},
+	} {
+		v, ok := opts[o.name]
+		if !ok {
+			continue
+		}
+		if rules == nil {
+			rules = &ClientCertRules{}
+		}
+		re, err := regexp.Compile("^(?:" + v + ")$")
+		if err != nil {
+			log.Printf("[WARN] Invalid pattern %q for route option %s rejects all clients. %s", v, o.name, err)
+			rules.invalid = true
+			continue
+		}
+		*o.re(rules) = re
+	}
+	return rules
+}
+
+// ClientCertDenied returns true if the target has client certificate
+// rules and the connection has no verified client certificate which
+// matches them.
+func (t *Target) ClientCertDenied(state *tls.ConnectionState) bool {
+	r := t.ClientCert
+	if r == nil {
+		return false
+	}
+	if r.invalid || state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return true
+	}
+	return !r.matches(state.VerifiedChains[0][0])
+}
+
+func (r *ClientCertRules) matches(c *x509.Certificate) bool {
+	if r.CN != nil && !r.CN.MatchString(c.Subject.CommonName) {
+		return false
+	}
+	if r.OU != nil && !matchAny(r.OU, c.Subject.OrganizationalUnit) {
+		return false
+	}
+	if r.SAN != nil && !matchAny(r.SAN, SubjectAltNames(c)) {
+		return false
+	}
+	return true
+}
+
+// SubjectAltNames returns the DNS names, email addresses, IP addresses
+// and URIs of the subject alternative names of the certificate.
+func SubjectAltNames(c *x509.Certificate) []string {
+	var sans []string
+	sans = append(sans, c.DNSNames...)
+	sans = append(sans, c.EmailAddresses...)
+	for _, ip := range c.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, u := range c.URIs {
+		sans = append(sans, u.String())
+	}
+	return sans
+}
+
+func matchAny(re *regexp.Regexp, values []string) bool {
+	for _, v := range values {
+		if re.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package route
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestTargetClientCertDenied(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.com/svc")
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "admin-1", OrganizationalUnit: []string{"ops", "dev"}},
+		DNSNames:       []string{"a.example.com"},
+		EmailAddresses: []string{"ops@example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("10.1.2.3")},
+		URIs:           []*url.URL{spiffe},
+	}
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	unverified := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+
+	tests := []struct {
+		opts   string
+		state  *tls.ConnectionState
+		denied bool
+	}{
+		{"", nil, false},
+		{"clientcert-cn=admin-.*", nil, true},
+		{"clientcert-cn=admin-.*", unverified, true},
+		{"clientcert-cn=admin-.*", verified, false},
+		{"clientcert-cn=admin", verified, true},
+		{"clientcert-cn=dmin-1", verified, true},
+		{"clientcert-ou=dev", verified, false},
+		{"clientcert-ou=sales", verified, true},
+		{"clientcert-cn=admin-.* clientcert-ou=sales", verified, true},
+		{"clientcert-san=.*\\.example\\.com", verified, false},
+		{"clientcert-san=ops@example.com", verified, false},
+		{"clientcert-san=10\\.1\\..*", verified, false},
+		{"clientcert-san=spiffe://example.com/svc", verified, false},
+		{"clientcert-san=b.example.com", verified, true},
+		{"clientcert-cn=(", verified, true},
+	}
+
+	for i, tt := range tests {
+		r := &Route{}
+		r.addTarget("svc", mustParse("http://foo.com/"), 0, nil, ParseOpts(tt.opts))
+		if got, want := r.Targets[0].ClientCertDenied(tt.state), tt.denied; got != want {
+			t.Errorf("%d: got %v want %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package route
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CORS contains the cross-origin resource sharing rules of a target.
+// The proxy answers the preflight requests and adds the CORS headers
+// to the responses for requests from the allowed origins.
+type CORS struct {
+	// Origins contains the allowed origins in lower case.
+	// '*' allows all origins.
+	Origins []string
+
+	// Methods contains the allowed methods of the actual request.
+	Methods []string
+
+	// Headers contains the allowed request headers
+	// of the actual request in canonical form.
+	Headers []string
+
+	// MaxAge is the time the result of a preflight
+	// request can be cached if it is not zero.
+	MaxAge time.Duration
+
+	// Credentials allows requests with cookies
+	// or HTTP authentication.
+	Credentials bool
+}
+
+// defaultCORSMethods are the methods which are allowed
+// if the 'cors-methods' option is not set.
+var defaultCORSMethods = []string{"GET", "HEAD", "POST"}
+
+// optCORS returns the CORS rules from the 'cors-origins', 'cors-methods',
+// 'cors-headers', 'cors-maxage' and 'cors-credentials' options or nil if
+// the 'cors-origins' option is not set.
+func optCORS(opts map[string]string) *CORS {
+	origins := splitList(opts["cors-origins"], strings.ToLower)
+	if origins == nil {
+		return nil
+	}
+	c := &CORS{
+		Origins: origins,
+		Methods: optMethods(opts, "cors-methods"),
+		Headers: splitList(opts["cors-headers"], http.CanonicalHeaderKey),
+		MaxAge:  optDuration(opts, "cors-maxage"),
+	}
+	if len(c.Methods) == 0 {
+		c.Methods = defaultCORSMethods
+	}
+	if _, ok := opts["cors-credentials"]; ok {
+		c.Credentials = true
+	}
+	return c
+}
+
+// AllowOrigin returns true if requests from the origin are allowed.
+func (c *CORS) AllowOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, o := range c.Origins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowMethod returns true if the method is allowed.
+func (c *CORS) AllowMethod(method string) bool {
+	for _, m := range c.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowHeader returns true if the request header is allowed.
+func (c *CORS) AllowHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	for _, h := range c.Headers {
+		if h == "*" || h == name {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList returns the non-empty values of the comma separated
+// list after applying fn or nil if there are none.
+func splitList(s string, fn func(string) string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, fn(v))
+		}
+	}
+	return list
+}
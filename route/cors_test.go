@@ -0,0 +1,51 @@
+package route
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOptCORS(t *testing.T) {
+	tests := []struct {
+		desc string
+		opts map[string]string
+		want *CORS
+	}{
+		{"no cors", map[string]string{"cors-methods": "GET"}, nil},
+		{"empty origins", map[string]string{"cors-origins": ""}, nil},
+		{
+			"defaults",
+			map[string]string{"cors-origins": "https://A.com"},
+			&CORS{Origins: []string{"https://a.com"}, Methods: []string{"GET", "HEAD", "POST"}},
+		},
+		{
+			"all options",
+			map[string]string{"cors-origins": "https://a.com, https://b.com", "cors-methods": "get,put", "cors-headers": "content-type,x-token", "cors-maxage": "1h", "cors-credentials": ""},
+			&CORS{Origins: []string{"https://a.com", "https://b.com"}, Methods: []string{"GET", "PUT"}, Headers: []string{"Content-Type", "X-Token"}, MaxAge: time.Hour, Credentials: true},
+		},
+	}
+	for _, tt := range tests {
+		if got := optCORS(tt.opts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v want %+v", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestCORSAllow(t *testing.T) {
+	c := &CORS{Origins: []string{"https://a.com"}, Methods: []string{"GET"}, Headers: []string{"X-Token"}}
+	if !c.AllowOrigin("https://A.com") || c.AllowOrigin("https://b.com") {
+		t.Error("origin not matched")
+	}
+	if !c.AllowMethod("GET") || c.AllowMethod("PUT") {
+		t.Error("method not matched")
+	}
+	if !c.AllowHeader("x-token") || c.AllowHeader("X-Other") {
+		t.Error("header not matched")
+	}
+
+	c = &CORS{Origins: []string{"*"}, Headers: []string{"*"}}
+	if !c.AllowOrigin("https://b.com") || !c.AllowHeader("X-Other") {
+		t.Error("wildcard not matched")
+	}
+}
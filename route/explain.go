@@ -0,0 +1,129 @@
+package route
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Explanation describes how the routing table selects the
+// target for a request.
+type Explanation struct {
+	Host    string `json:"host"`
+	Path    string `json:"path"`
+	Matcher string `json:"matcher"`
+
+	// Steps describes the routes which have been considered.
+	Steps []string `json:"steps"`
+
+	// Route is the host and path of the selected route
+	// or empty if no route matches.
+	Route string `json:"route,omitempty"`
+
+	// Candidates contains the targets of the route from which
+	// the target is selected.
+	Candidates []Candidate `json:"candidates,omitempty"`
+}
+
+// Candidate is a target which can be selected for a request.
+type Candidate struct {
+	Service string `json:"service"`
+	URL     string `json:"url"`
+
+	// Share is the fraction of the requests which
+	// are sent to the target.
+	Share float64 `json:"share"`
+
+	// Predicate is true if the target is selected because
+	// its header, cookie, source or method predicates match.
+	Predicate bool `json:"predicate,omitempty"`
+}
+
+func (e *Explanation) step(format string, args ...interface{}) {
+	e.Steps = append(e.Steps, fmt.Sprintf(format, args...))
+}
+
+// Explain describes the lookup of the target for the request like
+// Lookup without selecting a target so that the state of the picker
+// is not changed.
+func (t Table) Explain(req *http.Request) *Explanation {
+	host := normalizeHost(req)
+	e := &Explanation{Host: host, Path: req.RequestURI, Matcher: matchName.Load().(string)}
+
+	// same order as in Lookup
+	hosts := []string{host}
+	for h := host; strings.IndexByte(h, '.') >= 0; {
+		h = h[strings.IndexByte(h, '.')+1:]
+		hosts = append(hosts, "*."+h)
+	}
+	hosts = append(hosts, "")
+
+	for _, h := range hosts {
+		name := h
+		if name == "" {
+			name = "routes without host"
+		}
+		if len(t[h]) == 0 {
+			if h == host || h == "" {
+				e.step("%s: no routes", name)
+			}
+			continue
+		}
+		for _, r := range t[h] {
+			if !match(e.Path, r) {
+				continue
+			}
+			candidates, ok := r.candidates(req)
+			if !ok {
+				e.step("%s: route %s%s matches but none of its target predicates matches", name, r.Host, r.Path)
+				continue
+			}
+			e.step("%s: route %s%s matches", name, r.Host, r.Path)
+			e.Route = r.Host + r.Path
+			e.Candidates = candidates
+			return e
+		}
+		e.step("%s: no route matches the path", name)
+	}
+	return e
+}
+
+// candidates returns the targets from which lookup selects the target
+// for the request. ok is false if lookup continues with the next route.
+func (r *Route) candidates(req *http.Request) (c []Candidate, ok bool) {
+	if len(r.predicated) > 0 {
+		for _, t := range r.predicated {
+			if t.matches(req) {
+				c = append(c, Candidate{Service: t.Service, URL: t.URL.String(), Predicate: true})
+			}
+		}
+		for i := range c {
+			c[i].Share = 1 / float64(len(c))
+		}
+		if len(c) > 0 {
+			return c, true
+		}
+		if len(r.wTargets) == 0 {
+			return nil, false
+		}
+	}
+
+	predicated := map[*Target]bool{}
+	for _, t := range r.predicated {
+		predicated[t] = true
+	}
+	for _, t := range r.Targets {
+		if predicated[t] {
+			continue
+		}
+		var share float64
+		switch {
+		case len(r.Targets) == 1:
+			share = 1
+		case len(r.wTargets) > 0:
+			share = float64(r.targetWeight(t.URL.String())) / float64(len(r.wTargets))
+		}
+		c = append(c, Candidate{Service: t.Service, URL: t.URL.String(), Share: share})
+	}
+	return c, true
+}
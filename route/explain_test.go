@@ -0,0 +1,91 @@
+package route
+
+import (
+	"crypto/tls"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestTableExplain(t *testing.T) {
+	tbl, err := ParseString(`
+route add a example.com/a http://1.1.1.1:80/ weight 0.25
+route add a example.com/a http://1.1.1.2:80/
+route add b *.example.com/ http://2.2.2.2:80/
+route add c example.com/c http://3.3.3.3:80/ opts "match-header=X-Beta:1"
+route add d /c http://4.4.4.4:80/
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc       string
+		req        *http.Request
+		route      string
+		steps      []string
+		candidates []Candidate
+	}{
+		{
+			desc:  "weighted targets",
+			req:   &http.Request{Host: "example.com:443", RequestURI: "/a/b", TLS: &tls.ConnectionState{}},
+			route: "example.com/a",
+			steps: []string{"example.com: route example.com/a matches"},
+			candidates: []Candidate{
+				{Service: "a", URL: "http://1.1.1.1:80/", Share: 0.25},
+				{Service: "a", URL: "http://1.1.1.2:80/", Share: 0.75},
+			},
+		},
+		{
+			desc:  "wildcard host",
+			req:   &http.Request{Host: "www.example.com", RequestURI: "/x"},
+			route: "*.example.com/",
+			steps: []string{
+				"www.example.com: no routes",
+				"*.example.com: route *.example.com/ matches",
+			},
+			candidates: []Candidate{{Service: "b", URL: "http://2.2.2.2:80/", Share: 1}},
+		},
+		{
+			desc:       "predicate matches",
+			req:        &http.Request{Host: "example.com", RequestURI: "/c", Header: http.Header{"X-Beta": {"1"}}},
+			route:      "example.com/c",
+			steps:      []string{"example.com: route example.com/c matches"},
+			candidates: []Candidate{{Service: "c", URL: "http://3.3.3.3:80/", Share: 1, Predicate: true}},
+		},
+		{
+			desc:  "predicate does not match",
+			req:   &http.Request{Host: "example.com", RequestURI: "/c", Header: http.Header{}},
+			route: "/c",
+			steps: []string{
+				"example.com: route example.com/c matches but none of its target predicates matches",
+				"example.com: no route matches the path",
+				"routes without host: route /c matches",
+			},
+			candidates: []Candidate{{Service: "d", URL: "http://4.4.4.4:80/", Share: 1}},
+		},
+		{
+			desc: "no route",
+			req:  &http.Request{Host: "foo.org", RequestURI: "/"},
+			steps: []string{
+				"foo.org: no routes",
+				"routes without host: no route matches the path",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			e := tbl.Explain(tt.req)
+			if got, want := e.Route, tt.route; got != want {
+				t.Errorf("got route %q want %q", got, want)
+			}
+			if got, want := e.Steps, tt.steps; !reflect.DeepEqual(got, want) {
+				t.Errorf("got steps %q want %q", got, want)
+			}
+			if got, want := e.Candidates, tt.candidates; !reflect.DeepEqual(got, want) {
+				t.Errorf("got candidates %+v want %+v", got, want)
+			}
+		})
+	}
+}
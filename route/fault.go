@@ -0,0 +1,70 @@
+package route
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fault contains the artificial latency and errors which are injected
+// into the requests to a target for chaos experiments. Set with the
+// 'delay' and 'abort' options.
+type Fault struct {
+	// Delay is the latency which is added to the fraction
+	// DelayRatio of the requests.
+	Delay      time.Duration
+	DelayRatio float64
+
+	// AbortStatus is the status code which is returned without
+	// contacting the target for the fraction AbortRatio of
+	// the requests.
+	AbortStatus int
+	AbortRatio  float64
+}
+
+// optFault returns the fault injection from the 'delay=<d>[:<ratio>]' and
+// 'abort=<code>[:<ratio>]' options or nil if none of them is set. The
+// ratio is a fraction between 0 and 1 and defaults to 1. Invalid values
+// are logged and ignored.
+func optFault(opts map[string]string) *Fault {
+	f := &Fault{}
+	if v, ok := opts["delay"]; ok {
+		if d, ratio, ok := parseFault(v, validDuration); ok {
+			f.Delay, _ = time.ParseDuration(d)
+			f.DelayRatio = ratio
+		} else {
+			log.Printf("[WARN] Ignoring invalid value %q for route option delay", v)
+		}
+	}
+	if v, ok := opts["abort"]; ok {
+		if code, ratio, ok := parseFault(v, validStatus); ok {
+			f.AbortStatus, _ = strconv.Atoi(code)
+			f.AbortRatio = ratio
+		} else {
+			log.Printf("[WARN] Ignoring invalid value %q for route option abort", v)
+		}
+	}
+	if f.DelayRatio == 0 && f.AbortRatio == 0 {
+		return nil
+	}
+	return f
+}
+
+// parseFault splits the value of a fault option into the value and the
+// ratio. ok is false if the value is not valid or the ratio is not
+// between 0 and 1.
+func parseFault(s string, valid func(string) bool) (v string, ratio float64, ok bool) {
+	v, ratio = s, 1
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		r, err := strconv.ParseFloat(s[i+1:], 64)
+		if err != nil || !(r >= 0 && r <= 1) {
+			return "", 0, false
+		}
+		v, ratio = s[:i], r
+	}
+	if !valid(v) {
+		return "", 0, false
+	}
+	return v, ratio, true
+}
@@ -0,0 +1,30 @@
+package route
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOptFault(t *testing.T) {
+	tests := []struct {
+		desc string
+		opts map[string]string
+		want *Fault
+	}{
+		{"no fault", map[string]string{"proto": "https"}, nil},
+		{"delay", map[string]string{"delay": "200ms"}, &Fault{Delay: 200 * time.Millisecond, DelayRatio: 1}},
+		{"delay with ratio", map[string]string{"delay": "1s:0.5"}, &Fault{Delay: time.Second, DelayRatio: 0.5}},
+		{"abort", map[string]string{"abort": "503:0.01"}, &Fault{AbortStatus: 503, AbortRatio: 0.01}},
+		{"both", map[string]string{"delay": "1s", "abort": "500"}, &Fault{Delay: time.Second, DelayRatio: 1, AbortStatus: 500, AbortRatio: 1}},
+		{"zero ratio", map[string]string{"abort": "503:0"}, nil},
+		{"invalid ratio", map[string]string{"abort": "503:2"}, nil},
+		{"invalid status", map[string]string{"abort": "foo"}, nil},
+		{"invalid delay", map[string]string{"delay": "-1s"}, nil},
+	}
+	for _, tt := range tests {
+		if got := optFault(tt.opts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v want %+v", tt.desc, got, tt.want)
+		}
+	}
+}
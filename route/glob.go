@@ -0,0 +1,52 @@
+package route
+
+import (
+	"path"
+	"strings"
+)
+
+// globPattern is the path of a route prepared for the glob matcher
+// when the route is created. Paths without meta characters are
+// compared directly and the literal prefix of the other patterns
+// rejects most request paths without calling path.Match.
+type globPattern struct {
+	// pattern is the path of the route.
+	pattern string
+
+	// prefix is the part of the pattern before the first
+	// meta character.
+	prefix string
+
+	// literal is true if the pattern has no meta characters.
+	literal bool
+
+	// err is the syntax error of the pattern or nil.
+	err error
+}
+
+func newGlobPattern(p string) *globPattern {
+	g := &globPattern{pattern: p}
+	i := strings.IndexAny(p, `*?[\`)
+	if i < 0 {
+		g.prefix, g.literal = p, true
+		return g
+	}
+	g.prefix = p[:i]
+	_, g.err = path.Match(p, "")
+	return g
+}
+
+// match returns true if the uri matches the pattern.
+// It returns the same result as path.Match.
+func (g *globPattern) match(uri string) bool {
+	switch {
+	case g.literal:
+		return uri == g.pattern
+	case g.err != nil:
+		return false
+	case !strings.HasPrefix(uri, g.prefix):
+		return false
+	}
+	ok, _ := path.Match(g.pattern, uri)
+	return ok
+}
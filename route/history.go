@@ -0,0 +1,89 @@
+package route
+
+import (
+	"sync"
+	"time"
+)
+
+// historySize is the number of routing table changes
+// which are kept in the history.
+const historySize = 100
+
+// Change describes an update of the routing table. Added and Removed
+// contain the route commands of the targets which have been added
+// to or removed from the table. A changed weight is reported as
+// removed and added target.
+type Change struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source,omitempty"`
+	Added   []string  `json:"added,omitempty"`
+	Removed []string  `json:"removed,omitempty"`
+}
+
+// SourceRamp is the source of the periodic table updates which
+// only change the weights of ramps and slow starts. These updates
+// are not logged and not recorded in the history.
+const SourceRamp = "ramp"
+
+// changes is a ring buffer of the last historySize changes.
+var changes struct {
+	sync.Mutex
+	buf  []Change
+	next int
+}
+
+// History returns the recorded changes of the routing
+// table with the most recent change first.
+func History() []Change {
+	changes.Lock()
+	defer changes.Unlock()
+	h := make([]Change, 0, len(changes.buf))
+	for i := 1; i <= len(changes.buf); i++ {
+		h = append(h, changes.buf[(changes.next-i+len(changes.buf))%len(changes.buf)])
+	}
+	return h
+}
+
+// recordChange adds the difference between the old and the new
+// table to the history if there is one.
+func recordChange(source string, old, cur Table, now time.Time) {
+	added, removed := diffConfig(old.Config(true), cur.Config(true))
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	c := Change{Time: now, Source: source, Added: added, Removed: removed}
+
+	changes.Lock()
+	defer changes.Unlock()
+	if len(changes.buf) < historySize {
+		changes.buf = append(changes.buf, c)
+		changes.next = len(changes.buf) % historySize
+		return
+	}
+	changes.buf[changes.next] = c
+	changes.next = (changes.next + 1) % historySize
+}
+
+// diffConfig returns the lines which are only in cur
+// and the lines which are only in old in their order.
+func diffConfig(old, cur []string) (added, removed []string) {
+	in := func(lines []string) map[string]bool {
+		m := make(map[string]bool, len(lines))
+		for _, l := range lines {
+			m[l] = true
+		}
+		return m
+	}
+	inOld, inCur := in(old), in(cur)
+	for _, l := range cur {
+		if !inOld[l] {
+			added = append(added, l)
+		}
+	}
+	for _, l := range old {
+		if !inCur[l] {
+			removed = append(removed, l)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,74 @@
+package route
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	defer SetTable(make(Table))
+	defer func() { changes.buf, changes.next = nil, 0 }()
+	changes.buf, changes.next = nil, 0
+	SetTable(make(Table))
+
+	mustParse := func(s string) Table {
+		tbl, err := ParseString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tbl
+	}
+
+	SetTableFrom(mustParse("route add a /a http://1.1.1.1:80/"), "services")
+	SetTableFrom(mustParse("route add a /a http://1.1.1.1:80/"), "services")
+	SetTableFrom(mustParse("route add b /b http://2.2.2.2:80/"), "manual")
+	SetTableFrom(mustParse("route add b /b http://2.2.2.2:80/\nroute add c /c http://3.3.3.3:80/"), SourceRamp)
+
+	want := []Change{
+		{
+			Source:  "manual",
+			Added:   []string{"route add b /b http://2.2.2.2:80/ weight 1.00"},
+			Removed: []string{"route add a /a http://1.1.1.1:80/ weight 1.00"},
+		},
+		{
+			Source: "services",
+			Added:  []string{"route add a /a http://1.1.1.1:80/ weight 1.00"},
+		},
+	}
+	got := History()
+	for i := range got {
+		if got[i].Time.IsZero() {
+			t.Fatalf("%d: got zero time", i)
+		}
+		got[i].Time = time.Time{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+}
+
+func TestHistoryRing(t *testing.T) {
+	defer func() { changes.buf, changes.next = nil, 0 }()
+	changes.buf, changes.next = nil, 0
+
+	old := make(Table)
+	for i := 0; i < historySize+5; i++ {
+		cur := make(Table)
+		cur.AddRoute("svc", "/", fmt.Sprintf("http://1.1.1.1:%d/", 1000+i), 0, nil)
+		recordChange("services", old, cur, time.Unix(int64(i), 0))
+		old = cur
+	}
+
+	h := History()
+	if got, want := len(h), historySize; got != want {
+		t.Fatalf("got %d changes want %d", got, want)
+	}
+	if got, want := h[0].Time, time.Unix(historySize+4, 0); !got.Equal(want) {
+		t.Fatalf("got newest change at %v want %v", got, want)
+	}
+	if got, want := h[len(h)-1].Time, time.Unix(5, 0); !got.Equal(want) {
+		t.Fatalf("got oldest change at %v want %v", got, want)
+	}
+}
@@ -0,0 +1,137 @@
+package route
+
+import "sync"
+
+// node is a node of a radix tree of route paths. The edges are
+// labeled with the part of the path between two nodes and a node
+// has the route whose path ends there, if any.
+type node struct {
+	label    string
+	route    *Route
+	children []*node
+}
+
+// insert adds the route for the path below the node.
+func (n *node) insert(path string, r *Route) {
+	for {
+		if path == "" {
+			n.route = r
+			return
+		}
+
+		var child *node
+		for _, c := range n.children {
+			if c.label[0] == path[0] {
+				child = c
+				break
+			}
+		}
+		if child == nil {
+			n.children = append(n.children, &node{label: path, route: r})
+			return
+		}
+
+		// split the edge at the end of the common prefix
+		i := commonPrefix(child.label, path)
+		if i < len(child.label) {
+			split := &node{label: child.label[i:], route: child.route, children: child.children}
+			child.label, child.route, child.children = child.label[:i], nil, []*node{split}
+		}
+		n, path = child, path[i:]
+	}
+}
+
+// match appends the routes whose path is a prefix of uri to
+// matches ordered from the shortest to the longest path.
+func (n *node) match(uri string, matches []*Route) []*Route {
+	for {
+		if n.route != nil {
+			matches = append(matches, n.route)
+		}
+
+		var child *node
+		for _, c := range n.children {
+			if len(uri) > 0 && c.label[0] == uri[0] {
+				child = c
+				break
+			}
+		}
+		if child == nil || len(uri) < len(child.label) || uri[:len(child.label)] != child.label {
+			return matches
+		}
+		n, uri = child, uri[len(child.label):]
+	}
+}
+
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// routeIndex is the radix tree of the paths of the routes.
+type routeIndex struct {
+	routes Routes
+	root   *node
+}
+
+func newRouteIndex(rt Routes) *routeIndex {
+	idx := &routeIndex{routes: rt, root: &node{}}
+	for _, r := range rt {
+		idx.root.insert(r.Path, r)
+	}
+	return idx
+}
+
+// indexKey identifies a list of routes. Since the list is replaced or
+// grows when routes are added or removed the first route and the length
+// identify it together with the address of the underlying array.
+type indexKey struct {
+	first *Route
+	n     int
+}
+
+// indexes caches the radix trees for the lists of routes of the hosts.
+// The trees of the active table are built by SetTable before the table
+// becomes visible. The trees for other tables are built on first use.
+var indexes sync.Map
+
+// index returns the radix tree for the routes.
+func (rt Routes) index() *routeIndex {
+	key := indexKey{rt[0], len(rt)}
+	if v, ok := indexes.Load(key); ok {
+		idx := v.(*routeIndex)
+		if &idx.routes[0] == &rt[0] {
+			return idx
+		}
+	}
+	idx := newRouteIndex(rt)
+	indexes.Store(key, idx)
+	return idx
+}
+
+// buildIndexes builds the radix trees for all hosts of the table
+// and returns their keys.
+func buildIndexes(t Table) map[indexKey]bool {
+	keys := map[indexKey]bool{}
+	for _, routes := range t {
+		if len(routes) == 0 {
+			continue
+		}
+		routes.index()
+		keys[indexKey{routes[0], len(routes)}] = true
+	}
+	return keys
+}
+
+// pruneIndexes removes the cached radix trees which are not in keep.
+func pruneIndexes(keep map[indexKey]bool) {
+	indexes.Range(func(k, _ interface{}) bool {
+		if !keep[k.(indexKey)] {
+			indexes.Delete(k)
+		}
+		return true
+	})
+}
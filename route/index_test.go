@@ -0,0 +1,162 @@
+package route
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestNodeMatch(t *testing.T) {
+	root := &node{}
+	routes := map[string]*Route{}
+	for _, p := range []string{"/", "/foo", "/foo/bar", "/fo", "/foobar", "/baz/"} {
+		routes[p] = newRoute("", p)
+		root.insert(p, routes[p])
+	}
+
+	tests := []struct {
+		uri  string
+		want []string
+	}{
+		{"", nil},
+		{"/", []string{"/"}},
+		{"/f", []string{"/"}},
+		{"/fo", []string{"/", "/fo"}},
+		{"/foo/bar/baz", []string{"/", "/fo", "/foo", "/foo/bar"}},
+		{"/foobar", []string{"/", "/fo", "/foo", "/foobar"}},
+		{"/foob", []string{"/", "/fo", "/foo"}},
+		{"/baz", []string{"/"}},
+		{"/baz/x", []string{"/", "/baz/"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, r := range root.match(tt.uri, nil) {
+			got = append(got, r.Path)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %v want %v", tt.uri, got, tt.want)
+		}
+	}
+}
+
+// TestIndexLookup verifies that the radix tree finds the same
+// routes as the linear search over the sorted routes.
+func TestIndexLookup(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	segments := []string{"a", "ab", "b", "ba", "c", "/", "-"}
+	path := func() string {
+		p := "/"
+		for n := rnd.Intn(6); n > 0; n-- {
+			p += segments[rnd.Intn(len(segments))]
+		}
+		return p
+	}
+
+	tbl := make(Table)
+	for i := 0; i < 500; i++ {
+		if err := tbl.AddRoute("svc", "abc.com"+path(), fmt.Sprintf("http://host-%d:80/", i), 0, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2000; i++ {
+		uri := path()
+		var want string
+		for _, r := range tbl["abc.com"] {
+			if prefixMatcher(uri, r) {
+				want = "abc.com" + r.Path
+				break
+			}
+		}
+		var got string
+		if tg := tbl.Lookup(&http.Request{Host: "abc.com", RequestURI: uri}, ""); tg != nil {
+			got = tg.Route
+		}
+		if got != want {
+			t.Fatalf("%s: got route %q want %q", uri, got, want)
+		}
+	}
+}
+
+func TestIndexCache(t *testing.T) {
+	tbl := make(Table)
+	tbl.AddRoute("svc", "/a", "http://host:80/", 0, nil)
+	req := &http.Request{RequestURI: "/b"}
+	if got := tbl.Lookup(req, ""); got != nil {
+		t.Fatalf("got %v want nil", got.URL)
+	}
+
+	// the index is rebuilt when routes are added
+	tbl.AddRoute("svc", "/b", "http://host:81/", 0, nil)
+	if got := tbl.Lookup(req, ""); got == nil || got.URL.Host != "host:81" {
+		t.Fatalf("got %v want host:81", got)
+	}
+
+	// and removed
+	tbl.DelRoute("svc", "/b", "")
+	if got := tbl.Lookup(req, ""); got != nil {
+		t.Fatalf("got %v want nil", got.URL)
+	}
+}
+
+func TestSetTableBuildsIndexes(t *testing.T) {
+	defer SetTable(make(Table))
+
+	old := make(Table)
+	old.AddRoute("svc", "/a", "http://host:80/", 0, nil)
+	SetTable(old)
+
+	tbl := make(Table)
+	tbl.AddRoute("svc", "/b", "http://host:81/", 0, nil)
+	tbl.AddRoute("svc", "abc.com/c", "http://host:82/", 0, nil)
+	SetTable(tbl)
+
+	var keys []indexKey
+	indexes.Range(func(k, _ interface{}) bool {
+		keys = append(keys, k.(indexKey))
+		return true
+	})
+	if got, want := len(keys), 2; got != want {
+		t.Fatalf("got %d indexes want %d", got, want)
+	}
+	for _, k := range keys {
+		if k.first.Path != "/b" && k.first.Path != "/c" {
+			t.Fatalf("got index for %s", k.first.Path)
+		}
+	}
+}
+
+func TestSetTableConcurrentLookup(t *testing.T) {
+	defer SetTable(make(Table))
+
+	tables := make([]Table, 2)
+	for i := range tables {
+		tables[i] = make(Table)
+		tables[i].AddRoute("svc", "/", fmt.Sprintf("http://host-%d:80/", i), 0, nil)
+		tables[i].AddRoute("svc", "/foo", fmt.Sprintf("http://host-%d:81/", i), 0, nil)
+	}
+	SetTable(tables[0])
+
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			SetTable(tables[i%2])
+		}
+	}()
+
+	req := &http.Request{RequestURI: "/foo/bar"}
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			if tg := GetTable().Lookup(req, ""); tg == nil || tg.URL.Port() != "81" {
+				t.Fatalf("got %v want a target on port 81", tg)
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package route
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Maintenance describes the response for the requests to a route
+// in maintenance mode. Body defaults to the error page or the status
+// text of the status code if it is empty.
+type Maintenance struct {
+	Route      string
+	Status     int
+	Body       string
+	RetryAfter time.Duration
+}
+
+// maintenance contains the routes which have been put into
+// maintenance mode at runtime by their host and path.
+var maintenance = struct {
+	sync.RWMutex
+	m map[string]Maintenance
+}{m: map[string]Maintenance{}}
+
+// SetMaintenance puts the route into maintenance mode. The route
+// is the host and path of the route, e.g. 'example.com/app'.
+func SetMaintenance(m Maintenance) {
+	maintenance.Lock()
+	defer maintenance.Unlock()
+	maintenance.m[m.Route] = m
+}
+
+// ClearMaintenance ends the maintenance mode of the route which has
+// been started with SetMaintenance. It returns false if the route is
+// not in maintenance mode.
+func ClearMaintenance(route string) bool {
+	maintenance.Lock()
+	defer maintenance.Unlock()
+	_, ok := maintenance.m[route]
+	delete(maintenance.m, route)
+	return ok
+}
+
+// MaintenanceRoutes returns the routes which have been put
+// into maintenance mode with SetMaintenance sorted by route.
+func MaintenanceRoutes() []Maintenance {
+	maintenance.RLock()
+	defer maintenance.RUnlock()
+	ms := []Maintenance{}
+	for _, m := range maintenance.m {
+		ms = append(ms, m)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Route < ms[j].Route })
+	return ms
+}
+
+// Maintenance returns the maintenance mode of the route of the target
+// or nil if it is not in maintenance mode. The mode set at runtime takes
+// precedence over the 'maintenance' option.
+func (t *Target) Maintenance() *Maintenance {
+	maintenance.RLock()
+	m, ok := maintenance.m[t.Route]
+	maintenance.RUnlock()
+	if ok {
+		return &m
+	}
+	return t.maintenance
+}
+
+// optMaintenance returns the maintenance mode from the 'maintenance'
+// and 'maintenance-retryafter' options or nil if the target is not in
+// maintenance mode. The status code defaults to 503.
+func optMaintenance(opts map[string]string, route string) *Maintenance {
+	v, ok := opts["maintenance"]
+	if !ok {
+		return nil
+	}
+	m := &Maintenance{Route: route, Status: 503, RetryAfter: optDuration(opts, "maintenance-retryafter")}
+	if v != "" {
+		code, err := strconv.Atoi(v)
+		if err != nil || code < 200 || code > 599 {
+			log.Printf("[WARN] Ignoring invalid value %q for route option maintenance", v)
+		} else {
+			m.Status = code
+		}
+	}
+	return m
+}
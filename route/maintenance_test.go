@@ -0,0 +1,53 @@
+package route
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTargetMaintenance(t *testing.T) {
+	tbl, err := ParseString(`
+route add a /a http://1:111/ opts "maintenance maintenance-retryafter=1m"
+route add b /b http://2:222/ opts "maintenance=410"
+route add c /c http://3:333/ opts "maintenance=foo"
+route add d example.com/d http://4:444/`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := func(host, path string) *Target {
+		return tbl.route(host, path).Targets[0]
+	}
+
+	tests := []struct {
+		desc string
+		t    *Target
+		want *Maintenance
+	}{
+		{"option", target("", "/a"), &Maintenance{Route: "/a", Status: 503, RetryAfter: time.Minute}},
+		{"option with status", target("", "/b"), &Maintenance{Route: "/b", Status: 410}},
+		{"invalid status", target("", "/c"), &Maintenance{Route: "/c", Status: 503}},
+		{"no maintenance", target("example.com", "/d"), nil},
+	}
+	for _, tt := range tests {
+		if got := tt.t.Maintenance(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v want %+v", tt.desc, got, tt.want)
+		}
+	}
+
+	// runtime maintenance mode takes precedence
+	m := Maintenance{Route: "example.com/d", Status: 503, Body: "later"}
+	SetMaintenance(m)
+	if got := target("example.com", "/d").Maintenance(); !reflect.DeepEqual(got, &m) {
+		t.Fatalf("got %+v want %+v", got, &m)
+	}
+	if got, want := MaintenanceRoutes(), []Maintenance{m}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+	if !ClearMaintenance("example.com/d") {
+		t.Fatal("got false want true")
+	}
+	if got := target("example.com", "/d").Maintenance(); got != nil {
+		t.Fatalf("got %+v want nil", got)
+	}
+}
@@ -3,12 +3,27 @@ package route
 import (
 	"fmt"
 	"log"
-	"path"
+	"regexp"
 	"strings"
+	"sync/atomic"
 )
 
-// match contains the matcher function
-var match matcher = prefixMatcher
+// matchFn contains the matcher function. It can be
+// changed at runtime and is therefore stored atomically.
+var matchFn atomic.Value
+
+// matchName contains the name of the current matcher.
+var matchName atomic.Value
+
+func init() {
+	matchFn.Store(matcher(prefixMatcher))
+	matchName.Store("prefix")
+}
+
+// match calls the current matcher function.
+func match(uri string, r *Route) bool {
+	return matchFn.Load().(matcher)(uri, r)
+}
 
 // matcher determines whether a host/path matches a route
 type matcher func(uri string, r *Route) bool
@@ -18,25 +33,80 @@ func prefixMatcher(uri string, r *Route) bool {
 	return strings.HasPrefix(uri, r.Path)
 }
 
+// iprefixMatcher matches path to the routes' path ignoring
+// the case of the letters.
+func iprefixMatcher(uri string, r *Route) bool {
+	return len(uri) >= len(r.Path) && strings.EqualFold(uri[:len(r.Path)], r.Path)
+}
+
 // globMatcher matches path to the routes' path using globbing.
+// The pattern is prepared when the route is created.
 func globMatcher(uri string, r *Route) bool {
-	var hasMatch, err = path.Match(r.Path, uri)
-	if err != nil {
-		log.Printf("[ERROR] Glob matching error %s for path %s route %s", err, uri, r.Path)
+	return r.glob.match(uri)
+}
+
+// regexpMatcher matches the path of the uri to the routes' path
+// as a regular expression which must match the whole path.
+func regexpMatcher(uri string, r *Route) bool {
+	re := r.pathRegexp()
+	if re == nil {
 		return false
 	}
-	return hasMatch
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		uri = uri[:i]
+	}
+	return re.MatchString(uri)
+}
+
+// pathRegexp returns the anchored regular expression for the
+// path of the route or nil if the path is not a valid regular
+// expression. It is compiled on first use.
+func (r *Route) pathRegexp() *regexp.Regexp {
+	r.reOnce.Do(func() {
+		re, err := regexp.Compile("^(?:" + r.Path + ")$")
+		if err != nil {
+			log.Printf("[ERROR] Invalid regular expression for route %s%s. %s", r.Host, r.Path, err)
+			return
+		}
+		r.re = re
+	})
+	return r.re
 }
 
 // SetMatcher sets the matcher function for the proxy.
 func SetMatcher(s string) error {
 	switch s {
 	case "prefix":
-		match = prefixMatcher
+		matchFn.Store(matcher(prefixMatcher))
+	case "iprefix":
+		matchFn.Store(matcher(iprefixMatcher))
 	case "glob":
-		match = globMatcher
+		matchFn.Store(matcher(globMatcher))
+	case "regexp":
+		matchFn.Store(matcher(regexpMatcher))
 	default:
 		return fmt.Errorf("route: invalid matcher: %s", s)
 	}
+	matchName.Store(s)
 	return nil
 }
+
+// RewritePath returns the upstream path for the request path if the
+// regexp matcher is active and the path of the target URL refers to
+// capture groups of the route like '$1' or '${name}'. The references
+// are replaced with the captured values. ok is false if the path is
+// not rewritten.
+func (t *Target) RewritePath(path string) (upstream string, ok bool) {
+	if matchName.Load() != "regexp" || t.route == nil || !strings.Contains(t.URL.Path, "$") {
+		return "", false
+	}
+	re := t.route.pathRegexp()
+	if re == nil {
+		return "", false
+	}
+	m := re.FindStringSubmatchIndex(path)
+	if m == nil {
+		return "", false
+	}
+	return string(re.ExpandString(nil, t.URL.Path, path, m)), true
+}
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"path"
 	"testing"
 )
 
@@ -25,6 +26,28 @@ func TestPrefixMatcher(t *testing.T) {
 	}
 }
 
+func TestIPrefixMatcher(t *testing.T) {
+	routeFoo := newRoute("www.example.com", "/Foo")
+
+	tests := []struct {
+		uri   string
+		want  bool
+		route *Route
+	}{
+		{"/fo", false, routeFoo},
+		{"/foo", true, routeFoo},
+		{"/FOOLS", true, routeFoo},
+		{"/Foo/bar", true, routeFoo},
+		{"/bar", false, routeFoo},
+	}
+
+	for _, tt := range tests {
+		if got := iprefixMatcher(tt.uri, tt.route); got != tt.want {
+			t.Errorf("%s: got %v want %v", tt.uri, got, tt.want)
+		}
+	}
+}
+
 func TestGlobMatcher(t *testing.T) {
 	routeFoo := newRoute("www.example.com", "/foo")
 	routeFooWild := newRoute("www.example.com", "/foo.*")
@@ -54,3 +77,94 @@ func TestGlobMatcher(t *testing.T) {
 		}
 	}
 }
+
+func TestGlobPattern(t *testing.T) {
+	tests := []struct {
+		pattern, uri string
+	}{
+		{"/foo", "/foo"},
+		{"/foo", "/foo/bar"},
+		{"/foo/*", "/foo/bar"},
+		{"/foo/*", "/foo/bar/baz"},
+		{"/foo/*/baz", "/foo/bar/baz"},
+		{"/foo/?", "/foo/a"},
+		{"/foo/[a-c]", "/foo/b"},
+		{"/foo/[^a-c]", "/foo/b"},
+		{`/foo/\*`, "/foo/*"},
+		{`/foo/\*`, "/foo/a"},
+		{"/foo/[", "/foo/["},
+		{"*", "/foo"},
+		{"/*", "/foo"},
+	}
+	for _, tt := range tests {
+		want, _ := path.Match(tt.pattern, tt.uri)
+		if got := newGlobPattern(tt.pattern).match(tt.uri); got != want {
+			t.Errorf("%s %s: got %v want %v", tt.pattern, tt.uri, got, want)
+		}
+	}
+}
+
+func TestRegexpMatcher(t *testing.T) {
+	routeUser := newRoute("www.example.com", "/user/([0-9]+)")
+	routeInvalid := newRoute("www.example.com", "/foo(")
+
+	tests := []struct {
+		uri   string
+		want  bool
+		route *Route
+	}{
+		{"/user/123", true, routeUser},
+		{"/user/123?a=b", true, routeUser},
+		{"/user/abc", false, routeUser},
+		{"/user/123/x", false, routeUser},
+		{"/x/user/123", false, routeUser},
+		{"/foo(", false, routeInvalid},
+	}
+
+	for _, tt := range tests {
+		if got := regexpMatcher(tt.uri, tt.route); got != tt.want {
+			t.Errorf("%s: got %v want %v", tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestRewritePath(t *testing.T) {
+	defer SetMatcher("prefix")
+
+	tbl, err := ParseString(`
+route add svc /user/([0-9]+)/(?P<rest>.*) http://foo.com/v2/users/$1/${rest}
+route add svc /static/.* http://foo.com/assets/
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, static := tbl[""][0].Targets[0], tbl[""][1].Targets[0]
+	if user.route.Path != "/user/([0-9]+)/(?P<rest>.*)" {
+		user, static = static, user
+	}
+
+	// only the regexp matcher rewrites the path
+	if _, ok := user.RewritePath("/user/12/a/b"); ok {
+		t.Fatal("got rewrite for prefix matcher")
+	}
+
+	if err := SetMatcher("regexp"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		target *Target
+		path   string
+		want   string
+		ok     bool
+	}{
+		{user, "/user/12/a/b", "/v2/users/12/a/b", true},
+		{user, "/user/x/a", "", false},
+		{static, "/static/app.js", "", false},
+	}
+	for i, tt := range tests {
+		got, ok := tt.target.RewritePath(tt.path)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%d: got %q, %v want %q, %v", i, got, ok, tt.want, tt.ok)
+		}
+	}
+}
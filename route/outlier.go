@@ -0,0 +1,200 @@
+package route
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eBay/fabio/metrics"
+	"github.com/eBay/fabio/stats"
+)
+
+// OutlierDetection configures the ejection of targets whose latency
+// is much higher than the latency of the other targets of the route.
+type OutlierDetection struct {
+	// Multiple is the factor by which the latency percentile of a
+	// target must exceed the median of the route to be ejected.
+	// Zero disables the detection.
+	Multiple float64
+
+	// Percentile is the latency percentile which is compared,
+	// e.g. 0.99 for the p99 latency.
+	Percentile float64
+
+	// MinRequests is the number of requests a target must have
+	// received within Window to be considered.
+	MinRequests int64
+
+	// Window is the time span of the latencies which are compared.
+	Window time.Duration
+
+	// Interval is the time between two detection runs.
+	Interval time.Duration
+
+	// Cooldown is the time for which a target is ejected.
+	Cooldown time.Duration
+
+	// MaxEjection is the maximum fraction of the targets of a
+	// route which can be ejected at the same time.
+	MaxEjection float64
+}
+
+// outliers contains the ejected targets by target key and the time
+// until they are ejected. readmitted contains the time when a target
+// was readmitted so that only its new latencies are considered.
+var outliers = struct {
+	sync.Mutex
+	ejected    map[string]time.Time
+	readmitted map[string]time.Time
+}{ejected: map[string]time.Time{}, readmitted: map[string]time.Time{}}
+
+// ejectedKeys contains a copy of the keys of the ejected targets
+// for the lookups which must not block.
+var ejectedKeys atomic.Value
+
+func init() {
+	ejectedKeys.Store(map[string]bool{})
+}
+
+// Ejected returns true if the target has been ejected by the
+// outlier detection and should not receive requests.
+func (t *Target) Ejected() bool {
+	m := ejectedKeys.Load().(map[string]bool)
+	return len(m) > 0 && m[targetKey(t)]
+}
+
+// pickAvailable returns the target from the picker unless it has been
+// ejected. Then the next target which is not ejected is used starting
+// at a random position. If all targets are ejected the picked target
+// is returned.
+func pickAvailable(r *Route) *Target {
+	t := pick(r)
+	if !t.Ejected() {
+		return t
+	}
+	n := len(r.wTargets)
+	start := randIntn(n)
+	for i := 0; i < n; i++ {
+		if c := r.wTargets[(start+i)%n]; !c.Ejected() {
+			return c
+		}
+	}
+	return t
+}
+
+// DetectOutliers runs the outlier detection on the active routing
+// table in the configured interval until the context is done.
+func DetectOutliers(ctx context.Context, cfg OutlierDetection) {
+	if cfg.Multiple <= 0 {
+		return
+	}
+	log.Printf("[INFO] Ejecting targets with a p%g latency above %gx of the route median for %s", cfg.Percentile*100, cfg.Multiple, cfg.Cooldown)
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			detectOutliers(GetTable(), stats.Default, cfg)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// detectOutliers readmits the targets whose cooldown has ended and
+// ejects the targets of the routes in the table whose latency
+// percentile exceeds the median of the route by the configured
+// multiple. The routes need at least three targets with enough
+// requests.
+func detectOutliers(t Table, c *stats.Collector, cfg OutlierDetection) {
+	now := now()
+
+	outliers.Lock()
+	defer outliers.Unlock()
+
+	for key, until := range outliers.ejected {
+		if now.Before(until) {
+			continue
+		}
+		log.Printf("[INFO] Readmitting target %s", key)
+		delete(outliers.ejected, key)
+		outliers.readmitted[key] = now
+	}
+
+	active := map[string]bool{}
+	for _, routes := range t {
+		for _, r := range routes {
+			for _, tg := range r.Targets {
+				active[targetKey(tg)] = true
+			}
+			ejectOutliers(r, c, cfg, now)
+		}
+	}
+
+	keys := map[string]bool{}
+	for key := range outliers.ejected {
+		if !active[key] {
+			delete(outliers.ejected, key)
+			continue
+		}
+		keys[key] = true
+	}
+	for key := range outliers.readmitted {
+		if !active[key] {
+			delete(outliers.readmitted, key)
+		}
+	}
+	ejectedKeys.Store(keys)
+	metrics.DefaultRegistry.GetGauge("outliers.ejected").Update(int64(len(keys)))
+}
+
+// ejectOutliers ejects the targets of the route whose latency exceeds
+// the median. It must be called with the outliers lock held.
+func ejectOutliers(r *Route, c *stats.Collector, cfg OutlierDetection, now time.Time) {
+	type sample struct {
+		key     string
+		latency time.Duration
+	}
+
+	var samples []sample
+	ejected := 0
+	for _, t := range r.Targets {
+		key := targetKey(t)
+		if _, ok := outliers.ejected[key]; ok {
+			ejected++
+			continue
+		}
+		since := now.Add(-cfg.Window)
+		if t0, ok := outliers.readmitted[key]; ok && t0.After(since) {
+			since = t0
+		}
+		d, n := c.Latency(t.Route, t.Service, t.URL.Host, cfg.Percentile, since)
+		if n < cfg.MinRequests {
+			continue
+		}
+		samples = append(samples, sample{key, d})
+	}
+	if len(samples) < 3 {
+		return
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i].latency < samples[j].latency })
+	median := samples[(len(samples)-1)/2].latency
+	limit := time.Duration(float64(median) * cfg.Multiple)
+	max := int(cfg.MaxEjection * float64(len(r.Targets)))
+
+	// eject the slowest targets first
+	for i := len(samples) - 1; i >= 0 && ejected < max; i-- {
+		s := samples[i]
+		if s.latency <= limit {
+			break
+		}
+		log.Printf("[INFO] Ejecting target %s for %s. p%g latency %s is above %s", s.key, cfg.Cooldown, cfg.Percentile*100, s.latency, limit)
+		outliers.ejected[s.key] = now.Add(cfg.Cooldown)
+		delete(outliers.readmitted, s.key)
+		ejected++
+	}
+}
@@ -0,0 +1,109 @@
+package route
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/stats"
+)
+
+func TestDetectOutliers(t *testing.T) {
+	defer func(fn func() time.Time) { now = fn }(now)
+	reset := func() {
+		outliers.ejected, outliers.readmitted = map[string]time.Time{}, map[string]time.Time{}
+		ejectedKeys.Store(map[string]bool{})
+	}
+	reset()
+	defer reset()
+
+	t0 := time.Now()
+	now = func() time.Time { return t0 }
+
+	tbl, err := ParseString(`
+	route add svc /a http://a:1/
+	route add svc /a http://b:1/
+	route add svc /a http://c:1/
+	route add svc /a http://d:1/
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := stats.New()
+	latency := map[string]time.Duration{"a:1": 10 * time.Millisecond, "b:1": 12 * time.Millisecond, "c:1": 11 * time.Millisecond, "d:1": 200 * time.Millisecond}
+	for host, d := range latency {
+		for i := 0; i < 20; i++ {
+			c.Record("/a", "svc", host, 200, 0, d)
+		}
+	}
+
+	cfg := OutlierDetection{Multiple: 3, Percentile: 0.99, MinRequests: 10, Window: time.Minute, Cooldown: 30 * time.Second, MaxEjection: 0.5}
+	detectOutliers(tbl, c, cfg)
+
+	ejected := func() (hosts []string) {
+		for _, tg := range tbl[""][0].Targets {
+			if tg.Ejected() {
+				hosts = append(hosts, tg.URL.Host)
+			}
+		}
+		return hosts
+	}
+	if got := ejected(); len(got) != 1 || got[0] != "d:1" {
+		t.Fatalf("got ejected %v want [d:1]", got)
+	}
+
+	// the ejected target receives no requests
+	r := tbl[""][0]
+	for i := 0; i < 100; i++ {
+		if tg := r.Lookup(nil); tg.URL.Host == "d:1" {
+			t.Fatal("ejected target picked")
+		}
+	}
+
+	// the target is readmitted after the cooldown and its
+	// old latencies are ignored
+	now = func() time.Time { return t0.Add(31 * time.Second) }
+	detectOutliers(tbl, c, cfg)
+	if got := ejected(); len(got) != 0 {
+		t.Fatalf("got ejected %v after cooldown", got)
+	}
+}
+
+func TestDetectOutliersMaxEjection(t *testing.T) {
+	defer func(fn func() time.Time) { now = fn }(now)
+	defer func() {
+		outliers.ejected, outliers.readmitted = map[string]time.Time{}, map[string]time.Time{}
+		ejectedKeys.Store(map[string]bool{})
+	}()
+	now = time.Now
+
+	tbl, err := ParseString(`
+	route add svc /a http://a:1/
+	route add svc /a http://b:1/
+	route add svc /a http://c:1/
+	route add svc /a http://d:1/
+	route add svc /a http://e:1/
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := stats.New()
+	latency := map[string]time.Duration{"a:1": 10 * time.Millisecond, "b:1": 10 * time.Millisecond, "c:1": 10 * time.Millisecond, "d:1": 100 * time.Millisecond, "e:1": time.Second}
+	for host, d := range latency {
+		for i := 0; i < 20; i++ {
+			c.Record("/a", "svc", host, 200, 0, d)
+		}
+	}
+
+	detectOutliers(tbl, c, OutlierDetection{Multiple: 3, Percentile: 0.99, MinRequests: 10, Window: time.Minute, Cooldown: time.Minute, MaxEjection: 0.2})
+	var got []string
+	for _, tg := range tbl[""][0].Targets {
+		if tg.Ejected() {
+			got = append(got, tg.URL.Host)
+		}
+	}
+	if len(got) != 1 || got[0] != "e:1" {
+		t.Fatalf("got ejected %v want [e:1]", got)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Parse loads a routing table from a set of route commands.
@@ -30,6 +31,180 @@ import (
 // route add <svc> <src> <dst>
 //   - Add route for service svc from src to dst
 //
+// The destination is an http:// or https:// URL or a unix:// URL with the
+// path of a unix domain socket, e.g. unix:///var/run/app.sock. The request
+// path is forwarded unchanged to a unix socket target. The TCP+SNI proxy
+// also connects to the socket of a unix:// target. A file:// URL serves
+// static files from a directory without the path of the route or a single
+// file for all requests, e.g. file:///var/www/maintenance.html
+// Custom builds can handle other schemes with proxy.RegisterTargetHandler,
+// e.g. lambda://fn-name
+//
+// route add <svc> <src> <dst> ... opts "<k1>=<v1> <k2>=<v2> ..."
+//   - Any of the route add commands above can be followed by a
+//     space separated list of options for the route targets.
+//
+//     dialtimeout=<d>:     override proxy.dialtimeout, e.g. dialtimeout=2s
+//     responsetimeout=<d>: override proxy.responseheadertimeout, e.g. responsetimeout=30s
+//     maxrequesttimeout=<d>: cap the deadline from proxy.requesttimeout.header
+//     maxconn=<n>:         limit the number of concurrent requests to the target.
+//                          Requests over the limit are rejected with 503, e.g.
+//                          maxconn=50
+//     maxconn-wait=<d>:    let requests over the maxconn limit wait up to d for a
+//                          free slot before they are rejected, e.g. maxconn-wait=2s
+//     slowstart=<d>:       override proxy.slowstart, e.g. slowstart=2m
+//     maxidleconns=<n>:    override proxy.maxidleconns, e.g. maxidleconns=100
+//     idleconntimeout=<d>: override proxy.idleconntimeout, e.g. idleconntimeout=90s
+//     disablekeepalives:   do not reuse the upstream connections for this target.
+//     maxbps-in=<n>:       limit the bandwidth of every request body or connection
+//                          from the client to the target to n bits per second. n
+//                          can have a k, m or g suffix, e.g. maxbps-in=10m
+//     maxbps-out=<n>:      same as maxbps-in for the response or the data from
+//                          the target to the client, e.g. for bulk downloads.
+//     checksum=<algo>:     verify the response body against the checksum from the
+//                          upstream server. algo is one of md5, sha1, sha256, sha512.
+//     checksumheader=<h>:  response header with the checksum. Defaults to 'Content-MD5'
+//                          for md5 and the RFC 3230 'Digest' header otherwise.
+//     fallback=<svc>:      retry requests without a body once against a target of
+//                          service svc if the target responds with a 5xx status
+//                          or cannot be reached.
+//     fallbackstatus=<s>:  comma separated list of status codes which trigger the
+//                          fallback, e.g. fallbackstatus=502,503,504. Requests which
+//                          fail without a response always trigger the fallback.
+//     followredirects=<n>: follow up to n redirects of the upstream server for
+//                          requests without a body instead of returning them to
+//                          the client, e.g. for backends which redirect to
+//                          internal hosts.
+//     methodoverride:      honor the X-HTTP-Method-Override header of POST requests.
+//                          The override method is used to match the targets, e.g.
+//                          with the 'methods' option, and by the middleware.
+//     methodoverride=rewrite: also send the request with the override method upstream
+//                          and remove the header.
+//     auth=<name>:         require basic auth credentials from the auth source
+//                          with the given name. See proxy.auth.
+//     jwt=<name>:          require a valid JSON web token from the issuer with
+//                          the given name as bearer token. See proxy.jwt.
+//     oidc=<name>:         require a login with the OpenID Connect provider with
+//                          the given name. See proxy.oidc.
+//     extauthz=<name>:     ask the external authorization service with the given
+//                          name whether the request is allowed. See proxy.extauthz.
+//     allow=<rules>:       comma separated list of client networks which are
+//                          allowed, e.g. allow=ip:10.0.0.0/8,ip:192.168.0.1
+//     deny=<rules>:        comma separated list of client networks which are
+//                          rejected. deny takes precedence over allow.
+//     clientcert-cn=<re>:  require a verified client certificate with a common
+//                          name which matches the regular expression, e.g.
+//                          clientcert-cn=admin-.* The listener must have a
+//                          clientca to verify client certificates.
+//     clientcert-ou=<re>:  require a verified client certificate with an
+//                          organizational unit which matches the expression.
+//     clientcert-san=<re>: require a verified client certificate with a DNS name,
+//                          email address, IP address or URI in the subject
+//                          alternative names which matches the expression.
+//     cors-origins=<list>: comma separated list of origins which are allowed to
+//                          send cross-origin requests, e.g. cors-origins=https://a.com
+//                          '*' allows all origins. fabio answers the preflight
+//                          requests and adds the CORS headers to the responses.
+//     cors-methods=<list>: comma separated list of allowed methods. Defaults to
+//                          GET,HEAD,POST
+//     cors-headers=<list>: comma separated list of allowed request headers, e.g.
+//                          cors-headers=Content-Type,X-Token '*' allows all headers.
+//     cors-maxage=<d>:     time browsers can cache the preflight result, e.g. cors-maxage=1h
+//     cors-credentials:    allow requests with cookies or HTTP authentication.
+//     respheader.<name>=<tmpl>: set the response header <name> to the value of
+//                          the template, e.g. respheader.X-Route={{.RouteSrc}}
+//                          See proxy.header.response for the variables.
+//     meta.<key>=<value>:  metadata of the target which is shown in the UI, e.g.
+//                          meta.version=1.2. The consul backend adds the
+//                          service metadata as meta.<key> options.
+//     strip-query=<list>:  comma separated list of query parameters which are removed
+//                          from the upstream request, e.g. strip-query=debug,trace
+//     set-query=<list>:    comma separated list of name:value pairs which replace the
+//                          query parameters of the same name, e.g. set-query=lang:en
+//     add-query=<list>:    comma separated list of name:value pairs which are added to
+//                          the query parameters, e.g. add-query=version:2
+//                          strip-query is applied before set-query and add-query.
+//     sts.maxage=<n>:      set the Strict-Transport-Security header with a max-age of
+//                          n seconds on responses via HTTPS listeners. See
+//                          proxy.header.sts.maxage. sts.subdomains and sts.preload
+//                          add the includeSubDomains and preload directives.
+//     frameoptions=<v>:    set the X-Frame-Options header on responses via HTTPS
+//                          listeners, e.g. frameoptions=DENY
+//     contenttypeoptions=<v>: set the X-Content-Type-Options header on responses
+//                          via HTTPS listeners, e.g. contenttypeoptions=nosniff
+//     csp=<policy>:        set the Content-Security-Policy header on responses via
+//                          HTTPS listeners. The policy is percent-encoded since it
+//                          cannot contain spaces, e.g. csp=default-src%20'self'
+//     setcachecontrol=<v>: replace the Cache-Control header of responses with a
+//                          status code below 400 with <v>, e.g.
+//                          setcachecontrol=public,max-age=3600
+//     cache=<d>:           serve GET requests from the response cache, e.g. cache=5m
+//                          The Cache-Control header of the response takes precedence
+//                          over d. Requests with cookies or credentials and routes
+//                          with auth, jwt, oidc or extauthz are not cached.
+//                          See proxy.cache.size.
+//     stale-while-revalidate=<d>: serve stale cached responses for d while they
+//                          are refreshed in the background, e.g.
+//                          stale-while-revalidate=30s
+//     stale-if-error=<d>:  serve stale cached responses for d when the target
+//                          fails or responds with a 5xx status code, e.g.
+//                          stale-if-error=1h
+//     maintenance=<code>:  respond to all requests with the status code without
+//                          contacting the target. The code defaults to 503 and the
+//                          body is the error page from proxy.errorpages or the
+//                          status text. See also the /api/maintenance endpoint.
+//     maintenance-retryafter=<d>: send a Retry-After header with the maintenance
+//                          response, e.g. maintenance-retryafter=10m
+//     delay=<d>[:<ratio>]: add the latency d to the fraction ratio of the requests
+//                          for chaos experiments, e.g. delay=200ms:0.1 The ratio
+//                          defaults to 1.
+//     abort=<code>[:<ratio>]: respond to the fraction ratio of the requests with the
+//                          status code without contacting the target, e.g.
+//                          abort=503:0.01 The ratio defaults to 1.
+//     tlspin=<pins>:       comma separated list of sha256:<base64> hashes of the
+//                          subject public key info of which the certificate of an
+//                          https upstream must match one.
+//     tlspinonly:          verify the upstream certificate only against the
+//                          tlspin hashes and skip the CA validation.
+//     tlsskipverify=true:  do not verify the certificate of an https upstream.
+//                          See proxy.tls.skipverify.
+//     tlscs=<name>:        present the client certificate from the certificate
+//                          source with the given name to an https upstream.
+//                          See proxy.tls.cs.
+//     proto=https:         connect to the targets of a urlprefix- tag from consul
+//                          with https instead of http.
+//     mirror=<url>:        send a copy of every request to the server at <url>, e.g.
+//                          mirror=http://staging:8080. The response is discarded
+//                          and requests with a body larger than 1MB are not copied.
+//     informational:       forward informational 1xx responses like 103 Early Hints
+//                          from the upstream server to the client.
+//     idempotency:         replay the stored response for retries of requests with
+//                          the same Idempotency-Key header. See proxy.idempotency.size.
+//     match-header=<h>:<v>: the target only receives requests with the header h
+//                          and the value v, e.g. match-header=X-Beta:true. Without
+//                          a value the header only has to be present. Requests
+//                          which do not match are sent to the other targets.
+//     match-cookie=<c>:<v>: same as match-header for the cookie c.
+//     src=<nets>:          the target only receives requests from clients in the
+//                          comma separated list of networks, e.g. src=10.0.0.0/8.
+//                          Like match-header the other requests are sent to the
+//                          other targets.
+//     methods=<m>:         the target only receives requests with one of the comma
+//                          separated HTTP methods, e.g. methods=GET,HEAD to send the
+//                          reads to replicas and the writes to the other targets.
+//     check=<path>:        path for the health check of the target which is run
+//                          on demand via the /api/check endpoint. Without it the
+//                          check only opens a TCP connection.
+//     deploy=<id>:         the target belongs to the deployment with the given id
+//                          and is only added when the deployment is active.
+//
+// route deploy <id>
+//   - Activate all targets with the deploy=<id> option. Targets of deployments
+//     which are not activated are ignored. Since the whole table is swapped at
+//     once all routes of a deployment become visible or disappear together,
+//     e.g. by replacing 'route deploy v1' with 'route deploy v2' in the manual
+//     overrides. The position of the command in the table does not matter.
+//
 // route del <svc> <src> <dst>
 //   - Remove route matching svc, src and dst
 //
@@ -58,6 +233,20 @@ import (
 //
 //    Note that the total sum of traffic sent to all matching routes is w%.
 //
+// route weight <svc> <src> weight <w> ramp <to>:<d> tags "<t1>,<t2>,..."
+//   - All of the route weight commands above can be followed by a ramp
+//     which changes the weight linearly from w to <to> over the duration d,
+//     e.g. 'weight 0.05 ramp 1.0:30m' for a canary which starts with 5% of
+//     the traffic and receives all of it after 30 minutes. The ramp starts
+//     when the command is first seen and starts over when the command is
+//     changed or removed and added again.
+//
+// The host of src can start with a '*.' wildcard label, e.g.
+// *.example.com/, which matches all subdomains. Routes of the exact host
+// are tried first and then the wildcard hosts from the most to the least
+// specific one. The TCP+SNI proxy uses the host/ routes for the server
+// name of the TLS connection.
+//
 func Parse(r io.Reader) (Table, error) {
 	p := &parser{t: make(Table)}
 	if err := p.parse(r); err != nil {
@@ -85,6 +274,14 @@ type parser struct {
 	t          Table
 	lineNumber int
 	line       string
+
+	// deploys contains the ids of the active deployments.
+	deploys map[string]bool
+
+	// validate enables the additional checks of Validate which
+	// collects the errors of all lines in errs.
+	validate bool
+	errs     []*LineError
 }
 
 type cmdFn func(s string) error
@@ -94,12 +291,32 @@ func (p *parser) parse(r io.Reader) error {
 		"route add ":    p.routeAdd,
 		"route del ":    p.routeDel,
 		"route weight ": p.routeWeight,
+		"route deploy ": p.routeDeploy,
 	}
 
+	var lines []string
 	sc := bufio.NewScanner(r)
 	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	// the active deployments must be known before the
+	// routes are added since the command can appear anywhere.
+	for _, line := range lines {
+		if m := routeDeploy.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			if p.deploys == nil {
+				p.deploys = map[string]bool{}
+			}
+			p.deploys[m[1]] = true
+		}
+	}
+
+	for _, line := range lines {
 		p.lineNumber++
-		p.line = strings.TrimSpace(sc.Text())
+		p.line = strings.TrimSpace(line)
 		if p.line == "" || strings.HasPrefix(p.line, "#") {
 			continue
 		}
@@ -110,11 +327,17 @@ func (p *parser) parse(r io.Reader) error {
 				break
 			}
 		}
+		var err error
 		if fn == nil {
-			return p.syntaxError()
+			err = p.syntaxError()
+		} else {
+			err = fn(p.line)
 		}
-		if err := fn(p.line); err != nil {
-			return err
+		if err != nil {
+			if !p.validate {
+				return err
+			}
+			p.report(err)
 		}
 	}
 	return nil
@@ -132,18 +355,28 @@ var (
 
 	// route add <svc> <src> <dst>
 	routeAddSvc = regexp.MustCompile(`^route add (\S+) (\S+) (\S+)$`)
+
+	// ... opts "<k1>=<v1> <k2>=<v2> ..."
+	routeAddOpts = regexp.MustCompile(` opts "([^"]*)"$`)
 )
 
 func (p *parser) routeAdd(s string) error {
 	var svc, src, dst string
 	var tags []string
+	var opts map[string]string
 	var w float64
 	var err error
 
+	// strip the options first since they can follow all variants
+	if m := routeAddOpts.FindStringSubmatchIndex(s); m != nil {
+		opts = ParseOpts(s[m[2]:m[3]])
+		s = s[:m[0]]
+	}
+
 	// test most to least specific
 	if m := routeAddSvcWeightTags.FindStringSubmatch(s); m != nil {
-		svc, src, dst, tags = m[1], m[2], m[4], strings.Split(m[5], ",")
-		w, err = p.parseWeight(m[3])
+		svc, src, dst, tags = m[1], m[2], m[3], strings.Split(m[5], ",")
+		w, err = p.parseWeight(m[4])
 	} else if m := routeAddSvcWeight.FindStringSubmatch(s); m != nil {
 		svc, src, dst = m[1], m[2], m[3]
 		w, err = p.parseWeight(m[4])
@@ -159,10 +392,42 @@ func (p *parser) routeAdd(s string) error {
 		return err
 	}
 
-	p.t.AddRoute(svc, src, dst, w, tags)
+	// validation does not build the table
+	if p.validate {
+		p.checkTarget(dst)
+		p.checkWeight(w)
+		p.checkOpts(opts)
+		return nil
+	}
+
+	// skip targets of inactive deployments
+	if id, ok := opts["deploy"]; ok && !p.deploys[id] {
+		return nil
+	}
+
+	p.t.AddRouteOpts(svc, src, dst, w, tags, opts)
 	return nil
 }
 
+// ParseOpts parses a space separated list of route options
+// of the form "k1=v1 k2=v2 ..." into a map. Options without
+// a value are stored with an empty value.
+func ParseOpts(s string) map[string]string {
+	var opts map[string]string
+	for _, f := range strings.Fields(s) {
+		if opts == nil {
+			opts = map[string]string{}
+		}
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) == 1 {
+			opts[kv[0]] = ""
+			continue
+		}
+		opts[kv[0]] = kv[1]
+	}
+	return opts
+}
+
 var (
 	// route del <svc> <src> <dst>
 	routeDelSvcSrcDst = regexp.MustCompile(`^route del (\S+) (\S+) (\S+)$`)
@@ -206,6 +471,9 @@ var (
 
 	// route weight <svc> <src> weight <w>'
 	routeWeightSvcSrc = regexp.MustCompile(`^route weight (\S+) (\S+) weight (\S+)$`)
+
+	// ... weight <w> ramp <to>:<d> ...
+	routeWeightRamp = regexp.MustCompile(` ramp (\S+)`)
 )
 
 func (p *parser) routeWeight(s string) error {
@@ -214,6 +482,13 @@ func (p *parser) routeWeight(s string) error {
 	var w float64
 	var err error
 
+	// strip the ramp first since it can follow all variants
+	var ramp string
+	if m := routeWeightRamp.FindStringSubmatchIndex(s); m != nil {
+		ramp = s[m[2]:m[3]]
+		s = s[:m[0]] + s[m[1]:]
+	}
+
 	// test most to least specific
 	if m := routeWeightSvcSrcTags.FindStringSubmatch(s); m != nil {
 		svc, src, tags = m[1], m[2], strings.Split(m[4], ",")
@@ -232,7 +507,43 @@ func (p *parser) routeWeight(s string) error {
 		return err
 	}
 
-	p.t.AddRouteWeight(svc, src, w, tags)
+	var to float64
+	var d time.Duration
+	if ramp != "" {
+		var ok bool
+		if to, d, ok = parseRamp(ramp); !ok {
+			return p.errorf("invalid ramp: %s", ramp)
+		}
+	}
+
+	// validation does not build the table
+	if p.validate {
+		p.checkWeight(w)
+		if ramp != "" {
+			p.checkWeight(to)
+		}
+		return nil
+	}
+
+	if ramp == "" {
+		p.t.AddRouteWeight(svc, src, w, tags)
+		return nil
+	}
+
+	r := newRamp(p.line, w, to, d)
+	p.t.addRouteWeight(svc, src, r.Weight(now()), tags, r)
+	return nil
+}
+
+// route deploy <id>
+var routeDeploy = regexp.MustCompile(`^route deploy (\S+)$`)
+
+// routeDeploy validates the command. The active deployments
+// are collected before the table is built.
+func (p *parser) routeDeploy(s string) error {
+	if !routeDeploy.MatchString(s) {
+		return p.syntaxError()
+	}
 	return nil
 }
 
@@ -245,9 +556,9 @@ func (p *parser) parseWeight(s string) (float64, error) {
 }
 
 func (p *parser) syntaxError() error {
-	return fmt.Errorf("route: line %d: syntax error in %s", p.lineNumber, p.line)
+	return p.errorf("syntax error in %s", p.line)
 }
 
-func (p *parser) errorf(msg string, args ...string) error {
-	return fmt.Errorf("route: line %d: %s", p.lineNumber, fmt.Sprintf(msg, args))
+func (p *parser) errorf(msg string, args ...interface{}) error {
+	return &LineError{Line: p.lineNumber, Command: p.line, Message: fmt.Sprintf(msg, args...)}
 }
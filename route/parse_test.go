@@ -0,0 +1,177 @@
+package route
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseOpts(t *testing.T) {
+	tests := []struct {
+		in  string
+		out map[string]string
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"a=b", map[string]string{"a": "b"}},
+		{"a=b c=d", map[string]string{"a": "b", "c": "d"}},
+		{" a=b   c ", map[string]string{"a": "b", "c": ""}},
+		{"a=b=c", map[string]string{"a": "b=c"}},
+	}
+
+	for i, tt := range tests {
+		if got, want := ParseOpts(tt.in), tt.out; !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: got %v want %v", i, got, want)
+		}
+	}
+}
+
+func TestParseRouteOpts(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{
+			`route add svc /foo http://bar:111/ opts "responsetimeout=30s dialtimeout=2s"`,
+			`route add svc /foo http://bar:111/ opts "dialtimeout=2s responsetimeout=30s"`,
+		},
+		{
+			`route add svc /foo http://bar:111/ weight 0.5 opts "dialtimeout=2s"`,
+			`route add svc /foo http://bar:111/ weight 0.50 opts "dialtimeout=2s"`,
+		},
+		{
+			`route add svc /foo http://bar:111/ weight 0.5 tags "a,b"`,
+			`route add svc /foo http://bar:111/ weight 0.50 tags "a,b"`,
+		},
+		{
+			`route add svc /foo http://bar:111/ tags "a,b" opts "dialtimeout=2s"`,
+			`route add svc /foo http://bar:111/ tags "a,b" opts "dialtimeout=2s"`,
+		},
+	}
+
+	for i, tt := range tests {
+		tbl, err := ParseString(tt.in)
+		if err != nil {
+			t.Fatalf("%d: got %v want nil", i, err)
+		}
+		if got, want := tbl.String(), tt.out; got != want {
+			t.Errorf("%d: got %q want %q", i, got, want)
+		}
+	}
+}
+
+func TestRouteTimeoutOpts(t *testing.T) {
+	tbl, err := ParseString(`route add svc /foo http://bar:111/ opts "dialtimeout=2s responsetimeout=30s"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tg := tbl[""][0].Targets[0]
+	if got, want := tg.DialTimeout, 2*time.Second; got != want {
+		t.Errorf("got dial timeout %v want %v", got, want)
+	}
+	if got, want := tg.ResponseTimeout, 30*time.Second; got != want {
+		t.Errorf("got response timeout %v want %v", got, want)
+	}
+
+	tbl, err = ParseString(`route add svc /foo http://bar:111/ opts "dialtimeout=foo"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tbl[""][0].Targets[0].DialTimeout, time.Duration(0); got != want {
+		t.Errorf("got dial timeout %v want %v", got, want)
+	}
+}
+
+func TestRouteBandwidthOpts(t *testing.T) {
+	tbl, err := ParseString(`route add svc /foo http://bar:111/ opts "maxbps-in=512k maxbps-out=10M"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tg := tbl[""][0].Targets[0]
+	if got, want := tg.MaxBpsIn, int64(512000); got != want {
+		t.Errorf("got maxbps-in %d want %d", got, want)
+	}
+	if got, want := tg.MaxBpsOut, int64(10000000); got != want {
+		t.Errorf("got maxbps-out %d want %d", got, want)
+	}
+
+	tbl, err = ParseString(`route add svc /foo http://bar:111/ opts "maxbps-in=fast maxbps-out=-1"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tg = tbl[""][0].Targets[0]
+	if tg.MaxBpsIn != 0 || tg.MaxBpsOut != 0 {
+		t.Errorf("got %d, %d want 0, 0", tg.MaxBpsIn, tg.MaxBpsOut)
+	}
+}
+
+func TestRouteResponseHeaderOpts(t *testing.T) {
+	tbl, err := ParseString(`route add svc /foo http://bar:111/ opts "respheader.x-route={{.RouteSrc}} respheader.x-bad={{.Foo respheader.={{.Host}}"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tg := tbl[""][0].Targets[0]
+	if got, want := len(tg.ResponseHeaders), 1; got != want {
+		t.Fatalf("got %d templates want %d", got, want)
+	}
+	if tg.ResponseHeaders["X-Route"] == nil {
+		t.Fatalf("got %v want X-Route template", tg.ResponseHeaders)
+	}
+	if got, want := tg.Route, "/foo"; got != want {
+		t.Errorf("got route %q want %q", got, want)
+	}
+}
+
+func TestRouteMetaOpts(t *testing.T) {
+	tbl, err := ParseString(`route add svc /foo http://bar:111/ opts "meta.version=1.2 meta.team=web meta. dialtimeout=2s"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tg := tbl[""][0].Targets[0]
+	if got, want := tg.Meta, map[string]string{"version": "1.2", "team": "web"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestParseRouteDeploy(t *testing.T) {
+	routes := `
+route add a /a http://1:111/
+route add a /a http://2:222/ opts "deploy=v1"
+route add b /b http://3:333/ opts "deploy=v1"
+route add a /a http://4:444/ opts "deploy=v2"
+route add b /b http://5:555/ opts "deploy=v2"
+`
+
+	tests := []struct {
+		desc, in, out string
+	}{
+		{
+			"no active deployment",
+			routes,
+			`route add a /a http://1:111/`,
+		},
+		{
+			"v1 active",
+			routes + "route deploy v1",
+			"route add b /b http://3:333/ opts \"deploy=v1\"\nroute add a /a http://1:111/\nroute add a /a http://2:222/ opts \"deploy=v1\"",
+		},
+		{
+			"v2 active before routes",
+			"route deploy v2\n" + routes,
+			"route add b /b http://5:555/ opts \"deploy=v2\"\nroute add a /a http://1:111/\nroute add a /a http://4:444/ opts \"deploy=v2\"",
+		},
+	}
+
+	for _, tt := range tests {
+		tbl, err := ParseString(tt.in)
+		if err != nil {
+			t.Fatalf("%s: got %v want nil", tt.desc, err)
+		}
+		if got, want := tbl.String(), tt.out; got != want {
+			t.Errorf("%s: got %q want %q", tt.desc, got, want)
+		}
+	}
+
+	if _, err := ParseString("route deploy v1 v2"); err == nil {
+		t.Error("got nil want syntax error")
+	}
+}
@@ -2,29 +2,107 @@ package route
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
-// pick contains the picker function.
-var pick picker = rndPicker
+// pickFn contains the picker function. It can be
+// changed at runtime and is therefore stored atomically.
+var pickFn atomic.Value
 
-// Picker selects a target from a list of targets
-type picker func(r *Route) *Target
+var (
+	pickersMu sync.RWMutex
+	pickers   = map[string]Picker{}
+)
+
+func init() {
+	RegisterPicker("rnd", rndPicker)
+	RegisterPicker("rr", rrPicker)
+	RegisterPicker("local", localPicker)
+	localDatacenter.Store("")
+	pickFn.Store(Picker(rndPicker))
+}
+
+// pick calls the current picker function.
+func pick(r *Route) *Target {
+	return pickFn.Load().(Picker)(r)
+}
+
+// Picker selects a target from the weighted list of targets
+// of a route which is returned by r.WeightedTargets().
+type Picker func(r *Route) *Target
+
+// RegisterPicker makes a picker available under the given name
+// for the proxy.strategy option. RegisterPicker panics if the name
+// is empty, the picker is nil or a picker with the same name has
+// already been registered.
+func RegisterPicker(name string, fn Picker) {
+	pickersMu.Lock()
+	defer pickersMu.Unlock()
+
+	if name == "" {
+		panic("route: picker name is empty")
+	}
+	if fn == nil {
+		panic("route: picker " + name + " is nil")
+	}
+	if _, dup := pickers[name]; dup {
+		panic("route: picker " + name + " registered twice")
+	}
+	pickers[name] = fn
+}
+
+// Pickers returns the sorted names of the registered pickers.
+func Pickers() []string {
+	pickersMu.RLock()
+	defer pickersMu.RUnlock()
+
+	var names []string
+	for name := range pickers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
 // SetPickerStrategy sets the picker function for the proxy.
 func SetPickerStrategy(s string) error {
-	switch s {
-	case "rnd":
-		pick = rndPicker
-	case "rr":
-		pick = rrPicker
-	default:
-		return fmt.Errorf("route: invalid strategy: %s", s)
+	pickersMu.RLock()
+	fn := pickers[s]
+	pickersMu.RUnlock()
+
+	if fn == nil {
+		return fmt.Errorf("route: invalid strategy: %s. Valid strategies are %s", s, strings.Join(Pickers(), ", "))
 	}
+	pickFn.Store(fn)
 	return nil
 }
 
+// localDatacenter contains the datacenter of the
+// targets which the 'local' strategy prefers.
+var localDatacenter atomic.Value
+
+// SetLocalDatacenter sets the datacenter of the targets which the
+// 'local' strategy prefers. The targets are matched by their
+// 'dc=<dc>' tag. It applies to the routing tables which are
+// loaded afterwards.
+func SetLocalDatacenter(dc string) {
+	localDatacenter.Store(dc)
+}
+
+// localPicker picks a random target of the local datacenter.
+// Routes without targets in the local datacenter use all
+// targets.
+func localPicker(r *Route) *Target {
+	if n := len(r.localTargets); n > 0 {
+		return r.localTargets[randIntn(n)]
+	}
+	return rndPicker(r)
+}
+
 // rndPicker picks a random target from the list of targets.
 func rndPicker(r *Route) *Target {
 	return r.wTargets[randIntn(len(r.wTargets))]
@@ -13,8 +13,8 @@ var (
 
 func TestRndPicker(t *testing.T) {
 	r := newRoute("www.bar.com", "/foo")
-	r.addTarget("svc", fooDotCom, 0, nil)
-	r.addTarget("svc", barDotCom, 0, nil)
+	r.addTarget("svc", fooDotCom, 0, nil, nil)
+	r.addTarget("svc", barDotCom, 0, nil, nil)
 
 	tests := []struct {
 		rnd       int
@@ -37,8 +37,8 @@ func TestRndPicker(t *testing.T) {
 
 func TestRRPicker(t *testing.T) {
 	r := newRoute("www.bar.com", "/foo")
-	r.addTarget("svc", fooDotCom, 0, nil)
-	r.addTarget("svc", barDotCom, 0, nil)
+	r.addTarget("svc", fooDotCom, 0, nil, nil)
+	r.addTarget("svc", barDotCom, 0, nil, nil)
 
 	tests := []*url.URL{fooDotCom, barDotCom, fooDotCom, barDotCom, fooDotCom, barDotCom}
 
@@ -48,3 +48,62 @@ func TestRRPicker(t *testing.T) {
 		}
 	}
 }
+
+func TestLocalPicker(t *testing.T) {
+	defer SetLocalDatacenter("")
+	SetLocalDatacenter("dc1")
+
+	prev := randIntn
+	defer func() { randIntn = prev }()
+
+	r := newRoute("www.bar.com", "/foo")
+	r.addTarget("svc", fooDotCom, 0, []string{"dc=dc2"}, nil)
+	r.addTarget("svc", barDotCom, 0, []string{"dc=dc1"}, nil)
+	for i := 0; i < 10; i++ {
+		randIntn = func(int) int { return i }
+		if got, want := localPicker(r).URL, barDotCom; !reflect.DeepEqual(got, want) {
+			t.Fatalf("%d: got %v want %v", i, got, want)
+		}
+	}
+
+	// all targets are used without a target in the local datacenter
+	r = newRoute("www.bar.com", "/foo")
+	r.addTarget("svc", fooDotCom, 0, []string{"dc=dc2"}, nil)
+	randIntn = func(int) int { return 0 }
+	if got, want := localPicker(r).URL, fooDotCom; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
+func TestRegisterPicker(t *testing.T) {
+	defer func(fn interface{}) { pickFn.Store(fn) }(pickFn.Load())
+	defer func() {
+		pickersMu.Lock()
+		delete(pickers, "first")
+		pickersMu.Unlock()
+	}()
+
+	first := func(r *Route) *Target { return r.WeightedTargets()[0] }
+	RegisterPicker("first", first)
+
+	if got, want := Pickers(), []string{"first", "local", "rnd", "rr"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got pickers %v want %v", got, want)
+	}
+
+	if err := SetPickerStrategy("first"); err != nil {
+		t.Fatalf("got %v want nil", err)
+	}
+	r := newRoute("www.bar.com", "/foo")
+	r.addTarget("svc", fooDotCom, 0, nil, nil)
+	r.addTarget("svc", barDotCom, 0, nil, nil)
+	for i := 0; i < 3; i++ {
+		if got, want := pick(r).URL, fooDotCom; !reflect.DeepEqual(got, want) {
+			t.Fatalf("%d: got %v want %v", i, got, want)
+		}
+	}
+
+	err := SetPickerStrategy("foo")
+	if got, want := err.Error(), "route: invalid strategy: foo. Valid strategies are first, local, rnd, rr"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
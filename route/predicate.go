@@ -0,0 +1,177 @@
+package route
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Predicate restricts a target to the requests which have a header
+// or cookie with the given name. If Value is not empty the header
+// or cookie must also have this value.
+type Predicate struct {
+	Name  string
+	Value string
+}
+
+// optPredicate returns the predicate of the route option with the
+// given name. The value has the form '<name>:<value>' or '<name>'.
+// Invalid values are logged and ignored.
+func optPredicate(opts map[string]string, name string) *Predicate {
+	v, ok := opts[name]
+	if !ok {
+		return nil
+	}
+	p := strings.SplitN(v, ":", 2)
+	if p[0] == "" {
+		log.Printf("[WARN] Ignoring invalid value %q for route option %s", v, name)
+		return nil
+	}
+	pred := &Predicate{Name: p[0]}
+	if len(p) == 2 {
+		pred.Value = p[1]
+	}
+	return pred
+}
+
+// optSourceNets returns the networks of the 'src' route option.
+// The value is a comma separated list of addresses and networks
+// in CIDR notation with an optional 'ip:' prefix like the access
+// rules. The result is non-nil if the option is set so that an
+// option with only invalid networks matches no clients.
+func optSourceNets(opts map[string]string, name string) []*net.IPNet {
+	v, ok := opts[name]
+	if !ok {
+		return nil
+	}
+	nets := []*net.IPNet{}
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.HasPrefix(s, "ip:") {
+			s = "ip:" + s
+		}
+		n := parseAccessRule(s)
+		if n == nil {
+			log.Printf("[WARN] Ignoring invalid %s network %q", name, s)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// optMethods returns the upper case HTTP methods of the comma
+// separated list of the route option with the given name.
+func optMethods(opts map[string]string, name string) []string {
+	v, ok := opts[name]
+	if !ok {
+		return nil
+	}
+	methods := []string{}
+	for _, m := range strings.Split(v, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			methods = append(methods, strings.ToUpper(m))
+		}
+	}
+	return methods
+}
+
+// matchMethod returns true if the method of the
+// request is one of the methods.
+func matchMethod(r *http.Request, methods []string) bool {
+	method := r.Method
+	if method == "" {
+		method = "GET"
+	}
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSource returns true if the client address
+// of the request is in one of the networks.
+func matchSource(r *http.Request, nets []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && containsIP(nets, ip)
+}
+
+func (p *Predicate) matchHeader(r *http.Request) bool {
+	v, ok := r.Header[http.CanonicalHeaderKey(p.Name)]
+	if !ok {
+		return false
+	}
+	if p.Value == "" {
+		return true
+	}
+	for _, s := range v {
+		if s == p.Value {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Predicate) matchCookie(r *http.Request) bool {
+	c, err := r.Cookie(p.Name)
+	if err != nil {
+		return false
+	}
+	return p.Value == "" || c.Value == p.Value
+}
+
+// predicated returns true if the target is restricted to requests
+// with certain methods, a header or cookie or from certain clients.
+func (t *Target) predicated() bool {
+	return t.MatchHeader != nil || t.MatchCookie != nil || t.MatchSource != nil || t.MatchMethods != nil
+}
+
+// matches returns true if the request satisfies all
+// predicates of the target.
+func (t *Target) matches(r *http.Request) bool {
+	if r == nil {
+		return false
+	}
+	if t.MatchHeader != nil && !t.MatchHeader.matchHeader(r) {
+		return false
+	}
+	if t.MatchCookie != nil && !t.MatchCookie.matchCookie(r) {
+		return false
+	}
+	if t.MatchSource != nil && !matchSource(r, t.MatchSource) {
+		return false
+	}
+	if t.MatchMethods != nil && !matchMethod(r, t.MatchMethods) {
+		return false
+	}
+	return true
+}
+
+// matchTarget returns a target with predicates which match
+// the request or nil if there is none.
+func (r *Route) matchTarget(req *http.Request) *Target {
+	var targets []*Target
+	for _, t := range r.predicated {
+		if t.matches(req) {
+			targets = append(targets, t)
+		}
+	}
+	switch len(targets) {
+	case 0:
+		return nil
+	case 1:
+		return targets[0]
+	default:
+		return targets[randIntn(len(targets))]
+	}
+}
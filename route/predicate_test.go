@@ -0,0 +1,63 @@
+package route
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTableLookupPredicates(t *testing.T) {
+	s := `
+	route add svc / http://foo.com:800
+	route add svc /api http://foo.com:900
+	route add beta /api http://foo.com:1000 opts "match-header=X-Beta:true"
+	route add dark /dark http://foo.com:1100 opts "match-cookie=dark"
+	route add svc /int http://foo.com:1200
+	route add int /int http://foo.com:1300 opts "src=10.0.0.0/8,ip:192.168.1.1"
+	route add primary /db http://foo.com:1400
+	route add replica /db http://foo.com:1500 opts "methods=get,HEAD"
+	`
+
+	tbl, err := ParseString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := func(k, v string) http.Header { return http.Header{k: {v}} }
+	cookie := func(v string) http.Header { return header("Cookie", v) }
+
+	tests := []struct {
+		desc   string
+		method string
+		uri    string
+		hdr    http.Header
+		remote string
+		dst    string
+	}{
+		{"no header", "GET", "/api", nil, "", "http://foo.com:900"},
+		{"header matches", "GET", "/api", header("X-Beta", "true"), "", "http://foo.com:1000"},
+		{"header value differs", "GET", "/api", header("X-Beta", "false"), "", "http://foo.com:900"},
+		{"cookie present", "GET", "/dark", cookie("dark=1"), "", "http://foo.com:1100"},
+		{"cookie missing falls through", "GET", "/dark", cookie("light=1"), "", "http://foo.com:800"},
+		{"internal network", "GET", "/int", nil, "10.1.2.3:1234", "http://foo.com:1300"},
+		{"internal address", "GET", "/int", nil, "192.168.1.1:1234", "http://foo.com:1300"},
+		{"external client", "GET", "/int", nil, "1.2.3.4:1234", "http://foo.com:1200"},
+		{"read", "GET", "/db", nil, "", "http://foo.com:1500"},
+		{"head", "HEAD", "/db", nil, "", "http://foo.com:1500"},
+		{"write", "POST", "/db", nil, "", "http://foo.com:1400"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			req := &http.Request{Method: tt.method, Host: "abc.com", RequestURI: tt.uri, Header: tt.hdr, RemoteAddr: tt.remote}
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+			// the unmatched targets are picked randomly
+			for i := 0; i < 10; i++ {
+				if got, want := tbl.Lookup(req, "").URL.String(), tt.dst; got != want {
+					t.Fatalf("got %v want %v", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package route
+
+import (
+	"log"
+	"net/url"
+	"strings"
+)
+
+// QueryRules contains the changes to the query parameters of the
+// requests to a target before they are sent upstream.
+type QueryRules struct {
+	// Strip contains the names of the parameters which are removed.
+	Strip []string
+
+	// Set contains the parameters which replace all values of
+	// the parameters with the same name.
+	Set url.Values
+
+	// Add contains the parameters which are added to the
+	// existing values.
+	Add url.Values
+}
+
+// optQueryRules returns the query rules from the 'strip-query',
+// 'set-query' and 'add-query' options or nil if none of them is set.
+func optQueryRules(opts map[string]string) *QueryRules {
+	q := &QueryRules{
+		Strip: splitList(opts["strip-query"], func(s string) string { return s }),
+		Set:   optQueryParams(opts, "set-query"),
+		Add:   optQueryParams(opts, "add-query"),
+	}
+	if q.Strip == nil && q.Set == nil && q.Add == nil {
+		return nil
+	}
+	return q
+}
+
+// optQueryParams returns the parameters from the comma separated list
+// of 'name:value' pairs of the route option. Invalid pairs are logged
+// and ignored.
+func optQueryParams(opts map[string]string, name string) url.Values {
+	var params url.Values
+	for _, p := range splitList(opts[name], func(s string) string { return s }) {
+		k, v, ok := parseQueryParam(p)
+		if !ok {
+			log.Printf("[WARN] Ignoring invalid value %q for route option %s", p, name)
+			continue
+		}
+		if params == nil {
+			params = url.Values{}
+		}
+		params.Add(k, v)
+	}
+	return params
+}
+
+func parseQueryParam(s string) (name, value string, ok bool) {
+	i := strings.Index(s, ":")
+	if i <= 0 {
+		return "", "", false
+	}
+	return s[:i], s[i+1:], true
+}
+
+// Apply returns the query string with the parameters stripped,
+// replaced and added in that order. The parameters of the result
+// are sorted by name and invalid parameters are dropped.
+func (q *QueryRules) Apply(rawQuery string) string {
+	if q == nil {
+		return rawQuery
+	}
+	v, _ := url.ParseQuery(rawQuery)
+	for _, name := range q.Strip {
+		v.Del(name)
+	}
+	for name, vals := range q.Set {
+		v[name] = append([]string(nil), vals...)
+	}
+	for name, vals := range q.Add {
+		v[name] = append(v[name], vals...)
+	}
+	return v.Encode()
+}
@@ -0,0 +1,53 @@
+package route
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestOptQueryRules(t *testing.T) {
+	tests := []struct {
+		desc string
+		opts map[string]string
+		want *QueryRules
+	}{
+		{"no rules", map[string]string{"proto": "https"}, nil},
+		{"strip", map[string]string{"strip-query": "debug, trace"}, &QueryRules{Strip: []string{"debug", "trace"}}},
+		{
+			"set and add",
+			map[string]string{"set-query": "lang:en", "add-query": "version:2,tag:a:b,invalid"},
+			&QueryRules{Set: url.Values{"lang": {"en"}}, Add: url.Values{"version": {"2"}, "tag": {"a:b"}}},
+		},
+	}
+	for _, tt := range tests {
+		if got := optQueryRules(tt.opts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v want %+v", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestQueryRulesApply(t *testing.T) {
+	q := &QueryRules{
+		Strip: []string{"debug"},
+		Set:   url.Values{"lang": {"en"}},
+		Add:   url.Values{"tag": {"b"}},
+	}
+	tests := []struct {
+		in, out string
+	}{
+		{"", "lang=en&tag=b"},
+		{"debug=1&x=y", "lang=en&tag=b&x=y"},
+		{"lang=de&lang=fr&tag=a", "lang=en&tag=a&tag=b"},
+	}
+	for _, tt := range tests {
+		if got := q.Apply(tt.in); got != tt.out {
+			t.Errorf("%q: got %q want %q", tt.in, got, tt.out)
+		}
+	}
+
+	var none *QueryRules
+	if got := none.Apply("b=1&a=2"); got != "b=1&a=2" {
+		t.Errorf("got %q for nil rules", got)
+	}
+}
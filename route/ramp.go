@@ -0,0 +1,125 @@
+package route
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ramp describes a weight which increases or decreases linearly
+// from From to To over Duration, starting when the ramp was first
+// seen in the routing table.
+type Ramp struct {
+	From, To float64
+	Duration time.Duration
+	Start    time.Time
+
+	// key identifies the ramp across table updates.
+	key string
+}
+
+// Weight returns the weight of the ramp at the given time.
+func (r *Ramp) Weight(now time.Time) float64 {
+	elapsed := now.Sub(r.Start)
+	switch {
+	case elapsed <= 0:
+		return r.From
+	case elapsed >= r.Duration:
+		return r.To
+	}
+	return r.From + (r.To-r.From)*float64(elapsed)/float64(r.Duration)
+}
+
+// Done returns true if the ramp has reached its final weight.
+func (r *Ramp) Done(now time.Time) bool {
+	return now.Sub(r.Start) >= r.Duration
+}
+
+// String returns the ramp in the config language.
+func (r *Ramp) String() string {
+	return fmt.Sprintf("%s:%s", strconv.FormatFloat(r.To, 'f', -1, 64), r.Duration)
+}
+
+// parseRamp parses a ramp of the form '<to>:<duration>',
+// e.g. '1.0:30m'.
+func parseRamp(s string) (to float64, d time.Duration, ok bool) {
+	p := strings.SplitN(s, ":", 2)
+	if len(p) != 2 {
+		return 0, 0, false
+	}
+	to, err := strconv.ParseFloat(p[0], 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	d, err = time.ParseDuration(p[1])
+	if err != nil || d <= 0 {
+		return 0, 0, false
+	}
+	return to, d, true
+}
+
+// stubbed out for testing
+var now = time.Now
+
+// rampsMu guards rampStarts.
+var rampsMu sync.Mutex
+
+// rampStarts contains the time when a ramp was first seen
+// so that it continues when the routing table is rebuilt.
+var rampStarts = map[string]time.Time{}
+
+// newRamp returns the ramp for the key. The start time is
+// the time when the ramp with this key was first created.
+func newRamp(key string, from, to float64, d time.Duration) *Ramp {
+	rampsMu.Lock()
+	defer rampsMu.Unlock()
+	start, ok := rampStarts[key]
+	if !ok {
+		start = now()
+		rampStarts[key] = start
+	}
+	return &Ramp{From: from, To: to, Duration: d, Start: start, key: key}
+}
+
+// syncRamps forgets the start times of the ramps which are
+// no longer in the table so that they start over when they
+// are added again.
+func syncRamps(t Table) {
+	active := map[string]bool{}
+	for _, routes := range t {
+		for _, r := range routes {
+			for _, tg := range r.Targets {
+				if tg.Ramp != nil {
+					active[tg.Ramp.key] = true
+				}
+			}
+		}
+	}
+
+	rampsMu.Lock()
+	defer rampsMu.Unlock()
+	for key := range rampStarts {
+		if !active[key] {
+			delete(rampStarts, key)
+		}
+	}
+}
+
+// Ramping returns true if the active routing table contains
+// targets with a weight ramp which has not yet finished. The
+// table has to be rebuilt periodically to update their weights.
+func Ramping() bool {
+	t := now()
+	for _, routes := range GetTable() {
+		for _, r := range routes {
+			for _, tg := range r.Targets {
+				if tg.Ramp != nil && !tg.Ramp.Done(t) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package route
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRampWeight(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	r := &Ramp{From: 0.05, To: 1.0, Duration: 10 * time.Minute, Start: start}
+
+	tests := []struct {
+		elapsed time.Duration
+		want    float64
+		done    bool
+	}{
+		{-time.Minute, 0.05, false},
+		{0, 0.05, false},
+		{5 * time.Minute, 0.525, false},
+		{10 * time.Minute, 1.0, true},
+		{time.Hour, 1.0, true},
+	}
+
+	for i, tt := range tests {
+		now := start.Add(tt.elapsed)
+		if got, want := r.Weight(now), tt.want; got != want {
+			t.Errorf("%d: got weight %v want %v", i, got, want)
+		}
+		if got, want := r.Done(now), tt.done; got != want {
+			t.Errorf("%d: got done %v want %v", i, got, want)
+		}
+	}
+}
+
+func TestParseRouteWeightRamp(t *testing.T) {
+	defer func(fn func() time.Time) { now = fn }(now)
+	start := time.Unix(1500000000, 0)
+	now = func() time.Time { return start }
+
+	routes := `
+route add a /a http://1:111/
+route add a /a http://2:222/ tags "canary"
+route weight a /a weight 0.25 ramp 0.75:10m tags "canary"
+`
+
+	parse := func() Table {
+		tbl, err := ParseString(routes)
+		if err != nil {
+			t.Fatalf("got %v want nil", err)
+		}
+		SetTable(tbl)
+		return tbl
+	}
+
+	weight := func(tbl Table) float64 {
+		for _, tg := range tbl[""][0].Targets {
+			if tg.URL.Host == "2:222" {
+				return tg.FixedWeight
+			}
+		}
+		t.Fatal("target not found")
+		return 0
+	}
+
+	defer SetTable(make(Table))
+	if got, want := weight(parse()), 0.25; got != want {
+		t.Fatalf("got weight %v want %v", got, want)
+	}
+	if !Ramping() {
+		t.Fatal("got ramping false want true")
+	}
+
+	// the ramp continues when the table is rebuilt
+	now = func() time.Time { return start.Add(5 * time.Minute) }
+	if got, want := weight(parse()), 0.5; got != want {
+		t.Fatalf("got weight %v want %v", got, want)
+	}
+
+	now = func() time.Time { return start.Add(time.Hour) }
+	if got, want := weight(parse()), 0.75; got != want {
+		t.Fatalf("got weight %v want %v", got, want)
+	}
+	if Ramping() {
+		t.Fatal("got ramping true want false")
+	}
+
+	// the ramp starts over when it is removed and added again
+	SetTable(make(Table))
+	if got, want := weight(parse()), 0.25; got != want {
+		t.Fatalf("got weight %v want %v", got, want)
+	}
+
+	for _, s := range []string{"1.0", "x:10m", "1.0:x", "1.0:0s"} {
+		if _, err := ParseString("route add a /a http://1:111/\nroute weight a /a weight 0.1 ramp " + s); err == nil {
+			t.Errorf("%s: got nil want error", s)
+		}
+	}
+}
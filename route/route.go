@@ -3,9 +3,15 @@ package route
 import (
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/eBay/fabio/metrics"
 )
@@ -33,16 +39,42 @@ type Route struct {
 	// same order as targets
 	wTargets []*Target
 
+	// predicated contains the targets with a header or cookie
+	// predicate. They are not part of wTargets and only receive
+	// the requests which match their predicates.
+	predicated []*Target
+
+	// localTargets contains the entries of wTargets which are
+	// in the local datacenter for the 'local' strategy.
+	localTargets []*Target
+
 	// total contains the total number of requests for this route.
 	// Used by the RRPicker
 	total uint64
+
+	// re is the path as regular expression for the regexp matcher.
+	re     *regexp.Regexp
+	reOnce sync.Once
+
+	// glob is the path prepared for the glob matcher.
+	glob *globPattern
+}
+
+// WeightedTargets returns the targets of the route distributed
+// according to their weight. The list must not be modified.
+func (r *Route) WeightedTargets() []*Target {
+	return r.wTargets
 }
 
 func newRoute(host, path string) *Route {
-	return &Route{Host: host, Path: path}
+	r := &Route{Host: host, Path: path, glob: newGlobPattern(path)}
+	if r.glob.err != nil && matchName.Load() == "glob" {
+		log.Printf("[ERROR] Invalid glob pattern for route %s%s. %s", host, path, r.glob.err)
+	}
+	return r
 }
 
-func (r *Route) addTarget(service string, targetURL *url.URL, fixedWeight float64, tags []string) {
+func (r *Route) addTarget(service string, targetURL *url.URL, fixedWeight float64, tags []string, opts map[string]string) {
 	if fixedWeight < 0 {
 		fixedWeight = 0
 	}
@@ -54,11 +86,221 @@ func (r *Route) addTarget(service string, targetURL *url.URL, fixedWeight float6
 	}
 	timer := ServiceRegistry.GetTimer(name)
 
-	t := &Target{Service: service, Tags: tags, URL: targetURL, FixedWeight: fixedWeight, Timer: timer, timerName: name}
+	t := &Target{Service: service, Route: r.Host + r.Path, Tags: tags, Opts: opts, URL: targetURL, FixedWeight: fixedWeight, Timer: timer, timerName: name, route: r}
+
+	for i, c := range statusClasses {
+		t.statusCounters[i] = ServiceRegistry.GetCounter(metrics.WithSuffix(name, c))
+	}
+	t.ErrorRate = ServiceRegistry.GetFloatGauge(metrics.WithSuffix(name, ".errorrate"))
+
+	t.RouteTimer = metrics.NoopTimer{}
+	if rname, err := metrics.RouteName(service, r.Host, r.Path); err != nil {
+		log.Printf("[ERROR] Invalid route metrics name: %s", err)
+	} else if rname != "" {
+		t.RouteTimer, t.routeTimerName = ServiceRegistry.GetTimer(rname), rname
+	}
+	t.statusName = metrics.StatusName(service, r.Host, r.Path)
+	t.DialTimeout = optDuration(opts, "dialtimeout")
+	t.ResponseTimeout = optDuration(opts, "responsetimeout")
+	t.MaxRequestTimeout = optDuration(opts, "maxrequesttimeout")
+	t.MaxConn = optInt(opts, "maxconn")
+	t.MaxConnWait = optDuration(opts, "maxconn-wait")
+	t.MaxIdleConns = optInt(opts, "maxidleconns")
+	t.IdleConnTimeout = optDuration(opts, "idleconntimeout")
+	t.MaxBpsIn = optBandwidth(opts, "maxbps-in")
+	t.MaxBpsOut = optBandwidth(opts, "maxbps-out")
+	if _, ok := opts["disablekeepalives"]; ok {
+		t.DisableKeepAlives = true
+	}
+	t.MatchHeader = optPredicate(opts, "match-header")
+	t.MatchCookie = optPredicate(opts, "match-cookie")
+	t.MatchSource = optSourceNets(opts, "src")
+	t.MatchMethods = optMethods(opts, "methods")
+	t.Allow = optAccessRules(opts, "allow")
+	t.Deny = optAccessRules(opts, "deny")
+	t.ClientCert = optClientCertRules(opts)
+	t.CORS = optCORS(opts)
+	t.Query = optQueryRules(opts)
+	t.SecurityHeaders = optSecurityHeaders(opts)
+	t.ResponseHeaders = optTemplates(opts, "respheader.")
+	t.Meta = optPrefix(opts, "meta.")
+	t.CacheControl = opts["setcachecontrol"]
+	t.CacheTTL = optDuration(opts, "cache")
+	t.CacheStaleWhileRevalidate = optDuration(opts, "stale-while-revalidate")
+	t.CacheStaleIfError = optDuration(opts, "stale-if-error")
+	t.maintenance = optMaintenance(opts, t.Route)
+	t.Fault = optFault(opts)
+	t.Mirror = optURL(opts, "mirror")
+	t.TLSPins = optTLSPins(opts, "tlspin")
+	if _, ok := opts["tlspinonly"]; ok && t.TLSPins != nil {
+		t.TLSPinOnly = true
+	}
+	t.TLSSkipVerify = opts["tlsskipverify"] == "true"
+	t.TLSCertSource = opts["tlscs"]
+	t.SlowStart = optDuration(opts, "slowstart")
+	if _, ok := opts["slowstart"]; !ok {
+		t.SlowStart = slowStart.Load().(time.Duration)
+	}
+	if t.SlowStart > 0 {
+		t.started = targetStart(t)
+	}
 	r.Targets = append(r.Targets, t)
 	r.weighTargets()
 }
 
+// optDuration returns the value of the route option as duration
+// or zero if the option is not set or invalid.
+func optDuration(opts map[string]string, name string) time.Duration {
+	v, ok := opts[name]
+	if !ok {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Printf("[WARN] Ignoring invalid value %q for route option %s", v, name)
+		return 0
+	}
+	return d
+}
+
+// optInt returns the value of the route option as integer
+// or zero if the option is not set or invalid.
+func optInt(opts map[string]string, name string) int {
+	v, ok := opts[name]
+	if !ok {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Printf("[WARN] Ignoring invalid value %q for route option %s", v, name)
+		return 0
+	}
+	return n
+}
+
+// optBandwidth returns the value of the route option in bits per
+// second or zero if the option is not set or invalid. The value can
+// have a k, m or g suffix for kbit/s, mbit/s and gbit/s.
+func optBandwidth(opts map[string]string, name string) int64 {
+	v, ok := opts[name]
+	if !ok {
+		return 0
+	}
+	n, ok := parseBandwidth(v)
+	if !ok {
+		log.Printf("[WARN] Ignoring invalid value %q for route option %s", v, name)
+		return 0
+	}
+	return n
+}
+
+func parseBandwidth(v string) (int64, bool) {
+	s, mult := strings.ToLower(v), int64(1)
+	switch {
+	case strings.HasSuffix(s, "k"):
+		s, mult = s[:len(s)-1], 1e3
+	case strings.HasSuffix(s, "m"):
+		s, mult = s[:len(s)-1], 1e6
+	case strings.HasSuffix(s, "g"):
+		s, mult = s[:len(s)-1], 1e9
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n * mult, true
+}
+
+// optURL returns the URL of the route option with the given name.
+// Invalid URLs are logged and ignored.
+func optURL(opts map[string]string, name string) *url.URL {
+	v, ok := opts[name]
+	if !ok {
+		return nil
+	}
+	if !validURL(v) {
+		log.Printf("[WARN] Ignoring invalid value %q for route option %s", v, name)
+		return nil
+	}
+	u, _ := url.Parse(v)
+	return u
+}
+
+// optTemplates returns the templates of all route options with
+// the given prefix by the remainder of the option name as canonical
+// header name. Invalid templates are logged and ignored.
+func optTemplates(opts map[string]string, prefix string) map[string]*template.Template {
+	var m map[string]*template.Template
+	for k, v := range opts {
+		if !strings.HasPrefix(k, prefix) || len(k) == len(prefix) {
+			continue
+		}
+		name := http.CanonicalHeaderKey(k[len(prefix):])
+		tmpl, err := template.New(name).Parse(v)
+		if err != nil {
+			log.Printf("[WARN] Ignoring invalid template for route option %s. %s", k, err)
+			continue
+		}
+		if m == nil {
+			m = map[string]*template.Template{}
+		}
+		m[name] = tmpl
+	}
+	return m
+}
+
+// optPrefix returns the options whose keys start with the prefix
+// with the prefix removed from the keys.
+func optPrefix(opts map[string]string, prefix string) map[string]string {
+	var m map[string]string
+	for k, v := range opts {
+		if !strings.HasPrefix(k, prefix) || len(k) == len(prefix) {
+			continue
+		}
+		if m == nil {
+			m = map[string]string{}
+		}
+		m[k[len(prefix):]] = v
+	}
+	return m
+}
+
+// accepts returns false if the route has targets with predicates and
+// none of them matches the request and the lookup should continue with
+// the next route.
+func (r *Route) accepts(req *http.Request) bool {
+	return len(r.predicated) == 0 || len(r.wTargets) > 0 || r.matchTarget(req) != nil
+}
+
+// nonEmpty returns the route or nil if it has no targets.
+func nonEmpty(r *Route) *Route {
+	if len(r.Targets) == 0 {
+		return nil
+	}
+	return r
+}
+
+// Lookup picks the target for the request from the route. Targets with
+// predicates which match the request are preferred over the others.
+func (r *Route) Lookup(req *http.Request) *Target {
+	switch {
+	case len(r.Targets) == 0:
+		return nil
+	case len(r.predicated) > 0:
+		if target := r.matchTarget(req); target != nil {
+			return target
+		}
+		if len(r.wTargets) > 0 {
+			return pickAvailable(r)
+		}
+		return nil
+	case len(r.Targets) == 1:
+		return r.Targets[0]
+	default:
+		return pickAvailable(r)
+	}
+}
+
 func (r *Route) delService(service string) {
 	var clone []*Target
 	for _, t := range r.Targets {
@@ -83,7 +325,7 @@ func (r *Route) delTarget(service string, targetURL *url.URL) {
 	r.weighTargets()
 }
 
-func (r *Route) setWeight(service string, weight float64, tags []string) int {
+func (r *Route) setWeight(service string, weight float64, tags []string, ramp *Ramp) int {
 	loop := func(w float64) int {
 		n := 0
 		for _, t := range r.Targets {
@@ -95,6 +337,7 @@ func (r *Route) setWeight(service string, weight float64, tags []string) int {
 			}
 			n++
 			t.FixedWeight = w
+			t.Ramp = ramp
 		}
 		return n
 	}
@@ -150,9 +393,32 @@ func (r *Route) TargetConfig(t *Target, addWeight bool) string {
 	if len(t.Tags) > 0 {
 		s += fmt.Sprintf(" tags %q", strings.Join(t.Tags, ","))
 	}
+	if len(t.Opts) > 0 {
+		s += fmt.Sprintf(" opts %q", optsString(t.Opts))
+	}
 	return s
 }
 
+// optsString returns the route options as a space separated
+// list of key=value pairs sorted by key.
+func optsString(opts map[string]string) string {
+	var keys []string
+	for k := range opts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var p []string
+	for _, k := range keys {
+		if opts[k] == "" {
+			p = append(p, k)
+			continue
+		}
+		p = append(p, k+"="+opts[k])
+	}
+	return strings.Join(p, " ")
+}
+
 // config returns the route configuration in the config language.
 // with the weights specified by the user.
 func (r *Route) config(addWeight bool) []string {
@@ -206,6 +472,12 @@ func (r *Route) weighTargets() {
 		}
 	}
 
+	// targets in slow start receive a growing share of their weight
+	t0 := now()
+	for _, t := range r.Targets {
+		t.Weight *= t.slowStartFactor(t0)
+	}
+
 	// Distribute the targets on a ring with N slots. The distance
 	// between two entries for the same target should be N/count slots
 	// apart to achieve even distribution. count is the number of slots the
@@ -248,6 +520,33 @@ func (r *Route) weighTargets() {
 		}
 	}
 
+	// targets with predicates are selected separately
+	r.predicated = nil
+	for _, t := range r.Targets {
+		if t.predicated() {
+			r.predicated = append(r.predicated, t)
+		}
+	}
+	if len(r.predicated) > 0 {
+		var unpredicated []*Target
+		for _, t := range slots {
+			if !t.predicated() {
+				unpredicated = append(unpredicated, t)
+			}
+		}
+		slots = unpredicated
+	}
+
+	r.localTargets = nil
+	if dc := localDatacenter.Load().(string); dc != "" {
+		tag := "dc=" + dc
+		for _, t := range slots {
+			if contains(t.Tags, []string{tag}) {
+				r.localTargets = append(r.localTargets, t)
+			}
+		}
+	}
+
 	r.wTargets = slots
 }
 
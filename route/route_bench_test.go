@@ -3,6 +3,9 @@ package route
 import (
 	"fmt"
 	"net/http"
+	"net/url"
+	"path"
+	"sort"
 	"sync"
 	"testing"
 )
@@ -12,6 +15,7 @@ var (
 	b10Routes  Table
 	b100Routes Table
 	b500Routes Table
+	b10kRoutes Table
 
 	once sync.Once
 )
@@ -23,6 +27,7 @@ func initRoutes() {
 	b10Routes = makeRoutes(1, 5, 2, 6)
 	b100Routes = makeRoutes(10, 5, 2, 24)
 	b500Routes = makeRoutes(10, 10, 5, 24)
+	b10kRoutes = makeFlatRoutes(10000)
 }
 
 func BenchmarkPrefixMatcherRndPicker5Routes(b *testing.B) {
@@ -71,6 +76,58 @@ func BenchmarkPrefixMatcherRRPicker500Routes(b *testing.B) {
 	b.RunParallel(func(b *testing.PB) { benchmarkGet(b500Routes, prefixMatcher, rrPicker, b) })
 }
 
+func BenchmarkPrefixMatcherRndPicker10kRoutes(b *testing.B) {
+	once.Do(initRoutes)
+	b.SetParallelism(3)
+	b.RunParallel(func(b *testing.PB) { benchmarkGet(b10kRoutes, prefixMatcher, rndPicker, b) })
+}
+
+// BenchmarkPrefixMatcherLinear10kRoutes measures the lookup without
+// the radix tree for comparison.
+func BenchmarkPrefixMatcherLinear10kRoutes(b *testing.B) {
+	once.Do(initRoutes)
+	matchName.Store("linear")
+	defer matchName.Store("prefix")
+	b.SetParallelism(3)
+	b.RunParallel(func(b *testing.PB) { benchmarkGet(b10kRoutes, prefixMatcher, rndPicker, b) })
+}
+
+// BenchmarkGlobMatcher1kRoutes measures the lookup with the
+// prepared glob patterns.
+func BenchmarkGlobMatcher1kRoutes(b *testing.B) {
+	benchmarkGlob(b, globMatcher)
+}
+
+// BenchmarkGlobMatcherUnprepared1kRoutes measures the lookup with
+// path.Match on every route for comparison.
+func BenchmarkGlobMatcherUnprepared1kRoutes(b *testing.B) {
+	benchmarkGlob(b, func(uri string, r *Route) bool {
+		ok, _ := path.Match(r.Path, uri)
+		return ok
+	})
+}
+
+// benchmarkGlob runs the benchmark on the Table.Lookup() function
+// with glob routes and the given matcher function.
+func benchmarkGlob(b *testing.B, m matcher) {
+	t := makeGlobRoutes(1000)
+	var reqs []*http.Request
+	for i := 0; i < 1000; i += 10 {
+		reqs = append(reqs, &http.Request{Host: "www.example.com", RequestURI: fmt.Sprintf("/service-%d/v1/api", i)})
+	}
+	matchFn.Store(m)
+	matchName.Store("glob")
+	defer SetMatcher("prefix")
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		n := 0
+		for pb.Next() {
+			t.Lookup(reqs[n%len(reqs)], "")
+			n++
+		}
+	})
+}
+
 // makeRoutes builds a set of routes for a set of domains
 // and target urls. For each domain all paths up to depth
 // are constructed and all host/path combinations have the
@@ -94,6 +151,32 @@ func makeRoutes(domains, paths, depth, urls int) Table {
 	return t
 }
 
+// makeFlatRoutes builds n routes with distinct paths for a single
+// host. The routes are sorted once to keep the setup fast.
+func makeFlatRoutes(n int) Table {
+	routes := Routes{}
+	for i := 0; i < n; i++ {
+		r := newRoute("www.example.com", fmt.Sprintf("/service-%d/api", i))
+		r.addTarget("svc", &url.URL{Scheme: "http", Host: "host:12345"}, 0, nil, nil)
+		routes = append(routes, r)
+	}
+	sort.Sort(routes)
+	return Table{"www.example.com": routes}
+}
+
+// makeGlobRoutes builds n routes with distinct glob patterns
+// for a single host.
+func makeGlobRoutes(n int) Table {
+	routes := Routes{}
+	for i := 0; i < n; i++ {
+		r := newRoute("www.example.com", fmt.Sprintf("/service-%d/*/api", i))
+		r.addTarget("svc", &url.URL{Scheme: "http", Host: "host:12345"}, 0, nil, nil)
+		routes = append(routes, r)
+	}
+	sort.Sort(routes)
+	return Table{"www.example.com": routes}
+}
+
 // makeRequests builds a list of http.Request objects with an
 // additional path for benchmarking.
 func makeRequests(t Table) []*http.Request {
@@ -109,9 +192,10 @@ func makeRequests(t Table) []*http.Request {
 
 // benchmarkGet runs the benchmark on the Table.Lookup() function with the
 // given matcher and picker functions.
-func benchmarkGet(t Table, m matcher, p picker, pb *testing.PB) {
+func benchmarkGet(t Table, m matcher, p Picker, pb *testing.PB) {
 	reqs := makeRequests(t)
-	match, pick = m, p
+	matchFn.Store(m)
+	pickFn.Store(p)
 	k, n := len(reqs), 0
 	for pb.Next() {
 		t.Lookup(reqs[n%k], "")
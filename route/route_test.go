@@ -25,7 +25,7 @@ func TestAddTarget(t *testing.T) {
 	u := mustParse("http://foo.com/")
 
 	r := newRoute("www.bar.com", "/foo")
-	r.addTarget("service", u, 0, nil)
+	r.addTarget("service", u, 0, nil, nil)
 
 	if got, want := len(r.Targets), 1; got != want {
 		t.Errorf("target length: got %d want %d", got, want)
@@ -43,8 +43,8 @@ func TestDelService(t *testing.T) {
 	u1, u2 := mustParse("http://foo.com/"), mustParse("http://bar.com/")
 
 	r := newRoute("www.bar.com", "/foo")
-	r.addTarget("serviceA", u1, 0, nil)
-	r.addTarget("serviceB", u2, 0, nil)
+	r.addTarget("serviceA", u1, 0, nil, nil)
+	r.addTarget("serviceB", u2, 0, nil, nil)
 	r.delService("serviceA")
 
 	config := []string{"route add serviceB www.bar.com/foo http://bar.com/"}
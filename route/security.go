@@ -0,0 +1,76 @@
+package route
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// SecurityHeaders contains the values of the security headers which
+// are added to the responses for requests via HTTPS listeners.
+type SecurityHeaders struct {
+	// STSMaxAge, STSSubdomains and STSPreload configure the
+	// Strict-Transport-Security header. The header is not set
+	// if STSMaxAge is zero.
+	STSMaxAge     int
+	STSSubdomains bool
+	STSPreload    bool
+
+	// FrameOptions, ContentTypeOptions and CSP are the values
+	// of the X-Frame-Options, X-Content-Type-Options and
+	// Content-Security-Policy headers if they are not empty.
+	FrameOptions       string
+	ContentTypeOptions string
+	CSP                string
+}
+
+// Header returns the security headers which are set.
+func (s SecurityHeaders) Header() http.Header {
+	hdr := http.Header{}
+	if s.STSMaxAge > 0 {
+		v := "max-age=" + strconv.Itoa(s.STSMaxAge)
+		if s.STSSubdomains {
+			v += "; includeSubDomains"
+		}
+		if s.STSPreload {
+			v += "; preload"
+		}
+		hdr.Set("Strict-Transport-Security", v)
+	}
+	if s.FrameOptions != "" {
+		hdr.Set("X-Frame-Options", s.FrameOptions)
+	}
+	if s.ContentTypeOptions != "" {
+		hdr.Set("X-Content-Type-Options", s.ContentTypeOptions)
+	}
+	if s.CSP != "" {
+		hdr.Set("Content-Security-Policy", s.CSP)
+	}
+	return hdr
+}
+
+// optSecurityHeaders returns the security headers from the 'sts.maxage',
+// 'sts.subdomains', 'sts.preload', 'frameoptions', 'contenttypeoptions'
+// and 'csp' options or nil if none is set. The value of the 'csp' option
+// is percent-decoded since route options cannot contain spaces.
+func optSecurityHeaders(opts map[string]string) http.Header {
+	var s SecurityHeaders
+	s.STSMaxAge = optInt(opts, "sts.maxage")
+	_, s.STSSubdomains = opts["sts.subdomains"]
+	_, s.STSPreload = opts["sts.preload"]
+	s.FrameOptions = opts["frameoptions"]
+	s.ContentTypeOptions = opts["contenttypeoptions"]
+	if v, ok := opts["csp"]; ok {
+		csp, err := url.PathUnescape(v)
+		if err != nil {
+			log.Printf("[WARN] Ignoring invalid value %q for route option csp", v)
+		}
+		s.CSP = csp
+	}
+	hdr := s.Header()
+	if len(hdr) == 0 {
+		return nil
+	}
+	return hdr
+}
@@ -0,0 +1,29 @@
+package route
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestOptSecurityHeaders(t *testing.T) {
+	tests := []struct {
+		opts map[string]string
+		want http.Header
+	}{
+		{map[string]string{"sts.subdomains": ""}, nil},
+		{
+			map[string]string{"sts.maxage": "60", "sts.subdomains": "", "sts.preload": ""},
+			http.Header{"Strict-Transport-Security": {"max-age=60; includeSubDomains; preload"}},
+		},
+		{
+			map[string]string{"frameoptions": "DENY", "contenttypeoptions": "nosniff", "csp": "default-src%20'self';%20img-src%20*"},
+			http.Header{"X-Frame-Options": {"DENY"}, "X-Content-Type-Options": {"nosniff"}, "Content-Security-Policy": {"default-src 'self'; img-src *"}},
+		},
+	}
+	for i, tt := range tests {
+		if got := optSecurityHeaders(tt.opts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: got %v want %v", i, got, tt.want)
+		}
+	}
+}
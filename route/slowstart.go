@@ -0,0 +1,116 @@
+package route
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// slowStartMin is the fraction of its weight which a target
+// receives when it is added to the routing table.
+const slowStartMin = 0.1
+
+// slowStart contains the default slow start window from
+// proxy.slowstart. It is stored atomically.
+var slowStart atomic.Value
+
+func init() {
+	slowStart.Store(time.Duration(0))
+}
+
+// SetSlowStart sets the window in which the weight of new targets
+// increases from a small value to their full weight. Zero disables
+// the slow start unless the target has the 'slowstart' option.
+func SetSlowStart(d time.Duration) {
+	slowStart.Store(d)
+}
+
+// targetStarts contains the time when a target was first seen in the
+// active routing table so that its slow start continues when the table
+// is rebuilt. The targets of the first active routing table are not slow
+// started since they have not been added to a running system.
+var targetStarts = struct {
+	sync.Mutex
+	m      map[string]time.Time
+	active bool
+}{m: map[string]time.Time{}}
+
+func targetKey(t *Target) string {
+	return t.Service + " " + t.Route + " " + t.URL.String()
+}
+
+// targetStart returns the time when the target was first seen in the
+// active routing table or the current time for a new target. The time
+// is not recorded here since tables are also parsed for validation.
+func targetStart(t *Target) time.Time {
+	targetStarts.Lock()
+	defer targetStarts.Unlock()
+	start, ok := targetStarts.m[targetKey(t)]
+	if !ok && targetStarts.active {
+		start = now()
+	}
+	return start
+}
+
+// syncTargetStarts records the start times of the new targets of the
+// active table and forgets the start times of the targets which are
+// no longer in the table so that they are slow started again when
+// they reappear.
+func syncTargetStarts(t Table) {
+	targetStarts.Lock()
+	defer targetStarts.Unlock()
+
+	active := map[string]bool{}
+	for _, routes := range t {
+		for _, r := range routes {
+			for _, tg := range r.Targets {
+				key := targetKey(tg)
+				active[key] = true
+				if _, ok := targetStarts.m[key]; !ok && tg.SlowStart > 0 {
+					targetStarts.m[key] = tg.started
+				}
+			}
+		}
+	}
+
+	for key := range targetStarts.m {
+		if !active[key] {
+			delete(targetStarts.m, key)
+		}
+	}
+	targetStarts.active = true
+}
+
+// slowStartFactor returns the fraction of its weight the target
+// receives at the given time. It grows linearly from slowStartMin
+// to 1 over the slow start window.
+func (t *Target) slowStartFactor(now time.Time) float64 {
+	if t.SlowStart <= 0 || t.started.IsZero() {
+		return 1
+	}
+	f := float64(now.Sub(t.started)) / float64(t.SlowStart)
+	switch {
+	case f >= 1:
+		return 1
+	case f < slowStartMin:
+		return slowStartMin
+	}
+	return f
+}
+
+// SlowStarting returns true if the active routing table contains
+// targets whose slow start has not yet finished. The table has to
+// be rebuilt periodically to update their weights.
+func SlowStarting() bool {
+	t := now()
+	for _, routes := range GetTable() {
+		for _, r := range routes {
+			for _, tg := range r.Targets {
+				if tg.slowStartFactor(t) < 1 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
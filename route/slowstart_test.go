@@ -0,0 +1,69 @@
+package route
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSlowStart(t *testing.T) {
+	defer func(fn func() time.Time) { now = fn }(now)
+	targetStarts.m, targetStarts.active = map[string]time.Time{}, false
+	defer func() { targetStarts.m, targetStarts.active = map[string]time.Time{}, false }()
+
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return t0 }
+
+	one := `route add svc / http://a:1/ opts "slowstart=100s"`
+	two := one + "\n" + `route add svc / http://b:1/ opts "slowstart=100s"`
+
+	weights := func(s string) (a, b float64) {
+		tbl, err := ParseString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tg := range tbl[""][0].Targets {
+			if tg.URL.Host == "a:1" {
+				a = tg.Weight
+			} else {
+				b = tg.Weight
+			}
+		}
+		return a, b
+	}
+	check := func(desc string, s string, wantA, wantB float64) {
+		t.Helper()
+		a, b := weights(s)
+		if math.Abs(a-wantA) > 1e-9 || math.Abs(b-wantB) > 1e-9 {
+			t.Errorf("%s: got weights %v, %v want %v, %v", desc, a, b, wantA, wantB)
+		}
+	}
+
+	// the targets of the first table start with their full weight
+	tbl, _ := ParseString(one)
+	syncTargetStarts(tbl)
+	check("initial table", one, 1, 0)
+
+	check("new target", two, 0.5, 0.05)
+
+	// parsing the table does not start the slow start
+	now = func() time.Time { return t0.Add(10 * time.Second) }
+	check("not active", two, 0.5, 0.05)
+
+	now = func() time.Time { return t0 }
+	tbl2, _ := ParseString(two)
+	syncTargetStarts(tbl2)
+
+	now = func() time.Time { return t0.Add(50 * time.Second) }
+	check("half way", two, 0.5, 0.25)
+
+	now = func() time.Time { return t0.Add(100 * time.Second) }
+	check("done", two, 0.5, 0.5)
+
+	// a removed target starts over
+	syncTargetStarts(tbl)
+	check("re-added", two, 0.5, 0.05)
+	if _, ok := targetStarts.m[targetKey(tbl2[""][0].Targets[1])]; ok {
+		t.Error("start time of removed target not forgotten")
+	}
+}
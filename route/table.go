@@ -10,6 +10,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/eBay/fabio/metrics"
 )
@@ -36,22 +37,46 @@ func GetTable() Table {
 	return table.Load().(Table)
 }
 
-// mu guards table and registry in SetTable.
+// mu serializes the updates of the table and the registry
+// in SetTable. Lookups never acquire it.
 var mu sync.Mutex
 
 // SetTable sets the active routing table. A nil value
 // logs a warning and is ignored. The function is safe
 // to be called from multiple goroutines.
+//
+// The table must not be modified after it has been set since
+// it is shared by all concurrent lookups without locking. The
+// lookup structures are built before the table is swapped so
+// that lookups never wait for an update.
 func SetTable(t Table) {
+	SetTableFrom(t, "")
+}
+
+// SetTableFrom sets the active routing table like SetTable and
+// records the changes in the history with the source of the update.
+// Updates from SourceRamp are not recorded.
+func SetTableFrom(t Table, source string) {
 	if t == nil {
 		log.Print("[WARN] Ignoring nil routing table")
 		return
 	}
 	mu.Lock()
+	keys := buildIndexes(t)
+	old := GetTable()
 	table.Store(t)
+	if source != SourceRamp {
+		recordChange(source, old, t, time.Now())
+	}
+	reportChecksum(t)
+	pruneIndexes(keys)
 	syncRegistry(t)
+	syncRamps(t)
+	syncTargetStarts(t)
 	mu.Unlock()
-	log.Printf("[INFO] Updated config to\n%s", t)
+	if source != SourceRamp {
+		log.Printf("[INFO] Updated config to\n%s", t)
+	}
 }
 
 // syncRegistry unregisters all inactive timers.
@@ -72,7 +97,9 @@ func syncRegistry(t Table) {
 	for _, routes := range t {
 		for _, r := range routes {
 			for _, tg := range r.Targets {
-				timers[tg.timerName] = true
+				for _, name := range tg.metricNames() {
+					timers[name] = true
+				}
 			}
 		}
 	}
@@ -81,7 +108,7 @@ func syncRegistry(t Table) {
 	for name, active := range timers {
 		if !active {
 			ServiceRegistry.Unregister(name)
-			log.Printf("[INFO] Unregistered timer %s", name)
+			log.Printf("[INFO] Unregistered metric %s", name)
 		}
 	}
 }
@@ -104,6 +131,12 @@ func hostpath(prefix string) (host string, path string) {
 
 // AddRoute adds a new route prefix -> target for the given service.
 func (t Table) AddRoute(service, prefix, target string, weight float64, tags []string) error {
+	return t.AddRouteOpts(service, prefix, target, weight, tags, nil)
+}
+
+// AddRouteOpts adds a new route prefix -> target for the given service
+// and assigns the route options to the target.
+func (t Table) AddRouteOpts(service, prefix, target string, weight float64, tags []string, opts map[string]string) error {
 	host, path := hostpath(prefix)
 
 	if prefix == "" {
@@ -120,7 +153,7 @@ func (t Table) AddRoute(service, prefix, target string, weight float64, tags []s
 	}
 
 	r := newRoute(host, path)
-	r.addTarget(service, targetURL, weight, tags)
+	r.addTarget(service, targetURL, weight, tags, opts)
 
 	// add new host
 	if t[host] == nil {
@@ -136,12 +169,19 @@ func (t Table) AddRoute(service, prefix, target string, weight float64, tags []s
 	}
 
 	// add new target to existing route
-	t[host].find(path).addTarget(service, targetURL, weight, tags)
+	t[host].find(path).addTarget(service, targetURL, weight, tags, opts)
 
 	return nil
 }
 
 func (t Table) AddRouteWeight(service, prefix string, weight float64, tags []string) error {
+	return t.addRouteWeight(service, prefix, weight, tags, nil)
+}
+
+// addRouteWeight assigns the weight to the matching targets and
+// attaches the ramp if it is not nil. The weight is the current
+// weight of the ramp.
+func (t Table) addRouteWeight(service, prefix string, weight float64, tags []string, ramp *Ramp) error {
 	host, path := hostpath(prefix)
 
 	if prefix == "" {
@@ -152,7 +192,7 @@ func (t Table) AddRouteWeight(service, prefix string, weight float64, tags []str
 		return errNoMatch
 	}
 
-	if n := t[host].find(path).setWeight(service, weight, tags); n == 0 {
+	if n := t[host].find(path).setWeight(service, weight, tags, ramp); n == 0 {
 		return errNoMatch
 	}
 	return nil
@@ -250,9 +290,9 @@ func (t Table) Lookup(req *http.Request, trace string) *Target {
 		log.Printf("[TRACE] %s Tracing %s%s", trace, req.Host, req.RequestURI)
 	}
 
-	target := t.lookup(normalizeHost(req), req.RequestURI, trace)
-	if target == nil {
-		target = t.lookup("", req.RequestURI, trace)
+	var target *Target
+	if r := t.lookupRoute(req, trace); r != nil {
+		target = r.Lookup(req)
 	}
 
 	if target != nil && trace != "" {
@@ -262,28 +302,111 @@ func (t Table) Lookup(req *http.Request, trace string) *Target {
 	return target
 }
 
+// LookupRoute returns the route which Lookup picks the target from
+// or nil if there is none. Unlike Lookup it does not pick a target
+// and therefore does not change the state of the picker.
+func (t Table) LookupRoute(req *http.Request) *Route {
+	return t.lookupRoute(req, "")
+}
+
+func (t Table) lookupRoute(req *http.Request, trace string) *Route {
+	r := t.lookupHost(req, normalizeHost(req), req.RequestURI, trace)
+	if r == nil {
+		r = t.lookup(req, "", req.RequestURI, trace)
+	}
+	return r
+}
+
+// LookupService returns a random target of the given service
+// from all routes or nil if the service has no targets.
+func (t Table) LookupService(service string) *Target {
+	var targets []*Target
+	seen := map[string]bool{}
+	for _, routes := range t {
+		for _, r := range routes {
+			for _, tg := range r.Targets {
+				if tg.Service != service || seen[tg.URL.String()] {
+					continue
+				}
+				seen[tg.URL.String()] = true
+				targets = append(targets, tg)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	return targets[randIntn(len(targets))]
+}
+
+// LookupHost returns a target of the routes for the root path of the
+// host or nil if there is none. It is used by the TCP+SNI proxy with
+// the server name from the TLS handshake so that the same host/ routes
+// work for passthrough and terminated HTTPS connections.
 func (t Table) LookupHost(host string) *Target {
-	return t.lookup(host, "/", "")
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	r := t.lookupHost(nil, host, "/", "")
+	if r == nil {
+		return nil
+	}
+	return r.Lookup(nil)
 }
 
-func (t Table) lookup(host, path, trace string) *Target {
-	for _, r := range t[host] {
-		if match(path, r) {
-			n := len(r.Targets)
-			if n == 0 {
-				return nil
+// lookupHost finds the route for the path on the host. If none of the
+// routes of the host matches then the routes of the wildcard hosts are
+// tried from the most to the least specific one, e.g. *.b.example.com
+// and then *.example.com for a.b.example.com.
+func (t Table) lookupHost(req *http.Request, host, path, trace string) *Route {
+	if r := t.lookup(req, host, path, trace); r != nil {
+		return r
+	}
+	for h := host; ; {
+		i := strings.IndexByte(h, '.')
+		if i < 0 {
+			return nil
+		}
+		h = h[i+1:]
+		if r := t.lookup(req, "*."+h, path, trace); r != nil {
+			return r
+		}
+	}
+}
+
+// lookup finds the route for the path on the host. Routes whose
+// targets all have method, header, cookie or source predicates which
+// do not match the request are skipped. A route without targets ends
+// the lookup with nil. req can be nil for non-HTTP lookups.
+//
+// With the prefix matcher the routes are found with a radix tree of the
+// paths of the host unless the request is traced which logs all routes
+// which do not match.
+func (t Table) lookup(req *http.Request, host, path, trace string) *Route {
+	routes := t[host]
+	if trace == "" && len(routes) > 0 && matchName.Load() == "prefix" {
+		var buf [8]*Route
+		matches := routes.index().root.match(path, buf[:0])
+
+		// the longest matching path comes first
+		for i := len(matches) - 1; i >= 0; i-- {
+			if matches[i].accepts(req) {
+				return nonEmpty(matches[i])
 			}
+		}
+		return nil
+	}
 
-			var target *Target
-			if n == 1 {
-				target = r.Targets[0]
-			} else {
-				target = pick(r)
+	for _, r := range routes {
+		if match(path, r) {
+			if !r.accepts(req) {
+				if trace != "" {
+					log.Printf("[TRACE] %s No predicate match %s%s", trace, r.Host, r.Path)
+				}
+				continue
 			}
 			if trace != "" {
 				log.Printf("[TRACE] %s Match %s%s", trace, r.Host, r.Path)
 			}
-			return target
+			return nonEmpty(r)
 		}
 		if trace != "" {
 			log.Printf("[TRACE] %s No match %s%s", trace, r.Host, r.Path)
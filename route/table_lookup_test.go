@@ -74,3 +74,111 @@ func TestTableLookup(t *testing.T) {
 		}
 	}
 }
+
+func TestTableLookupWildcardHost(t *testing.T) {
+	s := `
+	route add svc / http://foo.com:800
+	route add svc *.abc.com/ http://foo.com:1000
+	route add svc *.b.abc.com/ http://foo.com:2000
+	route add svc *.b.abc.com/foo http://foo.com:2500
+	route add svc a.b.abc.com/ http://foo.com:3000
+	`
+
+	tbl, err := ParseString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		req *http.Request
+		dst string
+	}{
+		// exact host before wildcard host
+		{&http.Request{Host: "a.b.abc.com", RequestURI: "/"}, "http://foo.com:3000"},
+
+		// most specific wildcard host first
+		{&http.Request{Host: "x.b.abc.com", RequestURI: "/"}, "http://foo.com:2000"},
+		{&http.Request{Host: "x.b.abc.com", RequestURI: "/foo"}, "http://foo.com:2500"},
+		{&http.Request{Host: "x.y.abc.com", RequestURI: "/"}, "http://foo.com:1000"},
+
+		// wildcard does not match the domain itself
+		{&http.Request{Host: "abc.com", RequestURI: "/"}, "http://foo.com:800"},
+	}
+
+	for i, tt := range tests {
+		if got, want := tbl.Lookup(tt.req, "").URL.String(), tt.dst; got != want {
+			t.Errorf("%d: got %v want %v", i, got, want)
+		}
+	}
+
+	hosts := []struct {
+		host, dst string
+	}{
+		{"A.B.ABC.COM.", "http://foo.com:3000"},
+		{"x.abc.com", "http://foo.com:1000"},
+		{"abc.com", ""},
+	}
+	for _, tt := range hosts {
+		var got string
+		if target := tbl.LookupHost(tt.host); target != nil {
+			got = target.URL.String()
+		}
+		if got != tt.dst {
+			t.Errorf("%s: got %q want %q", tt.host, got, tt.dst)
+		}
+	}
+}
+
+func TestTableLookupService(t *testing.T) {
+	s := `
+	route add svc-a / http://foo.com:800
+	route add svc-a /foo http://foo.com:800
+	route add svc-b abc.com/ http://foo.com:1000
+	`
+
+	tbl, err := ParseString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := tbl.LookupService("svc-a").URL.String(), "http://foo.com:800"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := tbl.LookupService("svc-b").URL.String(), "http://foo.com:1000"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got := tbl.LookupService("svc-c"); got != nil {
+		t.Errorf("got %v want nil", got)
+	}
+}
+
+func TestTableLookupIPrefix(t *testing.T) {
+	if err := SetMatcher("iprefix"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetMatcher("prefix")
+
+	tbl, err := ParseString(`
+	route add svc / http://foo.com:800
+	route add svc /api http://foo.com:900
+	route add svc /api/v2 http://foo.com:1000
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/API", "http://foo.com:900"},
+		{"/Api/V2/users", "http://foo.com:1000"},
+		{"/other", "http://foo.com:800"},
+	}
+	for _, tt := range tests {
+		req := &http.Request{Host: "abc.com", RequestURI: tt.path}
+		if got := tbl.Lookup(req, "").URL.String(); got != tt.want {
+			t.Errorf("%s: got %s want %s", tt.path, got, tt.want)
+		}
+	}
+}
@@ -13,16 +13,21 @@ func TestSyncRegistry(t *testing.T) {
 	ServiceRegistry = newStubRegistry()
 	defer func() { ServiceRegistry = oldRegistry }()
 
+	names := func(prefix string) []string {
+		return []string{prefix, prefix + ".2xx", prefix + ".3xx", prefix + ".4xx", prefix + ".5xx", prefix + ".errorrate"}
+	}
+
 	tbl := make(Table)
 	tbl.AddRoute("svc-a", "/aaa", "http://localhost:1234", 1, nil)
 	tbl.AddRoute("svc-b", "/bbb", "http://localhost:5678", 1, nil)
-	if got, want := ServiceRegistry.Names(), []string{"svc-a._./aaa.localhost_1234", "svc-b._./bbb.localhost_5678"}; !reflect.DeepEqual(got, want) {
+	want := append(names("svc-a._./aaa.localhost_1234"), names("svc-b._./bbb.localhost_5678")...)
+	if got := ServiceRegistry.Names(); !reflect.DeepEqual(got, want) {
 		t.Fatalf("got %v want %v", got, want)
 	}
 
 	tbl.DelRoute("svc-b", "/bbb", "http://localhost:5678")
 	syncRegistry(tbl)
-	if got, want := ServiceRegistry.Names(), []string{"svc-a._./aaa.localhost_1234"}; !reflect.DeepEqual(got, want) {
+	if got, want := ServiceRegistry.Names(), names("svc-a._./aaa.localhost_1234"); !reflect.DeepEqual(got, want) {
 		t.Fatalf("got %v want %v", got, want)
 	}
 }
@@ -61,3 +66,13 @@ func (p *stubRegistry) GetTimer(name string) metrics.Timer {
 	p.names[name] = true
 	return metrics.NoopTimer{}
 }
+
+func (p *stubRegistry) GetGauge(name string) metrics.Gauge {
+	p.names[name] = true
+	return metrics.NoopGauge{}
+}
+
+func (p *stubRegistry) GetFloatGauge(name string) metrics.FloatGauge {
+	p.names[name] = true
+	return metrics.NoopFloatGauge{}
+}
@@ -1,7 +1,12 @@
 package route
 
 import (
+	"net"
+	"net/http"
 	"net/url"
+	"strconv"
+	"text/template"
+	"time"
 
 	"github.com/eBay/fabio/metrics"
 )
@@ -10,9 +15,148 @@ type Target struct {
 	// Service is the name of the service the targetURL points to
 	Service string
 
+	// Route is the host and path of the route of this target
+	Route string
+
 	// Tags are the list of tags for this target
 	Tags []string
 
+	// Opts contains the route options for this target
+	Opts map[string]string
+
+	// DialTimeout overrides the dial timeout for this target
+	// if it is not zero. Set with the 'dialtimeout' option.
+	DialTimeout time.Duration
+
+	// ResponseTimeout overrides the response header timeout for
+	// this target if it is not zero. Set with the 'responsetimeout'
+	// option.
+	ResponseTimeout time.Duration
+
+	// MaxIdleConns, IdleConnTimeout and DisableKeepAlives override
+	// the connection pooling of the upstream transport for this
+	// target if they are set. Set with the options of the same name
+	// in lower case.
+	MaxIdleConns      int
+	IdleConnTimeout   time.Duration
+	DisableKeepAlives bool
+
+	// MaxBpsIn and MaxBpsOut limit the bandwidth in bits per second
+	// of every connection or request to this target from the client
+	// to the upstream server and back if they are not zero. Set with
+	// the 'maxbps-in' and 'maxbps-out' options.
+	MaxBpsIn  int64
+	MaxBpsOut int64
+
+	// MaxConn limits the number of concurrent requests to the
+	// upstream server of this target if it is not zero. Requests
+	// over the limit wait up to MaxConnWait for a free slot. Set
+	// with the 'maxconn' and 'maxconn-wait' options.
+	MaxConn     int
+	MaxConnWait time.Duration
+
+	// MaxRequestTimeout caps the deadline which trusted clients can set
+	// with the request timeout header if it is not zero. Set with the
+	// 'maxrequesttimeout' option.
+	MaxRequestTimeout time.Duration
+
+	// MatchHeader and MatchCookie restrict the target to the requests
+	// with the header or cookie. Set with the 'match-header' and
+	// 'match-cookie' options.
+	MatchHeader *Predicate
+	MatchCookie *Predicate
+
+	// MatchSource restricts the target to the clients from the
+	// networks. Set with the 'src' option.
+	MatchSource []*net.IPNet
+
+	// MatchMethods restricts the target to requests with one of
+	// the HTTP methods. Set with the 'methods' option.
+	MatchMethods []string
+
+	// Allow and Deny contain the networks of the clients which are
+	// allowed or rejected. Set with the 'allow' and 'deny' options.
+	Allow, Deny []*net.IPNet
+
+	// ClientCert contains the rules for the client certificate of
+	// the clients which are allowed. Set with the 'clientcert-cn',
+	// 'clientcert-ou' and 'clientcert-san' options.
+	ClientCert *ClientCertRules
+
+	// CORS contains the cross-origin resource sharing rules of
+	// this target. Set with the 'cors-origins' option and the
+	// other 'cors-' options.
+	CORS *CORS
+
+	// Query contains the changes to the query parameters of the
+	// upstream requests. Set with the 'strip-query', 'set-query'
+	// and 'add-query' options.
+	Query *QueryRules
+
+	// SecurityHeaders contains the security headers for the responses
+	// to requests via HTTPS listeners which replace the ones from
+	// the proxy configuration. Set with the 'sts.maxage',
+	// 'sts.subdomains', 'sts.preload', 'frameoptions',
+	// 'contenttypeoptions' and 'csp' options.
+	SecurityHeaders http.Header
+
+	// ResponseHeaders contains the templates for the response headers
+	// of this target. Set with the 'respheader.<name>' options.
+	ResponseHeaders map[string]*template.Template
+
+	// Meta contains the metadata of the target, e.g. the service
+	// metadata from consul. It does not change the routing and is
+	// shown in the UI. Set with the 'meta.<key>' options.
+	Meta map[string]string
+
+	// CacheControl replaces the Cache-Control header of the successful
+	// responses of this target if it is not empty. Set with the
+	// 'setcachecontrol' option.
+	CacheControl string
+
+	// CacheTTL enables the response cache for the GET requests to
+	// this target if it is not zero. It is the lifetime of responses
+	// without a max-age directive. Set with the 'cache' option.
+	CacheTTL time.Duration
+
+	// CacheStaleWhileRevalidate and CacheStaleIfError are the
+	// windows in which stale responses are served while they are
+	// refreshed or when the upstream server fails. The directives of
+	// the same name in the Cache-Control header of the response take
+	// precedence. Set with the 'stale-while-revalidate' and
+	// 'stale-if-error' options.
+	CacheStaleWhileRevalidate time.Duration
+	CacheStaleIfError         time.Duration
+
+	// Fault injects latency and errors into the requests to
+	// this target if it is not nil. Set with the 'delay' and
+	// 'abort' options.
+	Fault *Fault
+
+	// maintenance is the maintenance mode of the target from the
+	// 'maintenance' option or nil. See Maintenance().
+	maintenance *Maintenance
+
+	// Mirror is the URL of the server which receives a copy of every
+	// request to this target. Set with the 'mirror' option.
+	Mirror *url.URL
+
+	// TLSPins contains the base64 encoded SHA-256 hashes of the public
+	// keys of which the upstream certificate must match one. Set with
+	// the 'tlspin' option. TLSPinOnly disables the CA validation of the
+	// upstream certificate and is set with the 'tlspinonly' option.
+	TLSPins    []string
+	TLSPinOnly bool
+
+	// TLSSkipVerify disables the verification of the upstream
+	// certificate. Set with the 'tlsskipverify=true' option.
+	TLSSkipVerify bool
+
+	// TLSCertSource is the name of the certificate source with the
+	// client certificate for an https upstream. Set with the 'tlscs'
+	// option.
+	TLSCertSource string
+
 	// URL is the endpoint the service instance listens on
 	URL *url.URL
 
@@ -20,6 +164,17 @@ type Target struct {
 	// If the value is 0 the targets weight is dynamic.
 	FixedWeight float64
 
+	// Ramp changes the fixed weight of the target over time.
+	// It is set by the 'route weight ... ramp <to>:<d>' command.
+	Ramp *Ramp
+
+	// SlowStart is the window in which the weight of the target
+	// increases from a small value to its full weight after it has
+	// been added. Set with the 'slowstart' option or proxy.slowstart.
+	// started is the time when the target was first seen.
+	SlowStart time.Duration
+	started   time.Time
+
 	// Weight is the actual weight for this service in percent.
 	Weight float64
 
@@ -28,4 +183,73 @@ type Target struct {
 
 	// timerName is the name of the timer in the metrics registry
 	timerName string
+
+	// route is the route of the target.
+	route *Route
+
+	// statusCounters count the responses of this target
+	// by the class of the status code from 2xx to 5xx.
+	statusCounters [4]metrics.Counter
+
+	// ErrorRate contains the ratio of 5xx responses of this
+	// target over the last few minutes.
+	ErrorRate metrics.FloatGauge
+
+	// RouteTimer measures throughput and latency of all targets of
+	// the service on this route. It is shared by these targets.
+	RouteTimer metrics.Timer
+
+	// routeTimerName is the name of the route timer in the metrics
+	// registry or empty if per route metrics are disabled.
+	routeTimerName string
+
+	// statusName is the name of the tagged counter for the status
+	// codes of the responses or empty if the metrics target does
+	// not support tags.
+	statusName string
+}
+
+// statusClasses contains the metric name suffixes
+// of the status counters.
+var statusClasses = [4]string{".2xx", ".3xx", ".4xx", ".5xx"}
+
+// StatusCounter returns the counter for the class of the status
+// code. Status codes outside of the 2xx to 5xx range are not
+// counted.
+func (t *Target) StatusCounter(code int) metrics.Counter {
+	i := code/100 - 2
+	if i < 0 || i >= len(t.statusCounters) || t.statusCounters[i] == nil {
+		return metrics.NoopCounter{}
+	}
+	return t.statusCounters[i]
+}
+
+// metricNames returns the names of the metrics of this
+// target in the ServiceRegistry.
+func (t *Target) metricNames() []string {
+	names := []string{t.timerName, metrics.WithSuffix(t.timerName, ".errorrate")}
+	for _, c := range statusClasses {
+		names = append(names, metrics.WithSuffix(t.timerName, c))
+	}
+	if t.routeTimerName != "" {
+		names = append(names, t.routeTimerName)
+	}
+	return names
+}
+
+// RoutePath returns the path of the route of the target.
+func (t *Target) RoutePath() string {
+	if t.route == nil {
+		return ""
+	}
+	return t.route.Path
+}
+
+// CountStatus counts a response with the given status code if the
+// metrics target supports tags.
+func (t *Target) CountStatus(code int) {
+	if t.statusName == "" {
+		return
+	}
+	metrics.DefaultRegistry.GetCounter(metrics.WithTag(t.statusName, "code", strconv.Itoa(code))).Inc(1)
 }
@@ -0,0 +1,35 @@
+package route
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"strings"
+)
+
+// optTLSPins returns the list of SPKI pins of the 'tlspin' route
+// option. Pins have the form 'sha256:<base64>' where the value is the
+// base64 encoded SHA-256 hash of the subject public key info of the
+// certificate and are separated by commas. The result is nil if the
+// option is not set and non-nil if it is set so that an option with
+// only invalid pins rejects all upstream certificates.
+func optTLSPins(opts map[string]string, name string) []string {
+	v, ok := opts[name]
+	if !ok {
+		return nil
+	}
+	pins := []string{}
+	for _, pin := range strings.Split(v, ",") {
+		pin = strings.TrimSpace(pin)
+		if pin == "" {
+			continue
+		}
+		h, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, "sha256:"))
+		if !strings.HasPrefix(pin, "sha256:") || err != nil || len(h) != sha256.Size {
+			log.Printf("[WARN] Ignoring invalid %s %q", name, pin)
+			continue
+		}
+		pins = append(pins, base64.StdEncoding.EncodeToString(h))
+	}
+	return pins
+}
@@ -0,0 +1,29 @@
+package route
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOptTLSPins(t *testing.T) {
+	pin := "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+	tests := []struct {
+		desc string
+		opts map[string]string
+		pins []string
+	}{
+		{"not set", nil, nil},
+		{"empty", map[string]string{"tlspin": ""}, []string{}},
+		{"one pin", map[string]string{"tlspin": "sha256:" + pin}, []string{pin}},
+		{"two pins", map[string]string{"tlspin": "sha256:" + pin + ", sha256:" + pin}, []string{pin, pin}},
+		{"no prefix", map[string]string{"tlspin": pin}, []string{}},
+		{"invalid base64", map[string]string{"tlspin": "sha256:xyz"}, []string{}},
+		{"short hash", map[string]string{"tlspin": "sha256:AAAA"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		if got, want := optTLSPins(tt.opts, "tlspin"), tt.pins; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %#v want %#v", tt.desc, got, want)
+		}
+	}
+}
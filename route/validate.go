@@ -0,0 +1,245 @@
+package route
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// LineError describes an invalid route command.
+type LineError struct {
+	Line    int    `json:"line"`
+	Command string `json:"command"`
+	Message string `json:"message"`
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("route: line %d: %s", e.Line, e.Message)
+}
+
+// Validate checks the route commands without changing the routing
+// table and returns the errors of all invalid commands in the order
+// of the lines. In addition to the syntax errors which Parse reports
+// Validate rejects invalid targets, unknown route options, option
+// values which would be ignored and weights outside of [0, 1].
+func Validate(s string) []*LineError {
+	p := &parser{t: make(Table), validate: true}
+	if err := p.parse(strings.NewReader(s)); err != nil {
+		return []*LineError{{Message: err.Error()}}
+	}
+	return p.errs
+}
+
+// checkTarget reports a target URL which cannot be parsed.
+func (p *parser) checkTarget(dst string) {
+	u, err := url.Parse(dst)
+	switch {
+	case err != nil:
+		p.report(p.errorf("invalid target %s. %s", dst, err))
+	case (u.Scheme == "http" || u.Scheme == "https") && u.Host == "":
+		p.report(p.errorf("invalid target %s. missing host", dst))
+	}
+}
+
+// checkWeight reports weights which are not a fraction of the traffic.
+func (p *parser) checkWeight(w float64) {
+	if !(w >= 0 && w <= 1) {
+		p.report(p.errorf("weight %v is not between 0 and 1", w))
+	}
+}
+
+// checkOpts reports unknown route options and invalid option values.
+func (p *parser) checkOpts(opts map[string]string) {
+	var keys []string
+	for k := range opts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := opts[k]
+		valid, known := optChecks[k]
+		if !known && strings.HasPrefix(k, "respheader.") && len(k) > len("respheader.") {
+			valid, known = validTemplate, true
+		}
+		if !known && strings.HasPrefix(k, "meta.") && len(k) > len("meta.") {
+			known = true
+		}
+		switch {
+		case !known:
+			p.report(p.errorf("unknown option %s", k))
+		case valid != nil && !valid(v):
+			p.report(p.errorf("invalid value %q for option %s", v, k))
+		}
+	}
+}
+
+func (p *parser) report(err error) {
+	p.errs = append(p.errs, err.(*LineError))
+}
+
+// optChecks contains the known route options and a function which
+// validates their value. Options with a nil function accept any value.
+var optChecks = map[string]func(string) bool{
+	"abort":                  validFault(validStatus),
+	"add-query":              validList(validQueryParam),
+	"allow":                  validList(func(s string) bool { return parseAccessRule(s) != nil }),
+	"auth":                   validName,
+	"cache":                  validDuration,
+	"check":                  validName,
+	"checksum":               validOneOf("md5", "sha1", "sha256", "sha512"),
+	"checksumheader":         validName,
+	"clientcert-cn":          validRegexp,
+	"clientcert-ou":          validRegexp,
+	"clientcert-san":         validRegexp,
+	"contenttypeoptions":     validName,
+	"cors-credentials":       nil,
+	"cors-headers":           nil,
+	"cors-maxage":            validDuration,
+	"cors-methods":           validName,
+	"cors-origins":           validName,
+	"csp":                    validName,
+	"delay":                  validFault(validDuration),
+	"deny":                   validList(func(s string) bool { return parseAccessRule(s) != nil }),
+	"deploy":                 validName,
+	"dialtimeout":            validDuration,
+	"disablekeepalives":      nil,
+	"extauthz":               validName,
+	"fallback":               validName,
+	"fallbackstatus":         validList(validStatus),
+	"followredirects":        validInt,
+	"frameoptions":           validName,
+	"idempotency":            nil,
+	"idleconntimeout":        validDuration,
+	"informational":          nil,
+	"jwt":                    validName,
+	"maintenance":            func(v string) bool { return v == "" || validStatus(v) },
+	"maintenance-retryafter": validDuration,
+	"match-cookie":           validPredicate,
+	"match-header":           validPredicate,
+	"maxbps-in":              validBandwidth,
+	"maxbps-out":             validBandwidth,
+	"maxconn":                validInt,
+	"maxconn-wait":           validDuration,
+	"maxidleconns":           validInt,
+	"maxrequesttimeout":      validDuration,
+	"methodoverride":         validOneOf("", "rewrite"),
+	"methods":                validName,
+	"mirror":                 validURL,
+	"oidc":                   validName,
+	"proto":                  validOneOf("http", "https"),
+	"responsetimeout":        validDuration,
+	"set-query":              validList(validQueryParam),
+	"setcachecontrol":        validName,
+	"slowstart":              validDuration,
+	"src":                    validList(func(s string) bool { return parseAccessRule("ip:"+strings.TrimPrefix(s, "ip:")) != nil }),
+	"stale-if-error":         validDuration,
+	"stale-while-revalidate": validDuration,
+	"strip-query":            validName,
+	"sts.maxage":             validInt,
+	"sts.preload":            nil,
+	"sts.subdomains":         nil,
+	"tlscs":                  validName,
+	"tlspin":                 validList(validPin),
+	"tlspinonly":             nil,
+	"tlsskipverify":          validOneOf("true", "false"),
+}
+
+func validName(v string) bool {
+	return v != ""
+}
+
+func validDuration(v string) bool {
+	d, err := time.ParseDuration(v)
+	return err == nil && d >= 0
+}
+
+func validInt(v string) bool {
+	n, err := strconv.Atoi(v)
+	return err == nil && n >= 0
+}
+
+func validBandwidth(v string) bool {
+	_, ok := parseBandwidth(v)
+	return ok
+}
+
+func validURL(v string) bool {
+	u, err := url.Parse(v)
+	return err == nil && u.Host != "" && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+func validStatus(v string) bool {
+	code, err := strconv.Atoi(v)
+	return err == nil && code >= 100 && code <= 599
+}
+
+func validRegexp(v string) bool {
+	_, err := regexp.Compile(v)
+	return err == nil
+}
+
+func validTemplate(v string) bool {
+	_, err := template.New("").Parse(v)
+	return err == nil
+}
+
+func validPredicate(v string) bool {
+	return !strings.HasPrefix(v, ":") && v != ""
+}
+
+func validQueryParam(v string) bool {
+	_, _, ok := parseQueryParam(v)
+	return ok
+}
+
+func validPin(v string) bool {
+	h, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(v, "sha256:"))
+	return strings.HasPrefix(v, "sha256:") && err == nil && len(h) == sha256.Size
+}
+
+// validOneOf returns a check which accepts only the given values.
+func validOneOf(values ...string) func(string) bool {
+	return func(v string) bool {
+		for _, s := range values {
+			if v == s {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// validFault returns a check which accepts a fault injection
+// value with an optional ratio.
+func validFault(valid func(string) bool) func(string) bool {
+	return func(v string) bool {
+		_, _, ok := parseFault(v, valid)
+		return ok
+	}
+}
+
+// validList returns a check which accepts a comma separated list
+// of values which are all valid.
+func validList(valid func(string) bool) func(string) bool {
+	return func(v string) bool {
+		n := 0
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			if !valid(s) {
+				return false
+			}
+			n++
+		}
+		return n > 0
+	}
+}
@@ -0,0 +1,90 @@
+package route
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		desc string
+		in   string
+		out  []*LineError
+	}{
+		{
+			desc: "valid",
+			in: `
+# comment
+route add svc / http://1.2.3.4:5000/ opts "dialtimeout=2s allow=ip:10.0.0.0/8 respheader.X-Route={{.RouteSrc}} meta.version=1.2 disablekeepalives"
+route weight svc / weight 0.5 tags "a"
+route del svc`,
+		},
+		{
+			desc: "syntax errors on all lines",
+			in:   "route foo\nroute add svc\nroute del svc",
+			out: []*LineError{
+				{Line: 1, Command: "route foo", Message: "syntax error in route foo"},
+				{Line: 2, Command: "route add svc", Message: "syntax error in route add svc"},
+			},
+		},
+		{
+			desc: "invalid weights",
+			in:   "route add svc / http://a/ weight x\nroute weight svc / weight 1.5\nroute add svc / http://a/ weight -1",
+			out: []*LineError{
+				{Line: 1, Command: "route add svc / http://a/ weight x", Message: "invalid weight: x"},
+				{Line: 2, Command: "route weight svc / weight 1.5", Message: "weight 1.5 is not between 0 and 1"},
+				{Line: 3, Command: "route add svc / http://a/ weight -1", Message: "weight -1 is not between 0 and 1"},
+			},
+		},
+		{
+			desc: "invalid target",
+			in:   "route add svc / http:///x",
+			out: []*LineError{
+				{Line: 1, Command: "route add svc / http:///x", Message: "invalid target http:///x. missing host"},
+			},
+		},
+		{
+			desc: "options",
+			in:   `route add svc / http://a/ opts "foo=1 dialtimeout=x maxbps-in=10m tlspin=sha256:abc respheader.X={{"`,
+			out: []*LineError{
+				{Line: 1, Command: `route add svc / http://a/ opts "foo=1 dialtimeout=x maxbps-in=10m tlspin=sha256:abc respheader.X={{"`, Message: `invalid value "x" for option dialtimeout`},
+				{Line: 1, Command: `route add svc / http://a/ opts "foo=1 dialtimeout=x maxbps-in=10m tlspin=sha256:abc respheader.X={{"`, Message: "unknown option foo"},
+				{Line: 1, Command: `route add svc / http://a/ opts "foo=1 dialtimeout=x maxbps-in=10m tlspin=sha256:abc respheader.X={{"`, Message: `invalid value "{{" for option respheader.X`},
+				{Line: 1, Command: `route add svc / http://a/ opts "foo=1 dialtimeout=x maxbps-in=10m tlspin=sha256:abc respheader.X={{"`, Message: `invalid value "sha256:abc" for option tlspin`},
+			},
+		},
+		{
+			desc: "empty security options",
+			in:   `route add svc / http://a/ opts "auth= jwt="`,
+			out: []*LineError{
+				{Line: 1, Command: `route add svc / http://a/ opts "auth= jwt="`, Message: `invalid value "" for option auth`},
+				{Line: 1, Command: `route add svc / http://a/ opts "auth= jwt="`, Message: `invalid value "" for option jwt`},
+			},
+		},
+		{
+			desc: "mirror",
+			in:   "route add svc / http://a/ opts \"mirror=http://staging:8080\"\nroute add svc / http://a/ opts \"mirror=staging\"",
+			out: []*LineError{
+				{Line: 2, Command: `route add svc / http://a/ opts "mirror=staging"`, Message: `invalid value "staging" for option mirror`},
+			},
+		},
+		{
+			desc: "inactive deployment",
+			in:   `route add svc / http://a/ opts "deploy=v2 foo"`,
+			out: []*LineError{
+				{Line: 1, Command: `route add svc / http://a/ opts "deploy=v2 foo"`, Message: "unknown option foo"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got, want := Validate(tt.in), tt.out; !reflect.DeepEqual(got, want) {
+				for _, e := range got {
+					t.Logf("%+v", e)
+				}
+				t.Fatalf("got %v want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eBay/fabio/metrics"
+)
+
+// errListenerClosed is returned by a limitListener which
+// is closed while a connection waits for the limits.
+var errListenerClosed = errors.New("use of closed network connection")
+
+// limitListener limits the number of open connections and the rate
+// in which new connections are accepted. Connections beyond the
+// limits wait in the accept queue of the operating system until
+// a connection is closed or the rate permits the next one.
+type limitListener struct {
+	net.Listener
+
+	// sem has a slot for every open connection.
+	// It is nil if the number of connections is not limited.
+	sem chan struct{}
+
+	// interval is the minimum time between two accepted
+	// connections or zero if the rate is not limited.
+	interval time.Duration
+	next     time.Time
+
+	conns   int64
+	gauge   metrics.Gauge
+	waiting metrics.Counter
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// limit wraps the listener with a limitListener if the
+// listener has a connection limit or an accept rate.
+func limit(ln net.Listener, maxConns, acceptRate int, name string) net.Listener {
+	if maxConns <= 0 && acceptRate <= 0 {
+		return ln
+	}
+	l := &limitListener{
+		Listener: ln,
+		gauge:    metrics.DefaultRegistry.GetGauge("listener." + name + ".conns"),
+		waiting:  metrics.DefaultRegistry.GetCounter("listener." + name + ".limited"),
+		done:     make(chan struct{}),
+	}
+	if maxConns > 0 {
+		l.sem = make(chan struct{}, maxConns)
+	}
+	if acceptRate > 0 {
+		l.interval = time.Second / time.Duration(acceptRate)
+	}
+	return l
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		default:
+			l.waiting.Inc(1)
+			select {
+			case l.sem <- struct{}{}:
+			case <-l.done:
+				return nil, errListenerClosed
+			}
+		}
+	}
+
+	if l.interval > 0 {
+		if d := time.Until(l.next); d > 0 {
+			l.waiting.Inc(1)
+			t := time.NewTimer(d)
+			select {
+			case <-t.C:
+			case <-l.done:
+				t.Stop()
+				l.release()
+				return nil, errListenerClosed
+			}
+		}
+	}
+
+	c, err := l.Listener.Accept()
+	if err != nil {
+		l.release()
+		return nil, err
+	}
+	if l.interval > 0 {
+		l.next = time.Now().Add(l.interval)
+	}
+	l.gauge.Update(atomic.AddInt64(&l.conns, 1))
+	return &limitConn{Conn: c, l: l}, nil
+}
+
+func (l *limitListener) release() {
+	if l.sem != nil {
+		<-l.sem
+	}
+}
+
+func (l *limitListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// limitConn frees the slot of the connection in the
+// limitListener when it is closed.
+type limitConn struct {
+	net.Conn
+	l         *limitListener
+	closeOnce sync.Once
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		c.l.gauge.Update(atomic.AddInt64(&c.l.conns, -1))
+		c.l.release()
+	})
+	return err
+}
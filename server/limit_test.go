@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLimitListenerMaxConns(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := limit(ln, 1, 0, "test")
+	defer l.Close()
+
+	conns, errc := make(chan net.Conn), make(chan error, 1)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				errc <- err
+				return
+			}
+			conns <- c
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+
+	first := <-conns
+	select {
+	case <-conns:
+		t.Fatal("accepted connection beyond the limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	first.Close()
+	select {
+	case c := <-conns:
+		c.Close()
+	case <-time.After(time.Second):
+		t.Fatal("connection not accepted after close")
+	}
+
+	// close the listener while no connection is waiting
+	// and while the next one waits for a free slot
+	if _, err := net.Dial("tcp", ln.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	<-conns
+	l.Close()
+	select {
+	case err := <-errc:
+		if err != errListenerClosed {
+			t.Fatalf("got %v want %v", err, errListenerClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("accept not interrupted by close")
+	}
+}
+
+func TestLimitListenerAcceptRate(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := limit(ln, 0, 20, "test")
+	defer l.Close()
+
+	for i := 0; i < 3; i++ {
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		c, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+	if got, want := time.Since(start), 100*time.Millisecond; got < want {
+		t.Fatalf("accepted 3 connections in %s want at least %s", got, want)
+	}
+}
+
+func TestLimitUnlimited(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if got := limit(ln, 0, 0, "test"); got != ln {
+		t.Fatalf("got %T want unwrapped listener", got)
+	}
+}
@@ -0,0 +1,292 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/armon/go-proxyproto"
+	"github.com/eBay/fabio/cert"
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/metrics"
+	"github.com/eBay/fabio/proxy"
+)
+
+// listener is an open listener for the HTTP, HTTPS
+// or TCP+SNI proxy.
+type listener struct {
+	cfg  config.Listen
+	ln   net.Listener
+	srv  *http.Server
+	tcph proxy.TCPProxy
+
+	// quit is closed when the listener is closed so that
+	// the accept errors of the TCP proxy can be ignored.
+	quit chan bool
+}
+
+// listen opens the listeners with the HTTP handler which is
+// returned by h for each of them. If one of them cannot be
+// opened the others are closed and an error is returned.
+func listen(cfgs []config.Listen, h func(config.Listen) http.Handler, tcph proxy.TCPProxy) ([]*listener, error) {
+	var ls []*listener
+	for _, l := range cfgs {
+		var lis *listener
+		var err error
+		switch l.Proto {
+		case "tcp+sni":
+			lis, err = listenTCP(l, tcph)
+		case "http", "https", "unix":
+			lis, err = listenHTTP(l, h(l))
+		default:
+			err = fmt.Errorf("invalid protocol: %s", l.Proto)
+		}
+		if err != nil {
+			for _, l := range ls {
+				l.close()
+			}
+			return nil, err
+		}
+		ls = append(ls, lis)
+	}
+	return ls, nil
+}
+
+// listenerProxy returns the proxy config with the upstream transport
+// settings which are overridden by the listener. ok is false if the
+// listener does not override any of them.
+func listenerProxy(cfg config.Proxy, l config.Listen) (pcfg config.Proxy, ok bool) {
+	if l.MaxIdleConns > 0 {
+		cfg.MaxIdleConns, ok = l.MaxIdleConns, true
+	}
+	if l.IdleConnTimeout > 0 {
+		cfg.IdleConnTimeout, ok = l.IdleConnTimeout, true
+	}
+	if l.DisableKeepAlives {
+		cfg.DisableKeepAlives, ok = true, true
+	}
+	return cfg, ok
+}
+
+func listenTCP(l config.Listen, h proxy.TCPProxy) (*listener, error) {
+	ln, err := netListen("tcp", l.Addr)
+	if err != nil {
+		return nil, err
+	}
+	log.Print("[INFO] TCP+SNI proxy listening on ", l.Addr)
+	ln = limit(tcpKeepAliveListener{ln.(*net.TCPListener)}, l.MaxConns, l.AcceptRate, metrics.Clean(l.Addr))
+	ln = &proxyproto.Listener{Listener: ln}
+	// the timeouts wrap the proxyproto connections so that the TCP
+	// proxy can end the handshake. The deadlines also cover the header.
+	ln = timeouts(ln, l.ReadHeaderTimeout, l.IdleTimeout)
+	return &listener{cfg: l, ln: ln, tcph: h, quit: make(chan bool)}, nil
+}
+
+func listenHTTP(l config.Listen, h http.Handler) (*listener, error) {
+	srv := &http.Server{
+		Handler:           h,
+		Addr:              l.Addr,
+		ReadTimeout:       l.ReadTimeout,
+		WriteTimeout:      l.WriteTimeout,
+		ReadHeaderTimeout: l.ReadHeaderTimeout,
+		IdleTimeout:       l.IdleTimeout,
+		MaxHeaderBytes:    l.MaxHeaderBytes,
+	}
+
+	if l.Proto == "https" {
+		src, err := cert.NewSource(l.CertSource)
+		if err != nil {
+			return nil, err
+		}
+
+		srv.TLSConfig, err = cert.TLSConfig(src, l)
+		if err != nil {
+			return nil, err
+		}
+
+		errors := metrics.DefaultRegistry.GetCounter("tls." + metrics.Clean(l.Addr) + ".handshake_errors")
+		srv.ErrorLog = log.New(&handshakeErrorWriter{errors: errors}, "", 0)
+	}
+
+	if l.Redirect == "https" {
+		srv.Handler = redirectHTTPS(h, l.RedirectPort)
+	}
+
+	var ln net.Listener
+	if l.Proto == "unix" {
+		uln, err := netListen("unix", l.Addr)
+		if err != nil {
+			return nil, err
+		}
+		ln = uln
+		srv.Handler = unixClients(h)
+	} else {
+		tln, err := netListen("tcp", srv.Addr)
+		if err != nil {
+			return nil, err
+		}
+		ln = tcpKeepAliveListener{tln.(*net.TCPListener)}
+	}
+	ln = limit(ln, l.MaxConns, l.AcceptRate, metrics.Clean(l.Addr))
+	ln = &proxyproto.Listener{Listener: ln}
+
+	if srv.TLSConfig != nil {
+		ln = tls.NewListener(ln, srv.TLSConfig)
+		log.Printf("[INFO] HTTPS proxy listening on %s", l.Addr)
+		if srv.TLSConfig.ClientAuth == tls.RequireAndVerifyClientCert {
+			log.Printf("[INFO] Client certificate authentication enabled on %s", l.Addr)
+		}
+	} else if l.Proto == "unix" {
+		log.Printf("[INFO] HTTP proxy listening on unix socket %s", l.Addr)
+	} else {
+		log.Printf("[INFO] HTTP proxy listening on %s", l.Addr)
+	}
+	return &listener{cfg: l, ln: ln, srv: srv, quit: make(chan bool)}, nil
+}
+
+// removeStaleSocket removes the socket file of a previous
+// process which has not been shut down cleanly.
+func removeStaleSocket(path string) {
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	if c, err := net.Dial("unix", path); err == nil {
+		c.Close()
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		log.Printf("[WARN] Cannot remove stale socket %s. %s", path, err)
+	}
+}
+
+// unixClients sets the client address of the requests on unix socket
+// listeners to the loopback address since the peers of a unix socket
+// have no address which the proxy could forward or match.
+func unixClients(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := net.SplitHostPort(r.RemoteAddr); err != nil {
+			r.RemoteAddr = "127.0.0.1:0"
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// handshakeErrorWriter counts the TLS handshake errors which are
+// reported by the HTTP server and writes all messages to the log.
+type handshakeErrorWriter struct {
+	errors metrics.Counter
+}
+
+func (w *handshakeErrorWriter) Write(p []byte) (int, error) {
+	if bytes.Contains(p, []byte("TLS handshake error")) {
+		w.errors.Inc(1)
+	}
+	log.Print(string(p))
+	return len(p), nil
+}
+
+// serve accepts connections until the listener is closed.
+// It returns nil if the listener was closed via close or shutdown.
+func (l *listener) serve() error {
+	if l.srv != nil {
+		if err := l.srv.Serve(l.ln); err != nil && err != http.ErrServerClosed {
+			select {
+			case <-l.quit:
+				return nil
+			default:
+				return err
+			}
+		}
+		return nil
+	}
+
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			select {
+			case <-l.quit:
+				return nil
+			default:
+				return err
+			}
+		}
+		go l.tcph.Serve(conn)
+	}
+}
+
+// drain closes the idle keep-alive connections and sends
+// 'Connection: close' on all further responses so that
+// clients reconnect elsewhere and the drain finishes quickly.
+func (l *listener) drain() {
+	if l.srv != nil {
+		l.srv.SetKeepAlivesEnabled(false)
+	}
+}
+
+// close closes the listener and all connections of the HTTP server.
+func (l *listener) close() {
+	select {
+	case <-l.quit:
+		return
+	default:
+		close(l.quit)
+	}
+	if l.srv != nil {
+		l.srv.Close()
+		return
+	}
+	l.ln.Close()
+}
+
+// shutdown closes the listener and gives the active requests of the
+// HTTP server up to timeout to finish before their connections are
+// closed. The connections of the TCP proxy are not affected.
+func (l *listener) shutdown(timeout time.Duration) {
+	select {
+	case <-l.quit:
+		return
+	default:
+		close(l.quit)
+	}
+	l.ln.Close()
+	if l.srv == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := l.srv.Shutdown(ctx); err != nil {
+			l.srv.Close()
+		}
+	}()
+}
+
+// copied from http://golang.org/src/net/http/server.go?s=54604:54695#L1967
+// tcpKeepAliveListener sets TCP keep-alive timeouts on accepted
+// connections. It's used by ListenAndServe and ListenAndServeTLS so
+// dead TCP connections (e.g. closing laptop mid-download) eventually
+// go away.
+type tcpKeepAliveListener struct {
+	*net.TCPListener
+}
+
+func (ln tcpKeepAliveListener) Accept() (c net.Conn, err error) {
+	tc, err := ln.AcceptTCP()
+	if err != nil {
+		return
+	}
+	if err = tc.SetKeepAlive(true); err != nil {
+		return
+	}
+	if err = tc.SetKeepAlivePeriod(3 * time.Minute); err != nil {
+		return
+	}
+	return tc, nil
+}
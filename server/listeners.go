@@ -0,0 +1,175 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/proxy"
+)
+
+// listenerShutdownTimeout is the time the active requests of a
+// listener which is removed or replaced at runtime have to finish
+// before their connections are closed.
+var listenerShutdownTimeout = 30 * time.Second
+
+// listeners manages the open listeners of a running server by address
+// so that they can be added, replaced and removed at runtime.
+type listeners struct {
+	handler func(config.Listen) http.Handler
+	tcph    proxy.TCPProxy
+
+	// errc receives the first error of a listener
+	// which failed while serving.
+	errc chan error
+
+	mu    sync.Mutex
+	addrs []string
+	ls    map[string]*listener
+}
+
+func newListeners(h func(config.Listen) http.Handler, tcph proxy.TCPProxy) *listeners {
+	return &listeners{
+		handler: h,
+		tcph:    tcph,
+		errc:    make(chan error, 1),
+		ls:      map[string]*listener{},
+	}
+}
+
+// open opens and serves the listeners. If one of them cannot be
+// opened the others are closed and an error is returned.
+func (r *listeners) open(cfgs []config.Listen) error {
+	ls, err := listen(cfgs, r.handler, r.tcph)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, l := range ls {
+		r.serve(l)
+	}
+	return nil
+}
+
+// serve registers the listener and accepts connections in
+// the background. r.mu must be held.
+func (r *listeners) serve(l *listener) {
+	r.addrs = append(r.addrs, l.cfg.Addr)
+	r.ls[l.cfg.Addr] = l
+	go func() {
+		if err := l.serve(); err != nil {
+			select {
+			case r.errc <- err:
+			default:
+			}
+		}
+	}()
+}
+
+// unregister removes the listener from the registry. r.mu must be held.
+func (r *listeners) unregister(addr string) *listener {
+	l := r.ls[addr]
+	if l == nil {
+		return nil
+	}
+	delete(r.ls, addr)
+	for i, a := range r.addrs {
+		if a == addr {
+			r.addrs = append(r.addrs[:i], r.addrs[i+1:]...)
+			break
+		}
+	}
+	return l
+}
+
+// list returns the configuration of the open listeners
+// in the order in which they have been opened.
+func (r *listeners) list() []config.Listen {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cfgs := make([]config.Listen, 0, len(r.addrs))
+	for _, addr := range r.addrs {
+		cfgs = append(cfgs, r.ls[addr].cfg)
+	}
+	return cfgs
+}
+
+// add opens a new listener. The address must not be in use
+// by another listener.
+func (r *listeners) add(cfg config.Listen) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ls[cfg.Addr] != nil {
+		return fmt.Errorf("listener %s already exists", cfg.Addr)
+	}
+	ls, err := listen([]config.Listen{cfg}, r.handler, r.tcph)
+	if err != nil {
+		return err
+	}
+	r.serve(ls[0])
+	log.Printf("[INFO] Added listener %s", cfg.Addr)
+	return nil
+}
+
+// update replaces the listener on the same address with a listener
+// for the new configuration. The active requests of the old listener
+// can finish. If the new listener cannot be opened the old
+// configuration is restored.
+func (r *listeners) update(cfg config.Listen) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := r.unregister(cfg.Addr)
+	if old == nil {
+		return fmt.Errorf("listener %s does not exist", cfg.Addr)
+	}
+	old.shutdown(listenerShutdownTimeout)
+
+	ls, err := listen([]config.Listen{cfg}, r.handler, r.tcph)
+	if err != nil {
+		if prev, perr := listen([]config.Listen{old.cfg}, r.handler, r.tcph); perr == nil {
+			r.serve(prev[0])
+		} else {
+			log.Printf("[ERROR] Cannot restore listener %s. %s", cfg.Addr, perr)
+		}
+		return err
+	}
+	r.serve(ls[0])
+	log.Printf("[INFO] Updated listener %s", cfg.Addr)
+	return nil
+}
+
+// remove closes the listener with the address. The active requests
+// can finish.
+func (r *listeners) remove(addr string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l := r.unregister(addr)
+	if l == nil {
+		return fmt.Errorf("listener %s does not exist", addr)
+	}
+	l.shutdown(listenerShutdownTimeout)
+	log.Printf("[INFO] Removed listener %s", addr)
+	return nil
+}
+
+// drain drains all listeners.
+func (r *listeners) drain() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, l := range r.ls {
+		l.drain()
+	}
+}
+
+// close closes all listeners and their connections.
+func (r *listeners) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, addr := range append([]string{}, r.addrs...) {
+		r.unregister(addr).close()
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/eBay/fabio/config"
+)
+
+func TestListeners(t *testing.T) {
+	handler := func(l config.Listen) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(l.Addr))
+		})
+	}
+	get := func(addr string) (string, error) {
+		resp, err := http.Get("http://" + addr + "/")
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	ls := newListeners(handler, nil)
+	defer ls.close()
+
+	a := config.Listen{Addr: freeAddr(t), Proto: "http"}
+	if err := ls.open([]config.Listen{a}); err != nil {
+		t.Fatal(err)
+	}
+
+	b := config.Listen{Addr: freeAddr(t), Proto: "http"}
+	if err := ls.add(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.add(b); err == nil {
+		t.Fatal("add: expected error for existing listener")
+	}
+	if body, err := get(b.Addr); err != nil || body != b.Addr {
+		t.Fatalf("got %q, %v want %q", body, err, b.Addr)
+	}
+	if got, want := ls.list(), []config.Listen{a, b}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+
+	b.StrictMatch = true
+	if err := ls.update(b); err != nil {
+		t.Fatal(err)
+	}
+	if body, err := get(b.Addr); err != nil || body != b.Addr {
+		t.Fatalf("got %q, %v want %q", body, err, b.Addr)
+	}
+	if got, want := ls.list(), []config.Listen{a, b}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+
+	if err := ls.update(config.Listen{Addr: b.Addr, Proto: "foo"}); err == nil {
+		t.Fatal("update: expected error for invalid protocol")
+	}
+	if got, want := ls.list(), []config.Listen{a, b}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if _, err := get(b.Addr); err != nil {
+		t.Fatal("update: listener not restored: ", err)
+	}
+
+	if err := ls.remove(a.Addr); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.remove(a.Addr); err == nil {
+		t.Fatal("remove: expected error for missing listener")
+	}
+	if _, err := get(a.Addr); err == nil {
+		t.Fatal("remove: listener still open")
+	}
+	if got, want := ls.list(), []config.Listen{b}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+
+	select {
+	case err := <-ls.errc:
+		t.Fatal("unexpected listener error: ", err)
+	default:
+	}
+}
+
+// freeAddr returns a local address with a free port.
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"os/user"
+	"strconv"
+)
+
+// lookupIDs returns the numeric user and group id for the user and
+// group names or ids. The group defaults to the primary group of the
+// user. uid or gid is -1 if the user or the group is not set.
+func lookupIDs(username, groupname string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if username != "" {
+		u, err := user.Lookup(username)
+		if err != nil {
+			if u, err = user.LookupId(username); err != nil {
+				return -1, -1, fmt.Errorf("unknown user %q", username)
+			}
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return -1, -1, fmt.Errorf("invalid uid %q for user %q", u.Uid, username)
+		}
+		if groupname == "" {
+			if gid, err = strconv.Atoi(u.Gid); err != nil {
+				return -1, -1, fmt.Errorf("invalid gid %q for user %q", u.Gid, username)
+			}
+		}
+	}
+	if groupname != "" {
+		g, err := user.LookupGroup(groupname)
+		if err != nil {
+			if g, err = user.LookupGroupId(groupname); err != nil {
+				return -1, -1, fmt.Errorf("unknown group %q", groupname)
+			}
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return -1, -1, fmt.Errorf("invalid gid %q for group %q", g.Gid, groupname)
+		}
+	}
+	return uid, gid, nil
+}
+
+// dropPrivileges switches the process to the user and the group.
+// Nothing happens if neither is set.
+func dropPrivileges(username, groupname string) error {
+	if username == "" && groupname == "" {
+		return nil
+	}
+	uid, gid, err := lookupIDs(username, groupname)
+	if err != nil {
+		return err
+	}
+	if err := setIDs(uid, gid); err != nil {
+		return fmt.Errorf("cannot switch to user %q and group %q: %s", username, groupname, err)
+	}
+	log.Printf("[INFO] Switched to user %q and group %q", username, groupname)
+	return nil
+}
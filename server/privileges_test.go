@@ -0,0 +1,45 @@
+package server
+
+import (
+	"os/user"
+	"strconv"
+	"testing"
+)
+
+func TestLookupIDs(t *testing.T) {
+	u, err := user.Current()
+	if err != nil {
+		t.Skip("cannot get current user: ", err)
+	}
+	g, err := user.LookupGroupId(u.Gid)
+	if err != nil {
+		t.Skip("cannot get group of current user: ", err)
+	}
+	uid, _ := strconv.Atoi(u.Uid)
+	gid, _ := strconv.Atoi(u.Gid)
+
+	tests := []struct {
+		user, group string
+		uid, gid    int
+		err         string
+	}{
+		{"", "", -1, -1, ""},
+		{u.Username, "", uid, gid, ""},
+		{u.Uid, "", uid, gid, ""},
+		{"", g.Name, -1, gid, ""},
+		{u.Username, g.Gid, uid, gid, ""},
+		{"no-such-user-fabio", "", -1, -1, `unknown user "no-such-user-fabio"`},
+		{"", "no-such-group-fabio", -1, -1, `unknown group "no-such-group-fabio"`},
+	}
+
+	for i, tt := range tests {
+		uid, gid, err := lookupIDs(tt.user, tt.group)
+		var errs string
+		if err != nil {
+			errs = err.Error()
+		}
+		if uid != tt.uid || gid != tt.gid || errs != tt.err {
+			t.Errorf("%d: got %d, %d, %q want %d, %d, %q", i, uid, gid, errs, tt.uid, tt.gid, tt.err)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package server
+
+import "syscall"
+
+// setIDs sets the group and then the user id of all threads of the
+// process. The supplementary groups are replaced with the group.
+// Ids which are -1 are not changed.
+func setIDs(uid, gid int) error {
+	if gid >= 0 {
+		if err := syscall.Setgroups([]int{gid}); err != nil {
+			return err
+		}
+		if err := syscall.Setgid(gid); err != nil {
+			return err
+		}
+	}
+	if uid >= 0 {
+		if err := syscall.Setuid(uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+package server
+
+import "errors"
+
+func setIDs(uid, gid int) error {
+	return errors.New("not supported on windows")
+}
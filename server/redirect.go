@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// acmeChallengePath is the path prefix of the ACME HTTP-01
+// challenges which must be answered via plain HTTP.
+const acmeChallengePath = "/.well-known/acme-challenge/"
+
+// redirectHTTPS redirects all requests except the ACME challenges to
+// the same host and path on https. The port of the request host is
+// replaced with port or removed if port is empty. GET and HEAD requests
+// are redirected with '301 Moved Permanently' and all other requests
+// with '308 Permanent Redirect' so that the method and body are kept.
+func redirectHTTPS(h http.Handler, port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, acmeChallengePath) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		host := r.Host
+		if host == "" {
+			http.Error(w, "missing host", http.StatusBadRequest)
+			return
+		}
+		if name, _, err := net.SplitHostPort(host); err == nil {
+			host = name
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		if port != "" {
+			host += ":" + port
+		}
+		code := http.StatusPermanentRedirect
+		if r.Method == "GET" || r.Method == "HEAD" {
+			code = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+	})
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectHTTPS(t *testing.T) {
+	routed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	tests := []struct {
+		method, host, uri, port string
+		code                    int
+		location                string
+	}{
+		{"GET", "example.com", "/a/b?c=d", "", 301, "https://example.com/a/b?c=d"},
+		{"HEAD", "example.com:80", "/", "", 301, "https://example.com/"},
+		{"POST", "example.com:8080", "/form", "8443", 308, "https://example.com:8443/form"},
+		{"GET", "[::1]:80", "/", "", 301, "https://[::1]/"},
+		{"GET", "example.com", "/.well-known/acme-challenge/token", "", 418, ""},
+		{"GET", "", "/", "", 400, ""},
+	}
+
+	for i, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.uri, nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		redirectHTTPS(routed, tt.port).ServeHTTP(rec, req)
+		if got, want := rec.Code, tt.code; got != want {
+			t.Errorf("%d: got code %d want %d", i, got, want)
+		}
+		if got, want := rec.Header().Get("Location"), tt.location; got != want {
+			t.Errorf("%d: got location %q want %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+// Package server wires the HTTP and TCP proxies, the routing table
+// and the registry backend together so that the routing engine of
+// fabio can be embedded in other programs.
+//
+//	srv := server.New(cfg)
+//	srv.Middleware = myMiddleware
+//	err := srv.Start(ctx)
+//
+// The proxy and the routing table use package level state. Only one
+// server should be started per process and a server cannot be
+// restarted after it has been shut down.
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/proxy"
+	"github.com/eBay/fabio/registry"
+	"github.com/eBay/fabio/route"
+)
+
+// Server runs the listeners for the proxy and keeps the
+// routing table in sync with the registry backend.
+type Server struct {
+	// Config is the configuration of the server.
+	Config *config.Config
+
+	// Backend provides the routes. If it is nil the backend
+	// configured in Config.Registry is created on start.
+	Backend registry.Backend
+
+	// Handler handles the requests of the HTTP and HTTPS listeners.
+	// If it is nil the fabio HTTP proxy is used.
+	Handler http.Handler
+
+	// Middleware wraps the Handler if it is not nil.
+	Middleware func(http.Handler) http.Handler
+
+	// TCPProxy handles the connections of the TCP+SNI listeners.
+	// If it is nil the fabio TCP+SNI proxy is used.
+	TCPProxy proxy.TCPProxy
+
+	mu sync.Mutex
+	ls *listeners
+}
+
+// New returns a server for the configuration.
+func New(cfg *config.Config) *Server {
+	return &Server{Config: cfg}
+}
+
+// Start registers the backend, starts watching it for route changes
+// and runs the listeners until the context is done or a listener
+// fails. When the context is done the service is deregistered and
+// the proxy stops routing new requests for the duration of
+// Config.Proxy.ShutdownWait before the listeners are closed.
+func (s *Server) Start(ctx context.Context) error {
+	cfg := s.Config
+
+	if err := route.SetPickerStrategy(cfg.Proxy.Strategy); err != nil {
+		return err
+	}
+	log.Printf("[INFO] Using routing strategy %q", cfg.Proxy.Strategy)
+
+	if err := route.SetMatcher(cfg.Proxy.Matcher); err != nil {
+		return err
+	}
+	log.Printf("[INFO] Using routing matching %q", cfg.Proxy.Matcher)
+
+	route.SetSlowStart(cfg.Proxy.SlowStart)
+
+	if _, err := proxy.MiddlewareChain(cfg.Proxy.Middleware); err != nil {
+		return err
+	}
+
+	h := s.Handler
+	if h == nil {
+		tr, err := proxy.NewTransport(cfg.Proxy)
+		if err != nil {
+			return err
+		}
+		h = proxy.NewHTTPProxy(tr, cfg.Proxy)
+	}
+	if s.Middleware != nil {
+		h = s.Middleware(h)
+	}
+	handler := func(config.Listen) http.Handler { return h }
+	if s.Handler == nil {
+		handler = func(l config.Listen) http.Handler {
+			pcfg, ok := listenerProxy(cfg.Proxy, l)
+			if !ok {
+				return h
+			}
+			// the TLS config has been validated with the default transport
+			tr, _ := proxy.NewTransport(pcfg)
+			lh := proxy.NewHTTPProxy(tr, pcfg)
+			if s.Middleware != nil {
+				lh = s.Middleware(lh)
+			}
+			return lh
+		}
+	}
+
+	tcph := s.TCPProxy
+	if tcph == nil {
+		tcph = proxy.NewTCPSNIProxy(cfg.Proxy)
+	}
+
+	// retry the backend until the deadline and keep the proxy
+	// from routing requests until the first routing table has
+	// been loaded or the deadline has expired.
+	deadline := time.Now().Add(cfg.Registry.Timeout)
+	if s.Backend == nil {
+		err := registry.Retry(time.Until(deadline), cfg.Registry.Retry, func() (err error) {
+			s.Backend, err = registry.New(cfg.Registry.Backend, &cfg.Registry)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	registry.Default = s.Backend
+	if err := registry.Retry(time.Until(deadline), cfg.Registry.Retry, s.Backend.Register); err != nil {
+		return err
+	}
+	if cfg.Registry.Timeout > 0 {
+		proxy.SetReady(false)
+	}
+
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	defer stopWatch()
+	go watchBackend(watchCtx, s.Backend, time.Until(deadline))
+	go route.DetectOutliers(watchCtx, route.OutlierDetection{
+		Multiple:    cfg.Proxy.OutlierMultiple,
+		Percentile:  cfg.Proxy.OutlierPercentile,
+		MinRequests: int64(cfg.Proxy.OutlierMinRequests),
+		Window:      cfg.Proxy.OutlierWindow,
+		Interval:    cfg.Proxy.OutlierInterval,
+		Cooldown:    cfg.Proxy.OutlierCooldown,
+		MaxEjection: cfg.Proxy.OutlierMaxEjection,
+	})
+
+	ls := newListeners(handler, tcph)
+	if err := ls.open(cfg.Listen); err != nil {
+		s.Backend.Deregister()
+		return err
+	}
+	if err := dropPrivileges(cfg.Runtime.User, cfg.Runtime.Group); err != nil {
+		ls.close()
+		s.Backend.Deregister()
+		return err
+	}
+	s.mu.Lock()
+	s.ls = ls
+	s.mu.Unlock()
+	notifyReady()
+
+	var err error
+	select {
+	case err = <-ls.errc:
+	case <-ctx.Done():
+	}
+
+	s.Backend.Deregister()
+
+	// disable routing for all requests and drain the connections
+	proxy.Shutdown()
+	ls.drain()
+	if err == nil {
+		log.Printf("[INFO] Graceful shutdown over %s", cfg.Proxy.ShutdownWait)
+		time.Sleep(cfg.Proxy.ShutdownWait)
+	}
+	s.mu.Lock()
+	s.ls = nil
+	s.mu.Unlock()
+	ls.close()
+	log.Print("[INFO] Down")
+	return err
+}
+
+// errNotRunning is returned when the listeners are
+// managed before the server has been started.
+var errNotRunning = errors.New("server is not running")
+
+func (s *Server) listeners() (*listeners, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ls == nil {
+		return nil, errNotRunning
+	}
+	return s.ls, nil
+}
+
+// Listeners returns the configuration of the open listeners.
+func (s *Server) Listeners() []config.Listen {
+	ls, err := s.listeners()
+	if err != nil {
+		return nil
+	}
+	return ls.list()
+}
+
+// AddListener opens a new listener while the server is running.
+func (s *Server) AddListener(l config.Listen) error {
+	ls, err := s.listeners()
+	if err != nil {
+		return err
+	}
+	return ls.add(l)
+}
+
+// UpdateListener replaces the listener on the same address with a
+// listener for the new configuration while the server is running.
+func (s *Server) UpdateListener(l config.Listen) error {
+	ls, err := s.listeners()
+	if err != nil {
+		return err
+	}
+	return ls.update(l)
+}
+
+// RemoveListener closes the listener on the address while the
+// server is running.
+func (s *Server) RemoveListener(addr string) error {
+	ls, err := s.listeners()
+	if err != nil {
+		return err
+	}
+	return ls.remove(addr)
+}
+
+// rampInterval is the interval in which the routing table is
+// rebuilt while the weight of a target is ramped up or down
+// or in slow start.
+var rampInterval = 10 * time.Second
+
+// watchBackend updates the routing table with the routes
+// from the backend until the context is done. If the proxy
+// is not ready it becomes ready when the first routing table
+// with the services has been loaded or after the gate duration.
+func watchBackend(ctx context.Context, b registry.Backend, gate time.Duration) {
+	var (
+		last   string
+		svccfg string
+		mancfg string
+		hasSvc bool
+	)
+
+	svc := b.WatchServices()
+	man := b.WatchManual()
+
+	ramp := time.NewTicker(rampInterval)
+	defer ramp.Stop()
+
+	var ready <-chan time.Time
+	if !proxy.Ready() {
+		t := time.NewTimer(gate)
+		defer t.Stop()
+		ready = t.C
+	}
+	setReady := func() {
+		if ready != nil && hasSvc {
+			log.Print("[INFO] Routing table loaded. Accepting requests")
+			proxy.SetReady(true)
+			ready = nil
+		}
+	}
+
+	for {
+		reweigh := false
+		var source string
+		select {
+		case svccfg = <-svc:
+			source, hasSvc = "services", true
+		case mancfg = <-man:
+			source = "manual"
+		case <-ramp.C:
+			reweigh = route.Ramping() || route.SlowStarting()
+			source = route.SourceRamp
+		case <-ready:
+			log.Printf("[WARN] No routing table loaded within %s. Accepting requests", gate)
+			proxy.SetReady(true)
+			ready = nil
+			continue
+		case <-ctx.Done():
+			return
+		}
+
+		// manual config overrides service config
+		// order matters
+		next := svccfg + "\n" + mancfg
+		if next == last && !reweigh {
+			setReady()
+			continue
+		}
+
+		t, err := route.ParseString(next)
+		if err != nil {
+			log.Printf("[WARN] %s", err)
+			continue
+		}
+
+		// only update the table when the weights have changed
+		if next == last && t.Checksum() == route.GetTable().Checksum() {
+			continue
+		}
+		route.SetTableFrom(t, source)
+
+		last = next
+		setReady()
+	}
+}
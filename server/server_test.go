@@ -0,0 +1,249 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/proxy"
+	"github.com/eBay/fabio/registry"
+	"github.com/eBay/fabio/registry/static"
+)
+
+func TestGracefulShutdown(t *testing.T) {
+	req := func(url string) (int, bool) {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode, resp.Close
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// start a server which responds after the shutdown has been triggered.
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-ctx.Done() // wait for shutdown signal
+	}))
+	defer upstream.Close()
+
+	be, err := static.NewBackend("route add svc / " + upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// start proxy with graceful shutdown period long enough
+	// to complete one more request.
+	l := config.Listen{Addr: "127.0.0.1:57777", Proto: "http"}
+	cfg := &config.Config{
+		Listen: []config.Listen{l},
+		Proxy:  config.Proxy{Strategy: "rnd", Matcher: "prefix", ShutdownWait: 250 * time.Millisecond},
+	}
+	var middleware int32
+	srv := New(cfg)
+	srv.Backend = be
+	srv.Middleware = func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.StoreInt32(&middleware, 1)
+			h.ServeHTTP(w, r)
+		})
+	}
+
+	done := make(chan error)
+	go func() { done <- srv.Start(ctx) }()
+
+	// trigger shutdown after some time
+	shutdownDelay := 100 * time.Millisecond
+	go func() {
+		time.Sleep(shutdownDelay)
+		cancel()
+	}()
+
+	// give proxy some time to start up
+	// needs to be done before shutdown is triggered
+	time.Sleep(shutdownDelay / 2)
+
+	// make 200 OK request
+	// start before and complete after shutdown was triggered
+	code, closed := req("http://" + l.Addr + "/")
+	if got, want := code, 200; got != want {
+		t.Fatalf("request 1: got %v want %v", got, want)
+	}
+	if !closed {
+		t.Fatal("request 1: got keep-alive want 'Connection: close'")
+	}
+	if atomic.LoadInt32(&middleware) == 0 {
+		t.Fatal("request 1: middleware not called")
+	}
+
+	// make 503 request
+	// start and complete after shutdown was triggered
+	code, closed = req("http://" + l.Addr + "/")
+	if got, want := code, 503; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if !closed {
+		t.Fatal("request 2: got keep-alive want 'Connection: close'")
+	}
+
+	// wait for Start() to return and the listeners to be closed
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("got %v want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+	if _, err := http.Get("http://" + l.Addr + "/"); err == nil {
+		t.Fatal("got nil want error after shutdown")
+	}
+}
+
+func TestStartInvalidListener(t *testing.T) {
+	be, err := static.NewBackend("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := New(&config.Config{
+		Listen: []config.Listen{{Addr: "127.0.0.1:57778", Proto: "foo"}},
+		Proxy:  config.Proxy{Strategy: "rnd", Matcher: "prefix"},
+	})
+	srv.Backend = be
+	if err := srv.Start(context.Background()); err == nil {
+		t.Fatal("got nil want error")
+	}
+}
+
+type counter struct{ n int64 }
+
+func (c *counter) Inc(n int64) { c.n += n }
+
+func TestHandshakeErrorWriter(t *testing.T) {
+	c := &counter{}
+	l := log.New(&handshakeErrorWriter{errors: c}, "", 0)
+	l.Printf("http: TLS handshake error from 1.2.3.4:5678: EOF")
+	l.Printf("http: Accept error: too many open files")
+	l.Printf("http: TLS handshake error from 1.2.3.4:5679: remote error: tls: bad certificate")
+	if got, want := c.n, int64(2); got != want {
+		t.Fatalf("got %d handshake errors want %d", got, want)
+	}
+}
+
+func TestListenerProxy(t *testing.T) {
+	cfg := config.Proxy{MaxIdleConns: 10, IdleConnTimeout: time.Second}
+
+	if _, ok := listenerProxy(cfg, config.Listen{Addr: ":1"}); ok {
+		t.Fatal("got override for listener without transport options")
+	}
+
+	got, ok := listenerProxy(cfg, config.Listen{Addr: ":1", MaxIdleConns: 5, DisableKeepAlives: true})
+	if !ok {
+		t.Fatal("got no override")
+	}
+	want := config.Proxy{MaxIdleConns: 5, IdleConnTimeout: time.Second, DisableKeepAlives: true}
+	if got.MaxIdleConns != want.MaxIdleConns || got.IdleConnTimeout != want.IdleConnTimeout || got.DisableKeepAlives != want.DisableKeepAlives {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "fabio.sock")
+
+	// a stale socket from a previous process is replaced
+	stale, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.RemoteAddr))
+	})
+	ls, err := listen([]config.Listen{{Addr: sock, Proto: "unix"}}, func(config.Listen) http.Handler { return h }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls[0].close()
+	go ls[0].serve()
+
+	client := &http.Client{Transport: &http.Transport{
+		Dial: func(string, string) (net.Conn, error) { return net.Dial("unix", sock) },
+	}}
+	resp, err := client.Get("http://unix/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), "127.0.0.1:0"; got != want {
+		t.Fatalf("got remote addr %q want %q", got, want)
+	}
+}
+
+// silentBackend never sends any routes.
+type silentBackend struct{ registry.Backend }
+
+func (silentBackend) WatchServices() chan string { return make(chan string) }
+func (silentBackend) WatchManual() chan string   { return make(chan string) }
+
+func TestWatchBackendReady(t *testing.T) {
+	waitReady := func(d time.Duration) bool {
+		deadline := time.Now().Add(d)
+		for time.Now().Before(deadline) {
+			if proxy.Ready() {
+				return true
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return false
+	}
+
+	t.Run("routes loaded", func(t *testing.T) {
+		be, err := static.NewBackend("route add svc / http://127.0.0.1:5000/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		proxy.SetReady(false)
+		defer proxy.SetReady(true)
+		go watchBackend(ctx, be, time.Hour)
+		if !waitReady(time.Second) {
+			t.Fatal("proxy not ready after the routing table was loaded")
+		}
+	})
+
+	t.Run("gate expired", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		proxy.SetReady(false)
+		defer proxy.SetReady(true)
+		go watchBackend(ctx, silentBackend{}, 50*time.Millisecond)
+		if waitReady(25 * time.Millisecond) {
+			t.Fatal("proxy ready without routing table")
+		}
+		if !waitReady(time.Second) {
+			t.Fatal("proxy not ready after the gate expired")
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// listenFDsStart is the first file descriptor
+// which is passed by systemd.
+const listenFDsStart = 3
+
+// activated contains the listening sockets which have been passed
+// by systemd via socket activation and are not used yet.
+var activated struct {
+	once sync.Once
+	mu   sync.Mutex
+	ls   []net.Listener
+}
+
+// activatedListeners returns the listening sockets from the
+// LISTEN_PID and LISTEN_FDS environment variables which are set
+// by systemd for socket activated services. The variables are
+// removed so that they are not inherited by child processes.
+func activatedListeners() []net.Listener {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil
+	}
+
+	var ls []net.Listener
+	for fd := listenFDsStart; fd < listenFDsStart+n; fd++ {
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			log.Printf("[WARN] Ignoring socket %d from systemd. %s", fd, err)
+			continue
+		}
+		log.Printf("[INFO] Received socket %s from systemd", ln.Addr())
+		ls = append(ls, ln)
+	}
+	return ls
+}
+
+// takeActivated returns the socket from systemd which listens
+// on the address and removes it from the list of available
+// sockets. It returns nil if there is no such socket.
+func takeActivated(network, addr string) net.Listener {
+	activated.once.Do(func() { activated.ls = activatedListeners() })
+
+	activated.mu.Lock()
+	defer activated.mu.Unlock()
+	for i, ln := range activated.ls {
+		if listensOn(ln, network, addr) {
+			activated.ls = append(activated.ls[:i], activated.ls[i+1:]...)
+			return ln
+		}
+	}
+	return nil
+}
+
+// listensOn returns true if the listener listens on the address.
+// A TCP address without a host matches a socket on all interfaces.
+func listensOn(ln net.Listener, network, addr string) bool {
+	switch a := ln.Addr().(type) {
+	case *net.TCPAddr:
+		if network != "tcp" {
+			return false
+		}
+		want, err := net.ResolveTCPAddr("tcp", addr)
+		if err != nil || want.Port != a.Port {
+			return false
+		}
+		if want.IP == nil || want.IP.IsUnspecified() {
+			return a.IP == nil || a.IP.IsUnspecified()
+		}
+		return want.IP.Equal(a.IP)
+	case *net.UnixAddr:
+		return network == "unix" && a.Name == addr
+	}
+	return false
+}
+
+// netListen returns the socket from systemd for the address if
+// there is one and opens a new one otherwise.
+func netListen(network, addr string) (net.Listener, error) {
+	if ln := takeActivated(network, addr); ln != nil {
+		log.Printf("[INFO] Using socket %s from systemd", addr)
+		return ln, nil
+	}
+	if network == "unix" {
+		removeStaleSocket(addr)
+	}
+	return net.Listen(network, addr)
+}
+
+// notifyReady tells systemd that the service has started when it
+// runs as a service of type 'notify'.
+func notifyReady() {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return
+	}
+	conn, err := net.Dial("unixgram", addr)
+	if err != nil {
+		log.Printf("[WARN] Cannot notify systemd. %s", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("READY=1")); err != nil {
+		log.Printf("[WARN] Cannot notify systemd. %s", err)
+	}
+}
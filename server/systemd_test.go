@@ -0,0 +1,109 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestListensOn(t *testing.T) {
+	any, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer any.Close()
+	anyPort := strconv.Itoa(any.Addr().(*net.TCPAddr).Port)
+
+	local, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+	localPort := strconv.Itoa(local.Addr().(*net.TCPAddr).Port)
+
+	tests := []struct {
+		ln      net.Listener
+		network string
+		addr    string
+		ok      bool
+	}{
+		{any, "tcp", ":" + anyPort, true},
+		{any, "tcp", "0.0.0.0:" + anyPort, true},
+		{any, "tcp", "127.0.0.1:" + anyPort, false},
+		{any, "tcp", ":1", false},
+		{any, "unix", ":" + anyPort, false},
+		{local, "tcp", "127.0.0.1:" + localPort, true},
+		{local, "tcp", ":" + localPort, false},
+	}
+	for i, tt := range tests {
+		if got, want := listensOn(tt.ln, tt.network, tt.addr), tt.ok; got != want {
+			t.Errorf("%d: %s %s: got %v want %v", i, tt.network, tt.addr, got, want)
+		}
+	}
+}
+
+func TestNetListenActivated(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	activated.once.Do(func() {})
+	activated.mu.Lock()
+	activated.ls = []net.Listener{ln}
+	activated.mu.Unlock()
+
+	got, err := netListen("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != ln {
+		t.Fatal("socket from systemd not used")
+	}
+	if len(activated.ls) != 0 {
+		t.Fatal("socket from systemd not removed")
+	}
+}
+
+func TestActivatedListenersOtherProcess(t *testing.T) {
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	os.Setenv("LISTEN_FDS", "1")
+	if ls := activatedListeners(); ls != nil {
+		t.Fatalf("got %v want nil", ls)
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Fatal("LISTEN_FDS not removed")
+	}
+}
+
+func TestNotifyReady(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sock, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", sock)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+	notifyReady()
+
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf[:n]), "READY=1"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
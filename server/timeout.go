@@ -0,0 +1,97 @@
+package server
+
+import (
+	"net"
+	"time"
+)
+
+// timeoutListener enforces the handshake and idle timeouts of
+// a TCP+SNI listener on the accepted connections.
+type timeoutListener struct {
+	net.Listener
+	handshake time.Duration
+	idle      time.Duration
+}
+
+// timeouts wraps the listener with a timeoutListener if
+// the listener has a handshake or an idle timeout.
+func timeouts(ln net.Listener, handshake, idle time.Duration) net.Listener {
+	if handshake <= 0 && idle <= 0 {
+		return ln
+	}
+	return &timeoutListener{Listener: ln, handshake: handshake, idle: idle}
+}
+
+func (l *timeoutListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if l.handshake > 0 {
+		c.SetReadDeadline(time.Now().Add(l.handshake))
+	} else if l.idle > 0 {
+		c.SetReadDeadline(time.Now().Add(l.idle))
+	}
+	return &timeoutConn{Conn: c, idle: l.idle, handshake: l.handshake > 0}, nil
+}
+
+// timeoutConn moves the read deadline after every read and write
+// so that the connection is closed only if there is no traffic in
+// either direction within the idle timeout. Writes must complete
+// within the idle timeout so that clients which do not read cannot
+// block the connection.
+//
+// The handshake deadline set by the listener is absolute. It is not
+// moved by the traffic of the handshake so that clients cannot hold
+// the connection by sending the handshake byte by byte. The proxy
+// calls HandshakeDone after it has read the server name to switch
+// to the idle timeout.
+type timeoutConn struct {
+	net.Conn
+	idle      time.Duration
+	handshake bool
+}
+
+// HandshakeDone replaces the handshake deadline with the idle timeout.
+func (c *timeoutConn) HandshakeDone() {
+	c.handshake = false
+	c.extend()
+}
+
+// IdleTimeout returns the idle timeout of the connection which
+// the proxy also applies to the upstream connection.
+func (c *timeoutConn) IdleTimeout() time.Duration {
+	return c.idle
+}
+
+func (c *timeoutConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.extend()
+	}
+	return n, err
+}
+
+func (c *timeoutConn) Write(b []byte) (int, error) {
+	if c.idle > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(c.idle))
+	}
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.extend()
+	}
+	return n, err
+}
+
+// extend moves the read deadline by the idle timeout or clears
+// it if there is none. It keeps the deadline during the handshake.
+func (c *timeoutConn) extend() {
+	if c.handshake {
+		return
+	}
+	var t time.Time
+	if c.idle > 0 {
+		t = time.Now().Add(c.idle)
+	}
+	c.Conn.SetReadDeadline(t)
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTimeoutListener(t *testing.T) {
+	tests := []struct {
+		desc            string
+		handshake, idle time.Duration
+		sends           []time.Duration
+		done            bool
+		closed          bool
+	}{
+		{"late handshake", 50 * time.Millisecond, 0, []time.Duration{100 * time.Millisecond}, false, true},
+		{"slow handshake", 50 * time.Millisecond, 0, []time.Duration{0, 30 * time.Millisecond, 30 * time.Millisecond}, false, true},
+		{"handshake without idle timeout", 50 * time.Millisecond, 0, []time.Duration{0, 100 * time.Millisecond}, true, false},
+		{"traffic within idle timeout", 50 * time.Millisecond, 100 * time.Millisecond, []time.Duration{0, 60 * time.Millisecond, 60 * time.Millisecond}, true, false},
+		{"idle", 50 * time.Millisecond, 50 * time.Millisecond, []time.Duration{0, 100 * time.Millisecond}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ln := timeouts(l, tt.handshake, tt.idle)
+			defer ln.Close()
+
+			errc := make(chan error, 1)
+			go func() {
+				c, err := ln.Accept()
+				if err != nil {
+					errc <- err
+					return
+				}
+				defer c.Close()
+				b := make([]byte, 1)
+				for i := range tt.sends {
+					if _, err := c.Read(b); err != nil {
+						errc <- err
+						return
+					}
+					// the proxy ends the handshake after the first read
+					if i == 0 && tt.done {
+						c.(*timeoutConn).HandshakeDone()
+					}
+				}
+				errc <- nil
+			}()
+
+			c, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			for _, d := range tt.sends {
+				time.Sleep(d)
+				c.Write([]byte("x"))
+			}
+
+			err = <-errc
+			if got, want := err != nil, tt.closed; got != want {
+				t.Fatalf("got error %v want closed %v", err, want)
+			}
+		})
+	}
+}
+
+func TestTimeoutsDisabled(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if got := timeouts(l, 0, 0); got != l {
+		t.Fatalf("got %T want unwrapped listener", got)
+	}
+}
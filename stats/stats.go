@@ -0,0 +1,394 @@
+// Package stats collects in-process request statistics for the routes
+// and targets over a sliding time window. They are used to find the
+// busiest routes without an external metrics backend.
+package stats
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// bucketSize is the time span which is covered by one bucket.
+	bucketSize = 10 * time.Second
+
+	// numBuckets is the number of buckets in the window.
+	numBuckets = 30
+
+	// Window is the time span over which the statistics are collected.
+	Window = numBuckets * bucketSize
+
+	// Resolution is the time span of the live statistics.
+	Resolution = bucketSize
+
+	// numLatencyBuckets is the number of buckets of the latency
+	// histogram. The upper bound of bucket i is 1ms * 2^(i/2) and
+	// the last bucket contains all slower requests.
+	numLatencyBuckets = 32
+)
+
+// Default is the collector for the proxied requests.
+var Default = New()
+
+// stubbed out for testing
+var now = time.Now
+
+// Entry contains the statistics of a route or a target
+// over the last Window.
+type Entry struct {
+	Route     string  `json:"route"`
+	Service   string  `json:"service,omitempty"`
+	Target    string  `json:"target,omitempty"`
+	Requests  int64   `json:"requests"`
+	RPS       float64 `json:"rps"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	Bytes     int64   `json:"bytes"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// counts contains the number of requests, server
+// errors, response bytes, the total latency and the
+// histogram of the latencies.
+type counts struct {
+	requests, errors, bytes int64
+	latency                 time.Duration
+	hist                    [numLatencyBuckets]int64
+}
+
+func (c *counts) add(o counts) {
+	c.requests += o.requests
+	c.errors += o.errors
+	c.bytes += o.bytes
+	c.latency += o.latency
+	for i, n := range o.hist {
+		c.hist[i] += n
+	}
+}
+
+// latencyBucket returns the histogram bucket for the latency.
+func latencyBucket(d time.Duration) int {
+	if d <= time.Millisecond {
+		return 0
+	}
+	i := int(math.Ceil(2 * math.Log2(float64(d)/float64(time.Millisecond))))
+	if i >= numLatencyBuckets {
+		return numLatencyBuckets - 1
+	}
+	return i
+}
+
+// latencyBound returns the upper bound of the histogram bucket.
+func latencyBound(i int) time.Duration {
+	return time.Duration(float64(time.Millisecond) * math.Pow(2, float64(i)/2))
+}
+
+// percentile returns the upper bound of the histogram bucket
+// which contains the fraction q of the requests.
+func (c *counts) percentile(q float64) time.Duration {
+	want := int64(math.Ceil(q * float64(c.requests)))
+	var n int64
+	for i, k := range c.hist {
+		if n += k; n >= want && n > 0 {
+			return latencyBound(i)
+		}
+	}
+	return 0
+}
+
+// window is a ring of buckets which contain the
+// counts for consecutive time spans of bucketSize.
+// It is safe for concurrent use.
+type window struct {
+	mu      sync.Mutex
+	buckets [numBuckets]counts
+
+	// ids contains the number of the time span
+	// which is stored in the bucket.
+	ids [numBuckets]int64
+
+	// cur is the number of the latest time span.
+	cur int64
+
+	// requests and errors are the totals of all buckets
+	// in the window. They are updated when a bucket is
+	// cleared so that the error rate can be computed
+	// without summing up the buckets.
+	requests, errors int64
+}
+
+func bucketID(t time.Time) int64 {
+	return t.UnixNano() / int64(bucketSize)
+}
+
+// advance clears the buckets of the time spans which
+// have dropped out of the window ending in span id.
+func (w *window) advance(id int64) {
+	if id <= w.cur {
+		return
+	}
+	first := w.cur + 1
+	if id-first >= numBuckets {
+		first = id - numBuckets + 1
+	}
+	for n := first; n <= id; n++ {
+		i := n % numBuckets
+		if w.ids[i] != n {
+			w.requests -= w.buckets[i].requests
+			w.errors -= w.buckets[i].errors
+			w.ids[i], w.buckets[i] = n, counts{}
+		}
+	}
+	w.cur = id
+}
+
+// record counts a request and returns the error rate
+// over the window.
+func (w *window) record(t time.Time, status int, bytes int64, latency time.Duration) float64 {
+	id := bucketID(t)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.advance(id)
+	if id < w.cur {
+		// count requests with an earlier time in the current span
+		id = w.cur
+	}
+	b := &w.buckets[id%numBuckets]
+	b.requests++
+	b.bytes += bytes
+	b.latency += latency
+	b.hist[latencyBucket(latency)]++
+	w.requests++
+	if status >= 500 {
+		b.errors++
+		w.errors++
+	}
+	return float64(w.errors) / float64(w.requests)
+}
+
+// empty returns true if the window has no requests.
+func (w *window) empty(t time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.advance(bucketID(t))
+	return w.requests == 0
+}
+
+func (w *window) sum(t time.Time) (c counts) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id := bucketID(t)
+	for i := range w.buckets {
+		if id-w.ids[i] < numBuckets {
+			c.add(w.buckets[i])
+		}
+	}
+	return c
+}
+
+// since returns the counts of the time spans which
+// started at or after the given time.
+func (w *window) since(t, start time.Time) (c counts) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id, first := bucketID(t), bucketID(start)
+	if start.UnixNano()%int64(bucketSize) != 0 {
+		first++
+	}
+	for i := range w.buckets {
+		if id-w.ids[i] < numBuckets && w.ids[i] >= first {
+			c.add(w.buckets[i])
+		}
+	}
+	return c
+}
+
+// last returns the counts of the last complete time span.
+func (w *window) last(t time.Time) counts {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id := bucketID(t) - 1
+	if i := id % numBuckets; w.ids[i] == id {
+		return w.buckets[i]
+	}
+	return counts{}
+}
+
+type key struct {
+	route, service, target string
+}
+
+// Collector records the requests per target. It is safe
+// for concurrent use. The map of targets is guarded by mu
+// and every window has its own lock so that requests to
+// different targets do not contend.
+type Collector struct {
+	mu      sync.RWMutex
+	targets map[key]*window
+
+	// pruned is the number of the time span in which
+	// the targets without requests were last removed.
+	pruned int64
+}
+
+// New creates an empty collector.
+func New() *Collector {
+	return &Collector{targets: map[key]*window{}}
+}
+
+// Record counts a request for the target of the service on the
+// given route which took the given time. Responses with a status
+// code of 500 and above are counted as errors. Record returns the
+// error rate of the target over the last Window. The targets
+// without requests in the window are removed once per Resolution.
+func (c *Collector) Record(route, service, target string, status int, bytes int64, latency time.Duration) (errorRate float64) {
+	k := key{route, service, target}
+	t := now()
+
+	if id, last := bucketID(t), atomic.LoadInt64(&c.pruned); id > last && atomic.CompareAndSwapInt64(&c.pruned, last, id) {
+		c.prune(t)
+	}
+
+	c.mu.RLock()
+	w := c.targets[k]
+	c.mu.RUnlock()
+	if w == nil {
+		c.mu.Lock()
+		if w = c.targets[k]; w == nil {
+			w = &window{}
+			c.targets[k] = w
+		}
+		c.mu.Unlock()
+	}
+	return w.record(t, status, bytes, latency)
+}
+
+// prune removes the targets without requests in the window.
+func (c *Collector) prune(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, w := range c.targets {
+		if w.empty(t) {
+			delete(c.targets, k)
+		}
+	}
+}
+
+// Latency returns the latency below which the fraction q of the
+// requests to the target completed since the given time and the
+// number of these requests. The window starts at the first time
+// span of Resolution after the given time and covers at most the
+// last Window. The latencies are estimated from a histogram with
+// buckets which grow by a factor of sqrt(2).
+func (c *Collector) Latency(route, service, target string, q float64, since time.Time) (latency time.Duration, requests int64) {
+	c.mu.RLock()
+	w := c.targets[key{route, service, target}]
+	c.mu.RUnlock()
+	var cnt counts
+	if w != nil {
+		cnt = w.since(now(), since)
+	}
+	return cnt.percentile(q), cnt.requests
+}
+
+// Top returns the n busiest routes and targets sorted by the given
+// order which is either 'rps', 'errors' or 'bytes'. The targets
+// without requests in the window are removed from the collector.
+func (c *Collector) Top(n int, order string) (routes, targets []Entry) {
+	t := now()
+	byRoute := map[string]counts{}
+
+	c.mu.Lock()
+	for k, w := range c.targets {
+		cnt := w.sum(t)
+		if cnt.requests == 0 {
+			delete(c.targets, k)
+			continue
+		}
+		targets = append(targets, newEntry(k, cnt))
+		rc := byRoute[k.route]
+		rc.add(cnt)
+		byRoute[k.route] = rc
+	}
+	c.mu.Unlock()
+
+	for route, cnt := range byRoute {
+		routes = append(routes, newEntry(key{route: route}, cnt))
+	}
+	return top(routes, n, order), top(targets, n, order)
+}
+
+// Live returns the statistics of the routes with requests in the
+// last complete time span of Resolution sorted by route.
+func (c *Collector) Live() []Entry {
+	t := now()
+	byRoute := map[string]counts{}
+
+	c.mu.RLock()
+	for k, w := range c.targets {
+		cnt := w.last(t)
+		if cnt.requests == 0 {
+			continue
+		}
+		rc := byRoute[k.route]
+		rc.add(cnt)
+		byRoute[k.route] = rc
+	}
+	c.mu.RUnlock()
+
+	routes := []Entry{}
+	for route, cnt := range byRoute {
+		e := newEntry(key{route: route}, cnt)
+		e.RPS = float64(cnt.requests) / Resolution.Seconds()
+		routes = append(routes, e)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Route < routes[j].Route })
+	return routes
+}
+
+func newEntry(k key, c counts) Entry {
+	return Entry{
+		Route:     k.route,
+		Service:   k.service,
+		Target:    k.target,
+		Requests:  c.requests,
+		RPS:       float64(c.requests) / Window.Seconds(),
+		Errors:    c.errors,
+		ErrorRate: float64(c.errors) / float64(c.requests),
+		Bytes:     c.bytes,
+		LatencyMS: float64(c.latency) / float64(c.requests) / float64(time.Millisecond),
+	}
+}
+
+// top sorts the entries in descending order and returns
+// the first n of them. Entries with the same value are
+// sorted by route and target.
+func top(e []Entry, n int, order string) []Entry {
+	less := func(a, b Entry) bool {
+		switch order {
+		case "errors":
+			if a.ErrorRate != b.ErrorRate {
+				return a.ErrorRate > b.ErrorRate
+			}
+		case "bytes":
+			if a.Bytes != b.Bytes {
+				return a.Bytes > b.Bytes
+			}
+		}
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		return a.Target < b.Target
+	}
+	sort.Slice(e, func(i, j int) bool { return less(e[i], e[j]) })
+	if n > 0 && len(e) > n {
+		e = e[:n]
+	}
+	return e
+}
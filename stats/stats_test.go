@@ -0,0 +1,219 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCollectorTop(t *testing.T) {
+	defer func(fn func() time.Time) { now = fn }(now)
+	start := time.Unix(1500000000, 0)
+	now = func() time.Time { return start }
+
+	c := New()
+	for i := 0; i < 30; i++ {
+		c.Record("/a", "svc-a", "1.1.1.1:80", 200, 10, 0)
+	}
+	for i := 0; i < 20; i++ {
+		c.Record("/a", "svc-a", "1.1.1.2:80", 500, 0, 0)
+	}
+	for i := 0; i < 15; i++ {
+		c.Record("/b", "svc-b", "2.2.2.2:80", 200, 1000, 0)
+	}
+
+	routes, targets := c.Top(0, "rps")
+	wantRoutes := []Entry{
+		{Route: "/a", Requests: 50, RPS: 50 / 300.0, Errors: 20, ErrorRate: 0.4, Bytes: 300},
+		{Route: "/b", Requests: 15, RPS: 15 / 300.0, Errors: 0, ErrorRate: 0, Bytes: 15000},
+	}
+	if !reflect.DeepEqual(routes, wantRoutes) {
+		t.Fatalf("got routes %+v want %+v", routes, wantRoutes)
+	}
+	if got, want := len(targets), 3; got != want {
+		t.Fatalf("got %d targets want %d", got, want)
+	}
+
+	tests := []struct {
+		order string
+		n     int
+		want  []string
+	}{
+		{"rps", 0, []string{"1.1.1.1:80", "1.1.1.2:80", "2.2.2.2:80"}},
+		{"rps", 2, []string{"1.1.1.1:80", "1.1.1.2:80"}},
+		{"errors", 1, []string{"1.1.1.2:80"}},
+		{"bytes", 0, []string{"2.2.2.2:80", "1.1.1.1:80", "1.1.1.2:80"}},
+	}
+	for i, tt := range tests {
+		_, targets := c.Top(tt.n, tt.order)
+		var got []string
+		for _, e := range targets {
+			got = append(got, e.Target)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: got %v want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestCollectorWindow(t *testing.T) {
+	defer func(fn func() time.Time) { now = fn }(now)
+	start := time.Unix(1500000000, 0)
+	now = func() time.Time { return start }
+
+	c := New()
+	c.Record("/a", "svc-a", "1.1.1.1:80", 200, 10, 0)
+
+	now = func() time.Time { return start.Add(Window / 2) }
+	c.Record("/a", "svc-a", "1.1.1.1:80", 200, 10, 0)
+	if _, targets := c.Top(0, "rps"); targets[0].Requests != 2 {
+		t.Fatalf("got %d requests want 2", targets[0].Requests)
+	}
+
+	// the first request has dropped out of the window
+	now = func() time.Time { return start.Add(Window) }
+	if _, targets := c.Top(0, "rps"); targets[0].Requests != 1 {
+		t.Fatalf("got %d requests want 1", targets[0].Requests)
+	}
+
+	// targets without requests are removed
+	now = func() time.Time { return start.Add(2 * Window) }
+	if routes, targets := c.Top(0, "rps"); len(routes) != 0 || len(targets) != 0 {
+		t.Fatalf("got %v %v want no entries", routes, targets)
+	}
+	if got := len(c.targets); got != 0 {
+		t.Fatalf("got %d targets in collector want 0", got)
+	}
+}
+
+func TestCollectorRecordErrorRate(t *testing.T) {
+	c := New()
+	if got, want := c.Record("/a", "svc-a", "1.1.1.1:80", 500, 0, 0), 1.0; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	c.Record("/a", "svc-a", "1.1.1.1:80", 200, 0, 0)
+	c.Record("/a", "svc-a", "1.1.1.1:80", 200, 0, 0)
+	if got, want := c.Record("/a", "svc-a", "1.1.1.1:80", 404, 0, 0), 0.25; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
+func TestCollectorLive(t *testing.T) {
+	defer func(fn func() time.Time) { now = fn }(now)
+	start := time.Unix(1500000000, 0)
+	now = func() time.Time { return start }
+
+	c := New()
+	for i := 0; i < 10; i++ {
+		c.Record("/b", "svc-b", "2.2.2.2:80", 200, 100, 20*time.Millisecond)
+	}
+	c.Record("/a", "svc-a", "1.1.1.1:80", 200, 10, 10*time.Millisecond)
+	c.Record("/a", "svc-a", "1.1.1.2:80", 502, 0, 30*time.Millisecond)
+
+	// the current time span is not complete
+	if got := c.Live(); len(got) != 0 {
+		t.Fatalf("got %+v want no entries", got)
+	}
+
+	now = func() time.Time { return start.Add(Resolution) }
+	want := []Entry{
+		{Route: "/a", Requests: 2, RPS: 0.2, Errors: 1, ErrorRate: 0.5, Bytes: 10, LatencyMS: 20},
+		{Route: "/b", Requests: 10, RPS: 1, Bytes: 1000, LatencyMS: 20},
+	}
+	if got := c.Live(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+
+	now = func() time.Time { return start.Add(2 * Resolution) }
+	if got := c.Live(); len(got) != 0 {
+		t.Fatalf("got %+v want no entries", got)
+	}
+}
+
+func TestCollectorLatency(t *testing.T) {
+	defer func(fn func() time.Time) { now = fn }(now)
+	start := time.Unix(1500000000, 0)
+	now = func() time.Time { return start }
+
+	c := New()
+	for i := 0; i < 98; i++ {
+		c.Record("/a", "svc", "1.1.1.1:80", 200, 0, 10*time.Millisecond)
+	}
+	c.Record("/a", "svc", "1.1.1.1:80", 200, 0, 500*time.Millisecond)
+	c.Record("/a", "svc", "1.1.1.1:80", 200, 0, 2*time.Second)
+
+	tests := []struct {
+		q     float64
+		since time.Time
+		want  time.Duration
+		n     int64
+	}{
+		{0.5, time.Time{}, latencyBound(latencyBucket(10 * time.Millisecond)), 100},
+		{0.99, time.Time{}, latencyBound(latencyBucket(500 * time.Millisecond)), 100},
+		{1, time.Time{}, latencyBound(latencyBucket(2 * time.Second)), 100},
+		{0.99, start.Add(time.Second), 0, 0},
+	}
+	for _, tt := range tests {
+		d, n := c.Latency("/a", "svc", "1.1.1.1:80", tt.q, tt.since)
+		if d != tt.want || n != tt.n {
+			t.Errorf("p%g since %v: got %s, %d want %s, %d", tt.q*100, tt.since, d, n, tt.want, tt.n)
+		}
+	}
+
+	if d, n := c.Latency("/b", "svc", "1.1.1.1:80", 0.99, time.Time{}); d != 0 || n != 0 {
+		t.Errorf("unknown target: got %s, %d", d, n)
+	}
+}
+
+func TestLatencyBucket(t *testing.T) {
+	for _, d := range []time.Duration{0, time.Millisecond, 3 * time.Millisecond, 100 * time.Millisecond, time.Minute} {
+		i := latencyBucket(d)
+		if d > latencyBound(i) && i < numLatencyBuckets-1 {
+			t.Errorf("%s: bound %s of bucket %d too small", d, latencyBound(i), i)
+		}
+		if i > 0 && d <= latencyBound(i-1) {
+			t.Errorf("%s: bucket %d too large", d, i)
+		}
+	}
+}
+
+func TestCollectorRecordErrorRateWindow(t *testing.T) {
+	defer func(fn func() time.Time) { now = fn }(now)
+	start := time.Unix(1500000000, 0)
+	now = func() time.Time { return start }
+
+	c := New()
+	c.Record("/a", "svc-a", "1.1.1.1:80", 500, 0, 0)
+	c.Record("/a", "svc-a", "1.1.1.1:80", 200, 0, 0)
+
+	// the error drops out of the window
+	now = func() time.Time { return start.Add(Window - Resolution) }
+	if got, want := c.Record("/a", "svc-a", "1.1.1.1:80", 200, 0, 0), 1/3.0; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	now = func() time.Time { return start.Add(Window) }
+	if got, want := c.Record("/a", "svc-a", "1.1.1.1:80", 200, 0, 0), 0.0; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	now = func() time.Time { return start.Add(3 * Window) }
+	if got, want := c.Record("/a", "svc-a", "1.1.1.1:80", 500, 0, 0), 1.0; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
+func TestCollectorRecordPrunes(t *testing.T) {
+	defer func(fn func() time.Time) { now = fn }(now)
+	start := time.Unix(1500000000, 0)
+	now = func() time.Time { return start }
+
+	c := New()
+	c.Record("/a", "svc-a", "1.1.1.1:80", 200, 0, 0)
+	c.Record("/a", "svc-a", "1.1.1.2:80", 200, 0, 0)
+
+	// targets without requests are removed by the next request
+	now = func() time.Time { return start.Add(Window) }
+	c.Record("/a", "svc-a", "1.1.1.2:80", 200, 0, 0)
+	if got, want := len(c.targets), 1; got != want {
+		t.Fatalf("got %d targets want %d", got, want)
+	}
+}
@@ -0,0 +1,212 @@
+// Package tracing creates and propagates trace headers for proxied
+// requests and reports the recorded spans to a Zipkin compatible
+// collector.
+//
+// Incoming requests are accepted with either B3 or W3C traceparent
+// headers. The headers sent to the upstream servers use the
+// configured format.
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mrand "math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eBay/fabio/config"
+)
+
+// Default stores the active tracer. Tracing is disabled if it is nil.
+var Default *Tracer
+
+// Tracer creates spans for requests and hands the finished spans
+// to the reporter.
+type Tracer struct {
+	format      string
+	serviceName string
+	localIP     string
+	sampleRate  float64
+	reporter    *reporter
+}
+
+// New creates a tracer which reports the spans to the configured
+// collector. localIP is reported as the address of the local endpoint.
+func New(cfg config.Tracing, localIP string) (*Tracer, error) {
+	if cfg.Format != "b3" && cfg.Format != "w3c" {
+		return nil, fmt.Errorf("tracing: invalid format %q", cfg.Format)
+	}
+	if cfg.Collector == "" {
+		return nil, fmt.Errorf("tracing: collector missing")
+	}
+	return &Tracer{
+		format:      cfg.Format,
+		serviceName: cfg.ServiceName,
+		localIP:     localIP,
+		sampleRate:  cfg.SampleRate,
+		reporter:    newReporter(cfg.Collector, cfg.Interval),
+	}, nil
+}
+
+// Span records the timing and metadata of a single proxied request.
+type Span struct {
+	TraceID  string
+	ID       string
+	ParentID string
+	Sampled  bool
+	Name     string
+	Start    time.Time
+	Tags     map[string]string
+
+	tracer *Tracer
+}
+
+// StartSpan creates a new span for the request which continues the
+// trace from the request headers if there is one and replaces the
+// trace headers of the request with the ones for the new span.
+// It returns nil if the tracer is nil.
+func (t *Tracer) StartSpan(r *http.Request, name string) *Span {
+	if t == nil {
+		return nil
+	}
+
+	s := &Span{
+		ID:     newID(8),
+		Name:   name,
+		Start:  time.Now(),
+		Tags:   map[string]string{},
+		tracer: t,
+	}
+
+	traceID, parentID, sampled, ok := extract(r.Header)
+	if ok {
+		s.TraceID, s.ParentID = traceID, parentID
+	} else {
+		s.TraceID = newID(16)
+	}
+	if sampled != nil {
+		s.Sampled = *sampled
+	} else {
+		s.Sampled = t.sampleRate >= 1 || (t.sampleRate > 0 && mrand.Float64() < t.sampleRate)
+	}
+
+	inject(r.Header, t.format, s)
+	return s
+}
+
+// SetTag adds a tag to the span. It is a no-op on a nil span.
+func (s *Span) SetTag(k, v string) {
+	if s == nil {
+		return
+	}
+	s.Tags[k] = v
+}
+
+// Finish records the duration of the span and reports it if the
+// span is sampled. It is a no-op on a nil span.
+func (s *Span) Finish() {
+	if s == nil || !s.Sampled {
+		return
+	}
+	s.tracer.reporter.report(s.zipkin(s.tracer.serviceName, s.tracer.localIP, time.Since(s.Start)))
+}
+
+// B3 and W3C trace context header names.
+const (
+	b3TraceID      = "X-B3-Traceid"
+	b3SpanID       = "X-B3-Spanid"
+	b3ParentSpanID = "X-B3-Parentspanid"
+	b3Sampled      = "X-B3-Sampled"
+	b3Flags        = "X-B3-Flags"
+	traceparent    = "Traceparent"
+)
+
+// extract returns the trace id, the id of the parent span and the
+// sampling decision from the W3C traceparent or the B3 headers.
+// sampled is nil if the headers do not contain a sampling decision.
+func extract(h http.Header) (traceID, spanID string, sampled *bool, ok bool) {
+	if v := h.Get(traceparent); v != "" {
+		p := strings.Split(strings.ToLower(strings.TrimSpace(v)), "-")
+		if len(p) >= 4 && len(p[0]) == 2 && isID(p[1], 32) && isID(p[2], 16) && len(p[3]) == 2 {
+			flags, err := hex.DecodeString(p[3])
+			if err == nil {
+				s := flags[0]&1 == 1
+				return p[1], p[2], &s, true
+			}
+		}
+	}
+
+	traceID, spanID = strings.ToLower(h.Get(b3TraceID)), strings.ToLower(h.Get(b3SpanID))
+	if h.Get(b3Flags) == "1" {
+		s := true
+		sampled = &s
+	} else if v := h.Get(b3Sampled); v != "" {
+		s := v == "1" || v == "true"
+		sampled = &s
+	}
+	if (isID(traceID, 16) || isID(traceID, 32)) && isID(spanID, 16) {
+		return traceID, spanID, sampled, true
+	}
+	return "", "", sampled, false
+}
+
+// inject replaces the trace headers with the ones for the span.
+func inject(h http.Header, format string, s *Span) {
+	for _, k := range []string{b3TraceID, b3SpanID, b3ParentSpanID, b3Sampled, b3Flags, traceparent} {
+		h.Del(k)
+	}
+
+	switch format {
+	case "w3c":
+		flags := "00"
+		if s.Sampled {
+			flags = "01"
+		}
+		h.Set(traceparent, "00-"+padID(s.TraceID, 32)+"-"+s.ID+"-"+flags)
+
+	default:
+		h.Set(b3TraceID, s.TraceID)
+		h.Set(b3SpanID, s.ID)
+		if s.ParentID != "" {
+			h.Set(b3ParentSpanID, s.ParentID)
+		}
+		if s.Sampled {
+			h.Set(b3Sampled, "1")
+		} else {
+			h.Set(b3Sampled, "0")
+		}
+	}
+}
+
+// isID returns true if s is a non-zero lower case hex
+// string of length n.
+func isID(s string, n int) bool {
+	if len(s) != n || strings.Trim(s, "0") == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// padID left pads a trace id with zeros to length n.
+func padID(s string, n int) string {
+	if len(s) >= n {
+		return s
+	}
+	return strings.Repeat("0", n-len(s)) + s
+}
+
+// newID returns a random id of n bytes as hex string.
+func newID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("tracing: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
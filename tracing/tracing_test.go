@@ -0,0 +1,191 @@
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eBay/fabio/config"
+)
+
+func TestExtract(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		desc            string
+		h               http.Header
+		traceID, spanID string
+		sampled         *bool
+		ok              bool
+	}{
+		{"no headers", http.Header{}, "", "", nil, false},
+		{
+			"b3 64 bit",
+			http.Header{b3TraceID: {"463ac35c9f6413ad"}, b3SpanID: {"a2fb4a1d1a96d312"}, b3Sampled: {"1"}},
+			"463ac35c9f6413ad", "a2fb4a1d1a96d312", &yes, true,
+		},
+		{
+			"b3 128 bit not sampled",
+			http.Header{b3TraceID: {"463AC35C9F6413AD48485A3953BB6124"}, b3SpanID: {"a2fb4a1d1a96d312"}, b3Sampled: {"0"}},
+			"463ac35c9f6413ad48485a3953bb6124", "a2fb4a1d1a96d312", &no, true,
+		},
+		{
+			"b3 debug flag",
+			http.Header{b3TraceID: {"463ac35c9f6413ad"}, b3SpanID: {"a2fb4a1d1a96d312"}, b3Flags: {"1"}},
+			"463ac35c9f6413ad", "a2fb4a1d1a96d312", &yes, true,
+		},
+		{
+			"b3 sampling decision only",
+			http.Header{b3Sampled: {"0"}},
+			"", "", &no, false,
+		},
+		{
+			"b3 invalid span id",
+			http.Header{b3TraceID: {"463ac35c9f6413ad"}, b3SpanID: {"xyz"}},
+			"", "", nil, false,
+		},
+		{
+			"w3c sampled",
+			http.Header{traceparent: {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}},
+			"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", &yes, true,
+		},
+		{
+			"w3c not sampled",
+			http.Header{traceparent: {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"}},
+			"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", &no, true,
+		},
+		{
+			"w3c zero trace id",
+			http.Header{traceparent: {"00-00000000000000000000000000000000-00f067aa0ba902b7-01"}},
+			"", "", nil, false,
+		},
+	}
+
+	for _, tt := range tests {
+		traceID, spanID, sampled, ok := extract(tt.h)
+		if got, want := ok, tt.ok; got != want {
+			t.Errorf("%s: got ok %v want %v", tt.desc, got, want)
+		}
+		if got, want := traceID, tt.traceID; got != want {
+			t.Errorf("%s: got trace id %q want %q", tt.desc, got, want)
+		}
+		if got, want := spanID, tt.spanID; got != want {
+			t.Errorf("%s: got span id %q want %q", tt.desc, got, want)
+		}
+		if (sampled == nil) != (tt.sampled == nil) || (sampled != nil && *sampled != *tt.sampled) {
+			t.Errorf("%s: got sampled %v want %v", tt.desc, sampled, tt.sampled)
+		}
+	}
+}
+
+func TestStartSpan(t *testing.T) {
+	tr := &Tracer{format: "b3", sampleRate: 0, reporter: &reporter{spans: make(chan zipkinSpan, 1)}}
+
+	// new trace
+	r := &http.Request{Header: http.Header{}}
+	s := tr.StartSpan(r, "svc")
+	if !isID(s.TraceID, 32) || !isID(s.ID, 16) || s.ParentID != "" {
+		t.Fatalf("got invalid span %+v", s)
+	}
+	if s.Sampled {
+		t.Fatal("got sampled span for sample rate 0")
+	}
+	if got, want := r.Header.Get(b3TraceID), s.TraceID; got != want {
+		t.Errorf("got trace id header %q want %q", got, want)
+	}
+	if got, want := r.Header.Get(b3SpanID), s.ID; got != want {
+		t.Errorf("got span id header %q want %q", got, want)
+	}
+	if got, want := r.Header.Get(b3Sampled), "0"; got != want {
+		t.Errorf("got sampled header %q want %q", got, want)
+	}
+
+	// continue w3c trace with b3 headers
+	r = &http.Request{Header: http.Header{traceparent: {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}}
+	s = tr.StartSpan(r, "svc")
+	if got, want := s.TraceID, "4bf92f3577b34da6a3ce929d0e0e4736"; got != want {
+		t.Errorf("got trace id %q want %q", got, want)
+	}
+	if got, want := s.ParentID, "00f067aa0ba902b7"; got != want {
+		t.Errorf("got parent id %q want %q", got, want)
+	}
+	if !s.Sampled {
+		t.Error("got unsampled span want sampled")
+	}
+	if got := r.Header.Get(traceparent); got != "" {
+		t.Errorf("got traceparent header %q want none", got)
+	}
+	if got, want := r.Header.Get(b3ParentSpanID), "00f067aa0ba902b7"; got != want {
+		t.Errorf("got parent span id header %q want %q", got, want)
+	}
+
+	// continue b3 trace with w3c headers
+	tr.format = "w3c"
+	r = &http.Request{Header: http.Header{b3TraceID: {"463ac35c9f6413ad"}, b3SpanID: {"a2fb4a1d1a96d312"}, b3Sampled: {"1"}}}
+	s = tr.StartSpan(r, "svc")
+	if got, want := r.Header.Get(traceparent), "00-0000000000000000463ac35c9f6413ad-"+s.ID+"-01"; got != want {
+		t.Errorf("got traceparent %q want %q", got, want)
+	}
+	if got := r.Header.Get(b3TraceID); got != "" {
+		t.Errorf("got b3 header %q want none", got)
+	}
+
+	// nil tracer returns nil span which is safe to use
+	var nt *Tracer
+	ns := nt.StartSpan(r, "svc")
+	if ns != nil {
+		t.Fatalf("got %v want nil", ns)
+	}
+	ns.SetTag("a", "b")
+	ns.Finish()
+}
+
+func TestReporter(t *testing.T) {
+	got := make(chan []zipkinSpan, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var spans []zipkinSpan
+		if err := json.NewDecoder(r.Body).Decode(&spans); err != nil {
+			t.Errorf("got %v want nil", err)
+		}
+		got <- spans
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	tr, err := New(config.Tracing{Format: "b3", Collector: srv.URL, ServiceName: "fabio", SampleRate: 1, Interval: 10 * time.Millisecond}, "1.2.3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := tr.StartSpan(&http.Request{Header: http.Header{}}, "svc")
+	s.SetTag("http.status_code", "200")
+	s.Finish()
+
+	select {
+	case spans := <-got:
+		if len(spans) != 1 {
+			t.Fatalf("got %d spans want 1", len(spans))
+		}
+		zs := spans[0]
+		if zs.TraceID != s.TraceID || zs.ID != s.ID || zs.Name != "svc" || zs.Kind != "SERVER" {
+			t.Errorf("got span %+v", zs)
+		}
+		if got, want := zs.LocalEndpoint, (zipkinEndpoint{ServiceName: "fabio", IPv4: "1.2.3.4"}); got != want {
+			t.Errorf("got endpoint %+v want %+v", got, want)
+		}
+		if got, want := zs.Tags["http.status_code"], "200"; got != want {
+			t.Errorf("got status tag %q want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(config.Tracing{Format: "foo", Collector: "http://a"}, ""); err == nil {
+		t.Error("got nil want error for invalid format")
+	}
+	if _, err := New(config.Tracing{Format: "b3"}, ""); err == nil {
+		t.Error("got nil want error for missing collector")
+	}
+}
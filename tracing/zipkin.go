@@ -0,0 +1,132 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/eBay/fabio/metrics"
+)
+
+// maxBatch is the maximum number of spans sent in a single request.
+const maxBatch = 100
+
+// zipkinSpan is the Zipkin v2 JSON representation of a span.
+type zipkinSpan struct {
+	TraceID       string            `json:"traceId"`
+	ID            string            `json:"id"`
+	ParentID      string            `json:"parentId,omitempty"`
+	Name          string            `json:"name"`
+	Kind          string            `json:"kind"`
+	Timestamp     int64             `json:"timestamp"`
+	Duration      int64             `json:"duration"`
+	LocalEndpoint zipkinEndpoint    `json:"localEndpoint"`
+	Tags          map[string]string `json:"tags,omitempty"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	IPv4        string `json:"ipv4,omitempty"`
+	IPv6        string `json:"ipv6,omitempty"`
+}
+
+func (s *Span) zipkin(serviceName, localIP string, d time.Duration) zipkinSpan {
+	ep := zipkinEndpoint{ServiceName: serviceName}
+	if ip := net.ParseIP(localIP); ip != nil {
+		if ip.To4() != nil {
+			ep.IPv4 = ip.String()
+		} else {
+			ep.IPv6 = ip.String()
+		}
+	}
+
+	// zipkin requires a minimum duration of 1µs
+	dur := int64(d / time.Microsecond)
+	if dur < 1 {
+		dur = 1
+	}
+
+	return zipkinSpan{
+		TraceID:       s.TraceID,
+		ID:            s.ID,
+		ParentID:      s.ParentID,
+		Name:          s.Name,
+		Kind:          "SERVER",
+		Timestamp:     s.Start.UnixNano() / int64(time.Microsecond),
+		Duration:      dur,
+		LocalEndpoint: ep,
+		Tags:          s.Tags,
+	}
+}
+
+// reporter collects the finished spans and sends them in batches
+// to the collector. Spans are dropped if the collector cannot keep up.
+type reporter struct {
+	url    string
+	client *http.Client
+	spans  chan zipkinSpan
+}
+
+func newReporter(url string, interval time.Duration) *reporter {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	r := &reporter{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+		spans:  make(chan zipkinSpan, 10*maxBatch),
+	}
+	go r.loop(interval)
+	return r
+}
+
+func (r *reporter) report(s zipkinSpan) {
+	select {
+	case r.spans <- s:
+	default:
+		metrics.DefaultRegistry.GetCounter("tracing.dropped").Inc(1)
+	}
+}
+
+func (r *reporter) loop(interval time.Duration) {
+	var batch []zipkinSpan
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case s := <-r.spans:
+			batch = append(batch, s)
+			if len(batch) < maxBatch {
+				continue
+			}
+		case <-tick.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		r.send(batch)
+		batch = nil
+	}
+}
+
+func (r *reporter) send(spans []zipkinSpan) {
+	data, err := json.Marshal(spans)
+	if err != nil {
+		log.Printf("[ERROR] tracing: Cannot encode spans. %s", err)
+		return
+	}
+	resp, err := r.client.Post(r.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		log.Printf("[WARN] tracing: Cannot send %d spans to %s. %s", len(spans), r.url, err)
+		metrics.DefaultRegistry.GetCounter("tracing.dropped").Inc(int64(len(spans)))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		log.Printf("[WARN] tracing: Collector %s returned %s", r.url, resp.Status)
+		metrics.DefaultRegistry.GetCounter("tracing.dropped").Inc(int64(len(spans)))
+	}
+}